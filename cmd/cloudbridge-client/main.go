@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
 	"github.com/2gc-dev/cloudbridge-client/pkg/api"
 	"github.com/2gc-dev/cloudbridge-client/pkg/auth"
 	"github.com/2gc-dev/cloudbridge-client/pkg/config"
+	"github.com/2gc-dev/cloudbridge-client/pkg/control"
 	"github.com/2gc-dev/cloudbridge-client/pkg/errors"
+	"github.com/2gc-dev/cloudbridge-client/pkg/netcheck"
 	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
 	"github.com/2gc-dev/cloudbridge-client/pkg/relay"
 	"github.com/2gc-dev/cloudbridge-client/pkg/service"
@@ -48,6 +54,23 @@ var (
 	p2pMode bool
 	peerID  string
 
+	// Netcheck flags
+	netcheckJSON bool
+
+	// Policy test flags
+	policyFile    string
+	policySrc     string
+	policyDst     string
+	policySrcTags []string
+	policyDstTags []string
+	policyProto   string
+	policyPort    int
+	policyJSON    bool
+
+	// Peer approval flags
+	controlSocket string
+	peersJSON     bool
+
 	// HTTP API specific flags
 	insecureSkipTLSVerify bool
 	logLevel              string
@@ -106,6 +129,9 @@ func main() {
 	rootCmd.AddCommand(createTunnelCommand())
 	rootCmd.AddCommand(createServiceCommand())
 	rootCmd.AddCommand(createWireGuardCommand())
+	rootCmd.AddCommand(createNetcheckCommand())
+	rootCmd.AddCommand(createPolicyCommand())
+	rootCmd.AddCommand(createPeersCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -592,6 +618,196 @@ func runWireGuardStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// createNetcheckCommand creates the network diagnostics subcommand
+func createNetcheckCommand() *cobra.Command {
+	netcheckCmd := &cobra.Command{
+		Use:   "netcheck",
+		Short: "Diagnose NAT type and network connectivity",
+		Long: "Classify NAT mapping and filtering behaviour (RFC 5780) using the configured STUN servers " +
+			"and report UDP reachability, IPv6, hairpinning, MTU and relay POP latency",
+		RunE: runNetcheck,
+	}
+
+	netcheckCmd.Flags().BoolVar(&netcheckJSON, "json", false, "Print the report as JSON")
+
+	return netcheckCmd
+}
+
+// runNetcheck runs network diagnostics and prints the report
+func runNetcheck(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	netcheckConfig := netcheck.DefaultConfig()
+	netcheckConfig.STUNServers = cfg.ICE.STUNServers
+	for _, ep := range cfg.Relay.Endpoints {
+		id, port := ep.ID, ep.Port
+		if id == "" {
+			id = ep.Host
+		}
+		if port == 0 {
+			port = cfg.Relay.Port
+		}
+		netcheckConfig.POPs = append(netcheckConfig.POPs, netcheck.POP{ID: id, Address: net.JoinHostPort(ep.Host, strconv.Itoa(port))})
+	}
+	if len(netcheckConfig.POPs) == 0 && cfg.Relay.Host != "" {
+		netcheckConfig.POPs = []netcheck.POP{{ID: cfg.Relay.Host, Address: net.JoinHostPort(cfg.Relay.Host, strconv.Itoa(cfg.Relay.Port))}}
+	}
+
+	var logger netcheck.Logger = nopLogger{}
+	if verbose {
+		logger = &p2pLogger{}
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+	defer cancel()
+	report, err := netcheck.NewChecker(netcheckConfig, logger).Run(ctx)
+	if err != nil {
+		return fmt.Errorf("netcheck failed: %w", err)
+	}
+
+	if netcheckJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Network Check Report:\n")
+	fmt.Printf("=====================\n")
+	fmt.Printf("UDP: %t\n", report.UDP)
+	fmt.Printf("IPv4: %t %s\n", report.IPv4, report.GlobalV4)
+	fmt.Printf("IPv6: %t %s\n", report.IPv6, report.GlobalV6)
+	fmt.Printf("NAT Type: %s\n", report.NATType)
+	fmt.Printf("Mapping Behavior: %s\n", report.MappingBehavior)
+	fmt.Printf("Filtering Behavior: %s\n", report.FilteringBehavior)
+	if report.Hairpinning != nil {
+		fmt.Printf("Hairpinning: %t\n", *report.Hairpinning)
+	} else {
+		fmt.Printf("Hairpinning: unknown\n")
+	}
+	if report.MTU > 0 {
+		fmt.Printf("MTU: %d (%s)\n", report.MTU, report.Interface)
+	}
+	for server, latency := range report.STUNLatency {
+		fmt.Printf("STUN %s: %v\n", server, latency.Round(time.Millisecond))
+	}
+	for popID, latency := range report.POPLatency {
+		fmt.Printf("POP %s: %v\n", popID, latency.Round(time.Millisecond))
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("Warning: %s\n", e)
+	}
+	fmt.Printf("Direct connectivity: %s\n", report.DirectConnectivity())
+
+	return nil
+}
+
+// createPolicyCommand creates the mesh ACL policy subcommand
+func createPolicyCommand() *cobra.Command {
+	policyCmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect the mesh ACL policy",
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Evaluate a hypothetical flow against the ACL policy",
+		Long: "Compile the ACL policy (--policy or p2p.acl.policy_file) and report whether it accepts " +
+			"a flow from --src to --dst; without --proto the whole peer connection is evaluated. " +
+			"Exits with an error if the flow is denied.",
+		Example: "  cloudbridge-client policy test --policy acl.json --src app1 --dst db1 --proto tcp --port 5432",
+		RunE:    runPolicyTest,
+	}
+
+	testCmd.Flags().StringVar(&policyFile, "policy", "", "ACL policy file (default: p2p.acl.policy_file from config)")
+	testCmd.Flags().StringVar(&policySrc, "src", "", "Source peer ID")
+	testCmd.Flags().StringVar(&policyDst, "dst", "", "Destination peer ID")
+	testCmd.Flags().StringSliceVar(&policySrcTags, "src-tag", nil, "Extra source peer tags (tag:<name>)")
+	testCmd.Flags().StringSliceVar(&policyDstTags, "dst-tag", nil, "Extra destination peer tags (tag:<name>)")
+	testCmd.Flags().StringVar(&policyProto, "proto", "", "Protocol: tcp, udp or icmp (empty: any port)")
+	testCmd.Flags().IntVar(&policyPort, "port", 0, "Destination port for tcp/udp")
+	testCmd.Flags().BoolVar(&policyJSON, "json", false, "Print the decision as JSON")
+	_ = testCmd.MarkFlagRequired("src") //nolint:errcheck // flag is defined above
+	_ = testCmd.MarkFlagRequired("dst") //nolint:errcheck // flag is defined above
+
+	policyCmd.AddCommand(testCmd)
+	return policyCmd
+}
+
+// runPolicyTest evaluates a hypothetical flow against the ACL policy
+func runPolicyTest(cmd *cobra.Command, args []string) error {
+	path := policyFile
+	if path == "" {
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		path = cfg.P2P.ACL.PolicyFile
+	}
+	if path == "" {
+		return fmt.Errorf("no ACL policy: use --policy or set p2p.acl.policy_file")
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- policy path is provided by the operator
+	if err != nil {
+		return fmt.Errorf("failed to read ACL policy: %w", err)
+	}
+	engine, err := acl.ParseAndCompile(data)
+	if err != nil {
+		return err
+	}
+
+	proto := strings.ToLower(policyProto)
+	switch proto {
+	case "", acl.ProtoICMP:
+	case acl.ProtoTCP, acl.ProtoUDP:
+		if policyPort < 1 || policyPort > 65535 {
+			return fmt.Errorf("--port is required for %s", proto)
+		}
+	default:
+		return fmt.Errorf("unsupported protocol: %s", policyProto)
+	}
+
+	decision := engine.Evaluate(acl.Flow{
+		Src:   acl.Identity{ID: policySrc, Tags: policySrcTags},
+		Dst:   acl.Identity{ID: policyDst, Tags: policyDstTags},
+		Proto: proto,
+		Port:  policyPort,
+	})
+
+	if policyJSON {
+		data, err := json.MarshalIndent(decision, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode decision: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		flow := "connection"
+		if proto != "" {
+			flow = proto
+			if proto != acl.ProtoICMP {
+				flow = fmt.Sprintf("%s/%d", proto, policyPort)
+			}
+		}
+		fmt.Printf("Policy: %s (%d rules)\n", path, engine.Rules())
+		fmt.Printf("Flow: %s %v -> %s %v %s\n",
+			policySrc, append(engine.TagsOf(policySrc), policySrcTags...),
+			policyDst, append(engine.TagsOf(policyDst), policyDstTags...), flow)
+		fmt.Printf("Decision: %s\n", decision)
+	}
+
+	if !decision.Allowed {
+		cmd.SilenceUsage = true
+		return acl.ErrDenied
+	}
+	return nil
+}
+
 // runServiceInstall installs the service
 func runServiceInstall(cmd *cobra.Command, args []string) error {
 	log.Printf("Installing CloudBridge Client service...")
@@ -717,6 +933,14 @@ func copyFile(src, dst string) error {
 	return os.WriteFile(dst, input, 0644) //nolint:gosec // Config files need readable permissions
 }
 
+// nopLogger discards log messages
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Warn(string, ...interface{})  {}
+
 // p2pLogger implements the p2p.Logger interface
 type p2pLogger struct{}
 
@@ -907,6 +1131,20 @@ func runP2P(cmd *cobra.Command, args []string) error {
 
 	log.Printf("P2P mesh started successfully")
 
+	// Control API: подтверждение пиров из очереди командой "peers"
+	if cfg.P2P.Control.Enabled {
+		controlServer := control.NewServer(&control.Config{Socket: cfg.P2P.Control.Socket}, p2pManager, p2pLogger)
+		if err := controlServer.Start(); err != nil {
+			log.Printf("Failed to start control API: %v", err)
+		} else {
+			defer func() {
+				if err := controlServer.Stop(); err != nil {
+					log.Printf("Failed to stop control API: %v", err)
+				}
+			}()
+		}
+	}
+
 	// Check L3-overlay network status
 	if p2pManager.IsL3OverlayReady() {
 		log.Printf("L3-overlay network ready: Peer IP=%s, Tenant CIDR=%s",
@@ -1041,3 +1279,90 @@ func validateFlags(cfg *types.Config, transportMode string) error {
 
 	return nil
 }
+
+// createPeersCommand creates the pending peer approval subcommand
+func createPeersCommand() *cobra.Command {
+	peersCmd := &cobra.Command{
+		Use:   "peers",
+		Short: "Approve or reject peers awaiting approval",
+		Long: "Manage the approval queue of a running client (whitelist without auto_approve) " +
+			"through its local control API socket.",
+	}
+	peersCmd.PersistentFlags().StringVar(&controlSocket, "socket", "",
+		"Control API socket (default: p2p.control.socket from config or ~/.cloudbridge-client/control.sock)")
+
+	pendingCmd := &cobra.Command{
+		Use:   "pending",
+		Short: "List peers awaiting approval",
+		Args:  cobra.NoArgs,
+		RunE:  runPeersPending,
+	}
+	pendingCmd.Flags().BoolVar(&peersJSON, "json", false, "Print pending peers as JSON")
+
+	approveCmd := &cobra.Command{
+		Use:     "approve <peer-id>",
+		Short:   "Approve a peer and accept its connection request",
+		Args:    cobra.ExactArgs(1),
+		Example: "  cloudbridge-client peers approve peer-office-gw",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := newControlClient().ApprovePeer(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Peer %s approved\n", args[0])
+			return nil
+		},
+	}
+
+	rejectCmd := &cobra.Command{
+		Use:   "reject <peer-id>",
+		Short: "Reject a peer and close its session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := newControlClient().RejectPeer(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Peer %s rejected\n", args[0])
+			return nil
+		},
+	}
+
+	peersCmd.AddCommand(pendingCmd, approveCmd, rejectCmd)
+	return peersCmd
+}
+
+// newControlClient connects to the control API socket from --socket or configuration
+func newControlClient() *control.Client {
+	socket := controlSocket
+	if socket == "" {
+		if cfg, err := config.LoadConfig(configFile); err == nil {
+			socket = cfg.P2P.Control.Socket
+		}
+	}
+	return control.NewClient(socket)
+}
+
+// runPeersPending lists peers awaiting approval
+func runPeersPending(cmd *cobra.Command, args []string) error {
+	peers, err := newControlClient().PendingPeers()
+	if err != nil {
+		return err
+	}
+
+	if peersJSON {
+		data, err := json.MarshalIndent(peers, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode pending peers: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(peers) == 0 {
+		fmt.Println("No peers awaiting approval")
+		return nil
+	}
+	for _, peer := range peers {
+		fmt.Printf("%s\tsession %s\trequested %s ago\n",
+			peer.PeerID, peer.SessionID, time.Since(peer.RequestedAt).Round(time.Second))
+	}
+	return nil
+}
@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/config"
+	"github.com/2gc-dev/cloudbridge-client/pkg/discovery"
 	quic "github.com/quic-go/quic-go"
 )
 
@@ -32,6 +36,7 @@ const (
 
 func main() {
 	var (
+		configPath  string
 		tokenPath   string
 		tokenInline string
 		host        string
@@ -44,6 +49,7 @@ func main() {
 		timeout     time.Duration
 	)
 
+	flag.StringVar(&configPath, "config", "", "Client config file; relay host/port are derived from it unless -host/-port are set")
 	flag.StringVar(&tokenPath, "token-file", "", "Path to JWT token file")
 	flag.StringVar(&tokenInline, "token", "", "JWT token value (overrides token-file if set)")
 	flag.StringVar(&host, "host", defaultHost, "Relay host")
@@ -56,6 +62,10 @@ func main() {
 	flag.DurationVar(&timeout, "timeout", 25*time.Second, "Dial timeout")
 	flag.Parse()
 
+	if configPath != "" {
+		host, port, serverName = relayFromConfig(configPath, host, port, serverName)
+	}
+
 	m := modeType(strings.ToLower(modeStr))
 	if m != modeSend && m != modeRecv {
 		log.Fatalf("invalid mode: %s (expected send|recv)", modeStr)
@@ -74,7 +84,7 @@ func main() {
 		log.Fatalf("token is required via --token or --token-file")
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	tlsConf := &tls.Config{
 		// ServerName:         serverName, // Отключаем SNI для тестирования
 		InsecureSkipVerify: insecureTLS,
@@ -199,3 +209,38 @@ func sanitize(s string) string {
 	s = strings.ReplaceAll(s, "\r", "\\r")
 	return s
 }
+
+// relayFromConfig выводит QUIC адрес relay из конфигурации клиента
+// (relay.host + relay.ports.quic, api.base_url или discovery); явно заданные флаги имеют приоритет
+func relayFromConfig(path, host string, port int, serverName string) (string, int, string) {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	quicAddr := discovery.FromConfig(cfg).QUIC
+	if quicAddr == "" {
+		log.Fatalf("relay QUIC address is not configured in %s", path)
+	}
+	cfgHost, portStr, err := net.SplitHostPort(quicAddr)
+	if err != nil {
+		log.Fatalf("invalid relay QUIC address %q: %v", quicAddr, err)
+	}
+	cfgPort, err := strconv.Atoi(portStr)
+	if err != nil {
+		log.Fatalf("invalid relay QUIC port %q: %v", portStr, err)
+	}
+
+	if !explicit["host"] {
+		host = cfgHost
+	}
+	if !explicit["port"] {
+		port = cfgPort
+	}
+	if !explicit["servername"] {
+		serverName = host
+	}
+	return host, port, serverName
+}
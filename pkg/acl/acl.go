@@ -0,0 +1,352 @@
+package acl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Протоколы потоков, которые различает политика
+const (
+	ProtoTCP  = "tcp"
+	ProtoUDP  = "udp"
+	ProtoICMP = "icmp"
+)
+
+// Действия правил политики
+const (
+	ActionAccept = "accept"
+	ActionDeny   = "deny"
+)
+
+// ErrDenied поток запрещен политикой доступа
+var ErrDenied = errors.New("denied by ACL policy")
+
+// Policy документ политики доступа между пирами mesh. Правила проверяются по
+// порядку, решение принимает первое подходящее; поток без подходящего правила запрещен.
+//
+//	{
+//	  "tags": {"tag:db": ["peer-db1"], "tag:app": ["peer-app1", "peer-app2"]},
+//	  "acls": [
+//	    {"action": "accept", "src": ["tag:app"], "dst": ["tag:db"], "ports": ["tcp/5432"]},
+//	    {"action": "deny", "src": ["*"], "dst": ["tag:db"]},
+//	    {"action": "accept", "src": ["*"], "dst": ["*"]}
+//	  ]
+//	}
+type Policy struct {
+	Version string              `json:"version,omitempty"`
+	Tags    map[string][]string `json:"tags,omitempty"` // тег -> peer ID
+	ACLs    []Rule              `json:"acls"`
+}
+
+// Rule правило политики. Селекторы пиров: "*", "tag:<имя>", "peer:<id>" или просто ID пира.
+// Порты: "*", "tcp/5432", "udp/53", "tcp/8000-8100", "tcp/*", "5432" (любой протокол), "icmp".
+type Rule struct {
+	Action string   `json:"action,omitempty"` // accept (по умолчанию) или deny
+	Src    []string `json:"src"`
+	Dst    []string `json:"dst"`
+	Ports  []string `json:"ports,omitempty"` // пусто — любые протоколы и порты
+}
+
+// Identity пир в потоке: ID и теги, известные помимо тегов политики
+type Identity struct {
+	ID   string
+	Tags []string
+}
+
+// Flow поток от пира к пиру. Пустой Proto описывает соединение пиров целиком:
+// оно разрешено, если политика открывает источнику хотя бы один порт получателя.
+type Flow struct {
+	Src   Identity
+	Dst   Identity
+	Proto string
+	Port  int
+}
+
+// Decision результат проверки потока
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    int    `json:"rule"` // номер правила с 1; 0 — правило не найдено
+	Reason  string `json:"reason"`
+}
+
+// String форматирует решение для логов и команды policy test
+func (d Decision) String() string {
+	action := ActionDeny
+	if d.Allowed {
+		action = ActionAccept
+	}
+	if d.Rule > 0 {
+		return fmt.Sprintf("%s (rule %d: %s)", action, d.Rule, d.Reason)
+	}
+	return fmt.Sprintf("%s (%s)", action, d.Reason)
+}
+
+// Engine скомпилированная политика; nil Engine разрешает все потоки
+type Engine struct {
+	version string
+	rules   []compiledRule
+	tags    map[string][]string // peer ID -> теги
+}
+
+type selector struct {
+	any  bool
+	tag  string
+	peer string
+}
+
+type portRange struct {
+	proto       string // пусто — любой протокол
+	first, last int
+}
+
+type compiledRule struct {
+	accept bool
+	text   string
+	src    []selector
+	dst    []selector
+	ports  []portRange // пусто — любые
+}
+
+// Parse разбирает JSON документ политики; неизвестные поля считаются ошибкой
+func Parse(data []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var policy Policy
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse ACL policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Compile проверяет политику и готовит ее к проверке потоков
+func Compile(policy *Policy) (*Engine, error) {
+	e := &Engine{version: policy.Version, tags: make(map[string][]string)}
+	for tag, peers := range policy.Tags {
+		if !strings.HasPrefix(tag, "tag:") || len(tag) == len("tag:") {
+			return nil, fmt.Errorf("invalid tag %q: tags must look like tag:<name>", tag)
+		}
+		for _, peer := range peers {
+			e.tags[peer] = append(e.tags[peer], tag)
+		}
+	}
+	for peer := range e.tags {
+		slices.Sort(e.tags[peer])
+	}
+
+	for i, rule := range policy.ACLs {
+		compiled, err := compileRule(rule, policy.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// ParseAndCompile разбирает и компилирует JSON документ политики
+func ParseAndCompile(data []byte) (*Engine, error) {
+	policy, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(policy)
+}
+
+func compileRule(rule Rule, tags map[string][]string) (compiledRule, error) {
+	c := compiledRule{}
+	switch strings.ToLower(rule.Action) {
+	case "", ActionAccept:
+		c.accept = true
+	case ActionDeny:
+	default:
+		return c, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if len(rule.Src) == 0 || len(rule.Dst) == 0 {
+		return c, fmt.Errorf("src and dst must not be empty")
+	}
+
+	var err error
+	if c.src, err = compileSelectors(rule.Src, tags); err != nil {
+		return c, fmt.Errorf("src: %w", err)
+	}
+	if c.dst, err = compileSelectors(rule.Dst, tags); err != nil {
+		return c, fmt.Errorf("dst: %w", err)
+	}
+	for _, spec := range rule.Ports {
+		ports, err := parsePorts(spec)
+		if err != nil {
+			return c, err
+		}
+		if ports == nil {
+			// "*" открывает все: остальные порты правила не важны
+			c.ports = nil
+			break
+		}
+		c.ports = append(c.ports, *ports)
+	}
+
+	ports := "*"
+	if len(rule.Ports) > 0 {
+		ports = strings.Join(rule.Ports, ",")
+	}
+	c.text = fmt.Sprintf("%s -> %s %s", strings.Join(rule.Src, ","), strings.Join(rule.Dst, ","), ports)
+	return c, nil
+}
+
+func compileSelectors(specs []string, tags map[string][]string) ([]selector, error) {
+	selectors := make([]selector, 0, len(specs))
+	for _, spec := range specs {
+		switch {
+		case spec == "*":
+			selectors = append(selectors, selector{any: true})
+		case strings.HasPrefix(spec, "tag:"):
+			if _, ok := tags[spec]; !ok {
+				return nil, fmt.Errorf("undefined tag %q", spec)
+			}
+			selectors = append(selectors, selector{tag: spec})
+		case strings.HasPrefix(spec, "peer:"):
+			selectors = append(selectors, selector{peer: strings.TrimPrefix(spec, "peer:")})
+		case spec == "" || strings.Contains(spec, ":"):
+			return nil, fmt.Errorf("invalid selector %q", spec)
+		default:
+			selectors = append(selectors, selector{peer: spec})
+		}
+	}
+	return selectors, nil
+}
+
+// parsePorts разбирает спецификацию портов; nil — любые протоколы и порты
+func parsePorts(spec string) (*portRange, error) {
+	if spec == "*" {
+		return nil, nil
+	}
+	proto, ports, found := strings.Cut(strings.ToLower(spec), "/")
+	if !found {
+		// "icmp" или только порт
+		if proto == ProtoTCP || proto == ProtoUDP || proto == ProtoICMP {
+			return &portRange{proto: proto, first: 0, last: 65535}, nil
+		}
+		proto, ports = "", spec
+	}
+	switch proto {
+	case "", ProtoTCP, ProtoUDP:
+	case ProtoICMP:
+		return nil, fmt.Errorf("invalid ports %q: icmp has no ports", spec)
+	default:
+		return nil, fmt.Errorf("invalid ports %q: unknown protocol %q", spec, proto)
+	}
+
+	if ports == "*" {
+		return &portRange{proto: proto, first: 0, last: 65535}, nil
+	}
+	firstText, lastText, isRange := strings.Cut(ports, "-")
+	first, err := strconv.Atoi(firstText)
+	if err != nil || first < 1 || first > 65535 {
+		return nil, fmt.Errorf("invalid ports %q", spec)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(lastText); err != nil || last < first || last > 65535 {
+			return nil, fmt.Errorf("invalid ports %q", spec)
+		}
+	}
+	return &portRange{proto: proto, first: first, last: last}, nil
+}
+
+// Version возвращает версию документа политики
+func (e *Engine) Version() string {
+	if e == nil {
+		return ""
+	}
+	return e.version
+}
+
+// Rules возвращает число правил политики
+func (e *Engine) Rules() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.rules)
+}
+
+// TagsOf возвращает теги, назначенные пиру политикой
+func (e *Engine) TagsOf(peerID string) []string {
+	if e == nil {
+		return nil
+	}
+	return slices.Clone(e.tags[peerID])
+}
+
+// Evaluate проверяет поток по правилам политики
+func (e *Engine) Evaluate(flow Flow) Decision {
+	if e == nil {
+		return Decision{Allowed: true, Reason: "no policy"}
+	}
+	src := e.identityTags(flow.Src)
+	dst := e.identityTags(flow.Dst)
+
+	for i, rule := range e.rules {
+		if !matchSelectors(rule.src, flow.Src.ID, src) || !matchSelectors(rule.dst, flow.Dst.ID, dst) {
+			continue
+		}
+		if flow.Proto == "" {
+			// Соединение пиров: accept открывает хотя бы часть портов,
+			// deny запрещает соединение только целиком
+			if rule.accept || len(rule.ports) == 0 {
+				return Decision{Allowed: rule.accept, Rule: i + 1, Reason: rule.text}
+			}
+			continue
+		}
+		if rule.matchPort(flow.Proto, flow.Port) {
+			return Decision{Allowed: rule.accept, Rule: i + 1, Reason: rule.text}
+		}
+	}
+	return Decision{Reason: "no matching rule"}
+}
+
+// identityTags объединяет теги пира из потока и из политики
+func (e *Engine) identityTags(id Identity) []string {
+	tags := e.tags[id.ID]
+	if len(id.Tags) == 0 {
+		return tags
+	}
+	return append(slices.Clone(tags), id.Tags...)
+}
+
+func matchSelectors(selectors []selector, peerID string, tags []string) bool {
+	for _, s := range selectors {
+		switch {
+		case s.any:
+			return true
+		case s.tag != "" && slices.Contains(tags, s.tag):
+			return true
+		case s.peer != "" && s.peer == peerID:
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledRule) matchPort(proto string, port int) bool {
+	if len(r.ports) == 0 {
+		return true
+	}
+	proto = strings.ToLower(proto)
+	for _, p := range r.ports {
+		if proto == ProtoICMP {
+			if p.proto == ProtoICMP {
+				return true
+			}
+			continue
+		}
+		if (p.proto == "" || p.proto == proto) && port >= p.first && port <= p.last {
+			return true
+		}
+	}
+	return false
+}
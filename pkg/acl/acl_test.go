@@ -0,0 +1,177 @@
+package acl
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPolicy = `{
+	"version": "1",
+	"tags": {"tag:db": ["db1"], "tag:app": ["app1", "app2"]},
+	"acls": [
+		{"action": "accept", "src": ["tag:app"], "dst": ["tag:db"], "ports": ["tcp/5432"]},
+		{"action": "deny", "src": ["*"], "dst": ["tag:db"]},
+		{"src": ["*"], "dst": ["*"], "ports": ["tcp/22", "udp/1000-2000", "icmp"]}
+	]
+}`
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+func peer(id string) Identity { return Identity{ID: id} }
+
+func TestEngine_Evaluate(t *testing.T) {
+	e, err := ParseAndCompile([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		flow    Flow
+		allowed bool
+		rule    int
+	}{
+		{"app to db postgres", Flow{Src: peer("app1"), Dst: peer("db1"), Proto: ProtoTCP, Port: 5432}, true, 1},
+		{"app to db ssh", Flow{Src: peer("app2"), Dst: peer("db1"), Proto: ProtoTCP, Port: 22}, false, 2},
+		{"other to db postgres", Flow{Src: peer("web"), Dst: peer("db1"), Proto: ProtoTCP, Port: 5432}, false, 2},
+		{"ssh between others", Flow{Src: peer("web"), Dst: peer("app1"), Proto: ProtoTCP, Port: 22}, true, 3},
+		{"udp range", Flow{Src: peer("web"), Dst: peer("app1"), Proto: ProtoUDP, Port: 1500}, true, 3},
+		{"icmp", Flow{Src: peer("web"), Dst: peer("app1"), Proto: ProtoICMP}, true, 3},
+		{"default deny", Flow{Src: peer("web"), Dst: peer("app1"), Proto: ProtoTCP, Port: 80}, false, 0},
+		{"tag from identity", Flow{Src: Identity{ID: "new", Tags: []string{"tag:app"}}, Dst: peer("db1"), Proto: ProtoTCP, Port: 5432}, true, 1},
+		{"peer connection", Flow{Src: peer("app1"), Dst: peer("db1")}, true, 1},
+		{"peer connection denied", Flow{Src: peer("web"), Dst: peer("db1")}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Evaluate(tt.flow)
+			if d.Allowed != tt.allowed || d.Rule != tt.rule {
+				t.Errorf("Evaluate = %s, want allowed=%v rule=%d", d, tt.allowed, tt.rule)
+			}
+		})
+	}
+
+	var none *Engine
+	if d := none.Evaluate(Flow{Src: peer("a"), Dst: peer("b"), Proto: ProtoTCP, Port: 1}); !d.Allowed {
+		t.Error("Expected nil engine to allow everything")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, doc := range []string{
+		`{"acls": [{"src": ["tag:missing"], "dst": ["*"]}]}`,
+		`{"acls": [{"src": ["*"], "dst": ["*"], "ports": ["sctp/1"]}]}`,
+		`{"acls": [{"src": ["*"], "dst": ["*"], "ports": ["tcp/70000"]}]}`,
+		`{"acls": [{"action": "allow", "src": ["*"], "dst": ["*"]}]}`,
+		`{"acls": [{"src": [], "dst": ["*"]}]}`,
+		`{"rules": []}`,
+	} {
+		if _, err := ParseAndCompile([]byte(doc)); err == nil {
+			t.Errorf("Expected error for %s", doc)
+		}
+	}
+}
+
+func TestEngine_EvaluatePacket(t *testing.T) {
+	e, err := ParseAndCompile([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = ipProtoTCP
+	copy(packet[12:16], []byte{10, 0, 0, 2})
+	copy(packet[16:20], []byte{10, 0, 0, 3})
+	binary.BigEndian.PutUint16(packet[22:24], 5432)
+
+	info, err := ParsePacket(packet)
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+	if info.Proto != ProtoTCP || info.Port != 5432 || info.Src.String() != "10.0.0.2" {
+		t.Errorf("Unexpected packet info: %+v", info)
+	}
+	if d, _ := e.EvaluatePacket(peer("app1"), peer("db1"), packet); !d.Allowed {
+		t.Errorf("Expected postgres packet to be allowed: %s", d)
+	}
+	if d, _ := e.EvaluatePacket(peer("web"), peer("db1"), packet); d.Allowed {
+		t.Errorf("Expected postgres packet from web to be denied: %s", d)
+	}
+
+	// Не первый фрагмент без порта: правила с портами к нему не применяются
+	binary.BigEndian.PutUint16(packet[6:8], 100)
+	if d, _ := e.EvaluatePacket(peer("app1"), peer("db1"), packet); d.Allowed {
+		t.Errorf("Expected fragment to be denied: %s", d)
+	}
+	if _, err := ParsePacket([]byte{0x45, 0}); err == nil {
+		t.Error("Expected truncated packet to fail")
+	}
+}
+
+func TestManager_SourcesAndFileReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(file, []byte(`{"acls": [{"src": ["*"], "dst": ["*"], "ports": ["tcp/22"]}]}`), 0o600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+
+	m := NewManager(&Config{PolicyFile: file, ReloadDelay: 10 * time.Millisecond}, testLogger{})
+	changes := make(chan *Engine, 10)
+	m.OnChange(func(e *Engine) { changes <- e })
+	if err := m.SetPolicy(SourceJWT, []byte(`{"acls": [{"src": ["*"], "dst": ["*"]}]}`)); err != nil {
+		t.Fatalf("Failed to set JWT policy: %v", err)
+	}
+	<-changes
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	defer m.Stop()
+	<-changes
+
+	flow := Flow{Src: peer("a"), Dst: peer("b"), Proto: ProtoTCP, Port: 80}
+	if m.Engine().Evaluate(flow).Allowed {
+		t.Error("Expected file policy to take precedence over JWT policy")
+	}
+
+	// Ошибочный документ не заменяет действующую политику
+	if err := m.SetPolicy(SourceFile, []byte(`{"acls": [`)); err == nil {
+		t.Error("Expected invalid policy to be rejected")
+	}
+
+	if err := os.WriteFile(file, []byte(`{"acls": [{"src": ["*"], "dst": ["*"], "ports": ["tcp/80"]}]}`), 0o600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	select {
+	case e := <-changes:
+		if !e.Evaluate(flow).Allowed {
+			t.Error("Expected reloaded policy to allow tcp/80")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for policy reload")
+	}
+
+	// Удаление файла возвращает политику из JWT
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("Failed to remove policy: %v", err)
+	}
+	select {
+	case e := <-changes:
+		if e.Rules() != 1 || !e.Evaluate(Flow{Src: peer("a"), Dst: peer("b"), Proto: ProtoUDP, Port: 53}).Allowed {
+			t.Error("Expected JWT policy after policy file removal")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for policy file removal")
+	}
+	if metrics := m.GetMetrics(); metrics["active_source"] != "jwt" || metrics["failures"] != 1 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+}
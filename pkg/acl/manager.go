@@ -0,0 +1,331 @@
+package acl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Source источник документа политики
+type Source string
+
+// Источники в порядке приоритета: локальный файл, relay API, JWT
+const (
+	SourceFile Source = "file"
+	SourceAPI  Source = "api"
+	SourceJWT  Source = "jwt"
+)
+
+var sourcePriority = []Source{SourceFile, SourceAPI, SourceJWT}
+
+// Logger interface for ACL logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Fetcher получает документ политики из relay API; пустой документ — политики нет
+type Fetcher func(ctx context.Context) ([]byte, error)
+
+// Config настройки источников политики
+type Config struct {
+	PolicyFile      string        // локальный файл политики (пусто — не используется)
+	RefreshInterval time.Duration // период опроса relay API
+	ReloadDelay     time.Duration // задержка перечитывания файла после изменения
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		RefreshInterval: time.Minute,
+		ReloadDelay:     500 * time.Millisecond,
+	}
+}
+
+// Manager держит политики из всех источников и применяет самую приоритетную.
+// Ошибочный документ не заменяет ранее действовавшую политику своего источника.
+type Manager struct {
+	config   *Config
+	logger   Logger
+	fetch    Fetcher
+	engines  map[Source]*Engine
+	raw      map[Source][]byte
+	active   Source
+	onChange []func(*Engine)
+	watcher  *fsnotify.Watcher
+	reload   *time.Timer
+	reloads  int
+	failures int
+	mu       sync.RWMutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewManager creates a new ACL policy manager
+func NewManager(config *Config, logger Logger) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	defaults := DefaultConfig()
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaults.RefreshInterval
+	}
+	if config.ReloadDelay <= 0 {
+		config.ReloadDelay = defaults.ReloadDelay
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		config:  config,
+		logger:  logger,
+		engines: make(map[Source]*Engine),
+		raw:     make(map[Source][]byte),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// SetFetcher задает получение политики из relay API; вызывается до Start
+func (m *Manager) SetFetcher(fetch Fetcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetch = fetch
+}
+
+// OnChange добавляет обработчик смены действующей политики
+func (m *Manager) OnChange(handler func(*Engine)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, handler)
+}
+
+// SetPolicy применяет документ политики источника; пустой документ удаляет политику источника
+func (m *Manager) SetPolicy(source Source, data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		m.update(source, nil, nil)
+		return nil
+	}
+
+	m.mu.RLock()
+	unchanged := bytes.Equal(m.raw[source], data)
+	m.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	engine, err := ParseAndCompile(data)
+	if err != nil {
+		m.mu.Lock()
+		m.failures++
+		m.mu.Unlock()
+		return fmt.Errorf("invalid %s ACL policy: %w", source, err)
+	}
+	m.update(source, engine, data)
+	return nil
+}
+
+// update заменяет политику источника и уведомляет о смене действующей политики
+func (m *Manager) update(source Source, engine *Engine, data []byte) {
+	m.mu.Lock()
+	before := m.activeLocked()
+	if engine == nil {
+		delete(m.engines, source)
+		delete(m.raw, source)
+	} else {
+		m.engines[source] = engine
+		m.raw[source] = data
+		m.reloads++
+	}
+	m.active = ""
+	for _, s := range sourcePriority {
+		if _, ok := m.engines[s]; ok {
+			m.active = s
+			break
+		}
+	}
+	after := m.activeLocked()
+	handlers := append([]func(*Engine){}, m.onChange...)
+	active := m.active
+	m.mu.Unlock()
+
+	if before == after {
+		return
+	}
+	m.logger.Info("ACL policy changed",
+		"source", active,
+		"version", after.Version(),
+		"rules", after.Rules())
+	for _, handler := range handlers {
+		handler(after)
+	}
+}
+
+func (m *Manager) activeLocked() *Engine {
+	if m.active == "" {
+		return nil
+	}
+	return m.engines[m.active]
+}
+
+// Engine возвращает действующую политику (nil — политики нет, все разрешено)
+func (m *Manager) Engine() *Engine {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.activeLocked()
+}
+
+// Start загружает файл политики, следит за его изменениями и опрашивает relay API
+func (m *Manager) Start() error {
+	m.mu.RLock()
+	file := m.config.PolicyFile
+	fetch := m.fetch
+	m.mu.RUnlock()
+
+	if file != "" {
+		if err := m.loadFile(); err != nil {
+			return err
+		}
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create policy file watcher: %w", err)
+		}
+		// Следим за каталогом: редакторы заменяют файл целиком
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close() //nolint:errcheck // cleanup after failed watch
+			return fmt.Errorf("failed to watch policy file: %w", err)
+		}
+		m.mu.Lock()
+		m.watcher = watcher
+		m.mu.Unlock()
+		go m.watchLoop(watcher)
+	}
+	if fetch != nil {
+		go m.refreshLoop(fetch)
+	}
+
+	m.logger.Info("ACL policy manager started", "policy_file", file, "api", fetch != nil)
+	return nil
+}
+
+// Stop останавливает отслеживание файла и опрос API (идемпотентный)
+func (m *Manager) Stop() {
+	m.cancel()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reload != nil {
+		m.reload.Stop()
+	}
+	if m.watcher != nil {
+		_ = m.watcher.Close() //nolint:errcheck // best-effort cleanup
+		m.watcher = nil
+	}
+}
+
+// loadFile читает файл политики; удаленный файл снимает его политику
+func (m *Manager) loadFile() error {
+	data, err := os.ReadFile(m.config.PolicyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		m.logger.Warn("ACL policy file not found", "path", m.config.PolicyFile)
+		return m.SetPolicy(SourceFile, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ACL policy file: %w", err)
+	}
+	return m.SetPolicy(SourceFile, data)
+}
+
+func (m *Manager) watchLoop(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(m.config.PolicyFile) {
+				continue
+			}
+			// Редакторы пишут файл несколькими операциями: перечитываем после паузы
+			m.mu.Lock()
+			if m.reload != nil {
+				m.reload.Stop()
+			}
+			m.reload = time.AfterFunc(m.config.ReloadDelay, func() {
+				if err := m.loadFile(); err != nil {
+					m.logger.Error("Failed to reload ACL policy file", "path", m.config.PolicyFile, "error", err)
+				}
+			})
+			m.mu.Unlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			m.logger.Error("ACL policy file watcher error", "error", err)
+		}
+	}
+}
+
+func (m *Manager) refreshLoop(fetch Fetcher) {
+	m.refresh(fetch)
+
+	ticker := time.NewTicker(m.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.refresh(fetch)
+		}
+	}
+}
+
+// refresh получает политику из API; при ошибке запроса действует прежняя политика
+func (m *Manager) refresh(fetch Fetcher) {
+	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	defer cancel()
+
+	data, err := fetch(ctx)
+	if err != nil {
+		m.logger.Debug("Failed to fetch ACL policy", "error", err)
+		return
+	}
+	if err := m.SetPolicy(SourceAPI, data); err != nil {
+		m.logger.Error("Rejected ACL policy from API", "error", err)
+	}
+}
+
+// GetMetrics возвращает состояние политик
+func (m *Manager) GetMetrics() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := make([]string, 0, len(m.engines))
+	for _, s := range sourcePriority {
+		if _, ok := m.engines[s]; ok {
+			sources = append(sources, string(s))
+		}
+	}
+	active := m.activeLocked()
+	return map[string]interface{}{
+		"active_source": string(m.active),
+		"version":       active.Version(),
+		"rules":         active.Rules(),
+		"sources":       sources,
+		"reloads":       m.reloads,
+		"failures":      m.failures,
+	}
+}
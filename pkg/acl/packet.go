@@ -0,0 +1,108 @@
+package acl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Номера протоколов IP, которые различает политика
+const (
+	ipProtoICMP   = 1
+	ipProtoTCP    = 6
+	ipProtoUDP    = 17
+	ipProtoICMPv6 = 58
+)
+
+// PacketInfo адреса, протокол и порт получателя IP пакета overlay сети
+type PacketInfo struct {
+	Src   net.IP
+	Dst   net.IP
+	Proto string // tcp, udp, icmp; пусто — другой протокол
+	Port  int    // порт получателя для tcp/udp
+}
+
+// ParsePacket разбирает заголовки IPv4/IPv6 пакета и TCP/UDP порт получателя.
+// Фрагменты IPv4 кроме первого и IPv6 с extension headers не содержат порта и
+// возвращаются без него: по таким пакетам решают только правила без портов.
+func ParsePacket(packet []byte) (PacketInfo, error) {
+	if len(packet) < 1 {
+		return PacketInfo{}, fmt.Errorf("empty packet")
+	}
+
+	var (
+		info      PacketInfo
+		proto     byte
+		transport []byte
+	)
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return PacketInfo{}, fmt.Errorf("truncated IPv4 header")
+		}
+		headerLen := int(packet[0]&0x0f) * 4
+		if headerLen < 20 || len(packet) < headerLen {
+			return PacketInfo{}, fmt.Errorf("invalid IPv4 header length %d", headerLen)
+		}
+		info.Src = net.IP(packet[12:16])
+		info.Dst = net.IP(packet[16:20])
+		proto = packet[9]
+		// Порт есть только в первом фрагменте
+		if binary.BigEndian.Uint16(packet[6:8])&0x1fff == 0 {
+			transport = packet[headerLen:]
+		}
+	case 6:
+		if len(packet) < 40 {
+			return PacketInfo{}, fmt.Errorf("truncated IPv6 header")
+		}
+		info.Src = net.IP(packet[8:24])
+		info.Dst = net.IP(packet[24:40])
+		proto = packet[6]
+		transport = packet[40:]
+	default:
+		return PacketInfo{}, fmt.Errorf("unknown IP version %d", packet[0]>>4)
+	}
+
+	switch proto {
+	case ipProtoTCP:
+		info.Proto = ProtoTCP
+	case ipProtoUDP:
+		info.Proto = ProtoUDP
+	case ipProtoICMP, ipProtoICMPv6:
+		info.Proto = ProtoICMP
+		return info, nil
+	default:
+		return info, nil
+	}
+	if len(transport) >= 4 {
+		info.Port = int(binary.BigEndian.Uint16(transport[2:4]))
+	}
+	return info, nil
+}
+
+// EvaluatePacket проверяет IP пакет overlay сети от пира src к пиру dst
+func (e *Engine) EvaluatePacket(src, dst Identity, packet []byte) (Decision, error) {
+	info, err := ParsePacket(packet)
+	if err != nil {
+		return Decision{Reason: err.Error()}, err
+	}
+	if e == nil {
+		return e.Evaluate(Flow{}), nil
+	}
+	if info.Proto == "" || (info.Proto != ProtoICMP && info.Port == 0) {
+		// Без протокола и порта подходят только правила на все порты
+		return e.evaluateAnyPort(src, dst), nil
+	}
+	return e.Evaluate(Flow{Src: src, Dst: dst, Proto: info.Proto, Port: info.Port}), nil
+}
+
+// evaluateAnyPort решение по правилам, не ограничивающим порты
+func (e *Engine) evaluateAnyPort(src, dst Identity) Decision {
+	srcTags, dstTags := e.identityTags(src), e.identityTags(dst)
+	for i, rule := range e.rules {
+		if len(rule.ports) == 0 && matchSelectors(rule.src, src.ID, srcTags) && matchSelectors(rule.dst, dst.ID, dstTags) {
+			return Decision{Allowed: rule.accept, Rule: i + 1, Reason: rule.text}
+		}
+	}
+	return Decision{Reason: "no matching rule"}
+}
@@ -32,8 +32,9 @@ type Logger interface {
 
 // PeerRegistrationRequest represents a peer registration request (simplified)
 type PeerRegistrationRequest struct {
-	PublicKey  string   `json:"public_key"`
-	AllowedIPs []string `json:"allowed_ips"`
+	PublicKey    string   `json:"public_key"`
+	AllowedIPs   []string `json:"allowed_ips"`
+	KeySignature string   `json:"key_signature,omitempty"` // previous identity key signs a rotated key
 }
 
 // PeerRegistrationResponse represents a peer registration response (simplified)
@@ -49,8 +50,10 @@ type PeerRegistrationResponse struct {
 
 // ICECredentialsRequest represents ICE credentials exchange request
 type ICECredentialsRequest struct {
-	Ufrag string `json:"ufrag"`
-	Pwd   string `json:"pwd"`
+	Ufrag        string `json:"ufrag"`
+	Pwd          string `json:"pwd"`
+	SessionID    string `json:"session_id,omitempty"`
+	TargetPeerID string `json:"target_peer_id,omitempty"`
 }
 
 // ICECredentialsResponse represents ICE credentials exchange response
@@ -80,6 +83,7 @@ type Peer struct {
 	PeerID         string         `json:"peer_id"`
 	RelaySessionID string         `json:"relay_session_id"`
 	PublicKey      string         `json:"public_key,omitempty"`
+	KeySignature   string         `json:"key_signature,omitempty"` // previous identity key signs a rotated key
 	AllowedIPs     []string       `json:"allowed_ips,omitempty"`
 	Endpoint       string         `json:"endpoint,omitempty"`
 	Keepalive      int            `json:"keepalive,omitempty"`
@@ -368,9 +372,10 @@ type ICECandidate struct {
 
 // ICESignalingRequest represents an ICE signaling request
 type ICESignalingRequest struct {
-	SessionID  string          `json:"session_id"`
-	PeerID     string          `json:"peer_id"`
-	Candidates []*ICECandidate `json:"candidates"`
+	SessionID    string          `json:"session_id"`
+	PeerID       string          `json:"peer_id"`
+	TargetPeerID string          `json:"target_peer_id,omitempty"`
+	Candidates   []*ICECandidate `json:"candidates"`
 }
 
 // ICESignalingResponse represents an ICE signaling response
@@ -397,6 +402,7 @@ type P2PConnectionRequest struct {
 	SessionID    string `json:"session_id"`
 	TargetPeerID string `json:"target_peer_id"`
 	Protocol     string `json:"protocol"`
+	Upgrade      bool   `json:"upgrade,omitempty"` // attempt to move an existing relayed connection to a direct path
 }
 
 // P2PConnectionResponse represents a P2P connection response
@@ -405,6 +411,47 @@ type P2PConnectionResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// P2PIncomingRequest represents a connection request initiated by another peer
+type P2PIncomingRequest struct {
+	SessionID  string `json:"session_id"`
+	FromPeerID string `json:"from_peer_id"`
+	Protocol   string `json:"protocol"`
+	Upgrade    bool   `json:"upgrade,omitempty"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// P2PIncomingRequestsResponse represents pending incoming connection requests
+type P2PIncomingRequestsResponse struct {
+	Success  bool                  `json:"success"`
+	Requests []*P2PIncomingRequest `json:"requests"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// P2PConnectionAnswer represents an answer to an incoming connection request
+type P2PConnectionAnswer struct {
+	SessionID    string `json:"session_id"`
+	TargetPeerID string `json:"target_peer_id"`
+	Accepted     bool   `json:"accepted"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// TURNCredentialsResponse represents short-lived TURN credentials (TURN REST API)
+type TURNCredentialsResponse struct {
+	Success  bool     `json:"success"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	TTL      int      `json:"ttl"`            // seconds
+	URIs     []string `json:"uris,omitempty"` // turn:/turns: URIs the credentials are valid for
+	Error    string   `json:"error,omitempty"`
+}
+
+// ACLPolicyResponse represents the tenant mesh ACL policy document
+type ACLPolicyResponse struct {
+	Success bool            `json:"success"`
+	Policy  json.RawMessage `json:"policy,omitempty"` // empty when the tenant has no policy
+	Error   string          `json:"error,omitempty"`
+}
+
 // SendHeartbeat sends a heartbeat to maintain peer connection
 func (c *Client) SendHeartbeat(ctx context.Context, tenantID, peerID, token string, req *HeartbeatRequest) (*HeartbeatResponse, error) {
 	url := fmt.Sprintf("%s/api/v1/tenants/%s/peers/%s/heartbeat", c.baseURL, tenantID, peerID)
@@ -23,6 +23,9 @@ type Manager struct {
 	tenantID          string
 	peerID            string
 	relaySessionID    string
+	publicKey         string // public key registered for this peer (DERP relay address)
+	keySignature      string // signature of publicKey by the previous identity key (empty if never rotated)
+	allowedIPs        []string
 	heartbeatInterval time.Duration
 	logger            Logger
 	ctx               context.Context
@@ -211,6 +214,37 @@ func (m *Manager) GetRelaySessionID() string {
 	return m.relaySessionID
 }
 
+// GetPublicKey returns the public key the peer was registered with
+func (m *Manager) GetPublicKey() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.publicKey
+}
+
+// SetPublicKey sets the identity public key advertised on registration instead of
+// the key from the token; must be called before Start
+func (m *Manager) SetPublicKey(publicKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publicKey = publicKey
+}
+
+// SetKeySignature sets the rotation signature advertised together with the identity
+// public key, so peers that pinned the previous key accept the current one;
+// must be called before Start
+func (m *Manager) SetKeySignature(signature string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keySignature = signature
+}
+
+// GetAllowedIPs returns the allowed IPs the peer was registered with
+func (m *Manager) GetAllowedIPs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string{}, m.allowedIPs...)
+}
+
 // GetToken returns the authentication token
 func (m *Manager) GetToken() string {
 	m.mu.RLock()
@@ -252,24 +286,36 @@ func (m *Manager) registerPeer() error {
 		return fmt.Errorf("failed to extract QUIC config: %w", err)
 	}
 
-	// Use public key from config or generate one
-	publicKey := quicConfig.PublicKey
+	// Use the identity key, the public key from config or generate one
+	m.mu.RLock()
+	publicKey, keySignature := m.publicKey, m.keySignature
+	m.mu.RUnlock()
+	if publicKey == "" {
+		publicKey, keySignature = quicConfig.PublicKey, ""
+	}
 	if publicKey == "" {
 		// Generate a unique public key for this session
 		// This ensures each client instance gets a unique peer ID
 		publicKey = fmt.Sprintf("generated-quic-key-%d", time.Now().UnixNano())
 	}
 
-	// Use allowed IPs from token or default
-	allowedIPs := quicConfig.AllowedIPs
+	// Keep the allowed IPs of a previous registration (including advertised routes),
+	// otherwise use allowed IPs from token or default
+	m.mu.RLock()
+	allowedIPs := m.allowedIPs
+	m.mu.RUnlock()
+	if len(allowedIPs) == 0 {
+		allowedIPs = quicConfig.AllowedIPs
+	}
 	if len(allowedIPs) == 0 {
 		allowedIPs = []string{"10.0.0.0/24"}
 	}
 
 	// Create simplified registration request
 	req := &PeerRegistrationRequest{
-		PublicKey:  publicKey,
-		AllowedIPs: allowedIPs,
+		PublicKey:    publicKey,
+		AllowedIPs:   allowedIPs,
+		KeySignature: keySignature,
 	}
 
 	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
@@ -288,6 +334,8 @@ func (m *Manager) registerPeer() error {
 	m.mu.Lock()
 	m.peerID = resp.PeerID
 	m.relaySessionID = resp.RelaySessionID
+	m.publicKey = publicKey
+	m.allowedIPs = allowedIPs
 	m.mu.Unlock()
 
 	m.logger.Info("Peer registered successfully",
@@ -298,8 +346,25 @@ func (m *Manager) registerPeer() error {
 	return nil
 }
 
-// RegisterPeerWith re-registers the peer using an explicit public key and allowed IPs
+// RegisterPeerWith re-registers the peer using an explicit public key and allowed IPs.
+// The rotation signature is kept only while the public key stays the same.
 func (m *Manager) RegisterPeerWith(publicKey string, allowedIPs []string) error {
+	m.mu.RLock()
+	keySignature := ""
+	if publicKey == m.publicKey {
+		keySignature = m.keySignature
+	}
+	m.mu.RUnlock()
+	return m.registerPeerWith(publicKey, keySignature, allowedIPs)
+}
+
+// RotatePublicKey re-registers the peer with a rotated identity public key and the
+// signature of the new key by the previous one, keeping the allowed IPs
+func (m *Manager) RotatePublicKey(publicKey, keySignature string) error {
+	return m.registerPeerWith(publicKey, keySignature, m.GetAllowedIPs())
+}
+
+func (m *Manager) registerPeerWith(publicKey, keySignature string, allowedIPs []string) error {
 	if strings.TrimSpace(publicKey) == "" {
 		return fmt.Errorf("public key is required")
 	}
@@ -308,8 +373,9 @@ func (m *Manager) RegisterPeerWith(publicKey string, allowedIPs []string) error
 	}
 
 	req := &PeerRegistrationRequest{
-		PublicKey:  publicKey,
-		AllowedIPs: allowedIPs,
+		PublicKey:    publicKey,
+		AllowedIPs:   allowedIPs,
+		KeySignature: keySignature,
 	}
 
 	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
@@ -326,6 +392,9 @@ func (m *Manager) RegisterPeerWith(publicKey string, allowedIPs []string) error
 	m.mu.Lock()
 	m.peerID = resp.PeerID
 	m.relaySessionID = resp.RelaySessionID
+	m.publicKey = publicKey
+	m.keySignature = keySignature
+	m.allowedIPs = allowedIPs
 	m.mu.Unlock()
 
 	m.logger.Info("Peer re-registered with explicit public key",
@@ -546,6 +615,135 @@ func (m *Manager) RequestP2PConnection(req *P2PConnectionRequest) error {
 	return nil
 }
 
+// GetIncomingP2PRequests gets pending connection requests initiated by other peers
+func (m *Manager) GetIncomingP2PRequests() (*P2PIncomingRequestsResponse, error) {
+	base := strings.TrimSuffix(m.client.baseURL, "/")
+	url := base + "/api/v1/p2p/requests"
+
+	httpReq, err := http.NewRequestWithContext(m.ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create P2P requests request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+m.token)
+	httpReq.Header.Set("X-Request-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
+
+	resp, err := m.client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get P2P requests: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("P2P requests request failed with status: %d", resp.StatusCode)
+	}
+
+	var response P2PIncomingRequestsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode P2P requests response: %w", err)
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("failed to get P2P requests: %s", response.Error)
+	}
+
+	return &response, nil
+}
+
+// AnswerP2PConnection accepts or declines an incoming connection request
+func (m *Manager) AnswerP2PConnection(req *P2PConnectionAnswer) error {
+	var response P2PConnectionResponse
+	if err := m.postJSONRequest("/api/v1/p2p/answer", req, &response, "P2P answer"); err != nil {
+		return err
+	}
+
+	if !response.Success {
+		return fmt.Errorf("P2P answer failed: %s", response.Error)
+	}
+
+	m.logger.Debug("P2P connection answered", "session_id", req.SessionID, "accepted", req.Accepted)
+	return nil
+}
+
+// GetTURNCredentials fetches short-lived TURN credentials issued by the relay
+func (m *Manager) GetTURNCredentials() (*TURNCredentialsResponse, error) {
+	base := strings.TrimSuffix(m.client.baseURL, "/")
+	url := base + "/api/v1/turn/credentials"
+
+	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TURN credentials request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+m.token)
+	httpReq.Header.Set("X-Request-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
+
+	resp, err := m.client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TURN credentials: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TURN credentials request failed with status: %d", resp.StatusCode)
+	}
+
+	var response TURNCredentialsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode TURN credentials response: %w", err)
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("failed to get TURN credentials: %s", response.Error)
+	}
+	if response.Username == "" || response.Password == "" {
+		return nil, fmt.Errorf("TURN credentials response has empty username or password")
+	}
+
+	return &response, nil
+}
+
+// GetACLPolicy fetches the tenant mesh ACL policy document; nil if the tenant has no policy
+func (m *Manager) GetACLPolicy(ctx context.Context) ([]byte, error) {
+	base := strings.TrimSuffix(m.client.baseURL, "/")
+	url := base + "/api/v1/p2p/acl"
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACL policy request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+m.token)
+	httpReq.Header.Set("X-Request-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
+
+	resp, err := m.client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ACL policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ACL policy request failed with status: %d", resp.StatusCode)
+	}
+
+	var response ACLPolicyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode ACL policy response: %w", err)
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("failed to get ACL policy: %s", response.Error)
+	}
+
+	return response.Policy, nil
+}
+
 // SendHeartbeat sends a heartbeat to maintain peer connection
 func (m *Manager) SendHeartbeat(ctx context.Context, tenantID, peerID, token string, req *HeartbeatRequest) (*HeartbeatResponse, error) {
 	// Use heartbeat URL from config
@@ -4,15 +4,22 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
 )
 
+// defaultWireGuardInterface имя интерфейса WireGuard ядра, который поднимает клиент
+const defaultWireGuardInterface = "wg0"
+
 // WireGuardClient расширяет базовый Client для WireGuard операций
 type WireGuardClient struct {
 	*Client
+	device *wireguard.KernelDevice // интерфейс, поднятый ApplyWireGuardConfig
+	mu     sync.Mutex
 }
 
 // NewWireGuardClient создает новый WireGuard клиент
@@ -255,104 +262,48 @@ func (wgc *WireGuardClient) SaveWireGuardConfigWithBackup(config, filename strin
 	return nil
 }
 
-// ApplyWireGuardConfig применяет WireGuard конфигурацию через wg-quick
-// Возвращает путь к созданному конфигурационному файлу
-func (wgc *WireGuardClient) ApplyWireGuardConfig(config string) (string, error) {
+// ApplyWireGuardConfig применяет конфигурацию wg-quick к интерфейсу WireGuard ядра через
+// netlink и wgctrl и возвращает его. Повторный вызов меняет только изменившихся пиров,
+// не пересоздавая интерфейс: ключ и адреса задаются при первом вызове.
+func (wgc *WireGuardClient) ApplyWireGuardConfig(config string) (*wireguard.KernelDevice, error) {
 	if strings.TrimSpace(config) == "" {
-		return "", fmt.Errorf("empty config")
-	}
-
-	// Проверяем права root для wg-quick
-	if os.Geteuid() != 0 {
-		return "", fmt.Errorf("wg-quick requires root privileges. Please run with sudo")
-	}
-
-	tmpDir := filepath.Join(os.TempDir(), "cloudbridge-wg")
-	if err := os.MkdirAll(tmpDir, 0o700); err != nil {
-		return "", fmt.Errorf("failed to prepare temp dir: %w", err)
-	}
-	cfgPath := filepath.Join(tmpDir, fmt.Sprintf("wg-%d.conf", time.Now().UnixNano()))
-	if err := os.WriteFile(cfgPath, []byte(config), 0o600); err != nil {
-		return "", fmt.Errorf("failed to write temp config: %w", err)
+		return nil, fmt.Errorf("empty config")
 	}
-
-	// Проверяем, не поднят ли уже интерфейс
-	interfaceName := wgc.extractInterfaceName(config)
-	if interfaceName != "" {
-		if wgc.isInterfaceUp(interfaceName) {
-			return cfgPath, fmt.Errorf("interface %s is already up", interfaceName)
-		}
-	}
-
-	cmd := exec.Command("wg-quick", "up", cfgPath)
-	out, err := cmd.CombinedOutput()
+	quick, err := wireguard.ParseQuickConfig(config)
 	if err != nil {
-		return cfgPath, fmt.Errorf("wg-quick up failed: %w: %s", err, string(out))
+		return nil, fmt.Errorf("failed to parse WireGuard config: %w", err)
 	}
-	return cfgPath, nil
+	return wgc.ApplyQuickConfig(quick)
 }
 
-// DownWireGuardConfig останавливает интерфейс по конфигу (опционально для тестов)
-func (wgc *WireGuardClient) DownWireGuardConfig(configPath string) error {
-	if strings.TrimSpace(configPath) == "" {
-		return fmt.Errorf("config path required")
-	}
-
-	// Если путь не найден, попробуем найти интерфейс по имени
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Попробуем найти интерфейс по имени из файла
-		interfaceName := wgc.extractInterfaceNameFromFile(configPath)
-		if interfaceName != "" && wgc.isInterfaceUp(interfaceName) {
-			cmd := exec.Command("wg-quick", "down", interfaceName)
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("wg-quick down %s failed: %w: %s", interfaceName, err, string(out))
-			}
-			return nil
+// ApplyQuickConfig применяет разобранную конфигурацию wg-quick, как ApplyWireGuardConfig
+func (wgc *WireGuardClient) ApplyQuickConfig(quick *wireguard.QuickConfig) (*wireguard.KernelDevice, error) {
+	wgc.mu.Lock()
+	defer wgc.mu.Unlock()
+	if wgc.device == nil {
+		device := wireguard.NewKernelDevice(&wireguard.Config{InterfaceName: defaultWireGuardInterface}, wgc.logger)
+		if err := device.Start(&quick.Interface); err != nil {
+			return nil, fmt.Errorf("failed to bring WireGuard interface up: %w", err)
 		}
-		return fmt.Errorf("config file not found: %s", configPath)
+		wgc.device = device
 	}
-
-	cmd := exec.Command("wg-quick", "down", configPath)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("wg-quick down failed: %w: %s", err, string(out))
+	if err := wgc.device.SetPeers(quick.Peers); err != nil {
+		return nil, fmt.Errorf("failed to configure WireGuard peers: %w", err)
 	}
-	return nil
+	return wgc.device, nil
 }
 
-// extractInterfaceName извлекает имя интерфейса из конфигурации
-func (wgc *WireGuardClient) extractInterfaceName(config string) string {
-	lines := strings.Split(config, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "[Interface]") {
-			// Ищем следующую строку с Address
-			for i, l := range lines {
-				if i > 0 && strings.HasPrefix(strings.TrimSpace(l), "Address") {
-					// Имя интерфейса обычно в комментарии или можно извлечь из Address
-					// Для простоты возвращаем "wg0" как дефолт
-					return "wg0"
-				}
-			}
-		}
+// DownWireGuardConfig удаляет интерфейс, поднятый ApplyWireGuardConfig
+func (wgc *WireGuardClient) DownWireGuardConfig() error {
+	wgc.mu.Lock()
+	defer wgc.mu.Unlock()
+	if wgc.device == nil {
+		return nil
 	}
-	return ""
-}
-
-// extractInterfaceNameFromFile извлекает имя интерфейса из файла конфигурации
-func (wgc *WireGuardClient) extractInterfaceNameFromFile(configPath string) string {
-	// Имя интерфейса обычно в базовом имени файла без расширения
-	baseName := filepath.Base(configPath)
-	if strings.HasSuffix(baseName, ".conf") {
-		return strings.TrimSuffix(baseName, ".conf")
+	err := wgc.device.Close()
+	wgc.device = nil
+	if err != nil {
+		return fmt.Errorf("failed to remove WireGuard interface: %w", err)
 	}
-	return baseName
-}
-
-// isInterfaceUp проверяет, поднят ли интерфейс
-func (wgc *WireGuardClient) isInterfaceUp(interfaceName string) bool {
-	cmd := exec.Command("wg", "show", interfaceName)
-	err := cmd.Run()
-	return err == nil
+	return nil
 }
@@ -647,6 +647,25 @@ func (am *AuthManager) ExtractNetworkConfig(token *jwt.Token) (*NetworkConfig, e
 	return config, nil
 }
 
+// ExtractACLPolicy extracts the mesh ACL policy document from token (JSON, nil if absent)
+func (am *AuthManager) ExtractACLPolicy(token *jwt.Token) ([]byte, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	policy, ok := claims["acl_policy"].(map[string]interface{})
+	if !ok {
+		return nil, nil // No ACL policy configured
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ACL policy: %w", err)
+	}
+	return data, nil
+}
+
 // ExtractPermissions extracts permissions from token
 func (am *AuthManager) ExtractPermissions(token *jwt.Token) ([]string, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -1,12 +1,16 @@
 package config
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
+	"strconv"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/discovery"
 	"github.com/2gc-dev/cloudbridge-client/pkg/types"
 	"github.com/spf13/viper"
 )
@@ -47,6 +51,16 @@ func LoadConfig(configPath string) (*types.Config, error) {
 	// Substitute environment variables in string fields
 	substituteEnvVars(&config)
 
+	// Resolve relay endpoints via DNS SRV / well-known URL
+	if config.Relay.Discovery.Enabled {
+		if err := discoverRelayEndpoints(&config); err != nil {
+			return nil, fmt.Errorf("relay discovery failed: %w", err)
+		}
+	}
+
+	// Fill endpoints that are derived from the relay host
+	applyDerivedDefaults(&config)
+
 	// Validate configuration
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -57,7 +71,8 @@ func LoadConfig(configPath string) (*types.Config, error) {
 
 // setDefaults sets default configuration values
 func setDefaults() {
-	// Relay configuration - синхронизировано с реальными портами edge.2gc.ru
+	// Relay configuration - синхронизировано с реальными портами edge.2gc.ru.
+	// Адреса API, ICE и WebSocket по умолчанию выводятся из relay.host (см. applyDerivedDefaults)
 	viper.SetDefault("relay.host", "edge.2gc.ru")       // Реальный домен
 	viper.SetDefault("relay.port", 5553)                // P2P QUIC порт
 	viper.SetDefault("relay.ports.http_api", 5553)      // P2P API через QUIC
@@ -75,6 +90,17 @@ func setDefaults() {
 	// Verify relay server certificate by default
 	viper.SetDefault("relay.tls.verify_cert", true)
 	viper.SetDefault("relay.tls.server_name", "b1.2gc.space") // Используем имя из серверного сертификата
+	// Multi-POP: список relay.endpoints пуст по умолчанию (используется relay.host)
+	viper.SetDefault("relay.selection.enabled", true)
+	viper.SetDefault("relay.selection.probe_interval", "30s")
+	viper.SetDefault("relay.selection.probe_timeout", "3s")
+	viper.SetDefault("relay.selection.preferred_region", "")
+	// Обнаружение relay через DNS SRV / well-known URL
+	viper.SetDefault("relay.discovery.enabled", false)
+	viper.SetDefault("relay.discovery.method", "auto")
+	viper.SetDefault("relay.discovery.domain", "")
+	viper.SetDefault("relay.discovery.well_known_url", "")
+	viper.SetDefault("relay.discovery.timeout", "5s")
 
 	// Authentication
 	viper.SetDefault("auth.type", "jwt")
@@ -105,20 +131,20 @@ func setDefaults() {
 	viper.SetDefault("metrics.pushgateway.instance", "")
 	viper.SetDefault("metrics.pushgateway.push_interval", "30s")
 
-	// API configuration - синхронизировано с edge.2gc.ru
-	viper.SetDefault("api.base_url", "https://edge.2gc.ru:5553")      // HTTPS основной домен через QUIC порт
-	viper.SetDefault("api.p2p_api_url", "https://edge.2gc.ru:5553")   // P2P API через QUIC порт
-	viper.SetDefault("api.heartbeat_url", "https://edge.2gc.ru:5553") // Heartbeat через QUIC порт
-	viper.SetDefault("api.insecure_skip_verify", false)               // Проверять SSL сертификаты
+	// API configuration - пустые URL выводятся из relay.host и relay.ports
+	viper.SetDefault("api.base_url", "")                // https://<relay.host>:<relay.ports.http_api>
+	viper.SetDefault("api.p2p_api_url", "")             // https://<relay.host>:<relay.ports.p2p_api>
+	viper.SetDefault("api.heartbeat_url", "")           // api.base_url
+	viper.SetDefault("api.insecure_skip_verify", false) // Проверять SSL сертификаты
 	viper.SetDefault("api.timeout", "30s")
 	viper.SetDefault("api.max_retries", 3)
 	viper.SetDefault("api.backoff_multiplier", 2.0)
 	viper.SetDefault("api.max_backoff", "60s")
 
-	// ICE configuration - синхронизировано с реальными серверами
-	viper.SetDefault("ice.stun_servers", []string{"edge.2gc.ru:19302", "stun.l.google.com:19302"})
-	viper.SetDefault("ice.turn_servers", []string{"edge.2gc.ru:3478"})
-	viper.SetDefault("ice.derp_servers", []string{"edge.2gc.ru:3479"})
+	// ICE configuration - пустые списки выводятся из relay.host и relay.ports
+	viper.SetDefault("ice.stun_servers", []string{}) // <relay.host>:<relay.ports.stun>, stun.l.google.com:19302
+	viper.SetDefault("ice.turn_servers", []string{}) // <relay.host>:<relay.ports.turn>
+	viper.SetDefault("ice.derp_servers", []string{}) // <relay.host>:<relay.ports.derp>
 	viper.SetDefault("ice.timeout", "30s")
 	viper.SetDefault("ice.max_binding_requests", 7)
 	viper.SetDefault("ice.connectivity_checks", true)
@@ -145,10 +171,37 @@ func setDefaults() {
 	viper.SetDefault("p2p.fallback_enabled", true)
 	viper.SetDefault("p2p.derp_fallback", true)
 	viper.SetDefault("p2p.websocket_fallback", true)
+	viper.SetDefault("p2p.listen_port", 0)
+	viper.SetDefault("p2p.port_mapping.enabled", false)
+	viper.SetDefault("p2p.port_mapping.protocols", []string{"pcp", "natpmp", "upnp"})
+	viper.SetDefault("p2p.port_mapping.gateway", "")
+	viper.SetDefault("p2p.port_mapping.lifetime", "2h")
+	viper.SetDefault("p2p.acl.enabled", false)
+	viper.SetDefault("p2p.acl.policy_file", "")
+	viper.SetDefault("p2p.acl.refresh_interval", "1m")
+	viper.SetDefault("p2p.identity.enabled", true)
+	viper.SetDefault("p2p.identity.key_file", "") // ~/.cloudbridge-client/identity.key
+	viper.SetDefault("p2p.identity.rotation_interval", "0s")
+	viper.SetDefault("p2p.identity.require_peer_keys", true)
+	viper.SetDefault("p2p.identity.pin_file", "") // ~/.cloudbridge-client/known_peers.json
+	viper.SetDefault("p2p.dns.enabled", true)
+	viper.SetDefault("p2p.dns.domain", "mesh")
+	viper.SetDefault("p2p.dns.listen_addr", "")       // <overlay-ip>:53
+	viper.SetDefault("p2p.dns.upstreams", []string{}) // по умолчанию network_config.dns из токена или /etc/resolv.conf
+	viper.SetDefault("p2p.dns.configure_system", "")
+	viper.SetDefault("p2p.routes.advertise", []string{})
+	viper.SetDefault("p2p.routes.advertise_exit_node", false)
+	viper.SetDefault("p2p.routes.accept", []string{})
+	viper.SetDefault("p2p.routes.exit_node", "")
+	viper.SetDefault("p2p.routes.snat", true)
+	viper.SetDefault("p2p.control.enabled", true)
+	viper.SetDefault("p2p.control.socket", "") // ~/.cloudbridge-client/control.sock
+	viper.SetDefault("p2p.socks5.enabled", false)
+	viper.SetDefault("p2p.socks5.listen_addr", "127.0.0.1:1080")
 
 	// TURN configuration
 	viper.SetDefault("turn.enabled", true)
-	viper.SetDefault("turn.servers", []string{"localhost:3478"})
+	viper.SetDefault("turn.servers", []string{}) // дополнительно к ice.turn_servers (по умолчанию relay.host:relay.ports.turn)
 	viper.SetDefault("turn.username", "cloudbridge")
 	viper.SetDefault("turn.password", "cloudbridge123")
 	viper.SetDefault("turn.realm", "cloudbridge.local")
@@ -157,14 +210,14 @@ func setDefaults() {
 
 	// DERP configuration
 	viper.SetDefault("derp.enabled", true)
-	viper.SetDefault("derp.servers", []string{"localhost:3479"})
+	viper.SetDefault("derp.servers", []string{}) // дополнительно к ice.derp_servers (по умолчанию relay.host:relay.ports.derp)
 	viper.SetDefault("derp.timeout", "30s")
 	viper.SetDefault("derp.max_connections", 100)
 	viper.SetDefault("derp.fallback_priority", 1)
 
 	// WebSocket configuration
 	viper.SetDefault("websocket.enabled", true)
-	viper.SetDefault("websocket.endpoint", "wss://edge.2gc.ru:5553/ws") // Правильный WebSocket endpoint
+	viper.SetDefault("websocket.endpoint", "") // wss://<relay.host>:<relay.ports.http_api>/ws
 	viper.SetDefault("websocket.timeout", "30s")
 	viper.SetDefault("websocket.ping_interval", "15s")
 	viper.SetDefault("websocket.max_reconnect_attempts", 5)
@@ -175,6 +228,89 @@ func setDefaults() {
 	viper.SetDefault("wireguard.port", 51820)
 	viper.SetDefault("wireguard.mtu", 1420)
 	viper.SetDefault("wireguard.persistent_keepalive", "25s")
+	viper.SetDefault("wireguard.mode", "kernel")
+
+	// Transport auto-switch configuration
+	viper.SetDefault("auto_switch.enabled", true)
+	viper.SetDefault("auto_switch.order", []string{"quic", "wireguard"})
+	viper.SetDefault("auto_switch.check_interval", "10s")
+	viper.SetDefault("auto_switch.check_timeout", "5s")
+	viper.SetDefault("auto_switch.cooldown", "15s")
+	viper.SetDefault("auto_switch.min_dwell", "20s")
+	viper.SetDefault("auto_switch.failure_threshold", 3)
+	viper.SetDefault("auto_switch.recovery_threshold", 3)
+}
+
+// discoverRelayEndpoints resolves relay endpoints for relay.discovery.domain
+// (relay.host by default) and applies them to the configuration
+func discoverRelayEndpoints(c *types.Config) error {
+	domain := c.Relay.Discovery.Domain
+	if domain == "" {
+		domain = c.Relay.Host
+	}
+
+	discoverer := discovery.NewDiscoverer(&discovery.Config{
+		Method:       c.Relay.Discovery.Method,
+		Domain:       domain,
+		WellKnownURL: c.Relay.Discovery.WellKnownURL,
+		Timeout:      c.Relay.Discovery.Timeout,
+	}, nil, nil, nil)
+
+	endpoints, err := discoverer.Discover(context.Background())
+	if err != nil {
+		return err
+	}
+	discovery.ApplyToConfig(c, endpoints)
+	return nil
+}
+
+// fallbackSTUNServer is appended to derived STUN servers as a public fallback
+const fallbackSTUNServer = "stun.l.google.com:19302"
+
+// applyDerivedDefaults fills API, ICE and WebSocket endpoints that were left empty
+// from relay.host and relay.ports, so pointing relay.host at a staging or self-hosted
+// relay moves every endpoint with it.
+func applyDerivedDefaults(c *types.Config) {
+	host := c.Relay.Host
+	if host == "" {
+		return
+	}
+	ports := c.Relay.Ports
+	apiPort := ports.HTTPAPI
+	if apiPort == 0 {
+		apiPort = c.Relay.Port
+	}
+
+	if c.API.BaseURL == "" {
+		c.API.BaseURL = "https://" + net.JoinHostPort(host, strconv.Itoa(apiPort))
+	}
+	if c.API.P2PAPIURL == "" {
+		if ports.P2PAPI > 0 {
+			c.API.P2PAPIURL = "https://" + net.JoinHostPort(host, strconv.Itoa(ports.P2PAPI))
+		} else {
+			c.API.P2PAPIURL = c.API.BaseURL
+		}
+	}
+	if c.API.HeartbeatURL == "" {
+		c.API.HeartbeatURL = c.API.BaseURL
+	}
+
+	if len(c.ICE.STUNServers) == 0 {
+		if ports.STUN > 0 {
+			c.ICE.STUNServers = append(c.ICE.STUNServers, net.JoinHostPort(host, strconv.Itoa(ports.STUN)))
+		}
+		c.ICE.STUNServers = append(c.ICE.STUNServers, fallbackSTUNServer)
+	}
+	if len(c.ICE.TURNServers) == 0 && ports.TURN > 0 {
+		c.ICE.TURNServers = []string{net.JoinHostPort(host, strconv.Itoa(ports.TURN))}
+	}
+	if len(c.ICE.DERPServers) == 0 && ports.DERP > 0 {
+		c.ICE.DERPServers = []string{net.JoinHostPort(host, strconv.Itoa(ports.DERP))}
+	}
+
+	if c.WebSocket.Endpoint == "" {
+		c.WebSocket.Endpoint = "wss://" + net.JoinHostPort(host, strconv.Itoa(apiPort)) + "/ws"
+	}
 }
 
 // validateConfig validates the configuration
@@ -187,6 +323,47 @@ func validateConfig(c *types.Config) error {
 		return fmt.Errorf("invalid relay port")
 	}
 
+	seenEndpoints := make(map[string]bool, len(c.Relay.Endpoints))
+	for i, ep := range c.Relay.Endpoints {
+		if ep.Host == "" {
+			return fmt.Errorf("relay endpoint #%d: host is required", i)
+		}
+		if ep.Port < 0 || ep.Port > 65535 {
+			return fmt.Errorf("relay endpoint %s: invalid port", ep.Host)
+		}
+		id := ep.ID
+		if id == "" {
+			id = ep.Host
+		}
+		if seenEndpoints[id] {
+			return fmt.Errorf("duplicate relay endpoint id: %s", id)
+		}
+		seenEndpoints[id] = true
+	}
+
+	switch c.Relay.Discovery.Method {
+	case "", "auto", "srv", "well_known":
+	default:
+		return fmt.Errorf("unsupported relay discovery method: %s", c.Relay.Discovery.Method)
+	}
+
+	for _, mode := range c.AutoSwitch.Order {
+		switch mode {
+		case "quic", "wireguard":
+		case "masque", "grpc", "ws":
+			// Эти транспорты несут только управляющий трафик relay: клиент предупреждает
+			// и исключает их из порядка переключения, старые конфигурации остаются рабочими
+		default:
+			return fmt.Errorf("unsupported auto_switch transport mode: %s", mode)
+		}
+	}
+
+	switch c.WireGuard.Mode {
+	case "", "kernel", "userspace", "netstack":
+	default:
+		return fmt.Errorf("unsupported wireguard mode: %s", c.WireGuard.Mode)
+	}
+
 	if c.Relay.TLS.Enabled && c.Relay.TLS.MinVersion != "1.3" {
 		return fmt.Errorf("only TLS 1.3 is supported")
 	}
@@ -226,6 +403,44 @@ func validateConfig(c *types.Config) error {
 		return fmt.Errorf("backoff multiplier must be positive")
 	}
 
+	if c.P2P.ListenPort < 0 || c.P2P.ListenPort > 65535 {
+		return fmt.Errorf("invalid p2p listen port: %d", c.P2P.ListenPort)
+	}
+	for _, protocol := range c.P2P.PortMapping.Protocols {
+		switch protocol {
+		case "pcp", "natpmp", "upnp":
+		default:
+			return fmt.Errorf("unsupported port mapping protocol: %s", protocol)
+		}
+	}
+	if c.P2P.ACL.RefreshInterval < 0 {
+		return fmt.Errorf("p2p ACL refresh interval must not be negative")
+	}
+	if c.P2P.Identity.RotationInterval < 0 {
+		return fmt.Errorf("p2p identity rotation interval must not be negative")
+	}
+	switch c.P2P.DNS.ConfigureSystem {
+	case "", "auto", "resolved", "resolvconf":
+	default:
+		return fmt.Errorf("unsupported p2p DNS configure_system mode: %s", c.P2P.DNS.ConfigureSystem)
+	}
+	for _, route := range c.P2P.Routes.Advertise {
+		if _, _, err := net.ParseCIDR(route); err != nil && net.ParseIP(route) == nil {
+			return fmt.Errorf("invalid p2p advertised route: %s", route)
+		}
+	}
+	for _, route := range c.P2P.Routes.Accept {
+		_, network, err := net.ParseCIDR(route)
+		if err != nil && net.ParseIP(route) == nil {
+			return fmt.Errorf("invalid p2p accepted route: %s", route)
+		}
+		if network != nil {
+			if ones, _ := network.Mask.Size(); ones == 0 {
+				return fmt.Errorf("p2p accepted route %s is a default route, use exit_node instead", route)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -311,6 +526,9 @@ func substituteEnvVars(config *types.Config) {
 	config.Relay.TLS.ClientCert = substituteEnvVar(config.Relay.TLS.ClientCert)
 	config.Relay.TLS.ClientKey = substituteEnvVar(config.Relay.TLS.ClientKey)
 	config.Relay.TLS.ServerName = substituteEnvVar(config.Relay.TLS.ServerName)
+	for i := range config.Relay.Endpoints {
+		config.Relay.Endpoints[i].Host = substituteEnvVar(config.Relay.Endpoints[i].Host)
+	}
 
 	// Substitute in logging config
 	config.Logging.Output = substituteEnvVar(config.Logging.Output)
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+)
+
+func validTestConfig() *types.Config {
+	c := &types.Config{}
+	c.Relay.Host = "edge.2gc.ru"
+	c.Relay.Port = 8083
+	c.RateLimiting.BackoffMultiplier = 2
+	return c
+}
+
+// TestValidateConfig_AutoSwitchOrder tests that control-only transports in auto_switch.order
+// are accepted (the client ignores them with a warning) and unknown modes are rejected
+func TestValidateConfig_AutoSwitchOrder(t *testing.T) {
+	c := validTestConfig()
+	c.AutoSwitch.Order = []string{"quic", "masque", "grpc", "ws", "wireguard"}
+	if err := validateConfig(c); err != nil {
+		t.Errorf("Expected control-only modes to be accepted, got %v", err)
+	}
+
+	c.AutoSwitch.Order = []string{"quic", "tcp"}
+	if err := validateConfig(c); err == nil {
+		t.Error("Expected unknown auto_switch mode to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
+)
+
+// Client клиент control API запущенного клиента (используется командами CLI)
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient creates a new control API client for the given Unix socket
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket()
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &Client{
+		socket: socket,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// PendingPeers возвращает пиров, ожидающих подтверждения
+func (c *Client) PendingPeers() ([]p2p.PendingPeer, error) {
+	var peers []p2p.PendingPeer
+	if err := c.do(http.MethodGet, "/v1/peers/pending", &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// ApprovePeer подтверждает пира
+func (c *Client) ApprovePeer(peerID string) error {
+	return c.do(http.MethodPost, "/v1/peers/"+url.PathEscape(peerID)+"/approve", nil)
+}
+
+// RejectPeer отклоняет пира
+func (c *Client) RejectPeer(peerID string) error {
+	return c.do(http.MethodPost, "/v1/peers/"+url.PathEscape(peerID)+"/reject", nil)
+}
+
+// do выполняет запрос к control API и разбирает ответ в out (nil — ответ не нужен)
+func (c *Client) do(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, "http://control"+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create control request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach client control API at %s: %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("control API returned %s", resp.Status)
+		}
+		return fmt.Errorf("control API: %s", apiErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode control API response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
+)
+
+// Logger interface for control API logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// ErrP2PNotRunning P2P mesh еще не запущен: решать по пирам некому
+var ErrP2PNotRunning = errors.New("P2P mesh is not running")
+
+// PeerApprover очередь пиров, ожидающих подтверждения (p2p.Manager или клиент relay)
+type PeerApprover interface {
+	PendingPeers() []p2p.PendingPeer
+	ApprovePeer(peerID string) error
+	RejectPeer(peerID string) error
+}
+
+// Config настройки control API
+type Config struct {
+	Socket string // Unix socket (права 0600: доступ только у владельца процесса)
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{Socket: DefaultSocket()}
+}
+
+// DefaultSocket возвращает путь сокета по умолчанию: ~/.cloudbridge-client/control.sock
+func DefaultSocket() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "control.sock"
+	}
+	return filepath.Join(home, ".cloudbridge-client", "control.sock")
+}
+
+// Server локальный control API клиента на Unix socket: просмотр очереди пиров,
+// ожидающих подтверждения, подтверждение и отклонение
+type Server struct {
+	config   *Config
+	peers    PeerApprover
+	logger   Logger
+	server   *http.Server
+	listener net.Listener
+	mu       sync.Mutex
+}
+
+// NewServer creates a new control API server
+func NewServer(config *Config, peers PeerApprover, logger Logger) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Socket == "" {
+		config.Socket = DefaultSocket()
+	}
+	return &Server{config: config, peers: peers, logger: logger}
+}
+
+// Start открывает сокет и начинает обслуживать запросы
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		return fmt.Errorf("control API already started")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.Socket), 0o700); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	// Сокет, оставшийся после аварийного завершения, мешает bind
+	if err := removeStaleSocket(s.config.Socket); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", s.config.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket %s: %w", s.config.Socket, err)
+	}
+	if err := os.Chmod(s.config.Socket, 0o600); err != nil {
+		_ = listener.Close() //nolint:errcheck // cleanup after failed setup
+		return fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Control API server error", "error", err)
+		}
+	}(s.server)
+
+	s.logger.Info("Control API started", "socket", s.config.Socket)
+	return nil
+}
+
+// removeStaleSocket удаляет сокет path, если его никто не слушает
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close() //nolint:errcheck // probe connection only
+		return fmt.Errorf("control socket %s is in use by another process", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+	return nil
+}
+
+// Stop останавливает сервер и удаляет сокет
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Close()
+	s.server, s.listener = nil, nil
+	if removeErr := os.Remove(s.config.Socket); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+		s.logger.Debug("Failed to remove control socket", "socket", s.config.Socket, "error", removeErr)
+	}
+	s.logger.Info("Control API stopped")
+	return err
+}
+
+// Socket возвращает путь сокета
+func (s *Server) Socket() string {
+	return s.config.Socket
+}
+
+// Handler возвращает HTTP обработчик control API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/peers/pending", s.handlePending)
+	mux.HandleFunc("POST /v1/peers/{peer_id}/approve", s.handleDecision(s.peers.ApprovePeer, "approved"))
+	mux.HandleFunc("POST /v1/peers/{peer_id}/reject", s.handleDecision(s.peers.RejectPeer, "rejected"))
+	return mux
+}
+
+func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.peers.PendingPeers())
+}
+
+// handleDecision применяет решение decide к пиру из пути запроса
+func (s *Server) handleDecision(decide func(peerID string) error, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		peerID := r.PathValue("peer_id")
+		if err := decide(peerID); err != nil {
+			s.logger.Warn("Control API peer decision failed", "peer_id", peerID, "decision", status, "error", err)
+			writeJSON(w, errorStatus(err), errorResponse{Error: err.Error()})
+			return
+		}
+		s.logger.Info("Peer decision applied via control API", "peer_id", peerID, "decision", status)
+		writeJSON(w, http.StatusOK, decisionResponse{PeerID: peerID, Status: status})
+	}
+}
+
+// errorStatus выбирает HTTP статус для ошибки решения по пиру
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, p2p.ErrPeerNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, ErrP2PNotRunning):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// decisionResponse ответ на подтверждение или отклонение пира
+type decisionResponse struct {
+	PeerID string `json:"peer_id"`
+	Status string `json:"status"` // approved или rejected
+}
+
+// errorResponse ответ с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body) //nolint:errcheck // client went away
+}
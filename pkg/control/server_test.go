@@ -0,0 +1,185 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
+)
+
+// fakeRelay relay API, запоминающий ответы на входящие запросы соединения
+type fakeRelay struct {
+	mu      sync.Mutex
+	answers map[string]api.P2PConnectionAnswer
+}
+
+func newFakeRelay(t *testing.T) (*fakeRelay, string) {
+	t.Helper()
+	relay := &fakeRelay{answers: make(map[string]api.P2PConnectionAnswer)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/p2p/answer" {
+			var answer api.P2PConnectionAnswer
+			if err := json.NewDecoder(r.Body).Decode(&answer); err == nil {
+				relay.mu.Lock()
+				relay.answers[answer.SessionID] = answer
+				relay.mu.Unlock()
+			}
+		}
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"success":true}`)) //nolint:errcheck // test server
+	}))
+	t.Cleanup(server.Close)
+	return relay, server.URL
+}
+
+func (r *fakeRelay) answer(sessionID string) (api.P2PConnectionAnswer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	answer, ok := r.answers[sessionID]
+	return answer, ok
+}
+
+// startTestServer запускает control API над p2p.Manager с очередью подтверждения
+func startTestServer(t *testing.T, whitelist *p2p.PeerWhitelist) (*p2p.Manager, *fakeRelay, *Client) {
+	t.Helper()
+	relay, relayURL := newFakeRelay(t)
+	logger := p2p.NewSimpleLogger("test")
+	manager := p2p.NewManagerWithAPI(&p2p.P2PConfig{
+		ConnectionType: p2p.ConnectionTypeP2PMesh,
+		MaxConnections: 10,
+		PeerWhitelist:  whitelist,
+	}, &api.ManagerConfig{BaseURL: relayURL, Timeout: 5 * time.Second}, nil, "test-token", logger)
+	t.Cleanup(func() {
+		_ = manager.Stop() //nolint:errcheck // test cleanup
+	})
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	server := NewServer(&Config{Socket: socket}, manager, logger)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start control API: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Stop(); err != nil {
+			t.Errorf("Failed to stop control API: %v", err)
+		}
+	})
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Control socket missing: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Control socket permissions = %o, want 600", perm)
+	}
+	return manager, relay, NewClient(socket)
+}
+
+func TestControl_ApprovePendingPeer(t *testing.T) {
+	manager, relay, client := startTestServer(t, &p2p.PeerWhitelist{AllowedPeers: []string{"peer-a", "peer-b"}})
+
+	// Входящие запросы без auto_approve ждут решения
+	manager.HandleIncomingRequest(&api.P2PIncomingRequest{SessionID: "s1", FromPeerID: "peer-a"})
+	manager.HandleIncomingRequest(&api.P2PIncomingRequest{SessionID: "s2", FromPeerID: "peer-b"})
+
+	pending, err := client.PendingPeers()
+	if err != nil {
+		t.Fatalf("PendingPeers failed: %v", err)
+	}
+	if len(pending) != 2 || pending[0].PeerID != "peer-a" || pending[0].SessionID != "s1" {
+		t.Fatalf("Unexpected pending peers: %+v", pending)
+	}
+	if _, ok := relay.answer("s1"); ok {
+		t.Fatal("Pending request must not be answered before a decision")
+	}
+
+	// Подтвержденный запрос переходит к установлению сессии с пиром
+	if err := client.ApprovePeer("peer-a"); err != nil {
+		t.Fatalf("ApprovePeer failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if session, ok := manager.GetPeerSession("peer-a"); ok && session.SessionID == "s1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Approved request did not start a session with the peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Отклоненному пиру relay передает отказ
+	if err := client.RejectPeer("peer-b"); err != nil {
+		t.Fatalf("RejectPeer failed: %v", err)
+	}
+	if answer, ok := relay.answer("s2"); !ok || answer.Accepted || answer.TargetPeerID != "peer-b" {
+		t.Errorf("Expected rejection answer for peer-b, got %+v (%v)", answer, ok)
+	}
+	if pending, err := client.PendingPeers(); err != nil || len(pending) != 0 {
+		t.Errorf("Expected empty queue after decisions, got %+v (%v)", pending, err)
+	}
+
+	// Отклоненный пир больше не попадает в очередь
+	manager.HandleIncomingRequest(&api.P2PIncomingRequest{SessionID: "s3", FromPeerID: "peer-b"})
+	if pending, _ := client.PendingPeers(); len(pending) != 0 {
+		t.Errorf("Expected rejected peer to bypass the queue, got %+v", pending)
+	}
+	if answer, ok := relay.answer("s3"); !ok || answer.Accepted {
+		t.Errorf("Expected rejected peer to be declined, got %+v (%v)", answer, ok)
+	}
+}
+
+func TestControl_ApproveNotAllowedPeer(t *testing.T) {
+	_, _, client := startTestServer(t, &p2p.PeerWhitelist{AllowedPeers: []string{"peer-a"}})
+
+	err := client.ApprovePeer("peer-x")
+	if err == nil || !strings.Contains(err.Error(), p2p.ErrPeerNotAllowed.Error()) {
+		t.Errorf("Expected whitelist error, got %v", err)
+	}
+}
+
+func TestControl_StaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	logger := p2p.NewSimpleLogger("test")
+	manager := p2p.NewManager(&p2p.P2PConfig{ConnectionType: p2p.ConnectionTypeP2PMesh}, logger)
+
+	first := NewServer(&Config{Socket: socket}, manager, logger)
+	if err := first.Start(); err != nil {
+		t.Fatalf("Expected stale socket to be replaced, got %v", err)
+	}
+	defer first.Stop() //nolint:errcheck // test cleanup
+
+	// Сокет работающего клиента не перехватывается
+	second := NewServer(&Config{Socket: socket}, manager, logger)
+	if err := second.Start(); err == nil {
+		second.Stop() //nolint:errcheck // test cleanup
+		t.Fatal("Expected second server on a live socket to fail")
+	}
+	if _, err := NewClient(socket).PendingPeers(); err != nil {
+		t.Errorf("First server stopped answering: %v", err)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	if status := errorStatus(ErrP2PNotRunning); status != 503 {
+		t.Errorf("errorStatus(ErrP2PNotRunning) = %d, want 503", status)
+	}
+	if status := errorStatus(errors.New("boom")); status != 500 {
+		t.Errorf("errorStatus(other) = %d, want 500", status)
+	}
+}
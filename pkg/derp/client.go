@@ -0,0 +1,403 @@
+package derp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Типы фреймов. Фрейм — бинарное WebSocket сообщение:
+// type (1 байт) | длина ключа (1 байт) | публичный ключ пира | payload
+const (
+	frameClientInfo byte = 0x01 // клиент → сервер: собственный публичный ключ
+	frameSendPacket byte = 0x02 // клиент → сервер: пакет для пира с ключом
+	frameRecvPacket byte = 0x03 // сервер → клиент: пакет от пира с ключом
+	frameKeepAlive  byte = 0x04
+	framePeerGone   byte = 0x05 // сервер → клиент: пир с ключом отключился
+)
+
+const (
+	// MaxPacketSize максимальный размер пакета пира
+	MaxPacketSize = 64 * 1024
+	// maxKeyLen ограничение длины ключа (длина кодируется одним байтом)
+	maxKeyLen = 255
+	// DefaultPath путь DERP endpoint, если сервер задан как host:port
+	DefaultPath = "/derp"
+)
+
+// Config конфигурация DERP клиента
+type Config struct {
+	Servers              []string      `json:"servers"`    // host:port или ws(s):// URL, в порядке приоритета
+	PublicKey            string        `json:"public_key"` // ключ, по которому relay адресует пакеты этому клиенту
+	Token                string        `json:"-"`
+	InsecureSkipVerify   bool          `json:"insecure_skip_verify"`
+	DialTimeout          time.Duration `json:"dial_timeout"`
+	KeepAliveInterval    time.Duration `json:"keepalive_interval"`
+	ReconnectInterval    time.Duration `json:"reconnect_interval"`
+	MaxReconnectInterval time.Duration `json:"max_reconnect_interval"`
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		DialTimeout:          10 * time.Second,
+		KeepAliveInterval:    30 * time.Second,
+		ReconnectInterval:    time.Second,
+		MaxReconnectInterval: 30 * time.Second,
+	}
+}
+
+// Client DERP клиент: пересылает пакеты пирам через HTTPS/WebSocket relay,
+// адресуя их публичным ключом пира
+type Client struct {
+	config *Config
+	logger Logger
+
+	conn      *websocket.Conn
+	server    string // URL текущего сервера
+	connected atomic.Bool
+	sendMu    sync.Mutex
+
+	peers map[string]*PeerConn
+
+	packetsSent     atomic.Int64
+	packetsReceived atomic.Int64
+	packetsDropped  atomic.Int64
+	reconnects      atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+}
+
+// NewClient создает DERP клиент; незаданные интервалы берутся из DefaultConfig
+func NewClient(config *Config, logger Logger) *Client {
+	defaults := DefaultConfig()
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaults.DialTimeout
+	}
+	if config.KeepAliveInterval <= 0 {
+		config.KeepAliveInterval = defaults.KeepAliveInterval
+	}
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = defaults.ReconnectInterval
+	}
+	if config.MaxReconnectInterval <= 0 {
+		config.MaxReconnectInterval = defaults.MaxReconnectInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		config: config,
+		logger: logger,
+		peers:  make(map[string]*PeerConn),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start проверяет конфигурацию и запускает фоновое подключение к серверам
+func (c *Client) Start() error {
+	if len(c.config.Servers) == 0 {
+		return fmt.Errorf("no DERP servers configured")
+	}
+	if c.config.PublicKey == "" || len(c.config.PublicKey) > maxKeyLen {
+		return fmt.Errorf("invalid DERP public key")
+	}
+	for _, server := range c.config.Servers {
+		if _, err := serverURL(server); err != nil {
+			return err
+		}
+	}
+
+	c.wg.Add(1)
+	go c.run()
+	return nil
+}
+
+// Stop закрывает соединение с relay и все соединения пиров
+func (c *Client) Stop() {
+	c.cancel()
+	c.closeConn()
+	c.wg.Wait()
+
+	c.mu.Lock()
+	peers := c.peers
+	c.peers = make(map[string]*PeerConn)
+	c.mu.Unlock()
+	for _, peer := range peers {
+		peer.closeLocal()
+	}
+}
+
+// IsConnected сообщает, подключен ли клиент к DERP серверу
+func (c *Client) IsConnected() bool {
+	return c.connected.Load()
+}
+
+// PeerConn возвращает net.PacketConn для обмена пакетами с пиром через relay.
+// Пакеты, пришедшие до вызова, уже буферизованы в соединении пира.
+func (c *Client) PeerConn(peerKey string) (*PeerConn, error) {
+	if peerKey == "" || len(peerKey) > maxKeyLen {
+		return nil, fmt.Errorf("invalid DERP peer key")
+	}
+	if c.ctx.Err() != nil {
+		return nil, fmt.Errorf("DERP client stopped")
+	}
+	return c.peerConn(peerKey), nil
+}
+
+// GetMetrics возвращает метрики DERP клиента
+func (c *Client) GetMetrics() map[string]interface{} {
+	c.mu.RLock()
+	server, peers := c.server, len(c.peers)
+	c.mu.RUnlock()
+
+	return map[string]interface{}{
+		"connected":        c.connected.Load(),
+		"server":           server,
+		"peers":            peers,
+		"packets_sent":     c.packetsSent.Load(),
+		"packets_received": c.packetsReceived.Load(),
+		"packets_dropped":  c.packetsDropped.Load(),
+		"reconnects":       c.reconnects.Load(),
+	}
+}
+
+func (c *Client) peerConn(peerKey string) *PeerConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	peer, ok := c.peers[peerKey]
+	if !ok {
+		peer = newPeerConn(c, peerKey)
+		c.peers[peerKey] = peer
+	}
+	return peer
+}
+
+// removePeer удаляет соединение пира после его закрытия
+func (c *Client) removePeer(peer *PeerConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.peers[peer.key]; ok && current == peer {
+		delete(c.peers, peer.key)
+	}
+}
+
+// send отправляет пакет пиру через текущее соединение с relay
+func (c *Client) send(peerKey string, packet []byte) error {
+	if len(packet) > MaxPacketSize {
+		return fmt.Errorf("packet exceeds %d bytes", MaxPacketSize)
+	}
+	if err := c.writeFrame(frameSendPacket, peerKey, packet); err != nil {
+		return err
+	}
+	c.packetsSent.Add(1)
+	return nil
+}
+
+func (c *Client) writeFrame(frameType byte, key string, payload []byte) error {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil || !c.connected.Load() {
+		return fmt.Errorf("DERP relay not connected")
+	}
+
+	frame := make([]byte, 0, 2+len(key)+len(payload))
+	frame = append(frame, frameType, byte(len(key)))
+	frame = append(frame, key...)
+	frame = append(frame, payload...)
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if err := websocket.Message.Send(conn, frame); err != nil {
+		return fmt.Errorf("failed to send DERP frame: %w", err)
+	}
+	return nil
+}
+
+// run поддерживает соединение, перебирая серверы по приоритету с экспоненциальной задержкой
+func (c *Client) run() {
+	defer c.wg.Done()
+
+	backoff := c.config.ReconnectInterval
+	for {
+		for _, server := range c.config.Servers {
+			err := c.connectAndServe(server)
+			if c.ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				c.logger.Warn("DERP relay connection lost", "server", server, "error", err)
+			}
+		}
+		c.reconnects.Add(1)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.config.MaxReconnectInterval {
+			backoff = c.config.MaxReconnectInterval
+		}
+	}
+}
+
+// connectAndServe подключается к серверу, регистрирует ключ и читает фреймы до ошибки
+func (c *Client) connectAndServe(server string) error {
+	wsConfig, err := c.websocketConfig(server)
+	if err != nil {
+		return err
+	}
+
+	dialCtx, cancel := context.WithTimeout(c.ctx, c.config.DialTimeout)
+	conn, err := wsConfig.DialContext(dialCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	conn.PayloadType = websocket.BinaryFrame
+
+	c.mu.Lock()
+	c.conn = conn
+	c.server = wsConfig.Location.String()
+	c.mu.Unlock()
+	c.connected.Store(true)
+
+	keepAliveDone := make(chan struct{})
+	defer func() {
+		close(keepAliveDone)
+		c.closeConn()
+	}()
+
+	if err := c.writeFrame(frameClientInfo, c.config.PublicKey, nil); err != nil {
+		return err
+	}
+	c.logger.Info("Connected to DERP relay", "server", c.server)
+	go c.keepAliveLoop(keepAliveDone)
+
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(conn, &frame); err != nil {
+			return fmt.Errorf("failed to read frame: %w", err)
+		}
+		c.handleFrame(frame)
+	}
+}
+
+func (c *Client) handleFrame(frame []byte) {
+	if len(frame) < 2 || len(frame) < 2+int(frame[1]) {
+		c.logger.Debug("Dropping malformed DERP frame", "size", len(frame))
+		return
+	}
+	frameType, key, payload := frame[0], string(frame[2:2+int(frame[1])]), frame[2+int(frame[1]):]
+
+	switch frameType {
+	case frameRecvPacket:
+		c.packetsReceived.Add(1)
+		if !c.peerConn(key).deliver(payload) {
+			c.packetsDropped.Add(1)
+		}
+	case framePeerGone:
+		c.logger.Debug("DERP peer gone", "peer_key", key)
+	case frameKeepAlive:
+		// keepalive
+	default:
+		c.logger.Debug("Ignoring unknown DERP frame", "type", frameType)
+	}
+}
+
+// keepAliveLoop не дает промежуточным прокси закрыть простаивающее соединение
+func (c *Client) keepAliveLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(c.config.KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(frameKeepAlive, "", nil); err != nil {
+				c.logger.Debug("Failed to send DERP keepalive", "error", err)
+				c.closeConn()
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) closeConn() {
+	c.connected.Store(false)
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close() //nolint:errcheck // connection is being dropped
+	}
+}
+
+// websocketConfig строит конфигурацию WebSocket для сервера с авторизацией
+func (c *Client) websocketConfig(server string) (*websocket.Config, error) {
+	u, err := serverURL(server)
+	if err != nil {
+		return nil, err
+	}
+
+	origin := "https://" + u.Host
+	if u.Scheme == "ws" {
+		origin = "http://" + u.Host
+	}
+	wsConfig, err := websocket.NewConfig(u.String(), origin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebSocket config: %w", err)
+	}
+	if c.config.Token != "" {
+		wsConfig.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	if u.Scheme == "wss" {
+		wsConfig.TlsConfig = &tls.Config{
+			ServerName:         u.Hostname(),
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: c.config.InsecureSkipVerify, // #nosec G402 -- opt-in for test environments
+		}
+	}
+	return wsConfig, nil
+}
+
+// serverURL приводит адрес сервера к ws(s):// URL; host:port означает wss://host:port/derp
+func serverURL(server string) (*url.URL, error) {
+	raw := server
+	if !strings.Contains(raw, "://") {
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			return nil, fmt.Errorf("invalid DERP server %q: %w", server, err)
+		}
+		raw = "wss://" + raw + DefaultPath
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid DERP server %q", server)
+	}
+	return u, nil
+}
@@ -0,0 +1,153 @@
+package derp
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+// testRelay минимальный DERP сервер: пересылает пакеты клиенту с ключом получателя
+type testRelay struct {
+	mu      sync.Mutex
+	clients map[string]*websocket.Conn
+}
+
+func newTestRelay(t *testing.T) (*testRelay, string) {
+	t.Helper()
+	relay := &testRelay{clients: make(map[string]*websocket.Conn)}
+	server := httptest.NewServer(websocket.Handler(relay.serve))
+	t.Cleanup(server.Close)
+	return relay, "ws" + strings.TrimPrefix(server.URL, "http") + DefaultPath
+}
+
+func (r *testRelay) serve(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	var key string
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(ws, &frame); err != nil {
+			return
+		}
+		if len(frame) < 2 || len(frame) < 2+int(frame[1]) {
+			return
+		}
+		frameKey := string(frame[2 : 2+int(frame[1])])
+
+		switch frame[0] {
+		case frameClientInfo:
+			key = frameKey
+			r.mu.Lock()
+			r.clients[key] = ws
+			r.mu.Unlock()
+		case frameSendPacket:
+			r.mu.Lock()
+			dst := r.clients[frameKey]
+			r.mu.Unlock()
+			if dst == nil {
+				continue
+			}
+			out := append([]byte{frameRecvPacket, byte(len(key))}, key...)
+			out = append(out, frame[2+int(frame[1]):]...)
+			_ = websocket.Message.Send(dst, out) //nolint:errcheck // test relay drops on error
+		}
+	}
+}
+
+func (r *testRelay) registered(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clients[key] != nil
+}
+
+func startTestClient(t *testing.T, relay *testRelay, url, key string) *Client {
+	t.Helper()
+	client := NewClient(&Config{Servers: []string{url}, PublicKey: key}, testLogger{})
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(client.Stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !client.IsConnected() || !relay.registered(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client %s did not connect", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return client
+}
+
+func TestClient_PacketsRoutedByKey(t *testing.T) {
+	relay, url := newTestRelay(t)
+	alice := startTestClient(t, relay, url, "key-alice")
+	bob := startTestClient(t, relay, url, "key-bob")
+
+	aliceToBob, err := alice.PeerConn("key-bob")
+	if err != nil {
+		t.Fatalf("PeerConn failed: %v", err)
+	}
+	bobToAlice, err := bob.PeerConn("key-alice")
+	if err != nil {
+		t.Fatalf("PeerConn failed: %v", err)
+	}
+
+	if _, err := aliceToBob.WriteTo([]byte("hello"), nil); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+
+	_ = bobToAlice.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck // never fails
+	buf := make([]byte, 64)
+	n, addr, err := bobToAlice.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+	if string(buf[:n]) != "hello" || addr.String() != "key-alice" {
+		t.Errorf("Expected hello from key-alice, got %q from %s", buf[:n], addr)
+	}
+}
+
+func TestPeerConn_ReadDeadlineAndClose(t *testing.T) {
+	client := NewClient(&Config{Servers: []string{"relay.example.com:443"}, PublicKey: "key-alice"}, testLogger{})
+	conn, err := client.PeerConn("key-bob")
+	if err != nil {
+		t.Fatalf("PeerConn failed: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)) //nolint:errcheck // never fails
+	if _, _, err := conn.ReadFrom(make([]byte, 16)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	if _, err := conn.WriteTo([]byte("x"), nil); err == nil {
+		t.Error("Expected write to fail without relay connection")
+	}
+
+	_ = conn.Close()                      //nolint:errcheck // never fails
+	_ = conn.SetReadDeadline(time.Time{}) //nolint:errcheck // never fails
+	if _, _, err := conn.ReadFrom(make([]byte, 16)); err == nil {
+		t.Error("Expected read on closed connection to fail")
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	u, err := serverURL("relay.example.com:3479")
+	if err != nil || u.String() != "wss://relay.example.com:3479/derp" {
+		t.Errorf("Expected default wss URL, got %v (%v)", u, err)
+	}
+	if _, err := serverURL("https://relay.example.com/derp"); err == nil {
+		t.Error("Expected error for non-WebSocket URL")
+	}
+}
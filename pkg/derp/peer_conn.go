@@ -0,0 +1,155 @@
+package derp
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// peerQueueSize число буферизованных входящих пакетов пира; при переполнении
+// пакеты отбрасываются, как при потерях в UDP
+const peerQueueSize = 256
+
+// Addr адрес пира в DERP relay — его публичный ключ
+type Addr struct {
+	Key string
+}
+
+// Network реализует net.Addr
+func (a *Addr) Network() string { return "derp" }
+
+// String реализует net.Addr
+func (a *Addr) String() string { return a.Key }
+
+// PeerConn net.PacketConn для обмена пакетами с одним пиром через DERP relay.
+// Поверх него может работать quic.Transport так же, как поверх UDP сокета.
+type PeerConn struct {
+	client *Client
+	key    string
+	queue  chan []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	deadlineCh   chan struct{} // закрывается при изменении read deadline
+}
+
+func newPeerConn(client *Client, key string) *PeerConn {
+	return &PeerConn{
+		client:     client,
+		key:        key,
+		queue:      make(chan []byte, peerQueueSize),
+		closed:     make(chan struct{}),
+		deadlineCh: make(chan struct{}),
+	}
+}
+
+// deliver кладет пакет в очередь; false — пакет отброшен
+func (p *PeerConn) deliver(packet []byte) bool {
+	buf := make([]byte, len(packet))
+	copy(buf, packet)
+	select {
+	case <-p.closed:
+		return false
+	case p.queue <- buf:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadFrom читает следующий пакет пира
+func (p *PeerConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		p.mu.Lock()
+		deadline, deadlineCh := p.readDeadline, p.deadlineCh
+		p.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		n, done, err := p.waitPacket(b, timeout, deadlineCh)
+		if timer != nil {
+			timer.Stop()
+		}
+		if done {
+			return n, p.RemoteAddr(), err
+		}
+		// deadline изменился — перечитываем
+	}
+}
+
+// waitPacket ждет пакет, закрытие или deadline; done=false — deadline был изменен
+func (p *PeerConn) waitPacket(b []byte, timeout <-chan time.Time, deadlineCh <-chan struct{}) (int, bool, error) {
+	select {
+	case packet := <-p.queue:
+		return copy(b, packet), true, nil
+	case <-p.closed:
+		return 0, true, net.ErrClosed
+	case <-timeout:
+		return 0, true, os.ErrDeadlineExceeded
+	case <-deadlineCh:
+		return 0, false, nil
+	}
+}
+
+// WriteTo отправляет пакет пиру (адрес игнорируется: соединение привязано к пиру)
+func (p *PeerConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	if err := p.client.send(p.key, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close закрывает соединение пира и удаляет его из клиента
+func (p *PeerConn) Close() error {
+	p.closeLocal()
+	p.client.removePeer(p)
+	return nil
+}
+
+func (p *PeerConn) closeLocal() {
+	p.closeOnce.Do(func() { close(p.closed) })
+}
+
+// LocalAddr возвращает адрес клиента (собственный публичный ключ)
+func (p *PeerConn) LocalAddr() net.Addr { return &Addr{Key: p.client.config.PublicKey} }
+
+// RemoteAddr возвращает адрес пира
+func (p *PeerConn) RemoteAddr() net.Addr { return &Addr{Key: p.key} }
+
+// SetDeadline реализует net.PacketConn; запись не блокируется, поэтому влияет только на чтение
+func (p *PeerConn) SetDeadline(t time.Time) error {
+	return p.SetReadDeadline(t)
+}
+
+// SetReadDeadline задает deadline чтения и будит ожидающий ReadFrom
+func (p *PeerConn) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readDeadline = t
+	close(p.deadlineCh)
+	p.deadlineCh = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline реализует net.PacketConn
+func (p *PeerConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
@@ -0,0 +1,322 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Методы обнаружения relay
+const (
+	MethodSRV       = "srv"        // DNS SRV записи
+	MethodWellKnown = "well_known" // JSON документ по well-known URL
+	MethodAuto      = "auto"       // SRV, при неудаче well-known URL
+)
+
+// Имена SRV сервисов (_<service>._udp.<domain>)
+const (
+	ServiceQUIC   = "cloudbridge-quic"
+	ServiceMASQUE = "cloudbridge-masque"
+	ServiceSTUN   = "stun"
+	ServiceTURN   = "turn"
+)
+
+// WellKnownPath путь well-known документа с адресами relay
+const WellKnownPath = "/.well-known/cloudbridge-relay.json"
+
+// Endpoints адреса сервисов relay (host:port)
+type Endpoints struct {
+	QUIC   string   `json:"quic"`             // QUIC транспорт (P2P relay)
+	STUN   []string `json:"stun,omitempty"`   // STUN серверы
+	TURN   []string `json:"turn,omitempty"`   // TURN серверы
+	MASQUE string   `json:"masque,omitempty"` // MASQUE прокси
+}
+
+// Resolver выполняет SRV запросы (совместим с *net.Resolver)
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Config конфигурация обнаружения
+type Config struct {
+	Method       string        `json:"method"`         // srv, well_known или auto
+	Domain       string        `json:"domain"`         // Домен для SRV и well-known URL
+	WellKnownURL string        `json:"well_known_url"` // Явный URL документа (опционально)
+	Timeout      time.Duration `json:"timeout"`        // Общий таймаут обнаружения
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		Method:  MethodAuto,
+		Timeout: 5 * time.Second,
+	}
+}
+
+// Discoverer обнаруживает адреса relay через DNS SRV или well-known URL
+type Discoverer struct {
+	config     *Config
+	resolver   Resolver
+	httpClient *http.Client
+	logger     Logger
+}
+
+// noopLogger используется, если логгер не передан
+type noopLogger struct{}
+
+func (noopLogger) Info(msg string, fields ...interface{})  {}
+func (noopLogger) Error(msg string, fields ...interface{}) {}
+func (noopLogger) Debug(msg string, fields ...interface{}) {}
+func (noopLogger) Warn(msg string, fields ...interface{})  {}
+
+// NewDiscoverer создает discoverer; nil resolver/httpClient/logger заменяются системными
+func NewDiscoverer(config *Config, resolver Resolver, httpClient *http.Client, logger Logger) *Discoverer {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Method == "" {
+		config.Method = MethodAuto
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig().Timeout
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: config.Timeout}
+	}
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	return &Discoverer{
+		config:     config,
+		resolver:   resolver,
+		httpClient: httpClient,
+		logger:     logger,
+	}
+}
+
+// Discover возвращает адреса relay согласно настроенному методу
+func (d *Discoverer) Discover(ctx context.Context) (*Endpoints, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	switch d.config.Method {
+	case MethodSRV:
+		return d.discoverSRV(ctx)
+	case MethodWellKnown:
+		return d.discoverWellKnown(ctx)
+	case MethodAuto:
+		eps, err := d.discoverSRV(ctx)
+		if err == nil {
+			return eps, nil
+		}
+		d.logger.Debug("SRV discovery failed, trying well-known URL", "domain", d.config.Domain, "error", err)
+		return d.discoverWellKnown(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported discovery method: %s", d.config.Method)
+	}
+}
+
+// discoverSRV читает SRV записи домена; запись QUIC обязательна, остальные опциональны
+func (d *Discoverer) discoverSRV(ctx context.Context) (*Endpoints, error) {
+	if d.config.Domain == "" {
+		return nil, fmt.Errorf("discovery domain is not set")
+	}
+
+	quicAddrs, err := d.lookupSRV(ctx, ServiceQUIC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve QUIC SRV record: %w", err)
+	}
+	if len(quicAddrs) == 0 {
+		return nil, fmt.Errorf("no QUIC SRV records for %s", d.config.Domain)
+	}
+
+	eps := &Endpoints{QUIC: quicAddrs[0]}
+	if addrs, err := d.lookupSRV(ctx, ServiceMASQUE); err == nil && len(addrs) > 0 {
+		eps.MASQUE = addrs[0]
+	}
+	if addrs, err := d.lookupSRV(ctx, ServiceSTUN); err == nil {
+		eps.STUN = addrs
+	}
+	if addrs, err := d.lookupSRV(ctx, ServiceTURN); err == nil {
+		eps.TURN = addrs
+	}
+
+	d.logger.Info("Relay endpoints discovered via DNS SRV",
+		"domain", d.config.Domain,
+		"quic", eps.QUIC,
+		"masque", eps.MASQUE,
+		"stun", eps.STUN,
+		"turn", eps.TURN)
+	return eps, nil
+}
+
+// lookupSRV возвращает host:port целей SRV записи в порядке приоритета
+func (d *Discoverer) lookupSRV(ctx context.Context, service string) ([]string, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, service, "udp", d.config.Domain)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(records))
+	for _, r := range records {
+		target := strings.TrimSuffix(r.Target, ".")
+		if target == "" {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(r.Port))))
+	}
+	return addrs, nil
+}
+
+// discoverWellKnown загружает JSON документ с адресами relay
+func (d *Discoverer) discoverWellKnown(ctx context.Context) (*Endpoints, error) {
+	wellKnownURL := d.config.WellKnownURL
+	if wellKnownURL == "" {
+		if d.config.Domain == "" {
+			return nil, fmt.Errorf("discovery domain is not set")
+		}
+		wellKnownURL = (&url.URL{Scheme: "https", Host: d.config.Domain, Path: WellKnownPath}).String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnownURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", wellKnownURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery document %s returned %s", wellKnownURL, resp.Status)
+	}
+
+	var eps Endpoints
+	if err := json.NewDecoder(resp.Body).Decode(&eps); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+	if _, _, err := net.SplitHostPort(eps.QUIC); err != nil {
+		return nil, fmt.Errorf("discovery document has invalid quic endpoint %q: %w", eps.QUIC, err)
+	}
+
+	d.logger.Info("Relay endpoints discovered via well-known URL",
+		"url", wellKnownURL,
+		"quic", eps.QUIC,
+		"masque", eps.MASQUE,
+		"stun", eps.STUN,
+		"turn", eps.TURN)
+	return &eps, nil
+}
+
+// FromConfig выводит адреса relay из конфигурации: relay.host + relay.ports,
+// а если relay.host не задан — хост и порт из api.base_url
+func FromConfig(cfg *types.Config) *Endpoints {
+	host := cfg.Relay.Host
+	basePort := 0
+	if host == "" {
+		host, basePort = hostPortFromURL(cfg.API.BaseURL)
+	}
+	if host == "" {
+		return &Endpoints{}
+	}
+
+	ports := cfg.Relay.Ports
+	eps := &Endpoints{}
+	if port := firstPositive(ports.QUIC, basePort, cfg.Relay.Port); port > 0 {
+		eps.QUIC = joinHostPort(host, port)
+	}
+	if ports.MASQUE > 0 {
+		eps.MASQUE = joinHostPort(host, ports.MASQUE)
+	}
+	if ports.STUN > 0 {
+		eps.STUN = []string{joinHostPort(host, ports.STUN)}
+	}
+	if ports.TURN > 0 {
+		eps.TURN = []string{joinHostPort(host, ports.TURN)}
+	}
+	return eps
+}
+
+// ApplyToConfig переносит обнаруженные адреса в конфигурацию: хост и порты relay,
+// STUN/TURN серверы добавляются в начало списков ICE
+func ApplyToConfig(cfg *types.Config, eps *Endpoints) {
+	if host, port, ok := splitHostPort(eps.QUIC); ok {
+		cfg.Relay.Host = host
+		cfg.Relay.Ports.QUIC = port
+	}
+	if _, port, ok := splitHostPort(eps.MASQUE); ok {
+		cfg.Relay.Ports.MASQUE = port
+	}
+	cfg.ICE.STUNServers = prependUnique(eps.STUN, cfg.ICE.STUNServers)
+	cfg.ICE.TURNServers = prependUnique(eps.TURN, cfg.ICE.TURNServers)
+}
+
+// hostPortFromURL извлекает хост и порт из URL (порт 0, если не указан)
+func hostPortFromURL(raw string) (string, int) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return "", 0
+	}
+	port, _ := strconv.Atoi(u.Port()) //nolint:errcheck // пустой порт -> 0
+	return u.Hostname(), port
+}
+
+func splitHostPort(addr string) (string, int, bool) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, false
+	}
+	return host, port, true
+}
+
+func joinHostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// prependUnique возвращает first + rest без дубликатов
+func prependUnique(first, rest []string) []string {
+	if len(first) == 0 {
+		return rest
+	}
+	seen := make(map[string]bool, len(first)+len(rest))
+	result := make([]string, 0, len(first)+len(rest))
+	for _, s := range append(append([]string{}, first...), rest...) {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
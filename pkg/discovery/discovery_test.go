@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+)
+
+// fakeResolver возвращает заранее заданные SRV записи по имени сервиса
+type fakeResolver struct {
+	records map[string][]*net.SRV
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	records, ok := r.records[service]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return "", records, nil
+}
+
+func TestDiscover_SRV(t *testing.T) {
+	resolver := &fakeResolver{records: map[string][]*net.SRV{
+		ServiceQUIC:   {{Target: "relay1.example.com.", Port: 5553}},
+		ServiceMASQUE: {{Target: "relay1.example.com.", Port: 8443}},
+		ServiceSTUN:   {{Target: "stun1.example.com.", Port: 3478}, {Target: "stun2.example.com.", Port: 3478}},
+	}}
+	d := NewDiscoverer(&Config{Method: MethodSRV, Domain: "example.com"}, resolver, nil, nil)
+
+	eps, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if eps.QUIC != "relay1.example.com:5553" {
+		t.Errorf("Expected QUIC relay1.example.com:5553, got %s", eps.QUIC)
+	}
+	if eps.MASQUE != "relay1.example.com:8443" {
+		t.Errorf("Expected MASQUE relay1.example.com:8443, got %s", eps.MASQUE)
+	}
+	if len(eps.STUN) != 2 || eps.STUN[0] != "stun1.example.com:3478" {
+		t.Errorf("Unexpected STUN servers: %v", eps.STUN)
+	}
+	if len(eps.TURN) != 0 {
+		t.Errorf("Expected no TURN servers, got %v", eps.TURN)
+	}
+}
+
+func TestDiscover_AutoFallsBackToWellKnown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != WellKnownPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"quic":"relay.example.com:5553","stun":["relay.example.com:3478"],"turn":["relay.example.com:3479"]}`)) //nolint:errcheck // test server
+	}))
+	defer server.Close()
+
+	d := NewDiscoverer(&Config{
+		Method:       MethodAuto,
+		Domain:       "example.com",
+		WellKnownURL: server.URL + WellKnownPath,
+	}, &fakeResolver{}, server.Client(), nil)
+
+	eps, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if eps.QUIC != "relay.example.com:5553" {
+		t.Errorf("Expected QUIC relay.example.com:5553, got %s", eps.QUIC)
+	}
+	if len(eps.TURN) != 1 || eps.TURN[0] != "relay.example.com:3479" {
+		t.Errorf("Unexpected TURN servers: %v", eps.TURN)
+	}
+}
+
+func TestDiscover_WellKnownRejectsInvalidQUIC(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"quic":"relay.example.com"}`)) //nolint:errcheck // test server
+	}))
+	defer server.Close()
+
+	d := NewDiscoverer(&Config{Method: MethodWellKnown, WellKnownURL: server.URL}, nil, server.Client(), nil)
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Error("Expected error for QUIC endpoint without port")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := &types.Config{}
+	cfg.Relay.Host = "relay.example.com"
+	cfg.Relay.Port = 8080
+	cfg.Relay.Ports.QUIC = 5553
+	cfg.Relay.Ports.STUN = 3478
+
+	eps := FromConfig(cfg)
+	if eps.QUIC != "relay.example.com:5553" {
+		t.Errorf("Expected QUIC from relay.ports.quic, got %s", eps.QUIC)
+	}
+	if len(eps.STUN) != 1 || eps.STUN[0] != "relay.example.com:3478" {
+		t.Errorf("Unexpected STUN servers: %v", eps.STUN)
+	}
+
+	// Без relay.host адрес берется из api.base_url
+	cfg = &types.Config{}
+	cfg.API.BaseURL = "https://api.example.com:9443"
+	if eps := FromConfig(cfg); eps.QUIC != "api.example.com:9443" {
+		t.Errorf("Expected QUIC from api.base_url, got %s", eps.QUIC)
+	}
+}
+
+func TestApplyToConfig(t *testing.T) {
+	cfg := &types.Config{}
+	cfg.ICE.STUNServers = []string{"stun.l.google.com:19302", "relay.example.com:3478"}
+
+	ApplyToConfig(cfg, &Endpoints{
+		QUIC:   "relay.example.com:5553",
+		MASQUE: "relay.example.com:8443",
+		STUN:   []string{"relay.example.com:3478"},
+	})
+
+	if cfg.Relay.Host != "relay.example.com" || cfg.Relay.Ports.QUIC != 5553 || cfg.Relay.Ports.MASQUE != 8443 {
+		t.Errorf("Unexpected relay config: host=%s quic=%d masque=%d",
+			cfg.Relay.Host, cfg.Relay.Ports.QUIC, cfg.Relay.Ports.MASQUE)
+	}
+	if len(cfg.ICE.STUNServers) != 2 || cfg.ICE.STUNServers[0] != "relay.example.com:3478" {
+		t.Errorf("Expected discovered STUN server first without duplicates, got %v", cfg.ICE.STUNServers)
+	}
+}
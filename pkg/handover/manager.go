@@ -49,6 +49,7 @@ type tokenPayload struct {
 // HandoverManager управляет seamless handover
 type HandoverManager struct {
 	token       *ContinuationToken
+	issued      string        // Сериализованный токен, выданный relay сервером
 	penalty     time.Duration // Penalty/cooldown
 	lastSwitch  time.Time     // Время последнего переключения
 	secretKey   []byte        // Секретный ключ для подписи
@@ -276,6 +277,49 @@ func (hm *HandoverManager) SetToken(token *ContinuationToken) error {
 	return nil
 }
 
+// StoreIssuedToken сохраняет continuation token, выданный relay сервером при аутентификации.
+// Подпись проверяет сервер (клиент не знает его ключ), поэтому локально проверяется только срок действия.
+func (hm *HandoverManager) StoreIssuedToken(tokenStr string) (*ContinuationToken, error) {
+	token, err := hm.DeserializeToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	hm.mu.Lock()
+	hm.token = token
+	hm.issued = tokenStr
+	hm.mu.Unlock()
+
+	hm.logger.Debug("Stored relay continuation token",
+		"conn_id", token.ConnID,
+		"server_id", token.ServerID,
+		"expires_at", token.ExpiresAt)
+
+	return token, nil
+}
+
+// ResumeToken возвращает выданный relay токен для возобновления сессии, если он еще действителен
+func (hm *HandoverManager) ResumeToken() (string, bool) {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	if hm.issued == "" || hm.token == nil || time.Now().After(hm.token.ExpiresAt) {
+		return "", false
+	}
+	return hm.issued, true
+}
+
+// ClearToken сбрасывает текущий токен (например, после отказа relay возобновить сессию)
+func (hm *HandoverManager) ClearToken() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.token = nil
+	hm.issued = ""
+}
+
 // GetMetrics возвращает метрики handover
 func (hm *HandoverManager) GetMetrics() map[string]interface{} {
 	hm.mu.RLock()
@@ -1,6 +1,7 @@
 package heartbeat
 
 import (
+	"errors"
 	"fmt"
 	"math/rand/v2"
 	"sync"
@@ -23,9 +24,9 @@ type Manager struct {
 	interval time.Duration
 
 	// runtime
-	ticker   *time.Ticker
-	stopChan chan struct{}
-	running  bool
+	stopChan   chan struct{}
+	intervalCh chan struct{} // смена интервала: тикер принадлежит heartbeatLoop
+	running    bool
 
 	// state
 	mu        sync.RWMutex
@@ -38,10 +39,26 @@ type Manager struct {
 	maxBackoff   time.Duration
 	jitterFrac   float64
 
+	// streaming
+	stream            interfaces.HeartbeatStream
+	streamErrCh       chan streamError
+	streamUnsupported bool
+	serverMetrics     *interfaces.ServerMetrics
+	handlers          []NotificationHandler
+
 	// logging
 	logger Logger
 }
 
+// NotificationHandler обрабатывает уведомления сервера из heartbeat stream
+type NotificationHandler func(n interfaces.ServerNotification)
+
+// streamError сообщает об обрыве конкретного heartbeat stream
+type streamError struct {
+	stream interfaces.HeartbeatStream
+	err    error
+}
+
 // NewManager creates a new heartbeat manager
 func NewManager(client interfaces.ClientInterface) *Manager {
 	base := 30 * time.Second
@@ -52,6 +69,8 @@ func NewManager(client interfaces.ClientInterface) *Manager {
 		maxBackoff:   2 * time.Minute,
 		jitterFrac:   0.15, // ±15% джиттер
 		maxFails:     3,
+		intervalCh:   make(chan struct{}, 1),
+		streamErrCh:  make(chan streamError, 1),
 	}
 }
 
@@ -105,17 +124,18 @@ func (m *Manager) Start() error {
 		return fmt.Errorf("heartbeat manager is already running")
 	}
 
-	// (re)create control chan & ticker
+	// (re)create control chan
 	m.stopChan = make(chan struct{})
-	m.ticker = time.NewTicker(m.interval)
 	m.failCount = 0
 	m.running = true
 
+	m.streamUnsupported = false
+
 	// стартуем, даже если клиент не подключен — будем ретраить с backoff
-	go m.heartbeatLoop()
+	go m.heartbeatLoop(m.stopChan, m.interval)
 
 	// подписываемся на события состояния клиента для авто-рестарта
-	go m.stateEventLoop()
+	go m.stateEventLoop(m.stopChan)
 
 	m.logDebug("heartbeat started", "interval", m.interval.String())
 	return nil
@@ -131,15 +151,12 @@ func (m *Manager) Stop() {
 	}
 	m.running = false
 
-	// закрываем тикер и сигналим горутине
-	if m.ticker != nil {
-		m.ticker.Stop()
-		m.ticker = nil
-	}
+	// сигналим горутине, она остановит тикер
 	if m.stopChan != nil {
 		close(m.stopChan)
 		m.stopChan = nil
 	}
+	m.closeStreamLocked()
 
 	m.logDebug("heartbeat stopped")
 }
@@ -153,16 +170,32 @@ func (m *Manager) SetInterval(interval time.Duration) {
 	defer m.mu.Unlock()
 
 	m.baseInterval = interval
-	m.interval = interval
-	if m.ticker != nil {
-		// безопасно пересоздать тикер, чтобы исключить гонки Reset
-		m.ticker.Stop()
-		m.ticker = time.NewTicker(m.interval)
-	}
+	m.setIntervalLocked(interval)
 
 	m.logDebug("heartbeat interval set", "interval", m.interval.String())
 }
 
+// OnNotification registers a handler for server notifications received on the heartbeat stream
+func (m *Manager) OnNotification(handler NotificationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, handler)
+}
+
+// GetServerMetrics returns the latest relay metrics reported in heartbeat responses
+func (m *Manager) GetServerMetrics() *interfaces.ServerMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.serverMetrics
+}
+
+// IsStreaming returns true while heartbeats are sent over an open stream
+func (m *Manager) IsStreaming() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stream != nil
+}
+
 // GetInterval returns the current heartbeat interval
 func (m *Manager) GetInterval() time.Duration {
 	m.mu.RLock()
@@ -215,10 +248,16 @@ func (m *Manager) GetStats() map[string]interface{} {
 		"max_fails":   m.maxFails,
 		"base_intvl":  m.baseInterval.String(),
 		"max_backoff": m.maxBackoff.String(),
+		"streaming":   m.stream != nil,
 	}
 	if !m.lastBeat.IsZero() {
 		stats["time_since_last_beat"] = time.Since(m.lastBeat).String()
 	}
+	if m.serverMetrics != nil {
+		stats["server_connected_clients"] = m.serverMetrics.ConnectedClients
+		stats["server_active_tunnels"] = m.serverMetrics.ActiveTunnels
+		stats["server_load"] = m.serverMetrics.ServerLoad
+	}
 	return stats
 }
 
@@ -240,17 +279,181 @@ func (m *Manager) SetMaxFails(maxFails int) {
 
 // === internal ===
 
-func (m *Manager) heartbeatLoop() {
+// heartbeatLoop отправляет heartbeat по тикеру. Тикер принадлежит циклу: интервал,
+// измененный из других горутин (ответ сервера, события клиента), применяется здесь
+// через intervalCh.
+func (m *Manager) heartbeatLoop(stopChan chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-m.stopChan:
+		case <-stopChan:
 			return
-		case <-m.ticker.C:
-			if err := m.sendHeartbeat(); err != nil {
+		case <-m.intervalCh:
+			if next := m.GetInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		case <-ticker.C:
+			if err := m.beat(); err != nil {
 				m.handleHeartbeatFailure(err)
-			} else {
+			} else if !m.IsStreaming() {
+				// в режиме stream успех фиксируется по ответу сервера
 				m.handleHeartbeatSuccess()
 			}
+		case se := <-m.streamErrCh:
+			m.handleStreamError(se)
+		}
+	}
+}
+
+// beat отправляет heartbeat через stream, при необходимости (пере)открывая его.
+// Если stream не поддерживается клиентом или сервером — unary heartbeat.
+func (m *Manager) beat() error {
+	if !m.client.IsConnected() {
+		return fmt.Errorf("client is not connected")
+	}
+
+	stream, err := m.ensureStream()
+	if err != nil {
+		if !errors.Is(err, interfaces.ErrHeartbeatStreamUnsupported) {
+			m.logDebug("heartbeat stream unavailable, using unary heartbeat", "error", err)
+		}
+		return m.sendHeartbeat()
+	}
+
+	if err := stream.Send(); err != nil {
+		m.dropStream(stream)
+		return err
+	}
+	return nil
+}
+
+// ensureStream возвращает открытый stream или открывает новый
+func (m *Manager) ensureStream() (interfaces.HeartbeatStream, error) {
+	streamer, ok := m.client.(interfaces.HeartbeatStreamer)
+	if !ok {
+		return nil, interfaces.ErrHeartbeatStreamUnsupported
+	}
+
+	m.mu.RLock()
+	stream, unsupported := m.stream, m.streamUnsupported
+	m.mu.RUnlock()
+	if stream != nil {
+		return stream, nil
+	}
+	if unsupported {
+		return nil, interfaces.ErrHeartbeatStreamUnsupported
+	}
+
+	stream, err := streamer.OpenHeartbeatStream()
+	if err != nil {
+		if errors.Is(err, interfaces.ErrHeartbeatStreamUnsupported) {
+			m.markStreamUnsupported()
+		}
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.stream = stream
+	m.mu.Unlock()
+
+	go m.recvLoop(stream)
+	m.logDebug("heartbeat stream established")
+	return stream, nil
+}
+
+// recvLoop читает ответы и уведомления сервера до обрыва stream
+func (m *Manager) recvLoop(stream interfaces.HeartbeatStream) {
+	for {
+		ack, err := stream.Recv()
+		if err != nil {
+			select {
+			case m.streamErrCh <- streamError{stream: stream, err: err}:
+			default:
+				// цикл heartbeat остановлен или уже обрабатывает обрыв
+				m.dropStream(stream)
+			}
+			return
+		}
+		m.handleAck(ack)
+	}
+}
+
+// handleStreamError закрывает оборванный stream; новый откроется на следующем тике с учетом backoff
+func (m *Manager) handleStreamError(se streamError) {
+	m.mu.RLock()
+	current := m.stream == se.stream
+	m.mu.RUnlock()
+	if !current {
+		return
+	}
+
+	m.dropStream(se.stream)
+	if errors.Is(se.err, interfaces.ErrHeartbeatStreamUnsupported) {
+		m.markStreamUnsupported()
+		return
+	}
+	m.handleHeartbeatFailure(se.err)
+}
+
+// dropStream закрывает stream, если он все еще текущий
+func (m *Manager) dropStream(stream interfaces.HeartbeatStream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stream == stream {
+		m.closeStreamLocked()
+	}
+}
+
+func (m *Manager) closeStreamLocked() {
+	if m.stream == nil {
+		return
+	}
+	if err := m.stream.Close(); err != nil {
+		m.logDebug("failed to close heartbeat stream", "error", err)
+	}
+	m.stream = nil
+}
+
+func (m *Manager) markStreamUnsupported() {
+	m.mu.Lock()
+	m.streamUnsupported = true
+	m.mu.Unlock()
+	m.logDebug("heartbeat stream not supported by relay, falling back to unary heartbeats")
+}
+
+// handleAck применяет ответ сервера: интервал, метрики сервера и уведомления
+func (m *Manager) handleAck(ack *interfaces.HeartbeatAck) {
+	if ack.Status != "" && ack.Status != "ok" {
+		m.handleHeartbeatFailure(fmt.Errorf("heartbeat rejected: %s", ack.ErrorMessage))
+	} else if ack.Status == "ok" {
+		m.mu.Lock()
+		if ack.Interval > 0 && ack.Interval != m.baseInterval {
+			// интервал задается сервером
+			m.baseInterval = ack.Interval
+			m.logDebug("heartbeat interval changed by server", "interval", ack.Interval.String())
+		}
+		if ack.ServerMetrics != nil {
+			m.serverMetrics = ack.ServerMetrics
+		}
+		m.mu.Unlock()
+		m.handleHeartbeatSuccess()
+	}
+
+	if len(ack.Notifications) == 0 {
+		return
+	}
+	m.mu.RLock()
+	handlers := make([]NotificationHandler, len(m.handlers))
+	copy(handlers, m.handlers)
+	m.mu.RUnlock()
+
+	for _, n := range ack.Notifications {
+		m.logDebug("server notification received", "type", n.Type, "message", n.Message)
+		for _, h := range handlers {
+			h(n)
 		}
 	}
 }
@@ -262,6 +465,20 @@ func (m *Manager) sendHeartbeat() error {
 	return m.client.SendHeartbeat()
 }
 
+// setIntervalLocked меняет текущий интервал и будит heartbeatLoop, который переставит
+// тикер. Вызывается под m.mu.
+func (m *Manager) setIntervalLocked(interval time.Duration) {
+	if interval == m.interval {
+		return
+	}
+	m.interval = interval
+	select {
+	case m.intervalCh <- struct{}{}:
+	default:
+		// цикл еще не забрал предыдущий сигнал: прочитает актуальный интервал
+	}
+}
+
 func (m *Manager) handleHeartbeatSuccess() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -270,13 +487,7 @@ func (m *Manager) handleHeartbeatSuccess() {
 	m.failCount = 0
 
 	// вернуть интервал к базовому при успехе
-	if m.interval != m.baseInterval {
-		m.interval = m.baseInterval
-		if m.ticker != nil {
-			m.ticker.Stop()
-			m.ticker = time.NewTicker(m.interval)
-		}
-	}
+	m.setIntervalLocked(m.baseInterval)
 
 	m.logDebug("heartbeat OK", "at", m.lastBeat.Format(time.RFC3339), "interval", m.interval.String())
 }
@@ -285,31 +496,28 @@ func (m *Manager) handleHeartbeatFailure(err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if !m.running {
+		return
+	}
+
 	m.failCount++
 	m.logWarn("heartbeat failed", "attempt", m.failCount, "max", m.maxFails, "error", err)
 
 	// backoff: экспонента по failCount с верхней границей + джиттер
 	next := m.backoffIntervalLocked()
 	if next != m.interval {
-		m.interval = next
-		if m.ticker != nil {
-			m.ticker.Stop()
-			m.ticker = time.NewTicker(m.interval)
-		}
+		m.setIntervalLocked(next)
 		m.logDebug("heartbeat backoff applied", "next_interval", m.interval.String())
 	}
 
 	if m.failCount >= m.maxFails {
 		m.logWarn("too many heartbeat failures, stopping")
 		m.running = false
-		if m.ticker != nil {
-			m.ticker.Stop()
-			m.ticker = nil
-		}
 		if m.stopChan != nil {
 			close(m.stopChan)
 			m.stopChan = nil
 		}
+		m.closeStreamLocked()
 	}
 }
 
@@ -338,12 +546,12 @@ func (m *Manager) backoffIntervalLocked() time.Duration {
 }
 
 // stateEventLoop listens for client state changes and handles auto-restart
-func (m *Manager) stateEventLoop() {
+func (m *Manager) stateEventLoop(stopChan chan struct{}) {
 	stateChan := m.client.SubscribeState()
 
 	for {
 		select {
-		case <-m.stopChan:
+		case <-stopChan:
 			return
 		case event, ok := <-stateChan:
 			if !ok {
@@ -366,15 +574,11 @@ func (m *Manager) handleStateEvent(event interfaces.ClientStateEvent) {
 	switch event.State {
 	case "connected":
 		// клиент подключился - сбрасываем счетчик сбоев и возвращаем базовый интервал
+		// stream старого соединения больше не действителен
 		m.mu.Lock()
 		m.failCount = 0
-		if m.interval != m.baseInterval {
-			m.interval = m.baseInterval
-			if m.ticker != nil {
-				m.ticker.Stop()
-				m.ticker = time.NewTicker(m.interval)
-			}
-		}
+		m.closeStreamLocked()
+		m.setIntervalLocked(m.baseInterval)
 		m.mu.Unlock()
 		m.logDebug("heartbeat reset to base interval after reconnection")
 
@@ -382,14 +586,8 @@ func (m *Manager) handleStateEvent(event interfaces.ClientStateEvent) {
 		// клиент отключился - увеличиваем backoff
 		m.mu.Lock()
 		m.failCount++
-		next := m.backoffIntervalLocked()
-		if next != m.interval {
-			m.interval = next
-			if m.ticker != nil {
-				m.ticker.Stop()
-				m.ticker = time.NewTicker(m.interval)
-			}
-		}
+		m.closeStreamLocked()
+		m.setIntervalLocked(m.backoffIntervalLocked())
 		m.mu.Unlock()
 		m.logDebug("heartbeat backoff applied due to disconnection", "next_interval", m.interval.String())
 	}
@@ -0,0 +1,165 @@
+package heartbeat
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/interfaces"
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+)
+
+// fakeStream отвечает на каждый heartbeat ответом ack; failFirst обрывает stream
+// вместо первого ответа
+type fakeStream struct {
+	ack       interfaces.HeartbeatAck
+	failFirst bool
+	replies   chan *interfaces.HeartbeatAck
+	closed    chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+	sends     int
+}
+
+func newFakeStream(ack interfaces.HeartbeatAck, failFirst bool) *fakeStream {
+	return &fakeStream{
+		ack:       ack,
+		failFirst: failFirst,
+		replies:   make(chan *interfaces.HeartbeatAck, 16),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (s *fakeStream) Send() error {
+	s.mu.Lock()
+	s.sends++
+	s.mu.Unlock()
+	ack := s.ack
+	select {
+	case s.replies <- &ack:
+	default:
+	}
+	return nil
+}
+
+func (s *fakeStream) Recv() (*interfaces.HeartbeatAck, error) {
+	select {
+	case <-s.closed:
+		return nil, errors.New("stream closed")
+	case ack := <-s.replies:
+		if s.failFirst {
+			return nil, errors.New("connection reset")
+		}
+		return ack, nil
+	}
+}
+
+func (s *fakeStream) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func (s *fakeStream) Sends() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sends
+}
+
+// fakeClient подключенный клиент, открывающий stream из списка streams
+type fakeClient struct {
+	streams []*fakeStream
+	state   chan interfaces.ClientStateEvent
+	mu      sync.Mutex
+	opened  int
+}
+
+func newFakeClient(streams ...*fakeStream) *fakeClient {
+	return &fakeClient{streams: streams, state: make(chan interfaces.ClientStateEvent)}
+}
+
+func (c *fakeClient) OpenHeartbeatStream() (interfaces.HeartbeatStream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opened >= len(c.streams) {
+		return nil, errors.New("no more streams")
+	}
+	stream := c.streams[c.opened]
+	c.opened++
+	return stream, nil
+}
+
+func (c *fakeClient) Opened() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opened
+}
+
+func (c *fakeClient) IsConnected() bool                                  { return true }
+func (c *fakeClient) Start() error                                       { return nil }
+func (c *fakeClient) Stop() error                                        { return nil }
+func (c *fakeClient) Reconnect() error                                   { return nil }
+func (c *fakeClient) SendHeartbeat() error                               { return nil }
+func (c *fakeClient) LastHeartbeat() time.Time                           { return time.Time{} }
+func (c *fakeClient) GetClientID() string                                { return "client" }
+func (c *fakeClient) GetTenantID() string                                { return "tenant" }
+func (c *fakeClient) GetConfig() *types.Config                           { return &types.Config{} }
+func (c *fakeClient) SubscribeState() <-chan interfaces.ClientStateEvent { return c.state }
+
+// waitFor ждет выполнения условия до timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManager_ServerIntervalChange(t *testing.T) {
+	stream := newFakeStream(interfaces.HeartbeatAck{Status: "ok", Interval: 20 * time.Millisecond}, false)
+	m := NewManager(newFakeClient(stream))
+	m.SetInterval(50 * time.Millisecond)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop()
+
+	waitFor(t, time.Second, "server interval", func() bool { return m.GetInterval() == 20*time.Millisecond })
+	before := stream.Sends()
+	time.Sleep(300 * time.Millisecond)
+	// около 15 heartbeat за 300ms; остановившийся тикер дает 0-1
+	if sent := stream.Sends() - before; sent < 5 {
+		t.Errorf("heartbeats after interval change = %d, want at least 5", sent)
+	}
+}
+
+func TestManager_StreamReestablished(t *testing.T) {
+	broken := newFakeStream(interfaces.HeartbeatAck{Status: "ok"}, true)
+	stream := newFakeStream(interfaces.HeartbeatAck{Status: "ok"}, false)
+	client := newFakeClient(broken, stream)
+	m := NewManager(client)
+	m.SetInterval(20 * time.Millisecond)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop()
+
+	// обрыв stream дает backoff (не меньше секунды), затем stream открывается заново
+	waitFor(t, 3*time.Second, "new stream", func() bool { return client.Opened() == 2 })
+	waitFor(t, time.Second, "base interval", func() bool { return m.GetInterval() == 20*time.Millisecond })
+	if !m.IsRunning() || m.GetFailCount() != 0 {
+		t.Fatalf("running = %v, fail count = %d after recovery", m.IsRunning(), m.GetFailCount())
+	}
+
+	before := stream.Sends()
+	time.Sleep(300 * time.Millisecond)
+	if sent := stream.Sends() - before; sent < 5 {
+		t.Errorf("heartbeats after stream recovery = %d, want at least 5", sent)
+	}
+	if !m.IsStreaming() {
+		t.Error("manager not streaming after recovery")
+	}
+}
@@ -1,10 +1,12 @@
 package ice
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/ice/v2"
@@ -16,11 +18,31 @@ type ICEAgent struct {
 	agent       *ice.Agent
 	stunServers []string
 	turnServers []string
+	turnUser    string // TURN username (static or short-lived REST API credentials)
+	turnPass    string
 	config      *ice.AgentConfig
+	udpMux      ice.UniversalUDPMux // общий UDP сокет (nil — агент открывает свои сокеты)
+	localUfrag  string
+	localPwd    string
+	state       atomic.Int32 // ice.ConnectionState; обновляется из callback без a.mu
 	mu          sync.RWMutex
 	logger      Logger
+
+	// Обработчики событий агента; защищены отдельной блокировкой, так как
+	// callback'и pion вызываются конкурентно с методами под a.mu
+	handlerMu        sync.Mutex
+	candidateHandler CandidateHandler
+	stateHandler     StateHandler
+	gatherDone       chan struct{}
 }
 
+// CandidateHandler receives local candidates as they are gathered (trickle ICE).
+// A nil candidate signals end of candidates.
+type CandidateHandler func(candidate ice.Candidate)
+
+// StateHandler receives ICE connection state changes
+type StateHandler func(state ice.ConnectionState)
+
 // Logger interface for ICE agent logging
 type Logger interface {
 	Info(msg string, fields ...interface{})
@@ -31,11 +53,37 @@ type Logger interface {
 
 // NewICEAgent creates a new ICE agent
 func NewICEAgent(stunServers, turnServers []string, logger Logger) *ICEAgent {
-	return &ICEAgent{
+	a := &ICEAgent{
 		stunServers: stunServers,
 		turnServers: turnServers,
 		logger:      logger,
 	}
+	a.state.Store(int32(ice.ConnectionStateNew))
+	return a
+}
+
+// SetLocalCredentials sets the local ufrag/pwd used by the agent; must be called before Start
+func (a *ICEAgent) SetLocalCredentials(ufrag, pwd string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.localUfrag = ufrag
+	a.localPwd = pwd
+}
+
+// SetTURNCredentials sets the credentials used for all TURN servers; must be called before Start
+func (a *ICEAgent) SetTURNCredentials(username, password string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.turnUser = username
+	a.turnPass = password
+}
+
+// SetUDPMux makes the agent gather host and server-reflexive candidates on a shared
+// UDP socket instead of ephemeral ones; must be called before Start
+func (a *ICEAgent) SetUDPMux(mux ice.UniversalUDPMux) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.udpMux = mux
 }
 
 // Start initializes and starts the ICE agent
@@ -45,23 +93,45 @@ func (a *ICEAgent) Start() error {
 
 	a.logger.Info("Starting ICE agent", "stun_servers", a.stunServers, "turn_servers", a.turnServers)
 
-	urls := make([]*stun.URI, len(a.stunServers))
-	for i, s := range a.stunServers {
-		// дополняем префикс если его нет
-		if !strings.HasPrefix(s, "stun:") && !strings.HasPrefix(s, "stuns:") {
-			s = "stun:" + s
+	urls := make([]*stun.URI, 0, len(a.stunServers)+len(a.turnServers))
+	for _, s := range a.stunServers {
+		u, err := parseSTUNURI(s)
+		if err != nil {
+			return err
 		}
-		u, err := stun.ParseURI(s)
+		urls = append(urls, u)
+	}
+
+	// TURN серверы дают relay кандидаты для пиров за симметричным NAT
+	turnServers := a.turnServers
+	if len(turnServers) > 0 && (a.turnUser == "" || a.turnPass == "") {
+		a.logger.Warn("TURN servers configured without credentials, relay candidates disabled", "turn_servers", turnServers)
+		turnServers = nil
+	}
+	for _, s := range turnServers {
+		u, err := parseTURNURI(s, a.turnUser, a.turnPass)
 		if err != nil {
-			return fmt.Errorf("failed to parse STUN URI %q: %w", s, err)
+			return err
 		}
-		urls[i] = u
+		urls = append(urls, u)
 	}
 
 	a.config = &ice.AgentConfig{
 		NetworkTypes: []ice.NetworkType{ice.NetworkTypeUDP4, ice.NetworkTypeUDP6},
-		Urls:         urls,
-		// Либо тут, либо позже через `agent.Set*`
+		CandidateTypes: []ice.CandidateType{
+			ice.CandidateTypeHost,
+			ice.CandidateTypeServerReflexive,
+			ice.CandidateTypeRelay,
+		},
+		Urls:       urls,
+		LocalUfrag: a.localUfrag,
+		LocalPwd:   a.localPwd,
+	}
+	if a.udpMux != nil {
+		// Общий сокет слушает только IPv4; TURN кандидаты по-прежнему используют свои сокеты
+		a.config.NetworkTypes = []ice.NetworkType{ice.NetworkTypeUDP4}
+		a.config.UDPMux = a.udpMux
+		a.config.UDPMuxSrflx = a.udpMux
 	}
 
 	// Create ICE agent
@@ -72,6 +142,11 @@ func (a *ICEAgent) Start() error {
 
 	a.agent = agent
 
+	// Если credentials не заданы, агент генерирует их сам
+	if ufrag, pwd, err := agent.GetLocalUserCredentials(); err == nil {
+		a.localUfrag, a.localPwd = ufrag, pwd
+	}
+
 	// Set up event handlers
 	a.setupEventHandlers()
 
@@ -100,7 +175,37 @@ func (a *ICEAgent) Stop() error {
 	return nil
 }
 
-// GatherCandidates starts candidate gathering
+// OnLocalCandidate sets the handler for trickled local candidates
+func (a *ICEAgent) OnLocalCandidate(handler CandidateHandler) {
+	a.handlerMu.Lock()
+	defer a.handlerMu.Unlock()
+	a.candidateHandler = handler
+}
+
+// OnConnectionStateChange sets the handler for ICE connection state changes
+func (a *ICEAgent) OnConnectionStateChange(handler StateHandler) {
+	a.handlerMu.Lock()
+	defer a.handlerMu.Unlock()
+	a.stateHandler = handler
+}
+
+// StartGathering starts candidate gathering without waiting for it to complete.
+// Candidates are delivered to the OnLocalCandidate handler, followed by nil.
+func (a *ICEAgent) StartGathering() error {
+	a.mu.RLock()
+	ag := a.agent
+	a.mu.RUnlock()
+	if ag == nil {
+		return fmt.Errorf("ICE agent not started")
+	}
+
+	if err := ag.GatherCandidates(); err != nil {
+		return fmt.Errorf("failed to gather candidates: %w", err)
+	}
+	return nil
+}
+
+// GatherCandidates gathers candidates and blocks until gathering completes
 func (a *ICEAgent) GatherCandidates() ([]ice.Candidate, error) {
 	a.mu.RLock()
 	ag := a.agent
@@ -109,19 +214,14 @@ func (a *ICEAgent) GatherCandidates() ([]ice.Candidate, error) {
 		return nil, fmt.Errorf("ICE agent not started")
 	}
 
+	// завершение сбора сигнализируется nil кандидатом в OnCandidate
 	done := make(chan struct{})
-	// ловим завершение сбора через OnCandidate с nil
-	if err := ag.OnCandidate(func(c ice.Candidate) {
-		if c == nil {
-			// nil candidate означает завершение сбора
-			close(done)
-		}
-	}); err != nil {
-		return nil, fmt.Errorf("failed to set candidate handler: %w", err)
-	}
+	a.handlerMu.Lock()
+	a.gatherDone = done
+	a.handlerMu.Unlock()
 
-	if err := ag.GatherCandidates(); err != nil {
-		return nil, fmt.Errorf("failed to gather candidates: %w", err)
+	if err := a.StartGathering(); err != nil {
+		return nil, err
 	}
 
 	select {
@@ -140,6 +240,45 @@ func (a *ICEAgent) GatherCandidates() ([]ice.Candidate, error) {
 	return cands, nil
 }
 
+// Restart performs an ICE restart with newly generated local credentials.
+// Remote credentials are cleared; gathering must be started again with
+// StartGathering and the new remote credentials set with StartConnectivityChecks.
+// An established ice.Conn stays valid and switches to the new candidate pair.
+func (a *ICEAgent) Restart() (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.agent == nil {
+		return "", "", fmt.Errorf("ICE agent not started")
+	}
+
+	if err := a.agent.Restart("", ""); err != nil {
+		return "", "", fmt.Errorf("failed to restart ICE agent: %w", err)
+	}
+
+	ufrag, pwd, err := a.agent.GetLocalUserCredentials()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get local ICE credentials: %w", err)
+	}
+	a.localUfrag = ufrag
+	a.localPwd = pwd
+	// pion сообщает о переходе в checking асинхронно; фиксируем состояние сразу,
+	// чтобы не читать устаревшее connected
+	if ice.ConnectionState(a.state.Load()) != ice.ConnectionStateNew {
+		a.state.Store(int32(ice.ConnectionStateChecking))
+	}
+
+	a.logger.Info("ICE agent restarted", "local_ufrag", ufrag)
+	return ufrag, pwd, nil
+}
+
+// GetLocalCredentials returns the local ufrag/pwd of the current ICE generation
+func (a *ICEAgent) GetLocalCredentials() (string, string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.localUfrag, a.localPwd
+}
+
 // AddRemoteCandidateFromSDP adds a remote candidate from SDP string
 func (a *ICEAgent) AddRemoteCandidateFromSDP(sdpCand string) error {
 	a.mu.RLock()
@@ -168,7 +307,7 @@ func (a *ICEAgent) AddRemoteCandidate(candidate ice.Candidate) error {
 	return ag.AddRemoteCandidate(candidate)
 }
 
-// StartConnectivityChecks starts ICE connectivity checks (skeleton through remote creds)
+// StartConnectivityChecks sets remote credentials ahead of Connect
 func (a *ICEAgent) StartConnectivityChecks(remoteUfrag, remotePwd string) error {
 	a.mu.RLock()
 	ag := a.agent
@@ -177,28 +316,39 @@ func (a *ICEAgent) StartConnectivityChecks(remoteUfrag, remotePwd string) error
 		return fmt.Errorf("ICE agent not started")
 	}
 
-	// В v2 это три шага: задать свои/их креды + обмен кандидатами + Dial/Accept
-	// 1) свои креды (обычно их генерят):
-	localUfrag, _, err := ag.GetLocalUserCredentials()
-	if err != nil {
-		return fmt.Errorf("failed to get local ICE creds: %w", err)
-	}
-	a.logger.Debug("Local ICE creds", "ufrag", localUfrag, "pwd", "***")
-
-	// 2) установить удалённые креды
 	if err := ag.SetRemoteCredentials(remoteUfrag, remotePwd); err != nil {
 		return fmt.Errorf("failed to set remote ICE creds: %w", err)
 	}
-
-	// 3) Транспорт поднимется, когда вы вызовете Dial/Accept (в разных ролях)
-	// Здесь оставляем как хук — реализация зависит от роли и архитектуры:
-	//   conn, err := ag.Dial(context, remoteAddrs...)   // для controlling
-	//   conn, err := ag.Accept(context, ...)            // для controlled
-	// Управляйте role через AgentConfig.Role в NewAgent или SetControlling().
-	a.logger.Info("ICE credentials set; start Dial/Accept in your session flow")
 	return nil
 }
 
+// Connect runs connectivity checks and blocks until a candidate pair succeeds.
+// The controlling side dials, the controlled side accepts.
+func (a *ICEAgent) Connect(ctx context.Context, controlling bool, remoteUfrag, remotePwd string) (*ice.Conn, error) {
+	a.mu.RLock()
+	ag := a.agent
+	a.mu.RUnlock()
+	if ag == nil {
+		return nil, fmt.Errorf("ICE agent not started")
+	}
+
+	a.logger.Debug("Starting ICE connectivity checks", "controlling", controlling, "remote_ufrag", remoteUfrag)
+
+	var (
+		conn *ice.Conn
+		err  error
+	)
+	if controlling {
+		conn, err = ag.Dial(ctx, remoteUfrag, remotePwd)
+	} else {
+		conn, err = ag.Accept(ctx, remoteUfrag, remotePwd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ICE connectivity checks failed: %w", err)
+	}
+	return conn, nil
+}
+
 // GetSelectedCandidatePair returns the selected candidate pair
 func (a *ICEAgent) GetSelectedCandidatePair() (*ice.CandidatePair, error) {
 	a.mu.RLock()
@@ -218,9 +368,7 @@ func (a *ICEAgent) GetConnectionState() ice.ConnectionState {
 	if a.agent == nil {
 		return ice.ConnectionStateClosed
 	}
-	// В v2 GetConnectionState может отсутствовать, используем альтернативный подход
-	// Можно отслеживать состояние через OnConnectionStateChange callback
-	return ice.ConnectionStateNew // fallback
+	return ice.ConnectionState(a.state.Load())
 }
 
 // setupEventHandlers sets up ICE agent event handlers
@@ -229,6 +377,18 @@ func (a *ICEAgent) setupEventHandlers() {
 		if c != nil {
 			a.logger.Debug("New candidate gathered", "candidate", c.String())
 		}
+
+		a.handlerMu.Lock()
+		handler := a.candidateHandler
+		if c == nil && a.gatherDone != nil {
+			close(a.gatherDone)
+			a.gatherDone = nil
+		}
+		a.handlerMu.Unlock()
+
+		if handler != nil {
+			handler(c)
+		}
 	}); err != nil {
 		a.logger.Error("Failed to set candidate handler", "error", err)
 	}
@@ -254,11 +414,47 @@ func (a *ICEAgent) setupEventHandlers() {
 
 	if err := a.agent.OnConnectionStateChange(func(s ice.ConnectionState) {
 		a.logger.Info("ICE connection state changed", "state", s.String())
+		a.state.Store(int32(s))
+
+		a.handlerMu.Lock()
+		handler := a.stateHandler
+		a.handlerMu.Unlock()
+		if handler != nil {
+			handler(s)
+		}
 	}); err != nil {
 		a.logger.Error("Failed to set connection state handler", "error", err)
 	}
 }
 
+// parseSTUNURI parses a STUN server given as stun:/stuns: URI or host:port
+func parseSTUNURI(server string) (*stun.URI, error) {
+	// дополняем префикс если его нет
+	if !strings.HasPrefix(server, "stun:") && !strings.HasPrefix(server, "stuns:") {
+		server = "stun:" + server
+	}
+	u, err := stun.ParseURI(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse STUN URI %q: %w", server, err)
+	}
+	return u, nil
+}
+
+// parseTURNURI parses a TURN server given as turn:/turns: URI (optionally with
+// ?transport=udp|tcp) or host:port, and attaches the credentials
+func parseTURNURI(server, username, password string) (*stun.URI, error) {
+	if !strings.HasPrefix(server, "turn:") && !strings.HasPrefix(server, "turns:") {
+		server = "turn:" + server
+	}
+	u, err := stun.ParseURI(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TURN URI %q: %w", server, err)
+	}
+	u.Username = username
+	u.Password = password
+	return u, nil
+}
+
 // ValidateSTUNServer validates a STUN server
 func ValidateSTUNServer(server string) error {
 	// допускаем «короткий» формат host:port
@@ -0,0 +1,217 @@
+package ice
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+// trickleAgent собирает кандидаты агента через OnLocalCandidate
+type trickleAgent struct {
+	*ICEAgent
+	mu         sync.Mutex
+	candidates []ice.Candidate
+	done       chan struct{}
+}
+
+func newTrickleAgent(t *testing.T) *trickleAgent {
+	t.Helper()
+	ta := &trickleAgent{ICEAgent: NewICEAgent(nil, nil, testLogger{})}
+	if err := ta.Start(); err != nil {
+		t.Fatalf("Failed to start agent: %v", err)
+	}
+	t.Cleanup(func() { _ = ta.Stop() }) //nolint:errcheck // test cleanup
+	ta.OnLocalCandidate(func(c ice.Candidate) {
+		ta.mu.Lock()
+		defer ta.mu.Unlock()
+		if c == nil {
+			close(ta.done)
+			return
+		}
+		ta.candidates = append(ta.candidates, c)
+	})
+	return ta
+}
+
+// gather запускает trickle сбор и ждет end-of-candidates
+func (ta *trickleAgent) gather(t *testing.T) []ice.Candidate {
+	t.Helper()
+	ta.mu.Lock()
+	ta.candidates = nil
+	ta.done = make(chan struct{})
+	done := ta.done
+	ta.mu.Unlock()
+
+	if err := ta.StartGathering(); err != nil {
+		t.Fatalf("Failed to start gathering: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("End of candidates not signaled")
+	}
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	if len(ta.candidates) == 0 {
+		t.Skip("No host candidates available")
+	}
+	return append([]ice.Candidate(nil), ta.candidates...)
+}
+
+func addAll(t *testing.T, agent *ICEAgent, candidates []ice.Candidate) {
+	t.Helper()
+	for _, c := range candidates {
+		if err := agent.AddRemoteCandidate(c); err != nil {
+			t.Fatalf("Failed to add remote candidate: %v", err)
+		}
+	}
+}
+
+func TestICEAgent_TrickleAndRestart(t *testing.T) {
+	a := newTrickleAgent(t)
+	b := newTrickleAgent(t)
+
+	addAll(t, b.ICEAgent, a.gather(t))
+	addAll(t, a.ICEAgent, b.gather(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	aUfrag, aPwd := a.GetLocalCredentials()
+	bUfrag, bPwd := b.GetLocalCredentials()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := b.Connect(ctx, false, aUfrag, aPwd)
+		errCh <- err
+	}()
+	if _, err := a.Connect(ctx, true, bUfrag, bPwd); err != nil {
+		t.Fatalf("Controlling connect failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Controlled connect failed: %v", err)
+	}
+
+	// Restart меняет credentials; соединение восстанавливается на новых кандидатах
+	newUfrag, newPwd, err := a.Restart()
+	if err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	if newUfrag == aUfrag || newPwd == aPwd {
+		t.Fatal("Expected new credentials after restart")
+	}
+	newBUfrag, newBPwd, err := b.Restart()
+	if err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+
+	if err := a.StartConnectivityChecks(newBUfrag, newBPwd); err != nil {
+		t.Fatalf("Failed to set remote credentials: %v", err)
+	}
+	if err := b.StartConnectivityChecks(newUfrag, newPwd); err != nil {
+		t.Fatalf("Failed to set remote credentials: %v", err)
+	}
+	addAll(t, b.ICEAgent, a.gather(t))
+	addAll(t, a.ICEAgent, b.gather(t))
+
+	deadline := time.Now().Add(15 * time.Second)
+	for a.GetConnectionState() != ice.ConnectionStateConnected || b.GetConnectionState() != ice.ConnectionStateConnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Agents did not reconnect after restart: a=%s b=%s", a.GetConnectionState(), b.GetConnectionState())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestParseTURNURI(t *testing.T) {
+	tests := []struct {
+		server    string
+		scheme    stun.SchemeType
+		host      string
+		port      int
+		transport stun.ProtoType
+	}{
+		{"relay.example.com:3478", stun.SchemeTypeTURN, "relay.example.com", 3478, stun.ProtoTypeUDP},
+		{"turn:relay.example.com?transport=tcp", stun.SchemeTypeTURN, "relay.example.com", 3478, stun.ProtoTypeTCP},
+		{"turns:relay.example.com:5349", stun.SchemeTypeTURNS, "relay.example.com", 5349, stun.ProtoTypeTCP},
+	}
+
+	for _, tt := range tests {
+		u, err := parseTURNURI(tt.server, "1700000000:peer-a", "secret")
+		if err != nil {
+			t.Fatalf("parseTURNURI(%q) failed: %v", tt.server, err)
+		}
+		if u.Scheme != tt.scheme || u.Host != tt.host || u.Port != tt.port || u.Proto != tt.transport {
+			t.Errorf("parseTURNURI(%q) = %s %s:%d %s", tt.server, u.Scheme, u.Host, u.Port, u.Proto)
+		}
+		if u.Username != "1700000000:peer-a" || u.Password != "secret" {
+			t.Errorf("parseTURNURI(%q) did not attach credentials", tt.server)
+		}
+	}
+
+	if _, err := parseTURNURI("turn:relay.example.com?transport=sctp", "u", "p"); err == nil {
+		t.Error("Expected error for unsupported transport")
+	}
+}
+
+func TestICEAgent_SharedUDPMux(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	mux := ice.NewUniversalUDPMuxDefault(ice.UniversalUDPMuxParams{UDPConn: conn})
+	defer mux.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	a := &trickleAgent{ICEAgent: NewICEAgent(nil, nil, testLogger{})}
+	a.SetUDPMux(mux)
+	if err := a.Start(); err != nil {
+		t.Fatalf("Failed to start agent: %v", err)
+	}
+	defer a.Stop() //nolint:errcheck // test cleanup
+	b := newTrickleAgent(t)
+
+	candidates, err := a.GatherCandidates()
+	if err != nil {
+		t.Fatalf("Failed to gather candidates: %v", err)
+	}
+	if len(candidates) == 0 {
+		t.Fatal("Expected host candidates on the shared socket")
+	}
+	for _, c := range candidates {
+		if c.Port() != port {
+			t.Errorf("Candidate %s does not use shared port %d", c, port)
+		}
+	}
+
+	addAll(t, b.ICEAgent, candidates)
+	addAll(t, a.ICEAgent, b.gather(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	aUfrag, aPwd := a.GetLocalCredentials()
+	bUfrag, bPwd := b.GetLocalCredentials()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := b.Connect(ctx, false, aUfrag, aPwd)
+		errCh <- err
+	}()
+	if _, err := a.Connect(ctx, true, bUfrag, bPwd); err != nil {
+		t.Fatalf("Controlling connect failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Controlled connect failed: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// KeyPrefix префикс опубликованного ключа идентичности; отличает его от ключей
+// других форматов (WireGuard ключ из токена, временные ключи)
+const KeyPrefix = "ed25519:"
+
+// ErrKeyMismatch сертификат пира подписан не закрепленным за ним ключом
+var ErrKeyMismatch = errors.New("peer certificate does not match pinned identity key")
+
+// ErrNotIdentityKey опубликованный ключ не является ключом идентичности
+var ErrNotIdentityKey = errors.New("not an identity key")
+
+// certificateLifetime срок действия сертификата идентичности: проверяется ключ, а не срок
+const certificateLifetime = 10 * 365 * 24 * time.Hour
+
+// curve25519P модуль поля Curve25519: 2^255 - 19
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// Key постоянная пара ключей идентичности клиента. Ключ Ed25519 подписывает
+// TLS handshake между пирами; ключ Curve25519 (X25519) для WireGuard выводится
+// из него, так что пиру достаточно одного опубликованного публичного ключа.
+type Key struct {
+	private ed25519.PrivateKey
+}
+
+// Generate создает новую пару ключей
+func Generate() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+	return &Key{private: private}, nil
+}
+
+// NewKey оборачивает приватный ключ Ed25519
+func NewKey(private ed25519.PrivateKey) (*Key, error) {
+	if len(private) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 private key size %d", len(private))
+	}
+	return &Key{private: private}, nil
+}
+
+// PublicKey возвращает публичный ключ Ed25519 (ed25519:<base64>) — ключ, который
+// клиент публикует при регистрации и по которому пиры его закрепляют
+func (k *Key) PublicKey() string {
+	return KeyPrefix + base64.StdEncoding.EncodeToString(k.Ed25519PublicKey())
+}
+
+// Ed25519PublicKey возвращает публичный ключ Ed25519
+func (k *Key) Ed25519PublicKey() ed25519.PublicKey {
+	return k.private.Public().(ed25519.PublicKey)
+}
+
+// Curve25519PrivateKey возвращает приватный ключ X25519, соответствующий ключу Ed25519
+// (скаляр из SHA-512 seed, как в RFC 8032)
+func (k *Key) Curve25519PrivateKey() []byte {
+	h := sha512.Sum512(k.private.Seed())
+	scalar := h[:32]
+	scalar[0] &= 248
+	scalar[31] &= 127
+	scalar[31] |= 64
+	return scalar
+}
+
+// WireGuardPrivateKey возвращает приватный ключ X25519 в формате WireGuard (base64)
+func (k *Key) WireGuardPrivateKey() string {
+	return base64.StdEncoding.EncodeToString(k.Curve25519PrivateKey())
+}
+
+// WireGuardPublicKey возвращает публичный ключ X25519 в формате WireGuard (base64)
+func (k *Key) WireGuardPublicKey() string {
+	pub, _ := Curve25519PublicKey(k.Ed25519PublicKey()) //nolint:errcheck // own key is always valid
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// Certificate создает самоподписанный сертификат TLS на ключе идентичности
+func (k *Key) Certificate(commonName string) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, k.Ed25519PublicKey(), k.private)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: k.private}, nil
+}
+
+// ParsePublicKey разбирает опубликованный публичный ключ идентичности (ed25519:<base64>)
+func ParsePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(publicKey, KeyPrefix) {
+		return nil, ErrNotIdentityKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(publicKey, KeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key encoding: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid identity key size %d", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Curve25519PublicKey переводит публичный ключ Ed25519 в ключ X25519:
+// u = (1 + y) / (1 - y) mod p (бирациональное отображение Edwards -> Montgomery)
+func Curve25519PublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key size %d", len(pub))
+	}
+
+	// Координата y в little-endian, старший бит — знак x
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverse(le))
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, fmt.Errorf("invalid Ed25519 public key")
+	}
+
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, curve25519P)
+	inverse := denominator.ModInverse(denominator, curve25519P)
+	if inverse == nil {
+		return nil, fmt.Errorf("invalid Ed25519 public key")
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, inverse)
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, 32)
+	u.FillBytes(out)
+	return reverse(out), nil
+}
+
+// VerifyCertificate проверяет, что сертификат выпущен на закрепленный ключ.
+// Владение ключом доказывает подпись TLS handshake.
+func VerifyCertificate(der []byte, pinned ed25519.PublicKey) error {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse peer certificate: %w", err)
+	}
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok || !pub.Equal(pinned) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
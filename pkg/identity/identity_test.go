@@ -0,0 +1,173 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+func TestKey_Curve25519(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		key, err := Generate()
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		private, err := ecdh.X25519().NewPrivateKey(key.Curve25519PrivateKey())
+		if err != nil {
+			t.Fatalf("Invalid X25519 private key: %v", err)
+		}
+		derived, err := Curve25519PublicKey(key.Ed25519PublicKey())
+		if err != nil {
+			t.Fatalf("Curve25519PublicKey failed: %v", err)
+		}
+		if !bytes.Equal(derived, private.PublicKey().Bytes()) {
+			t.Fatal("Derived X25519 public key does not match private key")
+		}
+		if key.WireGuardPublicKey() != base64.StdEncoding.EncodeToString(derived) {
+			t.Fatal("Unexpected WireGuard public key")
+		}
+	}
+}
+
+func TestKey_PublicKeyAndCertificate(t *testing.T) {
+	key, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	pub, err := ParsePublicKey(key.PublicKey())
+	if err != nil || !pub.Equal(key.Ed25519PublicKey()) {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	if _, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub)); !errors.Is(err, ErrNotIdentityKey) {
+		t.Errorf("Expected key without prefix to be rejected, got %v", err)
+	}
+	if _, err := ParsePublicKey(KeyPrefix + "AAAA"); err == nil {
+		t.Error("Expected short key to be rejected")
+	}
+
+	cert, err := key.Certificate("peer-a")
+	if err != nil {
+		t.Fatalf("Certificate failed: %v", err)
+	}
+	if err := VerifyCertificate(cert.Certificate[0], pub); err != nil {
+		t.Errorf("Expected certificate to match its key: %v", err)
+	}
+	other, _ := Generate() //nolint:errcheck // tested above
+	if err := VerifyCertificate(cert.Certificate[0], other.Ed25519PublicKey()); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected key mismatch, got %v", err)
+	}
+}
+
+func TestStore_LoadAndRotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys", "identity.key")
+	store := NewStore(&Config{KeyFile: file}, testLogger{})
+	key, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Expected key file to be created: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("Expected private key file readable by owner only, got %v", info.Mode())
+	}
+
+	// Ключ постоянный: повторная загрузка возвращает тот же ключ
+	reloaded, err := NewStore(&Config{KeyFile: file}, testLogger{}).Load()
+	if err != nil || reloaded.PublicKey() != key.PublicKey() {
+		t.Fatalf("Expected persisted key, got %v", err)
+	}
+
+	// Неудачная публикация оставляет прежний ключ
+	if _, err := store.Rotate(func(*Key, string) error { return errors.New("registration failed") }); err == nil {
+		t.Error("Expected rotation to fail")
+	}
+	if store.Key().PublicKey() != key.PublicKey() {
+		t.Error("Expected key to survive failed rotation")
+	}
+
+	var published, signature string
+	rotated, err := store.Rotate(func(k *Key, sig string) error {
+		published, signature = k.PublicKey(), sig
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if rotated.PublicKey() == key.PublicKey() || published != rotated.PublicKey() {
+		t.Error("Expected a new published key")
+	}
+	reloaded, err = NewStore(&Config{KeyFile: file}, testLogger{}).Load()
+	if err != nil || reloaded.PublicKey() != rotated.PublicKey() {
+		t.Errorf("Expected rotated key to be persisted, got %v", err)
+	}
+
+	// Переход подписан прежним ключом, подпись сохраняется вместе с ключом
+	if err := VerifyRotation(key.PublicKey(), rotated.PublicKey(), signature); err != nil {
+		t.Errorf("Expected rotation signed by previous key: %v", err)
+	}
+	reloadedStore := NewStore(&Config{KeyFile: file}, testLogger{})
+	if _, err := reloadedStore.Load(); err != nil || reloadedStore.Signature() != signature {
+		t.Errorf("Expected rotation signature to be persisted, got %q (%v)", reloadedStore.Signature(), err)
+	}
+}
+
+func TestPinStore_PinAndRotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "known_peers.json")
+	pins := NewPinStore(file, testLogger{})
+	if err := pins.Load(); err != nil {
+		t.Fatalf("Load of missing file failed: %v", err)
+	}
+
+	peer, _ := Generate() //nolint:errcheck // tested above
+	mitm, _ := Generate() //nolint:errcheck // tested above
+	if err := pins.Pin("peer-a", peer.PublicKey()); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	if err := pins.Pin("peer-a", mitm.PublicKey()); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected pinned key not to be replaced, got %v", err)
+	}
+	if err := pins.Pin("peer-b", "generated-quic-key-1"); !errors.Is(err, ErrNotIdentityKey) {
+		t.Errorf("Expected non-identity key not to be pinned, got %v", err)
+	}
+
+	// Смена ключа без подписи закрепленным ключом не принимается
+	rotated, _ := Generate() //nolint:errcheck // tested above
+	if err := pins.Rotate("peer-a", rotated.PublicKey(), mitm.SignRotation(rotated.PublicKey())); !errors.Is(err, ErrRotationNotSigned) {
+		t.Errorf("Expected rotation signed by another key to fail, got %v", err)
+	}
+	if err := pins.Rotate("peer-a", rotated.PublicKey(), ""); !errors.Is(err, ErrRotationNotSigned) {
+		t.Errorf("Expected unsigned rotation to fail, got %v", err)
+	}
+	if err := pins.Rotate("peer-a", rotated.PublicKey(), peer.SignRotation(rotated.PublicKey())); err != nil {
+		t.Fatalf("Expected signed rotation to succeed: %v", err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Expected pin file to be created: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("Expected pin file readable by owner only, got %v", info.Mode())
+	}
+	reloaded := NewPinStore(file, testLogger{})
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if key, ok := reloaded.Lookup("peer-a"); !ok || key != rotated.PublicKey() {
+		t.Errorf("Expected rotated pin to be persisted, got %q", key)
+	}
+}
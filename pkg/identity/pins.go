@@ -0,0 +1,186 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rotationContext контекст подписи смены ключа: подпись нельзя выдать за подпись другого сообщения
+const rotationContext = "cloudbridge-identity-rotation:"
+
+// ErrRotationNotSigned новый ключ пира не подписан закрепленным за ним ключом
+var ErrRotationNotSigned = errors.New("rotated identity key is not signed by the pinned key")
+
+// SignRotation подписывает переход на новый ключ newPublicKey текущим ключом.
+// Пиры, закрепившие текущий ключ, принимают новый только с этой подписью.
+func (k *Key) SignRotation(newPublicKey string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, []byte(rotationContext+newPublicKey)))
+}
+
+// VerifyRotation проверяет, что переход на newPublicKey подписан ключом oldPublicKey
+func VerifyRotation(oldPublicKey, newPublicKey, signature string) error {
+	old, err := ParsePublicKey(oldPublicKey)
+	if err != nil {
+		return err
+	}
+	if _, err := ParsePublicKey(newPublicKey); err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(old, []byte(rotationContext+newPublicKey), sig) {
+		return ErrRotationNotSigned
+	}
+	return nil
+}
+
+// DefaultPinFile возвращает путь файла закрепленных ключей пиров по умолчанию:
+// ~/.cloudbridge-client/known_peers.json
+func DefaultPinFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "known_peers.json"
+	}
+	return filepath.Join(home, ".cloudbridge-client", "known_peers.json")
+}
+
+// pinnedKey закрепленный ключ пира
+type pinnedKey struct {
+	PublicKey string    `json:"public_key"`
+	PinnedAt  time.Time `json:"pinned_at"`
+}
+
+// PinStore хранит ключи идентичности пиров, закрепленные при первом соединении (TOFU).
+// Закрепленный ключ меняется только сменой, подписанной самим этим ключом,
+// так что relay не может подменить пира, которого клиент уже видел.
+type PinStore struct {
+	path   string
+	logger Logger
+	pins   map[string]pinnedKey
+	mu     sync.RWMutex
+}
+
+// NewPinStore creates a new peer key pin store
+func NewPinStore(path string, logger Logger) *PinStore {
+	if path == "" {
+		path = DefaultPinFile()
+	}
+	return &PinStore{path: path, logger: logger, pins: make(map[string]pinnedKey)}
+}
+
+// Load читает закрепленные ключи из файла; отсутствие файла означает, что пиров еще нет
+func (s *PinStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pinned peer keys: %w", err)
+	}
+	pins := make(map[string]pinnedKey)
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return fmt.Errorf("invalid pinned peer keys %s: %w", s.path, err)
+	}
+	s.pins = pins
+	s.logger.Info("Loaded pinned peer keys", "path", s.path, "count", len(pins))
+	return nil
+}
+
+// Lookup возвращает закрепленный ключ пира
+func (s *PinStore) Lookup(peerID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pin, ok := s.pins[peerID]
+	return pin.PublicKey, ok
+}
+
+// Pin закрепляет ключ пира при первом соединении. Ключ, отличный от уже
+// закрепленного, не принимается: для смены ключа нужна подпись (Rotate).
+func (s *PinStore) Pin(peerID, publicKey string) error {
+	if _, err := ParsePublicKey(publicKey); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pin, ok := s.pins[peerID]; ok {
+		if pin.PublicKey != publicKey {
+			return ErrKeyMismatch
+		}
+		return nil
+	}
+	return s.storeLocked(peerID, publicKey)
+}
+
+// Rotate заменяет закрепленный ключ пира новым, если переход подписан закрепленным ключом
+func (s *PinStore) Rotate(peerID, publicKey, signature string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pin, ok := s.pins[peerID]
+	if !ok {
+		return fmt.Errorf("no pinned key for peer %s", peerID)
+	}
+	if pin.PublicKey == publicKey {
+		return nil
+	}
+	if err := VerifyRotation(pin.PublicKey, publicKey, signature); err != nil {
+		return err
+	}
+	if err := s.storeLocked(peerID, publicKey); err != nil {
+		return err
+	}
+	s.logger.Info("Pinned peer key rotated", "peer_id", peerID, "public_key", publicKey, "previous_public_key", pin.PublicKey)
+	return nil
+}
+
+// storeLocked закрепляет ключ и сохраняет файл. Вызывается под s.mu.
+func (s *PinStore) storeLocked(peerID, publicKey string) error {
+	previous, existed := s.pins[peerID]
+	s.pins[peerID] = pinnedKey{PublicKey: publicKey, PinnedAt: time.Now().UTC()}
+	if err := s.writeLocked(); err != nil {
+		// Закрепление, которое не удалось сохранить, не должно действовать только в памяти
+		if existed {
+			s.pins[peerID] = previous
+		} else {
+			delete(s.pins, peerID)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeLocked атомарно записывает файл (CreateTemp создает файл с правами 0600). Вызывается под s.mu.
+func (s *PinStore) writeLocked() error {
+	data, err := json.MarshalIndent(s.pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pinned peer keys: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create pinned peer keys directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".known-peers-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write pinned peer keys: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // temp file is gone after rename
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close() //nolint:errcheck // cleanup after failed write
+		return fmt.Errorf("failed to write pinned peer keys: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pinned peer keys: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write pinned peer keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pemType тип PEM блока файла ключа
+const pemType = "PRIVATE KEY"
+
+// signaturePEMType тип PEM блока подписи смены ключа прежним ключом
+const signaturePEMType = "ROTATION SIGNATURE"
+
+// Logger interface for identity logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Config настройки ключа идентичности
+type Config struct {
+	KeyFile          string        // файл приватного ключа (PKCS#8 PEM); создается при первом запуске
+	RotationInterval time.Duration // автоматическая смена ключа (0 — только вручную)
+	RequirePeerKeys  bool          // отклонять пиров, ключ идентичности которых неизвестен
+	PinFile          string        // ключи пиров, закрепленные при первом соединении
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{KeyFile: DefaultKeyFile(), RequirePeerKeys: true, PinFile: DefaultPinFile()}
+}
+
+// DefaultKeyFile возвращает путь файла ключа по умолчанию: ~/.cloudbridge-client/identity.key
+func DefaultKeyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "identity.key"
+	}
+	return filepath.Join(home, ".cloudbridge-client", "identity.key")
+}
+
+// Store хранит ключ идентичности в файле и сменяет его
+type Store struct {
+	config    *Config
+	logger    Logger
+	key       *Key
+	signature string // подпись текущего ключа прежним ключом (пусто — ключ не сменялся)
+	createdAt time.Time
+	mu        sync.RWMutex
+}
+
+// NewStore creates a new identity key store
+func NewStore(config *Config, logger Logger) *Store {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.KeyFile == "" {
+		config.KeyFile = DefaultKeyFile()
+	}
+	return &Store{config: config, logger: logger}
+}
+
+// Load читает ключ из файла; если файла нет, создает и сохраняет новый ключ
+func (s *Store) Load() (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.config.KeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := Generate()
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKey(s.config.KeyFile, key, ""); err != nil {
+			return nil, err
+		}
+		s.key, s.signature, s.createdAt = key, "", time.Now()
+		s.logger.Info("Generated new identity key", "path", s.config.KeyFile, "public_key", key.PublicKey())
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity key: %w", err)
+	}
+
+	key, signature, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key %s: %w", s.config.KeyFile, err)
+	}
+	s.key, s.signature, s.createdAt = key, signature, time.Now()
+	if info, err := os.Stat(s.config.KeyFile); err == nil {
+		s.createdAt = info.ModTime()
+	}
+	s.logger.Info("Loaded identity key", "path", s.config.KeyFile, "public_key", key.PublicKey())
+	return key, nil
+}
+
+// Key возвращает текущий ключ (nil — ключ не загружен)
+func (s *Store) Key() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.key
+}
+
+// Signature возвращает подпись перехода на текущий ключ прежним ключом (пусто — ключ не сменялся).
+// Она публикуется вместе с ключом, чтобы пиры, закрепившие прежний ключ, приняли новый.
+func (s *Store) Signature() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signature
+}
+
+// Age возвращает возраст текущего ключа
+func (s *Store) Age() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.createdAt)
+}
+
+// Config возвращает настройки хранилища
+func (s *Store) Config() *Config {
+	return s.config
+}
+
+// Rotate создает новый ключ, подписывает переход на него прежним ключом, передает
+// ключ и подпись publish (регистрация нового ключа) и только после успешной
+// публикации сохраняет и применяет его. При ошибке публикации действует прежний ключ.
+func (s *Store) Rotate(publish func(key *Key, signature string) error) (*Key, error) {
+	key, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+	signature := ""
+	if previous := s.Key(); previous != nil {
+		signature = previous.SignRotation(key.PublicKey())
+	}
+	if publish != nil {
+		if err := publish(key, signature); err != nil {
+			return nil, fmt.Errorf("failed to publish rotated identity key: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Ключ уже опубликован: сохраняем его даже при ошибке записи, чтобы не расходиться с relay
+	previous := s.key
+	s.key, s.signature, s.createdAt = key, signature, time.Now()
+	if err := writeKey(s.config.KeyFile, key, signature); err != nil {
+		return key, err
+	}
+	previousKey := ""
+	if previous != nil {
+		previousKey = previous.PublicKey()
+	}
+	s.logger.Info("Identity key rotated", "public_key", key.PublicKey(), "previous_public_key", previousKey)
+	return key, nil
+}
+
+// parseKey разбирает файл ключа: PEM блок ключа и необязательный блок подписи смены ключа
+func parseKey(data []byte) (*Key, string, error) {
+	block, rest := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, "", fmt.Errorf("no %s PEM block", pemType)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	key, err := NewKey(private)
+	if err != nil {
+		return nil, "", err
+	}
+
+	signature := ""
+	if block, _ := pem.Decode(rest); block != nil && block.Type == signaturePEMType {
+		signature = base64.StdEncoding.EncodeToString(block.Bytes)
+	}
+	return key, signature, nil
+}
+
+// writeKey атомарно записывает ключ и подпись смены ключа (CreateTemp создает файл с правами 0600)
+func writeKey(path string, key *Key, signature string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return fmt.Errorf("failed to encode identity key: %w", err)
+	}
+	var sig []byte
+	if signature != "" {
+		if sig, err = base64.StdEncoding.DecodeString(signature); err != nil {
+			return fmt.Errorf("failed to encode rotation signature: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create identity key directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".identity-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write identity key: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // temp file is gone after rename
+	if err := pem.Encode(tmp, &pem.Block{Type: pemType, Bytes: der}); err != nil {
+		_ = tmp.Close() //nolint:errcheck // cleanup after failed write
+		return fmt.Errorf("failed to write identity key: %w", err)
+	}
+	if sig != nil {
+		if err := pem.Encode(tmp, &pem.Block{Type: signaturePEMType, Bytes: sig}); err != nil {
+			_ = tmp.Close() //nolint:errcheck // cleanup after failed write
+			return fmt.Errorf("failed to write identity key: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write identity key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write identity key: %w", err)
+	}
+	return nil
+}
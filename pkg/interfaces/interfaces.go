@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"errors"
 	"time"
 
 	"github.com/2gc-dev/cloudbridge-client/pkg/types"
@@ -36,6 +37,56 @@ type ClientStateEvent struct {
 	Updated time.Time // timestamp
 }
 
+// ErrHeartbeatStreamUnsupported is returned when the relay or the active transport
+// does not support streaming heartbeats; callers fall back to unary heartbeats.
+var ErrHeartbeatStreamUnsupported = errors.New("heartbeat stream is not supported")
+
+// HeartbeatStreamer is implemented by clients that can keep a long-lived heartbeat stream.
+type HeartbeatStreamer interface {
+	OpenHeartbeatStream() (HeartbeatStream, error)
+}
+
+// HeartbeatStream is a bidirectional heartbeat stream to the relay.
+type HeartbeatStream interface {
+	Send() error                  // sends a heartbeat with current client metrics
+	Recv() (*HeartbeatAck, error) // blocks until the next server message
+	Close() error
+}
+
+// HeartbeatAck is a server message received on the heartbeat stream.
+type HeartbeatAck struct {
+	Status        string
+	Interval      time.Duration // server-requested heartbeat interval (0 = unchanged)
+	ServerMetrics *ServerMetrics
+	Notifications []ServerNotification
+	ErrorMessage  string
+}
+
+// ServerMetrics contains relay-side metrics reported with heartbeats.
+type ServerMetrics struct {
+	ConnectedClients int32
+	ActiveTunnels    int32
+	ServerLoad       float64
+	Timestamp        time.Time
+}
+
+// Server notification types
+const (
+	NotificationTunnelRevoked = "tunnel_revoked"
+	NotificationTokenExpiring = "token_expiring"
+	NotificationReconnect     = "reconnect"
+)
+
+// ServerNotification is a server-to-client notification pushed on the heartbeat stream.
+type ServerNotification struct {
+	Type       string
+	TunnelID   string    // tunnel_revoked
+	POPID      string    // reconnect: target POP (optional)
+	POPAddress string    // reconnect: target host:port (optional)
+	Message    string    // human-readable reason
+	ExpiresAt  time.Time // token_expiring
+}
+
 // ConfigInterface defines a read-only configuration accessor.
 type ConfigInterface interface {
 	// Relay
@@ -41,7 +41,7 @@ type MASQUEConfig struct {
 // DefaultMASQUEConfig возвращает безопасную конфигурацию по умолчанию
 func DefaultMASQUEConfig() *MASQUEConfig {
 	return &MASQUEConfig{
-		ServerName:         "edge.2gc.ru",
+		ServerName:         "", // Задается из выбранного relay POP
 		HandshakeTimeout:   8 * time.Second,
 		IdleTimeout:        30 * time.Second,
 		EnableDatagrams:    true,
@@ -55,6 +55,9 @@ func NewMASQUEClient(cfg *MASQUEConfig) (*MASQUEClient, error) {
 	if cfg == nil {
 		cfg = DefaultMASQUEConfig()
 	}
+	if cfg.ServerName == "" {
+		return nil, errors.New("MASQUE server name is required")
+	}
 
 	tlsConf := &tls.Config{
 		MinVersion:         tls.VersionTLS13,
@@ -0,0 +1,23 @@
+package meshdns
+
+import "fmt"
+
+// Error ошибка настройки системного резолвера: какая операция, на каком интерфейсе и с
+// каким значением (адрес сервера, домен) не удалась
+type Error struct {
+	Op        string // операция: lookup link, set dns, set domain, revert
+	Interface string
+	Target    string // адрес сервера или домен (пусто — весь интерфейс)
+	Err       error  // для systemd-resolved — dbus.Error с именем ошибки D-Bus
+}
+
+func (e *Error) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("mesh DNS %s %s on %s: %v", e.Op, e.Target, e.Interface, e.Err)
+	}
+	return fmt.Sprintf("mesh DNS %s on %s: %v", e.Op, e.Interface, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,432 @@
+package meshdns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// recordTTL TTL ответов на имена пиров, секунды
+	recordTTL = 60
+	// forwardTimeout таймаут запроса к upstream резолверу
+	forwardTimeout = 2 * time.Second
+	// tcpIdleTimeout закрывает неактивные TCP соединения клиентов
+	tcpIdleTimeout = 10 * time.Second
+	// maxMessageSize максимальный размер DNS сообщения
+	maxMessageSize = 65535
+)
+
+// Logger interface for mesh DNS logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Config настройки DNS mesh сети
+type Config struct {
+	Domain     string   // зона имен пиров: <peer-id>.<tenant>.<domain>
+	Tenant     string   // тенант (пусто — <peer-id>.<domain>)
+	ListenAddr string   // адрес UDP/TCP, обычно <overlay-ip>:53
+	Upstreams  []string // резолверы для прочих имен (host или host:port)
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{Domain: "mesh"}
+}
+
+// Server отвечает на запросы имен пиров mesh и пересылает прочие запросы upstream резолверам
+type Server struct {
+	config   *Config
+	logger   Logger
+	zone     string              // <tenant>.<domain>. в нижнем регистре
+	records  map[string][]net.IP // полное имя -> адреса
+	udp      net.PacketConn
+	tcp      net.Listener
+	mu       sync.RWMutex
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	queries  int
+	answered int
+	nxdomain int
+	forwards int
+	failures int
+}
+
+// NewServer creates a new mesh DNS server
+func NewServer(config *Config, logger Logger) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Domain == "" {
+		config.Domain = DefaultConfig().Domain
+	}
+	// Домен может состоять из нескольких меток (corp.mesh)
+	labels := strings.Split(strings.Trim(config.Domain, "."), ".")
+	for i, label := range labels {
+		labels[i] = Label(label)
+	}
+	zone := strings.Join(labels, ".") + "."
+	if config.Tenant != "" {
+		zone = Label(config.Tenant) + "." + zone
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		config:  config,
+		logger:  logger,
+		zone:    zone,
+		records: make(map[string][]net.IP),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Label приводит идентификатор к метке DNS: нижний регистр, символы кроме
+// a-z, 0-9 и '-' (в том числе точки) заменяются на '-'
+func Label(id string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(id) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// Zone возвращает зону имен пиров (с точкой в конце)
+func (s *Server) Zone() string {
+	return s.zone
+}
+
+// Name возвращает полное имя пира (с точкой в конце)
+func (s *Server) Name(peerID string) string {
+	return Label(peerID) + "." + s.zone
+}
+
+// SetPeers заменяет записи: адреса overlay сети по peer ID
+func (s *Server) SetPeers(peers map[string][]net.IP) {
+	records := make(map[string][]net.IP, len(peers))
+	for peerID, ips := range peers {
+		if len(ips) > 0 {
+			records[s.Name(peerID)] = ips
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+}
+
+// Start начинает прием запросов по UDP и TCP
+func (s *Server) Start() error {
+	udp, err := net.ListenPacket("udp", s.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP %s: %w", s.config.ListenAddr, err)
+	}
+	// TCP слушает тот же порт, что достался UDP (важно для порта 0)
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		_ = udp.Close() //nolint:errcheck // cleanup after failed listen
+		return fmt.Errorf("failed to listen on TCP %s: %w", s.config.ListenAddr, err)
+	}
+
+	s.mu.Lock()
+	s.udp, s.tcp = udp, tcp
+	s.mu.Unlock()
+
+	s.wg.Add(2)
+	go s.serveUDP(udp)
+	go s.serveTCP(tcp)
+
+	s.logger.Info("Mesh DNS started", "address", udp.LocalAddr().String(), "zone", s.zone, "upstreams", s.config.Upstreams)
+	return nil
+}
+
+// Stop останавливает прием запросов
+func (s *Server) Stop() {
+	s.cancel()
+	s.mu.Lock()
+	udp, tcp := s.udp, s.tcp
+	s.udp, s.tcp = nil, nil
+	s.mu.Unlock()
+	if udp != nil {
+		_ = udp.Close() //nolint:errcheck // best-effort cleanup
+	}
+	if tcp != nil {
+		_ = tcp.Close() //nolint:errcheck // best-effort cleanup
+	}
+	s.wg.Wait()
+}
+
+// Addr возвращает адрес UDP сокета (nil — сервер не запущен)
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.udp == nil {
+		return nil
+	}
+	return s.udp.LocalAddr()
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Mesh DNS UDP read failed", "error", err)
+			}
+			return
+		}
+		query := append([]byte{}, buf[:n]...)
+		// Пересылка upstream блокирует: каждый запрос в своей горутине
+		go func() {
+			if resp := s.handle(query, "udp"); resp != nil {
+				_, _ = conn.WriteTo(resp, addr) //nolint:errcheck // client may be gone
+			}
+		}()
+	}
+}
+
+func (s *Server) serveTCP(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Mesh DNS TCP accept failed", "error", err)
+			}
+			return
+		}
+		go s.serveTCPConn(conn)
+	}
+}
+
+func (s *Server) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_ = conn.SetDeadline(time.Now().Add(tcpIdleTimeout)) //nolint:errcheck // deadline on live conn
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+		resp := s.handle(query, "tcp")
+		if resp == nil {
+			return
+		}
+		if err := writeTCPMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle отвечает на запрос; nil — запрос не разобран и остается без ответа
+func (s *Server) handle(query []byte, network string) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return s.reply(header, nil, dnsmessage.RCodeFormatError, nil)
+	}
+
+	s.mu.Lock()
+	s.queries++
+	s.mu.Unlock()
+
+	name := strings.ToLower(question.Name.String())
+	if name != s.zone && !strings.HasSuffix(name, "."+s.zone) {
+		return s.forward(header, question, query, network)
+	}
+
+	s.mu.Lock()
+	ips, ok := s.records[name]
+	if ok || name == s.zone {
+		s.answered++
+	} else {
+		s.nxdomain++
+	}
+	s.mu.Unlock()
+
+	if !ok && name != s.zone {
+		return s.reply(header, &question, dnsmessage.RCodeNameError, nil)
+	}
+	// Имя пира без адресов запрошенного типа — пустой ответ (NODATA)
+	var answers []net.IP
+	for _, ip := range ips {
+		if (question.Type == dnsmessage.TypeA && ip.To4() != nil) ||
+			(question.Type == dnsmessage.TypeAAAA && ip.To4() == nil) {
+			answers = append(answers, ip)
+		}
+	}
+	return s.reply(header, &question, dnsmessage.RCodeSuccess, answers)
+}
+
+// reply собирает авторитативный ответ зоны mesh
+func (s *Server) reply(query dnsmessage.Header, question *dnsmessage.Question, rcode dnsmessage.RCode, ips []net.IP) []byte {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{
+		ID:                 query.ID,
+		Response:           true,
+		Authoritative:      rcode != dnsmessage.RCodeServerFailure,
+		RecursionDesired:   query.RecursionDesired,
+		RecursionAvailable: len(s.config.Upstreams) > 0,
+		RCode:              rcode,
+	})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil
+	}
+	if question != nil {
+		if err := builder.Question(*question); err != nil {
+			return nil
+		}
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: recordTTL}
+		var err error
+		if ip4 := ip.To4(); ip4 != nil {
+			var a dnsmessage.AResource
+			copy(a.A[:], ip4)
+			err = builder.AResource(header, a)
+		} else {
+			var aaaa dnsmessage.AAAAResource
+			copy(aaaa.AAAA[:], ip.To16())
+			err = builder.AAAAResource(header, aaaa)
+		}
+		if err != nil {
+			return nil
+		}
+	}
+	resp, err := builder.Finish()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// forward пересылает запрос upstream резолверам по очереди; при неудаче — SERVFAIL
+func (s *Server) forward(header dnsmessage.Header, question dnsmessage.Question, query []byte, network string) []byte {
+	if len(s.config.Upstreams) == 0 {
+		return s.reply(header, &question, dnsmessage.RCodeRefused, nil)
+	}
+
+	var lastErr error
+	for _, upstream := range s.config.Upstreams {
+		resp, err := exchange(s.ctx, network, upstreamAddr(upstream), query, header.ID)
+		if err == nil {
+			s.mu.Lock()
+			s.forwards++
+			s.mu.Unlock()
+			return resp
+		}
+		lastErr = err
+	}
+
+	s.mu.Lock()
+	s.failures++
+	s.mu.Unlock()
+	s.logger.Debug("Mesh DNS forward failed", "name", question.Name.String(), "error", lastErr)
+	return s.reply(header, &question, dnsmessage.RCodeServerFailure, nil)
+}
+
+// exchange выполняет один запрос к резолверу по UDP или TCP
+func exchange(ctx context.Context, network, addr string, query []byte, id uint16) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial upstream %s: %w", addr, err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline) //nolint:errcheck // deadline on fresh conn
+
+	var resp []byte
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, fmt.Errorf("failed to query upstream %s: %w", addr, err)
+		}
+		resp, err = readTCPMessage(conn)
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, fmt.Errorf("failed to query upstream %s: %w", addr, err)
+		}
+		buf := make([]byte, maxMessageSize)
+		var n int
+		n, err = conn.Read(buf)
+		resp = buf[:n]
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream %s response: %w", addr, err)
+	}
+	if len(resp) < 2 || binary.BigEndian.Uint16(resp) != id {
+		return nil, fmt.Errorf("unexpected response from upstream %s", addr)
+	}
+	return resp, nil
+}
+
+// upstreamAddr добавляет порт 53, если он не указан
+func upstreamAddr(upstream string) string {
+	if _, _, err := net.SplitHostPort(upstream); err == nil {
+		return upstream
+	}
+	return net.JoinHostPort(strings.Trim(upstream, "[]"), "53")
+}
+
+// readTCPMessage читает DNS сообщение с двухбайтовым префиксом длины (RFC 1035 4.2.2)
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg))) // #nosec G115 -- DNS messages are below 64 KiB
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// GetMetrics возвращает статистику DNS
+func (s *Server) GetMetrics() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]interface{}{
+		"zone":             s.zone,
+		"records":          len(s.records),
+		"queries":          s.queries,
+		"answered":         s.answered,
+		"nxdomain":         s.nxdomain,
+		"forwarded":        s.forwards,
+		"forward_failures": s.failures,
+	}
+}
@@ -0,0 +1,149 @@
+package meshdns
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+func startTestServer(t *testing.T, config *Config) *Server {
+	t.Helper()
+	config.ListenAddr = "127.0.0.1:0"
+	s := NewServer(config, testLogger{})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Failed to start DNS server: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// query отправляет запрос и возвращает код ответа и адреса
+func query(t *testing.T, network, addr, name string, qtype dnsmessage.Type) (dnsmessage.RCode, []net.IP) {
+	t.Helper()
+	msg, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack query: %v", err)
+	}
+
+	conn, err := net.DialTimeout(network, addr, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck // test deadline
+
+	var resp []byte
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, msg); err != nil {
+			t.Fatalf("Failed to send query: %v", err)
+		}
+		resp, err = readTCPMessage(conn)
+	} else {
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatalf("Failed to send query: %v", err)
+		}
+		buf := make([]byte, 1500)
+		var n int
+		n, err = conn.Read(buf)
+		resp = buf[:n]
+	}
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	var parsed dnsmessage.Message
+	if err := parsed.Unpack(resp); err != nil {
+		t.Fatalf("Failed to unpack response: %v", err)
+	}
+	if parsed.ID != 42 {
+		t.Fatalf("Unexpected response ID %d", parsed.ID)
+	}
+	var ips []net.IP
+	for _, answer := range parsed.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		}
+	}
+	return parsed.RCode, ips
+}
+
+func TestServer_ResolvesPeers(t *testing.T) {
+	// Upstream — второй сервер со своей зоной
+	upstream := startTestServer(t, &Config{Domain: "corp"})
+	upstream.SetPeers(map[string][]net.IP{"www": {net.ParseIP("192.0.2.10")}})
+
+	s := startTestServer(t, &Config{Domain: "mesh", Tenant: "Acme", Upstreams: []string{upstream.Addr().String()}})
+	s.SetPeers(map[string][]net.IP{
+		"Peer_A": {net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2")},
+		"peer-b": {net.ParseIP("10.0.0.3")},
+	})
+	addr := s.Addr().String()
+
+	if s.Name("Peer_A") != "peer-a.acme.mesh." {
+		t.Errorf("Unexpected peer name %s", s.Name("Peer_A"))
+	}
+	for _, network := range []string{"udp", "tcp"} {
+		rcode, ips := query(t, network, addr, "PEER-A.acme.mesh.", dnsmessage.TypeA)
+		if rcode != dnsmessage.RCodeSuccess || len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.2")) {
+			t.Errorf("%s: unexpected A answer %v %v", network, rcode, ips)
+		}
+	}
+	if _, ips := query(t, "udp", addr, "peer-a.acme.mesh.", dnsmessage.TypeAAAA); len(ips) != 1 || !ips[0].Equal(net.ParseIP("fd00::2")) {
+		t.Errorf("Unexpected AAAA answer %v", ips)
+	}
+	if rcode, ips := query(t, "udp", addr, "peer-b.acme.mesh.", dnsmessage.TypeAAAA); rcode != dnsmessage.RCodeSuccess || len(ips) != 0 {
+		t.Errorf("Expected NODATA for missing AAAA, got %v %v", rcode, ips)
+	}
+	if rcode, _ := query(t, "udp", addr, "unknown.acme.mesh.", dnsmessage.TypeA); rcode != dnsmessage.RCodeNameError {
+		t.Errorf("Expected NXDOMAIN for unknown peer, got %v", rcode)
+	}
+
+	// Прочие имена пересылаются upstream
+	for _, network := range []string{"udp", "tcp"} {
+		rcode, ips := query(t, network, addr, "www.corp.", dnsmessage.TypeA)
+		if rcode != dnsmessage.RCodeSuccess || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.10")) {
+			t.Errorf("%s: unexpected forwarded answer %v %v", network, rcode, ips)
+		}
+	}
+
+	upstream.Stop()
+	if rcode, _ := query(t, "udp", addr, "www.corp.", dnsmessage.TypeA); rcode != dnsmessage.RCodeServerFailure {
+		t.Errorf("Expected SERVFAIL without upstream, got %v", rcode)
+	}
+
+	metrics := s.GetMetrics()
+	if metrics["nxdomain"] != 1 || metrics["forwarded"] != 2 || metrics["forward_failures"] != 1 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	original := []byte("search example.com\nnameserver 192.0.2.1\nnameserver 2001:db8::1\nnameserver bogus\n")
+	if servers := parseNameservers(original); strings.Join(servers, ",") != "192.0.2.1,2001:db8::1" {
+		t.Errorf("Unexpected nameservers: %v", servers)
+	}
+
+	updated := prependNameserver(original, net.ParseIP("10.0.0.1"))
+	if servers := parseNameservers(updated); len(servers) != 3 || servers[0] != "10.0.0.1" {
+		t.Errorf("Expected mesh DNS to be the first nameserver: %v", servers)
+	}
+	if !strings.HasPrefix(string(updated), resolvConfMarker) || !strings.HasSuffix(string(updated), string(original)) {
+		t.Errorf("Unexpected resolv.conf:\n%s", updated)
+	}
+}
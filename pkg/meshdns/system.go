@@ -0,0 +1,72 @@
+package meshdns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Способы настройки системного резолвера
+const (
+	ModeAuto       = "auto"       // systemd-resolved, если доступен, иначе resolv.conf
+	ModeResolved   = "resolved"   // split DNS через systemd-resolved: только зона mesh
+	ModeResolvConf = "resolvconf" // mesh DNS первым nameserver в /etc/resolv.conf
+)
+
+// resolvConfPath файл настроек резолвера
+const resolvConfPath = "/etc/resolv.conf"
+
+// resolvConfMarker отмечает строки, добавленные клиентом
+const resolvConfMarker = "# added by cloudbridge-client mesh DNS"
+
+// SystemNameservers возвращает nameserver из /etc/resolv.conf (nil — файла нет)
+func SystemNameservers() []string {
+	data, err := os.ReadFile(resolvConfPath)
+	if err != nil {
+		return nil
+	}
+	return parseNameservers(data)
+}
+
+func parseNameservers(data []byte) []string {
+	var servers []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// InterfaceForIP возвращает имя сетевого интерфейса, которому назначен адрес
+func InterfaceForIP(ip net.IP) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(ip) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no interface with address %s", ip)
+}
+
+// prependNameserver добавляет mesh DNS первым nameserver, сохраняя прежние как запасные
+func prependNameserver(original []byte, server net.IP) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\nnameserver %s\n", resolvConfMarker, server)
+	b.Write(original)
+	return b.Bytes()
+}
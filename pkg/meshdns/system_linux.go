@@ -0,0 +1,131 @@
+//go:build linux
+
+package meshdns
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ConfigureSystem направляет запросы зоны mesh системного резолвера на сервер mesh DNS.
+// Возвращает функцию, восстанавливающую прежние настройки.
+func ConfigureSystem(mode, iface string, server net.IP, zone string) (func() error, error) {
+	if mode == ModeAuto {
+		mode = ModeResolvConf
+		if _, err := os.Stat("/run/systemd/resolve"); err == nil {
+			mode = ModeResolved
+		}
+	}
+
+	switch mode {
+	case ModeResolved:
+		return configureResolved(callResolved, iface, server, zone)
+	case ModeResolvConf:
+		return configureResolvConf(server)
+	default:
+		return nil, fmt.Errorf("unsupported resolver configuration mode: %s", mode)
+	}
+}
+
+// API systemd-resolved на системной шине D-Bus
+const (
+	resolvedBusName    = "org.freedesktop.resolve1"
+	resolvedObjectPath = "/org/freedesktop/resolve1"
+	resolvedManager    = "org.freedesktop.resolve1.Manager"
+)
+
+// resolvedCall вызывает метод org.freedesktop.resolve1.Manager
+type resolvedCall func(method string, args ...interface{}) error
+
+// linkDNS адрес DNS сервера интерфейса (сигнатура D-Bus (iay))
+type linkDNS struct {
+	Family  int32
+	Address []byte
+}
+
+// linkDomain домен поиска или маршрутизирующий домен интерфейса (сигнатура D-Bus (sb))
+type linkDomain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+// callResolved вызывает метод systemd-resolved по отдельному соединению с системной шиной:
+// восстановление настроек выполняется много позже их установки
+func callResolved(method string, args ...interface{}) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck // one-shot connection
+	return conn.Object(resolvedBusName, resolvedObjectPath).Call(resolvedManager+"."+method, 0, args...).Err
+}
+
+// configureResolved задает split DNS на интерфейсе overlay: через mesh DNS идут только
+// имена зоны (маршрутизирующий домен ~zone)
+func configureResolved(call resolvedCall, iface string, server net.IP, zone string) (func() error, error) {
+	if iface == "" {
+		return nil, fmt.Errorf("overlay interface is required for systemd-resolved")
+	}
+	link, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, &Error{Op: "lookup link", Interface: iface, Err: err}
+	}
+	index := int32(link.Index) // #nosec G115 -- interface indexes fit in int32
+
+	dns := linkDNS{Family: syscall.AF_INET6, Address: server.To16()}
+	if ip4 := server.To4(); ip4 != nil {
+		dns = linkDNS{Family: syscall.AF_INET, Address: ip4}
+	}
+	domain := strings.TrimSuffix(zone, ".")
+
+	if err := call("SetLinkDNS", index, []linkDNS{dns}); err != nil {
+		return nil, &Error{Op: "set dns", Interface: iface, Target: server.String(), Err: err}
+	}
+	if err := call("SetLinkDomains", index, []linkDomain{{Domain: domain, RoutingOnly: true}}); err != nil {
+		_ = call("RevertLink", index) //nolint:errcheck // rollback after failed setup
+		return nil, &Error{Op: "set domain", Interface: iface, Target: "~" + domain, Err: err}
+	}
+
+	return func() error {
+		if err := call("RevertLink", index); err != nil {
+			return &Error{Op: "revert", Interface: iface, Err: err}
+		}
+		return nil
+	}, nil
+}
+
+// configureResolvConf добавляет mesh DNS первым nameserver в /etc/resolv.conf
+func configureResolvConf(server net.IP) (func() error, error) {
+	original, err := os.ReadFile(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", resolvConfPath, err)
+	}
+	info, err := os.Stat(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", resolvConfPath, err)
+	}
+	if err := os.WriteFile(resolvConfPath, prependNameserver(original, server), info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", resolvConfPath, err)
+	}
+
+	return func() error {
+		current, err := os.ReadFile(resolvConfPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", resolvConfPath, err)
+		}
+		// Файл переписал кто-то другой (DHCP, NetworkManager): не трогаем
+		if !bytes.HasPrefix(current, []byte(resolvConfMarker)) {
+			return nil
+		}
+		if err := os.WriteFile(resolvConfPath, original, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", resolvConfPath, err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,85 @@
+//go:build linux
+
+package meshdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeResolved записывает вызовы systemd-resolved и отклоняет метод fail
+type fakeResolved struct {
+	calls []string
+	fail  string
+}
+
+func (f *fakeResolved) call(method string, args ...interface{}) error {
+	f.calls = append(f.calls, fmt.Sprint(append([]interface{}{method}, args...)...))
+	if method == f.fail {
+		return dbus.Error{Name: "org.freedesktop.resolve1.LinkBusy", Body: []interface{}{"Link lo is managed"}}
+	}
+	return nil
+}
+
+func loopbackIndex(t *testing.T) int32 {
+	t.Helper()
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("No loopback interface: %v", err)
+	}
+	return int32(lo.Index) // #nosec G115 -- interface indexes fit in int32
+}
+
+func TestConfigureResolved(t *testing.T) {
+	index := loopbackIndex(t)
+	resolved := &fakeResolved{}
+	restore, err := configureResolved(resolved.call, "lo", net.ParseIP("10.8.0.1"), "mesh.")
+	if err != nil {
+		t.Fatalf("configureResolved failed: %v", err)
+	}
+	if err := restore(); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	want := []string{
+		fmt.Sprint("SetLinkDNS", index, []linkDNS{{Family: 2, Address: []byte{10, 8, 0, 1}}}),
+		fmt.Sprint("SetLinkDomains", index, []linkDomain{{Domain: "mesh", RoutingOnly: true}}),
+		fmt.Sprint("RevertLink", index),
+	}
+	if fmt.Sprint(resolved.calls) != fmt.Sprint(want) {
+		t.Errorf("Expected calls %v, got %v", want, resolved.calls)
+	}
+}
+
+// TestConfigureResolved_Error tests that a failed setup is rolled back and reported as *Error
+func TestConfigureResolved_Error(t *testing.T) {
+	index := loopbackIndex(t)
+	resolved := &fakeResolved{fail: "SetLinkDomains"}
+	_, err := configureResolved(resolved.call, "lo", net.ParseIP("fd00::1"), "mesh.")
+
+	var dnsErr *Error
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if dnsErr.Op != "set domain" || dnsErr.Interface != "lo" || dnsErr.Target != "~mesh" {
+		t.Errorf("Unexpected error fields: %+v", dnsErr)
+	}
+	var busErr dbus.Error
+	if !errors.As(err, &busErr) || busErr.Name != "org.freedesktop.resolve1.LinkBusy" {
+		t.Errorf("Expected D-Bus error, got %v", dnsErr.Err)
+	}
+	if last := resolved.calls[len(resolved.calls)-1]; last != fmt.Sprint("RevertLink", index) {
+		t.Errorf("Expected rollback after failed setup, got %v", resolved.calls)
+	}
+	if first := resolved.calls[0]; first != fmt.Sprint("SetLinkDNS", index, []linkDNS{{Family: 10, Address: net.ParseIP("fd00::1")}}) {
+		t.Errorf("Expected IPv6 DNS server, got %s", first)
+	}
+
+	if _, err := configureResolved(resolved.call, "missing0", net.ParseIP("10.8.0.1"), "mesh."); !errors.As(err, &dnsErr) || dnsErr.Op != "lookup link" {
+		t.Errorf("Expected lookup link error, got %v", err)
+	}
+}
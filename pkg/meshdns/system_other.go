@@ -0,0 +1,15 @@
+//go:build !linux
+
+package meshdns
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// ConfigureSystem направляет запросы зоны mesh системного резолвера на сервер mesh DNS.
+// Поддерживается только на Linux.
+func ConfigureSystem(mode, iface string, server net.IP, zone string) (func() error, error) {
+	return nil, fmt.Errorf("system resolver configuration is not supported on %s", runtime.GOOS)
+}
@@ -39,6 +39,7 @@ type Metrics struct {
 	// Required client metrics (объединены в одну метрику)
 	clientBytes   *prometheus.CounterVec
 	p2pSessions   prometheus.Gauge
+	p2pPaths      *prometheus.GaugeVec
 	transportMode prometheus.Gauge
 
 	// Prometheus metrics (без tunnel_id для снижения кардинальности)
@@ -105,10 +106,18 @@ func (m *Metrics) initPrometheusMetrics() {
 		},
 	)
 
+	m.p2pPaths = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "p2p_peer_paths",
+			Help: "Number of P2P connections by path type",
+		},
+		[]string{"path"}, // direct/turn/derp/relay
+	)
+
 	m.transportMode = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "transport_mode",
-			Help: "Current transport mode (0=QUIC, 1=WireGuard, 2=gRPC)",
+			Help: "Current transport mode (0=QUIC, 1=WireGuard)",
 		},
 	)
 
@@ -190,6 +199,7 @@ func (m *Metrics) initPrometheusMetrics() {
 	m.registry.MustRegister(
 		m.clientBytes,
 		m.p2pSessions,
+		m.p2pPaths,
 		m.transportMode,
 		m.bytesTransferred,
 		m.connectionsHandled,
@@ -440,8 +450,20 @@ func (m *Metrics) SetP2PSessions(count int) {
 	m.p2pSessions.Set(float64(count))
 }
 
+// SetP2PPaths sets the number of P2P connections per path type;
+// path types without connections are dropped
+func (m *Metrics) SetP2PPaths(counts map[string]int) {
+	if !m.enabled {
+		return
+	}
+	m.p2pPaths.Reset()
+	for path, count := range counts {
+		m.p2pPaths.WithLabelValues(path).Set(float64(count))
+	}
+}
+
 // SetTransportMode sets the current transport mode
-// 0=QUIC, 1=WireGuard, 2=gRPC
+// 0=QUIC, 1=WireGuard
 func (m *Metrics) SetTransportMode(mode int) {
 	if !m.enabled {
 		return
@@ -0,0 +1,502 @@
+// Package netcheck диагностирует сетевое окружение клиента: поведение NAT (RFC 5780),
+// доступность UDP и IPv6, hairpinning, MTU и задержки до relay POP.
+// Используется для разбора ситуаций, когда пиры не могут соединиться напрямую.
+package netcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Behavior поведение NAT при создании отображений или фильтрации входящих пакетов (RFC 5780)
+type Behavior string
+
+const (
+	BehaviorUnknown              Behavior = "unknown"
+	BehaviorEndpointIndependent  Behavior = "endpoint_independent"
+	BehaviorAddressDependent     Behavior = "address_dependent"
+	BehaviorAddressPortDependent Behavior = "address_and_port_dependent"
+	// BehaviorDependent отображение зависит от адреса назначения, но сервер без
+	// OTHER-ADDRESS не позволяет различить address и address+port зависимость
+	BehaviorDependent Behavior = "dependent"
+)
+
+// NATType классический тип NAT (RFC 3489), выводимый из mapping и filtering behavior
+type NATType string
+
+const (
+	NATUnknown            NATType = "unknown"
+	NATNone               NATType = "none"                 // публичный адрес, без трансляции
+	NATFullCone           NATType = "full_cone"            // EIM + EIF
+	NATRestrictedCone     NATType = "restricted_cone"      // EIM + address-dependent filtering
+	NATPortRestrictedCone NATType = "port_restricted_cone" // EIM + address/port-dependent filtering
+	NATSymmetric          NATType = "symmetric"            // отображение зависит от назначения
+)
+
+// POP relay POP, задержка до которого измеряется временем установления TCP соединения
+type POP struct {
+	ID      string `json:"id"`
+	Address string `json:"address"` // host:port
+}
+
+// Config конфигурация проверки
+type Config struct {
+	STUNServers    []string      `json:"stun_servers"` // host:port; для классификации желательно несколько
+	POPs           []POP         `json:"pops"`
+	Timeout        time.Duration `json:"timeout"`         // ожидание ответа на один STUN запрос
+	HairpinTimeout time.Duration `json:"hairpin_timeout"` // ожидание пакета, отправленного на свой внешний адрес
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout:        3 * time.Second,
+		HairpinTimeout: time.Second,
+	}
+}
+
+// Report результат проверки
+type Report struct {
+	UDP               bool                     `json:"udp"`                   // хотя бы один STUN сервер ответил по UDP
+	IPv4              bool                     `json:"ipv4"`                  // внешний IPv4 адрес определен
+	IPv6              bool                     `json:"ipv6"`                  // STUN по IPv6 прошел
+	GlobalV4          string                   `json:"global_v4,omitempty"`   // внешний IPv4 ip:port
+	GlobalV6          string                   `json:"global_v6,omitempty"`   // внешний IPv6 ip:port
+	LocalV4           string                   `json:"local_v4,omitempty"`    // локальный адрес сокета проверки
+	MappingBehavior   Behavior                 `json:"mapping_behavior"`      // RFC 5780, раздел 4.3
+	FilteringBehavior Behavior                 `json:"filtering_behavior"`    // RFC 5780, раздел 4.4
+	NATType           NATType                  `json:"nat_type"`              // классический тип NAT
+	Hairpinning       *bool                    `json:"hairpinning,omitempty"` // nil — не проверялось
+	Interface         string                   `json:"interface,omitempty"`   // интерфейс маршрута к STUN серверу
+	MTU               int                      `json:"mtu,omitempty"`         // MTU этого интерфейса
+	STUNLatency       map[string]time.Duration `json:"stun_latency"`          // RTT Binding запроса по серверам
+	POPLatency        map[string]time.Duration `json:"pop_latency"`           // время TCP connect по POP ID
+	Errors            []string                 `json:"errors,omitempty"`      // неудачные отдельные проверки
+	Duration          time.Duration            `json:"duration"`
+}
+
+// DirectConnectivity кратко оценивает шансы прямого соединения с пирами
+func (r *Report) DirectConnectivity() string {
+	switch {
+	case !r.UDP:
+		return "UDP is blocked: peers can only be reached through TURN over TCP, DERP or the relay"
+	case r.NATType == NATNone || r.NATType == NATFullCone:
+		return "good: peers can reach this host directly"
+	case r.NATType == NATRestrictedCone || r.NATType == NATPortRestrictedCone:
+		return "good: hole punching works with most peers"
+	case r.NATType == NATSymmetric:
+		return "poor: hole punching fails with peers behind restrictive NATs, expect TURN/DERP"
+	default:
+		return "unknown: NAT behaviour could not be determined"
+	}
+}
+
+// Checker выполняет проверки сети
+type Checker struct {
+	config *Config
+	logger Logger
+
+	mu     sync.Mutex
+	report *Report
+}
+
+// NewChecker создает Checker; незаданные таймауты берутся из DefaultConfig
+func NewChecker(config *Config, logger Logger) *Checker {
+	defaults := DefaultConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.HairpinTimeout <= 0 {
+		config.HairpinTimeout = defaults.HairpinTimeout
+	}
+	return &Checker{config: config, logger: logger}
+}
+
+// Run выполняет все проверки; ошибка возвращается только при некорректной конфигурации,
+// неудачи отдельных проверок перечисляются в Report.Errors
+func (c *Checker) Run(ctx context.Context) (*Report, error) {
+	if len(c.config.STUNServers) == 0 {
+		return nil, fmt.Errorf("no STUN servers configured")
+	}
+
+	start := time.Now()
+	c.report = &Report{
+		MappingBehavior:   BehaviorUnknown,
+		FilteringBehavior: BehaviorUnknown,
+		NATType:           NATUnknown,
+		STUNLatency:       make(map[string]time.Duration),
+		POPLatency:        make(map[string]time.Duration),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		c.checkIPv4(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		c.checkIPv6()
+	}()
+	go func() {
+		defer wg.Done()
+		c.checkPOPs(ctx)
+	}()
+	wg.Wait()
+
+	c.checkMTU()
+	c.report.NATType = classifyNAT(c.report)
+	c.report.Duration = time.Since(start)
+	sort.Strings(c.report.Errors)
+	return c.report, nil
+}
+
+// checkIPv4 определяет внешний адрес, mapping/filtering behavior и hairpinning
+func (c *Checker) checkIPv4(ctx context.Context) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		c.addError("failed to open UDP socket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Test I: Binding запрос к каждому серверу с одного сокета
+	var primary []serverResult
+	for _, server := range c.config.STUNServers {
+		if ctx.Err() != nil {
+			return
+		}
+		addr, err := net.ResolveUDPAddr("udp4", server)
+		if err != nil {
+			c.addError("failed to resolve STUN server %s: %v", server, err)
+			continue
+		}
+		result, err := bindingRequest(conn, addr, 0, c.config.Timeout)
+		if err != nil {
+			c.addError("STUN server %s: %v", server, err)
+			continue
+		}
+		primary = append(primary, serverResult{server: server, addr: addr, result: result})
+		c.setLatency(server, result.rtt)
+	}
+	if len(primary) == 0 {
+		return
+	}
+
+	first := primary[0].result.mapped
+	mapping := c.mappingBehavior(conn, primary)
+	filtering := c.filteringBehavior(primary)
+	hairpin := c.hairpinning(primary[0].addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.UDP = true
+	c.report.IPv4 = true
+	c.report.GlobalV4 = first.String()
+	c.report.LocalV4 = localAddress(conn, primary[0].addr)
+	c.report.MappingBehavior = mapping
+	c.report.FilteringBehavior = filtering
+	c.report.Hairpinning = hairpin
+}
+
+// serverResult ответ конкретного STUN сервера на Test I
+type serverResult struct {
+	server string
+	addr   *net.UDPAddr
+	result *bindingResult
+}
+
+// mappingBehavior классифицирует отображение NAT (RFC 5780, раздел 4.3). Если сервер
+// сообщает альтернативный адрес, используются Test II и III; иначе сравниваются
+// отображения для разных STUN серверов.
+func (c *Checker) mappingBehavior(conn *net.UDPConn, primary []serverResult) Behavior {
+	if alt := withOtherAddress(primary); alt != nil {
+		mapped := alt.result.mapped
+		// Test II: альтернативный IP, основной порт
+		test2, err := bindingRequest(conn, &net.UDPAddr{IP: alt.result.other.IP, Port: alt.addr.Port}, 0, c.config.Timeout)
+		if err == nil {
+			if sameAddr(test2.mapped, mapped) {
+				return BehaviorEndpointIndependent
+			}
+			// Test III: альтернативный IP и порт
+			test3, err := bindingRequest(conn, alt.result.other, 0, c.config.Timeout)
+			if err == nil {
+				if sameAddr(test3.mapped, test2.mapped) {
+					return BehaviorAddressDependent
+				}
+				return BehaviorAddressPortDependent
+			}
+		}
+		c.addError("mapping test with alternate address of %s failed: %v", alt.server, err)
+	}
+
+	var distinct []serverResult
+	for _, r := range primary {
+		if len(distinct) == 0 || !r.addr.IP.Equal(distinct[0].addr.IP) {
+			distinct = append(distinct, r)
+		}
+	}
+	if len(distinct) < 2 {
+		return BehaviorUnknown
+	}
+	for _, r := range distinct[1:] {
+		if !sameAddr(r.result.mapped, distinct[0].result.mapped) {
+			return BehaviorDependent
+		}
+	}
+	return BehaviorEndpointIndependent
+}
+
+// filteringBehavior классифицирует фильтрацию NAT (RFC 5780, раздел 4.4); нужен сервер,
+// поддерживающий CHANGE-REQUEST. Используется отдельный сокет: запросы mapping тестов
+// на альтернативный адрес открыли бы для него фильтр NAT.
+func (c *Checker) filteringBehavior(primary []serverResult) Behavior {
+	alt := withOtherAddress(primary)
+	if alt == nil {
+		return BehaviorUnknown
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		c.addError("failed to open UDP socket: %v", err)
+		return BehaviorUnknown
+	}
+	defer conn.Close()
+
+	// Test I: открывает фильтр только для основного адреса сервера
+	if _, err := bindingRequest(conn, alt.addr, 0, c.config.Timeout); err != nil {
+		c.addError("filtering test with %s failed: %v", alt.server, err)
+		return BehaviorUnknown
+	}
+
+	// Test II: ответ с другого IP и порта
+	_, err = bindingRequest(conn, alt.addr, changeIPFlag|changePortFlag, c.config.Timeout)
+	if err == nil {
+		return BehaviorEndpointIndependent
+	}
+	if !errors.Is(err, errNoResponse) {
+		c.addError("filtering test with %s failed: %v", alt.server, err)
+		return BehaviorUnknown
+	}
+
+	// Test III: ответ с того же IP, но другого порта
+	_, err = bindingRequest(conn, alt.addr, changePortFlag, c.config.Timeout)
+	if err == nil {
+		return BehaviorAddressDependent
+	}
+	if !errors.Is(err, errNoResponse) {
+		c.addError("filtering test with %s failed: %v", alt.server, err)
+		return BehaviorUnknown
+	}
+	return BehaviorAddressPortDependent
+}
+
+// hairpinning проверяет, доставляет ли NAT пакет, отправленный изнутри на собственный
+// внешний адрес: второй сокет отправляет пакет на отображение первого
+func (c *Checker) hairpinning(server *net.UDPAddr) *bool {
+	receiver, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		c.addError("hairpin check failed: %v", err)
+		return nil
+	}
+	defer receiver.Close()
+
+	result, err := bindingRequest(receiver, server, 0, c.config.Timeout)
+	if err != nil {
+		c.addError("hairpin check failed: %v", err)
+		return nil
+	}
+
+	sender, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		c.addError("hairpin check failed: %v", err)
+		return nil
+	}
+	defer sender.Close()
+
+	probe := []byte("cloudbridge-hairpin")
+	if _, err := sender.WriteToUDP(probe, result.mapped); err != nil {
+		c.addError("hairpin check failed: %v", err)
+		return nil
+	}
+
+	ok := false
+	buf := make([]byte, 1500)
+	_ = receiver.SetReadDeadline(time.Now().Add(c.config.HairpinTimeout)) //nolint:errcheck // read fails without a deadline anyway
+	for {
+		n, _, err := receiver.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		if string(buf[:n]) == string(probe) {
+			ok = true
+			break
+		}
+	}
+	return &ok
+}
+
+// checkIPv6 проверяет STUN по IPv6 на первом сервере с AAAA записью
+func (c *Checker) checkIPv6() {
+	conn, err := net.ListenUDP("udp6", nil)
+	if err != nil {
+		return // IPv6 стек недоступен
+	}
+	defer conn.Close()
+
+	for _, server := range c.config.STUNServers {
+		addr, err := net.ResolveUDPAddr("udp6", server)
+		if err != nil || addr.IP.To4() != nil {
+			continue
+		}
+		result, err := bindingRequest(conn, addr, 0, c.config.Timeout)
+		if err != nil {
+			c.addError("STUN server %s over IPv6: %v", server, err)
+			continue
+		}
+
+		c.mu.Lock()
+		c.report.IPv6 = true
+		c.report.UDP = true
+		c.report.GlobalV6 = result.mapped.String()
+		c.mu.Unlock()
+		return
+	}
+}
+
+// checkPOPs измеряет задержку до relay POP временем установления TCP соединения
+func (c *Checker) checkPOPs(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pop := range c.config.POPs {
+		wg.Add(1)
+		go func(pop POP) {
+			defer wg.Done()
+			dialCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			var d net.Dialer
+			conn, err := d.DialContext(dialCtx, "tcp", pop.Address)
+			if err != nil {
+				c.addError("POP %s (%s): %v", pop.ID, pop.Address, err)
+				return
+			}
+			latency := time.Since(start)
+			_ = conn.Close() //nolint:errcheck // probe connection
+
+			c.mu.Lock()
+			c.report.POPLatency[pop.ID] = latency
+			c.mu.Unlock()
+		}(pop)
+	}
+	wg.Wait()
+}
+
+// checkMTU определяет MTU интерфейса, через который идет трафик к первому STUN серверу
+func (c *Checker) checkMTU() {
+	conn, err := net.Dial("udp", c.config.STUNServers[0])
+	if err != nil {
+		return
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close() //nolint:errcheck // connected socket is only used for route lookup
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		c.addError("failed to list network interfaces: %v", err)
+		return
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				c.mu.Lock()
+				c.report.Interface = iface.Name
+				c.report.MTU = iface.MTU
+				c.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func (c *Checker) setLatency(server string, rtt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.STUNLatency[server] = rtt
+}
+
+func (c *Checker) addError(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	c.logger.Debug("Netcheck probe failed", "error", msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Errors = append(c.report.Errors, msg)
+}
+
+// classifyNAT выводит классический тип NAT из результатов проверок
+func classifyNAT(r *Report) NATType {
+	if !r.IPv4 {
+		return NATUnknown
+	}
+	if r.LocalV4 != "" && r.LocalV4 == r.GlobalV4 {
+		return NATNone
+	}
+	switch r.MappingBehavior {
+	case BehaviorAddressDependent, BehaviorAddressPortDependent, BehaviorDependent:
+		return NATSymmetric
+	case BehaviorEndpointIndependent:
+		switch r.FilteringBehavior {
+		case BehaviorEndpointIndependent:
+			return NATFullCone
+		case BehaviorAddressDependent:
+			return NATRestrictedCone
+		case BehaviorAddressPortDependent:
+			return NATPortRestrictedCone
+		}
+	}
+	return NATUnknown
+}
+
+// withOtherAddress возвращает первый сервер, сообщивший альтернативный адрес
+func withOtherAddress(results []serverResult) *serverResult {
+	for i := range results {
+		other := results[i].result.other
+		if other != nil && !other.IP.Equal(results[i].addr.IP) {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// localAddress возвращает локальный ip:port сокета conn для маршрута к server
+func localAddress(conn *net.UDPConn, server *net.UDPAddr) string {
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	probe, err := net.DialUDP("udp4", nil, server)
+	if err != nil {
+		return ""
+	}
+	defer probe.Close()
+	return net.JoinHostPort(probe.LocalAddr().(*net.UDPAddr).IP.String(), strconv.Itoa(port))
+}
+
+func sameAddr(a, b *net.UDPAddr) bool {
+	return a != nil && b != nil && a.IP.Equal(b.IP) && a.Port == b.Port
+}
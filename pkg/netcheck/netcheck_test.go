@@ -0,0 +1,207 @@
+package netcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+// testSTUNServer STUN сервер RFC 5780 на двух IP и двух портах loopback
+type testSTUNServer struct {
+	socks        [2][2]*net.UDPConn // [ip][port]
+	honorChange  bool               // false — CHANGE-REQUEST игнорируется (ответ не отправляется)
+	otherAddress bool
+}
+
+func startTestSTUNServer(t *testing.T, honorChange, otherAddress bool) *testSTUNServer {
+	t.Helper()
+	s := &testSTUNServer{honorChange: honorChange, otherAddress: otherAddress}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 2)}
+	for i, ip := range ips {
+		for j := range 2 {
+			port := 0
+			if i == 1 {
+				port = s.socks[0][j].LocalAddr().(*net.UDPAddr).Port
+			}
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ip, Port: port})
+			if err != nil {
+				t.Skipf("Second loopback address unavailable: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() }) //nolint:errcheck // test cleanup
+			s.socks[i][j] = conn
+		}
+	}
+	for i := range 2 {
+		for j := range 2 {
+			go s.serve(i, j)
+		}
+	}
+	return s
+}
+
+func (s *testSTUNServer) addr(ip, port int) string {
+	return s.socks[ip][port].LocalAddr().String()
+}
+
+func (s *testSTUNServer) serve(ip, port int) {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.socks[ip][port].ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if err := req.Decode(); err != nil || req.Type != stun.BindingRequest {
+			continue
+		}
+
+		respIP, respPort := ip, port
+		if value, err := req.Get(stun.AttrChangeRequest); err == nil && len(value) == 4 {
+			if !s.honorChange {
+				continue
+			}
+			flags := binary.BigEndian.Uint32(value)
+			if flags&changeIPFlag != 0 {
+				respIP = 1 - ip
+			}
+			if flags&changePortFlag != 0 {
+				respPort = 1 - port
+			}
+		}
+
+		setters := []stun.Setter{
+			stun.NewTransactionIDSetter(req.TransactionID),
+			stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: from.IP, Port: from.Port},
+		}
+		if s.otherAddress {
+			other := s.socks[1-ip][1-port].LocalAddr().(*net.UDPAddr)
+			setters = append(setters, &otherAddressSetter{stun.MappedAddress{IP: other.IP, Port: other.Port}})
+		}
+		resp, err := stun.Build(setters...)
+		if err != nil {
+			continue
+		}
+		_, _ = s.socks[respIP][respPort].WriteToUDP(resp.Raw, from) //nolint:errcheck // test server
+	}
+}
+
+type otherAddressSetter struct{ stun.MappedAddress }
+
+func (a *otherAddressSetter) AddTo(m *stun.Message) error {
+	return a.AddToAs(m, stun.AttrOtherAddress)
+}
+
+func TestChecker_OpenInternet(t *testing.T) {
+	server := startTestSTUNServer(t, true, true)
+	pop, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer pop.Close()
+
+	checker := NewChecker(&Config{
+		STUNServers: []string{server.addr(0, 0)},
+		POPs:        []POP{{ID: "local", Address: pop.Addr().String()}},
+		Timeout:     time.Second,
+	}, testLogger{})
+	report, err := checker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !report.UDP || !report.IPv4 {
+		t.Fatalf("Expected UDP to work, errors: %v", report.Errors)
+	}
+	if report.MappingBehavior != BehaviorEndpointIndependent || report.FilteringBehavior != BehaviorEndpointIndependent {
+		t.Errorf("Expected endpoint-independent mapping and filtering, got %s/%s",
+			report.MappingBehavior, report.FilteringBehavior)
+	}
+	if report.NATType != NATNone {
+		t.Errorf("Expected no NAT on loopback, got %s", report.NATType)
+	}
+	if report.Hairpinning == nil || !*report.Hairpinning {
+		t.Error("Expected hairpinning to work on loopback")
+	}
+	if _, ok := report.POPLatency["local"]; !ok {
+		t.Error("Expected POP latency to be measured")
+	}
+	if _, ok := report.STUNLatency[server.addr(0, 0)]; !ok {
+		t.Error("Expected STUN latency to be measured")
+	}
+}
+
+func TestChecker_FilteringWithoutChangeRequest(t *testing.T) {
+	server := startTestSTUNServer(t, false, true)
+
+	checker := NewChecker(&Config{STUNServers: []string{server.addr(0, 0)}, Timeout: 300 * time.Millisecond}, testLogger{})
+	report, err := checker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.FilteringBehavior != BehaviorAddressPortDependent {
+		t.Errorf("Expected address and port dependent filtering, got %s", report.FilteringBehavior)
+	}
+}
+
+func TestChecker_MappingAcrossServers(t *testing.T) {
+	// Без OTHER-ADDRESS mapping определяется сравнением ответов разных серверов
+	server := startTestSTUNServer(t, false, false)
+
+	checker := NewChecker(&Config{
+		STUNServers: []string{server.addr(0, 0), server.addr(1, 0)},
+		Timeout:     time.Second,
+	}, testLogger{})
+	report, err := checker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.MappingBehavior != BehaviorEndpointIndependent {
+		t.Errorf("Expected endpoint-independent mapping, got %s", report.MappingBehavior)
+	}
+	if report.FilteringBehavior != BehaviorUnknown {
+		t.Errorf("Expected unknown filtering without OTHER-ADDRESS, got %s", report.FilteringBehavior)
+	}
+}
+
+func TestClassifyNAT(t *testing.T) {
+	tests := []struct {
+		mapping, filtering Behavior
+		want               NATType
+	}{
+		{BehaviorEndpointIndependent, BehaviorEndpointIndependent, NATFullCone},
+		{BehaviorEndpointIndependent, BehaviorAddressDependent, NATRestrictedCone},
+		{BehaviorEndpointIndependent, BehaviorAddressPortDependent, NATPortRestrictedCone},
+		{BehaviorEndpointIndependent, BehaviorUnknown, NATUnknown},
+		{BehaviorAddressPortDependent, BehaviorAddressPortDependent, NATSymmetric},
+		{BehaviorDependent, BehaviorUnknown, NATSymmetric},
+	}
+
+	for _, tt := range tests {
+		report := &Report{
+			IPv4:              true,
+			LocalV4:           "192.168.1.10:40000",
+			GlobalV4:          "203.0.113.5:61000",
+			MappingBehavior:   tt.mapping,
+			FilteringBehavior: tt.filtering,
+		}
+		if got := classifyNAT(report); got != tt.want {
+			t.Errorf("classifyNAT(%s, %s) = %s, want %s", tt.mapping, tt.filtering, got, tt.want)
+		}
+	}
+
+	if got := classifyNAT(&Report{}); got != NATUnknown {
+		t.Errorf("Expected unknown NAT without IPv4 result, got %s", got)
+	}
+}
@@ -0,0 +1,124 @@
+package netcheck
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pion/stun"
+)
+
+// Флаги атрибута CHANGE-REQUEST (RFC 5780, раздел 7.2)
+const (
+	changeIPFlag   uint32 = 0x04
+	changePortFlag uint32 = 0x02
+)
+
+// stunRetransmitInterval интервал повторной отправки запроса (UDP без гарантии доставки)
+const stunRetransmitInterval = 500 * time.Millisecond
+
+// errNoResponse сервер не ответил за отведенное время
+var errNoResponse = errors.New("no STUN response")
+
+// bindingResult ответ на STUN Binding запрос
+type bindingResult struct {
+	mapped *net.UDPAddr  // адрес клиента, видимый серверу (XOR-MAPPED-ADDRESS)
+	other  *net.UDPAddr  // альтернативный адрес сервера (OTHER-ADDRESS / CHANGED-ADDRESS)
+	rtt    time.Duration // время от первой отправки до ответа
+}
+
+// bindingRequest отправляет Binding запрос с сокета conn и ждет ответа с тем же transaction ID.
+// conn не привязан к адресу сервера: ответы с измененного адреса (CHANGE-REQUEST) тоже принимаются.
+func bindingRequest(conn *net.UDPConn, server *net.UDPAddr, change uint32, timeout time.Duration) (*bindingResult, error) {
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if change != 0 {
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, change)
+		setters = append(setters, stun.RawAttribute{Type: stun.AttrChangeRequest, Value: value})
+	}
+	request, err := stun.Build(setters...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build STUN request: %w", err)
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	buf := make([]byte, 1500)
+	for {
+		if _, err := conn.WriteToUDP(request.Raw, server); err != nil {
+			return nil, fmt.Errorf("failed to send STUN request: %w", err)
+		}
+
+		retransmitAt := time.Now().Add(stunRetransmitInterval)
+		if retransmitAt.After(deadline) {
+			retransmitAt = deadline
+		}
+		response, err := readResponse(conn, request.TransactionID, buf, retransmitAt)
+		if err == nil {
+			result := &bindingResult{rtt: time.Since(start)}
+			if err := parseBindingResponse(response, result); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, errNoResponse
+		}
+	}
+}
+
+// readResponse читает пакеты до ответа с transactionID или до deadline; чужие пакеты пропускаются
+func readResponse(conn *net.UDPConn, transactionID [stun.TransactionIDSize]byte, buf []byte,
+	deadline time.Time) (*stun.Message, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, err
+		}
+		if !stun.IsMessage(buf[:n]) {
+			continue
+		}
+		msg := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if err := msg.Decode(); err != nil || msg.TransactionID != transactionID {
+			continue
+		}
+		return msg, nil
+	}
+}
+
+// parseBindingResponse извлекает mapped и альтернативный адреса из ответа сервера
+func parseBindingResponse(msg *stun.Message, result *bindingResult) error {
+	if msg.Type != stun.BindingSuccess {
+		return fmt.Errorf("unexpected STUN response type: %v", msg.Type)
+	}
+
+	var xorAddr stun.XORMappedAddress
+	if err := xorAddr.GetFrom(msg); err == nil {
+		result.mapped = &net.UDPAddr{IP: xorAddr.IP, Port: xorAddr.Port}
+	} else {
+		// Серверы RFC 3489 возвращают только MAPPED-ADDRESS
+		var addr stun.MappedAddress
+		if err := addr.GetFrom(msg); err != nil {
+			return fmt.Errorf("failed to get mapped address: %w", err)
+		}
+		result.mapped = &net.UDPAddr{IP: addr.IP, Port: addr.Port}
+	}
+
+	for _, attr := range []stun.AttrType{stun.AttrOtherAddress, stun.AttrChangedAddress} {
+		var other stun.MappedAddress
+		if err := other.GetFromAs(msg, attr); err == nil && other.Port != 0 {
+			result.other = &net.UDPAddr{IP: other.IP, Port: other.Port}
+			break
+		}
+	}
+	return nil
+}
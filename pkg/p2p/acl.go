@@ -0,0 +1,136 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
+)
+
+// SetACL включает политику доступа mesh из локального файла и relay API
+// в дополнение к политике из JWT; вызывается до Start
+func (m *Manager) SetACL(config *acl.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aclConfig = config
+}
+
+// startACL загружает политику доступа: локальный файл, relay API, JWT.
+// Вызывается из Start под m.mu.
+func (m *Manager) startACL() {
+	if m.aclConfig == nil && len(m.config.ACLPolicy) == 0 {
+		return
+	}
+
+	policies := acl.NewManager(m.aclConfig, m.logger)
+	if err := policies.SetPolicy(acl.SourceJWT, m.config.ACLPolicy); err != nil {
+		m.logger.Warn("Ignoring ACL policy from token", "error", err)
+	}
+	if m.apiManager != nil {
+		apiManager := m.apiManager
+		policies.SetFetcher(func(ctx context.Context) ([]byte, error) {
+			return apiManager.GetACLPolicy(ctx)
+		})
+	}
+	// Смена политики закрывает соединения, которые она больше не разрешает
+	policies.OnChange(func(*acl.Engine) {
+		go m.enforceACL()
+	})
+	if err := policies.Start(); err != nil {
+		m.logger.Warn("Failed to start ACL policy sources", "error", err)
+	}
+	m.acl = policies
+}
+
+// stopACL останавливает перечитывание политики
+func (m *Manager) stopACL() {
+	m.mu.RLock()
+	policies := m.acl
+	m.mu.RUnlock()
+	if policies != nil {
+		policies.Stop()
+	}
+}
+
+// aclEngine возвращает действующую политику (nil — все разрешено)
+func (m *Manager) aclEngine() *acl.Engine {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.acl.Engine()
+}
+
+// checkInboundACL проверяет, разрешает ли политика пиру соединение с локальным пиром.
+// Соединение допускается, если пиру открыт хотя бы один порт; протокол и порт
+// каждого пакета проверяет FilterOverlayPacket на устройстве WireGuard.
+func (m *Manager) checkInboundACL(peerID string) error {
+	engine := m.aclEngine()
+	if engine == nil {
+		return nil
+	}
+	m.mu.RLock()
+	localPeerID := m.peerID
+	m.mu.RUnlock()
+
+	decision := engine.Evaluate(acl.Flow{Src: acl.Identity{ID: peerID}, Dst: acl.Identity{ID: localPeerID}})
+	if !decision.Allowed {
+		return fmt.Errorf("%w: %s", acl.ErrDenied, decision)
+	}
+	return nil
+}
+
+// enforceACL закрывает входящие сессии пиров, которым политика больше не разрешает соединение
+func (m *Manager) enforceACL() {
+	m.mu.RLock()
+	inbound := make([]string, 0, len(m.sessions))
+	for peerID, session := range m.sessions {
+		if session.Inbound {
+			inbound = append(inbound, peerID)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, peerID := range inbound {
+		if err := m.checkInboundACL(peerID); err != nil {
+			m.logger.Warn("Closing P2P session denied by ACL policy", "peer_id", peerID, "error", err)
+			if err := m.ClosePeerSession(peerID); err != nil {
+				m.logger.Debug("Failed to close denied session", "peer_id", peerID, "error", err)
+			}
+		}
+	}
+}
+
+// FilterOverlayPacket проверяет IP пакет overlay сети, полученный от пира, по политике
+// доступа. Отправитель определяется по адресу источника среди AllowedIPs пиров mesh;
+// пакет от неизвестного адреса при действующей политике отбрасывается. Ответы на
+// соединения, открытые локальным пиром, устройство WireGuard пропускает по таблице
+// потоков, не вызывая фильтр.
+func (m *Manager) FilterOverlayPacket(packet []byte) error {
+	engine := m.aclEngine()
+	if engine == nil {
+		return nil
+	}
+	info, err := acl.ParsePacket(packet)
+	if err != nil {
+		return fmt.Errorf("failed to parse overlay packet: %w", err)
+	}
+
+	m.mu.RLock()
+	localPeerID, mesh := m.peerID, m.mesh
+	m.mu.RUnlock()
+	var srcPeer string
+	if mesh != nil {
+		srcPeer, _ = mesh.PeerForIP(info.Src)
+	}
+	if srcPeer == "" {
+		return fmt.Errorf("%w: unknown source %s", acl.ErrDenied, info.Src)
+	}
+
+	decision, err := engine.EvaluatePacket(acl.Identity{ID: srcPeer}, acl.Identity{ID: localPeerID}, packet)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate overlay packet: %w", err)
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("%w: %s %s -> %s/%d: %s", acl.ErrDenied, srcPeer, info.Src, info.Proto, info.Port, decision)
+	}
+	return nil
+}
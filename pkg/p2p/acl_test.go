@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
+)
+
+func TestManager_ACLEnforcement(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID = "db1"
+	m.mesh = NewMeshNetwork(&MeshConfig{}, NewSimpleLogger("test"))
+	m.mesh.UpsertPeer(&Peer{ID: "app1", IsConnected: true, AllowedIPs: []string{"10.0.0.2/32"}})
+	m.mesh.UpsertPeer(&Peer{ID: "web", IsConnected: true, AllowedIPs: []string{"10.0.0.0/24"}})
+
+	// Без политики разрешено все
+	if err := m.checkInboundACL("web"); err != nil {
+		t.Errorf("Expected no policy to allow everything, got %v", err)
+	}
+
+	m.acl = acl.NewManager(nil, m.logger)
+	if err := m.acl.SetPolicy(acl.SourceJWT, []byte(`{
+		"tags": {"tag:db": ["db1"]},
+		"acls": [{"src": ["app1"], "dst": ["tag:db"], "ports": ["tcp/5432"]}]
+	}`)); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+
+	if err := m.checkInboundACL("app1"); err != nil {
+		t.Errorf("Expected app1 to be allowed, got %v", err)
+	}
+	if err := m.checkInboundACL("web"); !errors.Is(err, acl.ErrDenied) {
+		t.Errorf("Expected web to be denied, got %v", err)
+	}
+
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = 6 // TCP
+	copy(packet[12:16], []byte{10, 0, 0, 2})
+	copy(packet[16:20], []byte{10, 0, 0, 1})
+	packet[22], packet[23] = 0x15, 0x38 // 5432
+	if err := m.FilterOverlayPacket(packet); err != nil {
+		t.Errorf("Expected postgres packet from app1 to pass, got %v", err)
+	}
+
+	// Более широкая подсеть web не перекрывает адрес app1, остальные адреса принадлежат web
+	copy(packet[12:16], []byte{10, 0, 0, 9})
+	if err := m.FilterOverlayPacket(packet); !errors.Is(err, acl.ErrDenied) {
+		t.Errorf("Expected packet from web to be denied, got %v", err)
+	}
+	copy(packet[12:16], []byte{192, 168, 1, 1})
+	if err := m.FilterOverlayPacket(packet); !errors.Is(err, acl.ErrDenied) {
+		t.Errorf("Expected packet from unknown source to be denied, got %v", err)
+	}
+
+	// Встроенный WireGuard проверяет политикой каждый пакет пиров
+	m.wgDeviceConfig = wireguard.DefaultConfig()
+	filter := m.userspaceWireGuardConfigLocked().InboundFilter
+	if filter == nil {
+		t.Fatal("Expected userspace WireGuard to filter inbound packets")
+	}
+	copy(packet[12:16], []byte{10, 0, 0, 2})
+	if err := filter(packet); err != nil {
+		t.Errorf("Expected postgres packet from app1 to pass the device filter, got %v", err)
+	}
+	packet[22], packet[23] = 0, 22
+	if err := filter(packet); !errors.Is(err, acl.ErrDenied) {
+		t.Errorf("Expected ssh packet from app1 to be dropped by the device filter, got %v", err)
+	}
+	if m.wgDeviceConfig.InboundFilter != nil {
+		t.Error("Expected configured WireGuard settings to stay unchanged")
+	}
+
+	// Смена политики закрывает входящие сессии, которые она больше не разрешает
+	if _, err := m.newSession("app1", "s1", true); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := m.newSession("web", "s2", true); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	m.enforceACL()
+	if _, ok := m.GetPeerSession("web"); ok {
+		t.Error("Expected denied inbound session to be closed")
+	}
+	if _, ok := m.GetPeerSession("app1"); !ok {
+		t.Error("Expected allowed inbound session to stay open")
+	}
+}
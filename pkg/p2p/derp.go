@@ -0,0 +1,292 @@
+package p2p
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/derp"
+	pionice "github.com/pion/ice/v2"
+)
+
+// directUpgradeInterval период попыток перевести соединения через DERP/relay на прямой путь
+const directUpgradeInterval = 2 * time.Minute
+
+// SetDERPServers задает DERP серверы (host:port или ws(s):// URL) для пересылки пакетов
+// пирам, если прямое ICE соединение не удалось
+func (m *Manager) SetDERPServers(servers []string, insecureSkipVerify bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.derpServers = servers
+	m.derpTLS = insecureSkipVerify
+}
+
+// startDERP подключает DERP клиент с публичным ключом пира. Вызывается из Start под m.mu.
+func (m *Manager) startDERP() {
+	if len(m.derpServers) == 0 {
+		return
+	}
+	publicKey := m.apiManager.GetPublicKey()
+	if publicKey == "" {
+		m.logger.Warn("Peer public key unknown, DERP fallback disabled")
+		return
+	}
+
+	client := derp.NewClient(&derp.Config{
+		Servers:            m.derpServers,
+		PublicKey:          publicKey,
+		Token:              m.token,
+		InsecureSkipVerify: m.derpTLS,
+	}, m.logger)
+	if err := client.Start(); err != nil {
+		m.logger.Warn("Failed to start DERP client, DERP fallback disabled", "error", err)
+		return
+	}
+	m.derp = client
+	m.logger.Info("DERP fallback enabled", "servers", m.derpServers)
+}
+
+// derpClient возвращает DERP клиент (nil — DERP не настроен)
+func (m *Manager) derpClient() *derp.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.derp
+}
+
+// rememberPeerKey запоминает публичный ключ пира — его адрес в DERP relay и ключ идентичности.
+// signature — подпись смены ключа прежним ключом пира. Возвращает ключ, который действует
+// для пира: закрепленный ключ relay заменить не может.
+func (m *Manager) rememberPeerKey(peerID, publicKey, signature string) string {
+	if peerID == "" || publicKey == "" {
+		return publicKey
+	}
+	publicKey = m.trustedPeerKey(peerID, publicKey, signature)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peerKeys[peerID] = publicKey
+	return publicKey
+}
+
+// peerPublicKey возвращает публичный ключ пира; неизвестные ключи запрашиваются через discovery
+func (m *Manager) peerPublicKey(peerID string) (string, error) {
+	m.mu.RLock()
+	key, ok := m.peerKeys[peerID]
+	m.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := m.refreshPeerKeys(); err != nil {
+		return "", err
+	}
+	m.mu.RLock()
+	key = m.peerKeys[peerID]
+	m.mu.RUnlock()
+	if key == "" {
+		return "", fmt.Errorf("public key of peer %s unknown", peerID)
+	}
+	return key, nil
+}
+
+// refreshPeerKeys перечитывает публичные ключи пиров через discovery
+func (m *Manager) refreshPeerKeys() error {
+	if m.apiManager == nil {
+		return fmt.Errorf("API manager not available")
+	}
+	resp, err := m.apiManager.DiscoverPeers()
+	if err != nil {
+		return fmt.Errorf("failed to discover peers: %w", err)
+	}
+	for _, peer := range resp.Peers {
+		m.rememberPeerKey(peer.PeerID, peer.PublicKey, peer.KeySignature)
+	}
+	return nil
+}
+
+// derpPeerConn открывает DERP соединение с пиром и закрепляет его за сессией
+func (m *Manager) derpPeerConn(session *PeerSession) (*derp.PeerConn, error) {
+	client := m.derpClient()
+	if client == nil {
+		return nil, fmt.Errorf("DERP not configured")
+	}
+	if !client.IsConnected() {
+		return nil, fmt.Errorf("DERP relay not connected")
+	}
+	key, err := m.peerPublicKey(session.PeerID)
+	if err != nil {
+		return nil, err
+	}
+	pconn, err := client.PeerConn(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сокет закрывается вместе с сессией, в том числе при неудачном handshake
+	session.mu.Lock()
+	session.pathConn = pconn
+	session.mu.Unlock()
+	return pconn, nil
+}
+
+// establishDERPConnection устанавливает QUIC соединение с пиром через DERP relay
+func (m *Manager) establishDERPConnection(session *PeerSession) error {
+	pconn, err := m.derpPeerConn(session)
+	if err != nil {
+		return err
+	}
+
+	quicConn, stream, err := m.dialPeerQUIC(session, pconn, pconn.RemoteAddr())
+	if err != nil {
+		return fmt.Errorf("failed to dial peer over DERP: %w", err)
+	}
+	if err := m.registerConnection(session, quicConn, true, stream, PathDERP); err != nil {
+		return err
+	}
+
+	m.logger.Info("P2P connection established via DERP", "peer_id", session.PeerID, "session_id", session.SessionID)
+	return nil
+}
+
+// acceptDERPConnection принимает QUIC соединение пира, пришедшее через DERP relay
+func (m *Manager) acceptDERPConnection(session *PeerSession) error {
+	pconn, err := m.derpPeerConn(session)
+	if err != nil {
+		return err
+	}
+
+	quicConn, stream, err := m.acceptPeerQUIC(session, pconn)
+	if err != nil {
+		return err
+	}
+	if err := m.registerConnection(session, quicConn, true, stream, PathDERP); err != nil {
+		return err
+	}
+
+	m.logger.Info("Inbound P2P connection established via DERP", "peer_id", session.PeerID, "session_id", session.SessionID)
+	return nil
+}
+
+// fallbackInbound переводит входящую сессию на DERP, если прямое соединение не удалось.
+// Инициатор в этом случае сам выбирает DERP (или relay stream, если DERP недоступен).
+func (m *Manager) fallbackInbound(session *PeerSession, cause error) error {
+	if session.upgrade || m.derpClient() == nil || m.routingPolicy() == RoutingDirect {
+		return m.failSession(session, cause)
+	}
+
+	m.logger.Warn("Direct P2P connection failed, waiting for peer via DERP",
+		"peer_id", session.PeerID,
+		"session_id", session.SessionID,
+		"error", cause)
+
+	if err := session.releaseICE(); err != nil {
+		m.logger.Debug("Failed to release ICE resources", "peer_id", session.PeerID, "error", err)
+	}
+	if err := m.acceptDERPConnection(session); err != nil {
+		return m.failSession(session, fmt.Errorf("%v; DERP fallback failed: %w", cause, err))
+	}
+	return nil
+}
+
+// newProbeSession создает пробную сессию перевода соединения на прямой путь.
+// Она не входит в m.sessions, пока не установлена: текущее соединение продолжает работать.
+func (m *Manager) newProbeSession(peerID, sessionID string, inbound bool) (*PeerSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.probes[peerID]; ok {
+		return nil, fmt.Errorf("%w: upgrade of %s", ErrSessionInProgress, peerID)
+	}
+	session := newPeerSession(m.ctx, peerID, sessionID, inbound, m.logger)
+	session.upgrade = true
+	session.onICEState = func(state pionice.ConnectionState) {
+		m.handleICEState(session, state)
+	}
+	m.probes[peerID] = session
+	return session, nil
+}
+
+// removeProbe удаляет пробную сессию из списка активных попыток
+func (m *Manager) removeProbe(session *PeerSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.probes[session.PeerID]; ok && current == session {
+		delete(m.probes, session.PeerID)
+	}
+}
+
+// startPathUpgrader периодически пытается перевести исходящие соединения
+// через DERP/relay на прямой путь (direct или TURN)
+func (m *Manager) startPathUpgrader() {
+	ticker := time.NewTicker(directUpgradeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			// Relay маршрутизация mesh намеренно держит трафик на relay
+			if m.routingPolicy() == RoutingRelay {
+				continue
+			}
+			m.mu.RLock()
+			var candidates []*PeerSession
+			for peerID, session := range m.sessions {
+				if _, probing := m.probes[peerID]; probing || session.Inbound {
+					continue
+				}
+				if session.State() == SessionStateConnected && !session.Info().Path.IsICE() {
+					candidates = append(candidates, session)
+				}
+			}
+			m.mu.RUnlock()
+
+			for _, session := range candidates {
+				go m.upgradeToDirect(session)
+			}
+		}
+	}
+}
+
+// upgradeToDirect повторяет ICE с пиром в пробной сессии. При успехе пробная сессия
+// заменяет текущую (registerConnection), при неудаче текущее соединение сохраняется.
+func (m *Manager) upgradeToDirect(current *PeerSession) {
+	probe, err := m.newProbeSession(current.PeerID, "", false)
+	if err != nil {
+		return
+	}
+	defer m.removeProbe(probe)
+
+	m.logger.Debug("Trying to upgrade P2P connection to a direct path",
+		"peer_id", current.PeerID,
+		"path", current.Info().Path)
+
+	err = probe.gather(m.iceServers())
+	if err == nil {
+		err = m.signalSession(probe)
+	}
+	if err == nil {
+		m.mu.RLock()
+		controlling := m.peerID < current.PeerID
+		m.mu.RUnlock()
+		err = probe.connectICE(controlling)
+	}
+	if err == nil {
+		err = m.establishQUICConnection(probe)
+	}
+	if err != nil {
+		m.logger.Debug("Direct path still unavailable", "peer_id", current.PeerID, "error", err)
+		_ = probe.Close() //nolint:errcheck // probe resources are released best-effort
+	}
+}
+
+// GetPathCounts возвращает число установленных соединений по типу пути
+func (m *Manager) GetPathCounts() map[PathType]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[PathType]int)
+	for _, conn := range m.connections {
+		counts[conn.Path]++
+	}
+	return counts
+}
@@ -0,0 +1,163 @@
+package p2p
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"github.com/2gc-dev/cloudbridge-client/pkg/derp"
+	"golang.org/x/net/websocket"
+)
+
+// startTestDERPRelay запускает минимальный DERP relay: фрейм type | keyLen | key | payload,
+// пакет пересылается клиенту с ключом получателя
+func startTestDERPRelay(t *testing.T) string {
+	t.Helper()
+	var mu sync.Mutex
+	clients := make(map[string]*websocket.Conn)
+
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		var key string
+		for {
+			var frame []byte
+			if err := websocket.Message.Receive(ws, &frame); err != nil {
+				return
+			}
+			if len(frame) < 2 || len(frame) < 2+int(frame[1]) {
+				return
+			}
+			frameKey := string(frame[2 : 2+int(frame[1])])
+			switch frame[0] {
+			case 0x01: // client info
+				key = frameKey
+				mu.Lock()
+				clients[key] = ws
+				mu.Unlock()
+			case 0x02: // send packet
+				mu.Lock()
+				dst := clients[frameKey]
+				mu.Unlock()
+				if dst == nil {
+					continue
+				}
+				out := append([]byte{0x03, byte(len(key))}, key...)
+				out = append(out, frame[2+int(frame[1]):]...)
+				_ = websocket.Message.Send(dst, out) //nolint:errcheck // test relay drops on error
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + derp.DefaultPath
+}
+
+// newDERPTestManager создает менеджер с DERP клиентом, подключенным к тестовому relay
+func newDERPTestManager(t *testing.T, relayURL, publicKey string) *Manager {
+	t.Helper()
+	m := newSessionTestManager(10)
+
+	cert, err := newPeerCertificate(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	m.tlsCert = cert
+
+	m.derp = derp.NewClient(&derp.Config{Servers: []string{relayURL}, PublicKey: publicKey}, m.logger)
+	if err := m.derp.Start(); err != nil {
+		t.Fatalf("Failed to start DERP client: %v", err)
+	}
+	t.Cleanup(m.derp.Stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.derp.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("DERP client did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return m
+}
+
+func TestManager_QUICOverDERP(t *testing.T) {
+	relayURL := startTestDERPRelay(t)
+	a := newDERPTestManager(t, relayURL, "key-a")
+	b := newDERPTestManager(t, relayURL, "key-b")
+	a.rememberPeerKey("peer-b", "key-b", "")
+	b.rememberPeerKey("peer-a", "key-a", "")
+
+	outbound, err := a.newSession("peer-b", "s1", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer outbound.Close()
+	inbound, err := b.newSession("peer-a", "s1", true)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer inbound.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- b.acceptDERPConnection(inbound)
+	}()
+	if err := a.establishDERPConnection(outbound); err != nil {
+		t.Fatalf("Failed to connect over DERP: %v", err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatalf("Failed to accept over DERP: %v", err)
+	}
+
+	if path := a.GetStatus().PeerPaths["peer-b"]; path != PathDERP {
+		t.Errorf("Expected derp path to peer-b, got %q", path)
+	}
+	if counts := b.GetPathCounts(); counts[PathDERP] != 1 {
+		t.Errorf("Expected one derp connection, got %v", counts)
+	}
+}
+
+func TestManager_UpgradeProbeReplacesSession(t *testing.T) {
+	m := newSessionTestManager(10)
+
+	// Запрос перевода без установленного соединения отклоняется
+	if _, err := m.acceptSession(&api.P2PIncomingRequest{SessionID: "s2", FromPeerID: "peer-b", Upgrade: true}); err == nil {
+		t.Fatal("Expected upgrade request without connection to be declined")
+	}
+
+	current, err := m.newSession("peer-b", "s1", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := m.registerConnection(current, nil, false, nil, PathDERP); err != nil {
+		t.Fatalf("Failed to register connection: %v", err)
+	}
+
+	probe, err := m.newProbeSession("peer-b", "s2", false)
+	if err != nil {
+		t.Fatalf("Failed to create probe session: %v", err)
+	}
+	if _, err := m.newProbeSession("peer-b", "s3", false); err == nil {
+		t.Error("Expected second probe for the same peer to be rejected")
+	}
+	if session, _ := m.GetPeerSession("peer-b"); session != current {
+		t.Fatal("Probe must not replace the session before it connects")
+	}
+
+	if err := m.registerConnection(probe, nil, false, nil, PathDirect); err != nil {
+		t.Fatalf("Failed to register probe connection: %v", err)
+	}
+	if session, _ := m.GetPeerSession("peer-b"); session != probe {
+		t.Error("Expected connected probe to replace the session")
+	}
+	if current.State() != SessionStateClosed {
+		t.Errorf("Expected replaced session to be closed, got %s", current.State())
+	}
+	if path := m.GetStatus().PeerPaths["peer-b"]; path != PathDirect {
+		t.Errorf("Expected direct path after upgrade, got %q", path)
+	}
+	if len(m.probes) != 0 {
+		t.Error("Expected probe to be removed after promotion")
+	}
+}
@@ -0,0 +1,157 @@
+package p2p
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/meshdns"
+)
+
+// meshDNSRefreshInterval период обновления записей mesh DNS из mesh сети
+const meshDNSRefreshInterval = 15 * time.Second
+
+// SetMeshDNS включает DNS mesh сети: <peer-id>.<tenant>.<domain> разрешается в адрес
+// пира в overlay сети. configureSystem (auto, resolved, resolvconf; пусто — нет)
+// направляет на него системный резолвер. Вызывается до Start.
+func (m *Manager) SetMeshDNS(config *meshdns.Config, configureSystem string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dnsConfig = config
+	m.dnsSystem = configureSystem
+}
+
+// startMeshDNS запускает DNS на адресе overlay сети после ее настройки.
+// Вызывается из Start под m.mu.
+func (m *Manager) startMeshDNS() {
+	if m.dnsConfig == nil {
+		return
+	}
+
+	config := *m.dnsConfig
+	overlayIP := overlayAddress(m.peerIP)
+	if config.ListenAddr == "" {
+		if m.netstackLocked() {
+			// Адрес overlay сети есть только внутри netstack, системе он недоступен
+			m.logger.Warn("Mesh DNS needs an overlay interface, set p2p.dns.listen_addr in netstack mode")
+			return
+		}
+		if overlayIP == nil {
+			m.logger.Warn("L3 overlay not ready, mesh DNS disabled")
+			return
+		}
+		config.ListenAddr = net.JoinHostPort(overlayIP.String(), "53")
+	}
+	if config.Tenant == "" {
+		config.Tenant = m.tenantID
+	}
+	// Прочие имена: резолверы из конфигурации, из network_config токена или системные
+	if len(config.Upstreams) == 0 && m.config.NetworkConfig != nil {
+		config.Upstreams = m.config.NetworkConfig.DNS
+	}
+	if len(config.Upstreams) == 0 {
+		config.Upstreams = meshdns.SystemNameservers()
+	}
+	config.Upstreams = withoutListenAddr(config.Upstreams, config.ListenAddr)
+
+	server := meshdns.NewServer(&config, m.logger)
+	server.SetPeers(m.meshDNSPeersLocked())
+	if err := server.Start(); err != nil {
+		m.logger.Warn("Failed to start mesh DNS", "error", err)
+		return
+	}
+	m.dns = server
+	go m.meshDNSLoop(server)
+
+	if m.dnsSystem == "" {
+		return
+	}
+	host, _, _ := net.SplitHostPort(config.ListenAddr) //nolint:errcheck // address was accepted by Listen
+	serverIP := net.ParseIP(host)
+	iface, err := meshdns.InterfaceForIP(serverIP)
+	if err != nil && m.dnsSystem != meshdns.ModeResolvConf {
+		m.logger.Warn("Overlay interface not found for split DNS", "error", err)
+	}
+	restore, err := meshdns.ConfigureSystem(m.dnsSystem, iface, serverIP, server.Zone())
+	if err != nil {
+		m.logger.Warn("Failed to configure system resolver for mesh DNS", "mode", m.dnsSystem, "error", err)
+		return
+	}
+	m.dnsRestore = restore
+	m.logger.Info("System resolver configured for mesh DNS", "mode", m.dnsSystem, "interface", iface, "zone", server.Zone())
+}
+
+// stopMeshDNS восстанавливает системный резолвер и останавливает DNS
+func (m *Manager) stopMeshDNS() {
+	m.mu.Lock()
+	server, restore := m.dns, m.dnsRestore
+	m.dns, m.dnsRestore = nil, nil
+	m.mu.Unlock()
+
+	if restore != nil {
+		if err := restore(); err != nil {
+			m.logger.Error("Failed to restore system resolver", "error", err)
+		}
+	}
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// meshDNSPeersLocked собирает адреса пиров из mesh сети и адрес локального пира.
+// Вызывается под m.mu.
+func (m *Manager) meshDNSPeersLocked() map[string][]net.IP {
+	peers := make(map[string][]net.IP)
+	if m.mesh != nil {
+		peers = m.mesh.PeerAddresses()
+	}
+	if ip := overlayAddress(m.peerIP); ip != nil && m.peerID != "" {
+		peers[m.peerID] = []net.IP{ip}
+	}
+	return peers
+}
+
+// meshDNSLoop обновляет записи по мере обнаружения пиров
+func (m *Manager) meshDNSLoop(server *meshdns.Server) {
+	ticker := time.NewTicker(meshDNSRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.RLock()
+			peers := m.meshDNSPeersLocked()
+			m.mu.RUnlock()
+			server.SetPeers(peers)
+		}
+	}
+}
+
+// overlayAddress разбирает адрес пира в overlay сети (адрес или адрес/префикс)
+func overlayAddress(peerIP string) net.IP {
+	if ip, _, err := net.ParseCIDR(peerIP); err == nil {
+		return ip
+	}
+	return net.ParseIP(peerIP)
+}
+
+// withoutListenAddr исключает из upstream резолверов собственный адрес DNS (защита от петли)
+func withoutListenAddr(upstreams []string, listenAddr string) []string {
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return upstreams
+	}
+	filtered := make([]string, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		upstreamHost := upstream
+		if h, _, err := net.SplitHostPort(upstream); err == nil {
+			upstreamHost = h
+		}
+		if strings.Trim(upstreamHost, "[]") != host {
+			filtered = append(filtered, upstream)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,38 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/meshdns"
+)
+
+func TestManager_MeshDNSRecords(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID, m.tenantID, m.peerIP = "peer-a", "acme", "10.0.0.1/24"
+	m.mesh = NewMeshNetwork(&MeshConfig{}, NewSimpleLogger("test"))
+	m.mesh.UpsertPeer(&Peer{ID: "peer-b", IsConnected: true, AllowedIPs: []string{"10.0.0.2/32", "192.168.1.0/24"}})
+	m.mesh.UpsertPeer(&Peer{ID: "peer-c", IsConnected: true, AllowedIPs: []string{"fd00::3"}})
+
+	peers := m.meshDNSPeersLocked()
+	if len(peers) != 3 || !peers["peer-a"][0].Equal(net.ParseIP("10.0.0.1")) ||
+		len(peers["peer-b"]) != 1 || !peers["peer-b"][0].Equal(net.ParseIP("10.0.0.2")) ||
+		!peers["peer-c"][0].Equal(net.ParseIP("fd00::3")) {
+		t.Errorf("Unexpected mesh DNS records: %v", peers)
+	}
+
+	// Собственный адрес DNS не используется как upstream
+	if upstreams := withoutListenAddr([]string{"10.0.0.1", "1.1.1.1", "10.0.0.1:53"}, "10.0.0.1:53"); len(upstreams) != 1 || upstreams[0] != "1.1.1.1" {
+		t.Errorf("Unexpected upstreams: %v", upstreams)
+	}
+
+	m.SetMeshDNS(&meshdns.Config{Domain: "mesh", ListenAddr: "127.0.0.1:0"}, "")
+	m.startMeshDNS()
+	defer m.stopMeshDNS()
+	if m.dns == nil {
+		t.Fatal("Expected mesh DNS to start")
+	}
+	if metrics := m.dns.GetMetrics(); metrics["zone"] != "acme.mesh." || metrics["records"] != 3 {
+		t.Errorf("Unexpected mesh DNS metrics: %v", metrics)
+	}
+}
@@ -0,0 +1,127 @@
+//go:build linux
+
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Переключатели пересылки пакетов ядра
+const (
+	ipv4ForwardPath = "/proc/sys/net/ipv4/ip_forward"
+	ipv6ForwardPath = "/proc/sys/net/ipv6/conf/all/forwarding"
+)
+
+// enableForwarding включает пересылку IP пакетов для объявленных подсетей и (snat)
+// маскарадинг трафика overlay сети в них: устройствам LAN не нужен маршрут в overlay.
+// Возвращает функцию, восстанавливающую прежние настройки; при ошибке уже внесенные
+// изменения откатываются.
+func enableForwarding(overlay string, routes []string, snat bool) (func() error, error) {
+	var undo []func() error
+	rollback := func() error {
+		var errs []string
+		for i := len(undo) - 1; i >= 0; i-- {
+			if err := undo[i](); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to restore forwarding: %s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	ipv4, ipv6 := false, false
+	for _, route := range routes {
+		if network := parseRoute(route); network != nil && network.IP.To4() != nil {
+			ipv4 = true
+		} else if network != nil {
+			ipv6 = true
+		}
+	}
+	for path, enabled := range map[string]bool{ipv4ForwardPath: ipv4, ipv6ForwardPath: ipv6} {
+		if !enabled {
+			continue
+		}
+		restore, err := enableSysctl(path)
+		if err != nil {
+			_ = rollback() //nolint:errcheck // best-effort rollback after failed setup
+			return nil, err
+		}
+		undo = append(undo, restore)
+	}
+
+	if snat {
+		for _, rule := range masqueradeRules(overlay, routes) {
+			if err := iptables(rule[0], "-A", rule[1:]); err != nil {
+				_ = rollback() //nolint:errcheck // best-effort rollback after failed setup
+				return nil, err
+			}
+			undo = append(undo, func() error { return iptables(rule[0], "-D", rule[1:]) })
+		}
+	}
+	return rollback, nil
+}
+
+// enableSysctl записывает 1 в переключатель ядра и возвращает восстановление прежнего значения
+func enableSysctl(path string) (func() error, error) {
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if string(bytes.TrimSpace(previous)) == "1" {
+		return func() error { return nil }, nil
+	}
+	if err := os.WriteFile(path, []byte("1\n"), 0o644); err != nil { // #nosec G306 -- procfs switch
+		return nil, fmt.Errorf("failed to enable forwarding in %s: %w", path, err)
+	}
+	return func() error {
+		if err := os.WriteFile(path, previous, 0o644); err != nil { // #nosec G306 -- procfs switch
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// masqueradeRules возвращает правила цепочки nat POSTROUTING: команду и условия правила
+// для трафика из overlay сети в объявленные подсети. Для маршрута по умолчанию маскируется
+// весь трафик, уходящий из overlay сети.
+func masqueradeRules(overlay string, routes []string) [][]string {
+	overlayNetwork := parseRoute(overlay)
+	if overlayNetwork == nil {
+		return nil
+	}
+	command := "iptables"
+	if overlayNetwork.IP.To4() == nil {
+		command = "ip6tables"
+	}
+
+	var rules [][]string
+	for _, route := range routes {
+		network := parseRoute(route)
+		if network == nil || (network.IP.To4() == nil) != (overlayNetwork.IP.To4() == nil) {
+			continue
+		}
+		rule := []string{command, "-s", overlayNetwork.String()}
+		if isDefaultRoute(route) {
+			rule = append(rule, "!", "-d", overlayNetwork.String())
+		} else {
+			rule = append(rule, "-d", network.String())
+		}
+		rules = append(rules, append(rule, "-j", "MASQUERADE"))
+	}
+	return rules
+}
+
+// iptables добавляет (-A) или удаляет (-D) правило маскарадинга в цепочке nat POSTROUTING
+func iptables(command, action string, rule []string) error {
+	args := append([]string{"-t", "nat", action, "POSTROUTING"}, rule...)
+	if out, err := exec.Command(command, args...).CombinedOutput(); err != nil { // #nosec G204 -- arguments are validated CIDRs
+		return fmt.Errorf("%s %s failed: %w: %s", command, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
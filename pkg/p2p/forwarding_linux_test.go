@@ -0,0 +1,22 @@
+//go:build linux
+
+package p2p
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMasqueradeRules(t *testing.T) {
+	rules := masqueradeRules("10.0.0.0/24", []string{"192.168.1.0/24", "fd00::/64", "0.0.0.0/0"})
+	want := [][]string{
+		{"iptables", "-s", "10.0.0.0/24", "-d", "192.168.1.0/24", "-j", "MASQUERADE"},
+		{"iptables", "-s", "10.0.0.0/24", "!", "-d", "10.0.0.0/24", "-j", "MASQUERADE"},
+	}
+	if !slices.EqualFunc(rules, want, slices.Equal) {
+		t.Errorf("masqueradeRules = %v, want %v", rules, want)
+	}
+	if rules := masqueradeRules("", []string{"192.168.1.0/24"}); rules != nil {
+		t.Errorf("Expected no rules without overlay subnet, got %v", rules)
+	}
+}
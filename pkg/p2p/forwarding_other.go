@@ -0,0 +1,14 @@
+//go:build !linux
+
+package p2p
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// enableForwarding не поддерживается вне Linux: пересылку пакетов и NAT для объявленных
+// подсетей настраивают средствами системы
+func enableForwarding(overlay string, routes []string, snat bool) (func() error, error) {
+	return nil, fmt.Errorf("forwarding for advertised routes is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,260 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/signaling"
+	pionice "github.com/pion/ice/v2"
+)
+
+// interfaceCheckInterval период проверки сетевых интерфейсов на изменения
+const interfaceCheckInterval = 5 * time.Second
+
+// RestartICE перезапускает ICE сессию с пиром, например после смены сети.
+// Без push сигналинга сессия пересоздается заново.
+func (m *Manager) RestartICE(peerID string) error {
+	session, ok := m.GetPeerSession(peerID)
+	if !ok || session.State() != SessionStateConnected {
+		return fmt.Errorf("no connected session with peer %s", peerID)
+	}
+	return m.restartSession(session, "requested")
+}
+
+// restartSession инициирует ICE restart прямой сессии
+func (m *Manager) restartSession(session *PeerSession, reason string) error {
+	if !session.Info().Path.IsICE() {
+		return fmt.Errorf("session with peer %s does not use a direct path", session.PeerID)
+	}
+	if !m.pushConnected() {
+		m.reconnectSession(session, "ICE restart requires push signaling")
+		return nil
+	}
+
+	m.logger.Info("Restarting ICE", "peer_id", session.PeerID, "session_id", session.SessionID, "reason", reason)
+	err := session.restartICE(m.announceRestart(session), m.trickleCandidate(session))
+	if errors.Is(err, errRestartInProgress) {
+		return nil
+	}
+	if err != nil {
+		m.reconnectSession(session, err.Error())
+		return fmt.Errorf("failed to restart ICE: %w", err)
+	}
+
+	go m.awaitRestart(session)
+	return nil
+}
+
+// handleRemoteRestart отвечает на ICE restart пира: перезапускает свой агент
+// (если restart начал пир) и применяет его новые credentials
+func (m *Manager) handleRemoteRestart(session *PeerSession, event *signaling.Event) {
+	if event.Ufrag == "" || event.Pwd == "" {
+		return
+	}
+	restarting, _, remoteUfrag := session.restartStatus()
+	if event.Ufrag == remoteUfrag {
+		return // повтор уже примененной генерации
+	}
+	if session.State() != SessionStateConnected || !session.Info().Path.IsICE() {
+		m.reconnectSession(session, "peer restarted ICE")
+		return
+	}
+
+	if !restarting {
+		err := session.restartICE(m.announceRestart(session), m.trickleCandidate(session))
+		if err != nil && !errors.Is(err, errRestartInProgress) {
+			m.reconnectSession(session, err.Error())
+			return
+		}
+		if err == nil {
+			go m.awaitRestart(session)
+		}
+	}
+
+	if err := session.applyRemoteRestart(event.Ufrag, event.Pwd); err != nil {
+		m.reconnectSession(session, err.Error())
+	}
+}
+
+// handleICEState запускает restart при потере связности прямой сессии
+func (m *Manager) handleICEState(session *PeerSession, state pionice.ConnectionState) {
+	if state != pionice.ConnectionStateDisconnected && state != pionice.ConnectionStateFailed {
+		return
+	}
+	if _, recovering, _ := session.restartStatus(); recovering || session.State() != SessionStateConnected {
+		return
+	}
+
+	go func() {
+		if err := m.restartSession(session, "ICE "+state.String()); err != nil {
+			m.logger.Debug("ICE restart not performed", "peer_id", session.PeerID, "error", err)
+		}
+	}()
+}
+
+// announceRestart отправляет пиру новые credentials сессии
+func (m *Manager) announceRestart(session *PeerSession) func(ufrag, pwd string) error {
+	return func(ufrag, pwd string) error {
+		if !m.sendSignal(&signaling.Event{
+			Type:      signaling.EventICERestart,
+			SessionID: session.SessionID,
+			ToPeerID:  session.PeerID,
+			Ufrag:     ufrag,
+			Pwd:       pwd,
+		}) {
+			return fmt.Errorf("failed to send ICE restart to peer")
+		}
+		return nil
+	}
+}
+
+// trickleCandidate отправляет пиру кандидаты по мере сбора; nil — конец кандидатов
+func (m *Manager) trickleCandidate(session *PeerSession) func(pionice.Candidate) {
+	return func(candidate pionice.Candidate) {
+		session.mu.RLock()
+		ufrag, pwd := session.localUfrag, session.localPwd
+		session.mu.RUnlock()
+
+		event := &signaling.Event{
+			Type:      signaling.EventEndOfCandidates,
+			SessionID: session.SessionID,
+			ToPeerID:  session.PeerID,
+			Ufrag:     ufrag,
+			Pwd:       pwd,
+		}
+		if candidate != nil {
+			event.Type = signaling.EventCandidate
+			event.Candidate = candidateToAPI(candidate)
+		} else if mapped := m.mappedCandidate(nil); mapped != nil {
+			// Кандидат отображения порта не собирается агентом — отправляем перед концом кандидатов
+			mappedEvent := *event
+			mappedEvent.Type = signaling.EventCandidate
+			mappedEvent.Candidate = candidateToAPI(mapped)
+			m.sendSignal(&mappedEvent)
+		}
+		m.sendSignal(event)
+	}
+}
+
+// awaitRestart ждет восстановления ICE после restart; иначе сессия пересоздается
+func (m *Manager) awaitRestart(session *PeerSession) {
+	ctx, cancel := context.WithTimeout(session.ctx, peerConnectTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if session.ctx.Err() == nil {
+				m.reconnectSession(session, "ICE restart timed out")
+			}
+			return
+		case <-ticker.C:
+			if session.finishRecovery() {
+				m.logger.Info("ICE restart completed", "peer_id", session.PeerID, "session_id", session.SessionID)
+				return
+			}
+		}
+	}
+}
+
+// reconnectSession закрывает сессию; новую попытку начинает ее инициатор
+func (m *Manager) reconnectSession(session *PeerSession, reason string) {
+	m.mu.Lock()
+	current, ok := m.sessions[session.PeerID]
+	if ok && current == session {
+		delete(m.sessions, session.PeerID)
+		delete(m.connections, session.PeerID)
+	}
+	m.mu.Unlock()
+	if !ok || current != session {
+		return
+	}
+
+	m.updateMeshPath(session.PeerID, "", false)
+
+	m.logger.Info("Re-establishing P2P session", "peer_id", session.PeerID, "session_id", session.SessionID, "reason", reason)
+	if err := session.Close(); err != nil {
+		m.logger.Debug("Failed to close P2P session", "peer_id", session.PeerID, "error", err)
+	}
+	if session.Inbound {
+		return
+	}
+	go func() {
+		if err := m.ConnectToPeer(session.PeerID); err != nil {
+			m.logger.Warn("Failed to re-establish P2P session", "peer_id", session.PeerID, "error", err)
+		}
+	}()
+}
+
+// startInterfaceMonitor перезапускает ICE прямых сессий при изменении сетевых интерфейсов
+func (m *Manager) startInterfaceMonitor() {
+	last, err := interfaceFingerprint()
+	if err != nil {
+		m.logger.Warn("Failed to read network interfaces", "error", err)
+	}
+
+	ticker := time.NewTicker(interfaceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := interfaceFingerprint()
+			if err != nil || current == last {
+				continue
+			}
+			last = current
+
+			m.mu.RLock()
+			sessions := make([]*PeerSession, 0, len(m.sessions))
+			for _, session := range m.sessions {
+				sessions = append(sessions, session)
+			}
+			m.mu.RUnlock()
+
+			m.logger.Info("Network interfaces changed, restarting ICE", "sessions", len(sessions))
+			for _, session := range sessions {
+				if session.State() != SessionStateConnected || !session.Info().Path.IsICE() {
+					continue
+				}
+				if err := m.restartSession(session, "network change"); err != nil {
+					m.logger.Debug("ICE restart not performed", "peer_id", session.PeerID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// interfaceFingerprint описывает адреса активных интерфейсов (без loopback)
+func interfaceFingerprint() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list interfaces: %w", err)
+	}
+
+	var addrs []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaceAddrs {
+			addrs = append(addrs, iface.Name+"="+addr.String())
+		}
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, ","), nil
+}
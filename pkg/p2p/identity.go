@@ -0,0 +1,254 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
+	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+)
+
+// identityCheckInterval период проверки возраста ключа идентичности для автоматической смены
+const identityCheckInterval = time.Hour
+
+// SetIdentity включает постоянный ключ идентичности: ключ публикуется при регистрации,
+// а QUIC соединения с пирами закрепляются за их опубликованными ключами, так что relay
+// не может подменить пира. Вызывается до Start.
+func (m *Manager) SetIdentity(config *identity.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identityConfig = config
+}
+
+// startIdentity загружает ключ идентичности до регистрации пира. Вызывается из Start под m.mu.
+func (m *Manager) startIdentity() error {
+	if m.identityConfig == nil {
+		return nil
+	}
+
+	store := identity.NewStore(m.identityConfig, m.logger)
+	key, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load identity key: %w", err)
+	}
+	pins := identity.NewPinStore(m.identityConfig.PinFile, m.logger)
+	if err := pins.Load(); err != nil {
+		return fmt.Errorf("failed to load pinned peer keys: %w", err)
+	}
+	m.identity, m.pins = store, pins
+	if m.apiManager != nil {
+		m.apiManager.SetPublicKey(key.PublicKey())
+		m.apiManager.SetKeySignature(store.Signature())
+	}
+	if interval := m.identityConfig.RotationInterval; interval > 0 {
+		go m.identityRotationLoop(store, interval)
+	}
+	return nil
+}
+
+// peerCertificateLocked возвращает сертификат для QUIC соединений с пирами: на ключе
+// идентичности, если он задан, иначе временный. Вызывается под m.mu.
+func (m *Manager) peerCertificateLocked() (tls.Certificate, error) {
+	if m.identity != nil {
+		return m.identity.Key().Certificate(m.peerID)
+	}
+	return newPeerCertificate(m.peerID)
+}
+
+// pinPeerTLS закрепляет QUIC соединение с пиром за его ключом идентичности.
+// Исходящее соединение предъявляет свой сертификат: пир проверяет и нас.
+func (m *Manager) pinPeerTLS(quicConn *quic.QUICConnection, peerID string, dialer bool) {
+	m.mu.RLock()
+	enabled, cert := m.identity != nil, m.tlsCert
+	m.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	if dialer {
+		quicConn.SetClientTLSCert(cert)
+	}
+	quicConn.SetPeerCertificateVerifier(func(rawCerts [][]byte) error {
+		return m.verifyPeerKey(peerID, rawCerts)
+	})
+}
+
+// verifyPeerKey проверяет сертификат пира по его ключу идентичности. Ключ, с которым
+// прошло первое соединение, закрепляется локально (TOFU); при несовпадении ключи пиров
+// перечитываются: пир мог сменить ключ, подписав новый закрепленным.
+func (m *Manager) verifyPeerKey(peerID string, rawCerts [][]byte) error {
+	publicKey, pinned, err := m.peerIdentityKey(peerID)
+	if err == nil && len(rawCerts) > 0 && identity.VerifyCertificate(rawCerts[0], pinned) == nil {
+		return m.pinPeerKey(peerID, publicKey)
+	}
+
+	if refreshErr := m.refreshPeerKeys(); refreshErr != nil {
+		m.logger.Debug("Failed to refresh peer keys", "peer_id", peerID, "error", refreshErr)
+	} else {
+		publicKey, pinned, err = m.peerIdentityKey(peerID)
+	}
+	if err != nil {
+		if m.requirePeerKeys() {
+			return fmt.Errorf("failed to pin peer %s: %w", peerID, err)
+		}
+		m.logger.Warn("Peer identity key unknown, connection is not pinned", "peer_id", peerID, "error", err)
+		return nil
+	}
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("peer %s presented no certificate", peerID)
+	}
+	if err := identity.VerifyCertificate(rawCerts[0], pinned); err != nil {
+		return fmt.Errorf("peer %s: %w", peerID, err)
+	}
+	return m.pinPeerKey(peerID, publicKey)
+}
+
+// peerIdentityKey возвращает ключ идентичности пира: закрепленный, иначе опубликованный
+func (m *Manager) peerIdentityKey(peerID string) (string, ed25519.PublicKey, error) {
+	m.mu.RLock()
+	pins := m.pins
+	key, ok := m.peerKeys[peerID]
+	m.mu.RUnlock()
+	if pins != nil {
+		if pinnedKey, pinned := pins.Lookup(peerID); pinned {
+			key, ok = pinnedKey, true
+		}
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("public key of peer %s unknown", peerID)
+	}
+	pub, err := identity.ParsePublicKey(key)
+	return key, pub, err
+}
+
+// pinPeerKey закрепляет ключ пира после первого успешного соединения
+func (m *Manager) pinPeerKey(peerID, publicKey string) error {
+	m.mu.RLock()
+	pins := m.pins
+	m.mu.RUnlock()
+	if pins == nil {
+		return nil
+	}
+	if _, pinned := pins.Lookup(peerID); pinned {
+		return nil
+	}
+	if err := pins.Pin(peerID, publicKey); err != nil {
+		return fmt.Errorf("failed to pin peer %s: %w", peerID, err)
+	}
+	m.logger.Info("Peer identity key pinned", "peer_id", peerID, "public_key", publicKey)
+	return nil
+}
+
+// trustedPeerKey выбирает ключ пира из опубликованного relay: закрепленный ключ relay
+// заменить не может, новый ключ принимается, только если переход подписан закрепленным
+func (m *Manager) trustedPeerKey(peerID, publicKey, signature string) string {
+	m.mu.RLock()
+	pins := m.pins
+	m.mu.RUnlock()
+	if pins == nil {
+		return publicKey
+	}
+	pinned, ok := pins.Lookup(peerID)
+	if !ok || pinned == publicKey {
+		return publicKey
+	}
+	if err := pins.Rotate(peerID, publicKey, signature); err != nil {
+		m.logger.Warn("Ignoring peer key from relay that does not match the pinned key",
+			"peer_id", peerID, "public_key", publicKey, "pinned_key", pinned, "error", err)
+		return pinned
+	}
+	return publicKey
+}
+
+func (m *Manager) requirePeerKeys() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.identityConfig != nil && m.identityConfig.RequirePeerKeys
+}
+
+// RotateIdentity сменяет ключ идентичности: новый ключ регистрируется в relay API,
+// сохраняется в файл и используется для новых соединений с пирами, DERP клиент
+// переподключается с новым ключом. Установленные соединения не разрываются.
+func (m *Manager) RotateIdentity() error {
+	m.mu.RLock()
+	store := m.identity
+	m.mu.RUnlock()
+	if store == nil {
+		return fmt.Errorf("identity key not configured")
+	}
+
+	key, err := store.Rotate(func(key *identity.Key, signature string) error {
+		if m.apiManager == nil {
+			return nil
+		}
+		return m.apiManager.RotatePublicKey(key.PublicKey(), signature)
+	})
+	if key == nil {
+		return err
+	}
+	if err != nil {
+		// Новый ключ уже зарегистрирован: применяем его, хотя он не сохранился
+		m.logger.Error("Failed to persist rotated identity key", "error", err)
+	}
+
+	m.mu.Lock()
+	if m.apiManager != nil {
+		if peerID := m.apiManager.GetPeerID(); peerID != "" && peerID != m.peerID {
+			m.logger.Info("Peer ID changed after key rotation", "old_peer_id", m.peerID, "peer_id", peerID)
+			m.peerID = peerID
+			if m.mesh != nil {
+				m.mesh.SetLocalPeerID(peerID)
+			}
+		}
+	}
+	cert, certErr := key.Certificate(m.peerID)
+	if certErr == nil {
+		m.tlsCert = cert
+	}
+	derpClient := m.derp
+	m.derp = nil
+	m.mu.Unlock()
+	if certErr != nil {
+		return fmt.Errorf("failed to create peer certificate: %w", certErr)
+	}
+
+	// DERP relay адресует пакеты по публичному ключу
+	if derpClient != nil {
+		derpClient.Stop()
+		m.mu.Lock()
+		m.startDERP()
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// identityRotationLoop сменяет ключ идентичности, когда его возраст достигает interval
+func (m *Manager) identityRotationLoop(store *identity.Store, interval time.Duration) {
+	ticker := time.NewTicker(min(interval, identityCheckInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if store.Age() < interval {
+				continue
+			}
+			if err := m.RotateIdentity(); err != nil {
+				m.logger.Error("Failed to rotate identity key", "error", err)
+			}
+		}
+	}
+}
+
+// identityStatusLocked возвращает состояние ключа идентичности. Вызывается под m.mu.
+func (m *Manager) identityStatusLocked() map[string]interface{} {
+	return map[string]interface{}{
+		"public_key":        m.identity.Key().PublicKey(),
+		"key_age":           m.identity.Age().Round(time.Second).String(),
+		"require_peer_keys": m.identityConfig.RequirePeerKeys,
+	}
+}
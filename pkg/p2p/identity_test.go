@@ -0,0 +1,181 @@
+package p2p
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
+)
+
+// newIdentityTestManager создает менеджер с ключом идентичности во временном каталоге
+func newIdentityTestManager(t *testing.T, peerID string) *Manager {
+	t.Helper()
+	m := newSessionTestManager(10)
+	m.peerID = peerID
+	dir := t.TempDir()
+	m.identityConfig = identity.DefaultConfig()
+	m.identityConfig.KeyFile = filepath.Join(dir, "identity.key")
+	m.identityConfig.PinFile = filepath.Join(dir, "known_peers.json")
+	store := identity.NewStore(m.identityConfig, m.logger)
+	if _, err := store.Load(); err != nil {
+		t.Fatalf("Failed to load identity key: %v", err)
+	}
+	m.identity = store
+	m.pins = identity.NewPinStore(m.identityConfig.PinFile, m.logger)
+	cert, err := m.peerCertificateLocked()
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	m.tlsCert = cert
+	return m
+}
+
+// handshakePeers устанавливает QUIC соединение a -> b и возвращает ошибки обеих сторон
+func handshakePeers(t *testing.T, a, b *Manager) (dialErr, acceptErr error) {
+	t.Helper()
+	connA, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	connB, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer connA.Close()
+	defer connB.Close()
+
+	outbound, err := a.newSession(b.peerID, "s1", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer a.ClosePeerSession(b.peerID) //nolint:errcheck // test cleanup
+	inbound, err := b.newSession(a.peerID, "s1", true)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer b.ClosePeerSession(a.peerID) //nolint:errcheck // test cleanup
+
+	done := make(chan error, 1)
+	go func() {
+		quicConn, _, err := b.acceptPeerQUIC(inbound, connB)
+		if err == nil {
+			_ = quicConn.Close() //nolint:errcheck // test cleanup
+		}
+		done <- err
+	}()
+	quicConn, _, dialErr := a.dialPeerQUIC(outbound, connA, connB.LocalAddr())
+	if dialErr != nil {
+		// Без stream принимающая сторона дождется таймаута: закрываем ее сессию
+		_ = inbound.Close() //nolint:errcheck // test cleanup
+	}
+	acceptErr = <-done
+	if quicConn != nil {
+		_ = quicConn.Close() //nolint:errcheck // test cleanup
+	}
+	return dialErr, acceptErr
+}
+
+func TestManager_PeerKeyPinning(t *testing.T) {
+	a := newIdentityTestManager(t, "peer-a")
+	b := newIdentityTestManager(t, "peer-b")
+	keyB := b.identity.Key().PublicKey()
+	a.rememberPeerKey("peer-b", keyB, "")
+	b.rememberPeerKey("peer-a", a.identity.Key().PublicKey(), "")
+
+	if dialErr, acceptErr := handshakePeers(t, a, b); dialErr != nil || acceptErr != nil {
+		t.Fatalf("Expected pinned handshake to succeed: dial=%v accept=%v", dialErr, acceptErr)
+	}
+	if pinned, ok := a.pins.Lookup("peer-b"); !ok || pinned != keyB {
+		t.Fatalf("Expected key of peer-b to be pinned after first connection, got %q", pinned)
+	}
+
+	// Relay подставил свой ключ вместо ключа b: закрепленный ключ не меняется
+	mitm, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if key := a.rememberPeerKey("peer-b", mitm.PublicKey(), ""); key != keyB {
+		t.Errorf("Expected relay key to be ignored for pinned peer, got %q", key)
+	}
+	if dialErr, acceptErr := handshakePeers(t, a, b); dialErr != nil || acceptErr != nil {
+		t.Fatalf("Expected handshake with pinned peer to succeed: dial=%v accept=%v", dialErr, acceptErr)
+	}
+
+	// Relay выдает за b узел с другим ключом: соединение отклоняется
+	impostor := newIdentityTestManager(t, "peer-b")
+	impostor.rememberPeerKey("peer-a", a.identity.Key().PublicKey(), "")
+	if dialErr, _ := handshakePeers(t, a, impostor); dialErr == nil {
+		t.Error("Expected handshake with unexpected peer key to fail")
+	}
+
+	// Смену ключа, подписанную закрепленным ключом, relay передать может
+	if err := b.RotateIdentity(); err != nil {
+		t.Fatalf("RotateIdentity failed: %v", err)
+	}
+	rotated := b.identity.Key().PublicKey()
+	if key := a.rememberPeerKey("peer-b", rotated, b.identity.Signature()); key != rotated {
+		t.Fatalf("Expected signed rotation to be accepted, got %q", key)
+	}
+	if dialErr, acceptErr := handshakePeers(t, a, b); dialErr != nil || acceptErr != nil {
+		t.Fatalf("Expected handshake after rotation to succeed: dial=%v accept=%v", dialErr, acceptErr)
+	}
+
+	// Закрепленные ключи переживают перезапуск
+	pins := identity.NewPinStore(a.identityConfig.PinFile, a.logger)
+	if err := pins.Load(); err != nil {
+		t.Fatalf("Failed to load pinned keys: %v", err)
+	}
+	if pinned, ok := pins.Lookup("peer-b"); !ok || pinned != rotated {
+		t.Errorf("Expected rotated key to be pinned on disk, got %q", pinned)
+	}
+}
+
+func TestManager_UnknownPeerKey(t *testing.T) {
+	a := newIdentityTestManager(t, "peer-a")
+	b := newIdentityTestManager(t, "peer-b")
+	b.rememberPeerKey("peer-a", a.identity.Key().PublicKey(), "")
+	a.rememberPeerKey("peer-b", "generated-quic-key-1", "")
+
+	// По умолчанию пир без ключа идентичности отклоняется
+	if err := a.verifyPeerKey("peer-b", nil); !errors.Is(err, identity.ErrNotIdentityKey) {
+		t.Errorf("Expected peer without identity key to be rejected, got %v", err)
+	}
+	if dialErr, _ := handshakePeers(t, a, b); dialErr == nil {
+		t.Error("Expected handshake with unknown peer key to fail")
+	}
+
+	// Без RequirePeerKeys соединение устанавливается, но не закрепляется
+	a.identityConfig.RequirePeerKeys = false
+	if dialErr, acceptErr := handshakePeers(t, a, b); dialErr != nil || acceptErr != nil {
+		t.Fatalf("Expected unpinned handshake to succeed: dial=%v accept=%v", dialErr, acceptErr)
+	}
+	if _, ok := a.pins.Lookup("peer-b"); ok {
+		t.Error("Expected unknown peer key not to be pinned")
+	}
+}
+
+func TestManager_RotateIdentity(t *testing.T) {
+	m := newIdentityTestManager(t, "peer-a")
+	before := m.identity.Key().PublicKey()
+
+	if err := m.RotateIdentity(); err != nil {
+		t.Fatalf("RotateIdentity failed: %v", err)
+	}
+	after := m.identity.Key().PublicKey()
+	if after == before {
+		t.Fatal("Expected a new identity key")
+	}
+
+	// Новый ключ сохранен и используется в сертификате пира
+	store := identity.NewStore(&identity.Config{KeyFile: m.identityConfig.KeyFile}, m.logger)
+	key, err := store.Load()
+	if err != nil || key.PublicKey() != after {
+		t.Errorf("Expected rotated key to be persisted, got %v", err)
+	}
+	pinned, _ := identity.ParsePublicKey(after) //nolint:errcheck // own key is always valid
+	if err := identity.VerifyCertificate(m.tlsCert.Certificate[0], pinned); err != nil {
+		t.Errorf("Expected peer certificate on rotated key: %v", err)
+	}
+}
@@ -2,15 +2,25 @@ package p2p
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
 	"github.com/2gc-dev/cloudbridge-client/pkg/api"
 	"github.com/2gc-dev/cloudbridge-client/pkg/auth"
-	"github.com/2gc-dev/cloudbridge-client/pkg/ice"
+	"github.com/2gc-dev/cloudbridge-client/pkg/derp"
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
+	"github.com/2gc-dev/cloudbridge-client/pkg/meshdns"
+	"github.com/2gc-dev/cloudbridge-client/pkg/portmap"
 	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+	"github.com/2gc-dev/cloudbridge-client/pkg/signaling"
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
 	"github.com/golang-jwt/jwt/v5"
 	pionice "github.com/pion/ice/v2"
 	quicgo "github.com/quic-go/quic-go"
@@ -19,7 +29,6 @@ import (
 // Manager handles P2P connections using QUIC + ICE/STUN/TURN
 type Manager struct {
 	config          *P2PConfig
-	iceAgent        *ice.ICEAgent
 	quicConn        *quic.QUICConnection
 	mesh            *MeshNetwork
 	status          *P2PStatus
@@ -34,17 +43,49 @@ type Manager struct {
 	tenantID        string
 	token           string
 	relaySessionID  string
+	relayAddr       string           // QUIC адрес relay (host:port) выбранного POP
+	stunServers     []string         // STUN серверы из конфигурации клиента / discovery
+	turnServers     []string         // TURN серверы из конфигурации клиента / discovery
+	turnStatic      turnCredentials  // статические TURN credentials из конфигурации
+	turnREST        *turnCredentials // краткоживущие TURN credentials из relay API
+	turnMu          sync.Mutex       // сериализует получение turnREST
 	connections     map[string]*PeerConnection
+	sessions        map[string]*PeerSession // ICE сессии по peer ID, включая незавершенные
+	tlsCert         tls.Certificate         // сертификат для приема QUIC соединений от пиров
+	incoming        *incomingRequests       // обработанные входящие запросы соединения
+	signaling       *signaling.Client       // push канал сигналинга (nil — только опрос API)
+	signalingURL    string                  // WebSocket endpoint push сигналинга
+	signalingTLS    bool                    // InsecureSkipVerify для push сигналинга
+	derp            *derp.Client            // DERP клиент (nil — DERP fallback выключен)
+	derpServers     []string                // DERP серверы в порядке приоритета
+	derpTLS         bool                    // InsecureSkipVerify для DERP
+	probes          map[string]*PeerSession // пробные сессии перевода на прямой путь по peer ID
+	peerKeys        map[string]string       // публичные ключи пиров (адреса в DERP) по peer ID
+	approvals       *peerApprovals          // подтверждение пиров при AutoApprove=false
+	aclConfig       *acl.Config             // источники политики доступа помимо JWT (nil — только JWT)
+	acl             *acl.Manager            // политика доступа mesh (nil — все разрешено)
+	identityConfig  *identity.Config        // ключ идентичности (nil — временный сертификат без закрепления)
+	identity        *identity.Store         // постоянный ключ идентичности клиента
+	pins            *identity.PinStore      // ключи пиров, закрепленные при первом соединении
+	dnsConfig       *meshdns.Config         // DNS mesh сети (nil — выключен)
+	dnsSystem       string                  // настройка системного резолвера (пусто — не настраивать)
+	dns             *meshdns.Server         // DNS mesh сети на адресе overlay
+	dnsRestore      func() error            // восстановление системного резолвера
+	wgDeviceConfig  *wireguard.Config       // встроенный WireGuard (nil — интерфейс ядра)
+	wgDevice        wireguard.Tunnel        // устройство WireGuard overlay сети
+	wgSync          *wireguard.Reconciler   // синхронизация пиров устройства с mesh discovery
+	routesConfig    *RoutesConfig           // объявляемые и используемые маршруты (nil — только overlay)
+	routesBase      []string                // AllowedIPs регистрации без объявленных маршрутов
+	forwardRestore  func() error            // восстановление настроек пересылки пакетов
+	portMapConfig   *portmap.Config         // отображение порта на роутере (nil — выключено)
+	listenPort      int                     // порт общего ICE сокета (0 — случайный)
+	portMapper      *portmap.Client         // клиент PCP/NAT-PMP/UPnP отображения
+	udpMux          pionice.UniversalUDPMux // общий ICE сокет всех сессий (nil — сокет на агент)
 	heartbeatTicker *time.Ticker
 	// L3-overlay network fields
 	peerIP          string
 	tenantCIDR      string
 	wireguardConfig string
-	// ICE credentials for signaling
-	localUfrag  string
-	localPwd    string
-	remoteUfrag string
-	remotePwd   string
 }
 
 // Logger interface for P2P manager logging
@@ -60,6 +101,7 @@ type PeerConnection struct {
 	PeerID      string
 	SessionID   string
 	Stream      *quicgo.Stream
+	Path        PathType // direct (ICE) или relay
 	ConnectedAt time.Time
 	LastSeen    time.Time
 }
@@ -83,6 +125,11 @@ func NewManager(config *P2PConfig, logger Logger) *Manager {
 		cancel:      cancel,
 		logger:      logger,
 		connections: make(map[string]*PeerConnection),
+		sessions:    make(map[string]*PeerSession),
+		probes:      make(map[string]*PeerSession),
+		peerKeys:    make(map[string]string),
+		approvals:   newPeerApprovals(),
+		incoming:    &incomingRequests{handled: make(map[string]time.Time)},
 	}
 }
 
@@ -119,11 +166,17 @@ func NewManagerWithAPI(config *P2PConfig, apiConfig *api.ManagerConfig,
 		config:          config,
 		apiManager:      apiManager,
 		wireguardClient: wireguardClient,
+		relayAddr:       relayAddrFromBaseURL(apiConfig.BaseURL),
 		status:          &P2PStatus{ConnectionType: config.ConnectionType, MeshEnabled: true},
 		ctx:             ctx,
 		cancel:          cancel,
 		logger:          logger,
 		connections:     make(map[string]*PeerConnection),
+		sessions:        make(map[string]*PeerSession),
+		probes:          make(map[string]*PeerSession),
+		peerKeys:        make(map[string]string),
+		approvals:       newPeerApprovals(),
+		incoming:        &incomingRequests{handled: make(map[string]time.Time)},
 	}
 }
 
@@ -137,11 +190,20 @@ func (m *Manager) Start() error {
 	// Generate session ID
 	m.sessionID = fmt.Sprintf("sess_%d", time.Now().UnixNano())
 
+	// Ключ идентичности публикуется при регистрации
+	if err := m.startIdentity(); err != nil {
+		return err
+	}
+
 	// Start HTTP API manager if available
 	if m.apiManager != nil {
 		if err := m.apiManager.Start(); err != nil {
 			return fmt.Errorf("failed to start API manager: %w", err)
 		}
+		// Подсети LAN и маршрут по умолчанию объявляются в AllowedIPs пира
+		if err := m.advertiseRoutesLocked(); err != nil {
+			m.logger.Error("Failed to advertise routes", "error", err)
+		}
 		// Заполняем обязательные поля после успешного старта API manager
 		m.peerID = m.apiManager.GetPeerID()
 		m.tenantID = m.config.TenantID
@@ -155,9 +217,9 @@ func (m *Manager) Start() error {
 		"interval", m.config.HeartbeatInterval,
 		"timeout", m.config.HeartbeatTimeout)
 
-	// Initialize ICE agent
-	if err := m.initializeICE(); err != nil {
-		return fmt.Errorf("failed to initialize ICE: %w", err)
+	// ICE агенты создаются на каждую сессию с пиром
+	if stunServers, _ := m.iceServersLocked(); len(stunServers) == 0 {
+		m.logger.Warn("No STUN servers configured, direct P2P connections will use host candidates only")
 	}
 
 	// Initialize QUIC connection
@@ -170,33 +232,30 @@ func (m *Manager) Start() error {
 		return fmt.Errorf("failed to connect to relay server: %w", err)
 	}
 
-	// Exchange ICE credentials for P2P connectivity
-	if err := m.ExchangeICECredentials(); err != nil {
-		m.logger.Warn("Failed to exchange ICE credentials", "error", err)
-		// Продолжаем работу без ICE - fallback на relay
-	} else {
-		m.logger.Info("ICE credentials exchanged successfully")
-	}
-
 	// Get and apply WireGuard configuration for L3-overlay network
 	if m.wireguardClient != nil {
-		if err := m.ApplyWireGuardConfig(); err != nil {
+		if err := m.applyWireGuardConfigLocked(); err != nil {
 			m.logger.Warn("Failed to apply WireGuard config", "error", err)
 		} else {
 			m.logger.Info("L3-overlay network configured and applied",
-				"peer_ip", m.GetPeerIP(),
-				"tenant_cidr", m.GetTenantCIDR())
-
-			// Обновляем mesh сеть с WireGuard информацией
-			if err := m.UpdateMeshWithWireGuard(); err != nil {
-				m.logger.Warn("Failed to update mesh with WireGuard", "error", err)
-			}
+				"peer_ip", m.peerIP,
+				"tenant_cidr", m.tenantCIDR)
 		}
 	}
 
-	// Start peer discovery
+	m.startACL()
+
+	// Start peer discovery and the listener for connections initiated by other peers.
+	// Push канал доставляет события сразу, опрос API остается запасным вариантом.
+	// Соединения через DERP/relay периодически пробуют перейти на прямой путь.
 	if m.apiManager != nil {
+		m.startPushSignaling()
+		m.startDERP()
+		m.startPortMapping()
 		go m.startPeerDiscovery()
+		go m.startSignalingListener()
+		go m.startInterfaceMonitor()
+		go m.startPathUpgrader()
 	}
 
 	// Start heartbeat routine
@@ -205,11 +264,28 @@ func (m *Manager) Start() error {
 	// Create mesh network
 	if m.config.MeshConfig != nil {
 		m.mesh = NewMeshNetwork(m.config.MeshConfig, m.logger)
+		if m.peerID != "" {
+			m.mesh.SetLocalPeerID(m.peerID)
+		}
+		m.mesh.OnPeerDead(m.closeDeadPeer)
+		m.mesh.SetPeerWhitelist(m.config.PeerWhitelist)
+		m.mesh.SetRouteFilter(m.routeFilterLocked())
 		if err := m.mesh.Start(); err != nil {
 			return fmt.Errorf("failed to start mesh network: %w", err)
 		}
+
+		// Обновляем mesh сеть с WireGuard информацией
+		if m.wireguardConfig != "" {
+			if err := m.updateMeshWithWireGuardLocked(); err != nil {
+				m.logger.Warn("Failed to update mesh with WireGuard", "error", err)
+			}
+		}
 	}
 
+	// Имена пиров разрешаются после настройки overlay сети
+	m.startMeshDNS()
+	m.startForwardingLocked()
+
 	// Start L3-overlay health monitoring
 	go m.MonitorL3OverlayHealth()
 
@@ -221,6 +297,20 @@ func (m *Manager) Start() error {
 
 // Stop stops the P2P manager and cleans up resources
 func (m *Manager) Stop() error {
+	// Канал сигналинга останавливается до захвата блокировки: его обработчик использует m.mu
+	m.mu.RLock()
+	signalingClient, derpClient := m.signaling, m.derp
+	m.mu.RUnlock()
+	if signalingClient != nil {
+		signalingClient.Stop()
+	}
+	if derpClient != nil {
+		derpClient.Stop()
+	}
+	m.stopPortMapping()
+	m.stopACL()
+	m.stopMeshDNS()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -239,14 +329,28 @@ func (m *Manager) Stop() error {
 		m.apiManager.Stop()
 	}
 
-	// Close all peer connections
-	for peerID, conn := range m.connections {
-		if err := conn.Stream.Close(); err != nil {
-			m.logger.Error("Failed to close peer connection", "peer_id", peerID, "error", err)
+	// Close all peer sessions (streams of established connections are closed with them)
+	for peerID, session := range m.sessions {
+		if err := session.Close(); err != nil {
+			m.logger.Error("Failed to close peer session", "peer_id", peerID, "error", err)
 		}
 	}
+	for _, probe := range m.probes {
+		_ = probe.Close() //nolint:errcheck // probe resources are released best-effort
+	}
+	m.sessions = make(map[string]*PeerSession)
+	m.probes = make(map[string]*PeerSession)
 	m.connections = make(map[string]*PeerConnection)
 
+	// Общий ICE сокет закрывается после агентов всех сессий
+	if m.udpMux != nil {
+		if err := m.udpMux.Close(); err != nil {
+			m.logger.Error("Failed to close shared ICE socket", "error", err)
+		}
+		m.udpMux = nil
+	}
+	m.portMapper = nil
+
 	// Stop QUIC connection
 	if m.quicConn != nil {
 		if err := m.quicConn.Close(); err != nil {
@@ -254,12 +358,8 @@ func (m *Manager) Stop() error {
 		}
 	}
 
-	// Stop ICE agent
-	if m.iceAgent != nil {
-		if err := m.iceAgent.Stop(); err != nil {
-			m.logger.Error("Failed to stop ICE agent", "error", err)
-		}
-	}
+	m.stopForwardingLocked()
+	m.stopWireGuardLocked()
 
 	// Stop mesh network
 	if m.mesh != nil {
@@ -273,36 +373,36 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// initializeICE initializes the ICE agent
-func (m *Manager) initializeICE() error {
-	m.logger.Info("Initializing ICE agent")
-
-	// STUN servers with correct format for pion/stun
-	stunServers := []string{"stun:edge.2gc.ru:19302"}
-
-	// Create ICE agent
-	m.iceAgent = ice.NewICEAgent(stunServers, []string{}, m.logger)
-
-	if err := m.iceAgent.Start(); err != nil {
-		return fmt.Errorf("failed to start ICE agent: %w", err)
-	}
-
-	// Gather candidates
-	candidates, err := m.iceAgent.GatherCandidates()
-	if err != nil {
-		return fmt.Errorf("failed to gather ICE candidates: %w", err)
+// iceServersLocked возвращает STUN/TURN серверы клиента; иначе — из network_config токена.
+// Вызывается под m.mu.
+func (m *Manager) iceServersLocked() (stunServers, turnServers []string) {
+	stunServers, turnServers = m.stunServers, m.turnServers
+	if len(stunServers) == 0 && m.config.NetworkConfig != nil {
+		stunServers = m.config.NetworkConfig.STUNServers
+		turnServers = m.config.NetworkConfig.TURNServers
 	}
-
-	m.logger.Info("ICE candidates gathered", "count", len(candidates))
-	return nil
+	return stunServers, turnServers
 }
 
 // initializeQUIC initializes the QUIC connection
 func (m *Manager) initializeQUIC() error {
 	m.logger.Info("Initializing QUIC connection")
 
+	// Самоподписанный сертификат для прямых QUIC соединений с пирами
+	cert, err := m.peerCertificateLocked()
+	if err != nil {
+		return fmt.Errorf("failed to create peer certificate: %w", err)
+	}
+	m.tlsCert = cert
+
 	// Create QUIC connection manager
 	m.quicConn = quic.NewQUICConnection(m.logger)
+	m.quicConn.SetServerTLSCert(cert)
+	// Прямые соединения пиров принимаются только на ICE сокете сессии
+	m.quicConn.SetConnectionHandler(func(conn *quicgo.Conn) {
+		m.logger.Warn("Rejecting QUIC connection outside of ICE session", "remote_addr", conn.RemoteAddr())
+		_ = conn.CloseWithError(0, "no ICE session") //nolint:errcheck // rejecting connection
+	})
 
 	// Start listening for incoming connections on ephemeral port to avoid conflicts
 	listenAddr := ":0" // ephemeral port to avoid conflicts with relay server
@@ -328,7 +428,16 @@ func (m *Manager) connectToRelayServer() error {
 
 	// Connect to relay server QUIC endpoint (derive from config)
 	relayAddr := m.deriveRelayAddrFromConfig()
+	if relayAddr == "" {
+		return fmt.Errorf("relay address is not configured")
+	}
 	m.logger.Info("Connecting to relay server", "address", relayAddr)
+	if host, _, err := net.SplitHostPort(relayAddr); err == nil {
+		m.quicConn.SetClientServerName(host)
+	}
+	if m.config.QUICConfig != nil && m.config.QUICConfig.InsecureSkipVerify {
+		m.quicConn.SetInsecureSkipVerify(true)
+	}
 
 	// Create QUIC connection to relay server
 	err := m.quicConn.Connect(m.ctx, relayAddr)
@@ -365,92 +474,292 @@ func (m *Manager) connectToRelayServer() error {
 	return nil
 }
 
-// ConnectToPeer establishes a connection to another peer
+// ConnectToPeer establishes a connection to another peer. Each peer gets its own
+// session, so calls for different peers may run concurrently; the number of
+// non-terminated sessions is limited by P2PConfig.MaxConnections.
 func (m *Manager) ConnectToPeer(targetPeerID string) error {
 	m.logger.Info("Connecting to peer", "target_peer_id", targetPeerID)
 
-	// 1. Gather ICE candidates
-	candidates, err := m.iceAgent.GatherCandidates()
+	session, err := m.newSession(targetPeerID, "", false)
 	if err != nil {
-		return fmt.Errorf("failed to gather candidates: %w", err)
+		return err
 	}
 
-	// 2. Send candidates to relay
-	if err := m.sendCandidatesToRelay(candidates); err != nil {
-		return fmt.Errorf("failed to send candidates to relay: %w", err)
+	// Relay маршрутизация mesh: весь трафик через relay, ICE не используется
+	if m.routingPolicy() == RoutingRelay {
+		if err := m.establishRelayConnection(session); err != nil {
+			return m.failSession(session, err)
+		}
+		return nil
 	}
 
-	// 3. Get remote candidates from relay
-	remoteCandidates, err := m.getRemoteCandidatesFromRelay(targetPeerID)
-	if err != nil {
-		return fmt.Errorf("failed to get remote candidates: %w", err)
+	// 1. Gather ICE candidates with the session's own agent and credentials
+	if err := session.gather(m.iceServers()); err != nil {
+		return m.failSession(session, err)
+	}
+
+	// 2. Exchange credentials and candidates through the relay API
+	if err := m.signalSession(session); err != nil {
+		return m.failSession(session, err)
+	}
+
+	// 3. Run connectivity checks; the peer with the lower ID is controlling
+	m.mu.RLock()
+	controlling := m.peerID < targetPeerID
+	m.mu.RUnlock()
+	if err := session.connectICE(controlling); err != nil {
+		return m.fallbackToRelay(session, fmt.Errorf("failed to establish connection: %w", err))
+	}
+
+	// 4. Establish QUIC connection over the ICE-selected pair
+	if err := m.establishQUICConnection(session); err != nil {
+		return m.fallbackToRelay(session, err)
+	}
+	return nil
+}
+
+// ConnectToPeers connects to several peers in parallel and returns errors by peer ID
+func (m *Manager) ConnectToPeers(peerIDs []string) map[string]error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures = make(map[string]error)
+	)
+	for _, peerID := range peerIDs {
+		wg.Add(1)
+		go func(peerID string) {
+			defer wg.Done()
+			if err := m.ConnectToPeer(peerID); err != nil {
+				mu.Lock()
+				failures[peerID] = err
+				mu.Unlock()
+			}
+		}(peerID)
+	}
+	wg.Wait()
+	return failures
+}
+
+// newSession регистрирует новую сессию с пиром с учетом лимита MaxConnections
+func (m *Manager) newSession(peerID, sessionID string, inbound bool) (*PeerSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.sessions[peerID]; ok {
+		if !existing.State().IsTerminal() {
+			return nil, fmt.Errorf("%w: %s", ErrSessionInProgress, peerID)
+		}
+		delete(m.sessions, peerID)
+	}
+	if err := m.checkPeerLocked(peerID); err != nil {
+		return nil, err
+	}
+
+	active := 0
+	for _, session := range m.sessions {
+		if !session.State().IsTerminal() {
+			active++
+		}
 	}
+	if m.config.MaxConnections > 0 && active >= m.config.MaxConnections {
+		return nil, fmt.Errorf("%w (%d)", ErrMaxConnections, m.config.MaxConnections)
+	}
+
+	session := newPeerSession(m.ctx, peerID, sessionID, inbound, m.logger)
+	session.onICEState = func(state pionice.ConnectionState) {
+		m.handleICEState(session, state)
+	}
+	m.sessions[peerID] = session
+	return session, nil
+}
+
+// failSession завершает сессию ошибкой; failed сессия остается в карте для диагностики
+func (m *Manager) failSession(session *PeerSession, err error) error {
+	m.mu.Lock()
+	disconnected := false
+	if conn, ok := m.connections[session.PeerID]; ok && conn.SessionID == session.SessionID {
+		delete(m.connections, session.PeerID)
+		disconnected = true
+	}
+	if probe, ok := m.probes[session.PeerID]; ok && probe == session {
+		delete(m.probes, session.PeerID)
+	}
+	m.mu.Unlock()
+	if disconnected {
+		m.updateMeshPath(session.PeerID, "", false)
+	}
+	return session.fail(err)
+}
+
+// ClosePeerSession закрывает сессию и соединение с пиром
+func (m *Manager) ClosePeerSession(peerID string) error {
+	m.mu.Lock()
+	session, ok := m.sessions[peerID]
+	probe, probing := m.probes[peerID]
+	delete(m.sessions, peerID)
+	delete(m.probes, peerID)
+	_, connected := m.connections[peerID]
+	delete(m.connections, peerID)
+	m.mu.Unlock()
+
+	if connected {
+		m.updateMeshPath(peerID, "", false)
+	}
+	if probing {
+		_ = probe.Close() //nolint:errcheck // probe resources are released best-effort
+	}
+	if !ok {
+		return fmt.Errorf("no session with peer %s", peerID)
+	}
+	return session.Close()
+}
+
+// GetPeerSession возвращает сессию с пиром
+func (m *Manager) GetPeerSession(peerID string) (*PeerSession, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[peerID]
+	return session, ok
+}
+
+// GetPeerSessions возвращает снимки всех сессий
+func (m *Manager) GetPeerSessions() []PeerSessionInfo {
+	m.mu.RLock()
+	sessions := make([]*PeerSession, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	m.mu.RUnlock()
 
-	// 4. Add remote candidates
-	for _, candidate := range remoteCandidates {
-		if err := m.iceAgent.AddRemoteCandidate(candidate); err != nil {
-			m.logger.Warn("Failed to add remote candidate", "candidate", candidate.String(), "error", err)
+	infos := make([]PeerSessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, session.Info())
+	}
+	return infos
+}
+
+// signalSession публикует credentials и кандидаты сессии и получает данные пира
+func (m *Manager) signalSession(session *PeerSession) error {
+	if !session.setState(SessionStateSignaling) {
+		return fmt.Errorf("session closed")
+	}
+
+	// Инициатор уведомляет пира, который отвечает через свой signaling listener
+	if !session.Inbound {
+		if err := m.apiManager.RequestP2PConnection(&api.P2PConnectionRequest{
+			SessionID:    session.SessionID,
+			TargetPeerID: session.PeerID,
+			Protocol:     PeerALPN,
+			Upgrade:      session.upgrade,
+		}); err != nil {
+			return fmt.Errorf("failed to request connection: %w", err)
 		}
 	}
 
-	// 5. Start connectivity checks с реальными credentials
-	if m.remoteUfrag == "" || m.remotePwd == "" {
-		return fmt.Errorf("ICE credentials not available - call ExchangeICECredentials() first")
+	if err := m.publishICECredentials(session); err != nil {
+		return err
+	}
+	if err := m.sendCandidatesToRelay(session); err != nil {
+		return fmt.Errorf("failed to send candidates to relay: %w", err)
 	}
+	m.pushSessionSignal(session)
 
-	if err := m.iceAgent.StartConnectivityChecks(m.remoteUfrag, m.remotePwd); err != nil {
-		return fmt.Errorf("failed to start connectivity checks: %w", err)
+	ufrag, pwd, remoteCandidates, err := m.awaitRemoteSignal(session)
+	if err != nil {
+		return err
 	}
+	return session.setRemote(ufrag, pwd, remoteCandidates)
+}
 
-	// 6. Wait for connection establishment
-	if err := m.waitForConnection(); err != nil {
-		return fmt.Errorf("failed to establish connection: %w", err)
+// awaitRemoteSignal ждет, пока пир опубликует credentials и кандидаты сессии.
+// Данные из push канала используются сразу; relay API опрашивается как запасной вариант,
+// реже, пока push канал подключен.
+func (m *Manager) awaitRemoteSignal(session *PeerSession) (string, string, []pionice.Candidate, error) {
+	ctx, cancel := context.WithTimeout(session.ctx, peerConnectTimeout)
+	defer cancel()
+
+	pollInterval := 500 * time.Millisecond
+	if m.pushConnected() {
+		pollInterval = defaultSignalingInterval
 	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	poll := true
+	err := fmt.Errorf("remote ICE credentials not published yet")
+	for {
+		if ufrag, pwd, candidates, ready := session.pushedRemote(); ready {
+			return ufrag, pwd, candidates, nil
+		}
 
-	// 7. Establish QUIC connection
-	return m.establishQUICConnection(targetPeerID)
+		if poll {
+			var ufrag, pwd string
+			ufrag, pwd, err = m.getRemoteICECredentials(session.PeerID)
+			if err == nil && (ufrag == "" || pwd == "") {
+				err = fmt.Errorf("remote ICE credentials not published yet")
+			}
+			if err == nil {
+				var candidates []pionice.Candidate
+				candidates, err = m.getRemoteCandidatesFromRelay(session)
+				if err == nil && len(candidates) > 0 {
+					return ufrag, pwd, candidates, nil
+				}
+				if err == nil {
+					err = fmt.Errorf("remote ICE candidates not published yet")
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", "", nil, fmt.Errorf("timed out waiting for peer signaling: %w", err)
+		case <-session.remoteSignal:
+			poll = false
+		case <-ticker.C:
+			poll = true
+		}
+	}
 }
 
-// sendCandidatesToRelay sends ICE candidates to the relay server
-func (m *Manager) sendCandidatesToRelay(candidates []pionice.Candidate) error {
+// sendCandidatesToRelay sends the session's ICE candidates to the relay server
+func (m *Manager) sendCandidatesToRelay(session *PeerSession) error {
 	if m.apiManager == nil {
 		return fmt.Errorf("API manager not available")
 	}
 
+	// Внешний адрес отображения порта на роутере добавляется к собранным кандидатам
+	candidates := m.advertisedCandidates(session)
+
 	// Convert ICE candidates to API format
 	apiCandidates := make([]*api.ICECandidate, len(candidates))
 	for i, candidate := range candidates {
-		apiCandidates[i] = &api.ICECandidate{
-			Foundation: candidate.Foundation(),
-			Component:  int(candidate.Component()),
-			Transport:  candidate.NetworkType().String(),
-			Priority:   int(candidate.Priority()),
-			Address:    candidate.Address(),
-			Port:       candidate.Port(),
-			Type:       string(candidate.Type()),
-		}
+		apiCandidates[i] = candidateToAPI(candidate)
 	}
 
 	// Send to relay
+	m.mu.RLock()
+	peerID := m.peerID
+	m.mu.RUnlock()
 	req := &api.ICESignalingRequest{
-		SessionID:  m.sessionID,
-		PeerID:     m.peerID,
-		Candidates: apiCandidates,
+		SessionID:    session.SessionID,
+		PeerID:       peerID,
+		TargetPeerID: session.PeerID,
+		Candidates:   apiCandidates,
 	}
 
 	return m.apiManager.SendICESignaling(req)
 }
 
-// getRemoteCandidatesFromRelay gets remote ICE candidates from relay
-func (m *Manager) getRemoteCandidatesFromRelay(targetPeerID string) ([]pionice.Candidate, error) {
+// getRemoteCandidatesFromRelay gets the peer's ICE candidates for the session from relay
+func (m *Manager) getRemoteCandidatesFromRelay(session *PeerSession) ([]pionice.Candidate, error) {
 	if m.apiManager == nil {
 		return nil, fmt.Errorf("API manager not available")
 	}
 
 	// Request remote candidates
 	req := &api.ICECandidateRequest{
-		SessionID:    m.sessionID,
-		TargetPeerID: targetPeerID,
+		SessionID:    session.SessionID,
+		TargetPeerID: session.PeerID,
 	}
 
 	resp, err := m.apiManager.GetRemoteICECandidates(req)
@@ -459,87 +768,253 @@ func (m *Manager) getRemoteCandidatesFromRelay(targetPeerID string) ([]pionice.C
 	}
 
 	// Convert API candidates to ICE candidates
-	candidates := make([]pionice.Candidate, len(resp.Candidates))
-	for i, apiCandidate := range resp.Candidates {
-		// Create candidate string in SDP format
-		candidateStr := fmt.Sprintf("candidate:%s %d %s %d %s %d typ %s",
-			apiCandidate.Foundation,
-			apiCandidate.Component,
-			apiCandidate.Transport,
-			apiCandidate.Priority,
-			apiCandidate.Address,
-			apiCandidate.Port,
-			apiCandidate.Type,
-		)
-
-		candidate, err := pionice.UnmarshalCandidate(candidateStr)
+	candidates := make([]pionice.Candidate, 0, len(resp.Candidates))
+	for _, apiCandidate := range resp.Candidates {
+		candidate, err := candidateFromAPI(apiCandidate)
 		if err != nil {
-			m.logger.Error("Failed to unmarshal candidate", "error", err, "candidate", candidateStr)
+			m.logger.Error("Failed to unmarshal candidate", "error", err)
 			continue
 		}
-		candidates[i] = candidate
+		candidates = append(candidates, candidate)
 	}
 
 	return candidates, nil
 }
 
-// waitForConnection waits for ICE connection to be established
-func (m *Manager) waitForConnection() error {
-	timeout := time.After(30 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+// candidateToAPI converts an ICE candidate to the relay API format
+func candidateToAPI(candidate pionice.Candidate) *api.ICECandidate {
+	return &api.ICECandidate{
+		Foundation: candidate.Foundation(),
+		Component:  int(candidate.Component()),
+		Transport:  candidate.NetworkType().String(),
+		Priority:   int(candidate.Priority()),
+		Address:    candidate.Address(),
+		Port:       candidate.Port(),
+		Type:       string(candidate.Type()),
+	}
+}
 
-	for {
-		select {
-		case <-timeout:
-			return fmt.Errorf("ICE connection timeout")
-		case <-ticker.C:
-			state := m.iceAgent.GetConnectionState()
-			if state == pionice.ConnectionStateConnected {
-				m.logger.Info("ICE connection established")
-				return nil
-			}
-		}
+// candidateFromAPI parses a relay API candidate via its SDP representation
+func candidateFromAPI(apiCandidate *api.ICECandidate) (pionice.Candidate, error) {
+	if apiCandidate == nil {
+		return nil, fmt.Errorf("empty candidate")
+	}
+	candidateStr := fmt.Sprintf("candidate:%s %d %s %d %s %d typ %s",
+		apiCandidate.Foundation,
+		apiCandidate.Component,
+		apiCandidate.Transport,
+		apiCandidate.Priority,
+		apiCandidate.Address,
+		apiCandidate.Port,
+		apiCandidate.Type,
+	)
+	candidate, err := pionice.UnmarshalCandidate(candidateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal candidate %q: %w", candidateStr, err)
+	}
+	return candidate, nil
+}
+
+// establishQUICConnection dials the peer over the ICE-selected candidate pair,
+// so peer traffic goes directly (or via TURN) and not through the relay
+func (m *Manager) establishQUICConnection(session *PeerSession) error {
+	session.mu.RLock()
+	iceConn := session.iceConn
+	session.mu.RUnlock()
+	if iceConn == nil {
+		return fmt.Errorf("ICE connection not established")
+	}
+
+	quicConn, stream, err := m.dialPeerQUIC(session, newICEPacketConn(iceConn), iceConn.RemoteAddr())
+	if err != nil {
+		return fmt.Errorf("failed to dial peer over ICE: %w", err)
 	}
+
+	path := session.icePath()
+	if err := m.registerConnection(session, quicConn, true, stream, path); err != nil {
+		return err
+	}
+
+	m.logger.Info("Direct QUIC connection established",
+		"peer_id", session.PeerID,
+		"path", path,
+		"remote_addr", iceConn.RemoteAddr().String())
+	return nil
 }
 
-// establishQUICConnection establishes QUIC connection to peer
-func (m *Manager) establishQUICConnection(targetPeerID string) error {
-	// Get selected candidate pair
-	pair, err := m.iceAgent.GetSelectedCandidatePair()
+// dialPeerQUIC устанавливает QUIC соединение с пиром поверх pconn и открывает stream пира
+func (m *Manager) dialPeerQUIC(session *PeerSession, pconn net.PacketConn, addr net.Addr) (*quic.QUICConnection, *quicgo.Stream, error) {
+	quicConn := quic.NewQUICConnection(m.logger)
+	quicConn.SetALPN(PeerALPN)
+	// Сертификат пира самоподписанный: цепочка не проверяется, подлинность пира
+	// проверяет pinPeerTLS по закрепленному ключу идентичности
+	quicConn.SetInsecureSkipVerify(true)
+	m.pinPeerTLS(quicConn, session.PeerID, true)
+	quicConn.EnableDatagrams()
+
+	ctx, cancel := context.WithTimeout(session.ctx, peerConnectTimeout)
+	defer cancel()
+
+	if err := quicConn.DialOn(ctx, pconn, addr); err != nil {
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed dial
+		return nil, nil, err
+	}
+
+	stream, err := quicConn.CreateStream(ctx, fmt.Sprintf("peer_%s", session.PeerID))
 	if err != nil {
-		return fmt.Errorf("failed to get selected candidate pair: %w", err)
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed dial
+		return nil, nil, fmt.Errorf("failed to create QUIC stream: %w", err)
 	}
 
-	if pair == nil {
-		return fmt.Errorf("no candidate pair selected")
+	// Stream становится виден пиру только после отправки данных
+	if err := writePeerHello(stream, session.SessionID); err != nil {
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed dial
+		return nil, nil, fmt.Errorf("failed to send peer hello: %w", err)
+	}
+	return quicConn, stream, nil
+}
+
+// fallbackToRelay переводит сессию на DERP (если настроен) или на relay stream,
+// если прямое соединение не удалось. Direct маршрутизация mesh fallback не использует.
+func (m *Manager) fallbackToRelay(session *PeerSession, cause error) error {
+	useDERP := m.derpClient() != nil
+	if m.routingPolicy() == RoutingDirect || (!useDERP && !m.config.RelayFallback) {
+		return m.failSession(session, cause)
+	}
+
+	m.logger.Warn("Direct P2P connection failed, falling back to relay",
+		"peer_id", session.PeerID,
+		"session_id", session.SessionID,
+		"derp", useDERP,
+		"error", cause)
+
+	if err := session.releaseICE(); err != nil {
+		m.logger.Debug("Failed to release ICE resources", "peer_id", session.PeerID, "error", err)
+	}
+	if useDERP {
+		err := m.establishDERPConnection(session)
+		if err == nil {
+			return nil
+		}
+		if !m.config.RelayFallback {
+			return m.failSession(session, fmt.Errorf("%v; DERP fallback failed: %w", cause, err))
+		}
+		m.logger.Warn("DERP fallback failed, using relay stream", "peer_id", session.PeerID, "error", err)
 	}
+	if err := m.establishRelayConnection(session); err != nil {
+		return m.failSession(session, fmt.Errorf("%v; relay fallback failed: %w", cause, err))
+	}
+	return nil
+}
 
-	// Connect to remote peer via QUIC
-	remoteAddr := fmt.Sprintf("%s:%d", pair.Remote.Address(), pair.Remote.Port())
+// establishRelayConnection opens the peer's stream on the relay QUIC connection;
+// the relay routes it by the TO:<peer_id>: header (same framing as cmd/quic-tester)
+func (m *Manager) establishRelayConnection(session *PeerSession) error {
+	if m.quicConn == nil || !m.quicConn.IsConnected() {
+		return fmt.Errorf("relay connection not available")
+	}
 
-	stream, err := m.quicConn.CreateStream(m.ctx, fmt.Sprintf("peer_%s", targetPeerID))
+	stream, err := m.quicConn.CreateStream(session.ctx, fmt.Sprintf("peer_%s", session.PeerID))
 	if err != nil {
-		return fmt.Errorf("failed to create QUIC stream: %w", err)
+		return fmt.Errorf("failed to create relay stream: %w", err)
+	}
+
+	header := fmt.Sprintf("%s%s:", relayToPrefix, session.PeerID)
+	if _, err := stream.Write([]byte(header)); err != nil {
+		_ = stream.Close() //nolint:errcheck // cleanup after failed write
+		return fmt.Errorf("failed to send relay header: %w", err)
+	}
+	if err := writePeerHello(stream, session.SessionID); err != nil {
+		_ = stream.Close() //nolint:errcheck // cleanup after failed write
+		return fmt.Errorf("failed to send peer hello: %w", err)
+	}
+
+	if err := m.registerConnection(session, m.quicConn, false, stream, PathRelay); err != nil {
+		return err
+	}
+
+	m.logger.Info("P2P connection established via relay", "peer_id", session.PeerID)
+	return nil
+}
+
+// registerConnection завершает сессию успехом и регистрирует пира в connections.
+// Установленная пробная сессия заменяет текущую сессию с пиром.
+func (m *Manager) registerConnection(session *PeerSession, quicConn *quic.QUICConnection, ownsQUIC bool,
+	stream *quicgo.Stream, path PathType) error {
+	session.mu.Lock()
+	session.quicConn = quicConn
+	session.ownsQUIC = ownsQUIC
+	session.stream = stream
+	session.path = path
+	session.mu.Unlock()
+	if !session.setState(SessionStateConnected) {
+		// Сессия закрыта параллельно — освобождаем то, что успели открыть
+		_ = session.release() //nolint:errcheck // best-effort cleanup
+		return fmt.Errorf("session closed")
 	}
 
-	// Store connection
 	conn := &PeerConnection{
-		PeerID:      targetPeerID,
-		SessionID:   m.sessionID,
+		PeerID:      session.PeerID,
+		SessionID:   session.SessionID,
 		Stream:      stream,
+		Path:        path,
 		ConnectedAt: time.Now(),
 		LastSeen:    time.Now(),
 	}
 
 	m.mu.Lock()
-	m.connections[targetPeerID] = conn
+	var replaced *PeerSession
+	if session.upgrade {
+		if probe, ok := m.probes[session.PeerID]; ok && probe == session {
+			delete(m.probes, session.PeerID)
+		}
+		if current, ok := m.sessions[session.PeerID]; ok && current != session {
+			replaced = current
+		}
+		m.sessions[session.PeerID] = session
+	}
+	m.connections[session.PeerID] = conn
 	m.mu.Unlock()
-
-	m.logger.Info("QUIC connection established", "peer_id", targetPeerID, "remote_addr", remoteAddr)
+	m.updateMeshPath(session.PeerID, path, true)
+	m.updateWireGuardPath(session.PeerID, path, session.Info().RemoteAddr)
+	if ownsQUIC {
+		m.startPeerPing(session, quicConn, peerPingInterval)
+	}
+
+	if replaced != nil {
+		m.logger.Info("P2P connection upgraded",
+			"peer_id", session.PeerID,
+			"from_path", replaced.Info().Path,
+			"to_path", path,
+			"session_id", session.SessionID)
+		if err := replaced.Close(); err != nil {
+			m.logger.Debug("Failed to close replaced P2P session", "peer_id", session.PeerID, "error", err)
+		}
+	}
 	return nil
 }
 
+// routingPolicy возвращает стратегию маршрутизации mesh (hybrid, если mesh не настроен)
+func (m *Manager) routingPolicy() string {
+	if m.config.MeshConfig == nil || m.config.MeshConfig.Routing == "" {
+		return RoutingHybrid
+	}
+	return m.config.MeshConfig.Routing
+}
+
+// updateMeshPath передает mesh сведения о локальном соединении с пиром для маршрутизации
+func (m *Manager) updateMeshPath(peerID string, path PathType, connected bool) {
+	m.mu.RLock()
+	mesh := m.mesh
+	m.mu.RUnlock()
+	if mesh != nil {
+		mesh.SetPeerPath(peerID, path, connected)
+	}
+	if !connected {
+		m.updateWireGuardPath(peerID, "", "")
+	}
+}
+
 // GetStatus returns the current P2P status
 func (m *Manager) GetStatus() *P2PStatus {
 	m.mu.RLock()
@@ -547,6 +1022,10 @@ func (m *Manager) GetStatus() *P2PStatus {
 
 	status := *m.status
 	status.ActiveConnections = len(m.connections)
+	status.PeerPaths = make(map[string]PathType, len(m.connections))
+	for peerID, conn := range m.connections {
+		status.PeerPaths[peerID] = conn.Path
+	}
 
 	// Update L3-overlay network status
 	status.L3OverlayReady = m.IsL3OverlayReady()
@@ -557,6 +1036,13 @@ func (m *Manager) GetStatus() *P2PStatus {
 	return &status
 }
 
+// GetRelaySessionID returns the P2P session ID registered on the relay
+func (m *Manager) GetRelaySessionID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.relaySessionID
+}
+
 // GetActivePeers returns the number of active peers
 func (m *Manager) GetActivePeers() int {
 	m.mu.RLock()
@@ -573,7 +1059,9 @@ func (m *Manager) GetTotalPeers() int {
 	return 0
 }
 
-// startPeerDiscovery starts the peer discovery process
+// startPeerDiscovery starts the peer discovery process.
+// While the push signaling channel is connected, peer join/leave events arrive
+// through it and polling is skipped.
 func (m *Manager) startPeerDiscovery() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -583,30 +1071,31 @@ func (m *Manager) startPeerDiscovery() {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			if m.apiManager != nil {
-				resp, err := m.apiManager.DiscoverPeers()
-				if err != nil {
-					m.logger.Error("Failed to discover peers", "error", err)
-					continue
+			if m.apiManager == nil || m.pushConnected() {
+				continue
+			}
+
+			resp, err := m.apiManager.DiscoverPeers()
+			if err != nil {
+				m.logger.Error("Failed to discover peers", "error", err)
+				continue
+			}
+
+			if resp.Success {
+				m.logger.Info("Discovered peers", "count", len(resp.Peers))
+
+				peers := make([]*Peer, 0, len(resp.Peers))
+				for _, peer := range resp.Peers {
+					peers = append(peers, m.trustedPeerFromAPI(peer))
 				}
 
-				if resp.Success {
-					m.logger.Info("Discovered peers", "count", len(resp.Peers))
-
-					// Update mesh network
-					if m.mesh != nil {
-						for _, peer := range resp.Peers {
-							p2pPeer := &Peer{
-								ID:          peer.PeerID,
-								PublicKey:   peer.PublicKey,
-								Endpoint:    peer.Endpoint,
-								AllowedIPs:  peer.AllowedIPs,
-								LastSeen:    time.Now().Unix(),
-								IsConnected: peer.IsOnline,
-							}
-							if err := m.mesh.AddPeer(p2pPeer); err != nil {
-								m.logger.Error("Failed to add peer to mesh", "peer_id", peer.PeerID, "error", err)
-							}
+				// Update mesh network
+				if m.mesh != nil {
+					for _, peer := range peers {
+						if err := m.mesh.AddPeer(peer); errors.Is(err, ErrPeerNotAllowed) || errors.Is(err, ErrMaxPeers) {
+							m.logger.Debug("Skipping discovered peer", "peer_id", peer.ID, "error", err)
+						} else if err != nil {
+							m.logger.Error("Failed to add peer to mesh", "peer_id", peer.ID, "error", err)
 						}
 					}
 				}
@@ -615,6 +1104,26 @@ func (m *Manager) startPeerDiscovery() {
 	}
 }
 
+// trustedPeerFromAPI converts a discovered peer to a mesh peer with the key the client
+// trusts: a pinned identity key is not replaced by the relay
+func (m *Manager) trustedPeerFromAPI(peer *api.Peer) *Peer {
+	p := peerFromAPI(peer)
+	p.PublicKey = m.rememberPeerKey(peer.PeerID, peer.PublicKey, peer.KeySignature)
+	return p
+}
+
+// peerFromAPI converts a discovered peer to a mesh peer
+func peerFromAPI(peer *api.Peer) *Peer {
+	return &Peer{
+		ID:          peer.PeerID,
+		PublicKey:   peer.PublicKey,
+		Endpoint:    peer.Endpoint,
+		AllowedIPs:  peer.AllowedIPs,
+		LastSeen:    time.Now().Unix(),
+		IsConnected: peer.IsOnline,
+	}
+}
+
 // ExtractP2PConfigFromToken extracts P2P configuration from JWT token
 func ExtractP2PConfigFromToken(authManager *auth.AuthManager, token *jwt.Token) (*P2PConfig, error) {
 	// Extract connection type
@@ -641,6 +1150,12 @@ func ExtractP2PConfigFromToken(authManager *auth.AuthManager, token *jwt.Token)
 		return nil, fmt.Errorf("failed to extract peer whitelist: %w", err)
 	}
 
+	// Extract ACL policy
+	aclPolicy, err := authManager.ExtractACLPolicy(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract ACL policy: %w", err)
+	}
+
 	// Extract permissions
 	permissions, err := authManager.ExtractPermissions(token)
 	if err != nil {
@@ -676,13 +1191,9 @@ func ExtractP2PConfigFromToken(authManager *auth.AuthManager, token *jwt.Token)
 	var p2pNetworkConfig *NetworkConfig
 	if networkConfig != nil {
 		p2pNetworkConfig = &NetworkConfig{
-			Subnet:      networkConfig.Subnet,
-			DNS:         networkConfig.DNS,
-			MTU:         networkConfig.MTU,
-			STUNServers: []string{"edge.2gc.ru:19302"},
-			TURNServers: []string{},
-			QUICPort:    5553,
-			ICEPort:     19302,
+			Subnet: networkConfig.Subnet,
+			DNS:    networkConfig.DNS,
+			MTU:    networkConfig.MTU,
 		}
 	}
 
@@ -690,6 +1201,7 @@ func ExtractP2PConfigFromToken(authManager *auth.AuthManager, token *jwt.Token)
 		ConnectionType: ConnectionType(connectionType),
 		MeshConfig:     p2pMeshConfig,
 		PeerWhitelist:  p2pPeerWhitelist,
+		ACLPolicy:      aclPolicy,
 		NetworkConfig:  p2pNetworkConfig,
 		TenantID:       tenantID,
 		Permissions:    permissions,
@@ -772,6 +1284,13 @@ func (m *Manager) sendHeartbeat() error {
 
 // GetWireGuardConfig получает WireGuard конфигурацию для L3-overlay сети
 func (m *Manager) GetWireGuardConfig() (*api.WireGuardConfigResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getWireGuardConfigLocked()
+}
+
+// getWireGuardConfigLocked получает конфигурацию WireGuard из relay API. Вызывается под m.mu.
+func (m *Manager) getWireGuardConfigLocked() (*api.WireGuardConfigResponse, error) {
 	if m.wireguardClient == nil {
 		return nil, fmt.Errorf("WireGuard client not available")
 	}
@@ -801,11 +1320,13 @@ func (m *Manager) GetWireGuardConfig() (*api.WireGuardConfigResponse, error) {
 	}
 
 	// Store the configuration for later use
-	m.mu.Lock()
 	m.wireguardConfig = config.ClientConfig
 	m.peerIP = config.PeerIP
 	m.tenantCIDR = config.TenantCIDR
-	m.mu.Unlock()
+	if m.mesh != nil {
+		// Подсеть overlay определяет, какие маршруты пиров принимаются без согласия
+		m.mesh.SetRouteFilter(m.routeFilterLocked())
+	}
 
 	m.logger.Info("WireGuard config obtained",
 		"peer_ip", config.PeerIP,
@@ -842,25 +1363,29 @@ func (m *Manager) IsL3OverlayReady() bool {
 	return m.peerIP != "" && m.tenantCIDR != "" && m.wireguardConfig != ""
 }
 
-// ExchangeICECredentials обменивается ICE credentials с сервером
-func (m *Manager) ExchangeICECredentials() error {
+// publishICECredentials публикует credentials сессии для целевого пира
+func (m *Manager) publishICECredentials(session *PeerSession) error {
 	if m.apiManager == nil {
 		return fmt.Errorf("API manager not available")
 	}
 
-	// Генерируем локальные ICE credentials
-	m.localUfrag = m.generateRandomString(8)
-	m.localPwd = m.generateRandomString(22)
+	m.mu.RLock()
+	token, tenantID, peerID := m.token, m.tenantID, m.peerID
+	m.mu.RUnlock()
 
+	session.mu.RLock()
 	req := &api.ICECredentialsRequest{
-		Ufrag: m.localUfrag,
-		Pwd:   m.localPwd,
+		Ufrag:        session.localUfrag,
+		Pwd:          session.localPwd,
+		SessionID:    session.SessionID,
+		TargetPeerID: session.PeerID,
 	}
+	session.mu.RUnlock()
 
-	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(session.ctx, 30*time.Second)
 	defer cancel()
 
-	resp, err := m.apiManager.GetClient().ExchangeICECredentials(ctx, m.token, m.tenantID, m.peerID, req)
+	resp, err := m.apiManager.GetClient().ExchangeICECredentials(ctx, token, tenantID, peerID, req)
 	if err != nil {
 		return fmt.Errorf("failed to exchange ICE credentials: %w", err)
 	}
@@ -869,48 +1394,44 @@ func (m *Manager) ExchangeICECredentials() error {
 		return fmt.Errorf("ICE credentials exchange failed: %s", resp.Error)
 	}
 
-	// Сохраняем удаленные credentials
-	m.remoteUfrag = resp.Ufrag
-	m.remotePwd = resp.Pwd
-
-	m.logger.Info("ICE credentials exchanged successfully",
-		"local_ufrag", m.localUfrag,
-		"remote_ufrag", m.remoteUfrag)
-
+	m.logger.Debug("ICE credentials published",
+		"target_peer", session.PeerID,
+		"session_id", session.SessionID,
+		"local_ufrag", req.Ufrag)
 	return nil
 }
 
-// GetICECredentials получает ICE credentials от другого пира
-func (m *Manager) GetICECredentials(targetPeerID string) error {
+// getRemoteICECredentials получает ICE credentials целевого пира
+func (m *Manager) getRemoteICECredentials(targetPeerID string) (string, string, error) {
 	if m.apiManager == nil {
-		return fmt.Errorf("API manager not available")
+		return "", "", fmt.Errorf("API manager not available")
 	}
 
+	m.mu.RLock()
+	token, tenantID := m.token, m.tenantID
+	m.mu.RUnlock()
+
 	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
 	defer cancel()
 
-	resp, err := m.apiManager.GetClient().GetICECredentials(ctx, m.token, m.tenantID, targetPeerID)
+	resp, err := m.apiManager.GetClient().GetICECredentials(ctx, token, tenantID, targetPeerID)
 	if err != nil {
-		return fmt.Errorf("failed to get ICE credentials: %w", err)
+		return "", "", fmt.Errorf("failed to get ICE credentials: %w", err)
 	}
 
 	if !resp.Success {
-		return fmt.Errorf("get ICE credentials failed: %s", resp.Error)
+		return "", "", fmt.Errorf("get ICE credentials failed: %s", resp.Error)
 	}
 
-	// Сохраняем удаленные credentials
-	m.remoteUfrag = resp.Ufrag
-	m.remotePwd = resp.Pwd
-
-	m.logger.Info("ICE credentials received",
+	m.logger.Debug("ICE credentials received",
 		"target_peer", targetPeerID,
-		"remote_ufrag", m.remoteUfrag)
+		"remote_ufrag", resp.Ufrag)
 
-	return nil
+	return resp.Ufrag, resp.Pwd, nil
 }
 
 // generateRandomString генерирует случайную строку заданной длины
-func (m *Manager) generateRandomString(length int) string {
+func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, length)
 	for i := range b {
@@ -921,48 +1442,86 @@ func (m *Manager) generateRandomString(length int) string {
 
 // ApplyWireGuardConfig применяет WireGuard конфигурацию
 func (m *Manager) ApplyWireGuardConfig() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.applyWireGuardConfigLocked()
+}
+
+// applyWireGuardConfigLocked получает и применяет конфигурацию WireGuard: встроенным
+// WireGuard, если он включен, иначе интерфейсом ядра через netlink. Вызывается под m.mu.
+func (m *Manager) applyWireGuardConfigLocked() error {
 	if m.wireguardClient == nil {
 		return fmt.Errorf("WireGuard client not available")
 	}
 
 	// Получаем конфигурацию
-	config, err := m.GetWireGuardConfig()
+	config, err := m.getWireGuardConfigLocked()
 	if err != nil {
 		return fmt.Errorf("failed to get WireGuard config: %w", err)
 	}
 
-	// Сохраняем конфигурацию в файл
-	configPath := "/tmp/wg-client.conf"
-	if err := m.wireguardClient.SaveWireGuardConfig(config.ClientConfig, configPath); err != nil {
-		return fmt.Errorf("failed to save WireGuard config: %w", err)
-	}
-
-	// Применяем конфигурацию
-	appliedPath, err := m.wireguardClient.ApplyWireGuardConfig(configPath)
+	quick, err := wireguard.ParseQuickConfig(config.ClientConfig)
 	if err != nil {
-		return fmt.Errorf("failed to apply WireGuard config: %w", err)
+		return fmt.Errorf("failed to parse WireGuard config: %w", err)
+	}
+	// Ключ WireGuard выводится из ключа идентичности: пиры получают его из опубликованного ключа
+	if m.identity != nil {
+		quick.Interface.PrivateKey = m.identity.Key().WireGuardPrivateKey()
 	}
 
-	m.logger.Info("WireGuard configuration applied successfully",
-		"config_path", appliedPath,
-		"peer_ip", config.PeerIP,
-		"tenant_cidr", config.TenantCIDR)
+	// Устройство поднимается один раз, дальше пиров меняет только reconciler
+	if m.wgDevice == nil {
+		if m.wgDeviceConfig != nil {
+			if err := m.startUserspaceWireGuardLocked(quick); err != nil {
+				return err
+			}
+		} else {
+			device, err := m.wireguardClient.ApplyQuickConfig(quick)
+			if err != nil {
+				return fmt.Errorf("failed to apply WireGuard config: %w", err)
+			}
+			m.wgDevice = device
+			if m.acl != nil {
+				// Пакеты интерфейса ядра не проходят через клиент
+				m.logger.Warn("ACL port rules are not enforced on kernel WireGuard, use userspace mode",
+					"interface", device.Name())
+			}
+		}
+		m.logger.Info("WireGuard configuration applied successfully",
+			"interface", m.wgDevice.Name(),
+			"peer_ip", config.PeerIP,
+			"tenant_cidr", config.TenantCIDR)
+	}
 
+	m.syncWireGuardPeersLocked(quick.Peers)
 	return nil
 }
 
 // UpdateMeshWithWireGuard обновляет mesh сеть с информацией о WireGuard
 func (m *Manager) UpdateMeshWithWireGuard() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.updateMeshWithWireGuardLocked()
+}
+
+// updateMeshWithWireGuardLocked добавляет локального пира с адресами overlay в mesh.
+// Вызывается под m.mu.
+func (m *Manager) updateMeshWithWireGuardLocked() error {
 	if m.mesh == nil {
 		return fmt.Errorf("mesh network not available")
 	}
 
+	publicKey := ""
+	if m.apiManager != nil {
+		publicKey = m.apiManager.GetPublicKey()
+	}
+
 	// Создаем пира для mesh сети с WireGuard информацией
 	peer := &Peer{
 		ID:          m.peerID,
-		Endpoint:    m.GetPeerIP(),
-		PublicKey:   "", // TODO: получить из WireGuard конфига
-		AllowedIPs:  []string{m.GetTenantCIDR()},
+		Endpoint:    m.peerIP,
+		PublicKey:   publicKey,
+		AllowedIPs:  []string{m.tenantCIDR},
 		IsConnected: true,
 		Latency:     0,
 		LastSeen:    time.Now().Unix(),
@@ -985,16 +1544,11 @@ func (m *Manager) GetL3OverlayStatus() map[string]interface{} {
 	defer m.mu.RUnlock()
 
 	status := map[string]interface{}{
-		"ready":       m.IsL3OverlayReady(),
-		"peer_ip":     m.peerIP,
-		"tenant_cidr": m.tenantCIDR,
-		"has_config":  m.wireguardConfig != "",
-		"ice_credentials": map[string]interface{}{
-			"local_ufrag":  m.localUfrag,
-			"local_pwd":    m.localPwd != "",
-			"remote_ufrag": m.remoteUfrag,
-			"remote_pwd":   m.remotePwd != "",
-		},
+		"ready":        m.IsL3OverlayReady(),
+		"peer_ip":      m.peerIP,
+		"tenant_cidr":  m.tenantCIDR,
+		"has_config":   m.wireguardConfig != "",
+		"ice_sessions": m.sessionStatsLocked(),
 	}
 
 	// Добавляем информацию о mesh сети
@@ -1002,10 +1556,55 @@ func (m *Manager) GetL3OverlayStatus() map[string]interface{} {
 		meshStats := m.mesh.GetMeshStats()
 		status["mesh"] = meshStats
 	}
+	if m.signaling != nil {
+		status["signaling"] = m.signaling.GetMetrics()
+	}
+	if m.derp != nil {
+		status["derp"] = m.derp.GetMetrics()
+	}
+	if m.portMapper != nil {
+		status["port_mapping"] = m.portMapper.GetMetrics()
+	}
+	if m.acl != nil {
+		status["acl"] = m.acl.GetMetrics()
+	}
+	if m.identity != nil {
+		status["identity"] = m.identityStatusLocked()
+	}
+	if m.dns != nil {
+		status["dns"] = m.dns.GetMetrics()
+	}
+	if m.routesConfig != nil {
+		status["routes"] = m.routesStatusLocked()
+	}
+	if m.wgDevice != nil {
+		metrics := m.wgDevice.GetMetrics()
+		if m.wgSync != nil {
+			metrics["sync"] = m.wgSync.GetMetrics()
+		}
+		status["wireguard"] = metrics
+	}
+	if pending := m.PendingPeers(); len(pending) > 0 {
+		status["pending_peers"] = pending
+	}
+	paths := make(map[PathType]int)
+	for _, conn := range m.connections {
+		paths[conn.Path]++
+	}
+	status["paths"] = paths
 
 	return status
 }
 
+// sessionStatsLocked возвращает число сессий по состояниям; вызывается под m.mu
+func (m *Manager) sessionStatsLocked() map[SessionState]int {
+	stats := make(map[SessionState]int)
+	for _, session := range m.sessions {
+		stats[session.State()]++
+	}
+	return stats
+}
+
 // MonitorL3OverlayHealth мониторит здоровье L3-overlay сети
 func (m *Manager) MonitorL3OverlayHealth() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -1023,10 +1622,10 @@ func (m *Manager) MonitorL3OverlayHealth() {
 				m.logger.Warn("L3-overlay network not ready", "status", status)
 			}
 
-			// Проверяем ICE credentials
-			iceCreds := status["ice_credentials"].(map[string]interface{})
-			if iceCreds["remote_ufrag"].(string) == "" {
-				m.logger.Debug("ICE credentials not available - using relay fallback")
+			// Проверяем наличие прямых ICE соединений
+			iceSessions := status["ice_sessions"].(map[SessionState]int)
+			if iceSessions[SessionStateConnected] == 0 {
+				m.logger.Debug("No direct ICE sessions - using relay fallback")
 			}
 
 			// Логируем статистику mesh сети
@@ -1040,9 +1639,43 @@ func (m *Manager) MonitorL3OverlayHealth() {
 	}
 }
 
+// SetRelayAddress задает QUIC адрес релэя (например, после выбора лучшего POP)
+func (m *Manager) SetRelayAddress(addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.relayAddr = addr
+}
+
+// SetICEServers задает STUN/TURN серверы (host:port) для ICE
+func (m *Manager) SetICEServers(stunServers, turnServers []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stunServers = stunServers
+	m.turnServers = turnServers
+}
+
+// SetSignalingEndpoint задает WebSocket endpoint push сигналинга (ws:// или wss://)
+func (m *Manager) SetSignalingEndpoint(endpoint string, insecureSkipVerify bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signalingURL = endpoint
+	m.signalingTLS = insecureSkipVerify
+}
+
 // deriveRelayAddrFromConfig извлекает адрес релэя из конфигурации
 func (m *Manager) deriveRelayAddrFromConfig() string {
-	// TODO: Реализовать извлечение адреса из BaseURL конфигурации
-	// Пока используем дефолтный адрес для тестирования
-	return "edge.2gc.ru:5553"
+	return m.relayAddr
+}
+
+// relayAddrFromBaseURL извлекает host:port из URL API (порт по умолчанию 443)
+func relayAddrFromBaseURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
 }
@@ -4,11 +4,26 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// RelayHopID хоп маршрута через relay сервер
+	RelayHopID = "relay"
+
+	// defaultRelayLatency оценка задержки до relay, пока она не измерена (мс)
+	defaultRelayLatency int64 = 50
+	// hybridRelayPenalty надбавка к участкам через relay в hybrid маршрутизации (мс):
+	// при сравнимой задержке выбирается P2P путь
+	hybridRelayPenalty int64 = 50
+	// minLinkLatency вес участка с неизмеренной задержкой (мс)
+	minLinkLatency int64 = 1
+)
+
 // MeshNetwork manages the mesh network topology and routing
 type MeshNetwork struct {
 	config   *MeshConfig
@@ -18,6 +33,16 @@ type MeshNetwork struct {
 	cancel   context.CancelFunc
 	mu       sync.RWMutex
 	logger   Logger
+
+	// Пиры с живостью по ping/pong: discovery не обновляет их LastSeen и IsConnected
+	pinged map[string]bool
+	// onPeerDead вызывается для пиров, удаленных из mesh по PeerDeadAfter
+	onPeerDead func(peerID string)
+	// whitelist белый список пиров и их лимит из JWT (nil — без ограничений)
+	whitelist *PeerWhitelist
+	// routeFilter объявленные пирами подсети и exit node, которые использует клиент
+	// (nil — все подсети, кроме маршрута по умолчанию)
+	routeFilter *RouteFilter
 }
 
 // MeshRouter handles mesh network routing
@@ -25,9 +50,25 @@ type MeshRouter struct {
 	routingTable map[string][]string // destination -> route
 	latencyTable map[string]int64    // destination -> latency
 	ownerIndex   map[string]string   // destination -> ownerPeerID
+	policy       routePolicy         // участки графа, разрешенные стратегией маршрутизации
+	relayLatency int64               // задержка до relay (мс); relay -> пир считается такой же
 	mu           sync.RWMutex
 }
 
+// routePolicy участки графа пиров, которые может использовать маршрут
+type routePolicy struct {
+	p2p          bool  // прямые P2P соединения (ICE, в том числе через TURN)
+	relay        bool  // relay сервер как промежуточный хоп
+	relayPenalty int64 // надбавка к весу участков через relay
+}
+
+// meshEdge направленный участок графа пиров
+type meshEdge struct {
+	to      string
+	latency int64
+	cost    int64 // вес для выбора пути: задержка плюс штраф политики
+}
+
 // NewMeshNetwork creates a new mesh network manager
 func NewMeshNetwork(config *MeshConfig, logger Logger) *MeshNetwork {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -54,10 +95,12 @@ func NewMeshNetwork(config *MeshConfig, logger Logger) *MeshNetwork {
 			routingTable: make(map[string][]string),
 			latencyTable: make(map[string]int64),
 			ownerIndex:   make(map[string]string),
+			relayLatency: defaultRelayLatency,
 		},
 		ctx:    ctx,
 		cancel: cancel,
 		logger: logger,
+		pinged: make(map[string]bool),
 	}
 }
 
@@ -155,15 +198,33 @@ func (mn *MeshNetwork) AddPeer(peer *Peer) error {
 	mn.mu.Lock()
 	defer mn.mu.Unlock()
 
+	if err := mn.admitPeerLocked(peer.ID); err != nil {
+		return err
+	}
+
 	mn.logger.Info("Adding peer to mesh network", "peer_id", peer.ID)
 
+	// Путь соединения и анонсированные связи известны локально, а не из discovery
+	if existing, ok := mn.topology.ConnectedPeers[peer.ID]; ok {
+		if peer.Path == "" {
+			peer.Path = existing.Path
+		}
+		if peer.Links == nil {
+			peer.Links = existing.Links
+		}
+		// Живость измеряется ping/pong — сведения discovery ее не заменяют
+		if mn.pinged[peer.ID] {
+			peer.LastSeen = existing.LastSeen
+			peer.IsConnected = existing.IsConnected
+			peer.Latency, peer.Jitter, peer.Loss = existing.Latency, existing.Jitter, existing.Loss
+		}
+	}
+
 	// Add to connected peers
 	mn.topology.ConnectedPeers[peer.ID] = peer
 
 	// Update routing table
-	if err := mn.updateRoutingForPeer(peer); err != nil {
-		mn.logger.Error("Failed to update routing for peer", "peer_id", peer.ID, "error", err)
-	}
+	mn.rebuildRoutesLocked()
 
 	mn.logger.Info("Peer added to mesh network successfully", "peer_id", peer.ID)
 	return nil
@@ -174,6 +235,7 @@ func (mn *MeshNetwork) SetLocalPeerID(id string) {
 	mn.mu.Lock()
 	defer mn.mu.Unlock()
 	mn.topology.LocalPeerID = id
+	mn.rebuildRoutesLocked()
 	mn.logger.Info("Local peer ID set", "peer_id", id)
 }
 
@@ -196,16 +258,74 @@ func (mn *MeshNetwork) UpsertPeer(peer *Peer) {
 		if len(peer.AllowedIPs) > 0 {
 			p.AllowedIPs = peer.AllowedIPs
 		}
-		p.IsConnected = peer.IsConnected
-		p.LastSeen = time.Now().Unix()
-		_ = mn.updateRoutingForPeer(p)
+		if peer.Path != "" {
+			p.Path = peer.Path
+		}
+		if peer.Links != nil {
+			p.Links = peer.Links
+		}
+		if !mn.pinged[peer.ID] {
+			p.IsConnected = peer.IsConnected
+			p.LastSeen = time.Now().Unix()
+		}
 		mn.logger.Debug("Updated existing peer", "peer_id", peer.ID)
 	} else {
+		if err := mn.admitPeerLocked(peer.ID); err != nil {
+			mn.logger.Debug("Peer not added to mesh", "peer_id", peer.ID, "error", err)
+			return
+		}
 		peer.LastSeen = time.Now().Unix()
 		mn.topology.ConnectedPeers[peer.ID] = peer
-		_ = mn.updateRoutingForPeer(peer)
 		mn.logger.Info("Added new peer", "peer_id", peer.ID)
 	}
+	mn.rebuildRoutesLocked()
+}
+
+// SetPeerPath обновляет локальное соединение с пиром: путь трафика и его наличие
+func (mn *MeshNetwork) SetPeerPath(peerID string, path PathType, connected bool) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	p, ok := mn.topology.ConnectedPeers[peerID]
+	if !ok {
+		if !connected {
+			return
+		}
+		p = &Peer{ID: peerID}
+		mn.topology.ConnectedPeers[peerID] = p
+	}
+	p.Path = path
+	p.IsConnected = connected
+	p.LastSeen = time.Now().Unix()
+	if !connected {
+		delete(mn.pinged, peerID)
+	}
+	mn.rebuildRoutesLocked()
+}
+
+// UpdatePeerLinks сохраняет соединения, анонсированные пиром, — участки для многохоповых маршрутов
+func (mn *MeshNetwork) UpdatePeerLinks(peerID string, links []PeerLink) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	p, ok := mn.topology.ConnectedPeers[peerID]
+	if !ok {
+		return
+	}
+	p.Links = links
+	p.LastSeen = time.Now().Unix()
+	mn.rebuildRoutesLocked()
+}
+
+// SetRelayLatency задает измеренную задержку до relay сервера (мс)
+func (mn *MeshNetwork) SetRelayLatency(latency int64) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.router.mu.Lock()
+	mn.router.relayLatency = latency
+	mn.router.mu.Unlock()
+	mn.rebuildRoutesLocked()
 }
 
 // UpdatePeerLatency обновляет латентность пира
@@ -215,13 +335,129 @@ func (mn *MeshNetwork) UpdatePeerLatency(peerID string, latency int64) {
 
 	if p, ok := mn.topology.ConnectedPeers[peerID]; ok {
 		p.Latency = latency
-		mn.router.mu.Lock()
-		mn.router.latencyTable[peerID] = latency
-		mn.router.mu.Unlock()
+		mn.rebuildRoutesLocked()
 		mn.logger.Debug("Updated peer latency", "peer_id", peerID, "latency", latency)
 	}
 }
 
+// RecordPeerPing учитывает ответ пира на ping: пир жив, задержка, jitter (мс) и потери измерены
+func (mn *MeshNetwork) RecordPeerPing(peerID string, latency, jitter int64, loss float64) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	p, ok := mn.topology.ConnectedPeers[peerID]
+	if !ok {
+		return
+	}
+	if !p.IsConnected {
+		mn.logger.Info("Peer is responsive again", "peer_id", peerID)
+	}
+	mn.pinged[peerID] = true
+	p.Latency, p.Jitter, p.Loss = latency, jitter, loss
+	p.IsConnected = true
+	p.LastSeen = time.Now().Unix()
+	mn.rebuildRoutesLocked()
+}
+
+// SetPeerWhitelist задает белый список и лимит пиров, принимаемых в mesh
+func (mn *MeshNetwork) SetPeerWhitelist(whitelist *PeerWhitelist) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.whitelist = whitelist
+}
+
+// admitPeerLocked проверяет пира по белому списку и лимиту перед добавлением;
+// локальный пир и уже известные пиры не ограничиваются. Вызывается под mn.mu.
+func (mn *MeshNetwork) admitPeerLocked(peerID string) error {
+	if peerID == mn.topology.LocalPeerID {
+		return nil
+	}
+	if _, ok := mn.topology.ConnectedPeers[peerID]; ok {
+		return nil
+	}
+	if !mn.whitelist.Allows(peerID) {
+		return fmt.Errorf("%w: %s", ErrPeerNotAllowed, peerID)
+	}
+	limit := mn.whitelist.maxPeers()
+	if limit == 0 {
+		return nil
+	}
+	peers := len(mn.topology.ConnectedPeers)
+	if _, ok := mn.topology.ConnectedPeers[mn.topology.LocalPeerID]; ok {
+		peers--
+	}
+	if peers >= limit {
+		return fmt.Errorf("%w (%d)", ErrMaxPeers, limit)
+	}
+	return nil
+}
+
+// SetRouteFilter задает объявленные пирами подсети и exit node, которые использует клиент
+func (mn *MeshNetwork) SetRouteFilter(filter *RouteFilter) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.routeFilter = filter
+	mn.rebuildRoutesLocked()
+}
+
+// AcceptedAllowedIPs возвращает AllowedIPs пира, которые клиент использует: адреса overlay
+// сети и принятые объявленные маршруты. Это AllowedIPs пира в WireGuard.
+func (mn *MeshNetwork) AcceptedAllowedIPs(peerID string) []string {
+	mn.mu.RLock()
+	defer mn.mu.RUnlock()
+
+	peer, ok := mn.topology.ConnectedPeers[peerID]
+	if !ok {
+		return nil
+	}
+	accepted := make([]string, 0, len(peer.AllowedIPs))
+	for _, allowed := range peer.AllowedIPs {
+		if mn.routeFilter.Accepts(peerID, allowed) {
+			accepted = append(accepted, allowed)
+		}
+	}
+	return accepted
+}
+
+// AdvertisedRoutes возвращает подсети LAN и маршруты по умолчанию, объявленные пирами,
+// с отметкой, использует ли их клиент
+func (mn *MeshNetwork) AdvertisedRoutes() []AdvertisedRoute {
+	mn.mu.RLock()
+	defer mn.mu.RUnlock()
+
+	routes := make([]AdvertisedRoute, 0)
+	for id, peer := range mn.topology.ConnectedPeers {
+		if id == mn.topology.LocalPeerID {
+			continue
+		}
+		for _, allowed := range peer.AllowedIPs {
+			if !mn.routeFilter.advertised(allowed) {
+				continue
+			}
+			routes = append(routes, AdvertisedRoute{
+				PeerID:   id,
+				Prefix:   allowed,
+				ExitNode: isDefaultRoute(allowed),
+				Accepted: mn.routeFilter.Accepts(id, allowed),
+			})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].PeerID != routes[j].PeerID {
+			return routes[i].PeerID < routes[j].PeerID
+		}
+		return routes[i].Prefix < routes[j].Prefix
+	})
+	return routes
+}
+
+// OnPeerDead задает обработчик пиров, удаленных из mesh как недоступные
+func (mn *MeshNetwork) OnPeerDead(handler func(peerID string)) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.onPeerDead = handler
+}
+
 // RemovePeer удаляет пира из mesh сети
 func (mn *MeshNetwork) RemovePeer(peerID string) error {
 	mn.mu.Lock()
@@ -232,60 +468,94 @@ func (mn *MeshNetwork) RemovePeer(peerID string) error {
 	}
 
 	delete(mn.topology.ConnectedPeers, peerID)
-	mn.removeRoutingForPeer(peerID)
+	delete(mn.pinged, peerID)
+	mn.rebuildRoutesLocked()
 	mn.logger.Info("Peer removed from mesh", "peer_id", peerID)
 	return nil
 }
 
-// GetOptimalRoute returns the optimal route to a destination
+// GetOptimalRoute returns the hops to a destination (peer ID, allowed IP/CIDR or an IP
+// inside a peer's CIDR). The last hop is the owning peer; RelayHopID marks the relay server.
 func (mn *MeshNetwork) GetOptimalRoute(destination string) ([]string, error) {
 	mn.router.mu.RLock()
 	defer mn.router.mu.RUnlock()
 
-	if route, exists := mn.router.routingTable[destination]; exists {
+	if key, ok := mn.router.lookupLocked(destination); ok {
 		// Return a copy to avoid race conditions
-		routeCopy := make([]string, len(route))
-		copy(routeCopy, route)
-		return routeCopy, nil
+		return slices.Clone(mn.router.routingTable[key]), nil
 	}
 
 	return nil, fmt.Errorf("no route found to destination: %s", destination)
 }
 
-// GetRouteLatency returns the latency to a destination
+// GetRouteLatency returns the latency to a destination along its optimal route
 func (mn *MeshNetwork) GetRouteLatency(destination string) (int64, error) {
 	mn.router.mu.RLock()
 	defer mn.router.mu.RUnlock()
 
-	if latency, exists := mn.router.latencyTable[destination]; exists {
-		return latency, nil
+	if key, ok := mn.router.lookupLocked(destination); ok {
+		return mn.router.latencyTable[key], nil
 	}
 
 	return 0, fmt.Errorf("no latency information for destination: %s", destination)
 }
 
+// lookupLocked находит запись таблицы: точное совпадение, иначе самый длинный CIDR с IP
+func (r *MeshRouter) lookupLocked(destination string) (string, bool) {
+	if _, ok := r.routingTable[destination]; ok {
+		return destination, true
+	}
+	ip := net.ParseIP(destination)
+	if ip == nil {
+		return "", false
+	}
+
+	best, bestBits := "", -1
+	for dest := range r.routingTable {
+		if !strings.Contains(dest, "/") {
+			continue
+		}
+		_, n, err := net.ParseCIDR(dest)
+		if err != nil || !n.Contains(ip) {
+			continue
+		}
+		if bits, _ := n.Mask.Size(); bits > bestBits {
+			best, bestBits = dest, bits
+		}
+	}
+	return best, bestBits >= 0
+}
+
 // initializeRouting initializes the routing based on mesh configuration
 func (mn *MeshNetwork) initializeRouting() error {
 	mn.logger.Info("Initializing mesh routing", "strategy", mn.config.Routing)
 
+	var err error
 	switch mn.config.Routing {
-	case "hybrid":
-		return mn.initializeHybridRouting()
-	case "direct":
-		return mn.initializeDirectRouting()
-	case "relay":
-		return mn.initializeRelayRouting()
+	case RoutingHybrid:
+		err = mn.initializeHybridRouting()
+	case RoutingDirect:
+		err = mn.initializeDirectRouting()
+	case RoutingRelay:
+		err = mn.initializeRelayRouting()
 	default:
 		return fmt.Errorf("unsupported routing strategy: %s", mn.config.Routing)
 	}
+	if err != nil {
+		return err
+	}
+
+	mn.rebuildRoutesLocked()
+	return nil
 }
 
 // initializeHybridRouting initializes hybrid routing (direct + relay)
 func (mn *MeshNetwork) initializeHybridRouting() error {
 	mn.logger.Info("Initializing hybrid routing")
 
-	// Hybrid routing tries direct connections first, falls back to relay
-	// This is the default and most flexible approach
+	// Маршрут может идти через P2P соединения других пиров и через relay;
+	// relay участки штрафуются, поэтому используются, когда P2P пути нет или он заметно медленнее
+	mn.setRoutePolicy(routePolicy{p2p: true, relay: true, relayPenalty: hybridRelayPenalty})
 	return nil
 }
 
@@ -293,8 +563,8 @@ func (mn *MeshNetwork) initializeHybridRouting() error {
 func (mn *MeshNetwork) initializeDirectRouting() error {
 	mn.logger.Info("Initializing direct routing")
 
-	// Direct routing only uses direct peer-to-peer connections
-	// No relay fallback
+	// Только P2P соединения (в том числе многохоповые через другие пиры), без relay
+	mn.setRoutePolicy(routePolicy{p2p: true})
 	return nil
 }
 
@@ -302,52 +572,156 @@ func (mn *MeshNetwork) initializeDirectRouting() error {
 func (mn *MeshNetwork) initializeRelayRouting() error {
 	mn.logger.Info("Initializing relay routing")
 
-	// Relay routing uses the relay server as an intermediary
-	// All traffic goes through the relay
+	// Весь трафик идет через relay сервер: маршрут [relay, пир]
+	mn.setRoutePolicy(routePolicy{relay: true})
 	return nil
 }
 
-// updateRoutingForPeer updates routing table when a peer is added
-func (mn *MeshNetwork) updateRoutingForPeer(peer *Peer) error {
+func (mn *MeshNetwork) setRoutePolicy(policy routePolicy) {
 	mn.router.mu.Lock()
 	defer mn.router.mu.Unlock()
+	mn.router.policy = policy
+}
 
-	// Add direct route to the peer
-	mn.router.routingTable[peer.ID] = []string{peer.ID}
-	mn.router.latencyTable[peer.ID] = peer.Latency
-	mn.router.ownerIndex[peer.ID] = peer.ID
+// peerStale сообщает, что о пире давно нет сведений; вызывается под mn.mu
+func (mn *MeshNetwork) peerStale(peer *Peer, now time.Time) bool {
+	return mn.config.PeerStaleAfter > 0 && now.Sub(time.Unix(peer.LastSeen, 0)) > mn.config.PeerStaleAfter
+}
 
-	// Add routes to the peer's allowed IPs
-	for _, allowedIP := range peer.AllowedIPs {
-		mn.router.routingTable[allowedIP] = []string{peer.ID}
-		mn.router.latencyTable[allowedIP] = peer.Latency
-		mn.router.ownerIndex[allowedIP] = peer.ID
+// buildGraphLocked строит граф пиров по политике маршрутизации. Участки:
+// локальный пир -> пир по собственному P2P соединению, пир -> пир по анонсированным
+// соединениям (если сведения о пире свежие) и через relay, если политика его разрешает.
+// Вызывается под mn.mu и mn.router.mu.
+func (mn *MeshNetwork) buildGraphLocked(now time.Time) map[string][]meshEdge {
+	policy := mn.router.policy
+	local := mn.topology.LocalPeerID
+	graph := make(map[string][]meshEdge)
+	addEdge := func(from, to string, latency, penalty int64) {
+		if latency < minLinkLatency {
+			latency = minLinkLatency
+		}
+		graph[from] = append(graph[from], meshEdge{to: to, latency: latency, cost: latency + penalty})
 	}
 
-	mn.logger.Debug("Updated routing for peer", "peer_id", peer.ID, "allowed_ips", peer.AllowedIPs)
-	return nil
+	for id, peer := range mn.topology.ConnectedPeers {
+		if id == local || mn.peerStale(peer, now) {
+			continue
+		}
+
+		if policy.p2p && peer.IsConnected && !peer.Path.viaRelay() {
+			addEdge(local, id, peer.Latency, 0)
+		}
+		if policy.p2p {
+			for _, link := range peer.Links {
+				if link.PeerID == local || link.PeerID == id || link.Path.viaRelay() {
+					continue
+				}
+				if _, known := mn.topology.ConnectedPeers[link.PeerID]; known {
+					addEdge(id, link.PeerID, link.Latency, 0)
+				}
+			}
+		}
+		if policy.relay {
+			addEdge(RelayHopID, id, mn.router.relayLatency, 0)
+		}
+	}
+	if policy.relay {
+		addEdge(local, RelayHopID, mn.router.relayLatency, policy.relayPenalty)
+	}
+	return graph
+}
+
+// shortestPaths находит пути минимального веса от source (алгоритм Дейкстры).
+// Возвращает предыдущий узел и задержку пути для каждого достижимого узла.
+func shortestPaths(graph map[string][]meshEdge, source string) (map[string]string, map[string]int64) {
+	cost := map[string]int64{source: 0}
+	latency := map[string]int64{source: 0}
+	prev := make(map[string]string)
+	done := make(map[string]bool)
+
+	for {
+		// Граф mesh небольшой: линейный выбор узла проще кучи; равные веса разрешаются по ID
+		current, found := "", false
+		for node, c := range cost {
+			if done[node] {
+				continue
+			}
+			if !found || c < cost[current] || (c == cost[current] && node < current) {
+				current, found = node, true
+			}
+		}
+		if !found {
+			return prev, latency
+		}
+		done[current] = true
+
+		for _, edge := range graph[current] {
+			next := cost[current] + edge.cost
+			if c, ok := cost[edge.to]; !ok || next < c {
+				cost[edge.to] = next
+				latency[edge.to] = latency[current] + edge.latency
+				prev[edge.to] = current
+			}
+		}
+	}
 }
 
-// removeRoutingForPeer removes routing entries when a peer is removed (O(1) через ownerIndex)
-func (mn *MeshNetwork) removeRoutingForPeer(peerID string) {
+// rebuildRoutesLocked пересчитывает таблицу маршрутизации по текущей топологии
+// и политике. Вызывается под mn.mu.
+func (mn *MeshNetwork) rebuildRoutesLocked() {
 	mn.router.mu.Lock()
 	defer mn.router.mu.Unlock()
 
-	// Remove direct route to the peer
-	delete(mn.router.routingTable, peerID)
-	delete(mn.router.latencyTable, peerID)
-	delete(mn.router.ownerIndex, peerID)
+	local := mn.topology.LocalPeerID
+	prev, latency := shortestPaths(mn.buildGraphLocked(time.Now()), local)
+
+	routingTable := make(map[string][]string)
+	latencyTable := make(map[string]int64)
+	ownerIndex := make(map[string]string)
+	for id, peer := range mn.topology.ConnectedPeers {
+		if id == local {
+			continue
+		}
+		if _, reachable := prev[id]; !reachable {
+			continue
+		}
+
+		var route []string
+		for hop := id; hop != local; hop = prev[hop] {
+			route = append(route, hop)
+		}
+		slices.Reverse(route)
+
+		routingTable[id] = route
+		latencyTable[id] = latency[id]
+		ownerIndex[id] = id
+		for _, allowedIP := range peer.AllowedIPs {
+			if !mn.routeFilter.Accepts(id, allowedIP) {
+				continue
+			}
+			routingTable[allowedIP] = route
+			latencyTable[allowedIP] = latency[id]
+			ownerIndex[allowedIP] = id
+		}
+	}
 
-	// Remove all destinations owned by this peer (быстро через ownerIndex)
-	for dest, owner := range mn.router.ownerIndex {
-		if owner == peerID {
-			delete(mn.router.routingTable, dest)
-			delete(mn.router.latencyTable, dest)
-			delete(mn.router.ownerIndex, dest)
+	// Смена пути к пиру (например, после потери прямого соединения) видна в логах
+	changed := make([]string, 0)
+	for dest, route := range routingTable {
+		if old, ok := mn.router.routingTable[dest]; ok && ownerIndex[dest] == dest && !slices.Equal(old, route) {
+			changed = append(changed, dest)
 		}
 	}
+	sort.Strings(changed)
+	for _, dest := range changed {
+		mn.logger.Info("Mesh route changed", "destination", dest,
+			"old_route", mn.router.routingTable[dest], "new_route", routingTable[dest])
+	}
 
-	mn.logger.Debug("Removed routing for peer", "peer_id", peerID)
+	mn.router.routingTable = routingTable
+	mn.router.latencyTable = latencyTable
+	mn.router.ownerIndex = ownerIndex
+	mn.topology.RoutingTable = routingTable
 }
 
 // topologyUpdateLoop continuously updates the mesh topology
@@ -414,51 +788,98 @@ func (mn *MeshNetwork) updateTopology() {
 		}
 	}
 
+	// Маршруты через устаревших пиров перестраиваются сразу
+	mn.rebuildRoutesLocked()
 	mn.logger.Debug("Updated mesh topology", "connected_peers", len(mn.topology.ConnectedPeers))
 }
 
 // updateRouting updates the routing table based on current topology
 func (mn *MeshNetwork) updateRouting() {
-	mn.router.mu.Lock()
-	defer mn.router.mu.Unlock()
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
 
 	// Recalculate optimal routes based on current peer status
-	for dest, route := range mn.router.routingTable {
-		if len(route) > 0 {
-			peerID := route[0]
-			if peer, exists := mn.topology.ConnectedPeers[peerID]; exists {
-				if !peer.IsConnected {
-					// Find alternative route
-					if altRoute := mn.findAlternativeRoute(dest, peerID); altRoute != nil {
-						mn.router.routingTable[dest] = altRoute
-						mn.logger.Info("Updated route due to peer unavailability", "destination", dest, "new_route", altRoute)
-					}
-				}
-			}
+	mn.rebuildRoutesLocked()
+	mn.logger.Debug("Updated mesh routing table", "routes", len(mn.router.routingTable))
+}
+
+// performHealthChecks удаляет из mesh пиров, от которых нет сведений дольше PeerDeadAfter
+func (mn *MeshNetwork) performHealthChecks() {
+	mn.mu.Lock()
+	dead := mn.config.PeerDeadAfter
+	now := time.Now()
+	var removed []string
+	for id, peer := range mn.topology.ConnectedPeers {
+		if since := now.Sub(time.Unix(peer.LastSeen, 0)); since > dead {
+			mn.logger.Warn("Peer failed health check, removing from mesh", "peer_id", id, "since", since)
+			delete(mn.topology.ConnectedPeers, id)
+			delete(mn.pinged, id)
+			removed = append(removed, id)
 		}
 	}
+	if len(removed) > 0 {
+		mn.rebuildRoutesLocked()
+	}
+	handler := mn.onPeerDead
+	mn.mu.Unlock()
 
-	mn.logger.Debug("Updated mesh routing table", "routes", len(mn.router.routingTable))
+	if handler != nil {
+		for _, id := range removed {
+			handler(id)
+		}
+	}
 }
 
-// performHealthChecks performs health checks on all mesh connections
-func (mn *MeshNetwork) performHealthChecks() {
+// PeerForIP возвращает пира, которому принадлежит адрес overlay сети (самый длинный префикс AllowedIPs)
+func (mn *MeshNetwork) PeerForIP(ip net.IP) (string, bool) {
 	mn.mu.RLock()
-	peers := make(map[string]*Peer, len(mn.topology.ConnectedPeers))
-	for id, p := range mn.topology.ConnectedPeers {
-		peers[id] = p
+	defer mn.mu.RUnlock()
+
+	owner, best := "", -1
+	for id, peer := range mn.topology.ConnectedPeers {
+		if id == mn.topology.LocalPeerID {
+			continue
+		}
+		for _, allowed := range peer.AllowedIPs {
+			if !mn.routeFilter.Accepts(id, allowed) {
+				continue
+			}
+			_, network, err := net.ParseCIDR(allowed)
+			if err != nil {
+				if single := net.ParseIP(allowed); single != nil && single.Equal(ip) {
+					network = &net.IPNet{IP: single, Mask: net.CIDRMask(len(single)*8, len(single)*8)}
+				} else {
+					continue
+				}
+			}
+			if ones, _ := network.Mask.Size(); network.Contains(ip) && ones > best {
+				owner, best = id, ones
+			}
+		}
 	}
-	mn.mu.RUnlock()
+	return owner, owner != ""
+}
 
-	dead := mn.config.PeerDeadAfter
-	for id, peer := range peers {
-		// Perform health check with configurable threshold
-		if time.Since(time.Unix(peer.LastSeen, 0)) > dead {
-			mn.logger.Warn("Peer failed health check", "peer_id", id, "since", time.Since(time.Unix(peer.LastSeen, 0)))
-			// Опционально: автоснос
-			// _ = mn.RemovePeer(id)
+// PeerAddresses возвращает адреса пиров в overlay сети: AllowedIPs одного хоста (/32, /128 или адрес без маски)
+func (mn *MeshNetwork) PeerAddresses() map[string][]net.IP {
+	mn.mu.RLock()
+	defer mn.mu.RUnlock()
+
+	addresses := make(map[string][]net.IP)
+	for id, peer := range mn.topology.ConnectedPeers {
+		for _, allowed := range peer.AllowedIPs {
+			ip, network, err := net.ParseCIDR(allowed)
+			if err != nil {
+				ip = net.ParseIP(allowed)
+			} else if ones, bits := network.Mask.Size(); ones != bits {
+				continue
+			}
+			if ip != nil {
+				addresses[id] = append(addresses[id], ip)
+			}
 		}
 	}
+	return addresses
 }
 
 // ipInCIDR проверяет, попадает ли IP в CIDR
@@ -482,27 +903,6 @@ func (mn *MeshNetwork) IsIPInTenantSubnet(ip string, tenantSubnet string) bool {
 	return ipInCIDR(ip, tenantSubnet)
 }
 
-// findAlternativeRoute finds an alternative route to a destination (CIDR-совместимый)
-func (mn *MeshNetwork) findAlternativeRoute(destination, excludePeerID string) []string {
-	// Если destination — IP, попробуем найти владельца по CIDR
-	for id, peer := range mn.topology.ConnectedPeers {
-		if id == excludePeerID || !peer.IsConnected {
-			continue
-		}
-		for _, allowed := range peer.AllowedIPs {
-			// точное совпадение
-			if allowed == destination {
-				return []string{id}
-			}
-			// CIDR
-			if strings.Contains(allowed, "/") && ipInCIDR(destination, allowed) {
-				return []string{id}
-			}
-		}
-	}
-	return nil
-}
-
 // GetMeshStats returns statistics about the mesh network
 func (mn *MeshNetwork) GetMeshStats() map[string]interface{} {
 	mn.mu.RLock()
@@ -523,7 +923,23 @@ func (mn *MeshNetwork) GetMeshStats() map[string]interface{} {
 		avgLatency = totalLatency / int64(connectedCount)
 	}
 
+	mn.router.mu.RLock()
+	defer mn.router.mu.RUnlock()
+	multiHop, viaRelay := 0, 0
+	for dest, route := range mn.router.routingTable {
+		if mn.router.ownerIndex[dest] != dest {
+			continue
+		}
+		if slices.Contains(route, RelayHopID) {
+			viaRelay++
+		} else if len(route) > 1 {
+			multiHop++
+		}
+	}
+
 	return map[string]interface{}{
+		"multi_hop_routes": multiHop,
+		"relay_routes":     viaRelay,
 		"total_peers":      len(mn.topology.ConnectedPeers),
 		"connected_peers":  connectedCount,
 		"routing_strategy": mn.config.Routing,
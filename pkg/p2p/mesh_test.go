@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestMesh(t *testing.T, routing string) *MeshNetwork {
+	t.Helper()
+	mn := NewMeshNetwork(&MeshConfig{Routing: routing}, NewSimpleLogger("test"))
+	mn.SetLocalPeerID("local")
+	if err := mn.Start(); err != nil {
+		t.Fatalf("Failed to start mesh: %v", err)
+	}
+	t.Cleanup(func() { _ = mn.Stop() }) //nolint:errcheck // test cleanup
+	return mn
+}
+
+func expectRoute(t *testing.T, mn *MeshNetwork, destination string, want []string, wantLatency int64) {
+	t.Helper()
+	route, err := mn.GetOptimalRoute(destination)
+	if err != nil {
+		t.Fatalf("No route to %s: %v", destination, err)
+	}
+	if !slices.Equal(route, want) {
+		t.Errorf("Route to %s = %v, want %v", destination, route, want)
+	}
+	if latency, _ := mn.GetRouteLatency(destination); latency != wantLatency {
+		t.Errorf("Latency to %s = %d, want %d", destination, latency, wantLatency)
+	}
+}
+
+func TestMesh_HybridPrefersFasterMultiHopPath(t *testing.T) {
+	mn := newTestMesh(t, RoutingHybrid)
+	mn.SetRouteFilter(&RouteFilter{OverlaySubnet: "10.8.0.0/16"})
+	mn.UpsertPeer(&Peer{ID: "a", IsConnected: true, Latency: 10, Path: PathDirect})
+	mn.UpsertPeer(&Peer{ID: "b", IsConnected: true, Latency: 100, Path: PathDirect, AllowedIPs: []string{"10.8.0.0/24"}})
+	mn.UpdatePeerLinks("a", []PeerLink{{PeerID: "b", Latency: 20, Path: PathDirect}})
+
+	expectRoute(t, mn, "a", []string{"a"}, 10)
+	expectRoute(t, mn, "b", []string{"a", "b"}, 30)
+	// IP внутри CIDR пира маршрутизируется к его владельцу
+	expectRoute(t, mn, "10.8.0.15", []string{"a", "b"}, 30)
+
+	// Пир доступен только через relay: hybrid использует relay хоп
+	mn.UpsertPeer(&Peer{ID: "c", IsConnected: true, Path: PathRelay})
+	expectRoute(t, mn, "c", []string{RelayHopID, "c"}, 2*defaultRelayLatency)
+
+	if stats := mn.GetMeshStats(); stats["multi_hop_routes"] != 1 || stats["relay_routes"] != 1 {
+		t.Errorf("Unexpected route stats: %v", stats)
+	}
+}
+
+func TestMesh_ReroutesWhenPeerGoesStale(t *testing.T) {
+	mn := newTestMesh(t, RoutingDirect)
+	mn.UpsertPeer(&Peer{ID: "a", IsConnected: true, Latency: 10, Path: PathDirect})
+	mn.UpsertPeer(&Peer{ID: "b", IsConnected: true, Latency: 5, Path: PathDirect})
+	mn.UpsertPeer(&Peer{ID: "d", IsConnected: true, Latency: 50, Path: PathDirect})
+	mn.UpdatePeerLinks("a", []PeerLink{{PeerID: "b", Latency: 10}})
+	mn.UpdatePeerLinks("b", []PeerLink{{PeerID: "d", Latency: 5}})
+
+	expectRoute(t, mn, "b", []string{"b"}, 5)
+	expectRoute(t, mn, "d", []string{"b", "d"}, 10)
+
+	// Сведения о b устарели: прямой участок и связи b больше не используются
+	mn.mu.Lock()
+	mn.topology.ConnectedPeers["b"].LastSeen = time.Now().Add(-2 * mn.config.PeerStaleAfter).Unix()
+	mn.mu.Unlock()
+	mn.updateTopology()
+
+	expectRoute(t, mn, "b", []string{"a", "b"}, 20)
+	expectRoute(t, mn, "d", []string{"d"}, 50)
+}
+
+func TestMesh_RoutingPolicies(t *testing.T) {
+	direct := newTestMesh(t, RoutingDirect)
+	direct.UpsertPeer(&Peer{ID: "a", IsConnected: true, Latency: 10, Path: PathDirect})
+	direct.UpsertPeer(&Peer{ID: "b", IsConnected: true, Path: PathDERP})
+	expectRoute(t, direct, "a", []string{"a"}, 10)
+	if _, err := direct.GetOptimalRoute("b"); err == nil {
+		t.Error("Expected no route over DERP with direct routing")
+	}
+
+	relay := newTestMesh(t, RoutingRelay)
+	relay.SetRelayLatency(15)
+	relay.UpsertPeer(&Peer{ID: "a", IsConnected: true, Latency: 1, Path: PathDirect})
+	expectRoute(t, relay, "a", []string{RelayHopID, "a"}, 30)
+
+	if err := relay.RemovePeer("a"); err != nil {
+		t.Fatalf("RemovePeer failed: %v", err)
+	}
+	if _, err := relay.GetOptimalRoute("a"); err == nil {
+		t.Error("Expected route to be removed with the peer")
+	}
+}
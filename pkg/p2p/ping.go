@@ -0,0 +1,217 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+)
+
+// Ping/pong пиров: QUIC датаграммы на соединении сессии измеряют RTT, jitter и
+// потери и служат сигналом живости пира для mesh. Соединения через relay stream
+// не измеряются: их живость по-прежнему определяется discovery.
+const (
+	// peerPingInterval период отправки ping пиру
+	peerPingInterval = 5 * time.Second
+	// peerPingWindow число последних ping, по которым считаются потери
+	peerPingWindow = 20
+
+	pingTypePing byte = 1
+	pingTypePong byte = 2
+	// pingMessageSize тип (1 байт) + порядковый номер (4 байта)
+	pingMessageSize = 5
+)
+
+// PeerLinkStats качество соединения с пиром по ping/pong
+type PeerLinkStats struct {
+	RTT      time.Duration `json:"rtt"`
+	Jitter   time.Duration `json:"jitter"`
+	Loss     float64       `json:"loss"` // доля потерянных ping в окне peerPingWindow
+	Sent     uint64        `json:"sent"`
+	Received uint64        `json:"received"`
+	LastPong time.Time     `json:"last_pong,omitempty"`
+}
+
+// peerPinger состояние измерений одного соединения; вызывается под мьютексом сессии
+type peerPinger struct {
+	seq         uint32
+	outstanding map[uint32]time.Time // отправленные ping без ответа
+	window      []bool               // результаты последних ping: true — pong получен
+	last        time.Duration        // последнее измерение RTT
+	stats       PeerLinkStats
+}
+
+func newPeerPinger() *peerPinger {
+	return &peerPinger{outstanding: make(map[uint32]time.Time)}
+}
+
+// encodePing кодирует ping/pong датаграмму
+func encodePing(kind byte, seq uint32) []byte {
+	msg := make([]byte, pingMessageSize)
+	msg[0] = kind
+	binary.BigEndian.PutUint32(msg[1:], seq)
+	return msg
+}
+
+// decodePing разбирает ping/pong датаграмму
+func decodePing(msg []byte) (byte, uint32, error) {
+	if len(msg) != pingMessageSize || (msg[0] != pingTypePing && msg[0] != pingTypePong) {
+		return 0, 0, fmt.Errorf("invalid ping datagram (%d bytes)", len(msg))
+	}
+	return msg[0], binary.BigEndian.Uint32(msg[1:]), nil
+}
+
+// nextPing регистрирует новый ping; ping, оставшиеся без ответа дольше timeout, считаются потерянными
+func (p *peerPinger) nextPing(now time.Time, timeout time.Duration) uint32 {
+	for seq, sent := range p.outstanding {
+		if now.Sub(sent) > timeout {
+			delete(p.outstanding, seq)
+			p.record(false)
+		}
+	}
+	p.seq++
+	p.outstanding[p.seq] = now
+	p.stats.Sent++
+	return p.seq
+}
+
+// handlePong учитывает ответ на ping; false — ответ на неизвестный или просроченный ping
+func (p *peerPinger) handlePong(seq uint32, now time.Time) bool {
+	sent, ok := p.outstanding[seq]
+	if !ok {
+		return false
+	}
+	delete(p.outstanding, seq)
+	p.record(true)
+
+	rtt := now.Sub(sent)
+	if p.stats.Received == 0 {
+		p.stats.RTT = rtt
+	} else {
+		// Jitter по RFC 3550: сглаженное отклонение соседних измерений
+		diff := rtt - p.last
+		if diff < 0 {
+			diff = -diff
+		}
+		p.stats.Jitter += (diff - p.stats.Jitter) / 16
+		// Сглаженный RTT как в TCP (RFC 6298)
+		p.stats.RTT += (rtt - p.stats.RTT) / 8
+	}
+	p.last = rtt
+	p.stats.Received++
+	p.stats.LastPong = now
+	return true
+}
+
+// record добавляет результат ping в окно потерь
+func (p *peerPinger) record(received bool) {
+	p.window = append(p.window, received)
+	if len(p.window) > peerPingWindow {
+		p.window = p.window[len(p.window)-peerPingWindow:]
+	}
+	lost := 0
+	for _, ok := range p.window {
+		if !ok {
+			lost++
+		}
+	}
+	p.stats.Loss = float64(lost) / float64(len(p.window))
+}
+
+// startPeerPing запускает ping/pong на собственном QUIC соединении сессии
+func (m *Manager) startPeerPing(session *PeerSession, quicConn *quic.QUICConnection, interval time.Duration) {
+	if !quicConn.SupportsDatagrams() {
+		m.logger.Debug("Peer does not support QUIC datagrams, link quality is not measured",
+			"peer_id", session.PeerID)
+		return
+	}
+	session.mu.Lock()
+	session.ping = newPeerPinger()
+	session.mu.Unlock()
+
+	go m.receivePings(session, quicConn)
+	go m.sendPings(session, quicConn, interval)
+}
+
+// sendPings периодически отправляет ping пиру до закрытия сессии.
+// Ответ, не полученный за interval, считается потерей.
+func (m *Manager) sendPings(session *PeerSession, quicConn *quic.QUICConnection, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.ctx.Done():
+			return
+		case <-ticker.C:
+			session.mu.Lock()
+			seq := session.ping.nextPing(time.Now(), interval)
+			session.mu.Unlock()
+			if err := quicConn.SendDatagram(encodePing(pingTypePing, seq)); err != nil {
+				m.logger.Debug("Failed to send ping", "peer_id", session.PeerID, "error", err)
+			}
+		}
+	}
+}
+
+// receivePings отвечает на ping пира и учитывает pong до закрытия соединения
+func (m *Manager) receivePings(session *PeerSession, quicConn *quic.QUICConnection) {
+	for {
+		msg, err := quicConn.ReceiveDatagram(session.ctx)
+		if err != nil {
+			if session.ctx.Err() == nil {
+				m.logger.Debug("Stopped receiving pings", "peer_id", session.PeerID, "error", err)
+			}
+			return
+		}
+		kind, seq, err := decodePing(msg)
+		if err != nil {
+			m.logger.Debug("Ignoring peer datagram", "peer_id", session.PeerID, "error", err)
+			continue
+		}
+		if kind == pingTypePing {
+			if err := quicConn.SendDatagram(encodePing(pingTypePong, seq)); err != nil {
+				m.logger.Debug("Failed to send pong", "peer_id", session.PeerID, "error", err)
+			}
+			continue
+		}
+
+		now := time.Now()
+		session.mu.Lock()
+		ok := session.ping.handlePong(seq, now)
+		stats := session.ping.stats
+		session.mu.Unlock()
+		if ok {
+			m.recordPeerPong(session, stats)
+		}
+	}
+}
+
+// recordPeerPong отмечает пира живым и передает mesh измеренное качество соединения
+func (m *Manager) recordPeerPong(session *PeerSession, stats PeerLinkStats) {
+	m.mu.Lock()
+	if conn, ok := m.connections[session.PeerID]; ok && conn.SessionID == session.SessionID {
+		conn.LastSeen = stats.LastPong
+	}
+	mesh := m.mesh
+	m.mu.Unlock()
+
+	if mesh != nil {
+		mesh.RecordPeerPing(session.PeerID, stats.RTT.Milliseconds(), stats.Jitter.Milliseconds(), stats.Loss)
+	}
+}
+
+// closeDeadPeer закрывает сессию с пиром, которого mesh признал недоступным
+func (m *Manager) closeDeadPeer(peerID string) {
+	m.mu.RLock()
+	_, ok := m.sessions[peerID]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+	m.logger.Warn("Closing P2P session with dead peer", "peer_id", peerID)
+	if err := m.ClosePeerSession(peerID); err != nil {
+		m.logger.Debug("Failed to close dead peer session", "peer_id", peerID, "error", err)
+	}
+}
@@ -0,0 +1,166 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPeerPinger_Stats(t *testing.T) {
+	p := newPeerPinger()
+	start := time.Now()
+
+	seq := p.nextPing(start, time.Second)
+	if !p.handlePong(seq, start.Add(40*time.Millisecond)) {
+		t.Fatal("Expected pong to match outstanding ping")
+	}
+	if p.handlePong(seq, start.Add(50*time.Millisecond)) {
+		t.Error("Expected duplicate pong to be ignored")
+	}
+	seq = p.nextPing(start.Add(time.Second), time.Second)
+	p.handlePong(seq, start.Add(time.Second+72*time.Millisecond))
+
+	if p.stats.RTT != 44*time.Millisecond || p.stats.Jitter != 2*time.Millisecond {
+		t.Errorf("Unexpected RTT/jitter: %v/%v", p.stats.RTT, p.stats.Jitter)
+	}
+
+	// Ping без ответа считается потерянным по истечении таймаута
+	p.nextPing(start.Add(2*time.Second), time.Second)
+	p.nextPing(start.Add(4*time.Second), time.Second)
+	if p.stats.Loss != 1.0/3 || p.stats.Sent != 4 || p.stats.Received != 2 {
+		t.Errorf("Unexpected loss stats: %+v", p.stats)
+	}
+
+	if _, _, err := decodePing([]byte{pingTypePong, 0, 0}); err == nil {
+		t.Error("Expected short datagram to be rejected")
+	}
+}
+
+func TestManager_PeerPingUpdatesMesh(t *testing.T) {
+	a := newSessionTestManager(10)
+	b := newSessionTestManager(10)
+	cert, err := newPeerCertificate("key-b")
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	b.tlsCert = cert
+
+	mesh := newTestMesh(t, RoutingHybrid)
+	mesh.UpsertPeer(&Peer{ID: "peer-b", IsConnected: true, Path: PathDirect})
+	a.mesh = mesh
+
+	connA, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	connB, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	outbound, err := a.newSession("peer-b", "s1", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer outbound.Close()
+	inbound, err := b.newSession("peer-a", "s1", true)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer inbound.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		quicConn, _, err := b.acceptPeerQUIC(inbound, connB)
+		if err == nil {
+			inbound.mu.Lock()
+			inbound.quicConn, inbound.ownsQUIC, inbound.pathConn = quicConn, true, connB
+			inbound.mu.Unlock()
+			b.startPeerPing(inbound, quicConn, time.Hour)
+		}
+		done <- err
+	}()
+	quicConn, _, err := a.dialPeerQUIC(outbound, connA, connB.LocalAddr())
+	if err != nil {
+		t.Fatalf("Failed to dial peer: %v", err)
+	}
+	outbound.mu.Lock()
+	outbound.quicConn, outbound.ownsQUIC, outbound.pathConn = quicConn, true, connA
+	outbound.mu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to accept peer: %v", err)
+	}
+
+	// Ping отправляет только a; b отвечает pong
+	a.startPeerPing(outbound, quicConn, 20*time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		link := outbound.Info().Link
+		if link != nil && link.Received >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for pongs, link stats %+v", link)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Ответы на ping — единственный источник живости: discovery не обновляет LastSeen
+	mesh.mu.Lock()
+	mesh.topology.ConnectedPeers["peer-b"].LastSeen = time.Now().Add(-time.Hour).Unix()
+	mesh.mu.Unlock()
+	mesh.UpsertPeer(&Peer{ID: "peer-b", IsConnected: true})
+	if peer := mesh.GetTopology().ConnectedPeers["peer-b"]; time.Since(time.Unix(peer.LastSeen, 0)) < time.Minute {
+		t.Error("Expected discovery not to refresh liveness of a pinged peer")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Since(time.Unix(mesh.GetTopology().ConnectedPeers["peer-b"].LastSeen, 0)) > time.Minute {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected pong to refresh peer liveness")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info := inbound.Info(); info.Link == nil || info.Link.Sent != 0 {
+		t.Errorf("Expected responder to only answer pings, got %+v", info.Link)
+	}
+}
+
+func TestMesh_RemovesDeadPeers(t *testing.T) {
+	mn := newTestMesh(t, RoutingHybrid)
+	var dead []string
+	mn.OnPeerDead(func(peerID string) { dead = append(dead, peerID) })
+
+	mn.UpsertPeer(&Peer{ID: "a", IsConnected: true, Path: PathDirect})
+	mn.UpsertPeer(&Peer{ID: "b", IsConnected: true, Path: PathDirect})
+	mn.RecordPeerPing("a", 12, 3, 0.1)
+
+	mn.mu.Lock()
+	mn.topology.ConnectedPeers["a"].LastSeen = time.Now().Add(-2 * mn.config.PeerStaleAfter).Unix()
+	mn.mu.Unlock()
+	mn.updateTopology()
+	if mn.GetTopology().ConnectedPeers["a"].IsConnected {
+		t.Error("Expected stale peer to be marked disconnected")
+	}
+
+	// Ответ на ping возвращает пира в маршрутизацию
+	mn.RecordPeerPing("a", 20, 3, 0.1)
+	expectRoute(t, mn, "a", []string{"a"}, 20)
+
+	mn.mu.Lock()
+	mn.topology.ConnectedPeers["a"].LastSeen = time.Now().Add(-2 * mn.config.PeerDeadAfter).Unix()
+	mn.mu.Unlock()
+	mn.performHealthChecks()
+
+	topology := mn.GetTopology()
+	if _, ok := topology.ConnectedPeers["a"]; ok || len(dead) != 1 || dead[0] != "a" {
+		t.Errorf("Expected dead peer a to be removed, dead=%v", dead)
+	}
+	if _, ok := topology.ConnectedPeers["b"]; !ok {
+		t.Error("Expected live peer b to stay in mesh")
+	}
+	if _, err := mn.GetOptimalRoute("a"); err == nil {
+		t.Error("Expected route to dead peer to be removed")
+	}
+}
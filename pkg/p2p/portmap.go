@@ -0,0 +1,109 @@
+package p2p
+
+import (
+	"net"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/portmap"
+	pionice "github.com/pion/ice/v2"
+)
+
+// SetPortMapping включает общий UDP сокет ICE агентов на listenPort (0 — случайный порт)
+// и запрос его внешнего отображения на роутере через PCP, NAT-PMP или UPnP IGD
+func (m *Manager) SetPortMapping(config *portmap.Config, listenPort int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.portMapConfig = config
+	m.listenPort = listenPort
+}
+
+// startPortMapping открывает общий ICE сокет и запускает клиент отображения порта.
+// Вызывается из Start под m.mu.
+func (m *Manager) startPortMapping() {
+	if m.portMapConfig == nil {
+		return
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: m.listenPort})
+	if err != nil {
+		m.logger.Warn("Failed to open shared ICE socket, port mapping disabled", "port", m.listenPort, "error", err)
+		return
+	}
+	m.udpMux = pionice.NewUniversalUDPMuxDefault(pionice.UniversalUDPMuxParams{UDPConn: conn})
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// Без отображения общий сокет остается: порт можно пробросить на роутере вручную
+	client := portmap.NewClient(m.portMapConfig, m.logger)
+	if err := client.Start(port); err != nil {
+		m.logger.Warn("Failed to start port mapping", "port", port, "error", err)
+		return
+	}
+	m.portMapper = client
+	m.logger.Info("Port mapping enabled", "port", port, "protocols", m.portMapConfig.Protocols)
+}
+
+// stopPortMapping удаляет отображение на роутере; сокет закрывается после сессий в Stop
+func (m *Manager) stopPortMapping() {
+	m.mu.RLock()
+	client := m.portMapper
+	m.mu.RUnlock()
+	if client != nil {
+		client.Stop()
+	}
+}
+
+// advertisedCandidates возвращает кандидаты сессии для пира: собранные агентом и
+// server-reflexive кандидат внешнего адреса из отображения порта на роутере
+func (m *Manager) advertisedCandidates(session *PeerSession) []pionice.Candidate {
+	session.mu.RLock()
+	candidates := session.localCandidates
+	session.mu.RUnlock()
+
+	mapped := m.mappedCandidate(candidates)
+	if mapped == nil {
+		return candidates
+	}
+	return append(append(make([]pionice.Candidate, 0, len(candidates)+1), candidates...), mapped)
+}
+
+// mappedCandidate создает кандидат внешнего адреса отображения (nil — отображения нет
+// или такой кандидат уже собран через STUN)
+func (m *Manager) mappedCandidate(local []pionice.Candidate) pionice.Candidate {
+	m.mu.RLock()
+	client := m.portMapper
+	m.mu.RUnlock()
+	if client == nil {
+		return nil
+	}
+	mapping := client.Mapping()
+	if mapping == nil {
+		return nil
+	}
+
+	address := mapping.External.IP.String()
+	relAddr := ""
+	for _, candidate := range local {
+		if candidate.Address() == address && candidate.Port() == mapping.External.Port {
+			return nil
+		}
+		if relAddr == "" && candidate.Type() == pionice.CandidateTypeHost && candidate.Port() == mapping.InternalPort {
+			relAddr = candidate.Address()
+		}
+	}
+	if relAddr == "" {
+		relAddr = net.IPv4zero.String()
+	}
+
+	candidate, err := pionice.NewCandidateServerReflexive(&pionice.CandidateServerReflexiveConfig{
+		Network:   "udp",
+		Address:   address,
+		Port:      mapping.External.Port,
+		Component: pionice.ComponentRTP,
+		RelAddr:   relAddr,
+		RelPort:   mapping.InternalPort,
+	})
+	if err != nil {
+		m.logger.Debug("Failed to create port mapping candidate", "error", err)
+		return nil
+	}
+	return candidate
+}
@@ -0,0 +1,110 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/portmap"
+	pionice "github.com/pion/ice/v2"
+)
+
+// startTestNATPMP NAT-PMP шлюз на loopback: внешний адрес 203.0.113.9, внешний порт = внутренний + 1.
+// deletions считает запросы удаления отображения.
+func startTestNATPMP(t *testing.T, deletions *atomic.Int32) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() }) //nolint:errcheck // test cleanup
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var resp []byte
+			switch {
+			case n == 2 && buf[1] == 0:
+				resp = []byte{0, 128, 0, 0, 0, 0, 0, 1, 203, 0, 113, 9}
+			case n == 12 && buf[1] == 1:
+				lifetime := binary.BigEndian.Uint32(buf[8:12])
+				if lifetime == 0 {
+					deletions.Add(1)
+				}
+				resp = make([]byte, 16)
+				resp[1] = 129
+				copy(resp[8:10], buf[4:6])
+				binary.BigEndian.PutUint16(resp[10:12], binary.BigEndian.Uint16(buf[4:6])+1)
+				binary.BigEndian.PutUint32(resp[12:16], lifetime)
+			default:
+				continue
+			}
+			_, _ = conn.WriteToUDP(resp, from) //nolint:errcheck // test gateway
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestManager_PortMappingCandidate(t *testing.T) {
+	var deletions atomic.Int32
+	gateway := startTestNATPMP(t, &deletions)
+
+	m := newSessionTestManager(10)
+	m.SetPortMapping(&portmap.Config{
+		Protocols: []portmap.Protocol{portmap.ProtocolNATPMP},
+		Gateway:   gateway,
+		Lifetime:  time.Hour,
+	}, 0)
+	m.mu.Lock()
+	m.startPortMapping()
+	m.mu.Unlock()
+	if m.udpMux == nil || m.portMapper == nil {
+		t.Fatal("Expected shared ICE socket and port mapping client")
+	}
+
+	session, err := m.newSession("peer-a", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := session.gather(m.iceServers()); err != nil {
+		t.Fatalf("Failed to gather candidates: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for m.portMapper.Mapping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for port mapping")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mapping := m.portMapper.Mapping()
+
+	candidates := m.advertisedCandidates(session)
+	var mapped pionice.Candidate
+	for _, c := range candidates {
+		if c.Type() == pionice.CandidateTypeServerReflexive && c.Address() == "203.0.113.9" {
+			mapped = c
+		} else if c.Type() == pionice.CandidateTypeHost && c.Port() != mapping.InternalPort {
+			t.Errorf("Host candidate %s does not use the shared port %d", c, mapping.InternalPort)
+		}
+	}
+	if mapped == nil {
+		t.Fatalf("Expected mapped server-reflexive candidate, got %v", candidates)
+	}
+	if mapped.Port() != mapping.InternalPort+1 || mapped.RelatedAddress().Port != mapping.InternalPort {
+		t.Errorf("Unexpected mapped candidate %s", mapped)
+	}
+
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if deletions.Load() != 1 {
+		t.Errorf("Expected mapping to be removed on Stop, got %d deletions", deletions.Load())
+	}
+}
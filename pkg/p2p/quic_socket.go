@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// PeerALPN ALPN протокол прямых QUIC соединений между пирами
+const PeerALPN = "cloudbridge-p2p"
+
+const (
+	// peerHelloPrefix первая строка stream пира: P2P_HELLO <session_id>
+	peerHelloPrefix = "P2P_HELLO "
+	// relayToPrefix заголовок маршрутизации stream через relay: TO:<peer_id>:
+	relayToPrefix = "TO:"
+	// maxPeerHelloLen ограничивает длину строки приветствия
+	maxPeerHelloLen = 256
+)
+
+// writePeerHello отправляет приветствие с session ID
+func writePeerHello(w io.Writer, sessionID string) error {
+	_, err := io.WriteString(w, peerHelloPrefix+sessionID+"\n")
+	return err
+}
+
+// readPeerHello читает приветствие побайтно, не забирая данные после него
+func readPeerHello(r io.Reader) (string, error) {
+	line := make([]byte, 0, 64)
+	b := make([]byte, 1)
+	for len(line) < maxPeerHelloLen {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", fmt.Errorf("failed to read peer hello: %w", err)
+		}
+		if b[0] == '\n' {
+			hello := string(line)
+			if !strings.HasPrefix(hello, peerHelloPrefix) || len(hello) == len(peerHelloPrefix) {
+				return "", fmt.Errorf("unexpected peer hello: %q", hello)
+			}
+			return strings.TrimPrefix(hello, peerHelloPrefix), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", fmt.Errorf("peer hello exceeds %d bytes", maxPeerHelloLen)
+}
+
+// icePacketConn представляет соединенный net.Conn выбранной ICE пары как
+// net.PacketConn, чтобы quic.Transport мог работать поверх ICE сокета
+type icePacketConn struct {
+	net.Conn
+}
+
+func newICEPacketConn(conn net.Conn) *icePacketConn {
+	return &icePacketConn{Conn: conn}
+}
+
+// ReadFrom читает датаграмму; отправителем всегда является удаленный кандидат пары
+func (c *icePacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, err := c.Conn.Read(p)
+	return n, c.Conn.RemoteAddr(), err
+}
+
+// WriteTo отправляет датаграмму удаленному кандидату пары (адрес игнорируется)
+func (c *icePacketConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	return c.Conn.Write(p)
+}
+
+// newPeerCertificate создает самоподписанный сертификат для QUIC между пирами.
+// Пиры аутентифицируются через relay сигналинг, сертификат нужен только для TLS 1.3.
+func newPeerCertificate(peerID string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: peerID},
+		DNSNames:     []string{peerID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
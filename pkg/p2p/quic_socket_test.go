@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+)
+
+// connectedUDPPair возвращает два соединенных друг с другом UDP сокета (аналог выбранной ICE пары)
+func connectedUDPPair(t *testing.T) (*net.UDPConn, *net.UDPConn) {
+	t.Helper()
+	loopback := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	a, err := net.ListenUDP("udp", loopback)
+	if err != nil {
+		t.Fatalf("Failed to open UDP socket: %v", err)
+	}
+	b, err := net.DialUDP("udp", loopback, a.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Failed to dial UDP socket: %v", err)
+	}
+	aAddr := a.LocalAddr().(*net.UDPAddr)
+	a.Close()
+
+	// Переоткрываем a как соединенный сокет на том же порту
+	a, err = net.DialUDP("udp", aAddr, b.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Failed to reopen UDP socket: %v", err)
+	}
+	return a, b
+}
+
+func TestQUICOverICEPacketConn(t *testing.T) {
+	serverSock, clientSock := connectedUDPPair(t)
+	defer serverSock.Close()
+	defer clientSock.Close()
+
+	cert, err := newPeerCertificate("peer-a")
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server := quic.NewQUICConnection(NewSimpleLogger("server"))
+	server.SetServerTLSCert(cert)
+	server.SetALPN(PeerALPN)
+	defer server.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- server.AcceptOn(ctx, newICEPacketConn(serverSock))
+	}()
+
+	client := quic.NewQUICConnection(NewSimpleLogger("client"))
+	client.SetALPN(PeerALPN)
+	client.SetInsecureSkipVerify(true)
+	defer client.Close()
+
+	if err := client.DialOn(ctx, newICEPacketConn(clientSock), serverSock.LocalAddr()); err != nil {
+		t.Fatalf("DialOn failed: %v", err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatalf("AcceptOn failed: %v", err)
+	}
+
+	stream, err := client.CreateStream(ctx, "peer_peer-a")
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+	if err := writePeerHello(stream, "sess-1"); err != nil {
+		t.Fatalf("Failed to write hello: %v", err)
+	}
+
+	incoming, err := server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to accept stream: %v", err)
+	}
+	sessionID, err := readPeerHello(incoming)
+	if err != nil {
+		t.Fatalf("Failed to read hello: %v", err)
+	}
+	if sessionID != "sess-1" {
+		t.Errorf("Expected session sess-1, got %s", sessionID)
+	}
+}
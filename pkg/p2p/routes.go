@@ -0,0 +1,313 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// exitNodeRoutes маршруты по умолчанию, которые объявляет exit node
+var exitNodeRoutes = []string{"0.0.0.0/0", "::/0"}
+
+// RoutesConfig подсети, которые клиент объявляет другим пирам (subnet router, exit node),
+// и объявленные другими пирами подсети, которые он использует
+type RoutesConfig struct {
+	Advertise   []string // подсети LAN за клиентом, доступные пирам через него
+	ExitNode    bool     // объявлять маршрут по умолчанию (0.0.0.0/0, ::/0)
+	Accept      []string // подсети LAN других пиров, которые использует клиент
+	UseExitNode string   // peer ID exit node для всего остального трафика (пусто — не использовать)
+	SNAT        bool     // маскарадинг трафика overlay сети в объявленные подсети
+}
+
+// RouteFilter выбирает объявленные пирами маршруты, которые использует клиент.
+// Адреса overlay сети используются всегда, подсети LAN — только из Accept,
+// маршрут по умолчанию — только от выбранного exit node.
+type RouteFilter struct {
+	OverlaySubnet string   // подсеть overlay тенанта (пусто — overlay только маршруты хостов)
+	Accept        []string // принятые подсети LAN
+	ExitNode      string   // peer ID выбранного exit node
+}
+
+// AdvertisedRoute подсеть, объявленная пиром
+type AdvertisedRoute struct {
+	PeerID   string `json:"peer_id"`
+	Prefix   string `json:"prefix"`
+	ExitNode bool   `json:"exit_node"`
+	Accepted bool   `json:"accepted"`
+}
+
+// Accepts сообщает, использует ли клиент маршрут prefix, объявленный пиром peerID
+func (f *RouteFilter) Accepts(peerID, prefix string) bool {
+	network := parseRoute(prefix)
+	if network == nil {
+		return false
+	}
+	if isDefaultRoute(prefix) {
+		return f != nil && f.ExitNode != "" && f.ExitNode == peerID
+	}
+	if !f.advertised(prefix) {
+		return true
+	}
+	if f == nil {
+		return false
+	}
+	for _, accept := range f.Accept {
+		if acceptNetwork := parseRoute(accept); acceptNetwork != nil && containsNetwork(acceptNetwork, network) {
+			return true
+		}
+	}
+	return false
+}
+
+// advertised сообщает, является ли prefix объявленным маршрутом (подсеть LAN или маршрут
+// по умолчанию), а не адресом overlay сети
+func (f *RouteFilter) advertised(prefix string) bool {
+	network := parseRoute(prefix)
+	if network == nil {
+		return false
+	}
+	ones, bits := network.Mask.Size()
+	if ones == 0 {
+		return true
+	}
+	if ones == bits {
+		return false
+	}
+	// Пока подсеть overlay неизвестна, любая подсеть считается объявленной: без согласия
+	// пир не может забрать трафик чужой LAN
+	if f == nil || f.OverlaySubnet == "" {
+		return true
+	}
+	overlay := parseRoute(f.OverlaySubnet)
+	return overlay != nil && !containsNetwork(overlay, network)
+}
+
+// NormalizeRoutes проверяет подсети и приводит их к адресу сети (192.168.1.7/24 -> 192.168.1.0/24);
+// адрес без маски считается одним хостом. Повторы удаляются.
+func NormalizeRoutes(routes []string) ([]string, error) {
+	normalized := make([]string, 0, len(routes))
+	for _, route := range routes {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+		network := parseRoute(route)
+		if network == nil {
+			return nil, fmt.Errorf("invalid route %q", route)
+		}
+		if !slices.Contains(normalized, network.String()) {
+			normalized = append(normalized, network.String())
+		}
+	}
+	return normalized, nil
+}
+
+// parseRoute разбирает CIDR или адрес хоста
+func parseRoute(route string) *net.IPNet {
+	if _, network, err := net.ParseCIDR(route); err == nil {
+		return network
+	}
+	ip := net.ParseIP(route)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// isDefaultRoute сообщает, является ли prefix маршрутом по умолчанию
+func isDefaultRoute(prefix string) bool {
+	network := parseRoute(prefix)
+	if network == nil {
+		return false
+	}
+	ones, _ := network.Mask.Size()
+	return ones == 0
+}
+
+// containsNetwork сообщает, входит ли подсеть inner целиком в outer
+func containsNetwork(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// SetRoutes задает объявляемые и используемые клиентом маршруты. Вызывается до Start.
+func (m *Manager) SetRoutes(config *RoutesConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routesConfig = config
+}
+
+// advertisedRoutesLocked возвращает маршруты, которые объявляет клиент. Вызывается под m.mu.
+func (m *Manager) advertisedRoutesLocked() []string {
+	if m.routesConfig == nil {
+		return nil
+	}
+	routes, err := NormalizeRoutes(m.routesConfig.Advertise)
+	if err != nil {
+		m.logger.Warn("Ignoring invalid advertised routes", "error", err)
+		routes = nil
+	}
+	if m.routesConfig.ExitNode {
+		routes = append(routes, exitNodeRoutes...)
+	}
+	return routes
+}
+
+// advertiseRoutesLocked регистрирует пира с объявленными маршрутами в AllowedIPs.
+// Вызывается из Start под m.mu после регистрации пира.
+func (m *Manager) advertiseRoutesLocked() error {
+	if m.apiManager == nil {
+		return nil
+	}
+	if m.routesBase == nil {
+		m.routesBase = m.apiManager.GetAllowedIPs()
+	}
+	routes := m.advertisedRoutesLocked()
+	if len(routes) == 0 {
+		return nil
+	}
+	return m.registerRoutes(m.routesBase, routes)
+}
+
+// registerRoutes перерегистрирует пира с AllowedIPs base и маршрутами routes
+func (m *Manager) registerRoutes(base, routes []string) error {
+	allowedIPs := append([]string{}, base...)
+	for _, route := range routes {
+		if !slices.Contains(allowedIPs, route) {
+			allowedIPs = append(allowedIPs, route)
+		}
+	}
+	if err := m.apiManager.RegisterPeerWith(m.apiManager.GetPublicKey(), allowedIPs); err != nil {
+		return fmt.Errorf("failed to advertise routes: %w", err)
+	}
+	m.logger.Info("Advertised routes", "routes", routes)
+	return nil
+}
+
+// AdvertiseRoutes меняет объявляемые клиентом подсети LAN и признак exit node
+func (m *Manager) AdvertiseRoutes(routes []string, exitNode bool) error {
+	normalized, err := NormalizeRoutes(routes)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.routesConfig == nil {
+		m.routesConfig = &RoutesConfig{SNAT: true}
+	}
+	m.routesConfig.Advertise = normalized
+	m.routesConfig.ExitNode = exitNode
+	advertised := m.advertisedRoutesLocked()
+	base, apiManager := m.routesBase, m.apiManager
+	m.mu.Unlock()
+
+	if apiManager != nil && base != nil {
+		if err := m.registerRoutes(base, advertised); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status.IsConnected {
+		m.stopForwardingLocked()
+		m.startForwardingLocked()
+	}
+	return nil
+}
+
+// UseRoutes задает подсети LAN других пиров и exit node (пусто — не использовать),
+// через которые клиент направляет трафик
+func (m *Manager) UseRoutes(accept []string, exitNode string) error {
+	normalized, err := NormalizeRoutes(accept)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routesConfig == nil {
+		m.routesConfig = &RoutesConfig{SNAT: true}
+	}
+	m.routesConfig.Accept = normalized
+	m.routesConfig.UseExitNode = exitNode
+	if m.mesh != nil {
+		m.mesh.SetRouteFilter(m.routeFilterLocked())
+	}
+	m.logger.Info("Using mesh routes", "accept", normalized, "exit_node", exitNode)
+	return nil
+}
+
+// routeFilterLocked возвращает фильтр маршрутов mesh сети. Вызывается под m.mu.
+func (m *Manager) routeFilterLocked() *RouteFilter {
+	filter := &RouteFilter{OverlaySubnet: m.overlaySubnetLocked()}
+	if m.routesConfig != nil {
+		filter.Accept = m.routesConfig.Accept
+		filter.ExitNode = m.routesConfig.UseExitNode
+	}
+	return filter
+}
+
+// overlaySubnetLocked возвращает подсеть overlay тенанта: из конфигурации WireGuard
+// или network_config токена. Вызывается под m.mu.
+func (m *Manager) overlaySubnetLocked() string {
+	if m.tenantCIDR != "" {
+		return m.tenantCIDR
+	}
+	if m.config.NetworkConfig != nil {
+		return m.config.NetworkConfig.Subnet
+	}
+	return ""
+}
+
+// startForwardingLocked включает пересылку пакетов из overlay сети в объявленные подсети.
+// Ошибки не фатальны: пересылку можно настроить и вручную. Вызывается под m.mu.
+func (m *Manager) startForwardingLocked() {
+	routes := m.advertisedRoutesLocked()
+	if len(routes) == 0 {
+		return
+	}
+	overlay := m.overlaySubnetLocked()
+	snat := m.routesConfig.SNAT
+	if snat && overlay == "" {
+		m.logger.Warn("Overlay subnet unknown, SNAT for advertised routes disabled")
+		snat = false
+	}
+	restore, err := enableForwarding(overlay, routes, snat)
+	if err != nil {
+		m.logger.Warn("Failed to enable forwarding for advertised routes", "error", err)
+		return
+	}
+	m.forwardRestore = restore
+	m.logger.Info("Forwarding enabled for advertised routes", "routes", routes, "snat", snat)
+}
+
+// stopForwardingLocked восстанавливает настройки пересылки пакетов. Вызывается под m.mu.
+func (m *Manager) stopForwardingLocked() {
+	if m.forwardRestore == nil {
+		return
+	}
+	if err := m.forwardRestore(); err != nil {
+		m.logger.Warn("Failed to restore forwarding settings", "error", err)
+	}
+	m.forwardRestore = nil
+}
+
+// routesStatusLocked возвращает объявленные и доступные маршруты. Вызывается под m.mu.
+func (m *Manager) routesStatusLocked() map[string]interface{} {
+	status := map[string]interface{}{
+		"advertised":    m.advertisedRoutesLocked(),
+		"accept":        m.routesConfig.Accept,
+		"use_exit_node": m.routesConfig.UseExitNode,
+		"forwarding":    m.forwardRestore != nil,
+	}
+	if m.mesh != nil {
+		status["available"] = m.mesh.AdvertisedRoutes()
+	}
+	return status
+}
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestManager_AdvertisedRoutesRequireOptIn(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID, m.tenantCIDR = "local", "10.0.0.0/24"
+	m.mesh = newTestMesh(t, RoutingDirect)
+	m.mesh.SetRouteFilter(m.routeFilterLocked())
+	m.mesh.UpsertPeer(&Peer{ID: "office", IsConnected: true, Latency: 10, Path: PathDirect,
+		AllowedIPs: []string{"10.0.0.2/32", "192.168.10.0/24"}})
+	m.mesh.UpsertPeer(&Peer{ID: "gateway", IsConnected: true, Latency: 20, Path: PathDirect,
+		AllowedIPs: []string{"10.0.0.3/32", "0.0.0.0/0", "::/0"}})
+
+	// Без согласия используются только адреса overlay сети
+	if _, err := m.mesh.GetOptimalRoute("192.168.10.0/24"); err == nil {
+		t.Error("Expected LAN subnet to be ignored without opt-in")
+	}
+	if peer, ok := m.mesh.PeerForIP(net.ParseIP("8.8.8.8")); ok {
+		t.Errorf("Expected no exit node, got %s", peer)
+	}
+	if allowed := m.mesh.AcceptedAllowedIPs("gateway"); !slices.Equal(allowed, []string{"10.0.0.3/32"}) {
+		t.Errorf("Unexpected WireGuard AllowedIPs: %v", allowed)
+	}
+	if routes := m.mesh.AdvertisedRoutes(); len(routes) != 3 || routes[0].PeerID != "gateway" ||
+		!routes[0].ExitNode || routes[0].Accepted || routes[2].Prefix != "192.168.10.0/24" {
+		t.Errorf("Unexpected advertised routes: %+v", routes)
+	}
+
+	if err := m.UseRoutes([]string{"192.168.10.0/24", "bad"}, "gateway"); err == nil {
+		t.Error("Expected invalid route to be rejected")
+	}
+	if err := m.UseRoutes([]string{"192.168.10.0/23"}, "gateway"); err != nil {
+		t.Fatalf("UseRoutes failed: %v", err)
+	}
+	expectRoute(t, m.mesh, "192.168.10.0/24", []string{"office"}, 10)
+	if peer, _ := m.mesh.PeerForIP(net.ParseIP("192.168.10.20")); peer != "office" {
+		t.Errorf("LAN address routed to %q, want office", peer)
+	}
+	// Остальной трафик уходит через exit node, overlay адреса — напрямую к пирам
+	if peer, _ := m.mesh.PeerForIP(net.ParseIP("8.8.8.8")); peer != "gateway" {
+		t.Errorf("Internet address routed to %q, want gateway", peer)
+	}
+	if peer, _ := m.mesh.PeerForIP(net.ParseIP("10.0.0.2")); peer != "office" {
+		t.Errorf("Overlay address routed to %q, want office", peer)
+	}
+	if allowed := m.mesh.AcceptedAllowedIPs("gateway"); len(allowed) != 3 {
+		t.Errorf("Unexpected exit node AllowedIPs: %v", allowed)
+	}
+}
+
+func TestRouteFilter_UnknownOverlaySubnet(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID = "local"
+	m.mesh = newTestMesh(t, RoutingDirect)
+	m.mesh.SetRouteFilter(m.routeFilterLocked())
+	m.mesh.UpsertPeer(&Peer{ID: "office", IsConnected: true, Latency: 10, Path: PathDirect,
+		AllowedIPs: []string{"10.0.0.2/32", "fd00::2/128", "10.0.0.0/24", "192.168.0.0/16"}})
+
+	// Подсеть overlay неизвестна: без согласия принимаются только маршруты хостов
+	if allowed := m.mesh.AcceptedAllowedIPs("office"); !slices.Equal(allowed, []string{"10.0.0.2/32", "fd00::2/128"}) {
+		t.Errorf("Unexpected WireGuard AllowedIPs: %v", allowed)
+	}
+	if peer, ok := m.mesh.PeerForIP(net.ParseIP("192.168.1.10")); ok {
+		t.Errorf("Expected LAN address not to be routed, got %s", peer)
+	}
+	if peer, _ := m.mesh.PeerForIP(net.ParseIP("10.0.0.2")); peer != "office" {
+		t.Errorf("Overlay host routed to %q, want office", peer)
+	}
+	for _, filter := range []*RouteFilter{nil, {}} {
+		if filter.Accepts("office", "192.168.0.0/16") || !filter.Accepts("office", "10.0.0.2") {
+			t.Errorf("Filter %+v must accept only host routes", filter)
+		}
+	}
+
+	// Подсеть из конфигурации WireGuard снова делает подсеть тенанта overlay
+	m.tenantCIDR = "10.0.0.0/24"
+	m.mesh.SetRouteFilter(m.routeFilterLocked())
+	if allowed := m.mesh.AcceptedAllowedIPs("office"); len(allowed) != 3 || slices.Contains(allowed, "192.168.0.0/16") {
+		t.Errorf("Unexpected WireGuard AllowedIPs with known overlay subnet: %v", allowed)
+	}
+}
+
+func TestNormalizeRoutes(t *testing.T) {
+	routes, err := NormalizeRoutes([]string{"192.168.1.7/24", " 192.168.1.0/24", "10.1.2.3", "fd00::1/64", ""})
+	if err != nil {
+		t.Fatalf("NormalizeRoutes failed: %v", err)
+	}
+	if want := []string{"192.168.1.0/24", "10.1.2.3/32", "fd00::/64"}; !slices.Equal(routes, want) {
+		t.Errorf("NormalizeRoutes = %v, want %v", routes, want)
+	}
+	if _, err := NormalizeRoutes([]string{"192.168.1.0/33"}); err == nil {
+		t.Error("Expected invalid prefix to be rejected")
+	}
+
+	m := newSessionTestManager(10)
+	m.SetRoutes(&RoutesConfig{Advertise: []string{"192.168.1.7/24"}, ExitNode: true})
+	if routes := m.advertisedRoutesLocked(); !slices.Equal(routes, []string{"192.168.1.0/24", "0.0.0.0/0", "::/0"}) {
+		t.Errorf("Unexpected advertised routes: %v", routes)
+	}
+}
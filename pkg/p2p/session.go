@@ -0,0 +1,560 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/ice"
+	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+	pionice "github.com/pion/ice/v2"
+	quicgo "github.com/quic-go/quic-go"
+)
+
+// SessionState состояние ICE сессии с пиром
+type SessionState string
+
+const (
+	SessionStateNew       SessionState = "new"
+	SessionStateGathering SessionState = "gathering" // сбор локальных кандидатов
+	SessionStateSignaling SessionState = "signaling" // обмен credentials и кандидатами через relay API
+	SessionStateChecking  SessionState = "checking"  // ICE connectivity checks
+	SessionStateConnected SessionState = "connected"
+	SessionStateFailed    SessionState = "failed"
+	SessionStateClosed    SessionState = "closed"
+)
+
+// IsTerminal сообщает, что сессия завершена и не занимает слот соединения
+func (s SessionState) IsTerminal() bool {
+	return s == SessionStateFailed || s == SessionStateClosed
+}
+
+// PathType путь, по которому идет трафик пира
+type PathType string
+
+const (
+	PathDirect PathType = "direct" // QUIC поверх выбранной ICE пары
+	PathTURN   PathType = "turn"   // QUIC поверх ICE пары с relay кандидатом (TURN)
+	PathDERP   PathType = "derp"   // QUIC поверх пакетов, пересылаемых DERP relay по ключу пира
+	PathRelay  PathType = "relay"  // stream на QUIC соединении с relay
+)
+
+// IsICE сообщает, что трафик идет по ICE паре (прямо или через TURN)
+func (p PathType) IsICE() bool {
+	return p == PathDirect || p == PathTURN
+}
+
+// viaRelay сообщает, что трафик идет через relay сервер (DERP или relay stream)
+func (p PathType) viaRelay() bool {
+	return p == PathDERP || p == PathRelay
+}
+
+// Ошибки установки P2P сессий
+var (
+	ErrMaxConnections    = errors.New("maximum number of P2P connections reached")
+	ErrSessionInProgress = errors.New("P2P session with peer is already in progress")
+	errRestartInProgress = errors.New("ICE restart already in progress")
+)
+
+// peerConnectTimeout ограничивает ICE connectivity checks одной сессии
+const peerConnectTimeout = 30 * time.Second
+
+// PeerSession ICE сессия с одним пиром: собственный ICE агент, credentials,
+// наборы кандидатов, состояние и QUIC соединение
+type PeerSession struct {
+	PeerID    string
+	SessionID string
+	Inbound   bool // соединение инициировано удаленным пиром
+
+	iceAgent *ice.ICEAgent
+	iceConn  *pionice.Conn
+	quicConn *quic.QUICConnection // QUIC соединение, по которому идет трафик пира
+	ownsQUIC bool                 // quicConn принадлежит сессии и закрывается вместе с ней
+	stream   *quicgo.Stream
+	path     PathType
+	pathConn net.PacketConn // транспорт QUIC вне ICE (DERP), закрывается вместе с сессией
+	upgrade  bool           // пробная сессия перевода соединения на прямой путь
+	ping     *peerPinger    // измерения ping/pong (nil — соединение не измеряется)
+
+	localUfrag       string
+	localPwd         string
+	remoteUfrag      string
+	remotePwd        string
+	localCandidates  []pionice.Candidate
+	remoteCandidates []pionice.Candidate
+
+	// Данные пира, полученные через push сигналинг
+	pushedUfrag      string
+	pushedPwd        string
+	pushedCandidates []pionice.Candidate
+	remoteGathered   bool          // пир сообщил end_of_candidates
+	remoteSignal     chan struct{} // уведомление о новых push данных
+
+	// ICE restart
+	restarting  bool                          // ждем credentials пира новой генерации
+	recovering  bool                          // restart еще не завершился восстановлением соединения
+	iceRestarts int                           // число выполненных restart
+	trickle     func(pionice.Candidate)       // отправка кандидатов, собранных после restart
+	onICEState  func(pionice.ConnectionState) // задается менеджером при создании сессии
+
+	state       SessionState
+	lastError   string
+	createdAt   time.Time
+	connectedAt time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.RWMutex
+	logger Logger
+}
+
+// PeerSessionInfo снимок состояния сессии для статуса и диагностики
+type PeerSessionInfo struct {
+	PeerID           string         `json:"peer_id"`
+	SessionID        string         `json:"session_id"`
+	Inbound          bool           `json:"inbound"`
+	State            SessionState   `json:"state"`
+	LocalUfrag       string         `json:"local_ufrag"`
+	RemoteUfrag      string         `json:"remote_ufrag,omitempty"`
+	LocalCandidates  int            `json:"local_candidates"`
+	RemoteCandidates int            `json:"remote_candidates"`
+	RemoteAddr       string         `json:"remote_addr,omitempty"`
+	Path             PathType       `json:"path,omitempty"`
+	ICERestarts      int            `json:"ice_restarts,omitempty"`
+	LastError        string         `json:"last_error,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	ConnectedAt      time.Time      `json:"connected_at,omitempty"`
+	Link             *PeerLinkStats `json:"link,omitempty"`
+}
+
+// newPeerSession создает сессию с уникальными локальными ICE credentials;
+// входящая сессия использует session ID инициатора
+func newPeerSession(parent context.Context, peerID, sessionID string, inbound bool, logger Logger) *PeerSession {
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("sess_%s_%d", peerID, time.Now().UnixNano())
+	}
+	ctx, cancel := context.WithCancel(parent)
+	return &PeerSession{
+		PeerID:       peerID,
+		SessionID:    sessionID,
+		Inbound:      inbound,
+		localUfrag:   generateRandomString(8),
+		localPwd:     generateRandomString(22),
+		remoteSignal: make(chan struct{}, 1),
+		state:        SessionStateNew,
+		createdAt:    time.Now(),
+		ctx:          ctx,
+		cancel:       cancel,
+		logger:       logger,
+	}
+}
+
+// State возвращает текущее состояние сессии
+func (s *PeerSession) State() SessionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Stream возвращает QUIC stream пира (nil до установления соединения)
+func (s *PeerSession) Stream() *quicgo.Stream {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stream
+}
+
+// Info возвращает снимок состояния сессии
+func (s *PeerSession) Info() PeerSessionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info := PeerSessionInfo{
+		PeerID:           s.PeerID,
+		SessionID:        s.SessionID,
+		Inbound:          s.Inbound,
+		State:            s.state,
+		LocalUfrag:       s.localUfrag,
+		RemoteUfrag:      s.remoteUfrag,
+		LocalCandidates:  len(s.localCandidates),
+		RemoteCandidates: len(s.remoteCandidates),
+		Path:             s.path,
+		ICERestarts:      s.iceRestarts,
+		LastError:        s.lastError,
+		CreatedAt:        s.createdAt,
+		ConnectedAt:      s.connectedAt,
+	}
+	if s.iceConn != nil {
+		info.RemoteAddr = s.iceConn.RemoteAddr().String()
+	}
+	if s.ping != nil {
+		link := s.ping.stats
+		info.Link = &link
+	}
+	return info
+}
+
+// setState переводит сессию в новое состояние; терминальные состояния не покидаются
+func (s *PeerSession) setState(state SessionState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state.IsTerminal() {
+		return false
+	}
+	s.logger.Debug("P2P session state changed",
+		"peer_id", s.PeerID,
+		"session_id", s.SessionID,
+		"from", s.state,
+		"to", state)
+	s.state = state
+	if state == SessionStateConnected {
+		s.connectedAt = time.Now()
+	}
+	return true
+}
+
+// fail переводит сессию в failed, освобождает ресурсы и возвращает исходную ошибку
+func (s *PeerSession) fail(err error) error {
+	s.mu.Lock()
+	if !s.state.IsTerminal() {
+		s.state = SessionStateFailed
+		s.lastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	s.logger.Warn("P2P session failed", "peer_id", s.PeerID, "session_id", s.SessionID, "error", err)
+	if releaseErr := s.release(); releaseErr != nil {
+		s.logger.Debug("Failed to release P2P session resources", "peer_id", s.PeerID, "error", releaseErr)
+	}
+	return err
+}
+
+// Close закрывает сессию и освобождает ICE агент и stream
+func (s *PeerSession) Close() error {
+	s.mu.Lock()
+	if s.state == SessionStateClosed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.state = SessionStateClosed
+	s.mu.Unlock()
+
+	return s.release()
+}
+
+// release отменяет контекст сессии и закрывает stream, QUIC и ICE соединения и агент
+func (s *PeerSession) release() error {
+	s.cancel()
+
+	s.mu.Lock()
+	stream, iceConn, agent, pathConn := s.stream, s.iceConn, s.iceAgent, s.pathConn
+	var quicConn *quic.QUICConnection
+	if s.ownsQUIC {
+		quicConn = s.quicConn
+	}
+	s.stream, s.iceConn, s.iceAgent, s.quicConn, s.pathConn = nil, nil, nil, nil, nil
+	s.mu.Unlock()
+
+	var firstErr error
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			firstErr = fmt.Errorf("failed to close peer stream: %w", err)
+		}
+	}
+	if quicConn != nil {
+		if err := quicConn.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close peer QUIC connection: %w", err)
+		}
+	}
+	if pathConn != nil {
+		if err := pathConn.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close DERP connection: %w", err)
+		}
+	}
+	if iceConn != nil {
+		if err := iceConn.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close ICE connection: %w", err)
+		}
+	}
+	if agent != nil {
+		if err := agent.Stop(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to stop ICE agent: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// icePath определяет тип ICE пути: через TURN, если в выбранной паре есть relay кандидат
+func (s *PeerSession) icePath() PathType {
+	s.mu.RLock()
+	agent := s.iceAgent
+	s.mu.RUnlock()
+	if agent == nil {
+		return PathDirect
+	}
+
+	pair, err := agent.GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		return PathDirect
+	}
+	if pair.Local.Type() == pionice.CandidateTypeRelay || pair.Remote.Type() == pionice.CandidateTypeRelay {
+		return PathTURN
+	}
+	return PathDirect
+}
+
+// releaseICE закрывает ICE соединение и агент, сохраняя сессию (для перехода на relay)
+func (s *PeerSession) releaseICE() error {
+	s.mu.Lock()
+	iceConn, agent := s.iceConn, s.iceAgent
+	s.iceConn, s.iceAgent = nil, nil
+	s.mu.Unlock()
+
+	var firstErr error
+	if iceConn != nil {
+		if err := iceConn.Close(); err != nil {
+			firstErr = fmt.Errorf("failed to close ICE connection: %w", err)
+		}
+	}
+	if agent != nil {
+		if err := agent.Stop(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to stop ICE agent: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// gather запускает собственный ICE агент сессии и собирает локальные кандидаты
+func (s *PeerSession) gather(servers iceServerSet) error {
+	if !s.setState(SessionStateGathering) {
+		return fmt.Errorf("session closed")
+	}
+
+	agent := ice.NewICEAgent(servers.stun, servers.turn, s.logger)
+	agent.SetTURNCredentials(servers.turnUsername, servers.turnPassword)
+	agent.SetLocalCredentials(s.localUfrag, s.localPwd)
+	if servers.udpMux != nil {
+		agent.SetUDPMux(servers.udpMux)
+	}
+	agent.OnLocalCandidate(s.handleLocalCandidate)
+	agent.OnConnectionStateChange(s.handleICEState)
+	if err := agent.Start(); err != nil {
+		return fmt.Errorf("failed to start ICE agent: %w", err)
+	}
+
+	s.mu.Lock()
+	s.iceAgent = agent
+	s.mu.Unlock()
+
+	candidates, err := agent.GatherCandidates()
+	if err != nil {
+		return fmt.Errorf("failed to gather candidates: %w", err)
+	}
+
+	s.mu.Lock()
+	s.localCandidates = candidates
+	s.mu.Unlock()
+
+	s.logger.Debug("P2P session candidates gathered", "peer_id", s.PeerID, "count", len(candidates))
+	return nil
+}
+
+// setRemote сохраняет credentials и кандидаты пира и передает кандидаты агенту
+func (s *PeerSession) setRemote(ufrag, pwd string, candidates []pionice.Candidate) error {
+	if ufrag == "" || pwd == "" {
+		return fmt.Errorf("remote ICE credentials not available")
+	}
+
+	s.mu.Lock()
+	s.remoteUfrag = ufrag
+	s.remotePwd = pwd
+	s.remoteCandidates = candidates
+	agent := s.iceAgent
+	s.mu.Unlock()
+
+	if agent == nil {
+		return fmt.Errorf("session closed")
+	}
+	for _, candidate := range candidates {
+		if err := agent.AddRemoteCandidate(candidate); err != nil {
+			s.logger.Warn("Failed to add remote candidate", "peer_id", s.PeerID, "candidate", candidate.String(), "error", err)
+		}
+	}
+	return nil
+}
+
+// connectICE выполняет connectivity checks; controlling сторона определяется по peer ID
+func (s *PeerSession) connectICE(controlling bool) error {
+	if !s.setState(SessionStateChecking) {
+		return fmt.Errorf("session closed")
+	}
+
+	s.mu.RLock()
+	agent, ufrag, pwd := s.iceAgent, s.remoteUfrag, s.remotePwd
+	s.mu.RUnlock()
+	if agent == nil {
+		return fmt.Errorf("session closed")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, peerConnectTimeout)
+	defer cancel()
+
+	conn, err := agent.Connect(ctx, controlling, ufrag, pwd)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.iceConn = conn
+	s.mu.Unlock()
+
+	s.logger.Info("ICE connection established",
+		"peer_id", s.PeerID,
+		"session_id", s.SessionID,
+		"controlling", controlling,
+		"remote_addr", conn.RemoteAddr().String())
+	return nil
+}
+
+// addPushedSignal сохраняет credentials и кандидат, доставленные push сигналингом.
+// Если удаленные данные уже переданы агенту, кандидат добавляется сразу (trickle).
+func (s *PeerSession) addPushedSignal(ufrag, pwd string, candidate pionice.Candidate, endOfCandidates bool) {
+	s.mu.Lock()
+	// Кандидаты предыдущей генерации ICE (до restart) отбрасываются
+	if ufrag != "" && s.remoteUfrag != "" && ufrag != s.remoteUfrag {
+		s.mu.Unlock()
+		return
+	}
+	if ufrag != "" && pwd != "" {
+		s.pushedUfrag, s.pushedPwd = ufrag, pwd
+	}
+	var agent *ice.ICEAgent
+	if candidate != nil {
+		s.pushedCandidates = append(s.pushedCandidates, candidate)
+		if s.remoteUfrag != "" {
+			s.remoteCandidates = append(s.remoteCandidates, candidate)
+			agent = s.iceAgent
+		}
+	}
+	if endOfCandidates {
+		s.remoteGathered = true
+	}
+	s.mu.Unlock()
+
+	if agent != nil {
+		if err := agent.AddRemoteCandidate(candidate); err != nil {
+			s.logger.Warn("Failed to add remote candidate", "peer_id", s.PeerID, "candidate", candidate.String(), "error", err)
+		}
+	}
+
+	select {
+	case s.remoteSignal <- struct{}{}:
+	default:
+	}
+}
+
+// pushedRemote возвращает данные пира из push сигналинга; ready означает,
+// что известны credentials и пир закончил сбор кандидатов
+func (s *PeerSession) pushedRemote() (ufrag, pwd string, candidates []pionice.Candidate, ready bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	candidates = append([]pionice.Candidate(nil), s.pushedCandidates...)
+	ready = s.pushedUfrag != "" && s.pushedPwd != "" && s.remoteGathered && len(candidates) > 0
+	return s.pushedUfrag, s.pushedPwd, candidates, ready
+}
+
+// handleLocalCandidate передает кандидаты, собранные после restart, в trickle callback
+func (s *PeerSession) handleLocalCandidate(candidate pionice.Candidate) {
+	s.mu.Lock()
+	trickle := s.trickle
+	if trickle != nil {
+		if candidate != nil {
+			s.localCandidates = append(s.localCandidates, candidate)
+		} else {
+			s.trickle = nil
+		}
+	}
+	s.mu.Unlock()
+
+	if trickle != nil {
+		trickle(candidate)
+	}
+}
+
+// handleICEState передает изменения состояния ICE менеджеру
+func (s *PeerSession) handleICEState(state pionice.ConnectionState) {
+	if s.onICEState != nil {
+		s.onICEState(state)
+	}
+}
+
+// restartICE перезапускает ICE агент сессии с новыми локальными credentials:
+// announce сообщает их пиру, после чего кандидаты новой генерации уходят в trickle
+func (s *PeerSession) restartICE(announce func(ufrag, pwd string) error, trickle func(pionice.Candidate)) error {
+	s.mu.Lock()
+	agent := s.iceAgent
+	if agent == nil || s.state != SessionStateConnected {
+		s.mu.Unlock()
+		return fmt.Errorf("session has no active ICE connection")
+	}
+	if s.restarting {
+		s.mu.Unlock()
+		return errRestartInProgress
+	}
+	s.restarting = true
+	s.recovering = true
+	s.mu.Unlock()
+
+	ufrag, pwd, err := agent.Restart()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.localUfrag, s.localPwd = ufrag, pwd
+	s.localCandidates = nil
+	s.remoteUfrag, s.remotePwd, s.remoteCandidates = "", "", nil
+	s.pushedUfrag, s.pushedPwd, s.pushedCandidates, s.remoteGathered = "", "", nil, false
+	s.trickle = trickle
+	s.iceRestarts++
+	s.mu.Unlock()
+
+	if err := announce(ufrag, pwd); err != nil {
+		return err
+	}
+	return agent.StartGathering()
+}
+
+// applyRemoteRestart задает credentials пира новой генерации ICE
+func (s *PeerSession) applyRemoteRestart(ufrag, pwd string) error {
+	s.mu.Lock()
+	agent := s.iceAgent
+	s.remoteUfrag, s.remotePwd = ufrag, pwd
+	s.restarting = false
+	s.mu.Unlock()
+
+	if agent == nil {
+		return fmt.Errorf("session closed")
+	}
+	return agent.StartConnectivityChecks(ufrag, pwd)
+}
+
+// restartStatus сообщает, ждет ли сессия credentials пира и идет ли восстановление
+func (s *PeerSession) restartStatus() (restarting, recovering bool, remoteUfrag string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.restarting, s.recovering, s.remoteUfrag
+}
+
+// finishRecovery завершает restart; возвращает true, если ICE снова connected
+func (s *PeerSession) finishRecovery() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restarting || s.iceAgent == nil || s.iceAgent.GetConnectionState() != pionice.ConnectionStateConnected {
+		return false
+	}
+	s.recovering = false
+	return true
+}
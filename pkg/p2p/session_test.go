@@ -0,0 +1,157 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"github.com/2gc-dev/cloudbridge-client/pkg/signaling"
+)
+
+func newSessionTestManager(maxConnections int) *Manager {
+	return NewManager(&P2PConfig{
+		ConnectionType: ConnectionTypeP2PMesh,
+		MaxConnections: maxConnections,
+	}, NewSimpleLogger("test"))
+}
+
+func TestManager_SessionsAreIndependent(t *testing.T) {
+	m := newSessionTestManager(10)
+
+	a, err := m.newSession("peer-a", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	b, err := m.newSession("peer-b", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if a.SessionID == b.SessionID {
+		t.Error("Expected unique session IDs per peer")
+	}
+	if a.localUfrag == b.localUfrag || a.localPwd == b.localPwd {
+		t.Error("Expected unique ICE credentials per session")
+	}
+
+	if _, err := m.newSession("peer-a", "", false); !errors.Is(err, ErrSessionInProgress) {
+		t.Errorf("Expected ErrSessionInProgress for second attempt, got %v", err)
+	}
+}
+
+func TestManager_SessionLimit(t *testing.T) {
+	m := newSessionTestManager(2)
+
+	first, err := m.newSession("peer-a", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := m.newSession("peer-b", "", false); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := m.newSession("peer-c", "", false); !errors.Is(err, ErrMaxConnections) {
+		t.Fatalf("Expected ErrMaxConnections, got %v", err)
+	}
+
+	// Завершенная сессия освобождает слот и может быть заменена новой попыткой
+	_ = m.failSession(first, errors.New("ice timeout")) //nolint:errcheck // returns the passed error
+	if first.State() != SessionStateFailed {
+		t.Errorf("Expected failed state, got %s", first.State())
+	}
+	if _, err := m.newSession("peer-c", "", false); err != nil {
+		t.Errorf("Expected slot to be released, got %v", err)
+	}
+	if _, err := m.newSession("peer-a", "", false); !errors.Is(err, ErrMaxConnections) {
+		t.Errorf("Expected ErrMaxConnections after slot reuse, got %v", err)
+	}
+}
+
+func TestManager_AcceptSessionTieBreak(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID = "peer-b"
+
+	// Исходящая сессия к пиру с большим ID сохраняется
+	if _, err := m.newSession("peer-c", "", false); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := m.acceptSession(&api.P2PIncomingRequest{SessionID: "s1", FromPeerID: "peer-c"}); !errors.Is(err, ErrSessionInProgress) {
+		t.Errorf("Expected inbound request to be declined, got %v", err)
+	}
+
+	// Исходящая сессия к пиру с меньшим ID уступает входящей
+	outbound, err := m.newSession("peer-a", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	inbound, err := m.acceptSession(&api.P2PIncomingRequest{SessionID: "s2", FromPeerID: "peer-a"})
+	if err != nil {
+		t.Fatalf("Expected inbound session to replace outbound one, got %v", err)
+	}
+	if outbound.State() != SessionStateClosed {
+		t.Errorf("Expected outbound session to be closed, got %s", outbound.State())
+	}
+	if !inbound.Inbound || inbound.SessionID != "s2" {
+		t.Errorf("Expected inbound session with initiator's ID, got inbound=%v id=%s", inbound.Inbound, inbound.SessionID)
+	}
+}
+
+func TestManager_PushedSignalReachesSession(t *testing.T) {
+	m := newSessionTestManager(10)
+	session, err := m.newSession("peer-b", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	candidate := &api.ICECandidate{Foundation: "1", Component: 1, Transport: "udp", Priority: 100, Address: "10.0.0.2", Port: 5000, Type: "host"}
+	m.handleSignalingEvent(&signaling.Event{Type: signaling.EventCandidate, SessionID: session.SessionID, FromPeerID: "peer-b", Ufrag: "uf", Pwd: "pw", Candidate: candidate})
+	// Событие чужой сессии игнорируется
+	m.handleSignalingEvent(&signaling.Event{Type: signaling.EventEndOfCandidates, SessionID: "other", FromPeerID: "peer-b"})
+
+	if _, _, _, ready := session.pushedRemote(); ready {
+		t.Fatal("Expected session to wait for end of candidates")
+	}
+
+	m.handleSignalingEvent(&signaling.Event{Type: signaling.EventEndOfCandidates, SessionID: session.SessionID, FromPeerID: "peer-b"})
+	ufrag, pwd, candidates, ready := session.pushedRemote()
+	if !ready || ufrag != "uf" || pwd != "pw" || len(candidates) != 1 {
+		t.Errorf("Expected pushed signal to be ready, got ready=%v ufrag=%s candidates=%d", ready, ufrag, len(candidates))
+	}
+}
+
+func TestManager_RestartWithoutPushRecreatesSession(t *testing.T) {
+	m := newSessionTestManager(10)
+	session, err := m.newSession("peer-b", "s1", true)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.mu.Lock()
+	session.state = SessionStateConnected
+	session.path = PathDirect
+	session.mu.Unlock()
+
+	// Без push канала restart невозможен: входящая сессия закрывается,
+	// новую попытку начнет инициатор
+	if err := m.RestartICE("peer-b"); err != nil {
+		t.Fatalf("RestartICE failed: %v", err)
+	}
+	if session.State() != SessionStateClosed {
+		t.Errorf("Expected session to be closed, got %s", session.State())
+	}
+	if _, ok := m.GetPeerSession("peer-b"); ok {
+		t.Error("Expected session to be removed")
+	}
+	if err := m.RestartICE("peer-b"); err == nil {
+		t.Error("Expected error for peer without session")
+	}
+}
+
+func TestManager_ICEServersUseStaticTURNCredentials(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.SetICEServers([]string{"stun.example.com:3478"}, []string{"turn:relay.example.com:3478"})
+	m.SetTURNCredentials("cloudbridge", "secret")
+
+	servers := m.iceServers()
+	if len(servers.turn) != 1 || servers.turnUsername != "cloudbridge" || servers.turnPassword != "secret" {
+		t.Errorf("Expected configured TURN server with static credentials, got %+v", servers)
+	}
+}
@@ -0,0 +1,426 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"github.com/2gc-dev/cloudbridge-client/pkg/quic"
+	"github.com/2gc-dev/cloudbridge-client/pkg/signaling"
+	pionice "github.com/pion/ice/v2"
+	quicgo "github.com/quic-go/quic-go"
+)
+
+// defaultSignalingInterval период опроса, если SignalingInterval не задан
+const defaultSignalingInterval = 2 * time.Second
+
+// handledRequestTTL сколько помнить обработанные входящие запросы (защита от повторов при опросе)
+const handledRequestTTL = 5 * time.Minute
+
+// incomingRequests отслеживает уже обработанные входящие запросы по session ID
+type incomingRequests struct {
+	mu      sync.Mutex
+	handled map[string]time.Time
+}
+
+// markNew отмечает запрос обработанным; возвращает false для повторов
+func (r *incomingRequests) markNew(sessionID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, at := range r.handled {
+		if now.Sub(at) > handledRequestTTL {
+			delete(r.handled, id)
+		}
+	}
+	if _, ok := r.handled[sessionID]; ok {
+		return false
+	}
+	r.handled[sessionID] = now
+	return true
+}
+
+// startSignalingListener опрашивает relay API на предмет входящих запросов соединения
+func (m *Manager) startSignalingListener() {
+	interval := m.config.SignalingInterval
+	if interval <= 0 {
+		interval = defaultSignalingInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	m.logger.Info("P2P signaling listener started", "interval", interval)
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.pollIncomingRequests()
+		}
+	}
+}
+
+// pollIncomingRequests получает ожидающие запросы и обрабатывает новые
+func (m *Manager) pollIncomingRequests() {
+	resp, err := m.apiManager.GetIncomingP2PRequests()
+	if err != nil {
+		m.logger.Debug("Failed to poll incoming P2P requests", "error", err)
+		return
+	}
+
+	for _, req := range resp.Requests {
+		if req == nil || req.SessionID == "" || req.FromPeerID == "" {
+			continue
+		}
+		if !m.incoming.markNew(req.SessionID) {
+			continue
+		}
+		go m.HandleIncomingRequest(req)
+	}
+}
+
+// HandleIncomingRequest отвечает на запрос соединения, инициированный другим пиром:
+// публикует свои credentials и кандидаты, проходит ICE и принимает QUIC на ICE сокете
+func (m *Manager) HandleIncomingRequest(req *api.P2PIncomingRequest) {
+	m.logger.Info("Incoming P2P connection request",
+		"from_peer_id", req.FromPeerID,
+		"session_id", req.SessionID,
+		"protocol", req.Protocol,
+		"upgrade", req.Upgrade)
+
+	if err := m.checkInboundACL(req.FromPeerID); err != nil {
+		m.logger.Warn("Declining P2P connection request", "from_peer_id", req.FromPeerID, "error", err)
+		m.answerIncoming(req, false, err.Error())
+		return
+	}
+
+	// Перевод на прямой путь относится к уже допущенному пиру
+	if !req.Upgrade {
+		if err := m.admitIncoming(req); errors.Is(err, ErrPeerPendingApproval) {
+			// Ответ будет отправлен после ApprovePeer/RejectPeer
+			m.logger.Info("P2P connection request awaits approval", "from_peer_id", req.FromPeerID)
+			return
+		} else if err != nil {
+			m.logger.Warn("Declining P2P connection request", "from_peer_id", req.FromPeerID, "error", err)
+			m.answerIncoming(req, false, err.Error())
+			return
+		}
+	}
+
+	session, err := m.acceptSession(req)
+	if err != nil {
+		m.logger.Warn("Declining P2P connection request", "from_peer_id", req.FromPeerID, "error", err)
+		m.answerIncoming(req, false, err.Error())
+		return
+	}
+
+	if err := session.gather(m.iceServers()); err != nil {
+		m.answerIncoming(req, false, "candidate gathering failed")
+		_ = m.failSession(session, err) //nolint:errcheck // error is logged by the session
+		return
+	}
+
+	// Ответ отправляется после публикации credentials и кандидатов,
+	// чтобы инициатор сразу мог их получить
+	if err := m.signalSession(session); err != nil {
+		m.answerIncoming(req, false, "signaling failed")
+		_ = m.failSession(session, err) //nolint:errcheck // error is logged by the session
+		return
+	}
+	m.answerIncoming(req, true, "")
+
+	m.mu.RLock()
+	controlling := m.peerID < req.FromPeerID
+	m.mu.RUnlock()
+	if err := session.connectICE(controlling); err != nil {
+		_ = m.fallbackInbound(session, fmt.Errorf("failed to establish connection: %w", err)) //nolint:errcheck // logged
+		return
+	}
+
+	if err := m.acceptQUICConnection(session); err != nil {
+		_ = m.failSession(session, err) //nolint:errcheck // error is logged by the session
+		return
+	}
+
+	m.logger.Info("Inbound P2P connection established", "peer_id", session.PeerID, "session_id", session.SessionID)
+}
+
+// acceptSession создает входящую сессию. При встречных попытках соединения
+// сохраняется исходящая сессия пира с меньшим ID (controlling сторона).
+// Запрос перевода на прямой путь создает пробную сессию рядом с текущей.
+func (m *Manager) acceptSession(req *api.P2PIncomingRequest) (*PeerSession, error) {
+	m.mu.Lock()
+	existing, ok := m.sessions[req.FromPeerID]
+	localPeerID := m.peerID
+	m.mu.Unlock()
+
+	if req.Upgrade {
+		if !ok || existing.State() != SessionStateConnected {
+			return nil, fmt.Errorf("no connected session with peer %s to upgrade", req.FromPeerID)
+		}
+		return m.newProbeSession(req.FromPeerID, req.SessionID, true)
+	}
+
+	if ok && !existing.State().IsTerminal() {
+		if existing.SessionID == req.SessionID {
+			return nil, fmt.Errorf("%w: %s", ErrSessionInProgress, req.FromPeerID)
+		}
+		if localPeerID < req.FromPeerID {
+			return nil, fmt.Errorf("%w: outbound session wins", ErrSessionInProgress)
+		}
+		m.logger.Info("Replacing outbound P2P session with inbound one",
+			"peer_id", req.FromPeerID,
+			"outbound_session_id", existing.SessionID,
+			"inbound_session_id", req.SessionID)
+		if err := m.ClosePeerSession(req.FromPeerID); err != nil {
+			m.logger.Debug("Failed to close outbound session", "peer_id", req.FromPeerID, "error", err)
+		}
+	}
+
+	return m.newSession(req.FromPeerID, req.SessionID, true)
+}
+
+// answerIncoming сообщает инициатору о принятии или отклонении запроса
+func (m *Manager) answerIncoming(req *api.P2PIncomingRequest, accepted bool, reason string) {
+	if err := m.apiManager.AnswerP2PConnection(&api.P2PConnectionAnswer{
+		SessionID:    req.SessionID,
+		TargetPeerID: req.FromPeerID,
+		Accepted:     accepted,
+		Reason:       reason,
+	}); err != nil {
+		m.logger.Warn("Failed to answer P2P connection request", "from_peer_id", req.FromPeerID, "error", err)
+	}
+}
+
+// acceptQUICConnection принимает QUIC соединение пира на сокете выбранной ICE пары
+func (m *Manager) acceptQUICConnection(session *PeerSession) error {
+	session.mu.RLock()
+	iceConn := session.iceConn
+	session.mu.RUnlock()
+	if iceConn == nil {
+		return fmt.Errorf("session closed")
+	}
+
+	quicConn, stream, err := m.acceptPeerQUIC(session, newICEPacketConn(iceConn))
+	if err != nil {
+		return err
+	}
+	return m.registerConnection(session, quicConn, true, stream, session.icePath())
+}
+
+// acceptPeerQUIC принимает QUIC соединение пира на pconn и проверяет приветствие сессии
+func (m *Manager) acceptPeerQUIC(session *PeerSession, pconn net.PacketConn) (*quic.QUICConnection, *quicgo.Stream, error) {
+	m.mu.RLock()
+	cert := m.tlsCert
+	m.mu.RUnlock()
+
+	quicConn := quic.NewQUICConnection(m.logger)
+	quicConn.SetServerTLSCert(cert)
+	quicConn.SetALPN(PeerALPN)
+	quicConn.EnableDatagrams()
+	m.pinPeerTLS(quicConn, session.PeerID, false)
+
+	ctx, cancel := context.WithTimeout(session.ctx, peerConnectTimeout)
+	defer cancel()
+
+	if err := quicConn.AcceptOn(ctx, pconn); err != nil {
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed accept
+		return nil, nil, fmt.Errorf("failed to accept peer QUIC connection: %w", err)
+	}
+
+	stream, err := quicConn.AcceptStream(ctx)
+	if err != nil {
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed accept
+		return nil, nil, fmt.Errorf("failed to accept peer stream: %w", err)
+	}
+
+	sessionID, err := readPeerHello(stream)
+	if err == nil && sessionID != session.SessionID {
+		err = fmt.Errorf("peer hello for unexpected session %q", sessionID)
+	}
+	// Политика могла смениться после запроса соединения
+	if err == nil {
+		err = m.checkInboundACL(session.PeerID)
+	}
+	if err != nil {
+		_ = quicConn.Close() //nolint:errcheck // cleanup after failed accept
+		return nil, nil, err
+	}
+	return quicConn, stream, nil
+}
+
+// startPushSignaling подключает постоянный канал сигналинга, если задан endpoint.
+// Вызывается из Start под m.mu.
+func (m *Manager) startPushSignaling() {
+	if m.signalingURL == "" {
+		m.logger.Info("Push signaling endpoint not configured, using relay API polling")
+		return
+	}
+
+	client := signaling.NewClient(&signaling.Config{
+		Endpoint:           m.signalingURL,
+		Token:              m.token,
+		TenantID:           m.tenantID,
+		PeerID:             m.peerID,
+		InsecureSkipVerify: m.signalingTLS,
+	}, m.handleSignalingEvent, m.logger)
+	if err := client.Start(); err != nil {
+		m.logger.Warn("Failed to start push signaling, using relay API polling", "error", err)
+		return
+	}
+	m.signaling = client
+}
+
+// pushConnected сообщает, доставляются ли события через push канал
+func (m *Manager) pushConnected() bool {
+	m.mu.RLock()
+	client := m.signaling
+	m.mu.RUnlock()
+	return client != nil && client.IsConnected()
+}
+
+// sendSignal отправляет событие пиру через push канал, если он подключен
+func (m *Manager) sendSignal(event *signaling.Event) bool {
+	m.mu.RLock()
+	client := m.signaling
+	m.mu.RUnlock()
+	if client == nil || !client.IsConnected() {
+		return false
+	}
+	if err := client.Send(event); err != nil {
+		m.logger.Debug("Failed to push signaling event", "type", event.Type, "to_peer_id", event.ToPeerID, "error", err)
+		return false
+	}
+	return true
+}
+
+// pushSessionSignal дублирует в push канал запрос соединения, credentials и кандидаты сессии.
+// Relay API остается источником данных для пиров без push канала.
+func (m *Manager) pushSessionSignal(session *PeerSession) {
+	session.mu.RLock()
+	ufrag, pwd := session.localUfrag, session.localPwd
+	session.mu.RUnlock()
+	candidates := m.advertisedCandidates(session)
+
+	if !session.Inbound {
+		if !m.sendSignal(&signaling.Event{
+			Type:      signaling.EventConnectionRequest,
+			SessionID: session.SessionID,
+			ToPeerID:  session.PeerID,
+			Protocol:  PeerALPN,
+			Upgrade:   session.upgrade,
+		}) {
+			return
+		}
+	}
+
+	for _, candidate := range candidates {
+		if !m.sendSignal(&signaling.Event{
+			Type:      signaling.EventCandidate,
+			SessionID: session.SessionID,
+			ToPeerID:  session.PeerID,
+			Ufrag:     ufrag,
+			Pwd:       pwd,
+			Candidate: candidateToAPI(candidate),
+		}) {
+			return
+		}
+	}
+	m.sendSignal(&signaling.Event{
+		Type:      signaling.EventEndOfCandidates,
+		SessionID: session.SessionID,
+		ToPeerID:  session.PeerID,
+		Ufrag:     ufrag,
+		Pwd:       pwd,
+	})
+}
+
+// handleSignalingEvent обрабатывает событие push канала
+func (m *Manager) handleSignalingEvent(event *signaling.Event) {
+	switch event.Type {
+	case signaling.EventPeerJoin:
+		if event.Peer == nil {
+			return
+		}
+		peer := m.trustedPeerFromAPI(event.Peer)
+		m.mu.RLock()
+		mesh := m.mesh
+		m.mu.RUnlock()
+		if mesh != nil {
+			mesh.UpsertPeer(peer)
+		}
+
+	case signaling.EventPeerLeave:
+		m.mu.RLock()
+		mesh := m.mesh
+		m.mu.RUnlock()
+		if mesh != nil {
+			if err := mesh.RemovePeer(event.FromPeerID); err != nil {
+				m.logger.Debug("Failed to remove peer from mesh", "peer_id", event.FromPeerID, "error", err)
+			}
+		}
+		if err := m.ClosePeerSession(event.FromPeerID); err != nil {
+			m.logger.Debug("Failed to close session of departed peer", "peer_id", event.FromPeerID, "error", err)
+		}
+
+	case signaling.EventConnectionRequest:
+		if event.SessionID == "" || event.FromPeerID == "" || !m.incoming.markNew(event.SessionID) {
+			return
+		}
+		go m.HandleIncomingRequest(&api.P2PIncomingRequest{
+			SessionID:  event.SessionID,
+			FromPeerID: event.FromPeerID,
+			Protocol:   event.Protocol,
+			Upgrade:    event.Upgrade,
+			CreatedAt:  time.UnixMilli(event.Timestamp).Unix(),
+		})
+
+	case signaling.EventCandidate, signaling.EventEndOfCandidates:
+		session := m.sessionFor(event)
+		if session == nil {
+			return
+		}
+		var candidate pionice.Candidate
+		if event.Candidate != nil {
+			var err error
+			if candidate, err = candidateFromAPI(event.Candidate); err != nil {
+				m.logger.Warn("Failed to parse pushed candidate", "peer_id", event.FromPeerID, "error", err)
+				return
+			}
+		}
+		session.addPushedSignal(event.Ufrag, event.Pwd, candidate, event.Type == signaling.EventEndOfCandidates)
+
+	case signaling.EventICERestart:
+		if session := m.sessionFor(event); session != nil {
+			m.handleRemoteRestart(session, event)
+		}
+
+	default:
+		m.logger.Debug("Ignoring unknown signaling event", "type", event.Type)
+	}
+}
+
+// sessionFor возвращает активную (или пробную) сессию, к которой относится событие
+func (m *Manager) sessionFor(event *signaling.Event) *PeerSession {
+	m.mu.RLock()
+	probe, probing := m.probes[event.FromPeerID]
+	m.mu.RUnlock()
+	if probing && event.SessionID == probe.SessionID && !probe.State().IsTerminal() {
+		return probe
+	}
+
+	session, ok := m.GetPeerSession(event.FromPeerID)
+	if !ok || session.State().IsTerminal() {
+		return nil
+	}
+	if event.SessionID != "" && event.SessionID != session.SessionID {
+		return nil
+	}
+	return session
+}
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"time"
+
+	pionice "github.com/pion/ice/v2"
+)
+
+// turnCredentialsRefreshMargin запас до истечения, после которого credentials запрашиваются заново
+const turnCredentialsRefreshMargin = time.Minute
+
+// turnCredentials учетные данные TURN; expiresAt пустой у статических credentials
+type turnCredentials struct {
+	username  string
+	password  string
+	uris      []string // серверы, для которых выданы credentials (REST API)
+	expiresAt time.Time
+}
+
+// valid сообщает, что credentials заданы и не истекают в ближайшее время
+func (c *turnCredentials) valid(now time.Time) bool {
+	if c == nil || c.username == "" || c.password == "" {
+		return false
+	}
+	return c.expiresAt.IsZero() || now.Add(turnCredentialsRefreshMargin).Before(c.expiresAt)
+}
+
+// iceServerSet STUN/TURN серверы, TURN credentials и общий UDP сокет для ICE агента сессии
+type iceServerSet struct {
+	stun         []string
+	turn         []string
+	turnUsername string
+	turnPassword string
+	udpMux       pionice.UniversalUDPMux // nil — агент открывает свои сокеты
+}
+
+// iceServers собирает серверы для новой сессии. Краткоживущие credentials relay API
+// (TURN REST API) имеют приоритет над статическими из конфигурации.
+func (m *Manager) iceServers() iceServerSet {
+	m.mu.RLock()
+	stunServers, turnServers := m.iceServersLocked()
+	static := m.turnStatic
+	udpMux := m.udpMux
+	m.mu.RUnlock()
+
+	servers := iceServerSet{stun: stunServers, turn: turnServers, udpMux: udpMux}
+	if creds := m.restTURNCredentials(); creds != nil {
+		servers.turnUsername, servers.turnPassword = creds.username, creds.password
+		if len(creds.uris) > 0 {
+			servers.turn = creds.uris
+		}
+		return servers
+	}
+	if static.valid(time.Now()) {
+		servers.turnUsername, servers.turnPassword = static.username, static.password
+	}
+	return servers
+}
+
+// restTURNCredentials возвращает кэшированные credentials relay API, обновляя их при истечении
+func (m *Manager) restTURNCredentials() *turnCredentials {
+	if m.apiManager == nil {
+		return nil
+	}
+
+	m.turnMu.Lock()
+	defer m.turnMu.Unlock()
+
+	now := time.Now()
+	m.mu.RLock()
+	cached := m.turnREST
+	m.mu.RUnlock()
+	if cached.valid(now) {
+		return cached
+	}
+
+	resp, err := m.apiManager.GetTURNCredentials()
+	if err != nil {
+		m.logger.Debug("TURN credentials not available from relay API, using configured ones", "error", err)
+		return nil
+	}
+
+	creds := &turnCredentials{
+		username: resp.Username,
+		password: resp.Password,
+		uris:     resp.URIs,
+	}
+	if resp.TTL > 0 {
+		creds.expiresAt = now.Add(time.Duration(resp.TTL) * time.Second)
+	}
+
+	m.mu.Lock()
+	m.turnREST = creds
+	m.mu.Unlock()
+
+	m.logger.Debug("TURN credentials received from relay API", "ttl", resp.TTL, "uris", len(resp.URIs))
+	return creds
+}
+
+// SetTURNCredentials задает статические TURN credentials (используются, если relay API их не выдает)
+func (m *Manager) SetTURNCredentials(username, password string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turnStatic = turnCredentials{username: username, password: password}
+}
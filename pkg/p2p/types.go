@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"time"
@@ -61,15 +62,19 @@ type NetworkConfig struct {
 
 // P2PConfig represents complete P2P configuration
 type P2PConfig struct {
-	ConnectionType    ConnectionType `json:"connection_type"`
-	QUICConfig        *QUICConfig    `json:"quic_config,omitempty"`
-	MeshConfig        *MeshConfig    `json:"mesh_config,omitempty"`
-	PeerWhitelist     *PeerWhitelist `json:"peer_whitelist,omitempty"`
-	NetworkConfig     *NetworkConfig `json:"network_config,omitempty"`
-	TenantID          string         `json:"tenant_id,omitempty"`
-	Permissions       []string       `json:"permissions,omitempty"`
-	HeartbeatInterval time.Duration  `json:"heartbeat_interval,omitempty"`
-	HeartbeatTimeout  time.Duration  `json:"heartbeat_timeout,omitempty"`
+	ConnectionType    ConnectionType  `json:"connection_type"`
+	QUICConfig        *QUICConfig     `json:"quic_config,omitempty"`
+	MeshConfig        *MeshConfig     `json:"mesh_config,omitempty"`
+	PeerWhitelist     *PeerWhitelist  `json:"peer_whitelist,omitempty"`
+	ACLPolicy         json.RawMessage `json:"acl_policy,omitempty"` // документ политики доступа mesh из JWT
+	NetworkConfig     *NetworkConfig  `json:"network_config,omitempty"`
+	TenantID          string          `json:"tenant_id,omitempty"`
+	Permissions       []string        `json:"permissions,omitempty"`
+	HeartbeatInterval time.Duration   `json:"heartbeat_interval,omitempty"`
+	HeartbeatTimeout  time.Duration   `json:"heartbeat_timeout,omitempty"`
+	MaxConnections    int             `json:"max_connections,omitempty"`    // лимит одновременных ICE сессий с пирами
+	SignalingInterval time.Duration   `json:"signaling_interval,omitempty"` // период опроса входящих запросов соединения
+	RelayFallback     bool            `json:"relay_fallback,omitempty"`     // переход на relay, если прямое ICE соединение не удалось
 }
 
 // Peer represents a discovered peer in the mesh network
@@ -83,7 +88,20 @@ type Peer struct {
 	Persistent  bool     `json:"persistent"`
 	LastSeen    int64    `json:"last_seen"`
 	Latency     int64    `json:"latency_ms"`
+	Jitter      int64    `json:"jitter_ms,omitempty"`
+	Loss        float64  `json:"loss,omitempty"`
 	IsConnected bool     `json:"is_connected"`
+	// Путь локального соединения с пиром; пустой — путь еще не известен (считается P2P)
+	Path PathType `json:"path,omitempty"`
+	// Соединения пира с другими пирами — участки многохоповых маршрутов
+	Links []PeerLink `json:"links,omitempty"`
+}
+
+// PeerLink соединение пира с другим пиром, анонсированное в mesh
+type PeerLink struct {
+	PeerID  string   `json:"peer_id"`
+	Latency int64    `json:"latency_ms"`
+	Path    PathType `json:"path,omitempty"`
 }
 
 // MeshTopology represents the current mesh network topology
@@ -110,6 +128,8 @@ type P2PStatus struct {
 	PeerIP         string `json:"peer_ip,omitempty"`
 	TenantCIDR     string `json:"tenant_cidr,omitempty"`
 	WireGuardReady bool   `json:"wireguard_ready"`
+	// Текущий путь трафика к каждому подключенному пиру (direct/turn/derp/relay)
+	PeerPaths map[string]PathType `json:"peer_paths,omitempty"`
 }
 
 // P2PMessage represents a P2P protocol message
@@ -201,6 +221,12 @@ func (c *P2PConfig) FillDefaults() {
 	if c.HeartbeatTimeout <= 0 {
 		c.HeartbeatTimeout = 10 * time.Second
 	}
+	if c.MaxConnections <= 0 {
+		c.MaxConnections = 1000
+	}
+	if c.SignalingInterval <= 0 {
+		c.SignalingInterval = defaultSignalingInterval
+	}
 
 	if c.MeshConfig != nil {
 		c.MeshConfig.FillDefaults()
@@ -226,6 +252,21 @@ func (m *MeshConfig) FillDefaults() {
 	if d, ok := ParseFlexibleDuration(m.HeartbeatInterval); ok {
 		m.HeartbeatInterval = d
 	}
+	if m.TopologyInterval <= 0 {
+		m.TopologyInterval = 30 * time.Second
+	}
+	if m.RoutingInterval <= 0 {
+		m.RoutingInterval = 10 * time.Second
+	}
+	if m.HealthInterval <= 0 {
+		m.HealthInterval = 30 * time.Second
+	}
+	if m.PeerStaleAfter <= 0 {
+		m.PeerStaleAfter = 90 * time.Second
+	}
+	if m.PeerDeadAfter <= 0 {
+		m.PeerDeadAfter = 5 * time.Minute
+	}
 }
 
 // FillDefaults нормализует NetworkConfig
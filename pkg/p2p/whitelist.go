@@ -0,0 +1,190 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+)
+
+// maxPendingPeers ограничивает очередь пиров, ожидающих подтверждения
+const maxPendingPeers = 64
+
+// Ошибки проверки пиров по белому списку JWT
+var (
+	ErrPeerNotAllowed      = errors.New("peer is not in the whitelist")
+	ErrPeerRejected        = errors.New("peer was rejected")
+	ErrPeerPendingApproval = errors.New("peer is pending approval")
+	ErrMaxPeers            = errors.New("maximum number of peers reached")
+)
+
+// Allows сообщает, входит ли пир в белый список; пустой список разрешает любых пиров
+func (w *PeerWhitelist) Allows(peerID string) bool {
+	if w == nil || len(w.AllowedPeers) == 0 {
+		return true
+	}
+	return slices.Contains(w.AllowedPeers, peerID) || slices.Contains(w.AllowedPeers, "*")
+}
+
+// maxPeers возвращает лимит пиров (0 — без ограничения)
+func (w *PeerWhitelist) maxPeers() int {
+	if w == nil || w.MaxPeers < 0 {
+		return 0
+	}
+	return w.MaxPeers
+}
+
+// PendingPeer входящий запрос соединения от пира, ожидающего подтверждения
+type PendingPeer struct {
+	PeerID      string    `json:"peer_id"`
+	SessionID   string    `json:"session_id"`
+	RequestedAt time.Time `json:"requested_at"`
+
+	request *api.P2PIncomingRequest
+}
+
+// peerApprovals решения по пирам при AutoApprove=false
+type peerApprovals struct {
+	mu       sync.Mutex
+	pending  map[string]*PendingPeer
+	approved map[string]bool
+	rejected map[string]bool
+}
+
+func newPeerApprovals() *peerApprovals {
+	return &peerApprovals{
+		pending:  make(map[string]*PendingPeer),
+		approved: make(map[string]bool),
+		rejected: make(map[string]bool),
+	}
+}
+
+// checkPeerLocked проверяет белый список и отклоненных пиров перед созданием сессии; вызывается под m.mu
+func (m *Manager) checkPeerLocked(peerID string) error {
+	if !m.config.PeerWhitelist.Allows(peerID) {
+		return fmt.Errorf("%w: %s", ErrPeerNotAllowed, peerID)
+	}
+	m.approvals.mu.Lock()
+	rejected := m.approvals.rejected[peerID]
+	m.approvals.mu.Unlock()
+	if rejected {
+		return fmt.Errorf("%w: %s", ErrPeerRejected, peerID)
+	}
+
+	limit := m.config.PeerWhitelist.maxPeers()
+	if limit == 0 {
+		return nil
+	}
+	peers := 0
+	for id, session := range m.sessions {
+		if id != peerID && !session.State().IsTerminal() {
+			peers++
+		}
+	}
+	if peers >= limit {
+		return fmt.Errorf("%w (%d)", ErrMaxPeers, limit)
+	}
+	return nil
+}
+
+// admitIncoming проверяет входящий запрос соединения. Без AutoApprove запрос
+// неподтвержденного пира ставится в очередь и ждет ApprovePeer/RejectPeer.
+func (m *Manager) admitIncoming(req *api.P2PIncomingRequest) error {
+	m.mu.RLock()
+	err := m.checkPeerLocked(req.FromPeerID)
+	whitelist := m.config.PeerWhitelist
+	m.mu.RUnlock()
+	if err != nil || whitelist == nil || whitelist.AutoApprove {
+		return err
+	}
+
+	a := m.approvals
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.approved[req.FromPeerID] {
+		return nil
+	}
+	if _, ok := a.pending[req.FromPeerID]; !ok && len(a.pending) >= maxPendingPeers {
+		return fmt.Errorf("approval queue is full (%d)", maxPendingPeers)
+	}
+	a.pending[req.FromPeerID] = &PendingPeer{
+		PeerID:      req.FromPeerID,
+		SessionID:   req.SessionID,
+		RequestedAt: time.Now(),
+		request:     req,
+	}
+	return fmt.Errorf("%w: %s", ErrPeerPendingApproval, req.FromPeerID)
+}
+
+// PendingPeers возвращает пиров, ожидающих подтверждения, в порядке запросов
+func (m *Manager) PendingPeers() []PendingPeer {
+	m.approvals.mu.Lock()
+	defer m.approvals.mu.Unlock()
+
+	peers := make([]PendingPeer, 0, len(m.approvals.pending))
+	for _, p := range m.approvals.pending {
+		peers = append(peers, *p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].RequestedAt.Before(peers[j].RequestedAt) })
+	return peers
+}
+
+// ApprovePeer подтверждает пира: его запросы соединения принимаются. Если пир ждет
+// в очереди, соединение устанавливается: свежий запрос принимается, иначе пиру
+// отправляется собственный запрос.
+func (m *Manager) ApprovePeer(peerID string) error {
+	m.mu.RLock()
+	allowed := m.config.PeerWhitelist.Allows(peerID)
+	m.mu.RUnlock()
+	if !allowed {
+		return fmt.Errorf("%w: %s", ErrPeerNotAllowed, peerID)
+	}
+
+	a := m.approvals
+	a.mu.Lock()
+	pending := a.pending[peerID]
+	delete(a.pending, peerID)
+	delete(a.rejected, peerID)
+	a.approved[peerID] = true
+	a.mu.Unlock()
+
+	m.logger.Info("Peer approved", "peer_id", peerID)
+	if pending == nil {
+		return nil
+	}
+	if time.Since(pending.RequestedAt) < peerConnectTimeout {
+		go m.HandleIncomingRequest(pending.request)
+		return nil
+	}
+	go func() {
+		if err := m.ConnectToPeer(peerID); err != nil {
+			m.logger.Warn("Failed to connect to approved peer", "peer_id", peerID, "error", err)
+		}
+	}()
+	return nil
+}
+
+// RejectPeer отклоняет пира: ожидающий запрос отклоняется, сессия с пиром закрывается,
+// новые соединения с ним не устанавливаются до ApprovePeer
+func (m *Manager) RejectPeer(peerID string) error {
+	a := m.approvals
+	a.mu.Lock()
+	pending := a.pending[peerID]
+	delete(a.pending, peerID)
+	delete(a.approved, peerID)
+	a.rejected[peerID] = true
+	a.mu.Unlock()
+
+	m.logger.Info("Peer rejected", "peer_id", peerID)
+	if pending != nil && m.apiManager != nil {
+		m.answerIncoming(pending.request, false, ErrPeerRejected.Error())
+	}
+	if err := m.ClosePeerSession(peerID); err != nil {
+		m.logger.Debug("No session to close for rejected peer", "peer_id", peerID, "error", err)
+	}
+	return nil
+}
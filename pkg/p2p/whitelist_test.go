@@ -0,0 +1,82 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+)
+
+func TestManager_WhitelistAndMaxPeers(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.config.PeerWhitelist = &PeerWhitelist{AllowedPeers: []string{"peer-a", "peer-b"}, AutoApprove: true, MaxPeers: 1}
+
+	if _, err := m.newSession("peer-x", "", false); !errors.Is(err, ErrPeerNotAllowed) {
+		t.Errorf("Expected outbound session to non-whitelisted peer to fail, got %v", err)
+	}
+	if err := m.admitIncoming(&api.P2PIncomingRequest{SessionID: "s1", FromPeerID: "peer-x"}); !errors.Is(err, ErrPeerNotAllowed) {
+		t.Errorf("Expected inbound request from non-whitelisted peer to be declined, got %v", err)
+	}
+
+	if _, err := m.newSession("peer-a", "", false); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := m.newSession("peer-b", "", true); !errors.Is(err, ErrMaxPeers) {
+		t.Errorf("Expected peer limit to be enforced, got %v", err)
+	}
+}
+
+func TestManager_PendingApproval(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.config.PeerWhitelist = &PeerWhitelist{AutoApprove: false}
+
+	req := &api.P2PIncomingRequest{SessionID: "s1", FromPeerID: "peer-a"}
+	if err := m.admitIncoming(req); !errors.Is(err, ErrPeerPendingApproval) {
+		t.Fatalf("Expected request to await approval, got %v", err)
+	}
+	if pending := m.PendingPeers(); len(pending) != 1 || pending[0].PeerID != "peer-a" || pending[0].SessionID != "s1" {
+		t.Fatalf("Unexpected pending peers: %+v", pending)
+	}
+
+	// Отклоненный пир не может подключиться ни входящим, ни исходящим соединением
+	if err := m.RejectPeer("peer-a"); err != nil {
+		t.Fatalf("RejectPeer failed: %v", err)
+	}
+	if len(m.PendingPeers()) != 0 {
+		t.Error("Expected rejected peer to leave the queue")
+	}
+	if err := m.admitIncoming(req); !errors.Is(err, ErrPeerRejected) {
+		t.Errorf("Expected rejected peer to be declined, got %v", err)
+	}
+	if _, err := m.newSession("peer-a", "", false); !errors.Is(err, ErrPeerRejected) {
+		t.Errorf("Expected session with rejected peer to fail, got %v", err)
+	}
+
+	if err := m.ApprovePeer("peer-a"); err != nil {
+		t.Fatalf("ApprovePeer failed: %v", err)
+	}
+	if err := m.admitIncoming(req); err != nil {
+		t.Errorf("Expected approved peer to be admitted, got %v", err)
+	}
+}
+
+func TestMesh_EnforcesWhitelist(t *testing.T) {
+	mn := newTestMesh(t, RoutingHybrid)
+	mn.SetPeerWhitelist(&PeerWhitelist{AllowedPeers: []string{"a", "b"}, MaxPeers: 1})
+
+	if err := mn.AddPeer(&Peer{ID: "x", IsConnected: true}); !errors.Is(err, ErrPeerNotAllowed) {
+		t.Errorf("Expected non-whitelisted peer to be rejected, got %v", err)
+	}
+	mn.UpsertPeer(&Peer{ID: "local", IsConnected: true})
+	if err := mn.AddPeer(&Peer{ID: "a", IsConnected: true}); err != nil {
+		t.Fatalf("AddPeer failed: %v", err)
+	}
+	mn.UpsertPeer(&Peer{ID: "b", IsConnected: true})
+	if _, ok := mn.GetTopology().ConnectedPeers["b"]; ok {
+		t.Error("Expected peer limit to be enforced by UpsertPeer")
+	}
+	// Известный пир обновляется несмотря на лимит
+	if err := mn.AddPeer(&Peer{ID: "a", IsConnected: true, Latency: 7}); err != nil {
+		t.Errorf("Expected known peer update to succeed, got %v", err)
+	}
+}
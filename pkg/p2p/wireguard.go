@@ -0,0 +1,205 @@
+package p2p
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
+)
+
+const (
+	// wireGuardSyncInterval период синхронизации пиров WireGuard с mesh discovery
+	wireGuardSyncInterval = 15 * time.Second
+	// wireGuardKeepalive PersistentKeepalive обнаруженных пиров: держит NAT отображения
+	wireGuardKeepalive = 25 * time.Second
+)
+
+// SetUserspaceWireGuard включает встроенный WireGuard (wireguard-go на TUN интерфейсе)
+// вместо интерфейса ядра: не нужен модуль ядра и права root, если TUN интерфейс
+// создан заранее. В режиме wireguard.ModeNetstack интерфейс не нужен вовсе: overlay
+// сеть доступна через DialContext. Вызывается до Start.
+func (m *Manager) SetUserspaceWireGuard(config *wireguard.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wgDeviceConfig = config
+}
+
+// startUserspaceWireGuardLocked поднимает встроенный WireGuard по конфигурации wg-quick
+// из relay API. Вызывается под m.mu.
+func (m *Manager) startUserspaceWireGuardLocked(quick *wireguard.QuickConfig) error {
+	device := wireguard.NewDevice(m.userspaceWireGuardConfigLocked(), m.logger)
+	if err := device.Start(&quick.Interface); err != nil {
+		return fmt.Errorf("failed to start userspace WireGuard: %w", err)
+	}
+	if err := device.SetPeers(quick.Peers); err != nil {
+		_ = device.Close() //nolint:errcheck // cleanup after failed setup
+		return fmt.Errorf("failed to configure userspace WireGuard peers: %w", err)
+	}
+	m.wgDevice = device
+	return nil
+}
+
+// userspaceWireGuardConfigLocked возвращает настройки встроенного WireGuard: пакеты пиров
+// проверяются политикой доступа mesh до записи в интерфейс. Вызывается под m.mu.
+func (m *Manager) userspaceWireGuardConfigLocked() *wireguard.Config {
+	config := *m.wgDeviceConfig
+	config.InboundFilter = m.FilterOverlayPacket
+	return &config
+}
+
+// netstackLocked сообщает, что встроенный WireGuard работает на стеке gVisor без
+// интерфейса системы. Вызывается под m.mu.
+func (m *Manager) netstackLocked() bool {
+	return m.wgDeviceConfig != nil && m.wgDeviceConfig.Mode == wireguard.ModeNetstack
+}
+
+// DialContext соединяется с address: адреса overlay сети в режиме netstack — через стек
+// встроенного WireGuard, остальные — через сеть системы (на интерфейсе overlay сети
+// маршруты системы ведут в WireGuard сами)
+func (m *Manager) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	m.mu.RLock()
+	device, _ := m.wgDevice.(*wireguard.Device)
+	netstack := m.netstackLocked()
+	m.mu.RUnlock()
+	if device != nil && netstack {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			if addr, err := netip.ParseAddr(host); err == nil && device.Routes(addr) {
+				return device.DialContext(ctx, network, address)
+			}
+		}
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// syncWireGuardPeersLocked передает reconciler пиров из конфигурации WireGuard и сразу
+// добавляет на устройство пиров mesh сети. Пиры mesh сети передаются вне m.mu: разрешение
+// их endpoint может ждать DNS. Вызывается под m.mu.
+func (m *Manager) syncWireGuardPeersLocked(static []wireguard.Peer) {
+	if m.wgSync == nil {
+		m.wgSync = wireguard.NewReconciler(m.wgDevice, wireGuardKeepalive, m.logger)
+		go m.wireGuardSyncLoop(m.wgSync)
+	}
+	m.wgSync.SetStaticPeers(static)
+	if err := m.wgSync.Reconcile(); err != nil {
+		m.logger.Warn("Failed to sync WireGuard peers", "error", err)
+	}
+	go m.syncDiscoveredPeers(m.wgSync, m.wireGuardPeersLocked())
+}
+
+// syncDiscoveredPeers передает reconciler пиров mesh сети и применяет их к устройству
+func (m *Manager) syncDiscoveredPeers(sync *wireguard.Reconciler, peers []wireguard.DiscoveredPeer) {
+	sync.SetDiscoveredPeers(peers)
+	if err := sync.Reconcile(); err != nil {
+		m.logger.Warn("Failed to sync WireGuard peers", "error", err)
+	}
+}
+
+// wireGuardSyncLoop добавляет на устройство WireGuard появившихся пиров mesh сети и
+// удаляет ушедших
+func (m *Manager) wireGuardSyncLoop(sync *wireguard.Reconciler) {
+	ticker := time.NewTicker(wireGuardSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.RLock()
+			if m.wgSync != sync {
+				m.mu.RUnlock()
+				return
+			}
+			peers := m.wireGuardPeersLocked()
+			m.mu.RUnlock()
+
+			m.syncDiscoveredPeers(sync, peers)
+		}
+	}
+}
+
+// wireGuardPeersLocked собирает пиров mesh сети с ключами WireGuard и принятыми
+// AllowedIPs. Вызывается под m.mu.
+func (m *Manager) wireGuardPeersLocked() []wireguard.DiscoveredPeer {
+	if m.mesh == nil {
+		return nil
+	}
+	var peers []wireguard.DiscoveredPeer
+	for id, peer := range m.mesh.GetTopology().ConnectedPeers {
+		if id == m.peerID {
+			continue
+		}
+		peers = append(peers, wireguard.DiscoveredPeer{
+			ID:         id,
+			PublicKey:  wireGuardPublicKey(peer.PublicKey),
+			Endpoint:   peer.Endpoint,
+			AllowedIPs: m.mesh.AcceptedAllowedIPs(id),
+		})
+	}
+	return peers
+}
+
+// wireGuardPublicKey возвращает ключ WireGuard пира: из ключа идентичности он выводится,
+// иначе опубликованный ключ используется как есть (пиры без ключа WireGuard пропускаются)
+func wireGuardPublicKey(publicKey string) string {
+	pub, err := identity.ParsePublicKey(publicKey)
+	if err != nil {
+		return publicKey
+	}
+	curve, err := identity.Curve25519PublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(curve)
+}
+
+// updateWireGuardPath переключает endpoint WireGuard пира на прямой путь, найденный ICE.
+// Когда прямого пути больше нет, endpoint остается, пока по нему проходят handshake.
+func (m *Manager) updateWireGuardPath(peerID string, path PathType, remoteAddr string) {
+	m.mu.RLock()
+	sync := m.wgSync
+	m.mu.RUnlock()
+	if sync == nil {
+		return
+	}
+
+	address := ""
+	if path == PathDirect {
+		address = remoteAddr
+	}
+	if !sync.SetDirectAddress(peerID, address) {
+		return
+	}
+	if err := sync.Reconcile(); err != nil {
+		m.logger.Warn("Failed to update WireGuard peer endpoint", "peer_id", peerID, "error", err)
+		return
+	}
+	if address != "" {
+		m.logger.Info("WireGuard peer switched to direct path", "peer_id", peerID, "address", address)
+	}
+}
+
+// stopWireGuardLocked останавливает встроенный WireGuard или удаляет интерфейс ядра.
+// Вызывается под m.mu.
+func (m *Manager) stopWireGuardLocked() {
+	m.wgSync = nil
+	if m.wgDevice == nil {
+		return
+	}
+	var err error
+	if m.wgDeviceConfig == nil && m.wireguardClient != nil {
+		err = m.wireguardClient.DownWireGuardConfig()
+	} else {
+		err = m.wgDevice.Close()
+	}
+	if err != nil {
+		m.logger.Error("Failed to stop WireGuard", "error", err)
+	}
+	m.wgDevice = nil
+}
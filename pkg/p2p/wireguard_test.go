@@ -0,0 +1,108 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
+)
+
+func TestManager_WireGuardPeersFromMesh(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.peerID, m.tenantCIDR = "local", "10.0.0.0/24"
+	m.mesh = newTestMesh(t, RoutingDirect)
+	m.mesh.SetRouteFilter(m.routeFilterLocked())
+
+	key, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate identity key: %v", err)
+	}
+	rawKey := key.WireGuardPublicKey()
+	m.mesh.UpsertPeer(&Peer{ID: "local", PublicKey: "local-key", IsConnected: true, AllowedIPs: []string{"10.0.0.0/24"}})
+	m.mesh.UpsertPeer(&Peer{ID: "office", PublicKey: key.PublicKey(), Endpoint: "198.51.100.2:51820", IsConnected: true,
+		AllowedIPs: []string{"10.0.0.2/32", "192.168.10.0/24"}})
+	m.mesh.UpsertPeer(&Peer{ID: "laptop", PublicKey: rawKey, IsConnected: true, AllowedIPs: []string{"10.0.0.3/32"}})
+
+	peers := m.wireGuardPeersLocked()
+	slices.SortFunc(peers, func(a, b wireguard.DiscoveredPeer) int { return strings.Compare(a.ID, b.ID) })
+	if len(peers) != 2 || peers[0].ID != "laptop" || peers[1].ID != "office" {
+		t.Fatalf("Unexpected WireGuard peers: %+v", peers)
+	}
+	// Ключ WireGuard выводится из ключа идентичности; непринятая подсеть LAN не попадает в AllowedIPs
+	if peers[1].PublicKey != key.WireGuardPublicKey() || peers[1].Endpoint != "198.51.100.2:51820" ||
+		!slices.Equal(peers[1].AllowedIPs, []string{"10.0.0.2/32"}) {
+		t.Errorf("Unexpected office peer: %+v", peers[1])
+	}
+	if peers[0].PublicKey != rawKey {
+		t.Errorf("Expected published WireGuard key to be used as is, got %s", peers[0].PublicKey)
+	}
+}
+
+func TestManager_WireGuardDirectPath(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.wgSync = wireguard.NewReconciler(wireguard.NewDevice(nil, m.logger), time.Second, m.logger)
+
+	directPeers := func() interface{} { return m.wgSync.GetMetrics()["direct_peers"] }
+	m.updateWireGuardPath("office", PathTURN, "203.0.113.5:3478")
+	if directPeers() != 0 {
+		t.Error("TURN path must not be used as direct WireGuard endpoint")
+	}
+	m.updateWireGuardPath("office", PathDirect, "192.168.1.20:40000")
+	if directPeers() != 1 {
+		t.Error("Expected direct path to be recorded")
+	}
+	m.updateMeshPath("office", "", false)
+	if directPeers() != 0 {
+		t.Error("Expected direct path to be cleared on disconnect")
+	}
+}
+
+func TestManager_DialContextNetstack(t *testing.T) {
+	m := newSessionTestManager(10)
+	m.wgDeviceConfig = &wireguard.Config{InterfaceName: "wg-test", MTU: 1420, Mode: wireguard.ModeNetstack}
+
+	key, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate identity key: %v", err)
+	}
+	device := wireguard.NewDevice(m.wgDeviceConfig, m.logger)
+	if err := device.Start(&wireguard.Interface{PrivateKey: key.WireGuardPrivateKey(), Addresses: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("Failed to start netstack WireGuard: %v", err)
+	}
+	defer device.Close() //nolint:errcheck // test cleanup
+	peer, err := identity.Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate identity key: %v", err)
+	}
+	if err := device.UpsertPeer(wireguard.Peer{PublicKey: peer.WireGuardPublicKey(), AllowedIPs: []string{"10.9.0.2/32"}}); err != nil {
+		t.Fatalf("UpsertPeer failed: %v", err)
+	}
+	m.wgDevice = device
+
+	// Адреса вне overlay сети идут через сеть системы
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close() //nolint:errcheck // test cleanup
+	conn, err := m.DialContext(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected system dial to succeed, got %v", err)
+	}
+	_ = conn.Close() //nolint:errcheck // test cleanup
+
+	// Пир overlay сети без endpoint недостижим: соединение ждет в netstack до таймаута
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := m.DialContext(ctx, "tcp", "10.9.0.2:5432"); err == nil {
+		t.Fatal("Expected overlay dial without a peer endpoint to fail")
+	}
+	if device.GetMetrics()["mode"] != wireguard.ModeNetstack {
+		t.Errorf("Unexpected device metrics: %v", device.GetMetrics())
+	}
+}
@@ -210,3 +210,20 @@ func TestDisabledOptimizer(t *testing.T) {
 	}
 	stop() // Should be safe to call
 }
+
+func TestResourceSampler(t *testing.T) {
+	sampler := NewResourceSampler()
+
+	// Burn some CPU so the sample window is not empty
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+
+	usage := sampler.Sample()
+	if usage.MemoryBytes == 0 {
+		t.Error("Expected non-zero memory usage")
+	}
+	if usage.CPUPercent < 0 || usage.CPUPercent > 100 {
+		t.Errorf("Expected CPU usage within 0-100%%, got %f", usage.CPUPercent)
+	}
+}
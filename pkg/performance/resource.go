@@ -0,0 +1,51 @@
+package performance
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ResourceUsage describes process resource consumption
+type ResourceUsage struct {
+	CPUPercent  float64 // Share of total CPU capacity used since the previous sample (0-100)
+	MemoryBytes uint64  // Memory obtained from the OS by the Go runtime
+}
+
+// ResourceSampler measures process CPU usage between consecutive samples
+type ResourceSampler struct {
+	lastCPU  time.Duration
+	lastWall time.Time
+	mu       sync.Mutex
+}
+
+// NewResourceSampler creates a sampler; the first Sample reports usage since creation
+func NewResourceSampler() *ResourceSampler {
+	cpu, _ := processCPUTime() //nolint:errcheck // zero baseline on unsupported platforms
+	return &ResourceSampler{lastCPU: cpu, lastWall: time.Now()}
+}
+
+// Sample returns CPU usage since the previous sample and current memory usage
+func (rs *ResourceSampler) Sample() ResourceUsage {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	usage := ResourceUsage{MemoryBytes: m.Sys}
+
+	cpu, err := processCPUTime()
+	if err != nil {
+		return usage
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	wall := now.Sub(rs.lastWall)
+	if wall > 0 {
+		capacity := float64(wall) * float64(runtime.NumCPU())
+		usage.CPUPercent = float64(cpu-rs.lastCPU) / capacity * 100
+	}
+	rs.lastCPU = cpu
+	rs.lastWall = now
+	return usage
+}
@@ -0,0 +1,375 @@
+package pop
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/handover"
+	"github.com/2gc-dev/cloudbridge-client/pkg/probes"
+	"github.com/2gc-dev/cloudbridge-client/pkg/slo"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Endpoint описывает один relay POP
+type Endpoint struct {
+	ID         string `json:"id"`          // Уникальный ID POP
+	Host       string `json:"host"`        // Хост relay сервера
+	Port       int    `json:"port"`        // Порт relay сервера (gRPC/TLS)
+	Region     string `json:"region"`      // Регион (опционально)
+	ServerName string `json:"server_name"` // SNI (опционально)
+}
+
+// Address возвращает host:port endpoint'а
+func (e *Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Ranked представляет POP с результатами последнего probe
+type Ranked struct {
+	Endpoint *Endpoint
+	Healthy  bool
+	Metrics  *slo.PathMetrics
+}
+
+// SwitchCallback вызывается при смене активного POP (from может быть nil при первом выборе)
+type SwitchCallback func(from, to *Endpoint)
+
+// SelectorConfig конфигурация селектора POP
+type SelectorConfig struct {
+	ProbeInterval   time.Duration `json:"probe_interval"`   // Интервал probe и пересчета рейтинга
+	ProbeTimeout    time.Duration `json:"probe_timeout"`    // Таймаут одного probe
+	PreferredRegion string        `json:"preferred_region"` // Предпочтительный регион
+}
+
+// DefaultSelectorConfig возвращает конфигурацию по умолчанию
+func DefaultSelectorConfig() *SelectorConfig {
+	return &SelectorConfig{
+		ProbeInterval: 30 * time.Second,
+		ProbeTimeout:  3 * time.Second,
+	}
+}
+
+// Selector выбирает лучший relay POP по латентности и непрерывно пересчитывает рейтинг.
+// Для защиты от флаппинга используется гистерезис SLOController,
+// переключения фиксируются в HandoverManager.
+type Selector struct {
+	endpoints []*Endpoint
+	config    *SelectorConfig
+	probeMgr  *probes.SyntheticProbeManager
+	sloCtl    *slo.SLOController
+	handover  *handover.HandoverManager
+	logger    Logger
+
+	current   *Endpoint
+	callbacks []SwitchCallback
+	switches  int64
+
+	mu      sync.RWMutex
+	stopCh  chan struct{}
+	running bool
+}
+
+// NewSelector создает новый селектор POP. probeMgr, sloCtl и handoverMgr опциональны:
+// если не заданы, создаются собственные экземпляры.
+func NewSelector(endpoints []*Endpoint, config *SelectorConfig, probeMgr *probes.SyntheticProbeManager,
+	sloCtl *slo.SLOController, handoverMgr *handover.HandoverManager, logger Logger) (*Selector, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one relay endpoint is required")
+	}
+	if config == nil {
+		config = DefaultSelectorConfig()
+	}
+	if sloCtl == nil {
+		sloCtl = slo.NewSLOController(nil, logger)
+	}
+	if handoverMgr == nil {
+		handoverMgr = handover.NewHandoverManager(nil, logger)
+	}
+
+	if probeMgr == nil {
+		probeMgr = probes.NewSyntheticProbeManager(&probes.SyntheticProbeConfig{
+			Interval: config.ProbeInterval,
+			Timeout:  config.ProbeTimeout,
+		}, logger)
+	}
+
+	for _, ep := range endpoints {
+		if ep.ID == "" {
+			ep.ID = ep.Host
+		}
+		probeMgr.AddProbe(&probes.ProbeConfig{
+			Name:     probeName(ep),
+			URL:      "tcp://" + ep.Address(),
+			Timeout:  config.ProbeTimeout,
+			Interval: config.ProbeInterval,
+			Enabled:  true,
+			Mode:     "tcp",
+			POP:      ep.ID,
+		})
+	}
+
+	return &Selector{
+		endpoints: endpoints,
+		config:    config,
+		probeMgr:  probeMgr,
+		sloCtl:    sloCtl,
+		handover:  handoverMgr,
+		logger:    logger,
+		stopCh:    make(chan struct{}),
+	}, nil
+}
+
+// probeName возвращает имя probe для POP
+func probeName(ep *Endpoint) string {
+	return "pop-" + ep.ID
+}
+
+// Start выполняет начальный probe всех POP, выбирает лучший и запускает периодический пересчет
+func (s *Selector) Start() error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return fmt.Errorf("POP selector already running")
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	// Начальный probe синхронно, чтобы подключиться сразу к лучшему POP
+	s.probeMgr.RunOnce()
+	s.Rerank()
+
+	if err := s.probeMgr.Start(); err != nil {
+		return fmt.Errorf("failed to start POP probes: %w", err)
+	}
+
+	go s.rerankLoop()
+
+	s.logger.Info("POP selector started",
+		"endpoints", len(s.endpoints),
+		"interval", s.config.ProbeInterval,
+		"preferred_region", s.config.PreferredRegion)
+	return nil
+}
+
+// Stop останавливает probe и пересчет рейтинга
+func (s *Selector) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.probeMgr.Stop()
+
+	s.logger.Info("POP selector stopped")
+}
+
+// rerankLoop периодически пересчитывает рейтинг POP
+func (s *Selector) rerankLoop() {
+	ticker := time.NewTicker(s.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Rerank()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Current возвращает текущий выбранный POP
+func (s *Selector) Current() *Endpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		// До первого probe используем первый POP из конфигурации
+		return s.endpoints[0]
+	}
+	return s.current
+}
+
+// AddSwitchCallback добавляет callback на смену POP
+func (s *Selector) AddSwitchCallback(callback SwitchCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Ranking возвращает POP, отсортированные от лучшего к худшему
+func (s *Selector) Ranking() []*Ranked {
+	results := s.probeMgr.GetResults()
+
+	ranking := make([]*Ranked, 0, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		r := &Ranked{Endpoint: ep, Metrics: &slo.PathMetrics{}}
+		if res, ok := results[probeName(ep)]; ok && res.CheckCount > 0 {
+			r.Healthy = res.Success
+			r.Metrics.RTT = res.Latency
+			r.Metrics.Loss = res.ErrorRate
+		}
+		ranking = append(ranking, r)
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		if pref := s.config.PreferredRegion; pref != "" {
+			aPref, bPref := a.Endpoint.Region == pref, b.Endpoint.Region == pref
+			if aPref != bPref {
+				return aPref
+			}
+		}
+		return cost(a.Metrics) < cost(b.Metrics)
+	})
+
+	return ranking
+}
+
+// cost вычисляет стоимость POP (та же формула, что и в SLOController)
+func cost(m *slo.PathMetrics) float64 {
+	return float64(m.RTT.Milliseconds()) + m.Loss*1000 + float64(m.Jitter.Milliseconds())
+}
+
+// Rerank пересчитывает рейтинг и при необходимости переключает активный POP
+func (s *Selector) Rerank() {
+	ranking := s.Ranking()
+	best := ranking[0]
+	if !best.Healthy {
+		s.logger.Warn("No healthy relay POP available")
+		return
+	}
+
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	var currentRanked *Ranked
+	for _, r := range ranking {
+		if current != nil && r.Endpoint.ID == current.ID {
+			currentRanked = r
+			break
+		}
+	}
+
+	switch {
+	case current == nil:
+		// Первый выбор
+	case best.Endpoint.ID == current.ID:
+		s.sloCtl.Update(float64(best.Metrics.RTT.Milliseconds()), best.Metrics.Loss,
+			float64(best.Metrics.Jitter.Milliseconds()))
+		return
+	case currentRanked == nil || !currentRanked.Healthy:
+		// Текущий POP недоступен — переключаемся без гистерезиса
+		s.logger.Warn("Current relay POP is unhealthy, failing over",
+			"current", current.ID,
+			"candidate", best.Endpoint.ID)
+	default:
+		s.sloCtl.Update(float64(currentRanked.Metrics.RTT.Milliseconds()), currentRanked.Metrics.Loss,
+			float64(currentRanked.Metrics.Jitter.Milliseconds()))
+		if !s.sloCtl.ShouldSwitch(currentRanked.Metrics, best.Metrics) {
+			return
+		}
+	}
+
+	s.switchTo(current, best.Endpoint)
+}
+
+// SwitchTo переключает активный POP по ID (например, по команде relay)
+func (s *Selector) SwitchTo(id string) error {
+	var target *Endpoint
+	for _, ep := range s.endpoints {
+		if ep.ID == id {
+			target = ep
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("unknown relay POP: %s", id)
+	}
+
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+	if current != nil && current.ID == id {
+		return nil
+	}
+
+	s.switchTo(current, target)
+	return nil
+}
+
+// switchTo делает POP активным и уведомляет подписчиков
+func (s *Selector) switchTo(from, to *Endpoint) {
+	s.mu.Lock()
+	s.current = to
+	s.switches++
+	callbacks := make([]SwitchCallback, len(s.callbacks))
+	copy(callbacks, s.callbacks)
+	s.mu.Unlock()
+
+	if from != nil {
+		s.sloCtl.RecordSwitch()
+		s.handover.RecordSwitch(to.ID)
+	}
+
+	fromID := ""
+	if from != nil {
+		fromID = from.ID
+	}
+	s.logger.Info("Relay POP selected",
+		"from", fromID,
+		"to", to.ID,
+		"address", to.Address(),
+		"region", to.Region)
+
+	for _, cb := range callbacks {
+		cb(from, to)
+	}
+}
+
+// GetMetrics возвращает метрики селектора POP
+func (s *Selector) GetMetrics() map[string]interface{} {
+	ranking := s.Ranking()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pops := make([]map[string]interface{}, 0, len(ranking))
+	for _, r := range ranking {
+		pops = append(pops, map[string]interface{}{
+			"id":         r.Endpoint.ID,
+			"region":     r.Endpoint.Region,
+			"healthy":    r.Healthy,
+			"rtt_ms":     r.Metrics.RTT.Milliseconds(),
+			"error_rate": r.Metrics.Loss,
+		})
+	}
+
+	metrics := map[string]interface{}{
+		"running":  s.running,
+		"switches": s.switches,
+		"pops":     pops,
+	}
+	if s.current != nil {
+		metrics["current"] = s.current.ID
+	}
+	return metrics
+}
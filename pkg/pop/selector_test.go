@@ -0,0 +1,125 @@
+package pop
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testLogger реализует Logger для тестов
+type testLogger struct{}
+
+func (l *testLogger) Info(msg string, fields ...interface{})  {}
+func (l *testLogger) Error(msg string, fields ...interface{}) {}
+func (l *testLogger) Debug(msg string, fields ...interface{}) {}
+func (l *testLogger) Warn(msg string, fields ...interface{})  {}
+
+// listenPOP запускает TCP listener, имитирующий relay POP
+func listenPOP(t *testing.T) (net.Listener, *Endpoint) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return ln, &Endpoint{Host: host, Port: port}
+}
+
+// unreachableEndpoint возвращает endpoint, на котором никто не слушает
+func unreachableEndpoint(t *testing.T) *Endpoint {
+	t.Helper()
+	ln, ep := listenPOP(t)
+	_ = ln.Close()
+	return ep
+}
+
+func TestSelector_PicksReachablePOP(t *testing.T) {
+	dead := unreachableEndpoint(t)
+	dead.ID = "dead"
+	ln, alive := listenPOP(t)
+	defer ln.Close()
+	alive.ID = "alive"
+
+	selector, err := NewSelector([]*Endpoint{dead, alive}, &SelectorConfig{
+		ProbeInterval: time.Hour,
+		ProbeTimeout:  time.Second,
+	}, nil, nil, nil, &testLogger{})
+	if err != nil {
+		t.Fatalf("NewSelector failed: %v", err)
+	}
+
+	var switched *Endpoint
+	selector.AddSwitchCallback(func(from, to *Endpoint) {
+		if from != nil {
+			t.Errorf("Expected initial selection without previous POP, got %s", from.ID)
+		}
+		switched = to
+	})
+
+	if err := selector.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer selector.Stop()
+
+	if selector.Current().ID != "alive" {
+		t.Errorf("Expected POP 'alive', got %s", selector.Current().ID)
+	}
+	if switched == nil || switched.ID != "alive" {
+		t.Errorf("Expected switch callback for 'alive'")
+	}
+}
+
+func TestSelector_FailoverWhenCurrentUnhealthy(t *testing.T) {
+	ln1, first := listenPOP(t)
+	first.ID = "first"
+	first.Region = "eu"
+	ln2, second := listenPOP(t)
+	defer ln2.Close()
+	second.ID = "second"
+
+	selector, err := NewSelector([]*Endpoint{first, second}, &SelectorConfig{
+		ProbeInterval: time.Hour,
+		ProbeTimeout:  time.Second,
+		// Предпочитаемый регион гарантирует детерминированный начальный выбор
+		PreferredRegion: "eu",
+	}, nil, nil, nil, &testLogger{})
+	if err != nil {
+		t.Fatalf("NewSelector failed: %v", err)
+	}
+
+	if err := selector.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer selector.Stop()
+
+	if selector.Current().ID != "first" {
+		t.Fatalf("Expected initial POP 'first', got %s", selector.Current().ID)
+	}
+
+	// Текущий POP становится недоступным — переключение без ожидания гистерезиса
+	_ = ln1.Close()
+	selector.probeMgr.RunOnce()
+	selector.Rerank()
+
+	if selector.Current().ID != "second" {
+		t.Errorf("Expected failover to 'second', got %s", selector.Current().ID)
+	}
+}
+
+func TestSelector_RequiresEndpoints(t *testing.T) {
+	if _, err := NewSelector(nil, nil, nil, nil, nil, &testLogger{}); err == nil {
+		t.Error("Expected error for empty endpoint list")
+	}
+}
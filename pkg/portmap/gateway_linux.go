@@ -0,0 +1,43 @@
+//go:build linux
+
+package portmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// defaultGateway читает шлюз маршрута по умолчанию из /proc/net/route
+func defaultGateway() (net.IP, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer file.Close()
+	return parseRouteTable(bufio.NewScanner(file))
+}
+
+// parseRouteTable находит маршрут 0.0.0.0/0; адреса в таблице — little-endian hex
+func parseRouteTable(scanner *bufio.Scanner) (net.IP, error) {
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		if !ip.IsUnspecified() {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("no default route")
+}
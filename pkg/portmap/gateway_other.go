@@ -0,0 +1,42 @@
+//go:build !linux
+
+package portmap
+
+import (
+	"fmt"
+	"net"
+)
+
+// defaultGateway предполагает, что шлюз — первый адрес подсети локального
+// адреса маршрута по умолчанию (типичная конфигурация домашнего роутера).
+// Для других сетей адрес задается через Config.Gateway
+func defaultGateway() (net.IP, error) {
+	local, err := localIPFor(&net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 9})
+	if err != nil {
+		return nil, fmt.Errorf("no default route: %w", err)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.Equal(local) {
+				continue
+			}
+			gateway := ipNet.IP.Mask(ipNet.Mask).To4()
+			if gateway == nil {
+				continue
+			}
+			gateway[3]++
+			return gateway, nil
+		}
+	}
+	return nil, fmt.Errorf("no interface for local address %s", local)
+}
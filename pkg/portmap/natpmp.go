@@ -0,0 +1,124 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Коды операций NAT-PMP (RFC 6886)
+const (
+	natpmpVersion       byte = 0
+	natpmpOpExternalIP  byte = 0
+	natpmpOpMapUDP      byte = 1
+	natpmpResponseFlag  byte = 0x80
+	natpmpExternalIPLen      = 12
+	natpmpMapLen             = 16
+)
+
+// natpmpMapper отображение через NAT-PMP
+type natpmpMapper struct {
+	gateway *net.UDPAddr
+}
+
+func newNATPMPMapper(gateway *net.UDPAddr) *natpmpMapper {
+	return &natpmpMapper{gateway: gateway}
+}
+
+func (m *natpmpMapper) addMapping(ctx context.Context, internalPort int, previous *Mapping,
+	lifetime time.Duration) (*Mapping, error) {
+	externalIP, err := m.externalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	suggested := internalPort
+	if previous != nil {
+		suggested = previous.External.Port
+	}
+	externalPort, granted, err := m.mapUDP(ctx, internalPort, suggested, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if granted == 0 {
+		return nil, fmt.Errorf("NAT-PMP gateway granted zero lifetime")
+	}
+
+	return &Mapping{
+		Protocol:     ProtocolNATPMP,
+		InternalPort: internalPort,
+		External:     &net.UDPAddr{IP: externalIP, Port: externalPort},
+		Lifetime:     granted,
+		ExpiresAt:    time.Now().Add(granted),
+	}, nil
+}
+
+func (m *natpmpMapper) deleteMapping(ctx context.Context, mapping *Mapping) error {
+	// Удаление — запрос с нулевыми lifetime и внешним портом (RFC 6886, раздел 3.4)
+	_, _, err := m.mapUDP(ctx, mapping.InternalPort, 0, 0)
+	return err
+}
+
+// externalIP запрашивает внешний адрес шлюза
+func (m *natpmpMapper) externalIP(ctx context.Context) (net.IP, error) {
+	resp, err := exchange(ctx, m.gateway, []byte{natpmpVersion, natpmpOpExternalIP},
+		natpmpAccept(natpmpOpExternalIP, natpmpExternalIPLen))
+	if err != nil {
+		return nil, err
+	}
+	if err := natpmpResult(resp); err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// mapUDP запрашивает отображение UDP порта; возвращает внешний порт и выданный lifetime
+func (m *natpmpMapper) mapUDP(ctx context.Context, internalPort, suggested int, lifetime time.Duration) (int, time.Duration, error) {
+	req := make([]byte, 12)
+	req[0] = natpmpVersion
+	req[1] = natpmpOpMapUDP
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort)) // #nosec G115 -- port is validated in Start
+	binary.BigEndian.PutUint16(req[6:8], uint16(suggested))    // #nosec G115 -- port range
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime/time.Second))
+
+	resp, err := exchange(ctx, m.gateway, req, natpmpAccept(natpmpOpMapUDP, natpmpMapLen))
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := natpmpResult(resp); err != nil {
+		return 0, 0, err
+	}
+	if int(binary.BigEndian.Uint16(resp[8:10])) != internalPort {
+		return 0, 0, fmt.Errorf("NAT-PMP response for unexpected internal port")
+	}
+	externalPort := int(binary.BigEndian.Uint16(resp[10:12]))
+	granted := time.Duration(binary.BigEndian.Uint32(resp[12:16])) * time.Second
+	return externalPort, granted, nil
+}
+
+// natpmpAccept проверяет, что пакет — ответ на операцию op
+func natpmpAccept(op byte, length int) func([]byte) bool {
+	return func(resp []byte) bool {
+		return len(resp) >= length && resp[0] == natpmpVersion && resp[1] == natpmpResponseFlag|op
+	}
+}
+
+// natpmpResult преобразует код результата NAT-PMP в ошибку
+func natpmpResult(resp []byte) error {
+	switch code := binary.BigEndian.Uint16(resp[2:4]); code {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("NAT-PMP unsupported version")
+	case 2:
+		return fmt.Errorf("NAT-PMP not authorized")
+	case 3:
+		return fmt.Errorf("NAT-PMP network failure")
+	case 4:
+		return fmt.Errorf("NAT-PMP out of resources")
+	default:
+		return fmt.Errorf("NAT-PMP error code %d", code)
+	}
+}
@@ -0,0 +1,116 @@
+package portmap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Константы PCP (RFC 6887)
+const (
+	pcpVersion      byte = 2
+	pcpOpMap        byte = 1
+	pcpResponseFlag byte = 0x80
+	pcpProtocolUDP  byte = 17
+	pcpHeaderLen         = 24
+	pcpMapLen            = 36
+	pcpNonceLen          = 12
+)
+
+// pcpMapper отображение через PCP
+type pcpMapper struct {
+	gateway *net.UDPAddr
+}
+
+func newPCPMapper(gateway *net.UDPAddr) *pcpMapper {
+	return &pcpMapper{gateway: gateway}
+}
+
+func (m *pcpMapper) addMapping(ctx context.Context, internalPort int, previous *Mapping,
+	lifetime time.Duration) (*Mapping, error) {
+	// Продление использует тот же nonce и запрашивает тот же внешний адрес (RFC 6887, раздел 11.2)
+	nonce := make([]byte, pcpNonceLen)
+	suggested := &net.UDPAddr{IP: net.IPv4zero, Port: internalPort}
+	if previous != nil && len(previous.nonce) == pcpNonceLen {
+		nonce = previous.nonce
+		suggested = previous.External
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate PCP nonce: %w", err)
+	}
+
+	external, granted, err := m.mapUDP(ctx, nonce, internalPort, suggested, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if granted == 0 {
+		return nil, fmt.Errorf("PCP server granted zero lifetime")
+	}
+	return &Mapping{
+		Protocol:     ProtocolPCP,
+		InternalPort: internalPort,
+		External:     external,
+		Lifetime:     granted,
+		ExpiresAt:    time.Now().Add(granted),
+		nonce:        nonce,
+	}, nil
+}
+
+func (m *pcpMapper) deleteMapping(ctx context.Context, mapping *Mapping) error {
+	_, _, err := m.mapUDP(ctx, mapping.nonce, mapping.InternalPort, mapping.External, 0)
+	return err
+}
+
+// mapUDP отправляет MAP запрос; возвращает выданный внешний адрес и lifetime
+func (m *pcpMapper) mapUDP(ctx context.Context, nonce []byte, internalPort int, suggested *net.UDPAddr,
+	lifetime time.Duration) (*net.UDPAddr, time.Duration, error) {
+	clientIP, err := localIPFor(m.gateway)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to determine client address: %w", err)
+	}
+
+	req := make([]byte, pcpHeaderLen+pcpMapLen)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:8], uint32(lifetime/time.Second))
+	copy(req[8:24], clientIP.To16())
+	body := req[pcpHeaderLen:]
+	copy(body[0:12], nonce)
+	body[12] = pcpProtocolUDP
+	binary.BigEndian.PutUint16(body[16:18], uint16(internalPort))   // #nosec G115 -- port is validated in Start
+	binary.BigEndian.PutUint16(body[18:20], uint16(suggested.Port)) // #nosec G115 -- port range
+	copy(body[20:36], suggested.IP.To16())
+
+	accept := func(resp []byte) bool {
+		// Сервер NAT-PMP отвечает на PCP запрос версией 0 — такой ответ тоже принимается как отказ
+		if len(resp) >= 4 && resp[0] == natpmpVersion {
+			return true
+		}
+		return len(resp) >= pcpHeaderLen+pcpMapLen && resp[0] == pcpVersion &&
+			resp[1] == pcpResponseFlag|pcpOpMap && bytes.Equal(resp[pcpHeaderLen:pcpHeaderLen+pcpNonceLen], nonce)
+	}
+	resp, err := exchange(ctx, m.gateway, req, accept)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp[0] != pcpVersion {
+		return nil, 0, fmt.Errorf("PCP not supported by gateway")
+	}
+	if code := resp[3]; code != 0 {
+		return nil, 0, fmt.Errorf("PCP error code %d", code)
+	}
+
+	granted := time.Duration(binary.BigEndian.Uint32(resp[4:8])) * time.Second
+	body = resp[pcpHeaderLen:]
+	external := &net.UDPAddr{
+		IP:   net.IP(append([]byte(nil), body[20:36]...)),
+		Port: int(binary.BigEndian.Uint16(body[18:20])),
+	}
+	if ip4 := external.IP.To4(); ip4 != nil {
+		external.IP = ip4
+	}
+	return external, granted, nil
+}
@@ -0,0 +1,390 @@
+// Package portmap запрашивает у домашнего роутера внешнее отображение UDP порта
+// через PCP (RFC 6887), NAT-PMP (RFC 6886) или UPnP IGD, продлевает его до истечения
+// аренды и удаляет при остановке.
+package portmap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Protocol протокол управления отображениями
+type Protocol string
+
+const (
+	ProtocolPCP    Protocol = "pcp"
+	ProtocolNATPMP Protocol = "natpmp"
+	ProtocolUPnP   Protocol = "upnp"
+)
+
+// Config конфигурация клиента отображения портов
+type Config struct {
+	Protocols        []Protocol    `json:"protocols"`   // порядок попыток
+	Gateway          string        `json:"gateway"`     // шлюз для PCP/NAT-PMP; пусто — шлюз маршрута по умолчанию
+	Lifetime         time.Duration `json:"lifetime"`    // запрашиваемое время жизни отображения
+	Description      string        `json:"description"` // описание отображения (UPnP)
+	RequestTimeout   time.Duration `json:"request_timeout"`
+	RetryInterval    time.Duration `json:"retry_interval"` // пауза после неудачи всех протоколов
+	MaxRetryInterval time.Duration `json:"max_retry_interval"`
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		Protocols:        []Protocol{ProtocolPCP, ProtocolNATPMP, ProtocolUPnP},
+		Lifetime:         2 * time.Hour,
+		Description:      "CloudBridge P2P",
+		RequestTimeout:   5 * time.Second,
+		RetryInterval:    time.Minute,
+		MaxRetryInterval: 30 * time.Minute,
+	}
+}
+
+// Mapping внешнее отображение локального UDP порта
+type Mapping struct {
+	Protocol     Protocol      `json:"protocol"`
+	InternalPort int           `json:"internal_port"`
+	External     *net.UDPAddr  `json:"external"`
+	Lifetime     time.Duration `json:"lifetime"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+
+	nonce []byte // PCP: nonce, которым отображение продлевается и удаляется
+}
+
+// mapper реализация одного протокола
+type mapper interface {
+	// addMapping создает или продлевает отображение; previous — текущее отображение или nil
+	addMapping(ctx context.Context, internalPort int, previous *Mapping, lifetime time.Duration) (*Mapping, error)
+	deleteMapping(ctx context.Context, mapping *Mapping) error
+}
+
+// Client поддерживает отображение одного локального UDP порта
+type Client struct {
+	config *Config
+	logger Logger
+
+	// newMapper создает реализацию протокола; заменяется в тестах
+	newMapper func(protocol Protocol) (mapper, error)
+
+	mu      sync.RWMutex
+	mapping *Mapping
+	active  mapper
+
+	renewals atomic.Int64
+	failures atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewClient создает клиент; незаданные параметры берутся из DefaultConfig
+func NewClient(config *Config, logger Logger) *Client {
+	defaults := DefaultConfig()
+	if len(config.Protocols) == 0 {
+		config.Protocols = defaults.Protocols
+	}
+	if config.Lifetime <= 0 {
+		config.Lifetime = defaults.Lifetime
+	}
+	if config.Description == "" {
+		config.Description = defaults.Description
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = defaults.RequestTimeout
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaults.RetryInterval
+	}
+	if config.MaxRetryInterval <= 0 {
+		config.MaxRetryInterval = defaults.MaxRetryInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		config: config,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	c.newMapper = c.defaultMapper
+	return c
+}
+
+// Start запускает получение и продление отображения internalPort в фоне
+func (c *Client) Start(internalPort int) error {
+	if internalPort <= 0 || internalPort > 65535 {
+		return fmt.Errorf("invalid internal port %d", internalPort)
+	}
+	for _, protocol := range c.config.Protocols {
+		switch protocol {
+		case ProtocolPCP, ProtocolNATPMP, ProtocolUPnP:
+		default:
+			return fmt.Errorf("unsupported port mapping protocol %q", protocol)
+		}
+	}
+
+	c.wg.Add(1)
+	go c.run(internalPort)
+	return nil
+}
+
+// Stop останавливает продление и удаляет отображение на роутере
+func (c *Client) Stop() {
+	c.cancel()
+	c.wg.Wait()
+
+	c.mu.Lock()
+	mapping, active := c.mapping, c.active
+	c.mapping, c.active = nil, nil
+	c.mu.Unlock()
+	if mapping == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.RequestTimeout)
+	defer cancel()
+	if err := active.deleteMapping(ctx, mapping); err != nil {
+		c.logger.Warn("Failed to remove port mapping", "protocol", mapping.Protocol, "error", err)
+		return
+	}
+	c.logger.Info("Port mapping removed", "protocol", mapping.Protocol, "external", mapping.External.String())
+}
+
+// Mapping возвращает текущее отображение (nil — отображения нет)
+func (c *Client) Mapping() *Mapping {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.mapping == nil {
+		return nil
+	}
+	mapping := *c.mapping
+	return &mapping
+}
+
+// GetMetrics возвращает метрики клиента
+func (c *Client) GetMetrics() map[string]interface{} {
+	metrics := map[string]interface{}{
+		"mapped":   false,
+		"renewals": c.renewals.Load(),
+		"failures": c.failures.Load(),
+	}
+	if mapping := c.Mapping(); mapping != nil {
+		metrics["mapped"] = true
+		metrics["protocol"] = mapping.Protocol
+		metrics["external"] = mapping.External.String()
+		metrics["expires_at"] = mapping.ExpiresAt
+	}
+	return metrics
+}
+
+// run получает отображение и продлевает его на середине срока аренды;
+// при неудаче продления протоколы перебираются заново
+func (c *Client) run(internalPort int) {
+	defer c.wg.Done()
+
+	backoff := c.config.RetryInterval
+	for {
+		mapping, err := c.acquire(internalPort)
+		if err != nil {
+			c.failures.Add(1)
+			c.logger.Warn("Port mapping unavailable", "error", err, "retry_in", backoff)
+			if !c.sleep(backoff) {
+				return
+			}
+			backoff *= 2
+			if backoff > c.config.MaxRetryInterval {
+				backoff = c.config.MaxRetryInterval
+			}
+			continue
+		}
+		backoff = c.config.RetryInterval
+
+		for mapping != nil {
+			if !c.sleep(time.Until(mapping.ExpiresAt) / 2) {
+				return
+			}
+			mapping = c.renew(internalPort, mapping)
+		}
+	}
+}
+
+// acquire перебирает протоколы в порядке конфигурации
+func (c *Client) acquire(internalPort int) (*Mapping, error) {
+	var errs []string
+	for _, protocol := range c.config.Protocols {
+		m, err := c.newMapper(protocol)
+		if err == nil {
+			var mapping *Mapping
+			if mapping, err = c.request(m, internalPort, nil); err == nil {
+				c.setMapping(m, mapping)
+				c.logger.Info("Port mapping created",
+					"protocol", protocol,
+					"internal_port", internalPort,
+					"external", mapping.External.String(),
+					"lifetime", mapping.Lifetime)
+				return mapping, nil
+			}
+		}
+		c.logger.Debug("Port mapping protocol failed", "protocol", protocol, "error", err)
+		errs = append(errs, fmt.Sprintf("%s: %v", protocol, err))
+	}
+	return nil, fmt.Errorf("no port mapping protocol succeeded (%v)", errs)
+}
+
+// renew продлевает отображение тем же протоколом; nil — продление не удалось
+func (c *Client) renew(internalPort int, current *Mapping) *Mapping {
+	c.mu.RLock()
+	active := c.active
+	c.mu.RUnlock()
+
+	mapping, err := c.request(active, internalPort, current)
+	if err != nil {
+		c.failures.Add(1)
+		c.logger.Warn("Failed to renew port mapping", "protocol", current.Protocol, "error", err)
+		c.setMapping(nil, nil)
+		return nil
+	}
+	c.renewals.Add(1)
+	if !mapping.External.IP.Equal(current.External.IP) || mapping.External.Port != current.External.Port {
+		c.logger.Info("Port mapping changed",
+			"protocol", mapping.Protocol,
+			"from", current.External.String(),
+			"to", mapping.External.String())
+	}
+	c.setMapping(active, mapping)
+	return mapping
+}
+
+func (c *Client) request(m mapper, internalPort int, previous *Mapping) (*Mapping, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.config.RequestTimeout)
+	defer cancel()
+	mapping, err := m.addMapping(ctx, internalPort, previous, c.config.Lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.External == nil || mapping.External.IP == nil || mapping.External.IP.IsUnspecified() {
+		return nil, fmt.Errorf("gateway returned no external address")
+	}
+	return mapping, nil
+}
+
+func (c *Client) setMapping(m mapper, mapping *Mapping) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = m
+	c.mapping = mapping
+}
+
+// sleep ждет d или остановки клиента; false — клиент остановлен
+func (c *Client) sleep(d time.Duration) bool {
+	if d < time.Second {
+		d = time.Second
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// defaultMapper создает реализацию протокола для шлюза из конфигурации
+func (c *Client) defaultMapper(protocol Protocol) (mapper, error) {
+	switch protocol {
+	case ProtocolUPnP:
+		return newUPnPMapper(c.config.Description), nil
+	case ProtocolPCP, ProtocolNATPMP:
+		gateway, err := c.gatewayAddr(protocol)
+		if err != nil {
+			return nil, err
+		}
+		if protocol == ProtocolPCP {
+			return newPCPMapper(gateway), nil
+		}
+		return newNATPMPMapper(gateway), nil
+	default:
+		return nil, fmt.Errorf("unsupported port mapping protocol %q", protocol)
+	}
+}
+
+// gatewayAddr возвращает адрес сервера PCP/NAT-PMP (порт 5351, если не задан)
+func (c *Client) gatewayAddr(protocol Protocol) (*net.UDPAddr, error) {
+	gateway := c.config.Gateway
+	if gateway == "" {
+		ip, err := defaultGateway()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find default gateway: %w", err)
+		}
+		gateway = ip.String()
+	}
+	if _, _, err := net.SplitHostPort(gateway); err != nil {
+		gateway = net.JoinHostPort(gateway, "5351")
+	}
+	addr, err := net.ResolveUDPAddr("udp4", gateway)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s gateway %q: %w", protocol, gateway, err)
+	}
+	return addr, nil
+}
+
+// exchange отправляет запрос и ждет ответа с повторами по RFC 6886 (250 мс, удваивая)
+func exchange(ctx context.Context, gateway *net.UDPAddr, request []byte, accept func([]byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, gateway)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1100)
+	wait := 250 * time.Millisecond
+	for {
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		deadline := time.Now().Add(wait)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		_ = conn.SetReadDeadline(deadline) //nolint:errcheck // read fails without a deadline anyway
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if accept(buf[:n]) {
+				return append([]byte(nil), buf[:n]...), nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("no response from gateway %s", gateway)
+		}
+		wait *= 2
+	}
+}
+
+// localIPFor возвращает локальный IPv4 адрес маршрута к addr
+func localIPFor(addr *net.UDPAddr) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
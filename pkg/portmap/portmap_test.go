@@ -0,0 +1,307 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+// testGateway шлюз NAT-PMP/PCP на loopback, выдающий внешний адрес 203.0.113.7
+type testGateway struct {
+	conn *net.UDPConn
+	pcp  bool // false — на PCP запросы отвечает как сервер только NAT-PMP
+
+	mu       sync.Mutex
+	requests []uint32 // запрошенные lifetime отображений
+}
+
+func startTestGateway(t *testing.T, pcp bool) *testGateway {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() }) //nolint:errcheck // test cleanup
+	g := &testGateway{conn: conn, pcp: pcp}
+	go g.serve()
+	return g
+}
+
+func (g *testGateway) addr() string {
+	return g.conn.LocalAddr().String()
+}
+
+func (g *testGateway) lifetimes() []uint32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]uint32(nil), g.requests...)
+}
+
+func (g *testGateway) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		var resp []byte
+		switch {
+		case n >= 2 && req[0] == natpmpVersion && req[1] == natpmpOpExternalIP:
+			resp = []byte{0, 128, 0, 0, 0, 0, 0, 1, 203, 0, 113, 7}
+		case n >= 12 && req[0] == natpmpVersion && req[1] == natpmpOpMapUDP:
+			lifetime := binary.BigEndian.Uint32(req[8:12])
+			g.record(lifetime)
+			resp = make([]byte, 16)
+			resp[1] = 129
+			copy(resp[8:10], req[4:6])
+			binary.BigEndian.PutUint16(resp[10:12], binary.BigEndian.Uint16(req[4:6])+1)
+			binary.BigEndian.PutUint32(resp[12:16], lifetime)
+		case n >= pcpHeaderLen+pcpMapLen && req[0] == pcpVersion:
+			if !g.pcp {
+				resp = []byte{0, 0x80 | req[1], 0, 1}
+				break
+			}
+			lifetime := binary.BigEndian.Uint32(req[4:8])
+			g.record(lifetime)
+			resp = make([]byte, pcpHeaderLen+pcpMapLen)
+			resp[0] = pcpVersion
+			resp[1] = pcpResponseFlag | pcpOpMap
+			binary.BigEndian.PutUint32(resp[4:8], lifetime)
+			copy(resp[pcpHeaderLen:], req[pcpHeaderLen:])
+			body := resp[pcpHeaderLen:]
+			binary.BigEndian.PutUint16(body[18:20], 40000)
+			copy(body[20:36], net.IPv4(203, 0, 113, 7).To16())
+		default:
+			continue
+		}
+		_, _ = g.conn.WriteToUDP(resp, from) //nolint:errcheck // test gateway
+	}
+}
+
+func (g *testGateway) record(lifetime uint32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, lifetime)
+}
+
+func TestClient_NATPMPMappingAndRemoval(t *testing.T) {
+	gateway := startTestGateway(t, false)
+
+	client := NewClient(&Config{
+		Protocols:      []Protocol{ProtocolPCP, ProtocolNATPMP},
+		Gateway:        gateway.addr(),
+		Lifetime:       time.Hour,
+		RequestTimeout: time.Second,
+	}, testLogger{})
+	if err := client.Start(41641); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	mapping := waitForMapping(t, client)
+	if mapping.Protocol != ProtocolNATPMP {
+		t.Errorf("Expected fallback to NAT-PMP, got %s", mapping.Protocol)
+	}
+	if mapping.External.String() != "203.0.113.7:41642" {
+		t.Errorf("Unexpected external address %s", mapping.External)
+	}
+	if mapping.Lifetime != time.Hour {
+		t.Errorf("Expected granted lifetime 1h, got %s", mapping.Lifetime)
+	}
+
+	client.Stop()
+	if client.Mapping() != nil {
+		t.Error("Expected mapping to be cleared after Stop")
+	}
+	lifetimes := gateway.lifetimes()
+	if len(lifetimes) != 2 || lifetimes[1] != 0 {
+		t.Errorf("Expected mapping request followed by deletion, got lifetimes %v", lifetimes)
+	}
+}
+
+func TestClient_PCPRenewal(t *testing.T) {
+	gateway := startTestGateway(t, true)
+
+	client := NewClient(&Config{
+		Protocols:      []Protocol{ProtocolPCP},
+		Gateway:        gateway.addr(),
+		Lifetime:       2 * time.Second,
+		RequestTimeout: time.Second,
+	}, testLogger{})
+	if err := client.Start(41641); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer client.Stop()
+
+	mapping := waitForMapping(t, client)
+	if mapping.Protocol != ProtocolPCP || mapping.External.String() != "203.0.113.7:40000" {
+		t.Fatalf("Unexpected mapping %s %s", mapping.Protocol, mapping.External)
+	}
+
+	// Продление на середине аренды (не раньше чем через секунду)
+	deadline := time.Now().Add(5 * time.Second)
+	for client.GetMetrics()["renewals"].(int64) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Mapping was not renewed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if renewed := client.Mapping(); renewed == nil || !renewed.ExpiresAt.After(mapping.ExpiresAt) {
+		t.Error("Expected renewal to extend the mapping")
+	}
+}
+
+func TestClient_InvalidConfig(t *testing.T) {
+	client := NewClient(&Config{Protocols: []Protocol{"igd"}}, testLogger{})
+	if err := client.Start(41641); err == nil {
+		t.Error("Expected error for unsupported protocol")
+	}
+	if err := client.Start(0); err == nil {
+		t.Error("Expected error for invalid port")
+	}
+}
+
+func waitForMapping(t *testing.T, client *Client) *Mapping {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if mapping := client.Mapping(); mapping != nil {
+			return mapping
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for port mapping")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+const testRootDesc = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func TestUPnPMapper(t *testing.T) {
+	var mu sync.Mutex
+	var actions []string
+	var leases []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rootDesc.xml" {
+			_, _ = io.WriteString(w, testRootDesc) //nolint:errcheck // test server
+			return
+		}
+		if r.URL.Path != "/ctl/IPConn" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body) //nolint:errcheck // test server
+		action := r.Header.Get("SOAPAction")
+		action = strings.Trim(action[strings.Index(action, "#")+1:], `"`)
+		mu.Lock()
+		actions = append(actions, action)
+		mu.Unlock()
+
+		envelope := func(inner string) string {
+			return `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+				inner + `</s:Body></s:Envelope>`
+		}
+		switch action {
+		case "GetExternalIPAddress":
+			_, _ = io.WriteString(w, envelope(`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+				`<NewExternalIPAddress>198.51.100.20</NewExternalIPAddress></u:GetExternalIPAddressResponse>`)) //nolint:errcheck // test server
+		case "AddPortMapping":
+			lease := string(body)
+			lease = lease[strings.Index(lease, "<NewLeaseDuration>")+len("<NewLeaseDuration>"):]
+			lease = lease[:strings.Index(lease, "<")]
+			mu.Lock()
+			leases = append(leases, lease)
+			mu.Unlock()
+			if lease != "0" {
+				// Шлюз поддерживает только постоянные отображения
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, envelope(`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>`+
+					`<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>725</errorCode>`+
+					`<errorDescription>OnlyPermanentLeasesSupported</errorDescription></UPnPError></detail></s:Fault>`)) //nolint:errcheck // test server
+				return
+			}
+			_, _ = io.WriteString(w, envelope(`<u:AddPortMappingResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"/>`)) //nolint:errcheck // test server
+		case "DeletePortMapping":
+			_, _ = io.WriteString(w, envelope(`<u:DeletePortMappingResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"/>`)) //nolint:errcheck // test server
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	m := newUPnPMapper("test")
+	m.location = server.URL + "/rootDesc.xml"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	mapping, err := m.addMapping(ctx, 41641, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("addMapping failed: %v", err)
+	}
+	if mapping.External.String() != "198.51.100.20:41641" {
+		t.Errorf("Unexpected external address %s", mapping.External)
+	}
+	if mapping.Lifetime != 0 || !mapping.ExpiresAt.After(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expected permanent mapping renewed hourly, got lifetime %s expires %s", mapping.Lifetime, mapping.ExpiresAt)
+	}
+	if err := m.deleteMapping(ctx, mapping); err != nil {
+		t.Fatalf("deleteMapping failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(leases, ",") != "3600,0" {
+		t.Errorf("Expected lease retry with permanent mapping, got %v", leases)
+	}
+	if actions[len(actions)-1] != "DeletePortMapping" {
+		t.Errorf("Expected DeletePortMapping last, got %v", actions)
+	}
+}
+
+func TestSSDPLocation(t *testing.T) {
+	resp := "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: " + ssdpSearchTarget +
+		"\r\nLocation: http://192.168.1.1:5000/rootDesc.xml\r\n\r\n"
+	if got := ssdpLocation([]byte(resp)); got != "http://192.168.1.1:5000/rootDesc.xml" {
+		t.Errorf("Unexpected location %q", got)
+	}
+	if got := ssdpLocation([]byte("NOTIFY * HTTP/1.1\r\nLOCATION: http://x/\r\n\r\n")); got != "" {
+		t.Errorf("Expected no location for non-response, got %q", got)
+	}
+}
@@ -0,0 +1,411 @@
+package portmap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr           = "239.255.255.250:1900"
+	ssdpSearchTarget   = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+	upnpMaxBodySize    = 1 << 20
+	upnpErrConflict    = 718 // ConflictInMappingEntry
+	upnpErrPermanent   = 725 // OnlyPermanentLeasesSupported
+	upnpFallbackOffset = 1000
+)
+
+// upnpServiceTypes поддерживаемые WAN сервисы в порядке предпочтения
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpMapper отображение через UPnP IGD
+type upnpMapper struct {
+	description string
+	httpClient  *http.Client
+
+	// location адрес описания устройства; пусто — поиск через SSDP
+	location    string
+	controlURL  string
+	serviceType string
+}
+
+func newUPnPMapper(description string) *upnpMapper {
+	return &upnpMapper{
+		description: description,
+		httpClient:  &http.Client{},
+	}
+}
+
+func (m *upnpMapper) addMapping(ctx context.Context, internalPort int, previous *Mapping,
+	lifetime time.Duration) (*Mapping, error) {
+	if err := m.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	externalIP, err := m.externalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	internalIP, err := m.internalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	externalPort := internalPort
+	if previous != nil {
+		externalPort = previous.External.Port
+	}
+	granted, err := m.addPortMapping(ctx, internalIP, internalPort, externalPort, lifetime)
+	if isUPnPError(err, upnpErrConflict) && previous == nil {
+		// Порт уже занят другим клиентом — пробуем соседний диапазон
+		externalPort = 1024 + (internalPort+upnpFallbackOffset)%(65535-1024)
+		granted, err = m.addPortMapping(ctx, internalIP, internalPort, externalPort, lifetime)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &Mapping{
+		Protocol:     ProtocolUPnP,
+		InternalPort: internalPort,
+		External:     &net.UDPAddr{IP: externalIP, Port: externalPort},
+		Lifetime:     granted,
+		ExpiresAt:    time.Now().Add(granted),
+	}
+	if granted == 0 {
+		// Постоянное отображение: продлеваем с запрошенной периодичностью, чтобы заметить его потерю
+		mapping.ExpiresAt = time.Now().Add(lifetime)
+	}
+	return mapping, nil
+}
+
+func (m *upnpMapper) deleteMapping(ctx context.Context, mapping *Mapping) error {
+	_, err := m.soap(ctx, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(mapping.External.Port)},
+		{"NewProtocol", "UDP"},
+	})
+	return err
+}
+
+// addPortMapping выполняет AddPortMapping; шлюзам без поддержки аренды
+// отправляется постоянное отображение. Возвращает выданный lifetime (0 — постоянное)
+func (m *upnpMapper) addPortMapping(ctx context.Context, internalIP net.IP, internalPort, externalPort int,
+	lifetime time.Duration) (time.Duration, error) {
+	args := func(lease time.Duration) [][2]string {
+		return [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(externalPort)},
+			{"NewProtocol", "UDP"},
+			{"NewInternalPort", strconv.Itoa(internalPort)},
+			{"NewInternalClient", internalIP.String()},
+			{"NewEnabled", "1"},
+			{"NewPortMappingDescription", m.description},
+			{"NewLeaseDuration", strconv.Itoa(int(lease / time.Second))},
+		}
+	}
+
+	_, err := m.soap(ctx, "AddPortMapping", args(lifetime))
+	if isUPnPError(err, upnpErrPermanent) {
+		_, err = m.soap(ctx, "AddPortMapping", args(0))
+		return 0, err
+	}
+	return lifetime, err
+}
+
+// externalIP запрашивает внешний адрес через GetExternalIPAddress
+func (m *upnpMapper) externalIP(ctx context.Context) (net.IP, error) {
+	values, err := m.soap(ctx, "GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(values["NewExternalIPAddress"]))
+	if ip == nil {
+		return nil, fmt.Errorf("UPnP gateway returned invalid external address %q", values["NewExternalIPAddress"])
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return ip, nil
+}
+
+// internalClient возвращает локальный адрес, с которого виден control URL шлюза
+func (m *upnpMapper) internalClient() (net.IP, error) {
+	u, err := url.Parse(m.controlURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPnP control URL: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve UPnP gateway: %w", err)
+	}
+	ip, err := localIPFor(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine client address: %w", err)
+	}
+	return ip, nil
+}
+
+// discover находит шлюз через SSDP и его WAN сервис; результат кешируется
+func (m *upnpMapper) discover(ctx context.Context) error {
+	if m.controlURL != "" {
+		return nil
+	}
+	if m.location == "" {
+		location, err := ssdpSearch(ctx)
+		if err != nil {
+			return err
+		}
+		m.location = location
+	}
+
+	controlURL, serviceType, err := m.fetchDescription(ctx)
+	if err != nil {
+		m.location = ""
+		return err
+	}
+	m.controlURL, m.serviceType = controlURL, serviceType
+	return nil
+}
+
+// ssdpSearch отправляет M-SEARCH и возвращает LOCATION первого ответившего IGD
+func ssdpSearch(ctx context.Context) (string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+	request := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + ssdpSearchTarget + "\r\n\r\n"
+
+	deadline := time.Now().Add(3 * time.Second)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetReadDeadline(deadline) //nolint:errcheck // read fails without a deadline anyway
+
+	// Запрос дублируется: SSDP работает поверх UDP без подтверждений
+	for range 2 {
+		if _, err := conn.WriteToUDP([]byte(request), dst); err != nil {
+			return "", fmt.Errorf("failed to send SSDP search: %w", err)
+		}
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "", fmt.Errorf("no UPnP gateway found")
+		}
+		if location := ssdpLocation(buf[:n]); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// ssdpLocation извлекает заголовок LOCATION из ответа SSDP
+func ssdpLocation(resp []byte) string {
+	reader := bufio.NewReader(bytes.NewReader(resp))
+	statusLine, err := reader.ReadString('\n')
+	if err != nil || !strings.Contains(statusLine, " 200 ") {
+		return ""
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "location") {
+			return strings.TrimSpace(value)
+		}
+		if err != nil {
+			return ""
+		}
+	}
+}
+
+// upnpDevice описание устройства UPnP (вложенные устройства обходятся рекурсивно)
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+// fetchDescription загружает описание IGD и возвращает control URL WAN сервиса
+func (m *upnpMapper) fetchDescription(ctx context.Context) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.location, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid UPnP location %q: %w", m.location, err)
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch UPnP description: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to fetch UPnP description: status %d", resp.StatusCode)
+	}
+
+	var root upnpRoot
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, upnpMaxBodySize)).Decode(&root); err != nil {
+		return "", "", fmt.Errorf("failed to parse UPnP description: %w", err)
+	}
+
+	base, err := url.Parse(m.location)
+	if err != nil {
+		return "", "", err
+	}
+	if root.URLBase != "" {
+		if parsed, err := url.Parse(root.URLBase); err == nil {
+			base = parsed
+		}
+	}
+
+	for _, serviceType := range upnpServiceTypes {
+		if controlURL := findControlURL(&root.Device, serviceType); controlURL != "" {
+			ref, err := url.Parse(controlURL)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid UPnP control URL %q: %w", controlURL, err)
+			}
+			return base.ResolveReference(ref).String(), serviceType, nil
+		}
+	}
+	return "", "", fmt.Errorf("UPnP device has no WAN connection service")
+}
+
+func findControlURL(device *upnpDevice, serviceType string) string {
+	for _, service := range device.Services {
+		if strings.TrimSpace(service.ServiceType) == serviceType {
+			return strings.TrimSpace(service.ControlURL)
+		}
+	}
+	for i := range device.Devices {
+		if controlURL := findControlURL(&device.Devices[i], serviceType); controlURL != "" {
+			return controlURL
+		}
+	}
+	return ""
+}
+
+// upnpError ошибка SOAP действия с кодом UPnP
+type upnpError struct {
+	action      string
+	code        int
+	description string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("UPnP %s failed: %d %s", e.action, e.code, e.description)
+}
+
+func isUPnPError(err error, code int) bool {
+	upnpErr, ok := err.(*upnpError)
+	return ok && upnpErr.code == code
+}
+
+// soap выполняет действие WAN сервиса и возвращает аргументы ответа
+func (m *upnpMapper) soap(ctx context.Context, action string, args [][2]string) (map[string]string, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, m.serviceType)
+	for _, arg := range args {
+		body.WriteString("<" + arg[0] + ">")
+		_ = xml.EscapeText(&body, []byte(arg[1])) //nolint:errcheck // bytes.Buffer never fails
+		body.WriteString("</" + arg[0] + ">")
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.controlURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create UPnP request: %w", err)
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, m.serviceType, action))
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("UPnP %s request failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	values, fault, err := parseSOAPResponse(io.LimitReader(resp.Body, upnpMaxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UPnP %s response: %w", action, err)
+	}
+	if fault != nil {
+		fault.action = action
+		return nil, fault
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UPnP %s failed: status %d", action, resp.StatusCode)
+	}
+	return values, nil
+}
+
+// parseSOAPResponse собирает текстовые элементы ответа; для SOAP Fault возвращает upnpError
+func parseSOAPResponse(r io.Reader) (map[string]string, *upnpError, error) {
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(r)
+	var current string
+	var isFault bool
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			current = t.Name.Local
+			if current == "Fault" {
+				isFault = true
+			}
+		case xml.CharData:
+			if current != "" {
+				values[current] += string(t)
+			}
+		case xml.EndElement:
+			current = ""
+		}
+	}
+
+	if !isFault {
+		return values, nil, nil
+	}
+	code, _ := strconv.Atoi(strings.TrimSpace(values["errorCode"])) //nolint:errcheck // zero code for malformed faults
+	return nil, &upnpError{code: code, description: strings.TrimSpace(values["errorDescription"])}, nil
+}
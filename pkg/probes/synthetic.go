@@ -3,7 +3,9 @@ package probes
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -151,6 +153,12 @@ func (spm *SyntheticProbeManager) runProbes() {
 	}
 }
 
+// RunOnce синхронно выполняет все включенные probe (например, при старте,
+// чтобы не ждать первого тика)
+func (spm *SyntheticProbeManager) RunOnce() {
+	spm.runAllProbes()
+}
+
 // runAllProbes запускает все probe
 func (spm *SyntheticProbeManager) runAllProbes() {
 	spm.mu.RLock()
@@ -177,7 +185,11 @@ func (spm *SyntheticProbeManager) runAllProbes() {
 
 // runProbe запускает один probe
 func (spm *SyntheticProbeManager) runProbe(probe *ProbeConfig) {
-	ctx, cancel := context.WithTimeout(context.Background(), probe.Timeout)
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = spm.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	start := time.Now()
@@ -189,6 +201,10 @@ func (spm *SyntheticProbeManager) runProbe(probe *ProbeConfig) {
 
 // executeProbe выполняет probe
 func (spm *SyntheticProbeManager) executeProbe(ctx context.Context, probe *ProbeConfig) bool {
+	if probe.Mode == "tcp" {
+		return spm.executeTCPProbe(ctx, probe)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, probe.Method, probe.URL, nil)
 	if err != nil {
 		spm.logger.Error("Failed to create probe request",
@@ -216,6 +232,27 @@ func (spm *SyntheticProbeManager) executeProbe(ctx context.Context, probe *Probe
 	return success
 }
 
+// executeTCPProbe измеряет время установления TCP соединения (tcp://host:port)
+func (spm *SyntheticProbeManager) executeTCPProbe(ctx context.Context, probe *ProbeConfig) bool {
+	addr := probe.URL
+	if u, err := url.Parse(probe.URL); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		spm.logger.Debug("TCP probe failed",
+			"probe", probe.Name,
+			"address", addr,
+			"error", err)
+		return false
+	}
+	_ = conn.Close()
+
+	return true
+}
+
 // updateResult обновляет результат probe
 func (spm *SyntheticProbeManager) updateResult(name string, success bool, latency time.Duration) {
 	spm.mu.Lock()
@@ -254,9 +291,11 @@ func (spm *SyntheticProbeManager) GetResults() map[string]*ProbeResult {
 	spm.mu.RLock()
 	defer spm.mu.RUnlock()
 
+	// Возвращаем копии, чтобы вызывающий код не гонялся с updateResult
 	results := make(map[string]*ProbeResult)
 	for name, result := range spm.results {
-		results[name] = result
+		r := *result
+		results[name] = &r
 	}
 
 	return results
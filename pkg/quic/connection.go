@@ -3,7 +3,9 @@ package quic
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -14,6 +16,12 @@ import (
 type QUICConnection struct {
 	conn *quic.Conn
 
+	// transport используется, когда QUIC работает поверх внешнего сокета (AcceptOn)
+	transport *quic.Transport
+
+	// connHandler заменяет echo-обработчик входящих соединений
+	connHandler ConnectionHandler
+
 	streams map[string]*quic.Stream
 	mu      sync.RWMutex
 
@@ -31,6 +39,9 @@ type QUICConnection struct {
 	cancel context.CancelFunc
 }
 
+// ConnectionHandler takes ownership of an incoming QUIC connection
+type ConnectionHandler func(conn *quic.Conn)
+
 // Logger interface for QUIC connection logging
 type Logger interface {
 	Info(msg string, fields ...interface{})
@@ -94,6 +105,27 @@ func (q *QUICConnection) SetServerTLSCert(cert tls.Certificate) {
 	q.tlsServer.Certificates = []tls.Certificate{cert}
 }
 
+// SetClientTLSCert sets the certificate presented to servers that request one
+func (q *QUICConnection) SetClientTLSCert(cert tls.Certificate) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tlsClient.Certificates = []tls.Certificate{cert}
+}
+
+// SetPeerCertificateVerifier sets a custom check of the remote certificate chain
+// (e.g. key pinning) on both sides; the server then requests a client certificate.
+// The verifier receives an empty chain if the client sent no certificate.
+func (q *QUICConnection) SetPeerCertificateVerifier(verify func(rawCerts [][]byte) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	callback := func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verify(rawCerts)
+	}
+	q.tlsClient.VerifyPeerCertificate = callback
+	q.tlsServer.VerifyPeerCertificate = callback
+	q.tlsServer.ClientAuth = tls.RequestClientCert
+}
+
 // SetClientServerName sets SNI / hostname verification target
 func (q *QUICConnection) SetClientServerName(serverName string) {
 	q.mu.Lock()
@@ -123,6 +155,24 @@ func (q *QUICConnection) SetQUICConfig(cfg *quic.Config) {
 	q.qcfg = cfg
 }
 
+// EnableDatagrams enables unreliable QUIC datagrams (RFC 9221) on connections
+// established after the call; both endpoints must enable them
+func (q *QUICConnection) EnableDatagrams() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cfg := q.qcfg.Clone()
+	cfg.EnableDatagrams = true
+	q.qcfg = cfg
+}
+
+// SetConnectionHandler sets the handler for connections accepted by Listen;
+// without a handler incoming streams are echoed back (testing only)
+func (q *QUICConnection) SetConnectionHandler(h ConnectionHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.connHandler = h
+}
+
 // -------- client side --------
 
 // Connect establishes a QUIC connection to the specified address
@@ -151,6 +201,34 @@ func (q *QUICConnection) Connect(ctx context.Context, addr string) error {
 	return nil
 }
 
+// DialOn establishes a QUIC connection over an existing packet socket
+// (e.g. the ICE-selected candidate pair) instead of a new UDP socket
+func (q *QUICConnection) DialOn(ctx context.Context, pconn net.PacketConn, addr net.Addr) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tlsClient.ServerName == "" && !q.tlsClient.InsecureSkipVerify {
+		return fmt.Errorf("TLS client ServerName is empty; set it or enable InsecureSkipVerify (not recommended)")
+	}
+
+	q.logger.Info("Connecting to QUIC peer over socket", "address", addr, "alpn", q.tlsClient.NextProtos)
+
+	tr := &quic.Transport{Conn: pconn}
+	conn, err := tr.Dial(ctx, addr, q.tlsClient, q.qcfg)
+	if err != nil {
+		_ = tr.Close()
+		return fmt.Errorf("failed to connect to QUIC peer: %w", err)
+	}
+
+	q.transport = tr
+	q.conn = conn
+	q.logger.Info("QUIC connection established", "address", addr)
+
+	go q.monitorConnection(q.ctx)
+
+	return nil
+}
+
 // -------- server side --------
 
 // Listen starts listening for incoming QUIC connections on addr (e.g., ":5553")
@@ -177,6 +255,43 @@ func (q *QUICConnection) Listen(ctx context.Context, addr string) error {
 	return nil
 }
 
+// AcceptOn accepts a single QUIC connection on an existing packet socket
+// (e.g. the ICE-selected candidate pair); it becomes the current connection
+func (q *QUICConnection) AcceptOn(ctx context.Context, pconn net.PacketConn) error {
+	q.mu.Lock()
+	if len(q.tlsServer.Certificates) == 0 {
+		q.mu.Unlock()
+		return fmt.Errorf("server TLS config has no certificates; set via SetServerTLSCert/SetServerTLSConfig")
+	}
+	tr := &quic.Transport{Conn: pconn}
+	listener, err := tr.Listen(q.tlsServer, q.qcfg)
+	if err != nil {
+		q.mu.Unlock()
+		return fmt.Errorf("failed to start QUIC listener on socket: %w", err)
+	}
+	q.transport = tr
+	q.mu.Unlock()
+
+	// Закрытие listener не затрагивает уже принятое соединение
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	q.logger.Info("Waiting for QUIC connection on socket", "local_addr", pconn.LocalAddr())
+	conn, err := listener.Accept(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to accept QUIC connection: %w", err)
+	}
+
+	q.mu.Lock()
+	q.conn = conn
+	q.mu.Unlock()
+
+	q.logger.Info("QUIC connection accepted", "remote_addr", conn.RemoteAddr())
+	go q.monitorConnection(q.ctx)
+	return nil
+}
+
 // -------- streams --------
 
 // CreateStream creates a new bidirectional stream
@@ -230,6 +345,46 @@ func (q *QUICConnection) AcceptStream(ctx context.Context) (*quic.Stream, error)
 	return stream, nil
 }
 
+// -------- datagrams --------
+
+// SupportsDatagrams returns true if both endpoints negotiated QUIC datagrams
+func (q *QUICConnection) SupportsDatagrams() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.conn != nil && q.conn.ConnectionState().SupportsDatagrams
+}
+
+// SendDatagram sends an unreliable datagram on the current connection
+func (q *QUICConnection) SendDatagram(payload []byte) error {
+	q.mu.RLock()
+	conn := q.conn
+	q.mu.RUnlock()
+
+	if conn == nil {
+		return fmt.Errorf("QUIC connection not established")
+	}
+	if err := conn.SendDatagram(payload); err != nil {
+		return fmt.Errorf("failed to send datagram: %w", err)
+	}
+	return nil
+}
+
+// ReceiveDatagram blocks until a datagram arrives on the current connection
+func (q *QUICConnection) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	q.mu.RLock()
+	conn := q.conn
+	q.mu.RUnlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("QUIC connection not established")
+	}
+	payload, err := conn.ReceiveDatagram(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive datagram: %w", err)
+	}
+	return payload, nil
+}
+
 // GetStream returns a stream by ID
 func (q *QUICConnection) GetStream(streamID string) (*quic.Stream, bool) {
 	q.mu.RLock()
@@ -264,6 +419,13 @@ func (q *QUICConnection) Close() error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	if q.transport != nil {
+		defer func() {
+			_ = q.transport.Close()
+			q.transport = nil
+		}()
+	}
+
 	if q.conn == nil {
 		return nil
 	}
@@ -381,6 +543,14 @@ func (q *QUICConnection) acceptConnections(ctx context.Context, listener *quic.L
 
 // handleIncomingConnection handles an incoming QUIC connection
 func (q *QUICConnection) handleIncomingConnection(conn *quic.Conn) {
+	q.mu.RLock()
+	handler := q.connHandler
+	q.mu.RUnlock()
+	if handler != nil {
+		handler(conn)
+		return
+	}
+
 	defer func() {
 		_ = conn.CloseWithError(0, "connection closed") // cleanup
 	}()
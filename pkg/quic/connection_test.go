@@ -379,3 +379,42 @@ func TestContextCancellation(t *testing.T) {
 		// This test is more about ensuring the context exists and can be canceled
 	}
 }
+
+func TestAcceptOnPacketConn(t *testing.T) {
+	cert, err := generateTestCert()
+	if err != nil {
+		t.Fatalf("Failed to generate test cert: %v", err)
+	}
+
+	pconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open UDP socket: %v", err)
+	}
+	defer pconn.Close()
+
+	server := NewQUICConnection(&mockLogger{})
+	server.SetServerTLSCert(cert)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- server.AcceptOn(ctx, pconn)
+	}()
+
+	client := NewQUICConnection(&mockLogger{})
+	client.SetInsecureSkipVerify(true)
+	defer client.Close()
+	if err := client.Connect(ctx, pconn.LocalAddr().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err := <-accepted; err != nil {
+		t.Fatalf("AcceptOn failed: %v", err)
+	}
+	if !server.IsConnected() {
+		t.Error("Expected accepted connection to become the current connection")
+	}
+}
@@ -2,36 +2,53 @@ package relay
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/config"
+	autoswitch "github.com/2gc-dev/cloudbridge-client/pkg/transport"
 	"github.com/2gc-dev/cloudbridge-client/pkg/types"
 )
 
-// TransportMode represents the current transport mode
-type TransportMode string
+// TransportMode represents the current data-plane transport mode
+type TransportMode = autoswitch.Mode
 
 const (
-	// TransportModeQUIC represents QUIC transport mode
-	TransportModeQUIC TransportMode = "quic"
-	// TransportModeWireGuard represents WireGuard transport mode
-	TransportModeWireGuard TransportMode = "wireguard"
+	// TransportModeQUIC represents the direct path: tunnels reach their targets over the host network
+	// and QUIC reachability of the relay is checked
+	TransportModeQUIC TransportMode = autoswitch.ModeQUIC
+	// TransportModeWireGuard represents WireGuard transport mode: tunnel traffic is routed
+	// through the WireGuard interface to the relay
+	TransportModeWireGuard TransportMode = autoswitch.ModeWireGuard
 )
 
-// AutoSwitchManager manages automatic switching between QUIC and WireGuard transports
+// dataPlaneModes lists the transports the client can actually switch its data plane to.
+// MASQUE, gRPC and WebSocket carry only relay control traffic and are not switch targets.
+var dataPlaneModes = []TransportMode{TransportModeQUIC, TransportModeWireGuard}
+
+// wireGuardMaxHandshakeAge is the maximum age of the last WireGuard handshake for a healthy tunnel
+// (WireGuard rekeys every 2 minutes while traffic flows)
+const wireGuardMaxHandshakeAge = 3 * time.Minute
+
+// AutoSwitchManager switches the client data plane between the direct path (QUIC) and WireGuard.
+// Policy (priority, hysteresis, cooldown) is implemented by the transport package;
+// this type provides per-transport health checkers and performs the switch itself:
+// bringing WireGuard up or down and migrating live tunnels to the new transport.
 type AutoSwitchManager struct {
-	config            *types.Config
-	currentMode       TransportMode
-	wgManager         *WireGuardManager
-	logger            *relayLogger
-	mu                sync.RWMutex
-	ctx               context.Context
-	cancel            context.CancelFunc
-	healthCheckTicker *time.Ticker
-	switchCallbacks   []func(from, to TransportMode)
-	// healthCheckFunc allows overriding health check for testing
+	config    *types.Config
+	engine    *autoswitch.AutoSwitchManager
+	client    *Client
+	wgManager *WireGuardManager
+	logger    *relayLogger
+	mu        sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	// healthCheckFunc allows overriding QUIC health check for testing
 	healthCheckFunc func() bool
 }
 
@@ -39,49 +56,111 @@ type AutoSwitchManager struct {
 func NewAutoSwitchManager(config *types.Config, logger *relayLogger) *AutoSwitchManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &AutoSwitchManager{
-		config:          config,
-		currentMode:     TransportModeQUIC, // Start with QUIC by default
-		logger:          logger,
-		ctx:             ctx,
-		cancel:          cancel,
-		switchCallbacks: make([]func(from, to TransportMode), 0),
+	policy, ignored := autoSwitchConfigFromTypes(config)
+	if len(ignored) > 0 {
+		logger.Warn("Ignoring auto-switch modes without a data-plane transport", "modes", ignored)
+	}
+
+	asm := &AutoSwitchManager{
+		config: config,
+		engine: autoswitch.NewAutoSwitchManager(policy, &transportLoggerAdapter{logger: logger}),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
 	}
+	asm.engine.SetSwitchHandler(asm.applySwitch)
+	return asm
 }
 
-// Start starts the AutoSwitchManager
-func (asm *AutoSwitchManager) Start() error {
+// autoSwitchConfigFromTypes converts client configuration into the auto-switch policy configuration.
+// Modes without a data-plane transport are dropped from the order and returned as ignored.
+// WireGuard is a standby mode: its interface is only brought up on switch.
+func autoSwitchConfigFromTypes(cfg *types.Config) (*autoswitch.AutoSwitchConfig, []string) {
+	policy := autoswitch.DefaultAutoSwitchConfig()
+	policy.Order = slices.Clone(dataPlaneModes)
+	policy.Standby = []autoswitch.Mode{TransportModeWireGuard}
+	s := cfg.AutoSwitch
+
+	var ignored []string
+	if len(s.Order) > 0 {
+		order := make([]autoswitch.Mode, 0, len(s.Order))
+		for _, mode := range s.Order {
+			if !slices.Contains(dataPlaneModes, autoswitch.Mode(mode)) {
+				ignored = append(ignored, mode)
+				continue
+			}
+			order = append(order, autoswitch.Mode(mode))
+		}
+		if len(order) > 0 {
+			policy.Order = order
+		}
+	}
+	if s.CheckInterval > 0 {
+		policy.CheckInterval = s.CheckInterval
+	}
+	if s.CheckTimeout > 0 {
+		policy.CheckTimeout = s.CheckTimeout
+	}
+	if s.Cooldown > 0 {
+		policy.Cooldown = s.Cooldown
+	}
+	if s.MinDwell > 0 {
+		policy.MinDwell = s.MinDwell
+	}
+	if s.FailureThreshold > 0 {
+		policy.FailureThreshold = s.FailureThreshold
+	}
+	if s.RecoveryThreshold > 0 {
+		policy.RecoveryThreshold = s.RecoveryThreshold
+	}
+	return policy, ignored
+}
+
+// attachClient connects the manager to the client whose transports it checks and whose tunnels it migrates
+func (asm *AutoSwitchManager) attachClient(c *Client) {
 	asm.mu.Lock()
 	defer asm.mu.Unlock()
+	asm.client = c
+}
 
-	if !asm.config.WireGuard.Enabled {
+// Start starts the AutoSwitchManager
+func (asm *AutoSwitchManager) Start() error {
+	asm.mu.Lock()
+	if asm.config.WireGuard.Enabled {
+		// Initialize WireGuard manager
+		wgManager, err := NewWireGuardManager(asm.config, asm.logger)
+		if err != nil {
+			asm.mu.Unlock()
+			return fmt.Errorf("failed to create WireGuard manager: %w", err)
+		}
+		asm.wgManager = wgManager
+	} else {
 		asm.logger.Info("WireGuard fallback disabled in configuration")
-		return nil
 	}
+	asm.mu.Unlock()
+
+	asm.RefreshHealthCheckers()
 
-	// Initialize WireGuard manager
-	wgManager, err := NewWireGuardManager(asm.config, asm.logger)
-	if err != nil {
-		return fmt.Errorf("failed to create WireGuard manager: %w", err)
+	if !asm.config.AutoSwitch.Enabled {
+		asm.logger.Info("Transport auto-switch disabled in configuration")
+		return nil
 	}
-	asm.wgManager = wgManager
 
-	// Start health checking
-	asm.startHealthChecking()
+	if err := asm.engine.Start(); err != nil {
+		return fmt.Errorf("failed to start transport auto-switch: %w", err)
+	}
 
-	asm.logger.Info("AutoSwitchManager started", "initial_mode", asm.currentMode)
+	asm.logger.Info("AutoSwitchManager started", "initial_mode", asm.GetCurrentMode())
 	return nil
 }
 
 // Stop stops the AutoSwitchManager
 func (asm *AutoSwitchManager) Stop() error {
+	asm.engine.Stop()
+
 	asm.mu.Lock()
 	defer asm.mu.Unlock()
 
-	if asm.healthCheckTicker != nil {
-		asm.healthCheckTicker.Stop()
-	}
-
 	if asm.wgManager != nil {
 		if err := asm.wgManager.Stop(); err != nil {
 			asm.logger.Error("Failed to stop WireGuard manager", "error", err)
@@ -95,155 +174,163 @@ func (asm *AutoSwitchManager) Stop() error {
 
 // GetCurrentMode returns the current transport mode
 func (asm *AutoSwitchManager) GetCurrentMode() TransportMode {
-	asm.mu.RLock()
-	defer asm.mu.RUnlock()
-	return asm.currentMode
+	return asm.engine.GetCurrentMode()
+}
+
+// LastSwitch returns the time of the last transport switch (zero if none happened)
+func (asm *AutoSwitchManager) LastSwitch() time.Time {
+	return asm.engine.LastSwitch()
 }
 
 // AddSwitchCallback adds a callback to be called when transport mode switches
 func (asm *AutoSwitchManager) AddSwitchCallback(callback func(from, to TransportMode)) {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
-	asm.switchCallbacks = append(asm.switchCallbacks, callback)
+	asm.engine.AddSwitchCallback(callback)
 }
 
-// startHealthChecking starts periodic health checking of QUIC connection
-func (asm *AutoSwitchManager) startHealthChecking() {
-	// Check every 10 seconds
-	asm.healthCheckTicker = time.NewTicker(10 * time.Second)
-
-	go func() {
-		for {
-			select {
-			case <-asm.ctx.Done():
-				return
-			case <-asm.healthCheckTicker.C:
-				asm.performHealthCheck()
-			}
-		}
-	}()
+// GetMetrics returns auto-switch policy state and per-transport health
+func (asm *AutoSwitchManager) GetMetrics() map[string]interface{} {
+	return asm.engine.GetMetrics()
 }
 
-// performHealthCheck checks the health of current transport and switches if needed
-func (asm *AutoSwitchManager) performHealthCheck() {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
-
-	switch asm.currentMode {
-	case TransportModeQUIC:
-		if !asm.isQUICHealthy() {
-			asm.logger.Warn("QUIC connection unhealthy, switching to WireGuard")
-			if err := asm.switchToWireGuard(); err != nil {
-				asm.logger.Error("Failed to switch to WireGuard", "error", err)
-			}
-		}
-	case TransportModeWireGuard:
-		if asm.isQUICHealthy() {
-			asm.logger.Info("QUIC connection restored, switching back from WireGuard")
-			if err := asm.switchToQUIC(); err != nil {
-				asm.logger.Error("Failed to switch back to QUIC", "error", err)
+// RefreshHealthCheckers (re)registers health checkers for the data-plane transports.
+// Called on start and whenever the relay endpoint changes.
+func (asm *AutoSwitchManager) RefreshHealthCheckers() {
+	asm.mu.RLock()
+	cfg := asm.config
+	wgManager := asm.wgManager
+	healthCheckFunc := asm.healthCheckFunc
+	asm.mu.RUnlock()
+
+	// QUIC: реальный QUIC handshake с relay
+	switch {
+	case healthCheckFunc != nil:
+		asm.engine.RegisterHealthChecker(TransportModeQUIC, autoswitch.NewProbeHealthChecker(func(ctx context.Context) error {
+			if !healthCheckFunc() {
+				return fmt.Errorf("QUIC health check failed")
 			}
-		}
-	}
-}
-
-// isQUICHealthy checks if QUIC connection is healthy by testing UDP connectivity
-func (asm *AutoSwitchManager) isQUICHealthy() bool {
-	// Use custom health check function if set (for testing)
-	if asm.healthCheckFunc != nil {
-		return asm.healthCheckFunc()
+			return nil
+		}))
+	case cfg.Relay.Ports.QUIC > 0:
+		address := net.JoinHostPort(cfg.Relay.Host, strconv.Itoa(cfg.Relay.Ports.QUIC))
+		asm.engine.RegisterHealthChecker(TransportModeQUIC, autoswitch.NewQUICHealthChecker(address, asm.relayTLSConfig("cloudbridge-p2p", "h3")))
+	default:
+		asm.engine.UnregisterHealthChecker(TransportModeQUIC)
 	}
 
-	// Test UDP connectivity to MASQUE port (8443)
-	address := net.JoinHostPort(asm.config.Relay.Host, fmt.Sprintf("%d", asm.config.Relay.Ports.MASQUE))
-
-	conn, err := net.DialTimeout("udp", address, 5*time.Second)
-	if err != nil {
-		asm.logger.Debug("UDP connectivity test failed", "address", address, "error", err)
-		return false
+	// WireGuard: свежесть handshake на поднятом интерфейсе; неподнятый — резерв (standby)
+	if wgManager != nil {
+		asm.engine.RegisterHealthChecker(TransportModeWireGuard, autoswitch.NewWireGuardHealthChecker(func() (bool, time.Time, error) {
+			if !wgManager.IsConnected() {
+				return false, time.Time{}, nil
+			}
+			lastHandshake, err := wgManager.LatestHandshake()
+			return true, lastHandshake, err
+		}, wireGuardMaxHandshakeAge))
+	} else {
+		asm.engine.UnregisterHealthChecker(TransportModeWireGuard)
 	}
-	defer conn.Close()
+}
 
-	// Try to write a small test packet
-	_, err = conn.Write([]byte("health_check"))
-	if err != nil {
-		asm.logger.Debug("UDP write test failed", "error", err)
-		return false
+// relayTLSConfig builds the TLS configuration for health checks against the relay
+func (asm *AutoSwitchManager) relayTLSConfig(nextProtos ...string) *tls.Config {
+	tlsConfig, err := config.CreateTLSConfig(asm.config)
+	if err != nil || tlsConfig == nil {
+		serverName := asm.config.Relay.TLS.ServerName
+		if serverName == "" {
+			serverName = asm.config.Relay.Host
+		}
+		tlsConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS13,
+			ServerName:         serverName,
+			InsecureSkipVerify: !asm.config.Relay.TLS.VerifyCert, // #nosec G402 -- управляется relay.tls.verify_cert
+		}
 	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = nextProtos
+	return tlsConfig
+}
 
-	return true
+// performHealthCheck checks the health of all transports and switches if the policy requires it
+func (asm *AutoSwitchManager) performHealthCheck() {
+	asm.engine.Evaluate(asm.ctx)
 }
 
-// SetHealthCheckFunc sets a custom health check function (for testing)
+// SetHealthCheckFunc sets a custom QUIC health check function (for testing)
 func (asm *AutoSwitchManager) SetHealthCheckFunc(fn func() bool) {
 	asm.mu.Lock()
-	defer asm.mu.Unlock()
 	asm.healthCheckFunc = fn
+	asm.mu.Unlock()
+	asm.RefreshHealthCheckers()
 }
 
-// switchToWireGuard switches transport mode to WireGuard
-func (asm *AutoSwitchManager) switchToWireGuard() error {
-	if asm.currentMode == TransportModeWireGuard {
-		return nil // Already in WireGuard mode
-	}
-
-	if asm.wgManager == nil {
-		return fmt.Errorf("WireGuard manager not initialized")
-	}
-
-	// Start WireGuard connection
-	if err := asm.wgManager.Connect(); err != nil {
-		return fmt.Errorf("failed to connect via WireGuard: %w", err)
-	}
-
-	oldMode := asm.currentMode
-	asm.currentMode = TransportModeWireGuard
+// applySwitch brings up the target transport, migrates live tunnels to it
+// and releases the previous one. Any error cancels the switch.
+func (asm *AutoSwitchManager) applySwitch(ctx context.Context, from, to TransportMode) error {
+	asm.mu.RLock()
+	wgManager := asm.wgManager
+	client := asm.client
+	asm.mu.RUnlock()
 
-	// Call switch callbacks
-	for _, callback := range asm.switchCallbacks {
-		go callback(oldMode, asm.currentMode)
+	if to == TransportModeWireGuard {
+		if wgManager == nil {
+			return fmt.Errorf("WireGuard manager not initialized")
+		}
+		if err := wgManager.Connect(); err != nil {
+			return fmt.Errorf("failed to connect via WireGuard: %w", err)
+		}
 	}
 
-	asm.logger.Info("SwitchedToWG", "from", oldMode, "to", asm.currentMode)
-	return nil
-}
-
-// switchToQUIC switches transport mode back to QUIC
-func (asm *AutoSwitchManager) switchToQUIC() error {
-	if asm.currentMode == TransportModeQUIC {
-		return nil // Already in QUIC mode
+	if client != nil {
+		if err := client.migrateTunnels(to); err != nil {
+			if to == TransportModeWireGuard {
+				if tdErr := wgManager.TearDown(); tdErr != nil {
+					asm.logger.Warn("Failed to tear down WireGuard after failed migration", "error", tdErr)
+				}
+			}
+			return fmt.Errorf("failed to migrate tunnels to %s: %w", to, err)
+		}
 	}
 
-	if asm.wgManager != nil {
+	if from == TransportModeWireGuard && to != TransportModeWireGuard && wgManager != nil {
 		// Properly tear down WireGuard connection
-		if err := asm.wgManager.TearDown(); err != nil {
+		if err := wgManager.TearDown(); err != nil {
 			asm.logger.Warn("Failed to tear down WireGuard cleanly", "error", err)
 		}
 	}
 
-	oldMode := asm.currentMode
-	asm.currentMode = TransportModeQUIC
-
-	// Call switch callbacks
-	for _, callback := range asm.switchCallbacks {
-		go callback(oldMode, asm.currentMode)
-	}
-
-	asm.logger.Info("SwitchedToQUIC", "from", oldMode, "to", asm.currentMode)
 	return nil
 }
 
 // ForceSwitch forces a switch to the specified transport mode
 func (asm *AutoSwitchManager) ForceSwitch(mode TransportMode) error {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
-
-	switch mode {
-	case TransportModeQUIC:
-		return asm.switchToQUIC()
-	case TransportModeWireGuard:
-		return asm.switchToWireGuard()
-	default:
+	if !slices.Contains(dataPlaneModes, mode) {
 		return fmt.Errorf("unsupported transport mode: %s", mode)
 	}
+	return asm.engine.ForceSwitch(mode)
+}
+
+// migrateTunnels moves live tunnels to the given data-plane transport: the relay is told the new
+// transport mode and active tunnels are re-registered on it. Local listeners keep running,
+// so existing client sockets are not dropped.
+func (c *Client) migrateTunnels(mode TransportMode) error {
+	c.mu.RLock()
+	transportAdapter := c.transportAdapter
+	connected := c.connected
+	c.mu.RUnlock()
+
+	if transportAdapter != nil {
+		transportAdapter.SetDataPlaneMode(string(mode))
+	}
+	if !connected {
+		return nil
+	}
+	return c.restoreTunnels()
+}
+
+// transportModeMetric maps a transport mode to the transport_mode metric value
+func transportModeMetric(mode TransportMode) int {
+	if mode == TransportModeWireGuard {
+		return MetricTransportWireGuard
+	}
+	return MetricTransportQUIC
 }
@@ -1,7 +1,10 @@
 package relay
 
 import (
+	"errors"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -97,9 +100,12 @@ func TestAutoSwitchManager_SwitchToQUIC(t *testing.T) {
 	err = asm.ForceSwitch(TransportModeWireGuard)
 	if err != nil {
 		// If we don't have root privileges, skip the test
-		if strings.Contains(err.Error(), "Operation not permitted") {
+		if strings.Contains(err.Error(), "Operation not permitted") || errors.Is(err, syscall.EPERM) {
 			t.Skip("Test requires root privileges for WireGuard interface operations")
 		}
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			t.Skip("Kernel WireGuard is not available")
+		}
 		t.Errorf("Failed to switch to WireGuard: %v", err)
 	}
 
@@ -187,7 +193,7 @@ func TestAutoSwitchManager_CallbacksOnSwitch(t *testing.T) {
 	config := &types.Config{
 		WireGuard: types.WireGuardConfig{
 			Enabled:       true,
-			InterfaceName: "wg-test-callback",
+			InterfaceName: "wg-test-cb",
 			MTU:           1420,
 			Port:          51827,
 		},
@@ -229,9 +235,12 @@ func TestAutoSwitchManager_CallbacksOnSwitch(t *testing.T) {
 	err = asm.ForceSwitch(TransportModeWireGuard)
 	if err != nil {
 		// If we don't have root privileges, skip the test
-		if strings.Contains(err.Error(), "Operation not permitted") {
+		if strings.Contains(err.Error(), "Operation not permitted") || errors.Is(err, syscall.EPERM) {
 			t.Skip("Test requires root privileges for WireGuard interface operations")
 		}
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			t.Skip("Kernel WireGuard is not available")
+		}
 		t.Errorf("Failed to switch to WireGuard: %v", err)
 	}
 
@@ -267,3 +276,29 @@ func TestAutoSwitchManager_CallbacksOnSwitch(t *testing.T) {
 		}
 	}
 }
+
+// TestAutoSwitchManager_DataPlaneModesOnly tests that only transports with a data plane are switch targets
+func TestAutoSwitchManager_DataPlaneModesOnly(t *testing.T) {
+	config := &types.Config{
+		AutoSwitch: types.AutoSwitchConfig{Order: []string{"masque", "quic", "grpc", "ws", "wireguard"}},
+	}
+
+	policy, ignored := autoSwitchConfigFromTypes(config)
+	if want := []TransportMode{TransportModeQUIC, TransportModeWireGuard}; !slices.Equal(policy.Order, want) {
+		t.Errorf("Expected order %v, got %v", want, policy.Order)
+	}
+	if want := []string{"masque", "grpc", "ws"}; !slices.Equal(ignored, want) {
+		t.Errorf("Expected ignored modes %v, got %v", want, ignored)
+	}
+	if !slices.Equal(policy.Standby, []TransportMode{TransportModeWireGuard}) {
+		t.Errorf("Expected WireGuard to be the standby mode, got %v", policy.Standby)
+	}
+
+	asm := NewAutoSwitchManager(config, newTestRelayLogger())
+	if err := asm.ForceSwitch("masque"); err == nil {
+		t.Error("Expected switch to MASQUE to be rejected")
+	}
+	if mode := asm.GetCurrentMode(); mode != TransportModeQUIC {
+		t.Errorf("Expected to stay on QUIC, got %s", mode)
+	}
+}
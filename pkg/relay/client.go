@@ -11,17 +11,31 @@ import (
 	"sync"
 	"time"
 
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
 	"github.com/2gc-dev/cloudbridge-client/pkg/api"
 	"github.com/2gc-dev/cloudbridge-client/pkg/auth"
 	"github.com/2gc-dev/cloudbridge-client/pkg/config"
+	"github.com/2gc-dev/cloudbridge-client/pkg/control"
+	"github.com/2gc-dev/cloudbridge-client/pkg/discovery"
 	"github.com/2gc-dev/cloudbridge-client/pkg/errors"
+	"github.com/2gc-dev/cloudbridge-client/pkg/handover"
 	"github.com/2gc-dev/cloudbridge-client/pkg/heartbeat"
+	"github.com/2gc-dev/cloudbridge-client/pkg/identity"
 	"github.com/2gc-dev/cloudbridge-client/pkg/interfaces"
+	"github.com/2gc-dev/cloudbridge-client/pkg/masque"
+	"github.com/2gc-dev/cloudbridge-client/pkg/meshdns"
 	"github.com/2gc-dev/cloudbridge-client/pkg/metrics"
 	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
 	"github.com/2gc-dev/cloudbridge-client/pkg/performance"
+	"github.com/2gc-dev/cloudbridge-client/pkg/pop"
+	"github.com/2gc-dev/cloudbridge-client/pkg/portmap"
+	"github.com/2gc-dev/cloudbridge-client/pkg/probes"
+	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport"
+	"github.com/2gc-dev/cloudbridge-client/pkg/slo"
+	"github.com/2gc-dev/cloudbridge-client/pkg/socks"
 	"github.com/2gc-dev/cloudbridge-client/pkg/tunnel"
 	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+	"github.com/2gc-dev/cloudbridge-client/pkg/wireguard"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -29,7 +43,6 @@ import (
 const (
 	MetricTransportQUIC      = 0
 	MetricTransportWireGuard = 1
-	MetricTransportGRPC      = 2
 )
 
 // Client represents a CloudBridge Relay client
@@ -53,10 +66,13 @@ type Client struct {
 	connectionType      string
 
 	// Новые компоненты для улучшенного клиента
-	masqueClient    interface{} // *masque.MASQUEClient
-	handoverManager interface{} // *handover.HandoverManager
-	sloController   interface{} // *slo.SLOController
-	probeManager    interface{} // *probes.SyntheticProbeManager
+	masqueClient    *masque.MASQUEClient
+	handoverManager *handover.HandoverManager
+	sloController   *slo.SLOController
+	probeManager    *probes.SyntheticProbeManager
+	popSelector     *pop.Selector
+	controlServer   *control.Server
+	socksProxy      *socks.Server
 	logger          *relayLogger
 	mu              sync.RWMutex
 	connected       bool
@@ -67,6 +83,11 @@ type Client struct {
 	cancel          context.CancelFunc
 	lastHeartbeat   time.Time
 	stateEvents     chan interfaces.ClientStateEvent
+
+	// Heartbeat telemetry
+	resourceSampler   *performance.ResourceSampler
+	reportedBytesSent int64 // Tunnel bytes already exported to Prometheus
+	reportedBytesRecv int64
 }
 
 // Message types as defined in the requirements
@@ -145,46 +166,44 @@ func NewClient(cfg *types.Config, configPath string) (*Client, error) {
 	optimizer := performance.NewOptimizer(cfg.Performance.Enabled)
 
 	client := &Client{
-		config:        cfg,
-		configPath:    configPath,
-		authManager:   authManager,
-		retryStrategy: retryStrategy,
-		metrics:       metricsSystem,
-		optimizer:     optimizer,
-		logger:        NewRelayLogger("relay-client"),
-		ctx:           ctx,
-		cancel:        cancel,
+		config:          cfg,
+		configPath:      configPath,
+		authManager:     authManager,
+		retryStrategy:   retryStrategy,
+		metrics:         metricsSystem,
+		optimizer:       optimizer,
+		logger:          NewRelayLogger("relay-client"),
+		ctx:             ctx,
+		cancel:          cancel,
+		resourceSampler: performance.NewResourceSampler(),
 	}
 
 	// Create AutoSwitchManager for WireGuard fallback
 	client.autoSwitchMgr = NewAutoSwitchManager(cfg, client.logger)
 
-	// Инициализируем новые компоненты
-	client.initializeEnhancedComponents()
+	// Инициализируем новые компоненты (включая выбор relay POP до создания транспорта)
+	if err := client.initializeEnhancedComponents(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize enhanced components: %w", err)
+	}
 
 	// Add callback to update transport mode metrics
 	client.autoSwitchMgr.AddSwitchCallback(func(from, to TransportMode) {
-		var modeValue int
-		switch to {
-		case TransportModeQUIC:
-			modeValue = 0
-		case TransportModeWireGuard:
-			modeValue = 1
-		default:
-			modeValue = 0
-		}
-		client.metrics.SetTransportMode(modeValue)
+		client.metrics.SetTransportMode(transportModeMetric(to))
 	})
 
 	// Create transport adapter for gRPC support
 	client.transportAdapter = NewTransportAdapter(cfg, client.logger)
 	client.useTransportAdapter = true // Always use transport adapter for modern clients
+	client.autoSwitchMgr.attachClient(client)
 
-	// Create tunnel manager
+	// Create tunnel manager; overlay IPs are dialed through the P2P mesh (needed in netstack mode)
 	client.tunnelManager = tunnel.NewManager(client)
+	client.tunnelManager.SetDialer(client.DialContext)
 
 	// Create heartbeat manager
 	client.heartbeatMgr = heartbeat.NewManager(client)
+	client.heartbeatMgr.OnNotification(client.handleServerNotification)
 
 	// Initialize performance optimization
 	if cfg.Performance.Enabled {
@@ -202,17 +221,18 @@ func NewClient(cfg *types.Config, configPath string) (*Client, error) {
 		return nil, fmt.Errorf("failed to start metrics server: %w", err)
 	}
 
-	// Start AutoSwitchManager
-	if err := client.autoSwitchMgr.Start(); err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to start AutoSwitchManager: %w", err)
-	}
-
 	// Initialize transport adapter
 	if err := client.transportAdapter.Initialize(); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to initialize transport adapter: %w", err)
 	}
+	client.transportAdapter.SetDataPlaneMode(string(client.autoSwitchMgr.GetCurrentMode()))
+
+	// Start AutoSwitchManager (health checks need the initialized transport adapter)
+	if err := client.autoSwitchMgr.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start AutoSwitchManager: %w", err)
+	}
 
 	// Определяем использование transport adapter на основе режима
 	currentMode := client.transportAdapter.GetCurrentMode()
@@ -359,20 +379,21 @@ func (c *Client) Authenticate(token string) error {
 	// If using transport adapter (gRPC), дополнительно аутентифицируемся через адаптер
 	if useTA && c.transportAdapter != nil {
 		c.logger.Info("Using transport adapter for authentication")
-		clientID, _, err := c.transportAdapter.Authenticate(token)
+		result, err := c.transportAdapter.Authenticate(token)
 		if err != nil {
 			return err
 		}
+		c.storeContinuationToken(result.ContinuationToken)
 
 		c.mu.Lock()
-		c.clientID = clientID
+		c.clientID = result.ClientID
 		c.tenantID = tenantID // используем локальные claims
 		c.tokenString = token
 		c.connectionType = connType
 		c.mu.Unlock()
 
-		// Инициализируем P2P если нужно
-		if connType == "p2p-mesh" {
+		// Инициализируем P2P если нужно (при повторной аутентификации менеджер уже работает)
+		if connType == "p2p-mesh" && c.GetP2PManager() == nil {
 			if err := c.initializeP2PManager(validatedToken); err != nil {
 				return fmt.Errorf("p2p init: %w", err)
 			}
@@ -438,7 +459,7 @@ func (c *Client) Authenticate(token string) error {
 	c.connectionType = connectionType
 
 	// Initialize P2P manager if connection type is P2P mesh
-	if connectionType == "p2p-mesh" {
+	if connectionType == "p2p-mesh" && c.GetP2PManager() == nil {
 		if err := c.initializeP2PManager(validatedToken); err != nil {
 			return fmt.Errorf("failed to initialize P2P manager: %w", err)
 		}
@@ -535,11 +556,25 @@ func (c *Client) StopHeartbeat() {
 
 // Disconnect disconnects from the relay server
 func (c *Client) Disconnect() error {
+	if !c.disconnect() {
+		return nil
+	}
+	// Сессия завершена клиентом: следующее подключение не должно ее возобновлять
+	if c.handoverManager != nil {
+		c.handoverManager.ClearToken()
+	}
+	// Событие отправляется после освобождения мьютекса: emitStateEvent берет RLock
+	c.emitStateEvent("disconnected", "manual disconnect")
+	return nil
+}
+
+// disconnect closes the relay connection and reports whether the client was connected
+func (c *Client) disconnect() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if !c.connected {
-		return nil
+		return false
 	}
 
 	c.logger.Info("Disconnecting from relay server")
@@ -562,9 +597,8 @@ func (c *Client) Disconnect() error {
 	}
 
 	c.connected = false
-	c.emitStateEvent("disconnected", "manual disconnect")
 	c.logger.Info("Disconnected from relay server")
-	return nil
+	return true
 }
 
 // Close closes the client connection and cleans up resources
@@ -575,6 +609,13 @@ func (c *Client) Close() error {
 	// CRITICAL: всегда отменяем контекст в конце
 	defer c.cancel()
 
+	// Selector запускается в NewClient, поэтому останавливается и без подключения
+	if c.popSelector != nil {
+		c.popSelector.Stop()
+	}
+	c.stopControlServerLocked()
+	c.stopSOCKSProxyLocked()
+
 	if !c.connected {
 		// Still need to clean up resources even if not connected
 		return nil
@@ -672,31 +713,39 @@ func (c *Client) receiveMessage() (map[string]interface{}, error) {
 
 // SendHeartbeat sends a heartbeat message
 func (c *Client) SendHeartbeat() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.mu.RLock()
+	connected := c.connected
+	useTA := c.useTransportAdapter && c.transportAdapter != nil
+	clientID := c.clientID
+	tenantID := c.tenantID
+	c.mu.RUnlock()
 
-	if !c.connected {
+	if !connected {
 		return fmt.Errorf("not connected")
 	}
 
 	// If using transport adapter (gRPC), delegate to transport adapter
-	if c.useTransportAdapter && c.transportAdapter != nil {
+	if useTA {
 		c.logger.Debug("Sending heartbeat via transport adapter",
-			"client_id", c.clientID,
-			"tenant_id", c.tenantID)
-
-		err := c.transportAdapter.SendHeartbeat(c.clientID, c.tenantID)
-		if err == nil {
-			c.mu.Lock()
-			c.lastHeartbeat = time.Now()
-			c.mu.Unlock()
+			"client_id", clientID,
+			"tenant_id", tenantID)
+
+		if err := c.transportAdapter.SendHeartbeat(clientID, tenantID, c.heartbeatMetrics()); err != nil {
+			return err
 		}
-		return err
+
+		c.mu.Lock()
+		c.lastHeartbeat = time.Now()
+		c.mu.Unlock()
+		return nil
 	}
 
 	// Legacy JSON transport path
 	c.logger.Debug("Sending heartbeat via legacy JSON transport")
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	heartbeatMsg := map[string]interface{}{
 		"type": MessageTypeHeartbeat,
 	}
@@ -715,9 +764,7 @@ func (c *Client) SendHeartbeat() error {
 	}
 
 	// Update last heartbeat time
-	c.mu.Lock()
 	c.lastHeartbeat = time.Now()
-	c.mu.Unlock()
 
 	return nil
 }
@@ -756,6 +803,8 @@ func (c *Client) initializeP2PManager(token *jwt.Token) error {
 	if err != nil {
 		return fmt.Errorf("failed to extract P2P config from token: %w", err)
 	}
+	p2pConfig.MaxConnections = c.config.P2P.MaxConnections
+	p2pConfig.RelayFallback = c.config.P2P.FallbackEnabled
 
 	// Create P2P logger
 	p2pLogger := &p2pLogger{client: c}
@@ -773,6 +822,85 @@ func (c *Client) initializeP2PManager(token *jwt.Token) error {
 
 	// Use stored token string for API manager
 	c.p2pManager = p2p.NewManagerWithAPI(p2pConfig, apiConfig, c.authManager, c.tokenString, p2pLogger)
+	if relayEndpoints := discovery.FromConfig(c.config); relayEndpoints.QUIC != "" {
+		c.p2pManager.SetRelayAddress(relayEndpoints.QUIC)
+	}
+	turnServers := c.config.ICE.TURNServers
+	if c.config.TURN.Enabled {
+		turnServers = appendUnique(turnServers, c.config.TURN.Servers...)
+		c.p2pManager.SetTURNCredentials(c.config.TURN.Username, c.config.TURN.Password)
+	}
+	c.p2pManager.SetICEServers(c.config.ICE.STUNServers, turnServers)
+	if c.config.WebSocket.Enabled {
+		c.p2pManager.SetSignalingEndpoint(c.config.WebSocket.Endpoint, c.config.API.InsecureSkipVerify)
+	}
+	if c.config.DERP.Enabled && c.config.P2P.DERPFallback {
+		derpServers := appendUnique(c.config.ICE.DERPServers, c.config.DERP.Servers...)
+		c.p2pManager.SetDERPServers(derpServers, c.config.API.InsecureSkipVerify)
+	}
+	if c.config.P2P.PortMapping.Enabled {
+		portMapConfig := portmap.DefaultConfig()
+		if len(c.config.P2P.PortMapping.Protocols) > 0 {
+			portMapConfig.Protocols = make([]portmap.Protocol, len(c.config.P2P.PortMapping.Protocols))
+			for i, protocol := range c.config.P2P.PortMapping.Protocols {
+				portMapConfig.Protocols[i] = portmap.Protocol(protocol)
+			}
+		}
+		portMapConfig.Gateway = c.config.P2P.PortMapping.Gateway
+		if c.config.P2P.PortMapping.Lifetime > 0 {
+			portMapConfig.Lifetime = c.config.P2P.PortMapping.Lifetime
+		}
+		c.p2pManager.SetPortMapping(portMapConfig, c.config.P2P.ListenPort)
+	}
+	if c.config.P2P.ACL.Enabled {
+		aclConfig := acl.DefaultConfig()
+		aclConfig.PolicyFile = c.config.P2P.ACL.PolicyFile
+		if c.config.P2P.ACL.RefreshInterval > 0 {
+			aclConfig.RefreshInterval = c.config.P2P.ACL.RefreshInterval
+		}
+		c.p2pManager.SetACL(aclConfig)
+	}
+	if c.config.P2P.Identity.Enabled {
+		identityConfig := identity.DefaultConfig()
+		if c.config.P2P.Identity.KeyFile != "" {
+			identityConfig.KeyFile = c.config.P2P.Identity.KeyFile
+		}
+		if c.config.P2P.Identity.PinFile != "" {
+			identityConfig.PinFile = c.config.P2P.Identity.PinFile
+		}
+		identityConfig.RotationInterval = c.config.P2P.Identity.RotationInterval
+		identityConfig.RequirePeerKeys = c.config.P2P.Identity.RequirePeerKeys
+		c.p2pManager.SetIdentity(identityConfig)
+	} else {
+		c.logger.Warn("P2P identity disabled: peer connections are not authenticated")
+	}
+	if c.config.P2P.DNS.Enabled {
+		dnsConfig := meshdns.DefaultConfig()
+		if c.config.P2P.DNS.Domain != "" {
+			dnsConfig.Domain = c.config.P2P.DNS.Domain
+		}
+		dnsConfig.ListenAddr = c.config.P2P.DNS.ListenAddr
+		dnsConfig.Upstreams = c.config.P2P.DNS.Upstreams
+		c.p2pManager.SetMeshDNS(dnsConfig, c.config.P2P.DNS.ConfigureSystem)
+	}
+	switch c.config.WireGuard.Mode {
+	case wireGuardModeUserspace, wireGuardModeNetstack:
+		c.p2pManager.SetUserspaceWireGuard(&wireguard.Config{
+			InterfaceName: c.config.WireGuard.InterfaceName,
+			MTU:           c.config.WireGuard.MTU,
+			Mode:          c.config.WireGuard.Mode,
+		})
+	}
+	if routes := c.config.P2P.Routes; len(routes.Advertise) > 0 || routes.AdvertiseExitNode ||
+		len(routes.Accept) > 0 || routes.ExitNode != "" {
+		c.p2pManager.SetRoutes(&p2p.RoutesConfig{
+			Advertise:   routes.Advertise,
+			ExitNode:    routes.AdvertiseExitNode,
+			Accept:      routes.Accept,
+			UseExitNode: routes.ExitNode,
+			SNAT:        routes.SNAT,
+		})
+	}
 
 	// Start P2P manager
 	if err := c.p2pManager.Start(); err != nil {
@@ -783,6 +911,10 @@ func (c *Client) initializeP2PManager(token *jwt.Token) error {
 		"connection_type", p2pConfig.ConnectionType,
 		"tenant_id", p2pConfig.TenantID)
 
+	// Подтверждение пиров из очереди (whitelist без auto_approve)
+	c.startControlServer()
+	c.startSOCKSProxy()
+
 	return nil
 }
 
@@ -1013,34 +1145,75 @@ func (c *Client) ForceConfigReload() error {
 
 // UpdateMetrics updates client metrics (called periodically)
 func (c *Client) UpdateMetrics() {
+	c.recordClientMetrics(c.collectClientMetrics())
+}
+
+// heartbeatMetrics collects the metrics sent with a heartbeat and exports them to Prometheus
+func (c *Client) heartbeatMetrics() *transport.ClientMetrics {
+	m := c.collectClientMetrics()
+	c.recordClientMetrics(m)
+	return m
+}
+
+// collectClientMetrics gathers tunnel traffic, P2P sessions, process resource usage and
+// the active data-plane transport
+func (c *Client) collectClientMetrics() *transport.ClientMetrics {
+	traffic := c.tunnelManager.GetTrafficStats()
+	usage := c.resourceSampler.Sample()
+
+	m := &transport.ClientMetrics{
+		BytesSent:       traffic.BytesSent,
+		BytesReceived:   traffic.BytesReceived,
+		PacketsSent:     traffic.PacketsSent,
+		PacketsReceived: traffic.PacketsReceived,
+		ActiveTunnels:   int32(traffic.ActiveTunnels), // #nosec G115 -- tunnel count is small
+		CPUUsage:        usage.CPUPercent,
+		MemoryUsage:     float64(usage.MemoryBytes) / (1024 * 1024),
+	}
+
+	c.mu.RLock()
+	p2pManager := c.p2pManager
+	c.mu.RUnlock()
+	if p2pManager != nil {
+		m.ActiveP2PSessions = int32(p2pManager.GetActivePeers()) // #nosec G115 -- peer count is small
+		m.P2PPaths = make(map[string]int)
+		for path, count := range p2pManager.GetPathCounts() {
+			m.P2PPaths[string(path)] = count
+		}
+	}
+
+	if c.autoSwitchMgr != nil {
+		m.TransportMode = string(c.autoSwitchMgr.GetCurrentMode())
+		m.LastSwitch = c.autoSwitchMgr.LastSwitch()
+	}
+
+	return m
+}
+
+// recordClientMetrics exports collected client metrics to Prometheus
+func (c *Client) recordClientMetrics(m *transport.ClientMetrics) {
 	if c.metrics == nil {
 		return
 	}
 
-	// Update P2P sessions count
-	if c.p2pManager != nil {
-		// In a real implementation, you would get the actual session count
-		// For now, we'll use a placeholder
-		c.metrics.SetP2PSessions(1) // Placeholder
-	} else {
-		c.metrics.SetP2PSessions(0)
-	}
+	c.metrics.SetP2PSessions(int(m.ActiveP2PSessions))
+	c.metrics.SetP2PPaths(m.P2PPaths)
+	c.metrics.SetTransportMode(transportModeMetric(TransportMode(m.TransportMode)))
 
-	// Update transport mode based on current settings
-	if c.useTransportAdapter {
-		c.metrics.SetTransportMode(MetricTransportGRPC) // gRPC mode
-	} else {
-		// Use AutoSwitchManager mode
-		mode := c.autoSwitchMgr.GetCurrentMode()
-		switch mode {
-		case TransportModeQUIC:
-			c.metrics.SetTransportMode(MetricTransportQUIC)
-		case TransportModeWireGuard:
-			c.metrics.SetTransportMode(MetricTransportWireGuard)
-		default:
-			c.metrics.SetTransportMode(MetricTransportQUIC)
-		}
+	// Tunnel totals are cumulative, Prometheus counters take increments
+	c.mu.Lock()
+	var sent, recv int64
+	if m.BytesSent > c.reportedBytesSent {
+		sent = m.BytesSent - c.reportedBytesSent
+		c.reportedBytesSent = m.BytesSent
 	}
+	if m.BytesReceived > c.reportedBytesRecv {
+		recv = m.BytesReceived - c.reportedBytesRecv
+		c.reportedBytesRecv = m.BytesReceived
+	}
+	c.mu.Unlock()
+
+	c.RecordDataTransfer(sent, recv)
 }
 
 // RecordDataTransfer records data transfer for metrics
@@ -1075,36 +1248,49 @@ func (cla *configLoggerAdapter) Warn(msg string, fields ...interface{}) {
 }
 
 // initializeEnhancedComponents инициализирует новые компоненты клиента
-func (c *Client) initializeEnhancedComponents() {
+func (c *Client) initializeEnhancedComponents() error {
 	c.logger.Info("Initializing enhanced client components...")
 
-	// TODO: Реальная инициализация будет добавлена после создания пакетов
-	// Пока что устанавливаем заглушки для совместимости
-	c.masqueClient = nil
-	c.handoverManager = nil
-	c.sloController = nil
-	c.probeManager = nil
+	c.handoverManager = handover.NewHandoverManager(nil, c.logger)
+	c.sloController = slo.NewSLOController(nil, c.logger)
+
+	probeConfig := probes.DefaultSyntheticProbeConfig()
+	if c.config.Relay.Selection.ProbeInterval > 0 {
+		probeConfig.Interval = c.config.Relay.Selection.ProbeInterval
+	}
+	if c.config.Relay.Selection.ProbeTimeout > 0 {
+		probeConfig.Timeout = c.config.Relay.Selection.ProbeTimeout
+	}
+	c.probeManager = probes.NewSyntheticProbeManager(probeConfig, c.logger)
+
+	// Выбор relay POP также создает MASQUE клиент для выбранного POP
+	if err := c.initializePOPSelector(); err != nil {
+		return err
+	}
 
 	c.logger.Info("Enhanced client components initialized successfully")
+	return nil
 }
 
 // GetMASQUEClient возвращает MASQUE клиент
-func (c *Client) GetMASQUEClient() interface{} {
+func (c *Client) GetMASQUEClient() *masque.MASQUEClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.masqueClient
 }
 
 // GetHandoverManager возвращает Handover Manager
-func (c *Client) GetHandoverManager() interface{} {
+func (c *Client) GetHandoverManager() *handover.HandoverManager {
 	return c.handoverManager
 }
 
 // GetSLOController возвращает SLO Controller
-func (c *Client) GetSLOController() interface{} {
+func (c *Client) GetSLOController() *slo.SLOController {
 	return c.sloController
 }
 
 // GetProbeManager возвращает Synthetic Probe Manager
-func (c *Client) GetProbeManager() interface{} {
+func (c *Client) GetProbeManager() *probes.SyntheticProbeManager {
 	return c.probeManager
 }
 
@@ -1118,18 +1304,37 @@ func (c *Client) Stop() error {
 	return c.Close()
 }
 
-// Reconnect reconnects the client (implements interfaces.ClientInterface)
+// Reconnect reconnects the client to the currently configured relay (implements interfaces.ClientInterface).
+// If the relay issued a continuation token, the session is resumed with it; otherwise the client
+// re-authenticates and re-registers its tunnels. Local tunnel listeners keep running in both cases.
 func (c *Client) Reconnect() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.emitStateEvent("reconnecting", "reconnect requested")
 
-	if c.connected {
-		if err := c.Disconnect(); err != nil {
-			return fmt.Errorf("failed to disconnect: %w", err)
-		}
+	// В отличие от Disconnect, токен продолжения сохраняется для resumeSession
+	if c.disconnect() {
+		c.emitStateEvent("disconnected", "reconnect")
+	}
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
 	}
 
-	return c.Connect()
+	c.mu.RLock()
+	token := c.tokenString
+	c.mu.RUnlock()
+	if token == "" {
+		return nil
+	}
+
+	err := c.resumeSession()
+	if err == nil {
+		return nil
+	}
+	c.logger.Warn("Session resume failed, re-authenticating", "error", err)
+
+	if err := c.Authenticate(token); err != nil {
+		return fmt.Errorf("failed to re-authenticate: %w", err)
+	}
+	return c.restoreTunnels()
 }
 
 // LastHeartbeat returns the time of the last heartbeat (implements interfaces.ClientInterface)
@@ -1168,3 +1373,21 @@ func (c *Client) emitStateEvent(state, reason string) {
 		}
 	}
 }
+
+// appendUnique appends values that are not yet present in list
+func appendUnique(list []string, values ...string) []string {
+	result := append([]string(nil), list...)
+	for _, v := range values {
+		found := false
+		for _, existing := range result {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, v)
+		}
+	}
+	return result
+}
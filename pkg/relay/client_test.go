@@ -0,0 +1,185 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport/proto"
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// TestClient_CloseStopsPOPSelector tests that Close stops the POP selector of a client
+// that never connected
+func TestClient_CloseStopsPOPSelector(t *testing.T) {
+	config := &types.Config{
+		Auth: types.AuthConfig{Type: "jwt", Secret: "test-secret"},
+		Relay: types.RelayConfig{
+			Host: "127.0.0.1",
+			Port: 1,
+			Endpoints: []types.RelayEndpoint{
+				{ID: "pop-a", Host: "127.0.0.1", Port: 1},
+				{ID: "pop-b", Host: "127.0.0.1", Port: 2},
+			},
+			Selection: types.POPSelectionConfig{
+				Enabled:       true,
+				ProbeInterval: time.Hour,
+				ProbeTimeout:  100 * time.Millisecond,
+			},
+		},
+	}
+
+	client, err := NewClient(config, "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	selector := client.GetPOPSelector()
+	if selector == nil {
+		t.Fatal("POP selector not created for two endpoints")
+	}
+	if running, _ := selector.GetMetrics()["running"].(bool); !running {
+		t.Fatal("POP selector not running after NewClient")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if running, _ := selector.GetMetrics()["running"].(bool); running {
+		t.Error("POP selector still running after Close of a never connected client")
+	}
+}
+
+// TestClient_DisconnectEmitsStateEvent tests that Disconnect reports the state change
+// after releasing the client lock
+func TestClient_DisconnectEmitsStateEvent(t *testing.T) {
+	config := &types.Config{
+		Auth:  types.AuthConfig{Type: "jwt", Secret: "test-secret"},
+		Relay: types.RelayConfig{Host: "127.0.0.1", Port: 1},
+	}
+	client, err := NewClient(config, "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close() //nolint:errcheck // test cleanup
+
+	events := client.SubscribeState()
+	client.mu.Lock()
+	client.connected = true
+	client.mu.Unlock()
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.State != "disconnected" {
+			t.Errorf("Expected disconnected event, got %s", event.State)
+		}
+	default:
+		t.Fatal("No state event after Disconnect")
+	}
+	if client.IsConnected() {
+		t.Error("Client still connected after Disconnect")
+	}
+
+	// Повторный Disconnect ничего не делает и событий не отправляет
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Second Disconnect failed: %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event after second Disconnect: %s", event.State)
+	default:
+	}
+}
+
+// heartbeatRelay fake relay that records heartbeat metrics
+type heartbeatRelay struct {
+	proto.UnimplementedControlServiceServer
+	proto.UnimplementedHeartbeatServiceServer
+
+	mu      sync.Mutex
+	metrics []*proto.ClientMetrics
+}
+
+func (r *heartbeatRelay) Hello(ctx context.Context, req *proto.HelloRequest) (*proto.HelloResponse, error) {
+	return &proto.HelloResponse{Status: "ok", ServerVersion: "test"}, nil
+}
+
+func (r *heartbeatRelay) SendHeartbeat(ctx context.Context, req *proto.HeartbeatRequest) (*proto.HeartbeatResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, req.Metrics)
+	return &proto.HeartbeatResponse{Status: "ok", IntervalSeconds: 30}, nil
+}
+
+// TestClient_HeartbeatCarriesClientMetrics tests that heartbeats report tunnel traffic and
+// process resource usage
+func TestClient_HeartbeatCarriesClientMetrics(t *testing.T) {
+	relay := &heartbeatRelay{}
+	port := startTestRelay(t, func(server *grpc.Server) {
+		proto.RegisterControlServiceServer(server, relay)
+		proto.RegisterHeartbeatServiceServer(server, relay)
+	})
+
+	client, err := NewClient(&types.Config{
+		Auth:  types.AuthConfig{Type: "jwt", Secret: "test-secret"},
+		Relay: types.RelayConfig{Host: "127.0.0.1", Port: port},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close() //nolint:errcheck // test cleanup
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	localPort := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close() //nolint:errcheck // port is reused by the tunnel
+	if err := client.tunnelManager.RegisterTunnel("tunnel-1", localPort, "127.0.0.1", 5432); err != nil {
+		t.Fatalf("Failed to register tunnel: %v", err)
+	}
+	defer client.tunnelManager.UnregisterTunnel("tunnel-1") //nolint:errcheck // test cleanup
+	tunnel, _ := client.tunnelManager.GetTunnel("tunnel-1")
+	tunnel.Stats.RecordSent(1500)
+	tunnel.Stats.RecordSent(500)
+	tunnel.Stats.RecordReceived(4096)
+
+	// Нагрузка на CPU между созданием клиента и heartbeat
+	for deadline := time.Now().Add(20 * time.Millisecond); time.Now().Before(deadline); {
+	}
+
+	if err := client.SendHeartbeat(); err != nil {
+		t.Fatalf("SendHeartbeat failed: %v", err)
+	}
+
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if len(relay.metrics) != 1 || relay.metrics[0] == nil {
+		t.Fatalf("Expected one heartbeat with metrics, got %v", relay.metrics)
+	}
+	m := relay.metrics[0]
+	if m.BytesSent != 2000 || m.BytesReceived != 4096 || m.PacketsSent != 2 || m.PacketsReceived != 1 {
+		t.Errorf("Heartbeat does not carry tunnel traffic: sent=%d/%d received=%d/%d",
+			m.BytesSent, m.PacketsSent, m.BytesReceived, m.PacketsReceived)
+	}
+	if m.ActiveTunnels != 1 {
+		t.Errorf("Expected 1 active tunnel, got %d", m.ActiveTunnels)
+	}
+	if m.CpuUsage <= 0 || m.CpuUsage > 100 {
+		t.Errorf("Expected measured CPU usage, got %f", m.CpuUsage)
+	}
+	if m.MemoryUsage <= 0 {
+		t.Errorf("Expected measured memory usage, got %f", m.MemoryUsage)
+	}
+	if m.TransportMode != string(TransportModeQUIC) {
+		t.Errorf("Expected transport mode %s, got %s", TransportModeQUIC, m.TransportMode)
+	}
+}
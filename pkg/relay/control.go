@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"github.com/2gc-dev/cloudbridge-client/pkg/control"
+	"github.com/2gc-dev/cloudbridge-client/pkg/p2p"
+)
+
+// startControlServer starts the local control API (pending peer approval) once the P2P mesh runs.
+// The control API is auxiliary: failures are logged and do not stop the client.
+func (c *Client) startControlServer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.controlServer != nil || !c.config.P2P.Control.Enabled {
+		return
+	}
+	server := control.NewServer(&control.Config{Socket: c.config.P2P.Control.Socket}, c, c.logger)
+	if err := server.Start(); err != nil {
+		c.logger.Warn("Failed to start control API", "error", err)
+		return
+	}
+	c.controlServer = server
+}
+
+// stopControlServerLocked stops the control API. Called under c.mu.
+func (c *Client) stopControlServerLocked() {
+	if c.controlServer == nil {
+		return
+	}
+	if err := c.controlServer.Stop(); err != nil {
+		c.logger.Warn("Failed to stop control API", "error", err)
+	}
+	c.controlServer = nil
+}
+
+// PendingPeers returns peers awaiting approval (empty until the P2P mesh is started)
+func (c *Client) PendingPeers() []p2p.PendingPeer {
+	manager := c.GetP2PManager()
+	if manager == nil {
+		return []p2p.PendingPeer{}
+	}
+	return manager.PendingPeers()
+}
+
+// ApprovePeer approves a pending peer of the P2P mesh
+func (c *Client) ApprovePeer(peerID string) error {
+	manager := c.GetP2PManager()
+	if manager == nil {
+		return control.ErrP2PNotRunning
+	}
+	return manager.ApprovePeer(peerID)
+}
+
+// RejectPeer rejects a peer of the P2P mesh
+func (c *Client) RejectPeer(peerID string) error {
+	manager := c.GetP2PManager()
+	if manager == nil {
+		return control.ErrP2PNotRunning
+	}
+	return manager.RejectPeer(peerID)
+}
@@ -0,0 +1,174 @@
+package relay
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/interfaces"
+	"github.com/2gc-dev/cloudbridge-client/pkg/pop"
+	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OpenHeartbeatStream opens a streaming heartbeat over the gRPC transport
+// (implements interfaces.HeartbeatStreamer). The legacy JSON transport has no
+// stream and returns interfaces.ErrHeartbeatStreamUnsupported.
+func (c *Client) OpenHeartbeatStream() (interfaces.HeartbeatStream, error) {
+	c.mu.RLock()
+	connected := c.connected
+	useTA := c.useTransportAdapter && c.transportAdapter != nil
+	clientID := c.clientID
+	tenantID := c.tenantID
+	c.mu.RUnlock()
+
+	if !connected {
+		return nil, fmt.Errorf("not connected")
+	}
+	if !useTA {
+		return nil, interfaces.ErrHeartbeatStreamUnsupported
+	}
+
+	stream, err := c.transportAdapter.StreamHeartbeat(clientID, tenantID)
+	if err != nil {
+		return nil, heartbeatStreamError(err)
+	}
+	return &relayHeartbeatStream{client: c, stream: stream}, nil
+}
+
+// heartbeatStreamError maps Unimplemented from relays without StreamHeartbeat
+// to interfaces.ErrHeartbeatStreamUnsupported
+func heartbeatStreamError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("%w: %v", interfaces.ErrHeartbeatStreamUnsupported, err)
+	}
+	return err
+}
+
+// relayHeartbeatStream adapts transport.HeartbeatStream to interfaces.HeartbeatStream
+type relayHeartbeatStream struct {
+	client *Client
+	stream transport.HeartbeatStream
+}
+
+// Send sends a heartbeat with the current client metrics
+func (s *relayHeartbeatStream) Send() error {
+	return s.stream.Send(s.client.heartbeatMetrics())
+}
+
+// Recv receives the next heartbeat response or server notification
+func (s *relayHeartbeatStream) Recv() (*interfaces.HeartbeatAck, error) {
+	result, err := s.stream.Recv()
+	if err != nil {
+		return nil, heartbeatStreamError(err)
+	}
+
+	if result.Status == "ok" {
+		s.client.mu.Lock()
+		s.client.lastHeartbeat = time.Now()
+		s.client.mu.Unlock()
+	}
+
+	ack := &interfaces.HeartbeatAck{
+		Status:       result.Status,
+		Interval:     time.Duration(result.IntervalSeconds) * time.Second,
+		ErrorMessage: result.ErrorMessage,
+	}
+	if sm := result.ServerMetrics; sm != nil {
+		ack.ServerMetrics = &interfaces.ServerMetrics{
+			ConnectedClients: sm.ConnectedClients,
+			ActiveTunnels:    sm.ActiveTunnels,
+			ServerLoad:       sm.ServerLoad,
+			Timestamp:        sm.Timestamp,
+		}
+	}
+	for _, n := range result.Notifications {
+		ack.Notifications = append(ack.Notifications, interfaces.ServerNotification{
+			Type:       n.Type,
+			TunnelID:   n.TunnelID,
+			POPID:      n.POPID,
+			POPAddress: n.POPAddress,
+			Message:    n.Message,
+			ExpiresAt:  n.ExpiresAt,
+		})
+	}
+	return ack, nil
+}
+
+// Close closes the underlying stream
+func (s *relayHeartbeatStream) Close() error {
+	return s.stream.Close()
+}
+
+// handleServerNotification reacts to notifications pushed by the relay on the heartbeat stream
+func (c *Client) handleServerNotification(n interfaces.ServerNotification) {
+	switch n.Type {
+	case interfaces.NotificationTunnelRevoked:
+		c.logger.Warn("Tunnel revoked by relay", "tunnel_id", n.TunnelID, "reason", n.Message)
+		if err := c.tunnelManager.UnregisterTunnel(n.TunnelID); err != nil {
+			c.logger.Warn("Failed to stop revoked tunnel", "tunnel_id", n.TunnelID, "error", err)
+		}
+
+	case interfaces.NotificationTokenExpiring:
+		c.logger.Warn("Relay token is expiring", "expires_at", n.ExpiresAt, "reason", n.Message)
+		c.emitStateEvent("token_expiring", n.Message)
+
+	case interfaces.NotificationReconnect:
+		c.logger.Info("Relay requested reconnect",
+			"pop_id", n.POPID,
+			"pop_address", n.POPAddress,
+			"reason", n.Message)
+		// Переподключение закрывает heartbeat stream, поэтому выполняется вне цикла приема
+		go c.reconnectOnRequest(n)
+
+	default:
+		c.logger.Debug("Ignoring unknown server notification", "type", n.Type)
+	}
+}
+
+// reconnectOnRequest moves the session to the POP requested by the relay, or reconnects
+// to the current one when no target is given
+func (c *Client) reconnectOnRequest(n interfaces.ServerNotification) {
+	if selector := c.GetPOPSelector(); selector != nil && n.POPID != "" && selector.Current().ID != n.POPID {
+		// Смена POP через селектор вызывает handlePOPSwitch, который выполняет Reconnect
+		err := selector.SwitchTo(n.POPID)
+		if err == nil {
+			return
+		}
+		c.logger.Warn("Requested relay POP is not configured", "pop_id", n.POPID, "error", err)
+	}
+
+	if n.POPAddress != "" {
+		ep, err := endpointFromAddress(n.POPID, n.POPAddress)
+		if err != nil {
+			c.logger.Warn("Invalid relay POP address in reconnect request", "address", n.POPAddress, "error", err)
+		} else {
+			c.applyRelayEndpoint(ep)
+			if c.autoSwitchMgr != nil {
+				c.autoSwitchMgr.RefreshHealthCheckers()
+			}
+		}
+	}
+
+	if err := c.Reconnect(); err != nil {
+		c.logger.Error("Reconnect requested by relay failed", "error", err)
+	}
+}
+
+// endpointFromAddress builds a POP endpoint from a host:port pair
+func endpointFromAddress(id, address string) (*pop.Endpoint, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port: %w", err)
+	}
+	if id == "" {
+		id = host
+	}
+	return &pop.Endpoint{ID: id, Host: host, Port: port}, nil
+}
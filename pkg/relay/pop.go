@@ -0,0 +1,168 @@
+package relay
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/masque"
+	"github.com/2gc-dev/cloudbridge-client/pkg/pop"
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+)
+
+// relayEndpointsFromConfig builds the list of relay POPs from configuration.
+// When relay.endpoints is empty, relay.host/relay.port form a single POP.
+func relayEndpointsFromConfig(cfg *types.Config) []*pop.Endpoint {
+	if len(cfg.Relay.Endpoints) == 0 {
+		return []*pop.Endpoint{{
+			ID:         cfg.Relay.Host,
+			Host:       cfg.Relay.Host,
+			Port:       cfg.Relay.Port,
+			ServerName: cfg.Relay.TLS.ServerName,
+		}}
+	}
+
+	endpoints := make([]*pop.Endpoint, 0, len(cfg.Relay.Endpoints))
+	for _, ep := range cfg.Relay.Endpoints {
+		e := &pop.Endpoint{
+			ID:         ep.ID,
+			Host:       ep.Host,
+			Port:       ep.Port,
+			Region:     ep.Region,
+			ServerName: ep.ServerName,
+		}
+		if e.ID == "" {
+			e.ID = ep.Host
+		}
+		if e.Port == 0 {
+			e.Port = cfg.Relay.Port
+		}
+		if e.ServerName == "" {
+			e.ServerName = cfg.Relay.TLS.ServerName
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints
+}
+
+// initializePOPSelector creates the POP selector when several relay endpoints are configured
+// and points the client configuration at the best one before the first connection.
+func (c *Client) initializePOPSelector() error {
+	endpoints := relayEndpointsFromConfig(c.config)
+	if len(endpoints) < 2 || !c.config.Relay.Selection.Enabled {
+		c.applyRelayEndpoint(endpoints[0])
+		return nil
+	}
+
+	selectorConfig := pop.DefaultSelectorConfig()
+	if c.config.Relay.Selection.ProbeInterval > 0 {
+		selectorConfig.ProbeInterval = c.config.Relay.Selection.ProbeInterval
+	}
+	if c.config.Relay.Selection.ProbeTimeout > 0 {
+		selectorConfig.ProbeTimeout = c.config.Relay.Selection.ProbeTimeout
+	}
+	selectorConfig.PreferredRegion = c.config.Relay.Selection.PreferredRegion
+
+	selector, err := pop.NewSelector(endpoints, selectorConfig, c.probeManager, c.sloController, c.handoverManager, c.logger)
+	if err != nil {
+		return fmt.Errorf("failed to create POP selector: %w", err)
+	}
+	c.popSelector = selector
+
+	// Начальный выбор (from == nil) применяется синхронно внутри Start
+	selector.AddSwitchCallback(c.handlePOPSwitch)
+	if err := selector.Start(); err != nil {
+		return fmt.Errorf("failed to start POP selector: %w", err)
+	}
+
+	// Если ни один POP не ответил, используем первый из списка
+	if current := selector.Current(); c.config.Relay.Host != current.Host {
+		c.applyRelayEndpoint(current)
+	}
+	return nil
+}
+
+// applyRelayEndpoint points the relay configuration and the MASQUE client at the given POP
+func (c *Client) applyRelayEndpoint(ep *pop.Endpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.Relay.Host = ep.Host
+	c.config.Relay.Port = ep.Port
+	if ep.ServerName != "" {
+		c.config.Relay.TLS.ServerName = ep.ServerName
+	}
+	if c.p2pManager != nil && c.config.Relay.Ports.QUIC > 0 {
+		c.p2pManager.SetRelayAddress(net.JoinHostPort(ep.Host, strconv.Itoa(c.config.Relay.Ports.QUIC)))
+	}
+
+	if c.masqueClient != nil {
+		if err := c.masqueClient.Close(); err != nil {
+			c.logger.Warn("Failed to close MASQUE client", "error", err)
+		}
+		c.masqueClient = nil
+	}
+
+	masqueConfig := masque.DefaultMASQUEConfig()
+	masqueConfig.ServerName = ep.ServerName
+	if masqueConfig.ServerName == "" {
+		masqueConfig.ServerName = ep.Host
+	}
+	masqueConfig.InsecureSkipVerify = !c.config.Relay.TLS.VerifyCert
+	masqueClient, err := masque.NewMASQUEClient(masqueConfig)
+	if err != nil {
+		c.logger.Warn("Failed to create MASQUE client", "pop", ep.ID, "error", err)
+		return
+	}
+	c.masqueClient = masqueClient
+}
+
+// handlePOPSwitch is invoked by the POP selector when a better relay POP is chosen
+func (c *Client) handlePOPSwitch(from, to *pop.Endpoint) {
+	c.applyRelayEndpoint(to)
+	if c.autoSwitchMgr != nil {
+		// Health checks транспортов должны проверять новый POP
+		c.autoSwitchMgr.RefreshHealthCheckers()
+	}
+
+	if from == nil || !c.IsConnected() {
+		return
+	}
+
+	c.logger.Info("Moving session to another relay POP", "from", from.ID, "to", to.ID)
+	go func() {
+		if err := c.Reconnect(); err != nil {
+			c.logger.Error("Failed to move session to relay POP", "pop", to.ID, "error", err)
+		}
+	}()
+}
+
+// restoreTunnels re-registers locally running tunnels on the relay after a reconnect.
+// Local listeners keep running, so existing client sockets are not dropped.
+func (c *Client) restoreTunnels() error {
+	c.mu.RLock()
+	useTA := c.useTransportAdapter && c.transportAdapter != nil
+	tenantID := c.tenantID
+	c.mu.RUnlock()
+
+	if !useTA {
+		return nil
+	}
+
+	for _, t := range c.tunnelManager.ListTunnels() {
+		if !t.IsActive() {
+			continue
+		}
+		if err := c.transportAdapter.CreateTunnel(t.ID, tenantID, t.LocalPort, t.RemoteHost, t.RemotePort); err != nil {
+			return fmt.Errorf("failed to restore tunnel %s: %w", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetPOPSelector returns the relay POP selector (nil when a single relay is configured)
+func (c *Client) GetPOPSelector() *pop.Selector {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.popSelector
+}
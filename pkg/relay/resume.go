@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"fmt"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport"
+)
+
+// storeContinuationToken keeps the relay-issued continuation token for a later session resume
+func (c *Client) storeContinuationToken(tokenStr string) {
+	if c.handoverManager == nil {
+		return
+	}
+	if tokenStr == "" {
+		// Relay не поддерживает resume — при переподключении выполняется полная аутентификация
+		c.handoverManager.ClearToken()
+		return
+	}
+	if _, err := c.handoverManager.StoreIssuedToken(tokenStr); err != nil {
+		c.logger.Warn("Ignoring invalid continuation token from relay", "error", err)
+		c.handoverManager.ClearToken()
+	}
+}
+
+// resumeSession presents the continuation token to the (possibly new) relay and restores
+// the client ID, tunnels and P2P session without re-authentication.
+func (c *Client) resumeSession() error {
+	c.mu.RLock()
+	useTA := c.useTransportAdapter && c.transportAdapter != nil
+	token := c.tokenString
+	clientID := c.clientID
+	tenantID := c.tenantID
+	c.mu.RUnlock()
+
+	if !useTA {
+		return fmt.Errorf("session resume requires gRPC transport")
+	}
+	if c.handoverManager == nil {
+		return fmt.Errorf("handover manager not initialized")
+	}
+	continuationToken, ok := c.handoverManager.ResumeToken()
+	if !ok {
+		return fmt.Errorf("no valid continuation token")
+	}
+
+	activeTunnels := make(map[string]bool)
+	tunnelIDs := make([]string, 0)
+	for _, t := range c.tunnelManager.ListTunnels() {
+		if t.IsActive() {
+			activeTunnels[t.ID] = true
+			tunnelIDs = append(tunnelIDs, t.ID)
+		}
+	}
+
+	req := &transport.ResumeRequest{
+		Token:             token,
+		ContinuationToken: continuationToken,
+		ClientID:          clientID,
+		TunnelIDs:         tunnelIDs,
+	}
+	if p2pManager := c.GetP2PManager(); p2pManager != nil {
+		req.P2PSessionID = p2pManager.GetRelaySessionID()
+	}
+
+	result, err := c.transportAdapter.Resume(req)
+	if err != nil {
+		// Токен отклонен или устарел — повторно его не используем
+		c.handoverManager.ClearToken()
+		return fmt.Errorf("failed to resume session: %w", err)
+	}
+	c.storeContinuationToken(result.ContinuationToken)
+
+	c.mu.Lock()
+	if result.ClientID != "" {
+		c.clientID = result.ClientID
+	}
+	if tenantID == "" {
+		c.tenantID = result.TenantID
+	}
+	tenantID = c.tenantID
+	c.mu.Unlock()
+
+	// Туннели, которые relay не смог восстановить, регистрируем заново
+	for _, id := range result.ResumedTunnelIDs {
+		delete(activeTunnels, id)
+	}
+	for _, t := range c.tunnelManager.ListTunnels() {
+		if !activeTunnels[t.ID] {
+			continue
+		}
+		if err := c.transportAdapter.CreateTunnel(t.ID, tenantID, t.LocalPort, t.RemoteHost, t.RemotePort); err != nil {
+			return fmt.Errorf("failed to restore tunnel %s: %w", t.ID, err)
+		}
+	}
+
+	c.logger.Info("Relay session resumed",
+		"client_id", result.ClientID,
+		"resumed_tunnels", len(result.ResumedTunnelIDs),
+		"restored_tunnels", len(activeTunnels))
+	return nil
+}
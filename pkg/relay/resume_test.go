@@ -0,0 +1,218 @@
+package relay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/handover"
+	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport/proto"
+	"github.com/2gc-dev/cloudbridge-client/pkg/types"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// resumeRelay fake relay control service that issues continuation tokens
+type resumeRelay struct {
+	proto.UnimplementedControlServiceServer
+
+	mu         sync.Mutex
+	issue      func(connID string) string // serialized continuation token for a session
+	auths      int
+	resumed    []string // continuation tokens presented in Resume
+	issued     string   // last issued token
+	sessions   int
+	reject     bool // Resume rejects any token
+	expireNext bool // the next issued token is already expired
+}
+
+func (r *resumeRelay) nextTokenLocked() string {
+	r.sessions++
+	r.issued = r.issue("conn-" + strconv.Itoa(r.sessions))
+	return r.issued
+}
+
+func (r *resumeRelay) Hello(ctx context.Context, req *proto.HelloRequest) (*proto.HelloResponse, error) {
+	return &proto.HelloResponse{Status: "ok", ServerVersion: "test", SessionId: "session-1"}, nil
+}
+
+func (r *resumeRelay) Authenticate(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auths++
+	return &proto.AuthResponse{
+		Status:            "ok",
+		ClientId:          "client-1",
+		TenantId:          "tenant-1",
+		ContinuationToken: r.nextTokenLocked(),
+	}, nil
+}
+
+func (r *resumeRelay) Resume(ctx context.Context, req *proto.ResumeRequest) (*proto.ResumeResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resumed = append(r.resumed, req.ContinuationToken)
+	if r.reject || req.ContinuationToken != r.issued {
+		return &proto.ResumeResponse{Status: "error", ErrorMessage: "continuation token rejected"}, nil
+	}
+	token := r.nextTokenLocked()
+	if r.expireNext {
+		r.expireNext = false
+		token = r.issue("expired")
+		r.issued = token
+	}
+	return &proto.ResumeResponse{Status: "ok", ClientId: req.ClientId, TenantId: "tenant-1", ContinuationToken: token}, nil
+}
+
+func (r *resumeRelay) state() (auths int, resumed []string, issued string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.auths, append([]string(nil), r.resumed...), r.issued
+}
+
+// startTestRelay запускает fake relay с TLS (gRPC клиент всегда использует TLS) и возвращает его порт
+func startTestRelay(t *testing.T, register func(server *grpc.Server)) int {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS13,
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.Creds(creds))
+	register(server)
+	go server.Serve(listener) //nolint:errcheck // returns on Stop
+	t.Cleanup(server.Stop)
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TestClient_ResumeSession tests the continuation token lifecycle across reconnects
+func TestClient_ResumeSession(t *testing.T) {
+	const secret = "resume-test-secret"
+	relay := &resumeRelay{}
+	port := startTestRelay(t, func(server *grpc.Server) { proto.RegisterControlServiceServer(server, relay) })
+
+	client, err := NewClient(&types.Config{
+		Auth:  types.AuthConfig{Type: "jwt", Secret: secret},
+		Relay: types.RelayConfig{Host: "127.0.0.1", Port: port},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close() //nolint:errcheck // test cleanup
+
+	hm := client.handoverManager
+	relay.issue = func(connID string) string {
+		expiresAt := time.Now().Add(time.Hour)
+		if connID == "expired" {
+			expiresAt = time.Now().Add(-time.Minute)
+		}
+		token, err := hm.SerializeToken(&handover.ContinuationToken{ConnID: connID, ServerID: "relay-1", ExpiresAt: expiresAt})
+		if err != nil {
+			t.Errorf("Failed to serialize token: %v", err)
+		}
+		return token
+	}
+
+	jwtToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       "client-1",
+		"tenant_id": "tenant-1",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Failed to sign JWT: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := client.Authenticate(jwtToken); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	_, _, first := relay.state()
+	if token, ok := hm.ResumeToken(); !ok || token != first {
+		t.Fatal("Continuation token from Authenticate not stored")
+	}
+
+	// Переподключение предъявляет токен и не проходит аутентификацию заново
+	if err := client.Reconnect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	auths, resumed, second := relay.state()
+	if auths != 1 || len(resumed) != 1 || resumed[0] != first {
+		t.Fatalf("Expected resume with %q and one authentication, got auths=%d resumed=%v", first, auths, resumed)
+	}
+	if token, _ := hm.ResumeToken(); token != second {
+		t.Error("Continuation token not renewed after resume")
+	}
+
+	// Отклоненный токен сбрасывается, клиент открывает новую сессию
+	relay.mu.Lock()
+	relay.reject = true
+	relay.mu.Unlock()
+	if err := client.Reconnect(); err != nil {
+		t.Fatalf("Reconnect after rejected resume failed: %v", err)
+	}
+	auths, resumed, fresh := relay.state()
+	if auths != 2 || len(resumed) != 2 || resumed[1] != second {
+		t.Fatalf("Expected fallback authentication after rejected %q, got auths=%d resumed=%v", second, auths, resumed)
+	}
+	if token, _ := hm.ResumeToken(); token != fresh {
+		t.Error("Rejected continuation token not replaced by the fresh session token")
+	}
+
+	// Истекший токен не сохраняется: следующее переподключение идет без Resume
+	relay.mu.Lock()
+	relay.reject, relay.expireNext = false, true
+	relay.mu.Unlock()
+	if err := client.Reconnect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if _, ok := hm.ResumeToken(); ok {
+		t.Fatal("Expired continuation token kept")
+	}
+	if err := client.Reconnect(); err != nil {
+		t.Fatalf("Reconnect with expired token failed: %v", err)
+	}
+	auths, resumed, _ = relay.state()
+	if auths != 3 || len(resumed) != 3 {
+		t.Errorf("Expected fresh session without resume, got auths=%d resumed=%d", auths, len(resumed))
+	}
+
+	// Disconnect завершает сессию: токен больше не предъявляется
+	if _, ok := hm.ResumeToken(); !ok {
+		t.Fatal("No continuation token before Disconnect")
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	if _, ok := hm.ResumeToken(); ok {
+		t.Error("Continuation token kept after Disconnect")
+	}
+}
@@ -0,0 +1,43 @@
+package relay
+
+import (
+	"context"
+	"net"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/socks"
+)
+
+// startSOCKSProxy starts the local SOCKS5 proxy once the P2P mesh runs, so applications can reach
+// overlay IPs when WireGuard has no system interface (netstack mode). Failures are logged only.
+func (c *Client) startSOCKSProxy() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.socksProxy != nil || !c.config.P2P.SOCKS5.Enabled {
+		return
+	}
+	proxy := socks.NewServer(&socks.Config{ListenAddr: c.config.P2P.SOCKS5.ListenAddr}, c.DialContext, c.logger)
+	if err := proxy.Start(); err != nil {
+		c.logger.Warn("Failed to start SOCKS5 proxy", "error", err)
+		return
+	}
+	c.socksProxy = proxy
+}
+
+// stopSOCKSProxyLocked stops the SOCKS5 proxy. Called under c.mu.
+func (c *Client) stopSOCKSProxyLocked() {
+	if c.socksProxy == nil {
+		return
+	}
+	c.socksProxy.Stop()
+	c.socksProxy = nil
+}
+
+// DialContext connects to address through the P2P mesh: overlay IPs go through the embedded
+// WireGuard netstack, everything else (or everything before the mesh starts) through the system network
+func (c *Client) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if manager := c.GetP2PManager(); manager != nil {
+		return manager.DialContext(ctx, network, address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
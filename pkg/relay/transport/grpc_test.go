@@ -33,11 +33,26 @@ func (s *mockGRPCServer) Hello(ctx context.Context, req *proto.HelloRequest) (*p
 
 func (s *mockGRPCServer) Authenticate(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
 	return &proto.AuthResponse{
-		Status:       "ok",
-		ClientId:     "test-client-123",
-		TenantId:     "test-tenant-1",
-		SessionToken: "test-session-token",
-		ExpiresAt:    timestamppb.New(time.Now().Add(24 * time.Hour)),
+		Status:            "ok",
+		ClientId:          "test-client-123",
+		TenantId:          "test-tenant-1",
+		SessionToken:      "test-session-token",
+		ExpiresAt:         timestamppb.New(time.Now().Add(24 * time.Hour)),
+		ContinuationToken: "test-continuation-token",
+	}, nil
+}
+
+func (s *mockGRPCServer) Resume(ctx context.Context, req *proto.ResumeRequest) (*proto.ResumeResponse, error) {
+	if req.ContinuationToken != "test-continuation-token" {
+		return &proto.ResumeResponse{Status: "error", ErrorMessage: "invalid continuation token"}, nil
+	}
+	return &proto.ResumeResponse{
+		Status:            "ok",
+		ClientId:          req.ClientId,
+		TenantId:          "test-tenant-1",
+		ResumedTunnelIds:  req.TunnelIds,
+		ContinuationToken: "test-continuation-token-2",
+		ExpiresAt:         timestamppb.New(time.Now().Add(5 * time.Minute)),
 	}, nil
 }
 
@@ -72,6 +87,31 @@ func (s *mockGRPCServer) SendHeartbeat(ctx context.Context, req *proto.Heartbeat
 	}, nil
 }
 
+func (s *mockGRPCServer) StreamHeartbeat(stream proto.HeartbeatService_StreamHeartbeatServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if err := stream.Send(&proto.HeartbeatResponse{
+			Status:          "ok",
+			ServerTimestamp: timestamppb.Now(),
+			IntervalSeconds: 15,
+			ServerMetrics: &proto.ServerMetrics{
+				ConnectedClients: 5,
+				ActiveTunnels:    req.GetMetrics().GetActiveTunnels(),
+			},
+			Notifications: []*proto.ServerNotification{{
+				Type:     "tunnel_revoked",
+				TunnelId: "test-tunnel-1",
+				Message:  "revoked by admin",
+			}},
+		}); err != nil {
+			return err
+		}
+	}
+}
+
 // createTestGRPCServer creates a test gRPC server with bufconn
 func createTestGRPCServer() (*grpc.Server, *bufconn.Listener) {
 	buffer := 101024 * 1024
@@ -179,6 +219,37 @@ func TestGRPCTransport_HelloAuthCreateTunnel(t *testing.T) {
 		t.Errorf("Expected tenant ID 'test-tenant-1', got '%s'", authResult.TenantID)
 	}
 
+	if authResult.ContinuationToken != "test-continuation-token" {
+		t.Errorf("Expected continuation token 'test-continuation-token', got '%s'", authResult.ContinuationToken)
+	}
+
+	// Test Resume with the issued continuation token
+	resumeResult, err := transport.Resume(&ResumeRequest{
+		Token:             "test-jwt-token",
+		ContinuationToken: authResult.ContinuationToken,
+		ClientID:          authResult.ClientID,
+		TunnelIDs:         []string{"test-tunnel-1"},
+	})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if resumeResult.Status != "ok" {
+		t.Errorf("Expected Resume status 'ok', got '%s'", resumeResult.Status)
+	}
+
+	if resumeResult.ClientID != "test-client-123" {
+		t.Errorf("Expected resumed client ID 'test-client-123', got '%s'", resumeResult.ClientID)
+	}
+
+	if len(resumeResult.ResumedTunnelIDs) != 1 || resumeResult.ResumedTunnelIDs[0] != "test-tunnel-1" {
+		t.Errorf("Expected resumed tunnel 'test-tunnel-1', got %v", resumeResult.ResumedTunnelIDs)
+	}
+
+	if resumeResult.ContinuationToken != "test-continuation-token-2" {
+		t.Errorf("Expected fresh continuation token, got '%s'", resumeResult.ContinuationToken)
+	}
+
 	// Test CreateTunnel
 	tunnelResult, err := transport.CreateTunnel("test-tunnel-1", "test-tenant-1", 8080, "192.168.1.100", 80)
 	if err != nil {
@@ -220,6 +291,56 @@ func TestGRPCTransport_HelloAuthCreateTunnel(t *testing.T) {
 	}
 }
 
+// TestGRPCTransport_StreamHeartbeat tests the bidirectional heartbeat stream
+func TestGRPCTransport_StreamHeartbeat(t *testing.T) {
+	server, lis := createTestGRPCServer()
+	defer server.Stop()
+
+	conn, err := createTestGRPCClient(lis)
+	if err != nil {
+		t.Fatalf("Failed to create test gRPC client: %v", err)
+	}
+	defer conn.Close()
+
+	logger := newTestLogger()
+	grpcClient := &GRPCClient{
+		config:    &types.Config{},
+		conn:      conn,
+		logger:    logger,
+		connected: true,
+		ctx:       context.Background(),
+	}
+	transport := NewGRPCTransport(grpcClient, logger)
+
+	stream, err := transport.StreamHeartbeat("test-client-123", "test-tenant-1")
+	if err != nil {
+		t.Fatalf("StreamHeartbeat failed: %v", err)
+	}
+	defer stream.Close()
+
+	if err := stream.Send(&ClientMetrics{ActiveTunnels: 2}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	result, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if result.IntervalSeconds != 15 {
+		t.Errorf("Expected interval 15 seconds, got %d", result.IntervalSeconds)
+	}
+
+	if result.ServerMetrics == nil || result.ServerMetrics.ActiveTunnels != 2 {
+		t.Errorf("Expected server metrics with 2 active tunnels, got %+v", result.ServerMetrics)
+	}
+
+	if len(result.Notifications) != 1 || result.Notifications[0].Type != "tunnel_revoked" ||
+		result.Notifications[0].TunnelID != "test-tunnel-1" {
+		t.Errorf("Expected tunnel_revoked notification, got %+v", result.Notifications)
+	}
+}
+
 // TestGRPCTransport_ConnectionStates tests connection state handling
 func TestGRPCTransport_ConnectionStates(t *testing.T) {
 	// Create test config
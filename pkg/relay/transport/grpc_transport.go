@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/2gc-dev/cloudbridge-client/pkg/relay/transport/proto"
@@ -13,8 +14,10 @@ import (
 
 // GRPCTransport implements the Transport interface using gRPC
 type GRPCTransport struct {
-	client *GRPCClient
-	logger Logger
+	client        *GRPCClient
+	logger        Logger
+	dataPlaneMode string // Data-plane transport reported with tunnels
+	mu            sync.RWMutex
 }
 
 // NewGRPCTransport creates a new gRPC transport
@@ -25,6 +28,13 @@ func NewGRPCTransport(client *GRPCClient, logger Logger) *GRPCTransport {
 	}
 }
 
+// SetDataPlaneMode sets the data-plane transport mode announced when tunnels are created
+func (gt *GRPCTransport) SetDataPlaneMode(mode string) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	gt.dataPlaneMode = mode
+}
+
 // Connect establishes connection to the relay server
 func (gt *GRPCTransport) Connect() error {
 	return gt.client.Connect()
@@ -127,15 +137,67 @@ func (gt *GRPCTransport) Authenticate(token string) (*AuthResult, error) {
 
 	// Convert response
 	result := &AuthResult{
-		Status:       resp.Status,
-		ClientID:     resp.ClientId,
-		TenantID:     resp.TenantId,
-		SessionToken: resp.SessionToken,
-		ExpiresAt:    resp.ExpiresAt.AsTime(),
-		ErrorMessage: resp.ErrorMessage,
+		Status:            resp.Status,
+		ClientID:          resp.ClientId,
+		TenantID:          resp.TenantId,
+		SessionToken:      resp.SessionToken,
+		ExpiresAt:         resp.ExpiresAt.AsTime(),
+		ContinuationToken: resp.ContinuationToken,
+		ErrorMessage:      resp.ErrorMessage,
+	}
+
+	gt.logger.Info("gRPC Authentication completed", "status", result.Status, "client_id", result.ClientID,
+		"resumable", result.ContinuationToken != "")
+	return result, nil
+}
+
+// Resume restores a session on the relay using a continuation token
+func (gt *GRPCTransport) Resume(req *ResumeRequest) (*ResumeResult, error) {
+	if !gt.client.IsConnected() {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	gt.logger.Debug("Sending gRPC Resume request", "client_id", req.ClientID, "tunnels", len(req.TunnelIDs))
+
+	client := proto.NewControlServiceClient(gt.client.GetConnection())
+
+	authToken := req.Token
+	if envToken := os.Getenv("CLOUDBRIDGE_TOKEN"); envToken != "" {
+		authToken = envToken
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if authToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+authToken)
+	}
+
+	resp, err := client.Resume(ctx, &proto.ResumeRequest{
+		Token:             authToken,
+		ContinuationToken: req.ContinuationToken,
+		ClientId:          req.ClientID,
+		TunnelIds:         req.TunnelIDs,
+		P2PSessionId:      req.P2PSessionID,
+		Timestamp:         timestamppb.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gRPC Resume failed: %w", err)
+	}
+
+	result := &ResumeResult{
+		Status:            resp.Status,
+		ClientID:          resp.ClientId,
+		TenantID:          resp.TenantId,
+		ResumedTunnelIDs:  resp.ResumedTunnelIds,
+		ContinuationToken: resp.ContinuationToken,
+		ErrorMessage:      resp.ErrorMessage,
+	}
+	if resp.ExpiresAt != nil {
+		result.ExpiresAt = resp.ExpiresAt.AsTime()
 	}
 
-	gt.logger.Info("gRPC Authentication completed", "status", result.Status, "client_id", result.ClientID)
+	gt.logger.Info("gRPC Resume completed", "status", result.Status, "client_id", result.ClientID,
+		"resumed_tunnels", len(result.ResumedTunnelIDs))
 	return result, nil
 }
 
@@ -155,6 +217,10 @@ func (gt *GRPCTransport) CreateTunnel(tunnelID, tenantID string, localPort int,
 	// Create gRPC client
 	client := proto.NewTunnelServiceClient(gt.client.GetConnection())
 
+	gt.mu.RLock()
+	dataPlaneMode := gt.dataPlaneMode
+	gt.mu.RUnlock()
+
 	// Create request
 	req := &proto.CreateTunnelRequest{
 		TunnelId:   tunnelID,
@@ -168,6 +234,7 @@ func (gt *GRPCTransport) CreateTunnel(tunnelID, tenantID string, localPort int,
 			TimeoutSeconds:     30,
 			CompressionEnabled: false,
 			EncryptionEnabled:  true,
+			TransportMode:      dataPlaneMode,
 		},
 		Timestamp: timestamppb.Now(),
 	}
@@ -210,7 +277,27 @@ func (gt *GRPCTransport) SendHeartbeat(clientID, tenantID string, metrics *Clien
 	// Create gRPC client
 	client := proto.NewHeartbeatServiceClient(gt.client.GetConnection())
 
-	// Convert metrics
+	// Create request
+	req := newHeartbeatRequest(clientID, tenantID, metrics)
+
+	// Make gRPC call with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.SendHeartbeat(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC SendHeartbeat failed: %w", err)
+	}
+
+	// Convert response
+	result := heartbeatResultFromProto(resp)
+
+	gt.logger.Debug("gRPC Heartbeat completed", "status", result.Status)
+	return result, nil
+}
+
+// newHeartbeatRequest converts client metrics into a heartbeat request
+func newHeartbeatRequest(clientID, tenantID string, metrics *ClientMetrics) *proto.HeartbeatRequest {
 	var protoMetrics *proto.ClientMetrics
 	if metrics != nil {
 		var lastSwitch *timestamppb.Timestamp
@@ -232,25 +319,17 @@ func (gt *GRPCTransport) SendHeartbeat(clientID, tenantID string, metrics *Clien
 		}
 	}
 
-	// Create request
-	req := &proto.HeartbeatRequest{
+	return &proto.HeartbeatRequest{
 		ClientId:      clientID,
 		TenantId:      tenantID,
 		Timestamp:     timestamppb.Now(),
 		Metrics:       protoMetrics,
 		TransportMode: "grpc",
 	}
+}
 
-	// Make gRPC call with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	resp, err := client.SendHeartbeat(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("gRPC SendHeartbeat failed: %w", err)
-	}
-
-	// Convert response
+// heartbeatResultFromProto converts a heartbeat response, including server metrics and notifications
+func heartbeatResultFromProto(resp *proto.HeartbeatResponse) *HeartbeatResult {
 	result := &HeartbeatResult{
 		Status:          resp.Status,
 		ServerTimestamp: resp.ServerTimestamp.AsTime(),
@@ -258,8 +337,32 @@ func (gt *GRPCTransport) SendHeartbeat(clientID, tenantID string, metrics *Clien
 		ErrorMessage:    resp.ErrorMessage,
 	}
 
-	gt.logger.Debug("gRPC Heartbeat completed", "status", result.Status)
-	return result, nil
+	if sm := resp.ServerMetrics; sm != nil {
+		result.ServerMetrics = &ServerMetrics{
+			ConnectedClients: sm.ConnectedClients,
+			ActiveTunnels:    sm.ActiveTunnels,
+			ServerLoad:       sm.ServerLoad,
+		}
+		if sm.Timestamp != nil {
+			result.ServerMetrics.Timestamp = sm.Timestamp.AsTime()
+		}
+	}
+
+	for _, n := range resp.Notifications {
+		notification := &ServerNotification{
+			Type:       n.Type,
+			TunnelID:   n.TunnelId,
+			POPID:      n.PopId,
+			POPAddress: n.PopAddress,
+			Message:    n.Message,
+		}
+		if n.ExpiresAt != nil {
+			notification.ExpiresAt = n.ExpiresAt.AsTime()
+		}
+		result.Notifications = append(result.Notifications, notification)
+	}
+
+	return result
 }
 
 // IsConnected returns connection status
@@ -277,10 +380,11 @@ func (gt *GRPCTransport) GetClient() *GRPCClient {
 	return gt.client
 }
 
-// StreamHeartbeat implements streaming heartbeat functionality
-func (gt *GRPCTransport) StreamHeartbeat(clientID, tenantID string, metricsChan <-chan *ClientMetrics) error {
+// StreamHeartbeat opens a long-lived bidirectional heartbeat stream.
+// The stream is bound to the connection context and ends when the transport is closed.
+func (gt *GRPCTransport) StreamHeartbeat(clientID, tenantID string) (HeartbeatStream, error) {
 	if !gt.client.IsConnected() {
-		return fmt.Errorf("not connected")
+		return nil, fmt.Errorf("not connected")
 	}
 
 	gt.logger.Debug("Starting gRPC streaming heartbeat", "client_id", clientID, "tenant_id", tenantID)
@@ -290,66 +394,55 @@ func (gt *GRPCTransport) StreamHeartbeat(clientID, tenantID string, metricsChan
 
 	// Create streaming context
 	ctx, cancel := context.WithCancel(gt.client.GetContext())
-	defer cancel()
 
 	// Start streaming heartbeat
 	stream, err := client.StreamHeartbeat(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to start streaming heartbeat: %w", err)
-	}
-
-	// Send heartbeats from metrics channel
-	go func() {
-		defer func() {
-			_ = stream.CloseSend() //nolint:errcheck // Ignore error in cleanup
-		}()
-
-		for metrics := range metricsChan {
-			// Convert metrics
-			var protoMetrics *proto.ClientMetrics
-			if metrics != nil {
-				var lastSwitch *timestamppb.Timestamp
-				if !metrics.LastSwitch.IsZero() {
-					lastSwitch = timestamppb.New(metrics.LastSwitch)
-				}
-
-				protoMetrics = &proto.ClientMetrics{
-					BytesSent:         metrics.BytesSent,
-					BytesReceived:     metrics.BytesReceived,
-					PacketsSent:       metrics.PacketsSent,
-					PacketsReceived:   metrics.PacketsReceived,
-					ActiveTunnels:     metrics.ActiveTunnels,
-					ActiveP2PSessions: metrics.ActiveP2PSessions,
-					CpuUsage:          metrics.CPUUsage,
-					MemoryUsage:       metrics.MemoryUsage,
-					TransportMode:     metrics.TransportMode,
-					LastSwitch:        lastSwitch,
-				}
-			}
-
-			// Create request
-			req := &proto.HeartbeatRequest{
-				ClientId:      clientID,
-				TenantId:      tenantID,
-				Timestamp:     timestamppb.Now(),
-				Metrics:       protoMetrics,
-				TransportMode: "grpc",
-			}
-
-			if err := stream.Send(req); err != nil {
-				gt.logger.Error("Failed to send streaming heartbeat", "error", err)
-				return
-			}
-		}
-	}()
+		cancel()
+		return nil, fmt.Errorf("failed to start streaming heartbeat: %w", err)
+	}
 
-	// Receive responses
-	for {
-		resp, err := stream.Recv()
-		if err != nil {
-			return fmt.Errorf("streaming heartbeat receive error: %w", err)
-		}
+	return &grpcHeartbeatStream{
+		stream:   stream,
+		cancel:   cancel,
+		clientID: clientID,
+		tenantID: tenantID,
+	}, nil
+}
+
+// grpcHeartbeatStream implements HeartbeatStream over the gRPC StreamHeartbeat RPC
+type grpcHeartbeatStream struct {
+	stream   proto.HeartbeatService_StreamHeartbeatClient
+	cancel   context.CancelFunc
+	clientID string
+	tenantID string
+	sendMu   sync.Mutex
+}
+
+// Send sends a heartbeat on the stream
+func (s *grpcHeartbeatStream) Send(metrics *ClientMetrics) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
 
-		gt.logger.Debug("Received streaming heartbeat response", "status", resp.Status)
+	if err := s.stream.Send(newHeartbeatRequest(s.clientID, s.tenantID, metrics)); err != nil {
+		return fmt.Errorf("failed to send streaming heartbeat: %w", err)
 	}
+	return nil
+}
+
+// Recv receives the next heartbeat response from the stream
+func (s *grpcHeartbeatStream) Recv() (*HeartbeatResult, error) {
+	resp, err := s.stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("streaming heartbeat receive error: %w", err)
+	}
+	return heartbeatResultFromProto(resp), nil
+}
+
+// Close cancels the stream context, unblocking pending Send and Recv calls
+func (s *grpcHeartbeatStream) Close() error {
+	s.cancel()
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.CloseSend()
 }
@@ -247,15 +247,17 @@ func (x *AuthRequest) GetTimestamp() *timestamppb.Timestamp {
 
 // AuthResponse contains authentication result
 type AuthResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
-	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
-	SessionToken  string                 `protobuf:"bytes,4,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
-	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
-	ErrorMessage  string                 `protobuf:"bytes,6,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Status       string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	ClientId     string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	TenantId     string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	SessionToken string                 `protobuf:"bytes,4,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	ExpiresAt    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	ErrorMessage string                 `protobuf:"bytes,6,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	// continuation_token is a relay-signed token used to resume the session on another POP
+	ContinuationToken string `protobuf:"bytes,7,opt,name=continuation_token,json=continuationToken,proto3" json:"continuation_token,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *AuthResponse) Reset() {
@@ -330,6 +332,192 @@ func (x *AuthResponse) GetErrorMessage() string {
 	return ""
 }
 
+func (x *AuthResponse) GetContinuationToken() string {
+	if x != nil {
+		return x.ContinuationToken
+	}
+	return ""
+}
+
+// ResumeRequest presents a continuation token to resume a session after handover
+type ResumeRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Token             string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	ContinuationToken string                 `protobuf:"bytes,2,opt,name=continuation_token,json=continuationToken,proto3" json:"continuation_token,omitempty"`
+	ClientId          string                 `protobuf:"bytes,3,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	TunnelIds         []string               `protobuf:"bytes,4,rep,name=tunnel_ids,json=tunnelIds,proto3" json:"tunnel_ids,omitempty"`
+	P2PSessionId      string                 `protobuf:"bytes,5,opt,name=p2p_session_id,json=p2pSessionId,proto3" json:"p2p_session_id,omitempty"`
+	Timestamp         *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ResumeRequest) Reset() {
+	*x = ResumeRequest{}
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeRequest) ProtoMessage() {}
+
+func (x *ResumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeRequest.ProtoReflect.Descriptor instead.
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResumeRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetContinuationToken() string {
+	if x != nil {
+		return x.ContinuationToken
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetTunnelIds() []string {
+	if x != nil {
+		return x.TunnelIds
+	}
+	return nil
+}
+
+func (x *ResumeRequest) GetP2PSessionId() string {
+	if x != nil {
+		return x.P2PSessionId
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+// ResumeResponse contains session resume result
+type ResumeResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Status           string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	ClientId         string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	TenantId         string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	ResumedTunnelIds []string               `protobuf:"bytes,4,rep,name=resumed_tunnel_ids,json=resumedTunnelIds,proto3" json:"resumed_tunnel_ids,omitempty"`
+	// continuation_token is a fresh token issued by the new POP
+	ContinuationToken string                 `protobuf:"bytes,5,opt,name=continuation_token,json=continuationToken,proto3" json:"continuation_token,omitempty"`
+	ExpiresAt         *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	ErrorMessage      string                 `protobuf:"bytes,7,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ResumeResponse) Reset() {
+	*x = ResumeResponse{}
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeResponse) ProtoMessage() {}
+
+func (x *ResumeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeResponse.ProtoReflect.Descriptor instead.
+func (*ResumeResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResumeResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ResumeResponse) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ResumeResponse) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ResumeResponse) GetResumedTunnelIds() []string {
+	if x != nil {
+		return x.ResumedTunnelIds
+	}
+	return nil
+}
+
+func (x *ResumeResponse) GetContinuationToken() string {
+	if x != nil {
+		return x.ContinuationToken
+	}
+	return ""
+}
+
+func (x *ResumeResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *ResumeResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
 // StatusRequest requests current status
 type StatusRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -340,7 +528,7 @@ type StatusRequest struct {
 
 func (x *StatusRequest) Reset() {
 	*x = StatusRequest{}
-	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[4]
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -352,7 +540,7 @@ func (x *StatusRequest) String() string {
 func (*StatusRequest) ProtoMessage() {}
 
 func (x *StatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[4]
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -365,7 +553,7 @@ func (x *StatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
 func (*StatusRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{4}
+	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *StatusRequest) GetClientId() string {
@@ -391,7 +579,7 @@ type StatusResponse struct {
 
 func (x *StatusResponse) Reset() {
 	*x = StatusResponse{}
-	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[5]
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -403,7 +591,7 @@ func (x *StatusResponse) String() string {
 func (*StatusResponse) ProtoMessage() {}
 
 func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[5]
+	mi := &file_pkg_relay_transport_proto_control_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -416,7 +604,7 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
 func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{5}
+	return file_pkg_relay_transport_proto_control_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *StatusResponse) GetStatus() string {
@@ -490,7 +678,7 @@ const file_pkg_relay_transport_proto_control_proto_rawDesc = "" +
 	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1b\n" +
 	"\tauth_type\x18\x02 \x01(\tR\bauthType\x12\x1b\n" +
 	"\tclient_id\x18\x03 \x01(\tR\bclientId\x128\n" +
-	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\xe5\x01\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\x94\x02\n" +
 	"\fAuthResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1b\n" +
 	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12\x1b\n" +
@@ -498,7 +686,25 @@ const file_pkg_relay_transport_proto_control_proto_rawDesc = "" +
 	"\rsession_token\x18\x04 \x01(\tR\fsessionToken\x129\n" +
 	"\n" +
 	"expires_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12#\n" +
-	"\rerror_message\x18\x06 \x01(\tR\ferrorMessage\",\n" +
+	"\rerror_message\x18\x06 \x01(\tR\ferrorMessage\x12-\n" +
+	"\x12continuation_token\x18\a \x01(\tR\x11continuationToken\"\xf0\x01\n" +
+	"\rResumeRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12-\n" +
+	"\x12continuation_token\x18\x02 \x01(\tR\x11continuationToken\x12\x1b\n" +
+	"\tclient_id\x18\x03 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"tunnel_ids\x18\x04 \x03(\tR\ttunnelIds\x12$\n" +
+	"\x0ep2p_session_id\x18\x05 \x01(\tR\fp2pSessionId\x128\n" +
+	"\ttimestamp\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\x9f\x02\n" +
+	"\x0eResumeResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1b\n" +
+	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12,\n" +
+	"\x12resumed_tunnel_ids\x18\x04 \x03(\tR\x10resumedTunnelIds\x12-\n" +
+	"\x12continuation_token\x18\x05 \x01(\tR\x11continuationToken\x129\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12#\n" +
+	"\rerror_message\x18\a \x01(\tR\ferrorMessage\",\n" +
 	"\rStatusRequest\x12\x1b\n" +
 	"\tclient_id\x18\x01 \x01(\tR\bclientId\"\x9b\x02\n" +
 	"\x0eStatusResponse\x12\x16\n" +
@@ -509,10 +715,11 @@ const file_pkg_relay_transport_proto_control_proto_rawDesc = "" +
 	"\n" +
 	"bytes_sent\x18\x05 \x01(\x03R\tbytesSent\x12%\n" +
 	"\x0ebytes_received\x18\x06 \x01(\x03R\rbytesReceived\x12#\n" +
-	"\rerror_message\x18\a \x01(\tR\ferrorMessage2\xc9\x01\n" +
+	"\rerror_message\x18\a \x01(\tR\ferrorMessage2\x86\x02\n" +
 	"\x0eControlService\x128\n" +
 	"\x05Hello\x12\x16.relay.v1.HelloRequest\x1a\x17.relay.v1.HelloResponse\x12=\n" +
-	"\fAuthenticate\x12\x15.relay.v1.AuthRequest\x1a\x16.relay.v1.AuthResponse\x12>\n" +
+	"\fAuthenticate\x12\x15.relay.v1.AuthRequest\x1a\x16.relay.v1.AuthResponse\x12;\n" +
+	"\x06Resume\x12\x17.relay.v1.ResumeRequest\x1a\x18.relay.v1.ResumeResponse\x12>\n" +
 	"\tGetStatus\x12\x17.relay.v1.StatusRequest\x1a\x18.relay.v1.StatusResponseBGZEgithub.com/2gc-dev/cloudbridge-client/pkg/relay/transport/proto;protob\x06proto3"
 
 var (
@@ -527,33 +734,39 @@ func file_pkg_relay_transport_proto_control_proto_rawDescGZIP() []byte {
 	return file_pkg_relay_transport_proto_control_proto_rawDescData
 }
 
-var file_pkg_relay_transport_proto_control_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_pkg_relay_transport_proto_control_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_pkg_relay_transport_proto_control_proto_goTypes = []any{
 	(*HelloRequest)(nil),          // 0: relay.v1.HelloRequest
 	(*HelloResponse)(nil),         // 1: relay.v1.HelloResponse
 	(*AuthRequest)(nil),           // 2: relay.v1.AuthRequest
 	(*AuthResponse)(nil),          // 3: relay.v1.AuthResponse
-	(*StatusRequest)(nil),         // 4: relay.v1.StatusRequest
-	(*StatusResponse)(nil),        // 5: relay.v1.StatusResponse
-	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(*ResumeRequest)(nil),         // 4: relay.v1.ResumeRequest
+	(*ResumeResponse)(nil),        // 5: relay.v1.ResumeResponse
+	(*StatusRequest)(nil),         // 6: relay.v1.StatusRequest
+	(*StatusResponse)(nil),        // 7: relay.v1.StatusResponse
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
 }
 var file_pkg_relay_transport_proto_control_proto_depIdxs = []int32{
-	6, // 0: relay.v1.HelloRequest.timestamp:type_name -> google.protobuf.Timestamp
-	6, // 1: relay.v1.HelloResponse.timestamp:type_name -> google.protobuf.Timestamp
-	6, // 2: relay.v1.AuthRequest.timestamp:type_name -> google.protobuf.Timestamp
-	6, // 3: relay.v1.AuthResponse.expires_at:type_name -> google.protobuf.Timestamp
-	6, // 4: relay.v1.StatusResponse.last_heartbeat:type_name -> google.protobuf.Timestamp
-	0, // 5: relay.v1.ControlService.Hello:input_type -> relay.v1.HelloRequest
-	2, // 6: relay.v1.ControlService.Authenticate:input_type -> relay.v1.AuthRequest
-	4, // 7: relay.v1.ControlService.GetStatus:input_type -> relay.v1.StatusRequest
-	1, // 8: relay.v1.ControlService.Hello:output_type -> relay.v1.HelloResponse
-	3, // 9: relay.v1.ControlService.Authenticate:output_type -> relay.v1.AuthResponse
-	5, // 10: relay.v1.ControlService.GetStatus:output_type -> relay.v1.StatusResponse
-	8, // [8:11] is the sub-list for method output_type
-	5, // [5:8] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	8,  // 0: relay.v1.HelloRequest.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 1: relay.v1.HelloResponse.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 2: relay.v1.AuthRequest.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 3: relay.v1.AuthResponse.expires_at:type_name -> google.protobuf.Timestamp
+	8,  // 4: relay.v1.ResumeRequest.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 5: relay.v1.ResumeResponse.expires_at:type_name -> google.protobuf.Timestamp
+	8,  // 6: relay.v1.StatusResponse.last_heartbeat:type_name -> google.protobuf.Timestamp
+	0,  // 7: relay.v1.ControlService.Hello:input_type -> relay.v1.HelloRequest
+	2,  // 8: relay.v1.ControlService.Authenticate:input_type -> relay.v1.AuthRequest
+	4,  // 9: relay.v1.ControlService.Resume:input_type -> relay.v1.ResumeRequest
+	6,  // 10: relay.v1.ControlService.GetStatus:input_type -> relay.v1.StatusRequest
+	1,  // 11: relay.v1.ControlService.Hello:output_type -> relay.v1.HelloResponse
+	3,  // 12: relay.v1.ControlService.Authenticate:output_type -> relay.v1.AuthResponse
+	5,  // 13: relay.v1.ControlService.Resume:output_type -> relay.v1.ResumeResponse
+	7,  // 14: relay.v1.ControlService.GetStatus:output_type -> relay.v1.StatusResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_pkg_relay_transport_proto_control_proto_init() }
@@ -567,7 +780,7 @@ func file_pkg_relay_transport_proto_control_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_relay_transport_proto_control_proto_rawDesc), len(file_pkg_relay_transport_proto_control_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	ControlService_Hello_FullMethodName        = "/relay.v1.ControlService/Hello"
 	ControlService_Authenticate_FullMethodName = "/relay.v1.ControlService/Authenticate"
+	ControlService_Resume_FullMethodName       = "/relay.v1.ControlService/Resume"
 	ControlService_GetStatus_FullMethodName    = "/relay.v1.ControlService/GetStatus"
 )
 
@@ -34,6 +35,8 @@ type ControlServiceClient interface {
 	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
 	// Authenticate performs JWT authentication
 	Authenticate(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// Resume restores a session on another relay POP using a continuation token
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error)
 	// GetStatus returns current connection status
 	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
 }
@@ -66,6 +69,16 @@ func (c *controlServiceClient) Authenticate(ctx context.Context, in *AuthRequest
 	return out, nil
 }
 
+func (c *controlServiceClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeResponse)
+	err := c.cc.Invoke(ctx, ControlService_Resume_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *controlServiceClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(StatusResponse)
@@ -86,6 +99,8 @@ type ControlServiceServer interface {
 	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
 	// Authenticate performs JWT authentication
 	Authenticate(context.Context, *AuthRequest) (*AuthResponse, error)
+	// Resume restores a session on another relay POP using a continuation token
+	Resume(context.Context, *ResumeRequest) (*ResumeResponse, error)
 	// GetStatus returns current connection status
 	GetStatus(context.Context, *StatusRequest) (*StatusResponse, error)
 	mustEmbedUnimplementedControlServiceServer()
@@ -104,6 +119,9 @@ func (UnimplementedControlServiceServer) Hello(context.Context, *HelloRequest) (
 func (UnimplementedControlServiceServer) Authenticate(context.Context, *AuthRequest) (*AuthResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Authenticate not implemented")
 }
+func (UnimplementedControlServiceServer) Resume(context.Context, *ResumeRequest) (*ResumeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
 func (UnimplementedControlServiceServer) GetStatus(context.Context, *StatusRequest) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
 }
@@ -164,6 +182,24 @@ func _ControlService_Authenticate_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ControlService_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_Resume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).Resume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ControlService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StatusRequest)
 	if err := dec(in); err != nil {
@@ -197,6 +233,10 @@ var ControlService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Authenticate",
 			Handler:    _ControlService_Authenticate_Handler,
 		},
+		{
+			MethodName: "Resume",
+			Handler:    _ControlService_Resume_Handler,
+		},
 		{
 			MethodName: "GetStatus",
 			Handler:    _ControlService_GetStatus_Handler,
@@ -107,6 +107,7 @@ type HeartbeatResponse struct {
 	IntervalSeconds int32                  `protobuf:"varint,3,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
 	ServerMetrics   *ServerMetrics         `protobuf:"bytes,4,opt,name=server_metrics,json=serverMetrics,proto3" json:"server_metrics,omitempty"`
 	ErrorMessage    string                 `protobuf:"bytes,5,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	Notifications   []*ServerNotification  `protobuf:"bytes,6,rep,name=notifications,proto3" json:"notifications,omitempty"`
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -176,6 +177,98 @@ func (x *HeartbeatResponse) GetErrorMessage() string {
 	return ""
 }
 
+func (x *HeartbeatResponse) GetNotifications() []*ServerNotification {
+	if x != nil {
+		return x.Notifications
+	}
+	return nil
+}
+
+// ServerNotification is pushed by the server on the heartbeat stream
+type ServerNotification struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // "tunnel_revoked", "token_expiring", "reconnect"
+	TunnelId      string                 `protobuf:"bytes,2,opt,name=tunnel_id,json=tunnelId,proto3" json:"tunnel_id,omitempty"`
+	PopId         string                 `protobuf:"bytes,3,opt,name=pop_id,json=popId,proto3" json:"pop_id,omitempty"`
+	PopAddress    string                 `protobuf:"bytes,4,opt,name=pop_address,json=popAddress,proto3" json:"pop_address,omitempty"`
+	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerNotification) Reset() {
+	*x = ServerNotification{}
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerNotification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerNotification) ProtoMessage() {}
+
+func (x *ServerNotification) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerNotification.ProtoReflect.Descriptor instead.
+func (*ServerNotification) Descriptor() ([]byte, []int) {
+	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ServerNotification) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ServerNotification) GetTunnelId() string {
+	if x != nil {
+		return x.TunnelId
+	}
+	return ""
+}
+
+func (x *ServerNotification) GetPopId() string {
+	if x != nil {
+		return x.PopId
+	}
+	return ""
+}
+
+func (x *ServerNotification) GetPopAddress() string {
+	if x != nil {
+		return x.PopAddress
+	}
+	return ""
+}
+
+func (x *ServerNotification) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ServerNotification) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
 // HealthRequest requests health status
 type HealthRequest struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
@@ -187,7 +280,7 @@ type HealthRequest struct {
 
 func (x *HealthRequest) Reset() {
 	*x = HealthRequest{}
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[2]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -199,7 +292,7 @@ func (x *HealthRequest) String() string {
 func (*HealthRequest) ProtoMessage() {}
 
 func (x *HealthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[2]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -212,7 +305,7 @@ func (x *HealthRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthRequest.ProtoReflect.Descriptor instead.
 func (*HealthRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{2}
+	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *HealthRequest) GetClientId() string {
@@ -246,7 +339,7 @@ type HealthResponse struct {
 
 func (x *HealthResponse) Reset() {
 	*x = HealthResponse{}
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[3]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -258,7 +351,7 @@ func (x *HealthResponse) String() string {
 func (*HealthResponse) ProtoMessage() {}
 
 func (x *HealthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[3]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -271,7 +364,7 @@ func (x *HealthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthResponse.ProtoReflect.Descriptor instead.
 func (*HealthResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{3}
+	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *HealthResponse) GetStatus() string {
@@ -349,7 +442,7 @@ type ClientMetrics struct {
 
 func (x *ClientMetrics) Reset() {
 	*x = ClientMetrics{}
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[4]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -361,7 +454,7 @@ func (x *ClientMetrics) String() string {
 func (*ClientMetrics) ProtoMessage() {}
 
 func (x *ClientMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[4]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -374,7 +467,7 @@ func (x *ClientMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientMetrics.ProtoReflect.Descriptor instead.
 func (*ClientMetrics) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{4}
+	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ClientMetrics) GetBytesSent() int64 {
@@ -460,7 +553,7 @@ type ServerMetrics struct {
 
 func (x *ServerMetrics) Reset() {
 	*x = ServerMetrics{}
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[5]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -472,7 +565,7 @@ func (x *ServerMetrics) String() string {
 func (*ServerMetrics) ProtoMessage() {}
 
 func (x *ServerMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[5]
+	mi := &file_pkg_relay_transport_proto_heartbeat_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -485,7 +578,7 @@ func (x *ServerMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerMetrics.ProtoReflect.Descriptor instead.
 func (*ServerMetrics) Descriptor() ([]byte, []int) {
-	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{5}
+	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ServerMetrics) GetConnectedClients() int32 {
@@ -526,13 +619,23 @@ const file_pkg_relay_transport_proto_heartbeat_proto_rawDesc = "" +
 	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x128\n" +
 	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x121\n" +
 	"\ametrics\x18\x04 \x01(\v2\x17.relay.v1.ClientMetricsR\ametrics\x12%\n" +
-	"\x0etransport_mode\x18\x05 \x01(\tR\rtransportMode\"\x82\x02\n" +
+	"\x0etransport_mode\x18\x05 \x01(\tR\rtransportMode\"\xc6\x02\n" +
 	"\x11HeartbeatResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12E\n" +
 	"\x10server_timestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x0fserverTimestamp\x12)\n" +
 	"\x10interval_seconds\x18\x03 \x01(\x05R\x0fintervalSeconds\x12>\n" +
 	"\x0eserver_metrics\x18\x04 \x01(\v2\x17.relay.v1.ServerMetricsR\rserverMetrics\x12#\n" +
-	"\rerror_message\x18\x05 \x01(\tR\ferrorMessage\"U\n" +
+	"\rerror_message\x18\x05 \x01(\tR\ferrorMessage\x12B\n" +
+	"\rnotifications\x18\x06 \x03(\v2\x1c.relay.v1.ServerNotificationR\rnotifications\"\xd2\x01\n" +
+	"\x12ServerNotification\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x1b\n" +
+	"\ttunnel_id\x18\x02 \x01(\tR\btunnelId\x12\x15\n" +
+	"\x06pop_id\x18\x03 \x01(\tR\x05popId\x12\x1f\n" +
+	"\vpop_address\x18\x04 \x01(\tR\n" +
+	"popAddress\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x129\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"U\n" +
 	"\rHealthRequest\x12\x1b\n" +
 	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12'\n" +
 	"\x0finclude_metrics\x18\x02 \x01(\bR\x0eincludeMetrics\"\xed\x02\n" +
@@ -582,37 +685,40 @@ func file_pkg_relay_transport_proto_heartbeat_proto_rawDescGZIP() []byte {
 	return file_pkg_relay_transport_proto_heartbeat_proto_rawDescData
 }
 
-var file_pkg_relay_transport_proto_heartbeat_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_pkg_relay_transport_proto_heartbeat_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_pkg_relay_transport_proto_heartbeat_proto_goTypes = []any{
 	(*HeartbeatRequest)(nil),      // 0: relay.v1.HeartbeatRequest
 	(*HeartbeatResponse)(nil),     // 1: relay.v1.HeartbeatResponse
-	(*HealthRequest)(nil),         // 2: relay.v1.HealthRequest
-	(*HealthResponse)(nil),        // 3: relay.v1.HealthResponse
-	(*ClientMetrics)(nil),         // 4: relay.v1.ClientMetrics
-	(*ServerMetrics)(nil),         // 5: relay.v1.ServerMetrics
-	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(*ServerNotification)(nil),    // 2: relay.v1.ServerNotification
+	(*HealthRequest)(nil),         // 3: relay.v1.HealthRequest
+	(*HealthResponse)(nil),        // 4: relay.v1.HealthResponse
+	(*ClientMetrics)(nil),         // 5: relay.v1.ClientMetrics
+	(*ServerMetrics)(nil),         // 6: relay.v1.ServerMetrics
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
 }
 var file_pkg_relay_transport_proto_heartbeat_proto_depIdxs = []int32{
-	6,  // 0: relay.v1.HeartbeatRequest.timestamp:type_name -> google.protobuf.Timestamp
-	4,  // 1: relay.v1.HeartbeatRequest.metrics:type_name -> relay.v1.ClientMetrics
-	6,  // 2: relay.v1.HeartbeatResponse.server_timestamp:type_name -> google.protobuf.Timestamp
-	5,  // 3: relay.v1.HeartbeatResponse.server_metrics:type_name -> relay.v1.ServerMetrics
-	6,  // 4: relay.v1.HealthResponse.last_heartbeat:type_name -> google.protobuf.Timestamp
-	4,  // 5: relay.v1.HealthResponse.client_metrics:type_name -> relay.v1.ClientMetrics
-	5,  // 6: relay.v1.HealthResponse.server_metrics:type_name -> relay.v1.ServerMetrics
-	6,  // 7: relay.v1.ClientMetrics.last_switch:type_name -> google.protobuf.Timestamp
-	6,  // 8: relay.v1.ServerMetrics.timestamp:type_name -> google.protobuf.Timestamp
-	0,  // 9: relay.v1.HeartbeatService.SendHeartbeat:input_type -> relay.v1.HeartbeatRequest
-	0,  // 10: relay.v1.HeartbeatService.StreamHeartbeat:input_type -> relay.v1.HeartbeatRequest
-	2,  // 11: relay.v1.HeartbeatService.GetHealth:input_type -> relay.v1.HealthRequest
-	1,  // 12: relay.v1.HeartbeatService.SendHeartbeat:output_type -> relay.v1.HeartbeatResponse
-	1,  // 13: relay.v1.HeartbeatService.StreamHeartbeat:output_type -> relay.v1.HeartbeatResponse
-	3,  // 14: relay.v1.HeartbeatService.GetHealth:output_type -> relay.v1.HealthResponse
-	12, // [12:15] is the sub-list for method output_type
-	9,  // [9:12] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	7,  // 0: relay.v1.HeartbeatRequest.timestamp:type_name -> google.protobuf.Timestamp
+	5,  // 1: relay.v1.HeartbeatRequest.metrics:type_name -> relay.v1.ClientMetrics
+	7,  // 2: relay.v1.HeartbeatResponse.server_timestamp:type_name -> google.protobuf.Timestamp
+	6,  // 3: relay.v1.HeartbeatResponse.server_metrics:type_name -> relay.v1.ServerMetrics
+	2,  // 4: relay.v1.HeartbeatResponse.notifications:type_name -> relay.v1.ServerNotification
+	7,  // 5: relay.v1.ServerNotification.expires_at:type_name -> google.protobuf.Timestamp
+	7,  // 6: relay.v1.HealthResponse.last_heartbeat:type_name -> google.protobuf.Timestamp
+	5,  // 7: relay.v1.HealthResponse.client_metrics:type_name -> relay.v1.ClientMetrics
+	6,  // 8: relay.v1.HealthResponse.server_metrics:type_name -> relay.v1.ServerMetrics
+	7,  // 9: relay.v1.ClientMetrics.last_switch:type_name -> google.protobuf.Timestamp
+	7,  // 10: relay.v1.ServerMetrics.timestamp:type_name -> google.protobuf.Timestamp
+	0,  // 11: relay.v1.HeartbeatService.SendHeartbeat:input_type -> relay.v1.HeartbeatRequest
+	0,  // 12: relay.v1.HeartbeatService.StreamHeartbeat:input_type -> relay.v1.HeartbeatRequest
+	3,  // 13: relay.v1.HeartbeatService.GetHealth:input_type -> relay.v1.HealthRequest
+	1,  // 14: relay.v1.HeartbeatService.SendHeartbeat:output_type -> relay.v1.HeartbeatResponse
+	1,  // 15: relay.v1.HeartbeatService.StreamHeartbeat:output_type -> relay.v1.HeartbeatResponse
+	4,  // 16: relay.v1.HeartbeatService.GetHealth:output_type -> relay.v1.HealthResponse
+	14, // [14:17] is the sub-list for method output_type
+	11, // [11:14] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_pkg_relay_transport_proto_heartbeat_proto_init() }
@@ -626,7 +732,7 @@ func file_pkg_relay_transport_proto_heartbeat_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_relay_transport_proto_heartbeat_proto_rawDesc), len(file_pkg_relay_transport_proto_heartbeat_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
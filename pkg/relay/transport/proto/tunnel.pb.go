@@ -688,8 +688,10 @@ type TunnelConfig struct {
 	TimeoutSeconds     int32                  `protobuf:"varint,3,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
 	CompressionEnabled bool                   `protobuf:"varint,4,opt,name=compression_enabled,json=compressionEnabled,proto3" json:"compression_enabled,omitempty"`
 	EncryptionEnabled  bool                   `protobuf:"varint,5,opt,name=encryption_enabled,json=encryptionEnabled,proto3" json:"encryption_enabled,omitempty"`
-	unknownFields      protoimpl.UnknownFields
-	sizeCache          protoimpl.SizeCache
+	// transport_mode is the client data-plane transport (quic, wireguard)
+	TransportMode string `protobuf:"bytes,6,opt,name=transport_mode,json=transportMode,proto3" json:"transport_mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *TunnelConfig) Reset() {
@@ -757,6 +759,13 @@ func (x *TunnelConfig) GetEncryptionEnabled() bool {
 	return false
 }
 
+func (x *TunnelConfig) GetTransportMode() string {
+	if x != nil {
+		return x.TransportMode
+	}
+	return ""
+}
+
 // TunnelInfo contains tunnel information
 type TunnelInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -1006,7 +1015,7 @@ const file_pkg_relay_transport_proto_tunnel_proto_rawDesc = "" +
 	"\x0fsequence_number\x18\x03 \x01(\x03R\x0esequenceNumber\x128\n" +
 	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x125\n" +
 	"\vpacket_type\x18\x05 \x01(\x0e2\x14.relay.v1.PacketTypeR\n" +
-	"packetType\"\xd9\x01\n" +
+	"packetType\"\x80\x02\n" +
 	"\fTunnelConfig\x12\x1f\n" +
 	"\vbuffer_size\x18\x01 \x01(\x05R\n" +
 	"bufferSize\x12\x1f\n" +
@@ -1014,7 +1023,8 @@ const file_pkg_relay_transport_proto_tunnel_proto_rawDesc = "" +
 	"maxBuffers\x12'\n" +
 	"\x0ftimeout_seconds\x18\x03 \x01(\x05R\x0etimeoutSeconds\x12/\n" +
 	"\x13compression_enabled\x18\x04 \x01(\bR\x12compressionEnabled\x12-\n" +
-	"\x12encryption_enabled\x18\x05 \x01(\bR\x11encryptionEnabled\"\xeb\x02\n" +
+	"\x12encryption_enabled\x18\x05 \x01(\bR\x11encryptionEnabled\x12%\n" +
+	"\x0etransport_mode\x18\x06 \x01(\tR\rtransportMode\"\xeb\x02\n" +
 	"\n" +
 	"TunnelInfo\x12\x1b\n" +
 	"\ttunnel_id\x18\x01 \x01(\tR\btunnelId\x12\x1b\n" +
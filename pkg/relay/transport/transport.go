@@ -30,12 +30,18 @@ type Transport interface {
 	// Authenticate performs authentication
 	Authenticate(token string) (*AuthResult, error)
 
+	// Resume restores a session on the relay using a continuation token
+	Resume(req *ResumeRequest) (*ResumeResult, error)
+
 	// CreateTunnel creates a new tunnel
 	CreateTunnel(tunnelID, tenantID string, localPort int, remoteHost string, remotePort int) (*TunnelResult, error)
 
 	// SendHeartbeat sends a heartbeat
 	SendHeartbeat(clientID, tenantID string, metrics *ClientMetrics) (*HeartbeatResult, error)
 
+	// StreamHeartbeat opens a long-lived bidirectional heartbeat stream
+	StreamHeartbeat(clientID, tenantID string) (HeartbeatStream, error)
+
 	// IsConnected returns connection status
 	IsConnected() bool
 
@@ -54,12 +60,33 @@ type HelloResult struct {
 
 // AuthResult contains authentication response data
 type AuthResult struct {
-	Status       string
-	ClientID     string
-	TenantID     string
-	SessionToken string
-	ExpiresAt    time.Time
-	ErrorMessage string
+	Status            string
+	ClientID          string
+	TenantID          string
+	SessionToken      string
+	ExpiresAt         time.Time
+	ContinuationToken string
+	ErrorMessage      string
+}
+
+// ResumeRequest contains data presented to a relay to resume a session
+type ResumeRequest struct {
+	Token             string
+	ContinuationToken string
+	ClientID          string
+	TunnelIDs         []string
+	P2PSessionID      string
+}
+
+// ResumeResult contains session resume response data
+type ResumeResult struct {
+	Status            string
+	ClientID          string
+	TenantID          string
+	ResumedTunnelIDs  []string
+	ContinuationToken string
+	ExpiresAt         time.Time
+	ErrorMessage      string
 }
 
 // TunnelResult contains tunnel creation response data
@@ -75,9 +102,42 @@ type HeartbeatResult struct {
 	Status          string
 	ServerTimestamp time.Time
 	IntervalSeconds int32
+	ServerMetrics   *ServerMetrics
+	Notifications   []*ServerNotification
 	ErrorMessage    string
 }
 
+// ServerMetrics contains server-side metrics reported in heartbeat responses
+type ServerMetrics struct {
+	ConnectedClients int32
+	ActiveTunnels    int32
+	ServerLoad       float64
+	Timestamp        time.Time
+}
+
+// ServerNotification is a server-to-client notification delivered on the heartbeat stream
+type ServerNotification struct {
+	Type       string // "tunnel_revoked", "token_expiring", "reconnect"
+	TunnelID   string
+	POPID      string
+	POPAddress string
+	Message    string
+	ExpiresAt  time.Time
+}
+
+// HeartbeatStream is a bidirectional heartbeat stream.
+// Send and Recv may be called from different goroutines.
+type HeartbeatStream interface {
+	// Send sends a heartbeat with the given metrics
+	Send(metrics *ClientMetrics) error
+
+	// Recv blocks until the next heartbeat response or server notification
+	Recv() (*HeartbeatResult, error)
+
+	// Close closes the stream
+	Close() error
+}
+
 // ClientMetrics contains client metrics for heartbeat
 type ClientMetrics struct {
 	BytesSent         int64
@@ -86,10 +146,11 @@ type ClientMetrics struct {
 	PacketsReceived   int64
 	ActiveTunnels     int32
 	ActiveP2PSessions int32
-	CPUUsage          float64
-	MemoryUsage       float64
-	TransportMode     string
-	LastSwitch        time.Time
+	P2PPaths          map[string]int // Established P2P connections by path type (direct/turn/derp/relay)
+	CPUUsage          float64        // Percent of total CPU capacity
+	MemoryUsage       float64        // Process memory in MiB
+	TransportMode     string         // Active data-plane transport
+	LastSwitch        time.Time      // Last data-plane transport switch (zero if none)
 }
 
 // TransportManager manages multiple transport implementations
@@ -184,6 +245,15 @@ func (tm *TransportManager) getCurrentTransportUnsafe() Transport {
 	}
 }
 
+// SetDataPlaneMode sets the data-plane transport mode announced to the relay for tunnels
+func (tm *TransportManager) SetDataPlaneMode(mode string) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if tm.grpcTransport != nil {
+		tm.grpcTransport.SetDataPlaneMode(mode)
+	}
+}
+
 // GetCurrentMode returns the current transport mode
 func (tm *TransportManager) GetCurrentMode() TransportMode {
 	tm.mu.RLock()
@@ -261,6 +331,15 @@ func (tm *TransportManager) Authenticate(token string) (*AuthResult, error) {
 	return transport.Authenticate(token)
 }
 
+// Resume resumes a session using current transport
+func (tm *TransportManager) Resume(req *ResumeRequest) (*ResumeResult, error) {
+	transport := tm.GetTransport()
+	if transport == nil {
+		return nil, fmt.Errorf("no transport available")
+	}
+	return transport.Resume(req)
+}
+
 // CreateTunnel creates a tunnel using current transport
 func (tm *TransportManager) CreateTunnel(tunnelID, tenantID string, localPort int,
 	remoteHost string, remotePort int) (*TunnelResult, error) {
@@ -280,5 +359,14 @@ func (tm *TransportManager) SendHeartbeat(clientID, tenantID string, metrics *Cl
 	return transport.SendHeartbeat(clientID, tenantID, metrics)
 }
 
+// StreamHeartbeat opens a heartbeat stream using current transport
+func (tm *TransportManager) StreamHeartbeat(clientID, tenantID string) (HeartbeatStream, error) {
+	transport := tm.GetTransport()
+	if transport == nil {
+		return nil, fmt.Errorf("no transport available")
+	}
+	return transport.StreamHeartbeat(clientID, tenantID)
+}
+
 // Note: timeToTimestamp and timestampToTime utility functions would be implemented
 // when actual protobuf integration is added
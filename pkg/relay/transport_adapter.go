@@ -75,14 +75,14 @@ func (ta *TransportAdapter) Hello(version string, features []string) error {
 }
 
 // Authenticate performs authentication
-func (ta *TransportAdapter) Authenticate(token string) (clientID, tenantID string, err error) {
+func (ta *TransportAdapter) Authenticate(token string) (*transport.AuthResult, error) {
 	result, err := ta.transportManager.Authenticate(token)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	if result.Status != "ok" {
-		return "", "", fmt.Errorf("authentication failed: %s", result.ErrorMessage)
+		return nil, fmt.Errorf("authentication failed: %s", result.ErrorMessage)
 	}
 
 	ta.logger.Info("Authentication completed via transport",
@@ -90,7 +90,26 @@ func (ta *TransportAdapter) Authenticate(token string) (clientID, tenantID strin
 		"client_id", result.ClientID,
 		"tenant_id", result.TenantID)
 
-	return result.ClientID, result.TenantID, nil
+	return result, nil
+}
+
+// Resume resumes a session on the relay with a continuation token
+func (ta *TransportAdapter) Resume(req *transport.ResumeRequest) (*transport.ResumeResult, error) {
+	result, err := ta.transportManager.Resume(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Status != "ok" {
+		return nil, fmt.Errorf("session resume rejected: %s", result.ErrorMessage)
+	}
+
+	ta.logger.Info("Session resumed via transport",
+		"mode", ta.transportManager.GetCurrentMode(),
+		"client_id", result.ClientID,
+		"resumed_tunnels", len(result.ResumedTunnelIDs))
+
+	return result, nil
 }
 
 // CreateTunnel creates a tunnel
@@ -113,12 +132,7 @@ func (ta *TransportAdapter) CreateTunnel(tunnelID, tenantID string, localPort in
 }
 
 // SendHeartbeat sends a heartbeat
-func (ta *TransportAdapter) SendHeartbeat(clientID, tenantID string) error {
-	// Create basic metrics
-	metrics := &transport.ClientMetrics{
-		TransportMode: string(ta.transportManager.GetCurrentMode()),
-	}
-
+func (ta *TransportAdapter) SendHeartbeat(clientID, tenantID string, metrics *transport.ClientMetrics) error {
 	result, err := ta.transportManager.SendHeartbeat(clientID, tenantID, metrics)
 	if err != nil {
 		return err
@@ -135,6 +149,16 @@ func (ta *TransportAdapter) SendHeartbeat(clientID, tenantID string) error {
 	return nil
 }
 
+// StreamHeartbeat opens a long-lived heartbeat stream
+func (ta *TransportAdapter) StreamHeartbeat(clientID, tenantID string) (transport.HeartbeatStream, error) {
+	return ta.transportManager.StreamHeartbeat(clientID, tenantID)
+}
+
+// SetDataPlaneMode sets the data-plane transport mode announced to the relay for tunnels
+func (ta *TransportAdapter) SetDataPlaneMode(mode string) {
+	ta.transportManager.SetDataPlaneMode(mode)
+}
+
 // IsConnected returns connection status
 func (ta *TransportAdapter) IsConnected() bool {
 	return ta.transportManager.IsConnected()
@@ -1,5 +1,18 @@
 package relay
 
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// wireGuardModeUserspace runs WireGuard in-process (wireguard-go) instead of the kernel module
+	wireGuardModeUserspace = "userspace"
+	// wireGuardModeNetstack runs wireguard-go on a gVisor netstack: no TUN device or privileges needed
+	wireGuardModeNetstack = "netstack"
+)
+
 // WireGuardManagerInterface defines the common interface for WireGuard managers across platforms
 type WireGuardManagerInterface interface {
 	Connect() error
@@ -9,7 +22,28 @@ type WireGuardManagerInterface interface {
 	IsConnected() bool
 	GetInterfaceName() string
 	GetStatus() (map[string]interface{}, error)
+	LatestHandshake() (time.Time, error)
 }
 
 // Ensure both implementations satisfy the interface
 var _ WireGuardManagerInterface = (*WireGuardManager)(nil)
+
+// parseLatestHandshakes returns the most recent handshake from `wg show <iface> latest-handshakes` output.
+// Each line is "<public-key>\t<unix-seconds>"; zero means no handshake yet.
+func parseLatestHandshakes(output string) time.Time {
+	var latest time.Time
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || sec == 0 {
+			continue
+		}
+		if ts := time.Unix(sec, 0); ts.After(latest) {
+			latest = ts
+		}
+	}
+	return latest
+}
@@ -3,7 +3,10 @@
 package relay
 
 import (
-	"os/exec"
+	"errors"
+	"net"
+	"os"
+	"syscall"
 	"testing"
 
 	"github.com/2gc-dev/cloudbridge-client/pkg/types"
@@ -11,11 +14,6 @@ import (
 
 // TestWireGuardManager_TearDown tests the WireGuard teardown functionality
 func TestWireGuardManager_TearDown(t *testing.T) {
-	// Skip test if WireGuard tools are not available
-	if _, err := exec.LookPath("wg"); err != nil {
-		t.Skip("WireGuard tools not available, skipping test")
-	}
-
 	// Create test configuration
 	config := &types.Config{
 		WireGuard: types.WireGuardConfig{
@@ -47,11 +45,6 @@ func TestWireGuardManager_TearDown(t *testing.T) {
 
 // TestWireGuardManager_Stop tests the Stop method
 func TestWireGuardManager_Stop(t *testing.T) {
-	// Skip test if WireGuard tools are not available
-	if _, err := exec.LookPath("wg"); err != nil {
-		t.Skip("WireGuard tools not available, skipping test")
-	}
-
 	// Create test configuration
 	config := &types.Config{
 		WireGuard: types.WireGuardConfig{
@@ -80,13 +73,8 @@ func TestWireGuardManager_Stop(t *testing.T) {
 	// Test completed successfully - Stop() calls TearDown() internally
 }
 
-// TestWireGuardManager_RemovePeers tests peer removal functionality
-func TestWireGuardManager_RemovePeers(t *testing.T) {
-	// Skip test if WireGuard tools are not available
-	if _, err := exec.LookPath("wg"); err != nil {
-		t.Skip("WireGuard tools not available, skipping test")
-	}
-
+// TestWireGuardManager_NotConnected tests status of a manager that has not connected yet
+func TestWireGuardManager_NotConnected(t *testing.T) {
 	// Create test configuration
 	config := &types.Config{
 		WireGuard: types.WireGuardConfig{
@@ -106,20 +94,22 @@ func TestWireGuardManager_RemovePeers(t *testing.T) {
 		t.Fatalf("Failed to create WireGuard manager: %v", err)
 	}
 
-	// Test removePeers method (should not fail even if interface doesn't exist)
-	err = wgm.removePeers()
+	// No handshake can be reported without an interface
+	if _, err := wgm.LatestHandshake(); err == nil {
+		t.Error("LatestHandshake should fail when not connected")
+	}
+
+	status, err := wgm.GetStatus()
 	if err != nil {
-		t.Errorf("removePeers failed: %v", err)
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status["connected"] != false || status["mode"] != "kernel" {
+		t.Errorf("Unexpected status: %v", status)
 	}
 }
 
 // TestWireGuardManager_InterfaceOperations tests interface creation/destruction
 func TestWireGuardManager_InterfaceOperations(t *testing.T) {
-	// Skip test if WireGuard tools are not available
-	if _, err := exec.LookPath("wg"); err != nil {
-		t.Skip("WireGuard tools not available, skipping test")
-	}
-
 	// Skip test if not running as root (required for interface operations)
 	if !isRunningAsRoot() {
 		t.Skip("Test requires root privileges for interface operations")
@@ -144,38 +134,34 @@ func TestWireGuardManager_InterfaceOperations(t *testing.T) {
 		t.Fatalf("Failed to create WireGuard manager: %v", err)
 	}
 
-	// Clean up any existing interface first
-	_ = wgm.destroyInterface() //nolint:errcheck // Explicitly ignore errors in test cleanup
-
 	// Test interface creation
-	err = wgm.createInterface()
-	if err != nil {
-		t.Errorf("createInterface failed: %v", err)
+	if err := wgm.Connect(); err != nil {
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			t.Skip("Kernel WireGuard is not available")
+		}
+		t.Fatalf("Connect failed: %v", err)
 	}
 
 	// Verify interface exists
-	if !wgm.interfaceExists() {
-		t.Error("Interface should exist after creation")
+	if _, err := net.InterfaceByName(config.WireGuard.InterfaceName); err != nil {
+		t.Errorf("Interface should exist after creation: %v", err)
+	}
+	if _, err := wgm.LatestHandshake(); err != nil {
+		t.Errorf("LatestHandshake failed: %v", err)
 	}
 
 	// Test interface destruction
-	err = wgm.destroyInterface()
-	if err != nil {
-		t.Errorf("destroyInterface failed: %v", err)
+	if err := wgm.TearDown(); err != nil {
+		t.Errorf("TearDown failed: %v", err)
 	}
 
 	// Verify interface no longer exists
-	if wgm.interfaceExists() {
+	if _, err := net.InterfaceByName(config.WireGuard.InterfaceName); err == nil {
 		t.Error("Interface should not exist after destruction")
 	}
 }
 
 // isRunningAsRoot checks if the current process is running as root
 func isRunningAsRoot() bool {
-	cmd := exec.Command("id", "-u")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-	return string(output) == "0\n"
+	return os.Geteuid() == 0
 }
@@ -279,6 +279,17 @@ func (wgm *WireGuardManager) generatePrivateKey() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// LatestHandshake returns the time of the most recent handshake with a relay peer.
+// Zero time means the interface is up but no handshake has completed yet.
+func (wgm *WireGuardManager) LatestHandshake() (time.Time, error) {
+	cmd := exec.Command("wg.exe", "show", wgm.interfaceName, "latest-handshakes")
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest handshakes: %w", err)
+	}
+	return parseLatestHandshakes(string(output)), nil
+}
+
 // GetInterfaceName returns the WireGuard interface name
 func (wgm *WireGuardManager) GetInterfaceName() string {
 	return wgm.interfaceName
@@ -0,0 +1,322 @@
+package signaling
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"golang.org/x/net/websocket"
+)
+
+// Logger интерфейс для логирования
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// EventType тип события сигналинга
+type EventType string
+
+const (
+	EventPeerJoin          EventType = "peer_join"          // пир подключился к тенанту
+	EventPeerLeave         EventType = "peer_leave"         // пир отключился
+	EventConnectionRequest EventType = "connection_request" // пир инициирует соединение
+	EventCandidate         EventType = "candidate"          // trickle ICE кандидат
+	EventEndOfCandidates   EventType = "end_of_candidates"  // сбор кандидатов пира завершен
+	EventICERestart        EventType = "ice_restart"        // пир перезапускает ICE с новыми credentials
+	EventPing              EventType = "ping"
+	EventPong              EventType = "pong"
+)
+
+// Event сообщение сигналинга (JSON поверх WebSocket)
+type Event struct {
+	Type       EventType         `json:"type"`
+	SessionID  string            `json:"session_id,omitempty"`
+	FromPeerID string            `json:"from_peer_id,omitempty"`
+	ToPeerID   string            `json:"to_peer_id,omitempty"`
+	Protocol   string            `json:"protocol,omitempty"`
+	Upgrade    bool              `json:"upgrade,omitempty"` // запрос перевода соединения на прямой путь
+	Ufrag      string            `json:"ufrag,omitempty"`
+	Pwd        string            `json:"pwd,omitempty"`
+	Candidate  *api.ICECandidate `json:"candidate,omitempty"`
+	Peer       *api.Peer         `json:"peer,omitempty"`
+	Timestamp  int64             `json:"timestamp"`
+}
+
+// EventHandler обрабатывает входящие события; вызывается из цикла чтения
+type EventHandler func(event *Event)
+
+// Config конфигурация канала сигналинга
+type Config struct {
+	Endpoint             string        `json:"endpoint"` // ws:// или wss:// URL
+	Token                string        `json:"-"`
+	TenantID             string        `json:"tenant_id"`
+	PeerID               string        `json:"peer_id"`
+	InsecureSkipVerify   bool          `json:"insecure_skip_verify"`
+	DialTimeout          time.Duration `json:"dial_timeout"`
+	PingInterval         time.Duration `json:"ping_interval"`
+	ReconnectInterval    time.Duration `json:"reconnect_interval"`
+	MaxReconnectInterval time.Duration `json:"max_reconnect_interval"`
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		DialTimeout:          10 * time.Second,
+		PingInterval:         30 * time.Second,
+		ReconnectInterval:    time.Second,
+		MaxReconnectInterval: 30 * time.Second,
+	}
+}
+
+// Client постоянный WebSocket канал сигналинга с автоматическим переподключением
+type Client struct {
+	config  *Config
+	logger  Logger
+	handler EventHandler
+
+	conn      *websocket.Conn
+	connected atomic.Bool
+	sendMu    sync.Mutex
+
+	eventsReceived atomic.Int64
+	eventsSent     atomic.Int64
+	reconnects     atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+}
+
+// NewClient создает клиент сигналинга; незаданные интервалы берутся из DefaultConfig
+func NewClient(config *Config, handler EventHandler, logger Logger) *Client {
+	defaults := DefaultConfig()
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaults.DialTimeout
+	}
+	if config.PingInterval <= 0 {
+		config.PingInterval = defaults.PingInterval
+	}
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = defaults.ReconnectInterval
+	}
+	if config.MaxReconnectInterval <= 0 {
+		config.MaxReconnectInterval = defaults.MaxReconnectInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		config:  config,
+		logger:  logger,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start запускает фоновое подключение; ошибки подключения приводят к повторным попыткам
+func (c *Client) Start() error {
+	if _, err := c.websocketConfig(); err != nil {
+		return err
+	}
+
+	c.wg.Add(1)
+	go c.run()
+	return nil
+}
+
+// Stop закрывает канал и останавливает переподключение
+func (c *Client) Stop() {
+	c.cancel()
+	c.closeConn()
+	c.wg.Wait()
+}
+
+// IsConnected сообщает, установлен ли канал
+func (c *Client) IsConnected() bool {
+	return c.connected.Load()
+}
+
+// Send отправляет событие пиру через relay
+func (c *Client) Send(event *Event) error {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil || !c.connected.Load() {
+		return fmt.Errorf("signaling channel not connected")
+	}
+
+	if event.FromPeerID == "" {
+		event.FromPeerID = c.config.PeerID
+	}
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().UnixMilli()
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if err := websocket.JSON.Send(conn, event); err != nil {
+		return fmt.Errorf("failed to send signaling event: %w", err)
+	}
+	c.eventsSent.Add(1)
+	return nil
+}
+
+// GetMetrics возвращает метрики канала
+func (c *Client) GetMetrics() map[string]interface{} {
+	return map[string]interface{}{
+		"connected":       c.connected.Load(),
+		"endpoint":        c.config.Endpoint,
+		"events_received": c.eventsReceived.Load(),
+		"events_sent":     c.eventsSent.Load(),
+		"reconnects":      c.reconnects.Load(),
+	}
+}
+
+// run поддерживает соединение с экспоненциальной задержкой между попытками
+func (c *Client) run() {
+	defer c.wg.Done()
+
+	backoff := c.config.ReconnectInterval
+	for {
+		err := c.connectAndServe()
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			c.logger.Warn("Signaling channel disconnected", "endpoint", c.config.Endpoint, "error", err, "retry_in", backoff)
+		}
+		c.reconnects.Add(1)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.config.MaxReconnectInterval {
+			backoff = c.config.MaxReconnectInterval
+		}
+	}
+}
+
+// connectAndServe подключается и читает события до ошибки
+func (c *Client) connectAndServe() error {
+	wsConfig, err := c.websocketConfig()
+	if err != nil {
+		return err
+	}
+
+	dialCtx, cancel := context.WithTimeout(c.ctx, c.config.DialTimeout)
+	conn, err := wsConfig.DialContext(dialCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	c.connected.Store(true)
+	c.logger.Info("Signaling channel connected", "endpoint", c.config.Endpoint)
+
+	pingDone := make(chan struct{})
+	go c.pingLoop(pingDone)
+	defer func() {
+		close(pingDone)
+		c.closeConn()
+	}()
+
+	for {
+		var event Event
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			return fmt.Errorf("failed to read event: %w", err)
+		}
+		c.eventsReceived.Add(1)
+
+		switch event.Type {
+		case EventPing:
+			if err := c.Send(&Event{Type: EventPong}); err != nil {
+				c.logger.Debug("Failed to answer signaling ping", "error", err)
+			}
+		case EventPong:
+			// keepalive
+		default:
+			if c.handler != nil {
+				c.handler(&event)
+			}
+		}
+	}
+}
+
+// pingLoop отправляет keepalive, чтобы промежуточные прокси не закрывали канал
+func (c *Client) pingLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.Send(&Event{Type: EventPing}); err != nil {
+				c.logger.Debug("Failed to send signaling ping", "error", err)
+				c.closeConn()
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) closeConn() {
+	c.connected.Store(false)
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close() //nolint:errcheck // connection is being dropped
+	}
+}
+
+// websocketConfig строит конфигурацию WebSocket с авторизацией
+func (c *Client) websocketConfig() (*websocket.Config, error) {
+	u, err := url.Parse(c.config.Endpoint)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid signaling endpoint %q", c.config.Endpoint)
+	}
+
+	origin := "https://" + u.Host
+	if u.Scheme == "ws" {
+		origin = "http://" + u.Host
+	}
+
+	wsConfig, err := websocket.NewConfig(c.config.Endpoint, origin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebSocket config: %w", err)
+	}
+	wsConfig.Header.Set("Authorization", "Bearer "+c.config.Token)
+	if c.config.TenantID != "" {
+		wsConfig.Header.Set("X-Tenant-ID", c.config.TenantID)
+	}
+	if c.config.PeerID != "" {
+		wsConfig.Header.Set("X-Peer-ID", c.config.PeerID)
+	}
+	if u.Scheme == "wss" {
+		wsConfig.TlsConfig = &tls.Config{
+			ServerName:         u.Hostname(),
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: c.config.InsecureSkipVerify, // #nosec G402 -- opt-in for test environments
+		}
+	}
+	return wsConfig, nil
+}
@@ -0,0 +1,96 @@
+package signaling
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/api"
+	"golang.org/x/net/websocket"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+func TestClient_ReceiveAndSend(t *testing.T) {
+	received := make(chan *Event, 1)
+	authHeaders := make(chan string, 1)
+
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		authHeaders <- ws.Request().Header.Get("Authorization")
+
+		// Сервер доставляет кандидат и ждет ответное событие клиента
+		if err := websocket.JSON.Send(ws, &Event{
+			Type:       EventCandidate,
+			SessionID:  "s1",
+			FromPeerID: "peer-b",
+			Candidate:  &api.ICECandidate{Address: "10.0.0.2", Port: 5000, Type: "host"},
+		}); err != nil {
+			return
+		}
+		var reply Event
+		if err := websocket.JSON.Receive(ws, &reply); err != nil {
+			return
+		}
+		received <- &reply
+	}))
+	defer server.Close()
+
+	events := make(chan *Event, 1)
+	client := NewClient(&Config{
+		Endpoint: "ws" + strings.TrimPrefix(server.URL, "http") + "/ws",
+		Token:    "secret",
+		PeerID:   "peer-a",
+	}, func(event *Event) {
+		events <- event
+	}, testLogger{})
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer client.Stop()
+
+	select {
+	case auth := <-authHeaders:
+		if auth != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", auth)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Client did not connect")
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != EventCandidate || event.FromPeerID != "peer-b" || event.Candidate == nil || event.Candidate.Port != 5000 {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event not delivered")
+	}
+
+	if err := client.Send(&Event{Type: EventEndOfCandidates, SessionID: "s1", ToPeerID: "peer-b"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case reply := <-received:
+		if reply.FromPeerID != "peer-a" || reply.Timestamp == 0 {
+			t.Errorf("Expected sender and timestamp to be filled, got %+v", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not receive event")
+	}
+}
+
+func TestClient_InvalidEndpoint(t *testing.T) {
+	client := NewClient(&Config{Endpoint: "https://relay.example.com/ws"}, nil, testLogger{})
+	if err := client.Start(); err == nil {
+		t.Error("Expected error for non-WebSocket endpoint")
+	}
+	if err := client.Send(&Event{Type: EventPing}); err == nil {
+		t.Error("Expected error when sending without connection")
+	}
+}
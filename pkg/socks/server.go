@@ -0,0 +1,305 @@
+package socks
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	socksVersion = 5
+
+	methodNoAuth       = 0x00
+	methodNoAcceptable = 0xff
+
+	commandConnect = 0x01
+
+	addrIPv4   = 0x01
+	addrDomain = 0x03
+	addrIPv6   = 0x04
+
+	replySucceeded           = 0x00
+	replyHostUnreachable     = 0x04
+	replyCommandNotSupported = 0x07
+	replyAddressNotSupported = 0x08
+
+	// handshakeTimeout время на приветствие и запрос клиента
+	handshakeTimeout = 10 * time.Second
+	// dialTimeout таймаут соединения с адресом назначения
+	dialTimeout = 15 * time.Second
+)
+
+// Logger interface for SOCKS proxy logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// DialFunc соединяется с адресом назначения (например, через netstack WireGuard)
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config настройки SOCKS прокси
+type Config struct {
+	ListenAddr string // адрес TCP; без аутентификации, поэтому по умолчанию только localhost
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{ListenAddr: "127.0.0.1:1080"}
+}
+
+// Server SOCKS5 прокси (RFC 1928, только CONNECT без аутентификации): приложения
+// достигают адресов overlay сети, даже когда интерфейса overlay в системе нет
+type Server struct {
+	config      *Config
+	dial        DialFunc
+	logger      Logger
+	listener    net.Listener
+	conns       map[net.Conn]struct{}
+	done        chan struct{} // закрывается, когда цикл приема завершен
+	mu          sync.Mutex
+	connections int
+	failures    int
+}
+
+// NewServer creates a new SOCKS5 proxy server
+func NewServer(config *Config, dial DialFunc, logger Logger) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.ListenAddr == "" {
+		config.ListenAddr = DefaultConfig().ListenAddr
+	}
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	return &Server{config: config, dial: dial, logger: logger, conns: make(map[net.Conn]struct{})}
+}
+
+// Start начинает прием соединений
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return fmt.Errorf("SOCKS proxy already started")
+	}
+	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
+	}
+	s.listener, s.done = listener, make(chan struct{})
+	go s.serve(listener, s.done)
+	s.logger.Info("SOCKS5 proxy started", "addr", listener.Addr().String())
+	return nil
+}
+
+// Stop закрывает сокет и соединения клиентов. Обработчики, которые еще соединяются с
+// адресом назначения, не ожидаются: их клиенты уже закрыты, и они завершатся сами.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	listener, done := s.listener, s.done
+	s.listener = nil
+	if listener != nil {
+		_ = listener.Close() //nolint:errcheck // stopping the proxy
+	}
+	for conn := range s.conns {
+		_ = conn.Close() //nolint:errcheck // stopping the proxy
+	}
+	s.mu.Unlock()
+	if listener == nil {
+		return
+	}
+	<-done
+	s.logger.Info("SOCKS5 proxy stopped", "addr", listener.Addr().String())
+}
+
+// Addr возвращает адрес прокси (nil — не запущен)
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Server) serve(listener net.Listener, done chan struct{}) {
+	defer close(done)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(conn, true) {
+			_ = conn.Close() //nolint:errcheck // proxy is stopping
+			return
+		}
+		go func() {
+			defer s.track(conn, false)
+			s.handle(conn)
+		}()
+	}
+}
+
+// track учитывает соединение клиента, чтобы Stop мог его закрыть; false — прокси остановлен
+func (s *Server) track(conn net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.conns, conn)
+		return true
+	}
+	if s.listener == nil {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// handle обслуживает одно соединение клиента: приветствие, запрос CONNECT и пересылка данных
+func (s *Server) handle(client net.Conn) {
+	defer client.Close() //nolint:errcheck // connection is done
+
+	_ = client.SetDeadline(time.Now().Add(handshakeTimeout)) //nolint:errcheck // best effort
+	address, err := s.handshake(client)
+	if err != nil {
+		s.logger.Debug("SOCKS5 handshake failed", "client", client.RemoteAddr().String(), "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	remote, err := s.dial(ctx, "tcp", address)
+	cancel()
+	if err != nil {
+		s.recordFailure()
+		s.logger.Debug("SOCKS5 connect failed", "address", address, "error", err)
+		_ = writeReply(client, replyHostUnreachable) //nolint:errcheck // client is dropped anyway
+		return
+	}
+	defer remote.Close() //nolint:errcheck // connection is done
+
+	if err := writeReply(client, replySucceeded); err != nil {
+		return
+	}
+	_ = client.SetDeadline(time.Time{}) //nolint:errcheck // best effort
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, client) //nolint:errcheck // either side closing ends the copy
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, remote) //nolint:errcheck // either side closing ends the copy
+		done <- struct{}{}
+	}()
+	// Закрытие одной стороны закрывает обе: вторая копия завершится ошибкой
+	<-done
+}
+
+// handshake читает приветствие и запрос CONNECT и возвращает адрес назначения host:port
+func (s *Server) handshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", fmt.Errorf("failed to read auth methods: %w", err)
+	}
+	method := byte(methodNoAcceptable)
+	for _, m := range methods {
+		if m == methodNoAuth {
+			method = methodNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", fmt.Errorf("failed to write auth method: %w", err)
+	}
+	if method == methodNoAcceptable {
+		return "", errors.New("client requires authentication")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", fmt.Errorf("failed to read request: %w", err)
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", request[0])
+	}
+	if request[1] != commandConnect {
+		_ = writeReply(conn, replyCommandNotSupported) //nolint:errcheck // client is dropped anyway
+		return "", fmt.Errorf("unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case addrIPv4, addrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == addrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+		host = ip.String()
+	case addrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+		host = string(name)
+	default:
+		_ = writeReply(conn, replyAddressNotSupported) //nolint:errcheck // client is dropped anyway
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", fmt.Errorf("failed to read port: %w", err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// writeReply отвечает на запрос; адрес привязки не сообщается (0.0.0.0:0)
+func writeReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0x00, addrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+func (s *Server) recordFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+}
+
+// GetMetrics returns SOCKS proxy metrics
+func (s *Server) GetMetrics() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metrics := map[string]interface{}{
+		"running":            s.listener != nil,
+		"listen_addr":        s.config.ListenAddr,
+		"connections":        s.connections,
+		"active_connections": len(s.conns),
+		"failures":           s.failures,
+	}
+	return metrics
+}
@@ -0,0 +1,163 @@
+package socks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+// startEcho запускает TCP сервер, который возвращает полученные данные
+func startEcho(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() }) //nolint:errcheck // test cleanup
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()         //nolint:errcheck // test cleanup
+				_, _ = io.Copy(conn, conn) //nolint:errcheck // echo until the client closes
+			}()
+		}
+	}()
+	return listener
+}
+
+func startProxy(t *testing.T, dial DialFunc) *Server {
+	t.Helper()
+	server := NewServer(&Config{ListenAddr: "127.0.0.1:0"}, dial, testLogger{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start SOCKS proxy: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// connect выполняет приветствие и запрос CONNECT и возвращает код ответа
+func connect(t *testing.T, proxy net.Addr, request []byte) (net.Conn, byte) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", proxy.String(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })                //nolint:errcheck // test cleanup
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck // test deadline
+
+	if _, err := conn.Write([]byte{socksVersion, 1, methodNoAuth}); err != nil {
+		t.Fatalf("Failed to write greeting: %v", err)
+	}
+	method := make([]byte, 2)
+	if _, err := io.ReadFull(conn, method); err != nil || method[1] != methodNoAuth {
+		t.Fatalf("Unexpected method selection %x (%v)", method, err)
+	}
+	if _, err := conn.Write(request); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	return conn, reply[1]
+}
+
+func TestServer_Connect(t *testing.T) {
+	echo := startEcho(t)
+	port := echo.Addr().(*net.TCPAddr).Port
+
+	var mu sync.Mutex
+	var dialed []string
+	proxy := startProxy(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, address)
+		mu.Unlock()
+		if address == "db.mesh:5432" {
+			address = echo.Addr().String()
+		}
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	})
+
+	requests := map[string][]byte{
+		"ipv4":   {socksVersion, commandConnect, 0, addrIPv4, 127, 0, 0, 1, byte(port >> 8), byte(port)},
+		"domain": append(append([]byte{socksVersion, commandConnect, 0, addrDomain, 7}, "db.mesh"...), 0x15, 0x38),
+	}
+	for name, request := range requests {
+		conn, code := connect(t, proxy.Addr(), request)
+		if code != replySucceeded {
+			t.Fatalf("%s: expected success, got reply %d", name, code)
+		}
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatalf("%s: write failed: %v", name, err)
+		}
+		got := make([]byte, 4)
+		if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, []byte("ping")) {
+			t.Errorf("%s: expected echo, got %q (%v)", name, got, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dialed) != 2 {
+		t.Fatalf("Expected 2 dials, got %v", dialed)
+	}
+	for _, address := range dialed {
+		if address != echo.Addr().String() && address != "db.mesh:5432" {
+			t.Errorf("Unexpected dial address %s", address)
+		}
+	}
+}
+
+func TestServer_Failures(t *testing.T) {
+	proxy := startProxy(t, func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("no route to overlay")
+	})
+
+	if _, code := connect(t, proxy.Addr(), []byte{socksVersion, commandConnect, 0, addrIPv4, 10, 0, 0, 2, 0, 22}); code != replyHostUnreachable {
+		t.Errorf("Expected host unreachable, got reply %d", code)
+	}
+	// BIND не поддерживается
+	if _, code := connect(t, proxy.Addr(), []byte{socksVersion, 0x02, 0, addrIPv4, 10, 0, 0, 2, 0, 22}); code != replyCommandNotSupported {
+		t.Errorf("Expected command not supported, got reply %d", code)
+	}
+	if metrics := proxy.GetMetrics(); metrics["failures"] != 1 || metrics["connections"] != 0 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+
+	// Клиент без метода "без аутентификации" отклоняется
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()                                    //nolint:errcheck // test cleanup
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck // test deadline
+	if _, err := conn.Write([]byte{socksVersion, 1, 0x02}); err != nil {
+		t.Fatalf("Failed to write greeting: %v", err)
+	}
+	method := make([]byte, 2)
+	if _, err := io.ReadFull(conn, method); err != nil || method[1] != methodNoAcceptable {
+		t.Errorf("Expected no acceptable methods, got %x (%v)", method, err)
+	}
+
+	proxy.Stop()
+	if proxy.Addr() != nil || proxy.GetMetrics()["running"] != false {
+		t.Error("Expected proxy to be stopped")
+	}
+}
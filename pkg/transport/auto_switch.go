@@ -2,7 +2,7 @@ package transport
 
 import (
 	"context"
-	"net/http"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -11,9 +11,13 @@ import (
 type Mode string
 
 const (
+	ModeGRPC      Mode = "grpc"        // gRPC поверх TLS/TCP
+	ModeQUIC      Mode = "quic"        // QUIC
+	ModeMASQUE    Mode = "masque"      // MASQUE поверх HTTP/3
+	ModeWS        Mode = "ws"          // WebSocket
+	ModeWireGuard Mode = "wireguard"   // WireGuard
 	ModeH3        Mode = "h3"          // HTTP/3 + MASQUE
 	ModeH2Connect Mode = "h2_connect"  // HTTP/2 CONNECT
-	ModeWS        Mode = "ws"          // WebSocket
 	ModeTCP       Mode = "tcp_connect" // TCP CONNECT
 )
 
@@ -24,16 +28,51 @@ type HealthChecker interface {
 	ErrorRate(ctx context.Context) float64
 }
 
-// AutoSwitchManager управляет автоматическим переключением транспортов
+// SwitchHandler выполняет переключение на новый режим (поднимает транспорт и мигрирует туннели).
+// Ошибка отменяет переключение.
+type SwitchHandler func(ctx context.Context, from, to Mode) error
+
+// SwitchCallback вызывается после успешного переключения
+type SwitchCallback func(from, to Mode)
+
+// modeState хранит результаты проверок режима
+type modeState struct {
+	healthy     bool
+	wasHealthy  bool // Режим хотя бы раз прошел проверку
+	okStreak    int
+	failStreak  int
+	latency     time.Duration
+	errorRate   float64
+	lastChecked time.Time
+	failedAt    time.Time // Время последнего неудачного переключения на режим
+}
+
+// AutoSwitchManager управляет автоматическим переключением транспортов.
+// Политика: при устойчивом отказе текущего режима (FailureThreshold проверок подряд)
+// выполняется failover на самый приоритетный здоровый режим без учета cooldown;
+// возврат на более приоритетный режим требует RecoveryThreshold успешных проверок подряд
+// и истечения cooldown/min dwell (гистерезис). Резервные режимы (Standby) поднимаются
+// только при переключении, поэтому при отказе пробуются последними без успешной проверки.
 type AutoSwitchManager struct {
-	order          []Mode                 // Порядок fallback
-	healthCheckers map[Mode]HealthChecker // Health checkers для каждого режима
-	currentMode    Mode                   // Текущий режим
-	lastSwitch     time.Time              // Время последнего переключения
-	cooldown       time.Duration          // Cooldown после переключения
-	minDwell       time.Duration          // Минимальное время на режиме
-	mu             sync.RWMutex
-	logger         Logger
+	order             []Mode                 // Порядок fallback (приоритет по убыванию)
+	standby           map[Mode]bool          // Резервные режимы
+	healthCheckers    map[Mode]HealthChecker // Health checkers для каждого режима
+	states            map[Mode]*modeState    // Результаты последних проверок
+	currentMode       Mode                   // Текущий режим
+	lastSwitch        time.Time              // Время последнего переключения
+	cooldown          time.Duration          // Cooldown после переключения
+	minDwell          time.Duration          // Минимальное время на режиме
+	checkInterval     time.Duration          // Интервал проверок
+	checkTimeout      time.Duration          // Таймаут одной проверки
+	failureThreshold  int                    // Неудачных проверок подряд до failover
+	recoveryThreshold int                    // Успешных проверок подряд до возврата
+	switches          int64                  // Количество переключений
+	handler           SwitchHandler
+	callbacks         []SwitchCallback
+	mu                sync.RWMutex
+	switchMu          sync.Mutex // Сериализует переключения
+	cancel            context.CancelFunc
+	logger            Logger
 }
 
 // Logger интерфейс для логирования
@@ -46,17 +85,27 @@ type Logger interface {
 
 // AutoSwitchConfig конфигурация auto-switch
 type AutoSwitchConfig struct {
-	Order    []Mode        `json:"order"`     // Порядок fallback
-	Cooldown time.Duration `json:"cooldown"`  // Cooldown после переключения
-	MinDwell time.Duration `json:"min_dwell"` // Минимальное время на режиме
+	Order             []Mode        `json:"order"`              // Порядок fallback
+	Standby           []Mode        `json:"standby"`            // Режимы, которые проверяются только после подъема
+	Cooldown          time.Duration `json:"cooldown"`           // Cooldown после переключения
+	MinDwell          time.Duration `json:"min_dwell"`          // Минимальное время на режиме
+	CheckInterval     time.Duration `json:"check_interval"`     // Интервал проверок
+	CheckTimeout      time.Duration `json:"check_timeout"`      // Таймаут одной проверки
+	FailureThreshold  int           `json:"failure_threshold"`  // Неудачных проверок подряд до failover
+	RecoveryThreshold int           `json:"recovery_threshold"` // Успешных проверок подряд до возврата
 }
 
-// DefaultAutoSwitchConfig возвращает конфигурацию по умолчанию
+// DefaultAutoSwitchConfig возвращает конфигурацию по умолчанию: переключение только между
+// режимами, которые передают данные туннелей (MASQUE, gRPC и WebSocket несут лишь управление relay)
 func DefaultAutoSwitchConfig() *AutoSwitchConfig {
 	return &AutoSwitchConfig{
-		Order:    []Mode{ModeH3, ModeH2Connect, ModeWS, ModeTCP},
-		Cooldown: 15 * time.Second,
-		MinDwell: 20 * time.Second,
+		Order:             []Mode{ModeQUIC, ModeWireGuard},
+		Cooldown:          15 * time.Second,
+		MinDwell:          20 * time.Second,
+		CheckInterval:     10 * time.Second,
+		CheckTimeout:      5 * time.Second,
+		FailureThreshold:  3,
+		RecoveryThreshold: 3,
 	}
 }
 
@@ -65,23 +114,73 @@ func NewAutoSwitchManager(config *AutoSwitchConfig, logger Logger) *AutoSwitchMa
 	if config == nil {
 		config = DefaultAutoSwitchConfig()
 	}
+	defaults := DefaultAutoSwitchConfig()
+	if len(config.Order) == 0 {
+		config.Order = defaults.Order
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+	if config.CheckTimeout <= 0 {
+		config.CheckTimeout = defaults.CheckTimeout
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.RecoveryThreshold <= 0 {
+		config.RecoveryThreshold = 1
+	}
+
+	standby := make(map[Mode]bool, len(config.Standby))
+	for _, mode := range config.Standby {
+		standby[mode] = true
+	}
 
 	return &AutoSwitchManager{
-		order:          config.Order,
-		healthCheckers: make(map[Mode]HealthChecker),
-		currentMode:    config.Order[0], // Начинаем с первого в списке
-		cooldown:       config.Cooldown,
-		minDwell:       config.MinDwell,
-		lastSwitch:     time.Now(), // CRITICAL: инициализируем время
-		logger:         logger,
+		order:             config.Order,
+		standby:           standby,
+		healthCheckers:    make(map[Mode]HealthChecker),
+		states:            make(map[Mode]*modeState),
+		currentMode:       config.Order[0], // Начинаем с первого в списке
+		cooldown:          config.Cooldown,
+		minDwell:          config.MinDwell,
+		checkInterval:     config.CheckInterval,
+		checkTimeout:      config.CheckTimeout,
+		failureThreshold:  config.FailureThreshold,
+		recoveryThreshold: config.RecoveryThreshold,
+		lastSwitch:        time.Now(), // CRITICAL: инициализируем время
+		logger:            logger,
 	}
 }
 
-// RegisterHealthChecker регистрирует health checker для режима
+// RegisterHealthChecker регистрирует health checker для режима (заменяет существующий)
 func (asm *AutoSwitchManager) RegisterHealthChecker(mode Mode, checker HealthChecker) {
 	asm.mu.Lock()
 	defer asm.mu.Unlock()
 	asm.healthCheckers[mode] = checker
+	asm.states[mode] = &modeState{}
+}
+
+// UnregisterHealthChecker удаляет health checker режима; режим перестает быть кандидатом
+func (asm *AutoSwitchManager) UnregisterHealthChecker(mode Mode) {
+	asm.mu.Lock()
+	defer asm.mu.Unlock()
+	delete(asm.healthCheckers, mode)
+	delete(asm.states, mode)
+}
+
+// SetSwitchHandler задает обработчик, выполняющий переключение
+func (asm *AutoSwitchManager) SetSwitchHandler(handler SwitchHandler) {
+	asm.mu.Lock()
+	defer asm.mu.Unlock()
+	asm.handler = handler
+}
+
+// AddSwitchCallback добавляет callback на успешное переключение
+func (asm *AutoSwitchManager) AddSwitchCallback(callback SwitchCallback) {
+	asm.mu.Lock()
+	defer asm.mu.Unlock()
+	asm.callbacks = append(asm.callbacks, callback)
 }
 
 // GetCurrentMode возвращает текущий режим
@@ -91,34 +190,171 @@ func (asm *AutoSwitchManager) GetCurrentMode() Mode {
 	return asm.currentMode
 }
 
-// NextHealthy находит следующий здоровый режим
-func (asm *AutoSwitchManager) NextHealthy(ctx context.Context) Mode {
+// Start запускает периодические проверки. Первая проверка выполняется сразу.
+func (asm *AutoSwitchManager) Start() error {
+	asm.mu.Lock()
+	if asm.cancel != nil {
+		asm.mu.Unlock()
+		return fmt.Errorf("auto-switch already running")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	asm.cancel = cancel
+	asm.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(asm.checkInterval)
+		defer ticker.Stop()
+
+		asm.Evaluate(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				asm.Evaluate(ctx)
+			}
+		}
+	}()
+
+	asm.logger.Info("Transport auto-switch started",
+		"order", asm.order,
+		"interval", asm.checkInterval,
+		"current_mode", asm.GetCurrentMode())
+	return nil
+}
+
+// Stop останавливает периодические проверки
+func (asm *AutoSwitchManager) Stop() {
 	asm.mu.Lock()
 	defer asm.mu.Unlock()
+	if asm.cancel != nil {
+		asm.cancel()
+		asm.cancel = nil
+	}
+}
 
-	// Проверяем, можно ли переключаться
-	if !asm.canSwitch() && asm.isHealthyNoLock(ctx, asm.currentMode) {
-		return asm.currentMode
+// CheckAll выполняет проверки всех зарегистрированных режимов параллельно
+func (asm *AutoSwitchManager) CheckAll(ctx context.Context) {
+	asm.mu.RLock()
+	checkers := make(map[Mode]HealthChecker, len(asm.healthCheckers))
+	for mode, checker := range asm.healthCheckers {
+		checkers[mode] = checker
+	}
+	asm.mu.RUnlock()
+
+	type result struct {
+		mode      Mode
+		ok        bool
+		latency   time.Duration
+		errorRate float64
+	}
+	results := make(chan result, len(checkers))
+
+	for mode, checker := range checkers {
+		go func(mode Mode, checker HealthChecker) {
+			checkCtx, cancel := context.WithTimeout(ctx, asm.checkTimeout)
+			defer cancel()
+			ok := checker.OK(checkCtx)
+			results <- result{
+				mode:      mode,
+				ok:        ok,
+				latency:   checker.Latency(checkCtx),
+				errorRate: checker.ErrorRate(checkCtx),
+			}
+		}(mode, checker)
+	}
+
+	for range checkers {
+		r := <-results
+		asm.mu.Lock()
+		state, exists := asm.states[r.mode]
+		if exists {
+			state.healthy = r.ok
+			state.latency = r.latency
+			state.errorRate = r.errorRate
+			state.lastChecked = time.Now()
+			if r.ok {
+				state.wasHealthy = true
+				state.okStreak++
+				state.failStreak = 0
+			} else {
+				state.failStreak++
+				state.okStreak = 0
+			}
+		}
+		asm.mu.Unlock()
+	}
+}
+
+// Evaluate выполняет проверки и применяет политику переключения. Возвращает текущий режим.
+func (asm *AutoSwitchManager) Evaluate(ctx context.Context) Mode {
+	asm.CheckAll(ctx)
+
+	for _, candidate := range asm.candidates() {
+		if err := asm.switchTo(ctx, candidate, "policy"); err != nil {
+			asm.logger.Error("Failed to switch transport mode", "to", candidate, "error", err)
+			asm.markFailed(candidate)
+			continue
+		}
+		break
 	}
 
-	// Ищем лучший режим
+	return asm.GetCurrentMode()
+}
+
+// candidates возвращает режимы, на которые нужно попробовать переключиться, в порядке приоритета
+func (asm *AutoSwitchManager) candidates() []Mode {
+	asm.mu.RLock()
+	defer asm.mu.RUnlock()
+
+	// Режим, который ни разу не прошел проверку, не защищен гистерезисом
+	current := asm.states[asm.currentMode]
+	currentFailed := current == nil || current.failStreak >= asm.failureThreshold ||
+		(!current.healthy && !current.wasHealthy && !current.lastChecked.IsZero())
+
+	var candidates []Mode
 	for _, mode := range asm.order {
 		if mode == asm.currentMode {
-			continue // Пропускаем текущий
+			if !currentFailed {
+				// Ниже текущего по приоритету переключаемся только при его отказе
+				break
+			}
+			continue
+		}
+		state, exists := asm.states[mode]
+		if !exists || !state.healthy {
+			continue
+		}
+		if currentFailed {
+			// Failover: любой здоровый режим, cooldown не применяется
+			candidates = append(candidates, mode)
+			continue
 		}
-		if asm.isHealthyNoLock(ctx, mode) && asm.betterThanCurrent(ctx, mode) {
-			asm.logger.Info("Switching transport mode",
-				"from", asm.currentMode,
-				"to", mode)
-			asm.currentMode = mode
-			asm.lastSwitch = time.Now()
-			return mode
+		// Возврат на более приоритетный режим с гистерезисом
+		if state.okStreak >= asm.recoveryThreshold && asm.canSwitch() {
+			candidates = append(candidates, mode)
 		}
 	}
 
-	// Если ничего не найдено, возвращаем текущий режим
-	asm.logger.Warn("No better healthy transport mode found")
-	return asm.currentMode
+	if currentFailed && current != nil {
+		// Резервный режим нельзя проверить до подъема: пробуем его последним, а после
+		// неудачного подъема не раньше, чем через cooldown
+		for _, mode := range asm.order {
+			state, exists := asm.states[mode]
+			if mode == asm.currentMode || !asm.standby[mode] || !exists || state.healthy {
+				continue
+			}
+			if !state.failedAt.IsZero() && time.Since(state.failedAt) < asm.cooldown {
+				continue
+			}
+			candidates = append(candidates, mode)
+		}
+	}
+
+	if currentFailed && len(candidates) == 0 && current != nil {
+		asm.logger.Warn("No healthy transport mode available", "current_mode", asm.currentMode)
+	}
+	return candidates
 }
 
 // canSwitch проверяет, можно ли переключаться
@@ -132,56 +368,86 @@ func (asm *AutoSwitchManager) canSwitch() bool {
 	return true
 }
 
-// isHealthyNoLock проверяет здоровье режима без лока
-func (asm *AutoSwitchManager) isHealthyNoLock(ctx context.Context, mode Mode) bool {
-	checker, exists := asm.healthCheckers[mode]
-	if !exists {
-		asm.logger.Warn("No health checker for mode", "mode", mode)
-		return false
+// markFailed помечает режим как нездоровый после неудачного переключения
+func (asm *AutoSwitchManager) markFailed(mode Mode) {
+	asm.mu.Lock()
+	defer asm.mu.Unlock()
+	if state, exists := asm.states[mode]; exists {
+		state.healthy = false
+		state.okStreak = 0
+		state.failStreak = asm.failureThreshold
+		state.failedAt = time.Now()
 	}
-	return checker.OK(ctx)
 }
 
-// betterThanCurrent проверяет, лучше ли кандидат чем текущий режим
-func (asm *AutoSwitchManager) betterThanCurrent(ctx context.Context, candidate Mode) bool {
-	hcCand, okCand := asm.healthCheckers[candidate]
-	hcCurr, okCurr := asm.healthCheckers[asm.currentMode]
-	if !okCand || !okCurr {
-		return okCand && !okCurr
+// switchTo выполняет переключение через handler и уведомляет подписчиков
+func (asm *AutoSwitchManager) switchTo(ctx context.Context, to Mode, reason string) error {
+	asm.switchMu.Lock()
+	defer asm.switchMu.Unlock()
+
+	asm.mu.RLock()
+	from := asm.currentMode
+	handler := asm.handler
+	asm.mu.RUnlock()
+
+	if from == to {
+		return nil
 	}
 
-	// Простая гистерезис-логика: требуем 10% выигрыша по latency
-	latCand := hcCand.Latency(ctx)
-	latCurr := hcCurr.Latency(ctx)
-	return latCand < time.Duration(float64(latCurr)*0.9)
-}
+	if handler != nil {
+		if err := handler(ctx, from, to); err != nil {
+			return err
+		}
+	}
+
+	asm.mu.Lock()
+	asm.currentMode = to
+	asm.lastSwitch = time.Now()
+	asm.switches++
+	if state, exists := asm.states[from]; exists {
+		state.okStreak = 0
+	}
+	callbacks := make([]SwitchCallback, len(asm.callbacks))
+	copy(callbacks, asm.callbacks)
+	asm.mu.Unlock()
+
+	asm.logger.Info("Switched transport mode",
+		"from", from,
+		"to", to,
+		"reason", reason)
 
-// isHealthy проверяет здоровье режима (deprecated, используйте isHealthyNoLock)
-func (asm *AutoSwitchManager) isHealthy(ctx context.Context, mode Mode) bool {
-	return asm.isHealthyNoLock(ctx, mode)
+	for _, cb := range callbacks {
+		cb(from, to)
+	}
+	return nil
 }
 
-// ForceSwitch принудительно переключает на режим
-func (asm *AutoSwitchManager) ForceSwitch(mode Mode) {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
+// LastSwitch возвращает время последнего переключения (нулевое, если переключений не было)
+func (asm *AutoSwitchManager) LastSwitch() time.Time {
+	asm.mu.RLock()
+	defer asm.mu.RUnlock()
+	if asm.switches == 0 {
+		return time.Time{}
+	}
+	return asm.lastSwitch
+}
 
+// ForceSwitch принудительно переключает на режим, игнорируя политику
+func (asm *AutoSwitchManager) ForceSwitch(mode Mode) error {
 	asm.logger.Info("Force switching transport mode",
-		"from", asm.currentMode,
+		"from", asm.GetCurrentMode(),
 		"to", mode)
-
-	asm.currentMode = mode
-	asm.lastSwitch = time.Now()
+	return asm.switchTo(context.Background(), mode, "forced")
 }
 
-// GetHealthStatus возвращает статус здоровья всех режимов
+// GetHealthStatus возвращает статус здоровья всех режимов по результатам последних проверок
 func (asm *AutoSwitchManager) GetHealthStatus(ctx context.Context) map[Mode]bool {
 	asm.mu.RLock()
 	defer asm.mu.RUnlock()
 
 	status := make(map[Mode]bool)
-	for mode := range asm.healthCheckers {
-		status[mode] = asm.isHealthy(ctx, mode)
+	for mode, state := range asm.states {
+		status[mode] = state.healthy
 	}
 
 	return status
@@ -192,117 +458,26 @@ func (asm *AutoSwitchManager) GetMetrics() map[string]interface{} {
 	asm.mu.RLock()
 	defer asm.mu.RUnlock()
 
+	modes := make(map[string]interface{}, len(asm.states))
+	for mode, state := range asm.states {
+		modes[string(mode)] = map[string]interface{}{
+			"healthy":      state.healthy,
+			"latency_ms":   state.latency.Milliseconds(),
+			"error_rate":   state.errorRate,
+			"ok_streak":    state.okStreak,
+			"fail_streak":  state.failStreak,
+			"last_checked": state.lastChecked,
+			"standby":      asm.standby[mode],
+		}
+	}
+
 	return map[string]interface{}{
 		"current_mode":    asm.currentMode,
 		"last_switch":     asm.lastSwitch,
+		"switches":        asm.switches,
 		"cooldown":        asm.cooldown.String(),
 		"min_dwell":       asm.minDwell.String(),
 		"available_modes": len(asm.healthCheckers),
+		"modes":           modes,
 	}
 }
-
-// H3HealthChecker health checker для HTTP/3
-type H3HealthChecker struct {
-	client *http.Client
-	url    string
-}
-
-// NewH3HealthChecker создает health checker для HTTP/3
-func NewH3HealthChecker(client *http.Client, url string) *H3HealthChecker {
-	return &H3HealthChecker{
-		client: client,
-		url:    url,
-	}
-}
-
-// OK проверяет здоровье HTTP/3
-func (h3hc *H3HealthChecker) OK(ctx context.Context) bool {
-	req, err := http.NewRequestWithContext(ctx, "GET", h3hc.url+"/health", nil)
-	if err != nil {
-		return false
-	}
-
-	resp, err := h3hc.client.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	return resp.StatusCode == http.StatusOK
-}
-
-// Latency возвращает латентность HTTP/3
-func (h3hc *H3HealthChecker) Latency(ctx context.Context) time.Duration {
-	start := time.Now()
-	req, err := http.NewRequestWithContext(ctx, "GET", h3hc.url+"/health", nil)
-	if err != nil {
-		return 0
-	}
-
-	resp, err := h3hc.client.Do(req)
-	if err != nil {
-		return 0
-	}
-	defer resp.Body.Close()
-
-	return time.Since(start)
-}
-
-// ErrorRate возвращает rate ошибок HTTP/3
-func (h3hc *H3HealthChecker) ErrorRate(ctx context.Context) float64 {
-	// TODO: Реализовать подсчет error rate
-	return 0.0
-}
-
-// H2HealthChecker health checker для HTTP/2 CONNECT
-type H2HealthChecker struct {
-	client *http.Client
-	url    string
-}
-
-// NewH2HealthChecker создает health checker для HTTP/2
-func NewH2HealthChecker(client *http.Client, url string) *H2HealthChecker {
-	return &H2HealthChecker{
-		client: client,
-		url:    url,
-	}
-}
-
-// OK проверяет здоровье HTTP/2
-func (h2hc *H2HealthChecker) OK(ctx context.Context) bool {
-	req, err := http.NewRequestWithContext(ctx, "GET", h2hc.url+"/health", nil)
-	if err != nil {
-		return false
-	}
-
-	resp, err := h2hc.client.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	return resp.StatusCode == http.StatusOK
-}
-
-// Latency возвращает латентность HTTP/2
-func (h2hc *H2HealthChecker) Latency(ctx context.Context) time.Duration {
-	start := time.Now()
-	req, err := http.NewRequestWithContext(ctx, "GET", h2hc.url+"/health", nil)
-	if err != nil {
-		return 0
-	}
-
-	resp, err := h2hc.client.Do(req)
-	if err != nil {
-		return 0
-	}
-	defer resp.Body.Close()
-
-	return time.Since(start)
-}
-
-// ErrorRate возвращает rate ошибок HTTP/2
-func (h2hc *H2HealthChecker) ErrorRate(ctx context.Context) float64 {
-	// TODO: Реализовать подсчет error rate
-	return 0.0
-}
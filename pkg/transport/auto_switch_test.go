@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLogger реализует Logger для тестов
+type testLogger struct{}
+
+func (l *testLogger) Info(msg string, fields ...interface{})  {}
+func (l *testLogger) Error(msg string, fields ...interface{}) {}
+func (l *testLogger) Debug(msg string, fields ...interface{}) {}
+func (l *testLogger) Warn(msg string, fields ...interface{})  {}
+
+// fakeChecker возвращает заданное состояние здоровья
+type fakeChecker struct {
+	healthy atomic.Bool
+}
+
+func newFakeChecker(healthy bool) *fakeChecker {
+	c := &fakeChecker{}
+	c.healthy.Store(healthy)
+	return c
+}
+
+func (c *fakeChecker) OK(ctx context.Context) bool               { return c.healthy.Load() }
+func (c *fakeChecker) Latency(ctx context.Context) time.Duration { return time.Millisecond }
+func (c *fakeChecker) ErrorRate(ctx context.Context) float64     { return 0 }
+
+func newTestManager(cooldown time.Duration) *AutoSwitchManager {
+	return NewAutoSwitchManager(&AutoSwitchConfig{
+		Order:             []Mode{ModeQUIC, ModeGRPC, ModeWireGuard},
+		Cooldown:          cooldown,
+		CheckTimeout:      time.Second,
+		FailureThreshold:  2,
+		RecoveryThreshold: 2,
+	}, &testLogger{})
+}
+
+func TestAutoSwitch_FailoverAfterThreshold(t *testing.T) {
+	asm := newTestManager(time.Hour)
+	quic := newFakeChecker(true)
+	asm.RegisterHealthChecker(ModeQUIC, quic)
+	asm.RegisterHealthChecker(ModeGRPC, newFakeChecker(true))
+	asm.RegisterHealthChecker(ModeWireGuard, newFakeChecker(true))
+
+	ctx := context.Background()
+	if mode := asm.Evaluate(ctx); mode != ModeQUIC {
+		t.Fatalf("Expected to stay on QUIC, got %s", mode)
+	}
+
+	// Одна неудачная проверка не приводит к переключению (гистерезис)
+	quic.healthy.Store(false)
+	if mode := asm.Evaluate(ctx); mode != ModeQUIC {
+		t.Fatalf("Expected to stay on QUIC after single failure, got %s", mode)
+	}
+
+	// Failover на следующий по приоритету режим, cooldown не применяется
+	if mode := asm.Evaluate(ctx); mode != ModeGRPC {
+		t.Errorf("Expected failover to gRPC, got %s", mode)
+	}
+}
+
+func TestAutoSwitch_RecoveryRespectsCooldown(t *testing.T) {
+	asm := newTestManager(time.Hour)
+	quic := newFakeChecker(false)
+	asm.RegisterHealthChecker(ModeQUIC, quic)
+	asm.RegisterHealthChecker(ModeGRPC, newFakeChecker(true))
+
+	ctx := context.Background()
+	// QUIC ни разу не был здоров — немедленный переход на gRPC
+	if mode := asm.Evaluate(ctx); mode != ModeGRPC {
+		t.Fatalf("Expected initial failover to gRPC, got %s", mode)
+	}
+
+	quic.healthy.Store(true)
+	for i := 0; i < 3; i++ {
+		asm.Evaluate(ctx)
+	}
+	if mode := asm.GetCurrentMode(); mode != ModeGRPC {
+		t.Errorf("Expected to stay on gRPC during cooldown, got %s", mode)
+	}
+
+	// После истечения cooldown возвращаемся на более приоритетный QUIC
+	asm.mu.Lock()
+	asm.cooldown = 0
+	asm.mu.Unlock()
+	if mode := asm.Evaluate(ctx); mode != ModeQUIC {
+		t.Errorf("Expected recovery to QUIC, got %s", mode)
+	}
+}
+
+func TestAutoSwitch_HandlerFailureTriesNextMode(t *testing.T) {
+	asm := newTestManager(time.Hour)
+	asm.RegisterHealthChecker(ModeQUIC, newFakeChecker(false))
+	asm.RegisterHealthChecker(ModeGRPC, newFakeChecker(true))
+	asm.RegisterHealthChecker(ModeWireGuard, newFakeChecker(true))
+
+	asm.SetSwitchHandler(func(ctx context.Context, from, to Mode) error {
+		if to == ModeGRPC {
+			return errors.New("tunnel migration failed")
+		}
+		return nil
+	})
+
+	var switched []Mode
+	asm.AddSwitchCallback(func(from, to Mode) {
+		switched = append(switched, to)
+	})
+
+	if mode := asm.Evaluate(context.Background()); mode != ModeWireGuard {
+		t.Errorf("Expected switch to WireGuard after failed gRPC migration, got %s", mode)
+	}
+	if len(switched) != 1 || switched[0] != ModeWireGuard {
+		t.Errorf("Expected single switch callback for WireGuard, got %v", switched)
+	}
+}
+
+func TestAutoSwitch_StandbyFailover(t *testing.T) {
+	asm := NewAutoSwitchManager(&AutoSwitchConfig{
+		Order:             []Mode{ModeQUIC, ModeWireGuard},
+		Standby:           []Mode{ModeWireGuard},
+		Cooldown:          time.Hour,
+		CheckTimeout:      time.Second,
+		FailureThreshold:  1,
+		RecoveryThreshold: 1,
+	}, &testLogger{})
+	quic := newFakeChecker(true)
+	asm.RegisterHealthChecker(ModeQUIC, quic)
+	// Резервный режим не проходит проверку, пока не поднят
+	asm.RegisterHealthChecker(ModeWireGuard, newFakeChecker(false))
+
+	var attempts int
+	asm.SetSwitchHandler(func(ctx context.Context, from, to Mode) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("interface setup failed")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	if mode := asm.Evaluate(ctx); mode != ModeQUIC {
+		t.Fatalf("Expected to stay on healthy QUIC, got %s", mode)
+	}
+	if attempts != 0 {
+		t.Fatalf("Standby mode tried while current mode is healthy (%d attempts)", attempts)
+	}
+
+	quic.healthy.Store(false)
+	if mode := asm.Evaluate(ctx); mode != ModeQUIC || attempts != 1 {
+		t.Fatalf("Expected failed standby switch to keep QUIC, got %s after %d attempts", mode, attempts)
+	}
+	// После неудачного подъема резерв пробуется снова только через cooldown
+	if asm.Evaluate(ctx); attempts != 1 {
+		t.Fatalf("Standby retried during cooldown (%d attempts)", attempts)
+	}
+
+	asm.mu.Lock()
+	asm.cooldown = 0
+	asm.mu.Unlock()
+	if mode := asm.Evaluate(ctx); mode != ModeWireGuard {
+		t.Errorf("Expected failover to standby WireGuard, got %s", mode)
+	}
+}
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ProbeFunc выполняет одну проверку транспорта
+type ProbeFunc func(ctx context.Context) error
+
+// defaultErrorWindow размер окна для расчета error rate
+const defaultErrorWindow = 20
+
+// ProbeHealthChecker реализует HealthChecker поверх одиночной проверки:
+// OK выполняет probe, Latency и ErrorRate возвращают результаты последних проверок.
+type ProbeHealthChecker struct {
+	probe   ProbeFunc
+	latency time.Duration
+	lastErr error
+	results []bool // Скользящее окно результатов
+	mu      sync.RWMutex
+}
+
+// NewProbeHealthChecker создает health checker для произвольной проверки
+func NewProbeHealthChecker(probe ProbeFunc) *ProbeHealthChecker {
+	return &ProbeHealthChecker{probe: probe}
+}
+
+// OK выполняет проверку и сохраняет ее результат
+func (pc *ProbeHealthChecker) OK(ctx context.Context) bool {
+	start := time.Now()
+	err := pc.probe(ctx)
+	elapsed := time.Since(start)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.lastErr = err
+	if err == nil {
+		pc.latency = elapsed
+	}
+	pc.results = append(pc.results, err == nil)
+	if len(pc.results) > defaultErrorWindow {
+		pc.results = pc.results[len(pc.results)-defaultErrorWindow:]
+	}
+	return err == nil
+}
+
+// Latency возвращает латентность последней успешной проверки
+func (pc *ProbeHealthChecker) Latency(ctx context.Context) time.Duration {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.latency
+}
+
+// ErrorRate возвращает долю неудачных проверок в окне
+func (pc *ProbeHealthChecker) ErrorRate(ctx context.Context) float64 {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if len(pc.results) == 0 {
+		return 0.0
+	}
+	failed := 0
+	for _, ok := range pc.results {
+		if !ok {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(pc.results))
+}
+
+// LastError возвращает ошибку последней проверки
+func (pc *ProbeHealthChecker) LastError() error {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.lastErr
+}
+
+// NewQUICHealthChecker создает health checker, выполняющий QUIC handshake с relay
+func NewQUICHealthChecker(address string, tlsConf *tls.Config) *ProbeHealthChecker {
+	return NewProbeHealthChecker(func(ctx context.Context) error {
+		conn, err := quic.DialAddr(ctx, address, tlsConf, &quic.Config{})
+		if err != nil {
+			return fmt.Errorf("QUIC handshake failed: %w", err)
+		}
+		return conn.CloseWithError(0, "health check")
+	})
+}
+
+// WireGuardStatusFunc возвращает состояние WireGuard интерфейса:
+// up — интерфейс поднят, lastHandshake — время последнего handshake с relay peer
+type WireGuardStatusFunc func() (up bool, lastHandshake time.Time, err error)
+
+// ErrWireGuardDown интерфейс WireGuard не поднят: проверить туннель до переключения
+// на него нельзя (см. AutoSwitchConfig.Standby)
+var ErrWireGuardDown = errors.New("WireGuard interface is down")
+
+// NewWireGuardHealthChecker создает health checker для WireGuard: поднятый интерфейс
+// требует свежего handshake с relay, неподнятый — отказ.
+func NewWireGuardHealthChecker(statusFn WireGuardStatusFunc, maxHandshakeAge time.Duration) *ProbeHealthChecker {
+	return NewProbeHealthChecker(func(ctx context.Context) error {
+		up, lastHandshake, err := statusFn()
+		if err != nil {
+			return fmt.Errorf("WireGuard unavailable: %w", err)
+		}
+		if !up {
+			return ErrWireGuardDown
+		}
+		if lastHandshake.IsZero() {
+			return errors.New("no WireGuard handshake with relay")
+		}
+		if age := time.Since(lastHandshake); age > maxHandshakeAge {
+			return fmt.Errorf("WireGuard handshake is stale (%s)", age.Round(time.Second))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWireGuardHealthChecker(t *testing.T) {
+	tests := []struct {
+		name          string
+		up            bool
+		lastHandshake time.Time
+		err           error
+		want          bool
+	}{
+		{name: "down", up: false, want: false},
+		{name: "status error", err: errors.New("no device"), want: false},
+		{name: "no handshake", up: true, want: false},
+		{name: "stale handshake", up: true, lastHandshake: time.Now().Add(-time.Hour), want: false},
+		{name: "fresh handshake", up: true, lastHandshake: time.Now(), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewWireGuardHealthChecker(func() (bool, time.Time, error) {
+				return tt.up, tt.lastHandshake, tt.err
+			}, 3*time.Minute)
+			if got := checker.OK(context.Background()); got != tt.want {
+				t.Errorf("OK() = %v, want %v (error: %v)", got, tt.want, checker.LastError())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package tunnel
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
@@ -90,6 +91,10 @@ func (t *Tunnel) SetActive(active bool) {
 // TunnelStats represents tunnel statistics
 type TunnelStats struct {
 	BytesTransferred   int64
+	BytesSent          int64 // local -> remote
+	BytesReceived      int64 // remote -> local
+	PacketsSent        int64 // writes to remote
+	PacketsReceived    int64 // writes to local
 	ConnectionsHandled int64
 	ActiveConnections  int32
 	LastActivity       time.Time
@@ -111,6 +116,26 @@ func (ts *TunnelStats) UpdateBytesTransferred(bytes int64) {
 	ts.LastActivity = time.Now()
 }
 
+// RecordSent records a chunk forwarded from the local side to the remote host
+func (ts *TunnelStats) RecordSent(bytes int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.BytesTransferred += bytes
+	ts.BytesSent += bytes
+	ts.PacketsSent++
+	ts.LastActivity = time.Now()
+}
+
+// RecordReceived records a chunk forwarded from the remote host to the local side
+func (ts *TunnelStats) RecordReceived(bytes int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.BytesTransferred += bytes
+	ts.BytesReceived += bytes
+	ts.PacketsReceived++
+	ts.LastActivity = time.Now()
+}
+
 // IncrementConnections increments connection count
 func (ts *TunnelStats) IncrementConnections() {
 	ts.mu.Lock()
@@ -135,16 +160,25 @@ func (ts *TunnelStats) GetStats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"bytes_transferred":   ts.BytesTransferred,
+		"bytes_sent":          ts.BytesSent,
+		"bytes_received":      ts.BytesReceived,
+		"packets_sent":        ts.PacketsSent,
+		"packets_received":    ts.PacketsReceived,
 		"connections_handled": ts.ConnectionsHandled,
 		"active_connections":  ts.ActiveConnections,
 		"last_activity":       ts.LastActivity,
 	}
 }
 
+// DialFunc connects to a remote tunnel endpoint
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
 // Manager handles tunnel operations
 type Manager struct {
 	client  interfaces.ClientInterface
 	tunnels map[string]*Tunnel
+	closed  TrafficStats // Traffic of unregistered tunnels, keeps totals monotonic
+	dial    DialFunc     // Remote connections; nil dials through the system network
 	mu      sync.RWMutex
 }
 
@@ -156,6 +190,27 @@ func NewManager(client interfaces.ClientInterface) *Manager {
 	}
 }
 
+// SetDialer routes remote tunnel connections through dial, e.g. into a userspace
+// WireGuard netstack that has no system interface
+func (m *Manager) SetDialer(dial DialFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dial = dial
+}
+
+// dialRemote connects to the remote end of the tunnel
+func (m *Manager) dialRemote(tunnel *Tunnel) (net.Conn, error) {
+	m.mu.RLock()
+	dial := m.dial
+	m.mu.RUnlock()
+
+	address := net.JoinHostPort(tunnel.RemoteHost, strconv.Itoa(tunnel.RemotePort))
+	if dial == nil {
+		return net.Dial("tcp", address)
+	}
+	return dial(context.Background(), "tcp", address)
+}
+
 // RegisterTunnel registers a new tunnel
 func (m *Manager) RegisterTunnel(tunnelID string, localPort int, remoteHost string, remotePort int) error {
 	m.mu.Lock()
@@ -203,6 +258,7 @@ func (m *Manager) UnregisterTunnel(tunnelID string) error {
 	}
 
 	tunnel.SetActive(false)
+	tunnel.Stats.addTo(&m.closed)
 	delete(m.tunnels, tunnelID)
 
 	return nil
@@ -322,7 +378,7 @@ func (m *Manager) handleTunnelConnection(tunnel *Tunnel, localConn net.Conn) {
 	defer tunnel.Stats.DecrementConnections()
 
 	// Connect to remote host
-	remoteConn, err := net.Dial("tcp", net.JoinHostPort(tunnel.RemoteHost, strconv.Itoa(tunnel.RemotePort)))
+	remoteConn, err := m.dialRemote(tunnel)
 	if err != nil {
 		fmt.Printf("Failed to connect to remote host for tunnel %s: %v\n", tunnel.ID, err)
 		return
@@ -350,7 +406,7 @@ func (m *Manager) handleTunnelConnection(tunnel *Tunnel, localConn net.Conn) {
 				if err != nil {
 					break
 				}
-				tunnel.Stats.UpdateBytesTransferred(int64(n))
+				tunnel.Stats.RecordSent(int64(n))
 			}
 		}
 		done <- true
@@ -370,7 +426,7 @@ func (m *Manager) handleTunnelConnection(tunnel *Tunnel, localConn net.Conn) {
 				if err != nil {
 					break
 				}
-				tunnel.Stats.UpdateBytesTransferred(int64(n))
+				tunnel.Stats.RecordReceived(int64(n))
 			}
 		}
 		done <- true
@@ -381,6 +437,40 @@ func (m *Manager) handleTunnelConnection(tunnel *Tunnel, localConn net.Conn) {
 	<-done
 }
 
+// TrafficStats contains traffic totals across all tunnels
+type TrafficStats struct {
+	BytesSent       int64
+	BytesReceived   int64
+	PacketsSent     int64
+	PacketsReceived int64
+	ActiveTunnels   int // Only counted for registered tunnels
+}
+
+// addTo adds the tunnel traffic counters to total
+func (ts *TunnelStats) addTo(total *TrafficStats) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	total.BytesSent += ts.BytesSent
+	total.BytesReceived += ts.BytesReceived
+	total.PacketsSent += ts.PacketsSent
+	total.PacketsReceived += ts.PacketsReceived
+}
+
+// GetTrafficStats returns traffic totals since start, including tunnels that were unregistered
+func (m *Manager) GetTrafficStats() TrafficStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := m.closed
+	for _, tunnel := range m.tunnels {
+		if tunnel.IsActive() {
+			total.ActiveTunnels++
+		}
+		tunnel.Stats.addTo(&total)
+	}
+	return total
+}
+
 // GetTunnelStats returns statistics for all tunnels
 func (m *Manager) GetTunnelStats() map[string]interface{} {
 	m.mu.RLock()
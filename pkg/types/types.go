@@ -32,15 +32,47 @@ type Config struct {
 	DERP         DERPConfig         `mapstructure:"derp"`
 	WebSocket    WebSocketConfig    `mapstructure:"websocket"`
 	WireGuard    WireGuardConfig    `mapstructure:"wireguard"`
+	AutoSwitch   AutoSwitchConfig   `mapstructure:"auto_switch"`
 }
 
 // RelayConfig contains relay server connection settings
 type RelayConfig struct {
-	Host    string        `mapstructure:"host"`
-	Port    int           `mapstructure:"port"` // Legacy port for backward compatibility
-	Ports   RelayPorts    `mapstructure:"ports"`
-	Timeout time.Duration `mapstructure:"timeout"`
-	TLS     TLSConfig     `mapstructure:"tls"`
+	Host      string             `mapstructure:"host"`
+	Port      int                `mapstructure:"port"` // Legacy port for backward compatibility
+	Ports     RelayPorts         `mapstructure:"ports"`
+	Timeout   time.Duration      `mapstructure:"timeout"`
+	TLS       TLSConfig          `mapstructure:"tls"`
+	Endpoints []RelayEndpoint    `mapstructure:"endpoints"` // Optional list of relay POPs
+	Selection POPSelectionConfig `mapstructure:"selection"`
+	Discovery DiscoveryConfig    `mapstructure:"discovery"`
+}
+
+// DiscoveryConfig contains relay endpoint discovery settings.
+// Discovered QUIC/MASQUE/STUN/TURN endpoints override relay.host and relay.ports.
+type DiscoveryConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Method       string        `mapstructure:"method"`         // srv, well_known or auto
+	Domain       string        `mapstructure:"domain"`         // Defaults to relay.host
+	WellKnownURL string        `mapstructure:"well_known_url"` // Overrides https://<domain>/.well-known/cloudbridge-relay.json
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// RelayEndpoint describes a single relay POP.
+// Empty Port/ServerName fall back to Relay.Port/Relay.TLS.ServerName.
+type RelayEndpoint struct {
+	ID         string `mapstructure:"id"`
+	Host       string `mapstructure:"host"`
+	Port       int    `mapstructure:"port"`
+	Region     string `mapstructure:"region"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// POPSelectionConfig contains latency-based relay POP selection settings
+type POPSelectionConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	ProbeInterval   time.Duration `mapstructure:"probe_interval"`
+	ProbeTimeout    time.Duration `mapstructure:"probe_timeout"`
+	PreferredRegion string        `mapstructure:"preferred_region"`
 }
 
 // RelayPorts contains all relay server ports
@@ -162,16 +194,78 @@ type QUICConfig struct {
 
 // P2PConfig contains P2P mesh configuration
 type P2PConfig struct {
-	MaxConnections          int           `mapstructure:"max_connections"`
-	SessionTimeout          time.Duration `mapstructure:"session_timeout"`
-	PeerDiscoveryInterval   time.Duration `mapstructure:"peer_discovery_interval"`
-	ConnectionRetryInterval time.Duration `mapstructure:"connection_retry_interval"`
-	MaxRetryAttempts        int           `mapstructure:"max_retry_attempts"`
-	HeartbeatInterval       time.Duration `mapstructure:"heartbeat_interval"`
-	HeartbeatTimeout        time.Duration `mapstructure:"heartbeat_timeout"`
-	FallbackEnabled         bool          `mapstructure:"fallback_enabled"`
-	DERPFallback            bool          `mapstructure:"derp_fallback"`
-	WebSocketFallback       bool          `mapstructure:"websocket_fallback"`
+	MaxConnections          int               `mapstructure:"max_connections"`
+	SessionTimeout          time.Duration     `mapstructure:"session_timeout"`
+	PeerDiscoveryInterval   time.Duration     `mapstructure:"peer_discovery_interval"`
+	ConnectionRetryInterval time.Duration     `mapstructure:"connection_retry_interval"`
+	MaxRetryAttempts        int               `mapstructure:"max_retry_attempts"`
+	HeartbeatInterval       time.Duration     `mapstructure:"heartbeat_interval"`
+	HeartbeatTimeout        time.Duration     `mapstructure:"heartbeat_timeout"`
+	FallbackEnabled         bool              `mapstructure:"fallback_enabled"`
+	DERPFallback            bool              `mapstructure:"derp_fallback"`
+	WebSocketFallback       bool              `mapstructure:"websocket_fallback"`
+	ListenPort              int               `mapstructure:"listen_port"` // shared ICE UDP port (0 = random) when port mapping is enabled
+	PortMapping             PortMappingConfig `mapstructure:"port_mapping"`
+	ACL                     ACLConfig         `mapstructure:"acl"`
+	Identity                IdentityConfig    `mapstructure:"identity"`
+	DNS                     MeshDNSConfig     `mapstructure:"dns"`
+	Routes                  RoutesConfig      `mapstructure:"routes"`
+	Control                 ControlConfig     `mapstructure:"control"`
+	SOCKS5                  SOCKSConfig       `mapstructure:"socks5"`
+}
+
+// SOCKSConfig contains the local SOCKS5 proxy configuration (reaches overlay IPs in netstack mode)
+type SOCKSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	ListenAddr string `mapstructure:"listen_addr"` // no authentication: keep it on localhost
+}
+
+// ControlConfig contains the local control API configuration (pending peer approval)
+type ControlConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Socket  string `mapstructure:"socket"` // Unix socket, mode 0600 (default ~/.cloudbridge-client/control.sock)
+}
+
+// PortMappingConfig contains UPnP IGD / NAT-PMP / PCP port mapping configuration
+type PortMappingConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Protocols []string      `mapstructure:"protocols"` // tried in order: pcp, natpmp, upnp
+	Gateway   string        `mapstructure:"gateway"`   // PCP/NAT-PMP gateway (default: default route gateway)
+	Lifetime  time.Duration `mapstructure:"lifetime"`
+}
+
+// ACLConfig contains mesh ACL policy sources besides the policy delivered in the JWT
+type ACLConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`          // fetch the policy from the relay API
+	PolicyFile      string        `mapstructure:"policy_file"`      // local JSON policy, hot-reloaded; overrides API and JWT
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // relay API polling period
+}
+
+// IdentityConfig contains the persistent peer identity key configuration
+type IdentityConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`           // advertise the key and pin peer keys in the QUIC handshake
+	KeyFile          string        `mapstructure:"key_file"`          // PKCS#8 PEM private key, created on first start
+	RotationInterval time.Duration `mapstructure:"rotation_interval"` // automatic key rotation (0 = manual only)
+	RequirePeerKeys  bool          `mapstructure:"require_peer_keys"` // reject peers without a known identity key
+	PinFile          string        `mapstructure:"pin_file"`          // peer keys pinned on first connection (TOFU)
+}
+
+// MeshDNSConfig contains the embedded mesh DNS configuration
+type MeshDNSConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`          // answer <peer-id>.<tenant>.<domain> on the overlay address
+	Domain          string   `mapstructure:"domain"`           // mesh zone suffix
+	ListenAddr      string   `mapstructure:"listen_addr"`      // default <overlay-ip>:53
+	Upstreams       []string `mapstructure:"upstreams"`        // resolvers for other names
+	ConfigureSystem string   `mapstructure:"configure_system"` // auto, resolved, resolvconf; empty leaves the system resolver alone
+}
+
+// RoutesConfig contains subnet router and exit node configuration
+type RoutesConfig struct {
+	Advertise         []string `mapstructure:"advertise"`           // LAN subnets reachable through this client
+	AdvertiseExitNode bool     `mapstructure:"advertise_exit_node"` // offer this client as an exit node (0.0.0.0/0, ::/0)
+	Accept            []string `mapstructure:"accept"`              // subnets advertised by other peers to route through them
+	ExitNode          string   `mapstructure:"exit_node"`           // peer ID of the exit node to use
+	SNAT              bool     `mapstructure:"snat"`                // masquerade overlay traffic into advertised subnets
 }
 
 // TURNConfig contains TURN server configuration
@@ -210,4 +304,18 @@ type WireGuardConfig struct {
 	Port                int           `mapstructure:"port"`
 	MTU                 int           `mapstructure:"mtu"`
 	PersistentKeepAlive time.Duration `mapstructure:"persistent_keepalive"`
+	Mode                string        `mapstructure:"mode"` // kernel (netlink/wgctrl), userspace (embedded wireguard-go on a TUN device) or netstack (wireguard-go on a gVisor netstack, no system interface)
+}
+
+// AutoSwitchConfig contains transport auto-switching policy settings.
+// Order lists data-plane transport modes (quic, wireguard) from highest to lowest priority.
+type AutoSwitchConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	Order             []string      `mapstructure:"order"`
+	CheckInterval     time.Duration `mapstructure:"check_interval"`
+	CheckTimeout      time.Duration `mapstructure:"check_timeout"`
+	Cooldown          time.Duration `mapstructure:"cooldown"`
+	MinDwell          time.Duration `mapstructure:"min_dwell"`
+	FailureThreshold  int           `mapstructure:"failure_threshold"`  // Consecutive failed checks before failover
+	RecoveryThreshold int           `mapstructure:"recovery_threshold"` // Consecutive successful checks before switching back
 }
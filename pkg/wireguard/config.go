@@ -0,0 +1,216 @@
+package wireguard
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeySize размер ключей WireGuard (Curve25519 и preshared)
+const KeySize = 32
+
+// Interface секция [Interface] конфигурации wg-quick
+type Interface struct {
+	PrivateKey string   // base64
+	ListenPort int      // 0 — случайный порт
+	Addresses  []string // адреса интерфейса в overlay сети (CIDR)
+	MTU        int
+	DNS        []string
+}
+
+// Peer секция [Peer] конфигурации wg-quick
+type Peer struct {
+	PublicKey           string // base64
+	PresharedKey        string // base64 (пусто — без PSK)
+	Endpoint            string // host:port (пусто — пир подключается сам)
+	AllowedIPs          []string
+	PersistentKeepalive time.Duration
+}
+
+// QuickConfig конфигурация в формате wg-quick, которую выдает relay API
+type QuickConfig struct {
+	Interface Interface
+	Peers     []Peer
+}
+
+// ParseQuickConfig разбирает конфигурацию wg-quick. Ключи PostUp/PreDown и
+// прочие настройки скриптов wg-quick игнорируются.
+func ParseQuickConfig(text string) (*QuickConfig, error) {
+	config := &QuickConfig{}
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		row := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(row, '#'); i >= 0 {
+			row = strings.TrimSpace(row[:i])
+		}
+		if row == "" {
+			continue
+		}
+		if strings.HasPrefix(row, "[") && strings.HasSuffix(row, "]") {
+			section = strings.ToLower(strings.Trim(row, "[]"))
+			if section == "peer" {
+				config.Peers = append(config.Peers, Peer{})
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(row, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		var err error
+		switch section {
+		case "interface":
+			err = config.Interface.set(key, value)
+		case "peer":
+			err = config.Peers[len(config.Peers)-1].set(key, value)
+		default:
+			err = fmt.Errorf("key %s outside of a section", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read WireGuard config: %w", err)
+	}
+
+	if config.Interface.PrivateKey == "" {
+		return nil, fmt.Errorf("WireGuard config has no private key")
+	}
+	for i, peer := range config.Peers {
+		if peer.PublicKey == "" {
+			return nil, fmt.Errorf("WireGuard peer %d has no public key", i+1)
+		}
+	}
+	return config, nil
+}
+
+func (i *Interface) set(key, value string) error {
+	switch key {
+	case "privatekey":
+		if _, err := decodeKey(value); err != nil {
+			return fmt.Errorf("invalid private key: %w", err)
+		}
+		i.PrivateKey = value
+	case "listenport":
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("invalid listen port %q", value)
+		}
+		i.ListenPort = port
+	case "address":
+		addresses, err := parsePrefixes(value)
+		if err != nil {
+			return err
+		}
+		i.Addresses = append(i.Addresses, addresses...)
+	case "mtu":
+		mtu, err := strconv.Atoi(value)
+		if err != nil || mtu <= 0 {
+			return fmt.Errorf("invalid MTU %q", value)
+		}
+		i.MTU = mtu
+	case "dns":
+		i.DNS = append(i.DNS, splitList(value)...)
+	}
+	return nil
+}
+
+func (p *Peer) set(key, value string) error {
+	switch key {
+	case "publickey":
+		if _, err := decodeKey(value); err != nil {
+			return fmt.Errorf("invalid public key: %w", err)
+		}
+		p.PublicKey = value
+	case "presharedkey":
+		if _, err := decodeKey(value); err != nil {
+			return fmt.Errorf("invalid preshared key: %w", err)
+		}
+		p.PresharedKey = value
+	case "endpoint":
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			return fmt.Errorf("invalid endpoint %q: %w", value, err)
+		}
+		p.Endpoint = value
+	case "allowedips":
+		prefixes, err := parsePrefixes(value)
+		if err != nil {
+			return err
+		}
+		p.AllowedIPs = append(p.AllowedIPs, prefixes...)
+	case "persistentkeepalive":
+		if value == "off" {
+			p.PersistentKeepalive = 0
+			return nil
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > 65535 {
+			return fmt.Errorf("invalid persistent keepalive %q", value)
+		}
+		p.PersistentKeepalive = time.Duration(seconds) * time.Second
+	}
+	return nil
+}
+
+// parsePrefixes разбирает список CIDR через запятую; адрес без маски считается хостом
+func parsePrefixes(value string) ([]string, error) {
+	items := splitList(value)
+	prefixes := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, _, err := net.ParseCIDR(item); err == nil {
+			prefixes = append(prefixes, item)
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", item)
+		}
+		if ip.To4() != nil {
+			prefixes = append(prefixes, item+"/32")
+		} else {
+			prefixes = append(prefixes, item+"/128")
+		}
+	}
+	return prefixes, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// decodeKey декодирует ключ WireGuard из base64
+func decodeKey(key string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %w", err)
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("invalid key size %d", len(raw))
+	}
+	return raw, nil
+}
+
+// hexKey переводит ключ из base64 в hex формат протокола настройки (UAPI)
+func hexKey(key string) (string, error) {
+	raw, err := decodeKey(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,457 @@
+package wireguard
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+// Logger interface for WireGuard logging
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+}
+
+// Режимы WireGuard
+const (
+	ModeKernel    = "kernel"    // интерфейс WireGuard ядра, настраивается через netlink и wgctrl
+	ModeUserspace = "userspace" // wireguard-go в процессе клиента на TUN интерфейсе
+	ModeNetstack  = "netstack"  // wireguard-go на стеке gVisor: без интерфейса системы и прав
+)
+
+// Config настройки устройства WireGuard
+type Config struct {
+	// InterfaceName имя интерфейса. Встроенному WireGuard без CAP_NET_ADMIN нужен заранее
+	// созданный постоянный TUN интерфейс пользователя (ip tuntap add mode tun user ...)
+	InterfaceName string
+	MTU           int
+	Mode          string // ModeKernel (по умолчанию), ModeUserspace или ModeNetstack
+	// InboundFilter проверяет пакеты, полученные от пиров, до записи в интерфейс
+	// (nil — пропускать все). Применяется только встроенным WireGuard.
+	InboundFilter PacketFilter
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{InterfaceName: "wg-cloudbridge", MTU: 1420}
+}
+
+// PeerStatus состояние пира WireGuard
+type PeerStatus struct {
+	PublicKey           string        `json:"public_key"`
+	PresharedKey        string        `json:"-"`
+	Endpoint            string        `json:"endpoint,omitempty"`
+	AllowedIPs          []string      `json:"allowed_ips"`
+	PersistentKeepalive time.Duration `json:"persistent_keepalive,omitempty"`
+	LastHandshake       time.Time     `json:"last_handshake"`
+	RxBytes             int64         `json:"rx_bytes"`
+	TxBytes             int64         `json:"tx_bytes"`
+}
+
+// Device WireGuard на wireguard-go в процессе клиента: не нужны модуль ядра,
+// ip link type wireguard и утилиты wg/wg-quick
+type Device struct {
+	config *Config
+	logger Logger
+	tun    tun.Device
+	filter *filteredTUN // nil — входящие пакеты не фильтруются
+	device *device.Device
+	net    *netstack.Net // стек gVisor в режиме ModeNetstack (nil — TUN интерфейс системы)
+	name   string
+	routes routeTable
+	link   bool // интерфейс системы: адреса и маршруты настраиваются клиентом
+	mu     sync.Mutex
+}
+
+// NewDevice creates a new userspace WireGuard device
+func NewDevice(config *Config, logger Logger) *Device {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.MTU == 0 {
+		config.MTU = DefaultConfig().MTU
+	}
+	return &Device{config: config, logger: logger}
+}
+
+// Start создает TUN интерфейс (в режиме ModeNetstack — стек gVisor), запускает на нем
+// WireGuard и назначает адреса iface
+func (d *Device) Start(iface *Interface) error {
+	mtu := d.config.MTU
+	if iface.MTU > 0 {
+		mtu = iface.MTU
+	}
+	if d.config.Mode == ModeNetstack {
+		return d.startNetstack(iface, mtu)
+	}
+	tunDevice, err := tun.CreateTUN(d.config.InterfaceName, mtu)
+	if err != nil {
+		return opError("create TUN", d.config.InterfaceName, "", err)
+	}
+	if err := d.start(tunDevice, conn.NewDefaultBind(), iface); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.link = true
+	d.mu.Unlock()
+
+	// Без прав на настройку интерфейса адреса назначаются заранее
+	if err := configureLink(d.name, iface.Addresses, mtu); err != nil {
+		d.logger.Warn("Failed to configure WireGuard interface addresses", "interface", d.name, "error", err)
+	}
+	d.logger.Info("Userspace WireGuard started", "interface", d.name, "addresses", iface.Addresses, "mtu", mtu)
+	return nil
+}
+
+// start запускает WireGuard на готовом TUN устройстве
+func (d *Device) start(tunDevice tun.Device, bind conn.Bind, iface *Interface) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.device != nil {
+		_ = tunDevice.Close() //nolint:errcheck // device is already running
+		return fmt.Errorf("WireGuard device already started")
+	}
+
+	// Стек gVisor не интерфейс системы: имя остается из настроек
+	name, err := tunDevice.Name()
+	if err != nil || d.config.Mode == ModeNetstack {
+		name = d.config.InterfaceName
+	}
+	var filter *filteredTUN
+	if d.config.InboundFilter != nil {
+		filter = newFilteredTUN(tunDevice, d.config.InboundFilter, d.logger)
+		tunDevice = filter
+	}
+	dev := device.NewDevice(tunDevice, bind, &device.Logger{
+		Verbosef: func(format string, args ...any) { d.logger.Debug(fmt.Sprintf(format, args...), "interface", name) },
+		Errorf:   func(format string, args ...any) { d.logger.Error(fmt.Sprintf(format, args...), "interface", name) },
+	})
+
+	privateKey, err := hexKey(iface.PrivateKey)
+	if err != nil {
+		dev.Close()
+		return fmt.Errorf("invalid WireGuard private key: %w", err)
+	}
+	if err := dev.IpcSet(fmt.Sprintf("private_key=%s\nlisten_port=%d\n", privateKey, iface.ListenPort)); err != nil {
+		dev.Close()
+		return fmt.Errorf("failed to configure WireGuard device: %w", err)
+	}
+	if err := dev.Up(); err != nil {
+		dev.Close()
+		return fmt.Errorf("failed to bring WireGuard device up: %w", err)
+	}
+
+	d.tun, d.filter, d.device, d.name = tunDevice, filter, dev, name
+	return nil
+}
+
+// SetPeers приводит список пиров устройства к peers: удаляет лишних, добавляет новых и
+// обновляет изменившихся одной транзакцией. Сессии неизменных пиров сохраняются.
+func (d *Device) SetPeers(peers []Peer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.device == nil {
+		return ErrNotStarted
+	}
+	state, err := d.device.IpcGet()
+	if err != nil {
+		return opError("read peers", d.name, "", err)
+	}
+	current, err := parsePeerStatus(state)
+	if err != nil {
+		return opError("read peers", d.name, "", err)
+	}
+	changes := DiffPeers(current, peers)
+	if changes.Empty() {
+		return nil
+	}
+
+	var config strings.Builder
+	for _, publicKey := range changes.Remove {
+		key, err := hexKey(publicKey)
+		if err != nil {
+			return opError("remove peer", d.name, publicKey, err)
+		}
+		fmt.Fprintf(&config, "public_key=%s\nremove=true\n", key)
+	}
+	for _, peer := range changes.Upsert {
+		if err := writePeer(&config, peer); err != nil {
+			return opError("configure peer", d.name, peer.PublicKey, err)
+		}
+	}
+	if err := d.ipcSetLocked(config.String()); err != nil {
+		return err
+	}
+	d.logger.Debug("WireGuard peers updated", "interface", d.name,
+		"added_or_changed", len(changes.Upsert), "removed", len(changes.Remove), "unchanged", changes.Keep)
+	d.syncRoutesLocked()
+	return nil
+}
+
+// UpsertPeer добавляет пира или обновляет его endpoint и AllowedIPs
+func (d *Device) UpsertPeer(peer Peer) error {
+	var config strings.Builder
+	if err := writePeer(&config, peer); err != nil {
+		return opError("configure peer", d.Name(), peer.PublicKey, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.ipcSetLocked(config.String()); err != nil {
+		return err
+	}
+	d.syncRoutesLocked()
+	return nil
+}
+
+// RemovePeer удаляет пира по публичному ключу
+func (d *Device) RemovePeer(publicKey string) error {
+	key, err := hexKey(publicKey)
+	if err != nil {
+		return opError("remove peer", d.Name(), publicKey, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.ipcSetLocked(fmt.Sprintf("public_key=%s\nremove=true\n", key)); err != nil {
+		return err
+	}
+	d.syncRoutesLocked()
+	return nil
+}
+
+func (d *Device) ipcSetLocked(config string) error {
+	if d.device == nil {
+		return ErrNotStarted
+	}
+	if err := d.device.IpcSet(config); err != nil {
+		return opError("configure peers", d.name, "", err)
+	}
+	return nil
+}
+
+// writePeer записывает настройки пира в формате протокола настройки (UAPI)
+func writePeer(config *strings.Builder, peer Peer) error {
+	publicKey, err := hexKey(peer.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid peer public key: %w", err)
+	}
+	fmt.Fprintf(config, "public_key=%s\n", publicKey)
+	if peer.PresharedKey != "" {
+		presharedKey, err := hexKey(peer.PresharedKey)
+		if err != nil {
+			return fmt.Errorf("invalid preshared key: %w", err)
+		}
+		fmt.Fprintf(config, "preshared_key=%s\n", presharedKey)
+	}
+	if peer.Endpoint != "" {
+		endpoint, err := resolveEndpoint(peer.Endpoint)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(config, "endpoint=%s\n", endpoint)
+	}
+	fmt.Fprintf(config, "persistent_keepalive_interval=%d\n", int(peer.PersistentKeepalive/time.Second))
+	config.WriteString("replace_allowed_ips=true\n")
+	for _, allowedIP := range peer.AllowedIPs {
+		fmt.Fprintf(config, "allowed_ip=%s\n", allowedIP)
+	}
+	return nil
+}
+
+// syncRoutesLocked направляет в интерфейс AllowedIPs пиров устройства и убирает
+// маршруты, которые больше не принадлежат ни одному пиру. Вызывается под d.mu.
+func (d *Device) syncRoutesLocked() {
+	if !d.link {
+		return
+	}
+	state, err := d.device.IpcGet()
+	if err != nil {
+		d.logger.Warn("Failed to read WireGuard state", "error", err)
+		return
+	}
+	peers, err := parsePeerStatus(state)
+	if err != nil {
+		d.logger.Warn("Failed to parse WireGuard state", "error", err)
+		return
+	}
+	d.routes.sync(d.name, peers, d.logger)
+}
+
+// Peers возвращает состояние пиров устройства
+func (d *Device) Peers() ([]PeerStatus, error) {
+	d.mu.Lock()
+	dev := d.device
+	d.mu.Unlock()
+	if dev == nil {
+		return nil, ErrNotStarted
+	}
+	state, err := dev.IpcGet()
+	if err != nil {
+		return nil, opError("read peers", d.Name(), "", err)
+	}
+	return parsePeerStatus(state)
+}
+
+// LatestHandshake возвращает время последнего handshake с любым пиром (нулевое — не было)
+func (d *Device) LatestHandshake() (time.Time, error) {
+	peers, err := d.Peers()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return latestHandshake(peers), nil
+}
+
+// ListenPort возвращает UDP порт устройства
+func (d *Device) ListenPort() (int, error) {
+	d.mu.Lock()
+	dev := d.device
+	d.mu.Unlock()
+	if dev == nil {
+		return 0, ErrNotStarted
+	}
+	state, err := dev.IpcGet()
+	if err != nil {
+		return 0, opError("read listen port", d.Name(), "", err)
+	}
+	for _, line := range strings.Split(state, "\n") {
+		if value, ok := strings.CutPrefix(line, "listen_port="); ok {
+			return strconv.Atoi(value)
+		}
+	}
+	return 0, fmt.Errorf("WireGuard device has no listen port")
+}
+
+// Name возвращает имя TUN интерфейса
+func (d *Device) Name() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.name != "" {
+		return d.name
+	}
+	return d.config.InterfaceName
+}
+
+// Close останавливает WireGuard и удаляет TUN интерфейс вместе с его маршрутами
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.device == nil {
+		return nil
+	}
+	// Закрытие устройства закрывает и TUN
+	d.device.Close()
+	d.device, d.tun, d.filter, d.net, d.routes, d.link = nil, nil, nil, nil, routeTable{}, false
+	d.logger.Info("Userspace WireGuard stopped", "interface", d.name)
+	return nil
+}
+
+// GetMetrics returns userspace WireGuard metrics
+func (d *Device) GetMetrics() map[string]interface{} {
+	mode := ModeUserspace
+	if d.config.Mode == ModeNetstack {
+		mode = ModeNetstack
+	}
+	metrics := map[string]interface{}{
+		"mode":      mode,
+		"interface": d.Name(),
+	}
+	peers, err := d.Peers()
+	if err != nil {
+		metrics["running"] = false
+		return metrics
+	}
+	var rx, tx int64
+	for _, peer := range peers {
+		rx += peer.RxBytes
+		tx += peer.TxBytes
+	}
+	metrics["running"] = true
+	metrics["peers"] = len(peers)
+	metrics["rx_bytes"] = rx
+	metrics["tx_bytes"] = tx
+	d.mu.Lock()
+	if d.filter != nil {
+		metrics["filtered_packets"] = d.filter.Dropped()
+	}
+	d.mu.Unlock()
+	return metrics
+}
+
+// parsePeerStatus разбирает ответ get протокола настройки (UAPI)
+func parsePeerStatus(state string) ([]PeerStatus, error) {
+	var peers []PeerStatus
+	var handshakeSec, handshakeNsec int64
+	flush := func() {
+		if len(peers) > 0 && handshakeSec > 0 {
+			peers[len(peers)-1].LastHandshake = time.Unix(handshakeSec, handshakeNsec)
+		}
+		handshakeSec, handshakeNsec = 0, 0
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(state))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		if key == "public_key" {
+			flush()
+			raw, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer public key in WireGuard state: %w", err)
+			}
+			peers = append(peers, PeerStatus{PublicKey: base64.StdEncoding.EncodeToString(raw)})
+			continue
+		}
+		if len(peers) == 0 {
+			continue
+		}
+		peer := &peers[len(peers)-1]
+		switch key {
+		case "preshared_key":
+			if raw, err := hex.DecodeString(value); err == nil && !isZeroKey(raw) {
+				peer.PresharedKey = base64.StdEncoding.EncodeToString(raw)
+			}
+		case "endpoint":
+			peer.Endpoint = value
+		case "persistent_keepalive_interval":
+			seconds, _ := strconv.Atoi(value) //nolint:errcheck // malformed value means keepalive is off
+			peer.PersistentKeepalive = time.Duration(seconds) * time.Second
+		case "allowed_ip":
+			peer.AllowedIPs = append(peer.AllowedIPs, value)
+		case "last_handshake_time_sec":
+			handshakeSec, _ = strconv.ParseInt(value, 10, 64) //nolint:errcheck // malformed value means no handshake
+		case "last_handshake_time_nsec":
+			handshakeNsec, _ = strconv.ParseInt(value, 10, 64) //nolint:errcheck // malformed value means no handshake
+		case "rx_bytes":
+			peer.RxBytes, _ = strconv.ParseInt(value, 10, 64) //nolint:errcheck // counters are informational
+		case "tx_bytes":
+			peer.TxBytes, _ = strconv.ParseInt(value, 10, 64) //nolint:errcheck // counters are informational
+		}
+	}
+	flush()
+	return peers, scanner.Err()
+}
+
+// isZeroKey сообщает, что ключ не задан (устройство возвращает нулевой preshared key)
+func isZeroKey(key []byte) bool {
+	for _, b := range key {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,412 @@
+package wireguard
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/2gc-dev/cloudbridge-client/pkg/acl"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/tun/tuntest"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...interface{})  {}
+func (testLogger) Error(string, ...interface{}) {}
+func (testLogger) Debug(string, ...interface{}) {}
+func (testLogger) Warn(string, ...interface{})  {}
+
+func generateKeys(t *testing.T) (private, public string) {
+	t.Helper()
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()), base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+}
+
+func TestParseQuickConfig(t *testing.T) {
+	private, public := generateKeys(t)
+	config, err := ParseQuickConfig(fmt.Sprintf(`
+[Interface]
+PrivateKey = %s
+Address = 10.0.0.2/24, fd00::2
+ListenPort = 51820
+MTU = 1380
+PostUp = iptables -A FORWARD -i %%i -j ACCEPT
+
+[Peer] # relay
+PublicKey = %s
+Endpoint = relay.example.com:51820
+AllowedIPs = 10.0.0.0/24,192.168.10.0/24
+PersistentKeepalive = 25
+`, private, public))
+	if err != nil {
+		t.Fatalf("ParseQuickConfig failed: %v", err)
+	}
+	if config.Interface.ListenPort != 51820 || config.Interface.MTU != 1380 ||
+		len(config.Interface.Addresses) != 2 || config.Interface.Addresses[1] != "fd00::2/128" {
+		t.Errorf("Unexpected interface: %+v", config.Interface)
+	}
+	if len(config.Peers) != 1 || config.Peers[0].PublicKey != public || config.Peers[0].Endpoint != "relay.example.com:51820" ||
+		len(config.Peers[0].AllowedIPs) != 2 || config.Peers[0].PersistentKeepalive != 25*time.Second {
+		t.Errorf("Unexpected peers: %+v", config.Peers)
+	}
+
+	for _, invalid := range []string{
+		"[Interface]\nAddress = 10.0.0.2/24\n",
+		fmt.Sprintf("[Interface]\nPrivateKey = %s\n[Peer]\nAllowedIPs = 10.0.0.0/24\n", private),
+		"[Interface]\nPrivateKey = short\n",
+		"PrivateKey = " + private + "\n",
+	} {
+		if _, err := ParseQuickConfig(invalid); err == nil {
+			t.Errorf("Expected error for config %q", invalid)
+		}
+	}
+}
+
+func TestDevice_TunnelsPackets(t *testing.T) {
+	privateA, publicA := generateKeys(t)
+	privateB, publicB := generateKeys(t)
+	tunA, tunB := tuntest.NewChannelTUN(), tuntest.NewChannelTUN()
+
+	deviceA, deviceB := NewDevice(nil, testLogger{}), NewDevice(nil, testLogger{})
+	if err := deviceA.start(tunA.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateA}); err != nil {
+		t.Fatalf("Failed to start device A: %v", err)
+	}
+	defer deviceA.Close() //nolint:errcheck // test cleanup
+	if err := deviceB.start(tunB.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateB}); err != nil {
+		t.Fatalf("Failed to start device B: %v", err)
+	}
+	defer deviceB.Close() //nolint:errcheck // test cleanup
+
+	portB, err := deviceB.ListenPort()
+	if err != nil {
+		t.Fatalf("ListenPort failed: %v", err)
+	}
+	if err := deviceA.SetPeers([]Peer{{PublicKey: publicB, Endpoint: fmt.Sprintf("127.0.0.1:%d", portB),
+		AllowedIPs: []string{"10.9.0.2/32"}}}); err != nil {
+		t.Fatalf("SetPeers A failed: %v", err)
+	}
+	if err := deviceB.UpsertPeer(Peer{PublicKey: publicA, AllowedIPs: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("UpsertPeer B failed: %v", err)
+	}
+
+	ping := tuntest.Ping(netip.MustParseAddr("10.9.0.2"), netip.MustParseAddr("10.9.0.1"))
+	tunA.Outbound <- ping
+	select {
+	case got := <-tunB.Inbound:
+		if !bytes.Equal(got, ping) {
+			t.Errorf("Tunneled packet differs: %x, want %x", got, ping)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Packet was not tunneled")
+	}
+
+	peers, err := deviceA.Peers()
+	if err != nil {
+		t.Fatalf("Peers failed: %v", err)
+	}
+	if len(peers) != 1 || peers[0].PublicKey != publicB || peers[0].TxBytes == 0 ||
+		peers[0].AllowedIPs[0] != "10.9.0.2/32" || peers[0].LastHandshake.IsZero() {
+		t.Errorf("Unexpected peer status: %+v", peers)
+	}
+	if handshake, err := deviceB.LatestHandshake(); err != nil || handshake.IsZero() {
+		t.Errorf("Expected handshake on device B, got %v (%v)", handshake, err)
+	}
+
+	// Adding a peer must keep the session of the unchanged one
+	_, publicC := generateKeys(t)
+	if err := deviceA.SetPeers([]Peer{
+		{PublicKey: publicB, Endpoint: fmt.Sprintf("127.0.0.1:%d", portB), AllowedIPs: []string{"10.9.0.2/32"}},
+		{PublicKey: publicC, AllowedIPs: []string{"10.9.0.3/32"}},
+	}); err != nil {
+		t.Fatalf("SetPeers with new peer failed: %v", err)
+	}
+	updated, err := deviceA.Peers()
+	if err != nil || len(updated) != 2 {
+		t.Fatalf("Expected 2 peers, got %+v (%v)", updated, err)
+	}
+	for _, peer := range updated {
+		if peer.PublicKey == publicB && (!peer.LastHandshake.Equal(peers[0].LastHandshake) || peer.TxBytes < peers[0].TxBytes) {
+			t.Errorf("Unchanged peer was reset: %+v, was %+v", peer, peers[0])
+		}
+	}
+
+	if err := deviceA.RemovePeer(publicB); err != nil {
+		t.Fatalf("RemovePeer failed: %v", err)
+	}
+	if peers, _ := deviceA.Peers(); len(peers) != 1 || peers[0].PublicKey != publicC {
+		t.Errorf("Expected only peer C after removal, got %+v", peers)
+	}
+	if err := deviceA.SetPeers(nil); err != nil {
+		t.Fatalf("SetPeers(nil) failed: %v", err)
+	}
+	if peers, _ := deviceA.Peers(); len(peers) != 0 {
+		t.Errorf("Expected no peers, got %+v", peers)
+	}
+}
+
+// tcpPacket собирает IPv4 TCP пакет src:srcPort -> dst:port
+func tcpPacket(src, dst netip.Addr, srcPort, port uint16) []byte {
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	packet[8] = 64
+	packet[9] = 6 // TCP
+	copy(packet[12:16], src.AsSlice())
+	copy(packet[16:20], dst.AsSlice())
+	binary.BigEndian.PutUint16(packet[20:22], srcPort)
+	binary.BigEndian.PutUint16(packet[22:24], port)
+	packet[32] = 5 << 4
+	return packet
+}
+
+func TestDevice_InboundFilterDropsDeniedPorts(t *testing.T) {
+	engine, err := acl.ParseAndCompile([]byte(`{"acls": [{"src": ["peer-a"], "dst": ["peer-b"], "ports": ["tcp/5432"]}]}`))
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+	filter := func(packet []byte) error {
+		decision, err := engine.EvaluatePacket(acl.Identity{ID: "peer-a"}, acl.Identity{ID: "peer-b"}, packet)
+		if err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			return acl.ErrDenied
+		}
+		return nil
+	}
+
+	privateA, publicA := generateKeys(t)
+	privateB, publicB := generateKeys(t)
+	tunA, tunB := tuntest.NewChannelTUN(), tuntest.NewChannelTUN()
+	deviceA := NewDevice(nil, testLogger{})
+	deviceB := NewDevice(&Config{InboundFilter: filter}, testLogger{})
+	if err := deviceA.start(tunA.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateA}); err != nil {
+		t.Fatalf("Failed to start device A: %v", err)
+	}
+	defer deviceA.Close() //nolint:errcheck // test cleanup
+	if err := deviceB.start(tunB.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateB}); err != nil {
+		t.Fatalf("Failed to start device B: %v", err)
+	}
+	defer deviceB.Close() //nolint:errcheck // test cleanup
+
+	portB, err := deviceB.ListenPort()
+	if err != nil {
+		t.Fatalf("ListenPort failed: %v", err)
+	}
+	if err := deviceA.SetPeers([]Peer{{PublicKey: publicB, Endpoint: fmt.Sprintf("127.0.0.1:%d", portB),
+		AllowedIPs: []string{"10.9.0.2/32"}}}); err != nil {
+		t.Fatalf("SetPeers A failed: %v", err)
+	}
+	if err := deviceB.UpsertPeer(Peer{PublicKey: publicA, AllowedIPs: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("UpsertPeer B failed: %v", err)
+	}
+
+	src, dst := netip.MustParseAddr("10.9.0.1"), netip.MustParseAddr("10.9.0.2")
+	denied, allowed := tcpPacket(src, dst, 40000, 22), tcpPacket(src, dst, 40000, 5432)
+	tunA.Outbound <- denied
+	tunA.Outbound <- allowed
+
+	// Пакеты одного пира доставляются по порядку: первым приходит разрешенный
+	select {
+	case got := <-tunB.Inbound:
+		if !bytes.Equal(got, allowed) {
+			t.Fatalf("Expected only the tcp/5432 packet to pass, got %x", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Allowed packet was not tunneled")
+	}
+	select {
+	case got := <-tunB.Inbound:
+		t.Errorf("Unexpected packet after the allowed one: %x", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if dropped := deviceB.GetMetrics()["filtered_packets"]; dropped != uint64(1) {
+		t.Errorf("Expected one filtered packet, got %v", dropped)
+	}
+}
+
+func TestDevice_NetstackDialsOverlay(t *testing.T) {
+	privateA, publicA := generateKeys(t)
+	privateB, publicB := generateKeys(t)
+	config := &Config{InterfaceName: "wg-netstack", MTU: 1420, Mode: ModeNetstack}
+
+	deviceA, deviceB := NewTunnel(config, testLogger{}).(*Device), NewDevice(config, testLogger{})
+	if err := deviceA.Start(&Interface{PrivateKey: privateA, Addresses: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("Failed to start device A: %v", err)
+	}
+	defer deviceA.Close() //nolint:errcheck // test cleanup
+	if err := deviceB.Start(&Interface{PrivateKey: privateB, Addresses: []string{"10.9.0.2/32"}}); err != nil {
+		t.Fatalf("Failed to start device B: %v", err)
+	}
+	defer deviceB.Close() //nolint:errcheck // test cleanup
+	if deviceA.Name() != "wg-netstack" || deviceA.GetMetrics()["mode"] != ModeNetstack {
+		t.Errorf("Unexpected netstack device: %s %v", deviceA.Name(), deviceA.GetMetrics())
+	}
+
+	portB, err := deviceB.ListenPort()
+	if err != nil {
+		t.Fatalf("ListenPort failed: %v", err)
+	}
+	if err := deviceA.SetPeers([]Peer{{PublicKey: publicB, Endpoint: fmt.Sprintf("127.0.0.1:%d", portB),
+		AllowedIPs: []string{"10.9.0.2/32"}}}); err != nil {
+		t.Fatalf("SetPeers A failed: %v", err)
+	}
+	if err := deviceB.UpsertPeer(Peer{PublicKey: publicA, AllowedIPs: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("UpsertPeer B failed: %v", err)
+	}
+	if !deviceA.Routes(netip.MustParseAddr("10.9.0.2")) || deviceA.Routes(netip.MustParseAddr("192.0.2.1")) {
+		t.Error("Expected device A to route only the overlay address of B")
+	}
+
+	listener, err := deviceB.net.ListenTCPAddrPort(netip.MustParseAddrPort("10.9.0.2:8080"))
+	if err != nil {
+		t.Fatalf("Failed to listen on netstack: %v", err)
+	}
+	defer listener.Close() //nolint:errcheck // test cleanup
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()         //nolint:errcheck // test cleanup
+		_, _ = io.Copy(conn, conn) //nolint:errcheck // echo until the client closes
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := deviceA.DialContext(ctx, "tcp", "10.9.0.2:8080")
+	if err != nil {
+		t.Fatalf("DialContext over netstack failed: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test cleanup
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	reply := make([]byte, 4)
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline failed: %v", err)
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Errorf("Expected echo over overlay, got %q (%v)", reply, err)
+	}
+
+	tunDevice := NewDevice(nil, testLogger{})
+	if _, err := tunDevice.DialContext(ctx, "tcp", "10.9.0.2:8080"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("Expected ErrNotStarted, got %v", err)
+	}
+}
+
+func TestDevice_InboundFilterPassesReplies(t *testing.T) {
+	// Политика разрешает только A -> B tcp/5432: ответы B проходят по таблице потоков A
+	engine, err := acl.ParseAndCompile([]byte(`{"acls": [{"src": ["peer-a"], "dst": ["peer-b"], "ports": ["tcp/5432"]}]}`))
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+	filterFrom := func(src, dst string) PacketFilter {
+		return func(packet []byte) error {
+			decision, err := engine.EvaluatePacket(acl.Identity{ID: src}, acl.Identity{ID: dst}, packet)
+			if err != nil {
+				return err
+			}
+			if !decision.Allowed {
+				return acl.ErrDenied
+			}
+			return nil
+		}
+	}
+
+	privateA, publicA := generateKeys(t)
+	privateB, publicB := generateKeys(t)
+	tunA, tunB := tuntest.NewChannelTUN(), tuntest.NewChannelTUN()
+	deviceA := NewDevice(&Config{InboundFilter: filterFrom("peer-b", "peer-a")}, testLogger{})
+	deviceB := NewDevice(&Config{InboundFilter: filterFrom("peer-a", "peer-b")}, testLogger{})
+	if err := deviceA.start(tunA.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateA}); err != nil {
+		t.Fatalf("Failed to start device A: %v", err)
+	}
+	defer deviceA.Close() //nolint:errcheck // test cleanup
+	if err := deviceB.start(tunB.TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: privateB}); err != nil {
+		t.Fatalf("Failed to start device B: %v", err)
+	}
+	defer deviceB.Close() //nolint:errcheck // test cleanup
+
+	portB, err := deviceB.ListenPort()
+	if err != nil {
+		t.Fatalf("ListenPort failed: %v", err)
+	}
+	if err := deviceA.SetPeers([]Peer{{PublicKey: publicB, Endpoint: fmt.Sprintf("127.0.0.1:%d", portB),
+		AllowedIPs: []string{"10.9.0.2/32"}}}); err != nil {
+		t.Fatalf("SetPeers A failed: %v", err)
+	}
+	if err := deviceB.UpsertPeer(Peer{PublicKey: publicA, AllowedIPs: []string{"10.9.0.1/32"}}); err != nil {
+		t.Fatalf("UpsertPeer B failed: %v", err)
+	}
+
+	receive := func(tunnel *tuntest.ChannelTUN, want []byte, what string) {
+		t.Helper()
+		select {
+		case got := <-tunnel.Inbound:
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: unexpected packet %x, want %x", what, got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not delivered", what)
+		}
+	}
+
+	a, b := netip.MustParseAddr("10.9.0.1"), netip.MustParseAddr("10.9.0.2")
+	syn := tcpPacket(a, b, 40000, 5432)
+	tunA.Outbound <- syn
+	receive(tunB, syn, "Connection from A to B:5432")
+
+	synAck := tcpPacket(b, a, 5432, 40000)
+	tunB.Outbound <- synAck
+	receive(tunA, synAck, "Reply from B:5432 to A")
+
+	// Новый поток B -> A политикой не разрешен, ответ на другой порт A тоже
+	unsolicited, wrongPort := tcpPacket(b, a, 40001, 22), tcpPacket(b, a, 5432, 40001)
+	tunB.Outbound <- unsolicited
+	tunB.Outbound <- wrongPort
+	tunB.Outbound <- synAck
+	receive(tunA, synAck, "Second reply from B:5432 to A")
+	if dropped := deviceA.GetMetrics()["filtered_packets"]; dropped != uint64(2) {
+		t.Errorf("Expected two filtered packets on A, got %v", dropped)
+	}
+}
+
+func TestParseFlow(t *testing.T) {
+	a, b := netip.MustParseAddr("10.9.0.1"), netip.MustParseAddr("10.9.0.2")
+	f, ok := parseFlow(tcpPacket(a, b, 40000, 5432))
+	if !ok || f != (flow{proto: ipProtoTCP, src: a, dst: b, srcPort: 40000, dstPort: 5432}) {
+		t.Errorf("Unexpected TCP flow %+v (%v)", f, ok)
+	}
+
+	request := tuntest.Ping(b, a)
+	reply := append([]byte(nil), request...)
+	copy(reply[12:16], b.AsSlice())
+	copy(reply[16:20], a.AsSlice())
+	reply[20] = 0 // echo reply
+	out, ok := parseFlow(request)
+	if !ok {
+		t.Fatal("Expected echo request to have a flow")
+	}
+	in, ok := parseFlow(reply)
+	if !ok || in.reverse() != out {
+		t.Errorf("Expected echo reply %+v to match request %+v", in, out)
+	}
+	if _, ok := parseFlow([]byte{0x45, 0}); ok {
+		t.Error("Expected truncated packet to have no flow")
+	}
+}
@@ -0,0 +1,39 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupported WireGuard ядра или настройка интерфейса недоступны на этой платформе
+	ErrUnsupported = errors.New("not supported on this platform")
+	// ErrNotStarted устройство еще не запущено или уже остановлено
+	ErrNotStarted = errors.New("WireGuard device not started")
+	// ErrNoNetstack устройство работает на интерфейсе системы, а не на netstack
+	ErrNoNetstack = errors.New("WireGuard device is not running on netstack")
+)
+
+// Error ошибка операции с интерфейсом WireGuard: какая операция, с каким интерфейсом
+// и объектом (адрес, маршрут, ключ пира) не удалась
+type Error struct {
+	Op        string // операция: link add, addr replace, route replace, configure peers...
+	Interface string
+	Target    string // адрес, маршрут или ключ пира (пусто — весь интерфейс)
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("wireguard %s %s on %s: %v", e.Op, e.Target, e.Interface, e.Err)
+	}
+	return fmt.Sprintf("wireguard %s on %s: %v", e.Op, e.Interface, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func opError(op, name, target string, err error) error {
+	return &Error{Op: op, Interface: name, Target: target, Err: err}
+}
@@ -0,0 +1,190 @@
+package wireguard
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.zx2c4.com/wireguard/tun"
+)
+
+const (
+	// flowTimeout время жизни потока без исходящих пакетов: ответы на него после
+	// этого снова проверяются фильтром
+	flowTimeout = 3 * time.Minute
+	// maxFlows предел таблицы потоков; новые потоки сверх него не учитываются
+	// (ответы на них проверяются фильтром)
+	maxFlows = 65536
+)
+
+// Номера протоколов IP, ответы по которым пропускаются по таблице потоков
+const (
+	ipProtoICMP   = 1
+	ipProtoTCP    = 6
+	ipProtoUDP    = 17
+	ipProtoICMPv6 = 58
+)
+
+// PacketFilter решает, пропустить ли IP пакет, полученный от пира: ошибка — пакет отбрасывается
+type PacketFilter func(packet []byte) error
+
+// flow направление потока: протокол, адреса и порты (для ICMP echo — идентификатор
+// запроса в порту отправителя, ответа — в порту получателя)
+type flow struct {
+	proto            uint8
+	src, dst         netip.Addr
+	srcPort, dstPort uint16
+}
+
+func (f flow) reverse() flow {
+	return flow{proto: f.proto, src: f.dst, dst: f.src, srcPort: f.dstPort, dstPort: f.srcPort}
+}
+
+// filteredTUN TUN устройство, которое отбрасывает расшифрованные пакеты пиров,
+// не прошедшие фильтр, до их записи в интерфейс системы. Потоки, открытые локальной
+// стороной, учитываются при чтении: ответы на них пропускаются без фильтра, иначе
+// политика "пир A -> пир B:порт" отбрасывала бы ответы B на эфемерный порт A.
+type filteredTUN struct {
+	tun.Device
+	filter  PacketFilter
+	logger  Logger
+	dropped atomic.Uint64
+	flows   map[flow]time.Time // исходящий поток -> время последнего пакета
+	mu      sync.Mutex
+}
+
+func newFilteredTUN(device tun.Device, filter PacketFilter, logger Logger) *filteredTUN {
+	return &filteredTUN{Device: device, filter: filter, logger: logger, flows: make(map[flow]time.Time)}
+}
+
+// Read читает пакеты, отправляемые пирам, и запоминает их потоки
+func (t *filteredTUN) Read(bufs [][]byte, sizes []int, offset int) (int, error) {
+	n, err := t.Device.Read(bufs, sizes, offset)
+	if n > 0 {
+		now := time.Now()
+		t.mu.Lock()
+		for i := 0; i < n; i++ {
+			if f, ok := parseFlow(bufs[i][offset : offset+sizes[i]]); ok {
+				t.trackLocked(f, now)
+			}
+		}
+		t.mu.Unlock()
+	}
+	return n, err
+}
+
+// trackLocked запоминает исходящий поток. Вызывается под t.mu.
+func (t *filteredTUN) trackLocked(f flow, now time.Time) {
+	if _, ok := t.flows[f]; !ok && len(t.flows) >= maxFlows {
+		for known, seen := range t.flows {
+			if now.Sub(seen) > flowTimeout {
+				delete(t.flows, known)
+			}
+		}
+		if len(t.flows) >= maxFlows {
+			return
+		}
+	}
+	t.flows[f] = now
+}
+
+// reply сообщает, что пакет пира — ответ на поток, открытый локальной стороной
+func (t *filteredTUN) reply(packet []byte) bool {
+	f, ok := parseFlow(packet)
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen, ok := t.flows[f.reverse()]
+	return ok && time.Since(seen) <= flowTimeout
+}
+
+// Write записывает в интерфейс пакеты, которые пропускает фильтр. Отброшенные пакеты
+// считаются записанными: для WireGuard они доставлены.
+func (t *filteredTUN) Write(bufs [][]byte, offset int) (int, error) {
+	allowed := make([][]byte, 0, len(bufs))
+	for _, buf := range bufs {
+		if !t.reply(buf[offset:]) {
+			if err := t.filter(buf[offset:]); err != nil {
+				t.dropped.Add(1)
+				t.logger.Debug("Dropping inbound WireGuard packet", "error", err)
+				continue
+			}
+		}
+		allowed = append(allowed, buf)
+	}
+	if len(allowed) == 0 {
+		return len(bufs), nil
+	}
+	if _, err := t.Device.Write(allowed, offset); err != nil {
+		return 0, err
+	}
+	return len(bufs), nil
+}
+
+// Dropped возвращает число отброшенных пакетов
+func (t *filteredTUN) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// parseFlow разбирает поток IPv4/IPv6 пакета TCP, UDP или ICMP echo. Фрагменты без
+// заголовка транспорта и прочие пакеты потока не имеют.
+func parseFlow(packet []byte) (flow, bool) {
+	if len(packet) < 1 {
+		return flow{}, false
+	}
+	var (
+		f         flow
+		transport []byte
+	)
+	switch packet[0] >> 4 {
+	case 4:
+		headerLen := int(packet[0]&0x0f) * 4
+		if len(packet) < 20 || headerLen < 20 || len(packet) < headerLen ||
+			binary.BigEndian.Uint16(packet[6:8])&0x1fff != 0 {
+			return flow{}, false
+		}
+		f.proto = packet[9]
+		f.src = netip.AddrFrom4([4]byte(packet[12:16]))
+		f.dst = netip.AddrFrom4([4]byte(packet[16:20]))
+		transport = packet[headerLen:]
+	case 6:
+		if len(packet) < 40 {
+			return flow{}, false
+		}
+		f.proto = packet[6]
+		f.src = netip.AddrFrom16([16]byte(packet[8:24]))
+		f.dst = netip.AddrFrom16([16]byte(packet[24:40]))
+		transport = packet[40:]
+	default:
+		return flow{}, false
+	}
+
+	switch f.proto {
+	case ipProtoTCP, ipProtoUDP:
+		if len(transport) < 4 {
+			return flow{}, false
+		}
+		f.srcPort = binary.BigEndian.Uint16(transport[0:2])
+		f.dstPort = binary.BigEndian.Uint16(transport[2:4])
+	case ipProtoICMP, ipProtoICMPv6:
+		if len(transport) < 6 {
+			return flow{}, false
+		}
+		id := binary.BigEndian.Uint16(transport[4:6])
+		switch transport[0] {
+		case 8, 128: // echo request
+			f.srcPort = id
+		case 0, 129: // echo reply
+			f.dstPort = id
+		default:
+			return flow{}, false
+		}
+	default:
+		return flow{}, false
+	}
+	return f, true
+}
@@ -0,0 +1,334 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// KernelDevice интерфейс WireGuard ядра: интерфейс, адреса и маршруты настраиваются
+// через netlink, ключи и пиры — через wgctrl, без утилит ip/wg/wg-quick
+type KernelDevice struct {
+	config  *Config
+	logger  Logger
+	client  *wgctrl.Client
+	name    string
+	created bool // интерфейс создан клиентом и удаляется при Close
+	routes  routeTable
+	mu      sync.Mutex
+}
+
+// NewKernelDevice creates a new kernel WireGuard device
+func NewKernelDevice(config *Config, logger Logger) *KernelDevice {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.MTU == 0 {
+		config.MTU = DefaultConfig().MTU
+	}
+	return &KernelDevice{config: config, logger: logger, name: config.InterfaceName}
+}
+
+// Start создает интерфейс WireGuard (существующий используется повторно), задает ключ
+// и порт, назначает адреса iface и поднимает интерфейс
+func (d *KernelDevice) Start(iface *Interface) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client != nil {
+		return fmt.Errorf("WireGuard device already started")
+	}
+
+	privateKey, err := wgtypes.ParseKey(iface.PrivateKey)
+	if err != nil {
+		return opError("set private key", d.name, "", err)
+	}
+	mtu := d.config.MTU
+	if iface.MTU > 0 {
+		mtu = iface.MTU
+	}
+
+	created, err := ensureLink(d.name, mtu)
+	if err != nil {
+		return err
+	}
+	cleanup := func() {
+		if created {
+			if link, err := netlink.LinkByName(d.name); err == nil {
+				_ = netlink.LinkDel(link) //nolint:errcheck // cleanup after failed setup
+			}
+		}
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		cleanup()
+		return opError("open wgctrl", d.name, "", err)
+	}
+	config := wgtypes.Config{PrivateKey: &privateKey}
+	if iface.ListenPort > 0 {
+		config.ListenPort = &iface.ListenPort
+	}
+	if err := client.ConfigureDevice(d.name, config); err != nil {
+		_ = client.Close() //nolint:errcheck // cleanup after failed setup
+		cleanup()
+		return opError("configure device", d.name, "", err)
+	}
+	if err := configureLink(d.name, iface.Addresses, mtu); err != nil {
+		_ = client.Close() //nolint:errcheck // cleanup after failed setup
+		cleanup()
+		return err
+	}
+
+	d.client, d.created = client, created
+	d.logger.Info("Kernel WireGuard started", "interface", d.name, "addresses", iface.Addresses, "mtu", mtu, "created", created)
+	return nil
+}
+
+// ensureLink создает интерфейс WireGuard name, если его нет. Возвращает true, если
+// интерфейс создан.
+func ensureLink(name string, mtu int) (bool, error) {
+	link, err := netlink.LinkByName(name)
+	if err == nil {
+		if link.Type() != "wireguard" {
+			return false, opError("link add", name, "", fmt.Errorf("interface exists with type %s", link.Type()))
+		}
+		return false, nil
+	}
+	var notFound netlink.LinkNotFoundError
+	if !errors.As(err, &notFound) {
+		return false, opError("link lookup", name, "", err)
+	}
+	if err := netlink.LinkAdd(&netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: name, MTU: mtu}}); err != nil {
+		return false, opError("link add", name, "", err)
+	}
+	return true, nil
+}
+
+// SetPeers приводит список пиров интерфейса к peers: удаляет лишних, добавляет новых и
+// обновляет изменившихся одним сообщением netlink. Сессии неизменных пиров сохраняются.
+func (d *KernelDevice) SetPeers(peers []Peer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current, err := d.peersLocked()
+	if err != nil {
+		return err
+	}
+	changes := DiffPeers(current, peers)
+	if changes.Empty() {
+		return nil
+	}
+
+	configs := make([]wgtypes.PeerConfig, 0, len(changes.Remove)+len(changes.Upsert))
+	for _, publicKey := range changes.Remove {
+		key, err := wgtypes.ParseKey(publicKey)
+		if err != nil {
+			return opError("remove peer", d.name, publicKey, err)
+		}
+		configs = append(configs, wgtypes.PeerConfig{PublicKey: key, Remove: true})
+	}
+	for _, peer := range changes.Upsert {
+		config, err := peerConfig(peer)
+		if err != nil {
+			return opError("configure peer", d.name, peer.PublicKey, err)
+		}
+		configs = append(configs, config)
+	}
+	if err := d.client.ConfigureDevice(d.name, wgtypes.Config{Peers: configs}); err != nil {
+		return opError("configure peers", d.name, "", err)
+	}
+	d.logger.Debug("WireGuard peers updated", "interface", d.name,
+		"added_or_changed", len(changes.Upsert), "removed", len(changes.Remove), "unchanged", changes.Keep)
+	d.syncRoutesLocked()
+	return nil
+}
+
+// UpsertPeer добавляет пира или обновляет его endpoint и AllowedIPs
+func (d *KernelDevice) UpsertPeer(peer Peer) error {
+	config, err := peerConfig(peer)
+	if err != nil {
+		return opError("configure peer", d.Name(), peer.PublicKey, err)
+	}
+	return d.configurePeer(config)
+}
+
+// RemovePeer удаляет пира по публичному ключу
+func (d *KernelDevice) RemovePeer(publicKey string) error {
+	key, err := wgtypes.ParseKey(publicKey)
+	if err != nil {
+		return opError("remove peer", d.Name(), publicKey, err)
+	}
+	return d.configurePeer(wgtypes.PeerConfig{PublicKey: key, Remove: true})
+}
+
+func (d *KernelDevice) configurePeer(config wgtypes.PeerConfig) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == nil {
+		return ErrNotStarted
+	}
+	if err := d.client.ConfigureDevice(d.name, wgtypes.Config{Peers: []wgtypes.PeerConfig{config}}); err != nil {
+		return opError("configure peer", d.name, config.PublicKey.String(), err)
+	}
+	d.syncRoutesLocked()
+	return nil
+}
+
+// peerConfig переводит настройки пира в формат wgctrl
+func peerConfig(peer Peer) (wgtypes.PeerConfig, error) {
+	publicKey, err := wgtypes.ParseKey(peer.PublicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("invalid peer public key: %w", err)
+	}
+	keepalive := peer.PersistentKeepalive
+	config := wgtypes.PeerConfig{
+		PublicKey:                   publicKey,
+		PersistentKeepaliveInterval: &keepalive,
+		ReplaceAllowedIPs:           true,
+	}
+	if peer.PresharedKey != "" {
+		presharedKey, err := wgtypes.ParseKey(peer.PresharedKey)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid preshared key: %w", err)
+		}
+		config.PresharedKey = &presharedKey
+	}
+	if peer.Endpoint != "" {
+		endpoint, err := resolveEndpoint(peer.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, err
+		}
+		config.Endpoint = net.UDPAddrFromAddrPort(endpoint)
+	}
+	for _, allowedIP := range peer.AllowedIPs {
+		_, network, err := net.ParseCIDR(allowedIP)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid allowed IP %q: %w", allowedIP, err)
+		}
+		config.AllowedIPs = append(config.AllowedIPs, *network)
+	}
+	return config, nil
+}
+
+// peersLocked читает пиров интерфейса. Вызывается под d.mu.
+func (d *KernelDevice) peersLocked() ([]PeerStatus, error) {
+	if d.client == nil {
+		return nil, ErrNotStarted
+	}
+	device, err := d.client.Device(d.name)
+	if err != nil {
+		return nil, opError("read peers", d.name, "", err)
+	}
+	peers := make([]PeerStatus, 0, len(device.Peers))
+	for _, peer := range device.Peers {
+		status := PeerStatus{
+			PublicKey:           peer.PublicKey.String(),
+			PersistentKeepalive: peer.PersistentKeepaliveInterval,
+			LastHandshake:       peer.LastHandshakeTime,
+			RxBytes:             peer.ReceiveBytes,
+			TxBytes:             peer.TransmitBytes,
+		}
+		if peer.PresharedKey != (wgtypes.Key{}) {
+			status.PresharedKey = peer.PresharedKey.String()
+		}
+		if peer.Endpoint != nil {
+			status.Endpoint = peer.Endpoint.AddrPort().String()
+		}
+		for _, allowedIP := range peer.AllowedIPs {
+			status.AllowedIPs = append(status.AllowedIPs, allowedIP.String())
+		}
+		if status.LastHandshake.Unix() <= 0 {
+			status.LastHandshake = time.Time{}
+		}
+		peers = append(peers, status)
+	}
+	return peers, nil
+}
+
+// syncRoutesLocked направляет в интерфейс AllowedIPs пиров. Вызывается под d.mu.
+func (d *KernelDevice) syncRoutesLocked() {
+	peers, err := d.peersLocked()
+	if err != nil {
+		d.logger.Warn("Failed to read WireGuard state", "error", err)
+		return
+	}
+	d.routes.sync(d.name, peers, d.logger)
+}
+
+// Peers возвращает состояние пиров интерфейса
+func (d *KernelDevice) Peers() ([]PeerStatus, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.peersLocked()
+}
+
+// LatestHandshake возвращает время последнего handshake с любым пиром (нулевое — не было)
+func (d *KernelDevice) LatestHandshake() (time.Time, error) {
+	peers, err := d.Peers()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return latestHandshake(peers), nil
+}
+
+// Name возвращает имя интерфейса
+func (d *KernelDevice) Name() string {
+	return d.name
+}
+
+// Close удаляет созданный клиентом интерфейс. Интерфейс, созданный заранее, остается,
+// с него убираются только добавленные клиентом маршруты.
+func (d *KernelDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == nil {
+		return nil
+	}
+	var closeErr error
+	if d.created {
+		link, err := netlink.LinkByName(d.name)
+		if err == nil {
+			err = netlink.LinkDel(link)
+		}
+		if err != nil {
+			closeErr = opError("link del", d.name, "", err)
+		}
+		d.routes = routeTable{}
+	} else {
+		d.routes.clear(d.name, d.logger)
+	}
+	_ = d.client.Close() //nolint:errcheck // netlink socket only
+	d.client, d.created = nil, false
+	d.logger.Info("Kernel WireGuard stopped", "interface", d.name)
+	return closeErr
+}
+
+// GetMetrics returns kernel WireGuard metrics
+func (d *KernelDevice) GetMetrics() map[string]interface{} {
+	metrics := map[string]interface{}{
+		"mode":      ModeKernel,
+		"interface": d.name,
+	}
+	peers, err := d.Peers()
+	if err != nil {
+		metrics["running"] = false
+		return metrics
+	}
+	var rx, tx int64
+	for _, peer := range peers {
+		rx += peer.RxBytes
+		tx += peer.TxBytes
+	}
+	metrics["running"] = true
+	metrics["peers"] = len(peers)
+	metrics["rx_bytes"] = rx
+	metrics["tx_bytes"] = tx
+	return metrics
+}
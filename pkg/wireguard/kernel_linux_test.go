@@ -0,0 +1,56 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestKernelDevice_AppliesPeerDiffs(t *testing.T) {
+	inNetworkNamespace(t)
+
+	private, _ := generateKeys(t)
+	_, publicB := generateKeys(t)
+	_, publicC := generateKeys(t)
+
+	device := NewKernelDevice(&Config{InterfaceName: "wgtest1", MTU: 1420}, testLogger{})
+	if err := device.Start(&Interface{PrivateKey: private, ListenPort: 51899, Addresses: []string{"10.98.0.1/24"}}); err != nil {
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			t.Skip("Kernel WireGuard is not available")
+		}
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer device.Close() //nolint:errcheck // test cleanup
+
+	peerB := Peer{PublicKey: publicB, Endpoint: "192.0.2.2:51820", AllowedIPs: []string{"10.98.0.2/32"}}
+	if err := device.SetPeers([]Peer{peerB, {PublicKey: publicC, AllowedIPs: []string{"10.98.1.0/24"}}}); err != nil {
+		t.Fatalf("SetPeers failed: %v", err)
+	}
+	peers, err := device.Peers()
+	if err != nil || len(peers) != 2 {
+		t.Fatalf("Expected 2 peers, got %+v (%v)", peers, err)
+	}
+	if _, err := netlink.RouteGet(net.IPv4(10, 98, 1, 7)); err != nil {
+		t.Errorf("Route for peer AllowedIPs missing: %v", err)
+	}
+
+	if err := device.SetPeers([]Peer{peerB}); err != nil {
+		t.Fatalf("SetPeers failed: %v", err)
+	}
+	peers, err = device.Peers()
+	if err != nil || len(peers) != 1 || peers[0].PublicKey != publicB || peers[0].Endpoint != "192.0.2.2:51820" {
+		t.Errorf("Unexpected peers after diff: %+v (%v)", peers, err)
+	}
+
+	if err := device.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := netlink.LinkByName("wgtest1"); err == nil {
+		t.Error("Interface should be removed by Close")
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !linux
+
+package wireguard
+
+import "time"
+
+// KernelDevice интерфейс WireGuard ядра. Вне Linux не поддерживается: используйте
+// встроенный WireGuard (Device).
+type KernelDevice struct {
+	name string
+}
+
+// NewKernelDevice creates a new kernel WireGuard device
+func NewKernelDevice(config *Config, logger Logger) *KernelDevice {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &KernelDevice{name: config.InterfaceName}
+}
+
+// Start всегда возвращает ErrUnsupported
+func (d *KernelDevice) Start(iface *Interface) error {
+	return opError("link add", d.name, "", ErrUnsupported)
+}
+
+func (d *KernelDevice) SetPeers(peers []Peer) error       { return ErrNotStarted }
+func (d *KernelDevice) UpsertPeer(peer Peer) error        { return ErrNotStarted }
+func (d *KernelDevice) RemovePeer(publicKey string) error { return ErrNotStarted }
+func (d *KernelDevice) Peers() ([]PeerStatus, error)      { return nil, ErrNotStarted }
+func (d *KernelDevice) Name() string                      { return d.name }
+func (d *KernelDevice) Close() error                      { return nil }
+
+func (d *KernelDevice) LatestHandshake() (time.Time, error) {
+	return time.Time{}, ErrNotStarted
+}
+
+// GetMetrics returns kernel WireGuard metrics
+func (d *KernelDevice) GetMetrics() map[string]interface{} {
+	return map[string]interface{}{"mode": ModeKernel, "interface": d.name, "running": false}
+}
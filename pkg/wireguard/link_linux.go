@@ -0,0 +1,70 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// configureLink назначает адреса интерфейсу, задает MTU и поднимает его
+func configureLink(name string, addresses []string, mtu int) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return opError("link lookup", name, "", err)
+	}
+	for _, address := range addresses {
+		addr, err := netlink.ParseAddr(address)
+		if err != nil {
+			return opError("addr replace", name, address, err)
+		}
+		if err := netlink.AddrReplace(link, addr); err != nil {
+			return opError("addr replace", name, address, err)
+		}
+	}
+	if err := netlink.LinkSetMTU(link, mtu); err != nil {
+		return opError("link set mtu", name, "", err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return opError("link set up", name, "", err)
+	}
+	return nil
+}
+
+// addRoute направляет prefix в интерфейс
+func addRoute(name, prefix string) error {
+	route, err := linkRoute(name, prefix)
+	if err != nil {
+		return opError("route replace", name, prefix, err)
+	}
+	if err := netlink.RouteReplace(route); err != nil {
+		return opError("route replace", name, prefix, err)
+	}
+	return nil
+}
+
+// removeRoute удаляет маршрут prefix через интерфейс
+func removeRoute(name, prefix string) error {
+	route, err := linkRoute(name, prefix)
+	if err != nil {
+		return opError("route del", name, prefix, err)
+	}
+	if err := netlink.RouteDel(route); err != nil {
+		return opError("route del", name, prefix, err)
+	}
+	return nil
+}
+
+// linkRoute описывает маршрут prefix через интерфейс name
+func linkRoute(name, prefix string) (*netlink.Route, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	_, dst, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &netlink.Route{LinkIndex: link.Attrs().Index, Dst: dst, Scope: netlink.SCOPE_LINK}, nil
+}
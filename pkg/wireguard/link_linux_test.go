@@ -0,0 +1,105 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.zx2c4.com/wireguard/tun"
+)
+
+// inNetworkNamespace runs the test on a locked thread in a fresh network namespace
+func inNetworkNamespace(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("Test requires root privileges for network namespaces")
+	}
+	runtime.LockOSThread()
+	origin, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Skipf("Network namespaces not available: %v", err)
+	}
+	ns, err := netns.New()
+	if err != nil {
+		_ = origin.Close() //nolint:errcheck // test cleanup
+		runtime.UnlockOSThread()
+		t.Skipf("Network namespaces not available: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = netns.Set(origin) //nolint:errcheck // test cleanup
+		_ = ns.Close()        //nolint:errcheck // test cleanup
+		_ = origin.Close()    //nolint:errcheck // test cleanup
+		runtime.UnlockOSThread()
+	})
+}
+
+func TestLink_ConfiguresAddressesAndRoutes(t *testing.T) {
+	inNetworkNamespace(t)
+
+	tunDevice, err := tun.CreateTUN("wgtest0", 1420)
+	if err != nil {
+		t.Skipf("TUN not available: %v", err)
+	}
+	defer tunDevice.Close() //nolint:errcheck // test cleanup
+
+	if err := configureLink("wgtest0", []string{"10.99.0.2/24", "fd99::2/64"}, 1380); err != nil {
+		t.Fatalf("configureLink failed: %v", err)
+	}
+	link, err := netlink.LinkByName("wgtest0")
+	if err != nil {
+		t.Fatalf("LinkByName failed: %v", err)
+	}
+	if link.Attrs().MTU != 1380 || link.Attrs().Flags&net.FlagUp == 0 {
+		t.Errorf("Unexpected link attributes: mtu %d, flags %v", link.Attrs().MTU, link.Attrs().Flags)
+	}
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil || len(addrs) != 1 || addrs[0].IPNet.String() != "10.99.0.2/24" {
+		t.Errorf("Unexpected addresses: %v (%v)", addrs, err)
+	}
+
+	// Reapplying the same configuration is idempotent
+	if err := configureLink("wgtest0", []string{"10.99.0.2/24"}, 1380); err != nil {
+		t.Errorf("Repeated configureLink failed: %v", err)
+	}
+
+	hasRoute := func(prefix string) bool {
+		routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+		if err != nil {
+			t.Fatalf("RouteList failed: %v", err)
+		}
+		for _, route := range routes {
+			if route.Dst != nil && route.Dst.String() == prefix {
+				return true
+			}
+		}
+		return false
+	}
+	if err := addRoute("wgtest0", "192.168.77.0/24"); err != nil {
+		t.Fatalf("addRoute failed: %v", err)
+	}
+	if !hasRoute("192.168.77.0/24") {
+		t.Error("Route was not added")
+	}
+	if err := removeRoute("wgtest0", "192.168.77.0/24"); err != nil {
+		t.Fatalf("removeRoute failed: %v", err)
+	}
+	if hasRoute("192.168.77.0/24") {
+		t.Error("Route was not removed")
+	}
+
+	err = removeRoute("wgtest0", "192.168.77.0/24")
+	var opErr *Error
+	if !errors.As(err, &opErr) || opErr.Op != "route del" || opErr.Interface != "wgtest0" || opErr.Target != "192.168.77.0/24" {
+		t.Errorf("Expected structured route error, got %v", err)
+	}
+	if err := configureLink("missing0", nil, 1420); !errors.As(err, &opErr) || opErr.Op != "link lookup" {
+		t.Errorf("Expected structured link error, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package wireguard
+
+// configureLink не поддерживается вне Linux: адреса и маршруты интерфейса настраиваются
+// средствами системы
+func configureLink(name string, addresses []string, mtu int) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return opError("addr replace", name, addresses[0], ErrUnsupported)
+}
+
+func addRoute(name, prefix string) error {
+	return opError("route replace", name, prefix, ErrUnsupported)
+}
+
+func removeRoute(name, prefix string) error {
+	return opError("route del", name, prefix, ErrUnsupported)
+}
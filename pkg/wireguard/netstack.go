@@ -0,0 +1,75 @@
+package wireguard
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+// startNetstack запускает WireGuard на TCP/IP стеке gVisor в процессе клиента: интерфейс
+// системы не создается и права не нужны, overlay сеть доступна только через DialContext
+func (d *Device) startNetstack(iface *Interface, mtu int) error {
+	addresses := make([]netip.Addr, 0, len(iface.Addresses))
+	for _, address := range iface.Addresses {
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return opError("addr replace", d.config.InterfaceName, address, err)
+		}
+		addresses = append(addresses, prefix.Addr())
+	}
+	var dns []netip.Addr
+	for _, server := range iface.DNS {
+		if addr, err := netip.ParseAddr(server); err == nil {
+			dns = append(dns, addr)
+		}
+	}
+
+	tunDevice, tnet, err := netstack.CreateNetTUN(addresses, dns, mtu)
+	if err != nil {
+		return opError("create netstack", d.config.InterfaceName, "", err)
+	}
+	if err := d.start(tunDevice, conn.NewDefaultBind(), iface); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.net = tnet
+	d.mu.Unlock()
+	d.logger.Info("Userspace WireGuard started on netstack", "interface", d.config.InterfaceName,
+		"addresses", iface.Addresses, "mtu", mtu)
+	return nil
+}
+
+// DialContext соединяется с address через стек netstack устройства. Имена разрешаются
+// DNS серверами из секции [Interface] через overlay сеть.
+func (d *Device) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	started, tnet := d.device != nil, d.net
+	d.mu.Unlock()
+	if !started {
+		return nil, ErrNotStarted
+	}
+	if tnet == nil {
+		return nil, ErrNoNetstack
+	}
+	return tnet.DialContext(ctx, network, address)
+}
+
+// Routes сообщает, что addr входит в AllowedIPs одного из пиров устройства
+func (d *Device) Routes(addr netip.Addr) bool {
+	peers, err := d.Peers()
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, peer := range peers {
+		for _, allowedIP := range peer.AllowedIPs {
+			if prefix, err := netip.ParsePrefix(allowedIP); err == nil && prefix.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package wireguard
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// PeerChanges изменения, которые переводят пиров устройства в желаемое состояние
+type PeerChanges struct {
+	Upsert []Peer   // новые пиры и пиры с изменившимися настройками
+	Remove []string // публичные ключи пиров, которых больше нет
+	Keep   int      // пиры без изменений: их сессии не трогаются
+}
+
+// Empty сообщает, что устройство уже в желаемом состоянии
+func (c PeerChanges) Empty() bool {
+	return len(c.Upsert) == 0 && len(c.Remove) == 0
+}
+
+// DiffPeers сравнивает пиров устройства с желаемыми. Пустой Endpoint желаемого пира
+// не считается изменением: такой пир подключается сам, и устройство помнит его адрес.
+func DiffPeers(current []PeerStatus, desired []Peer) PeerChanges {
+	var changes PeerChanges
+	existing := make(map[string]PeerStatus, len(current))
+	for _, status := range current {
+		existing[status.PublicKey] = status
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, peer := range desired {
+		wanted[peer.PublicKey] = true
+		status, ok := existing[peer.PublicKey]
+		if ok && !peerChanged(status, peer) {
+			changes.Keep++
+			continue
+		}
+		changes.Upsert = append(changes.Upsert, peer)
+	}
+	for _, status := range current {
+		if !wanted[status.PublicKey] {
+			changes.Remove = append(changes.Remove, status.PublicKey)
+		}
+	}
+	return changes
+}
+
+// peerChanged сообщает, отличаются ли настройки пира устройства от желаемых
+func peerChanged(status PeerStatus, peer Peer) bool {
+	if status.PresharedKey != peer.PresharedKey || status.PersistentKeepalive != peer.PersistentKeepalive {
+		return true
+	}
+	if peer.Endpoint != "" && !sameEndpoint(status.Endpoint, peer.Endpoint) {
+		return true
+	}
+	return !slices.Equal(normalizePrefixes(status.AllowedIPs), normalizePrefixes(peer.AllowedIPs))
+}
+
+// sameEndpoint сравнивает адреса endpoint; имя хоста всегда считается изменением,
+// так как могло разрешиться в другой адрес
+func sameEndpoint(current, desired string) bool {
+	currentAddr, err := netip.ParseAddrPort(current)
+	if err != nil {
+		return false
+	}
+	desiredAddr, err := netip.ParseAddrPort(desired)
+	if err != nil {
+		return false
+	}
+	return currentAddr.Addr().Unmap() == desiredAddr.Addr().Unmap() && currentAddr.Port() == desiredAddr.Port()
+}
+
+// normalizePrefixes приводит подсети к адресу сети и сортирует их, как их хранит устройство
+func normalizePrefixes(prefixes []string) []string {
+	normalized := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if parsed, err := netip.ParsePrefix(prefix); err == nil {
+			prefix = parsed.Masked().String()
+		}
+		if !slices.Contains(normalized, prefix) {
+			normalized = append(normalized, prefix)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized
+}
+
+// resolveEndpoint разрешает endpoint пира в адрес: устройству нужен IP, имя хоста
+// разрешается здесь, как в wg-quick
+func resolveEndpoint(endpoint string) (netip.AddrPort, error) {
+	addr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("failed to resolve peer endpoint %s: %w", endpoint, err)
+	}
+	addrPort := addr.AddrPort()
+	return netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port()), nil
+}
+
+// latestHandshake возвращает время последнего handshake с любым пиром (нулевое — не было)
+func latestHandshake(peers []PeerStatus) time.Time {
+	var latest time.Time
+	for _, peer := range peers {
+		if peer.LastHandshake.After(latest) {
+			latest = peer.LastHandshake
+		}
+	}
+	return latest
+}
+
+// routeTable маршруты AllowedIPs, которые клиент добавил на интерфейс
+type routeTable struct {
+	routes []string
+}
+
+// sync направляет в интерфейс name AllowedIPs пиров и убирает маршруты, которые
+// больше не принадлежат ни одному пиру
+func (r *routeTable) sync(name string, peers []PeerStatus, logger Logger) {
+	var wanted []string
+	for _, peer := range peers {
+		wanted = append(wanted, normalizePrefixes(peer.AllowedIPs)...)
+	}
+
+	r.routes = slices.DeleteFunc(r.routes, func(route string) bool {
+		if slices.Contains(wanted, route) {
+			return false
+		}
+		if err := removeRoute(name, route); err != nil {
+			logger.Debug("Failed to remove WireGuard route", "route", route, "error", err)
+		}
+		return true
+	})
+	for _, prefix := range wanted {
+		if slices.Contains(r.routes, prefix) {
+			continue
+		}
+		// Маршрут по умолчанию через туннель требует policy routing (как в wg-quick): не трогаем
+		if parsed, err := netip.ParsePrefix(prefix); err == nil && parsed.Bits() == 0 {
+			continue
+		}
+		if err := addRoute(name, prefix); err != nil {
+			logger.Warn("Failed to add WireGuard route", "route", prefix, "interface", name, "error", err)
+			continue
+		}
+		r.routes = append(r.routes, prefix)
+	}
+}
+
+// clear удаляет добавленные маршруты с интерфейса name
+func (r *routeTable) clear(name string, logger Logger) {
+	r.sync(name, nil, logger)
+}
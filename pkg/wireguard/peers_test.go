@@ -0,0 +1,51 @@
+package wireguard
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiffPeers(t *testing.T) {
+	_, keyA := generateKeys(t)
+	_, keyB := generateKeys(t)
+	_, keyC := generateKeys(t)
+	_, keyD := generateKeys(t)
+
+	current := []PeerStatus{
+		{PublicKey: keyA, Endpoint: "192.0.2.1:51820", AllowedIPs: []string{"10.0.0.1/32"}, PersistentKeepalive: 25 * time.Second},
+		{PublicKey: keyB, Endpoint: "192.0.2.2:51820", AllowedIPs: []string{"10.0.0.2/32"}},
+		{PublicKey: keyC, Endpoint: "192.0.2.3:51820", AllowedIPs: []string{"10.0.0.3/32", "192.168.1.0/24"}},
+	}
+	desired := []Peer{
+		// Same settings written differently: unchanged
+		{PublicKey: keyA, Endpoint: "192.0.2.1:51820", AllowedIPs: []string{"10.0.0.1/32", "10.0.0.1/32"}, PersistentKeepalive: 25 * time.Second},
+		// No endpoint: the device keeps the roaming address, unchanged
+		{PublicKey: keyC, AllowedIPs: []string{"192.168.1.7/24", "10.0.0.3/32"}},
+		// New peer
+		{PublicKey: keyD, Endpoint: "192.0.2.4:51820", AllowedIPs: []string{"10.0.0.4/32"}},
+	}
+
+	changes := DiffPeers(current, desired)
+	if changes.Keep != 2 || len(changes.Upsert) != 1 || changes.Upsert[0].PublicKey != keyD {
+		t.Errorf("Unexpected upserts: %+v", changes)
+	}
+	if !slices.Equal(changes.Remove, []string{keyB}) {
+		t.Errorf("Expected removal of %s, got %v", keyB, changes.Remove)
+	}
+
+	for name, peer := range map[string]Peer{
+		"endpoint":   {PublicKey: keyA, Endpoint: "192.0.2.9:51820", AllowedIPs: []string{"10.0.0.1/32"}, PersistentKeepalive: 25 * time.Second},
+		"allowed IP": {PublicKey: keyA, AllowedIPs: []string{"10.0.0.1/32", "10.1.0.0/16"}, PersistentKeepalive: 25 * time.Second},
+		"keepalive":  {PublicKey: keyA, AllowedIPs: []string{"10.0.0.1/32"}},
+		"hostname":   {PublicKey: keyA, Endpoint: "relay.example.com:51820", AllowedIPs: []string{"10.0.0.1/32"}, PersistentKeepalive: 25 * time.Second},
+	} {
+		if changes := DiffPeers(current[:1], []Peer{peer}); len(changes.Upsert) != 1 {
+			t.Errorf("Changed %s was not detected: %+v", name, changes)
+		}
+	}
+
+	if changes := DiffPeers(current, nil); len(changes.Remove) != 3 || !DiffPeers(nil, nil).Empty() {
+		t.Errorf("Unexpected changes for empty peer list: %+v", changes)
+	}
+}
@@ -0,0 +1,195 @@
+package wireguard
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// handshakeFresh срок, в течение которого endpoint с последним handshake считается
+// рабочим: WireGuard повторяет handshake не реже раза в 2 минуты при трафике
+const handshakeFresh = 3 * time.Minute
+
+// DiscoveredPeer пир WireGuard, найденный discovery mesh сети
+type DiscoveredPeer struct {
+	ID         string   // peer ID в mesh
+	PublicKey  string   // ключ WireGuard (base64)
+	Endpoint   string   // адрес WireGuard пира из discovery (host:port, пусто — неизвестен)
+	AllowedIPs []string // подсети, которые клиент направляет пиру
+}
+
+// Reconciler поддерживает список пиров устройства WireGuard по данным discovery:
+// добавляет появившихся пиров, удаляет ушедших и переключает endpoint пира на прямой
+// путь, найденный ICE. Изменения применяются разницей (Tunnel.SetPeers), так что
+// сессии остальных пиров не прерываются.
+type Reconciler struct {
+	tunnel     Tunnel
+	logger     Logger
+	keepalive  time.Duration
+	static     []Peer            // пиры из конфигурации relay API (не удаляются)
+	discovered []DiscoveredPeer  // пиры из discovery
+	direct     map[string]string // peer ID -> адрес пира на выбранной ICE паре
+	reconciles int
+	lastError  string
+	mu         sync.Mutex
+}
+
+// NewReconciler creates a new WireGuard peer reconciler. keepalive задает
+// PersistentKeepalive обнаруженных пиров (держит открытыми NAT отображения).
+func NewReconciler(tunnel Tunnel, keepalive time.Duration, logger Logger) *Reconciler {
+	return &Reconciler{
+		tunnel:    tunnel,
+		logger:    logger,
+		keepalive: keepalive,
+		direct:    make(map[string]string),
+	}
+}
+
+// SetStaticPeers задает пиров из конфигурации WireGuard (relay сервер)
+func (r *Reconciler) SetStaticPeers(peers []Peer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.static = slices.Clone(peers)
+}
+
+// SetDiscoveredPeers задает текущий список обнаруженных пиров. Пиры, которых в нем
+// нет, удаляются с устройства при следующем Reconcile. Имена хостов в endpoint
+// разрешаются здесь, до блокировки: Reconcile не ждет DNS.
+func (r *Reconciler) SetDiscoveredPeers(peers []DiscoveredPeer) {
+	peers = slices.Clone(peers)
+	slices.SortFunc(peers, func(a, b DiscoveredPeer) int { return strings.Compare(a.ID, b.ID) })
+	for i := range peers {
+		if peers[i].Endpoint == "" {
+			continue
+		}
+		// Неразрешимый адрес одного пира не должен мешать настройке остальных
+		if endpoint, err := resolveEndpoint(peers[i].Endpoint); err == nil {
+			peers[i].Endpoint = endpoint.String()
+		} else {
+			r.logger.Debug("Ignoring invalid peer endpoint", "peer_id", peers[i].ID, "error", err)
+			peers[i].Endpoint = ""
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discovered = peers
+	for id := range r.direct {
+		if !slices.ContainsFunc(peers, func(peer DiscoveredPeer) bool { return peer.ID == id }) {
+			delete(r.direct, id)
+		}
+	}
+}
+
+// SetDirectAddress запоминает адрес пира на выбранной ICE паре (пусто — прямого пути
+// больше нет). Возвращает true, если адрес изменился.
+func (r *Reconciler) SetDirectAddress(peerID, address string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.direct[peerID] == address {
+		return false
+	}
+	if address == "" {
+		delete(r.direct, peerID)
+	} else {
+		r.direct[peerID] = address
+	}
+	return true
+}
+
+// Reconcile приводит пиров устройства к статическим и обнаруженным пирам
+func (r *Reconciler) Reconcile() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.tunnel.Peers()
+	if err == nil {
+		err = r.tunnel.SetPeers(r.desiredLocked(current))
+	}
+	r.reconciles++
+	if err != nil {
+		r.lastError = err.Error()
+		return err
+	}
+	r.lastError = ""
+	return nil
+}
+
+// desiredLocked собирает желаемый список пиров устройства. Вызывается под r.mu.
+func (r *Reconciler) desiredLocked(current []PeerStatus) []Peer {
+	statuses := make(map[string]PeerStatus, len(current))
+	for _, status := range current {
+		statuses[status.PublicKey] = status
+	}
+
+	desired := slices.Clone(r.static)
+	seen := make(map[string]bool, len(desired)+len(r.discovered))
+	for _, peer := range desired {
+		seen[peer.PublicKey] = true
+	}
+	for _, peer := range r.discovered {
+		if seen[peer.PublicKey] || len(peer.AllowedIPs) == 0 {
+			continue
+		}
+		if _, err := decodeKey(peer.PublicKey); err != nil {
+			r.logger.Debug("Skipping peer without WireGuard key", "peer_id", peer.ID, "error", err)
+			continue
+		}
+		seen[peer.PublicKey] = true
+		desired = append(desired, Peer{
+			PublicKey:           peer.PublicKey,
+			Endpoint:            r.endpointLocked(peer, statuses[peer.PublicKey]),
+			AllowedIPs:          peer.AllowedIPs,
+			PersistentKeepalive: r.keepalive,
+		})
+	}
+	return desired
+}
+
+// endpointLocked выбирает endpoint пира: прямой путь ICE, иначе текущий endpoint
+// устройства, пока по нему проходят handshake (пир мог сменить адрес), иначе адрес
+// из discovery. Пустой результат оставляет endpoint устройства. Вызывается под r.mu.
+func (r *Reconciler) endpointLocked(peer DiscoveredPeer, status PeerStatus) string {
+	if endpoint := directEndpoint(r.direct[peer.ID], peer.Endpoint); endpoint != "" {
+		return endpoint
+	}
+	if status.Endpoint != "" && time.Since(status.LastHandshake) < handshakeFresh {
+		return ""
+	}
+	return peer.Endpoint
+}
+
+// directEndpoint строит endpoint WireGuard по прямому пути ICE: ICE доказывает, что хост
+// пира достижим напрямую, а WireGuard пира слушает порт из discovery
+func directEndpoint(address, discovered string) string {
+	if address == "" || discovered == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(discovered)
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// GetMetrics returns reconciler metrics
+func (r *Reconciler) GetMetrics() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metrics := map[string]interface{}{
+		"static_peers":     len(r.static),
+		"discovered_peers": len(r.discovered),
+		"direct_peers":     len(r.direct),
+		"reconciles":       r.reconciles,
+	}
+	if r.lastError != "" {
+		metrics["last_error"] = r.lastError
+	}
+	return metrics
+}
@@ -0,0 +1,124 @@
+package wireguard
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/tun/tuntest"
+)
+
+func TestReconciler_SyncsDiscoveredPeers(t *testing.T) {
+	private, _ := generateKeys(t)
+	_, relayKey := generateKeys(t)
+	_, keyB := generateKeys(t)
+	_, keyC := generateKeys(t)
+
+	device := NewDevice(nil, testLogger{})
+	if err := device.start(tuntest.NewChannelTUN().TUN(), conn.NewDefaultBind(), &Interface{PrivateKey: private}); err != nil {
+		t.Fatalf("Failed to start device: %v", err)
+	}
+	defer device.Close() //nolint:errcheck // test cleanup
+
+	reconciler := NewReconciler(device, 25*time.Second, testLogger{})
+	reconciler.SetStaticPeers([]Peer{{PublicKey: relayKey, Endpoint: "192.0.2.1:51820", AllowedIPs: []string{"10.0.0.0/24"}}})
+	reconciler.SetDiscoveredPeers([]DiscoveredPeer{
+		{ID: "b", PublicKey: keyB, Endpoint: "198.51.100.2:51820", AllowedIPs: []string{"10.0.0.2/32"}},
+		{ID: "c", PublicKey: keyC, Endpoint: "bad-endpoint", AllowedIPs: []string{"10.0.0.3/32"}},
+		{ID: "no-key", PublicKey: "generated-quic-key-1", AllowedIPs: []string{"10.0.0.4/32"}},
+		{ID: "relay-duplicate", PublicKey: relayKey, AllowedIPs: []string{"10.0.0.5/32"}},
+	})
+	if err := reconciler.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	peers := peersByKey(t, device)
+	if len(peers) != 3 || peers[relayKey].AllowedIPs[0] != "10.0.0.0/24" ||
+		peers[keyB].Endpoint != "198.51.100.2:51820" || peers[keyB].PersistentKeepalive != 25*time.Second ||
+		peers[keyC].Endpoint != "" {
+		t.Fatalf("Unexpected peers: %+v", peers)
+	}
+
+	// ICE found a direct path to b: its WireGuard port stays the discovered one
+	if !reconciler.SetDirectAddress("b", "192.168.1.20:40000") || reconciler.SetDirectAddress("b", "192.168.1.20:40000") {
+		t.Error("SetDirectAddress should report only actual changes")
+	}
+	if err := reconciler.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if endpoint := peersByKey(t, device)[keyB].Endpoint; endpoint != "192.168.1.20:51820" {
+		t.Errorf("Expected direct endpoint, got %s", endpoint)
+	}
+
+	// c left the mesh; without a handshake on the direct path b returns to the discovered endpoint
+	reconciler.SetDirectAddress("b", "")
+	reconciler.SetDiscoveredPeers([]DiscoveredPeer{
+		{ID: "b", PublicKey: keyB, Endpoint: "198.51.100.2:51820", AllowedIPs: []string{"10.0.0.2/32", "192.168.50.0/24"}},
+	})
+	if err := reconciler.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	peers = peersByKey(t, device)
+	if len(peers) != 2 || peers[keyB].Endpoint != "198.51.100.2:51820" ||
+		!slices.Equal(peers[keyB].AllowedIPs, []string{"10.0.0.2/32", "192.168.50.0/24"}) {
+		t.Errorf("Unexpected peers after update: %+v", peers)
+	}
+
+	metrics := reconciler.GetMetrics()
+	if metrics["static_peers"] != 1 || metrics["discovered_peers"] != 1 || metrics["direct_peers"] != 0 || metrics["reconciles"] != 3 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+}
+
+func TestReconciler_KeepsWorkingEndpoint(t *testing.T) {
+	_, key := generateKeys(t)
+	reconciler := NewReconciler(nil, 0, testLogger{})
+	peer := DiscoveredPeer{ID: "b", PublicKey: key, Endpoint: "198.51.100.2:51820", AllowedIPs: []string{"10.0.0.2/32"}}
+
+	// The peer roamed: a recent handshake on another address keeps that address
+	fresh := PeerStatus{PublicKey: key, Endpoint: "203.0.113.7:51820", LastHandshake: time.Now().Add(-time.Minute)}
+	if endpoint := reconciler.endpointLocked(peer, fresh); endpoint != "" {
+		t.Errorf("Expected current endpoint to be kept, got %q", endpoint)
+	}
+	stale := PeerStatus{PublicKey: key, Endpoint: "203.0.113.7:51820", LastHandshake: time.Now().Add(-10 * time.Minute)}
+	if endpoint := reconciler.endpointLocked(peer, stale); endpoint != peer.Endpoint {
+		t.Errorf("Expected discovered endpoint for stale path, got %q", endpoint)
+	}
+	reconciler.direct["b"] = "[fd00::20]:40000"
+	if endpoint := reconciler.endpointLocked(peer, fresh); endpoint != "[fd00::20]:51820" {
+		t.Errorf("Expected direct endpoint to win, got %q", endpoint)
+	}
+}
+
+// TestReconciler_ResolvesEndpointsBeforeReconcile tests that discovered endpoints are
+// resolved when they are set, so Reconcile works with addresses only
+func TestReconciler_ResolvesEndpointsBeforeReconcile(t *testing.T) {
+	reconciler := NewReconciler(nil, 0, testLogger{})
+	reconciler.SetDiscoveredPeers([]DiscoveredPeer{
+		{ID: "b", Endpoint: "[::ffff:198.51.100.2]:51820"},
+		{ID: "c", Endpoint: "bad-endpoint"},
+		{ID: "d"},
+	})
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+	got := make([]string, 0, len(reconciler.discovered))
+	for _, peer := range reconciler.discovered {
+		got = append(got, peer.Endpoint)
+	}
+	if want := []string{"198.51.100.2:51820", "", ""}; !slices.Equal(got, want) {
+		t.Errorf("Expected resolved endpoints %v, got %v", want, got)
+	}
+}
+
+func peersByKey(t *testing.T, device *Device) map[string]PeerStatus {
+	t.Helper()
+	peers, err := device.Peers()
+	if err != nil {
+		t.Fatalf("Peers failed: %v", err)
+	}
+	byKey := make(map[string]PeerStatus, len(peers))
+	for _, peer := range peers {
+		byKey[peer.PublicKey] = peer
+	}
+	return byKey
+}
@@ -0,0 +1,29 @@
+package wireguard
+
+import "time"
+
+// Tunnel устройство WireGuard: встроенное (Device) или интерфейс ядра (KernelDevice)
+type Tunnel interface {
+	Start(iface *Interface) error
+	SetPeers(peers []Peer) error
+	UpsertPeer(peer Peer) error
+	RemovePeer(publicKey string) error
+	Peers() ([]PeerStatus, error)
+	LatestHandshake() (time.Time, error)
+	Name() string
+	Close() error
+	GetMetrics() map[string]interface{}
+}
+
+var (
+	_ Tunnel = (*Device)(nil)
+	_ Tunnel = (*KernelDevice)(nil)
+)
+
+// NewTunnel создает устройство WireGuard режима config.Mode (по умолчанию — ядра)
+func NewTunnel(config *Config, logger Logger) Tunnel {
+	if config != nil && (config.Mode == ModeUserspace || config.Mode == ModeNetstack) {
+		return NewDevice(config, logger)
+	}
+	return NewKernelDevice(config, logger)
+}